@@ -33,13 +33,7 @@ func ArmorWriterWithTypeChecksum(w io.Writer, armorType string, checksum bool) (
 // ArmorWriterWithTypeAndCustomHeaders returns a io.WriteCloser,
 // which armors input with the given armorType and headers.
 func ArmorWriterWithTypeAndCustomHeaders(w io.Writer, armorType, version, comment string) (io.WriteCloser, error) {
-	headers := make(map[string]string)
-	if version != "" {
-		headers["Version"] = version
-	}
-	if comment != "" {
-		headers["Comment"] = comment
-	}
+	headers := HeadersWithVersionAndComment(version, comment)
 	return armor.EncodeWithChecksumOption(w, armorType, headers, constants.ArmorChecksumEnabled)
 }
 
@@ -81,13 +75,7 @@ func ArmorWithTypeAndCustomHeaders(input []byte, armorType, version, comment str
 // ArmorWithTypeAndCustomHeadersChecksum armors input with the given armorType and
 // headers and checksum option.
 func ArmorWithTypeAndCustomHeadersChecksum(input []byte, armorType, version, comment string, checksum bool) (string, error) {
-	headers := make(map[string]string)
-	if version != "" {
-		headers["Version"] = version
-	}
-	if comment != "" {
-		headers["Comment"] = comment
-	}
+	headers := HeadersWithVersionAndComment(version, comment)
 	buffer, err := armorWithTypeAndHeaders(input, armorType, headers, checksum)
 	if err != nil {
 		return "", err
@@ -98,13 +86,7 @@ func ArmorWithTypeAndCustomHeadersChecksum(input []byte, armorType, version, com
 // ArmorWithTypeAndCustomHeadersBytes armors input with the given armorType and
 // headers.
 func ArmorWithTypeAndCustomHeadersBytes(input []byte, armorType, version, comment string) ([]byte, error) {
-	headers := make(map[string]string)
-	if version != "" {
-		headers["Version"] = version
-	}
-	if comment != "" {
-		headers["Comment"] = comment
-	}
+	headers := HeadersWithVersionAndComment(version, comment)
 	buffer, err := armorWithTypeAndHeaders(input, armorType, headers, constants.ArmorChecksumEnabled)
 	if err != nil {
 		return nil, err
@@ -164,6 +146,86 @@ func ArmorPGPMessageChecksum(signature []byte, checksum bool) (string, error) {
 	return ArmorWithTypeChecksum(signature, constants.PGPMessageHeader, checksum)
 }
 
+// DefaultLineLength is the number of base64 characters per armored line
+// if no other line length is configured.
+const DefaultLineLength = 64
+
+// MaxLineLength is the maximum number of base64 characters per armored line
+// allowed by RFC 9580.
+const MaxLineLength = 76
+
+// Options customizes the armored output.
+// Not supported on go-mobile clients.
+type Options struct {
+	// Headers are written as armor headers, e.g., Version or Comment.
+	// If nil, the default headers are used. An empty map omits all headers.
+	Headers map[string]string
+	// LineLength defines the number of base64 characters per line.
+	// Must be a multiple of four and at most MaxLineLength.
+	// If zero, DefaultLineLength is used.
+	LineLength int
+	// OmitChecksum indicates that no CRC24 checksum is appended,
+	// as recommended by RFC 9580.
+	OmitChecksum bool
+}
+
+// ArmorWriterWithOptions returns a io.WriteCloser which, when written to, writes
+// armored data to w with the given armorType and options.
+// If options is nil, the default options are used.
+func ArmorWriterWithOptions(w io.Writer, armorType string, options *Options) (io.WriteCloser, error) {
+	if options == nil {
+		options = &Options{}
+	}
+	if options.LineLength != 0 && !ValidLineLength(options.LineLength) {
+		return nil, errors.Errorf("armor: invalid line length %d", options.LineLength)
+	}
+	headers := options.Headers
+	if headers == nil {
+		headers = internal.ArmorHeaders
+	}
+	checksum := constants.ArmorChecksumEnabled && !options.OmitChecksum
+	if options.LineLength != 0 && options.LineLength != DefaultLineLength {
+		w = &lineLengthWriter{out: w, lineLength: options.LineLength}
+	}
+	return armor.EncodeWithChecksumOption(w, armorType, headers, checksum)
+}
+
+// ValidLineLength checks if lineLength is a multiple of four
+// between four and MaxLineLength.
+func ValidLineLength(lineLength int) bool {
+	return lineLength > 0 && lineLength <= MaxLineLength && lineLength%4 == 0
+}
+
+// ArmorWithOptions armors input with the given armorType and options.
+// If options is nil, the default options are used.
+func ArmorWithOptions(input []byte, armorType string, options *Options) ([]byte, error) {
+	var b bytes.Buffer
+	w, err := ArmorWriterWithOptions(&b, armorType, options)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = w.Write(input); err != nil {
+		return nil, errors.Wrap(err, "armor: unable to write armored to buffer")
+	}
+	if err := w.Close(); err != nil {
+		return nil, errors.Wrap(err, "armor: unable to close armor buffer")
+	}
+	return b.Bytes(), nil
+}
+
+// HeadersWithVersionAndComment returns armor headers with the given version and comment.
+// Empty parameters are omitted from the headers.
+func HeadersWithVersionAndComment(version, comment string) map[string]string {
+	headers := make(map[string]string)
+	if version != "" {
+		headers["Version"] = version
+	}
+	if comment != "" {
+		headers["Comment"] = comment
+	}
+	return headers
+}
+
 const armorPrefix = "-----BEGIN PGP"
 const maxGarbageBytes = 128
 
@@ -198,3 +260,67 @@ func armorWithTypeAndHeaders(input []byte, armorType string, headers map[string]
 	}
 	return &b, nil
 }
+
+// lineLengthWriter re-wraps the base64 body of armored data written to it
+// to lines of lineLength characters. The armor headers and the armor tail,
+// i.e., the checksum and the end line, are written unmodified.
+type lineLengthWriter struct {
+	out        io.Writer
+	lineLength int
+	inBody     bool
+	inTail     bool
+	lineUsed   int
+	lineStart  bool
+	headerLen  int
+}
+
+func (w *lineLengthWriter) Write(data []byte) (int, error) {
+	if w.inTail {
+		return w.out.Write(data)
+	}
+	buffer := internal.GetBuffer()
+	defer internal.PutBuffer(buffer)
+	for i, c := range data {
+		switch {
+		case w.inTail:
+			buffer.Write(data[i:])
+			if _, err := w.out.Write(buffer.Bytes()); err != nil {
+				return 0, err
+			}
+			return len(data), nil
+		case !w.inBody:
+			// Armor start line and headers end with an empty line.
+			buffer.WriteByte(c)
+			if c == '\n' {
+				if w.headerLen == 0 && w.lineStart {
+					w.inBody = true
+				}
+				w.lineStart = true
+				w.headerLen = 0
+			} else {
+				w.headerLen++
+			}
+		case c == '\n':
+			w.lineStart = true
+		case w.lineStart && (c == '=' || c == '-'):
+			// Checksum or armor end line.
+			if w.lineUsed > 0 {
+				buffer.WriteByte('\n')
+			}
+			buffer.WriteByte(c)
+			w.inTail = true
+		default:
+			w.lineStart = false
+			buffer.WriteByte(c)
+			w.lineUsed++
+			if w.lineUsed == w.lineLength {
+				buffer.WriteByte('\n')
+				w.lineUsed = 0
+			}
+		}
+	}
+	if _, err := w.out.Write(buffer.Bytes()); err != nil {
+		return 0, err
+	}
+	return len(data), nil
+}
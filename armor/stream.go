@@ -0,0 +1,177 @@
+package armor
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/pkg/errors"
+)
+
+// decoderBufferSize is the size of the read buffer of a Decoder,
+// which is also the window in which binary input is told apart from garbage before an armor header.
+const decoderBufferSize = 4096
+
+var (
+	byteOrderMark = []byte{0xef, 0xbb, 0xbf}
+	armorStart    = []byte("-----BEGIN ")
+)
+
+// Block is a block of data read by a Decoder.
+type Block struct {
+	// Armored indicates that the block was armored.
+	// Binary input is read as a single block that is not armored.
+	Armored bool
+	// Type is the armor type, e.g., "PGP MESSAGE", or empty for binary input.
+	Type string
+	// Header contains the armor headers, or is empty for binary input.
+	Header map[string]string
+	// Body reads the unarmored data of the block.
+	Body io.Reader
+}
+
+// Decoder reads armored or binary OpenPGP data from a stream.
+// The input is detected as armored if it contains an armor header line,
+// where a leading byte order mark, whitespace, and lines of garbage
+// before the header are skipped. Concatenated armored blocks are read one after the other.
+// Not supported on go-mobile clients.
+type Decoder struct {
+	in      *bufio.Reader
+	started bool
+	done    bool
+	blocks  int
+	current *Block
+}
+
+// NewDecoder returns a decoder that reads the blocks from in.
+func NewDecoder(in io.Reader) *Decoder {
+	return &Decoder{in: bufio.NewReaderSize(in, decoderBufferSize)}
+}
+
+// Next returns the next block of the input, or io.EOF if there are no more blocks.
+// Calling Next discards the unread data of the previous block.
+func (d *Decoder) Next() (*Block, error) {
+	if d.done {
+		return nil, io.EOF
+	}
+	if !d.started {
+		d.started = true
+		binary, err := d.detectBinary()
+		if err != nil {
+			d.done = true
+			return nil, err
+		}
+		if binary {
+			d.done = true
+			return &Block{Header: map[string]string{}, Body: d.in}, nil
+		}
+	}
+	if d.current != nil {
+		if _, err := io.Copy(io.Discard, d.current.Body); err != nil {
+			d.done = true
+			return nil, errors.Wrap(err, "armor: unable to unarmor")
+		}
+		d.current = nil
+	}
+	// Since the input is already buffered, the armor decoder reads from it directly
+	// and leaves the data after the end of the block for the next call.
+	block, err := armor.Decode(d.in)
+	if err != nil {
+		d.done = true
+		if errors.Is(err, io.EOF) && d.blocks > 0 {
+			return nil, io.EOF
+		}
+		return nil, errors.Wrap(err, "armor: unable to unarmor")
+	}
+	d.blocks++
+	d.current = &Block{
+		Armored: true,
+		Type:    block.Type,
+		Header:  block.Header,
+		Body:    block.Body,
+	}
+	return d.current, nil
+}
+
+// detectBinary skips a leading byte order mark and whitespace and checks if
+// the input is binary, i.e., starts with a packet tag and contains no armor header.
+// Returns io.EOF if the input is empty.
+func (d *Decoder) detectBinary() (bool, error) {
+	if prefix, _ := d.in.Peek(len(byteOrderMark)); bytes.Equal(prefix, byteOrderMark) {
+		_, _ = d.in.Discard(len(byteOrderMark))
+	}
+	for {
+		c, err := d.in.ReadByte()
+		if errors.Is(err, io.EOF) {
+			return false, io.EOF
+		}
+		if err != nil {
+			return false, errors.Wrap(err, "armor: unable to read input")
+		}
+		if c == ' ' || c == '\t' || c == '\r' || c == '\n' {
+			continue
+		}
+		_ = d.in.UnreadByte()
+		if c&0x80 == 0 {
+			// Packet tags have the most significant bit set.
+			return false, nil
+		}
+		break
+	}
+	window, err := d.in.Peek(decoderBufferSize)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, bufio.ErrBufferFull) {
+		return false, errors.Wrap(err, "armor: unable to read input")
+	}
+	return !bytes.Contains(window, armorStart), nil
+}
+
+// Encoder writes concatenated armored blocks to a stream.
+// Not supported on go-mobile clients.
+type Encoder struct {
+	out     io.Writer
+	options *Options
+	open    bool
+}
+
+// NewEncoder returns an encoder that writes armored blocks with the options to out.
+// If options is nil, the default options are used.
+func NewEncoder(out io.Writer, options *Options) *Encoder {
+	return &Encoder{out: out, options: options}
+}
+
+// Encode starts a new armored block with the armor type, e.g., "PGP MESSAGE".
+// The data written to the returned writer is armored, and closing it ends the block,
+// which must happen before the next block is started.
+// Each block ends with a line break, such that the blocks can be read with a Decoder.
+func (e *Encoder) Encode(armorType string) (io.WriteCloser, error) {
+	if e.open {
+		return nil, errors.New("armor: the previous block is not closed")
+	}
+	w, err := ArmorWriterWithOptions(e.out, armorType, e.options)
+	if err != nil {
+		return nil, err
+	}
+	e.open = true
+	return &blockWriter{WriteCloser: w, encoder: e}, nil
+}
+
+// blockWriter ends an armored block of an Encoder with a line break.
+type blockWriter struct {
+	io.WriteCloser
+	encoder *Encoder
+	closed  bool
+}
+
+func (w *blockWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	w.encoder.open = false
+	if err := w.WriteCloser.Close(); err != nil {
+		return err
+	}
+	_, err := w.encoder.out.Write([]byte("\n"))
+	return err
+}
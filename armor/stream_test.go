@@ -0,0 +1,92 @@
+package armor
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/ProtonMail/gopenpgp/v3/constants"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// readBlocks returns the blocks of the input and their data.
+func readBlocks(t *testing.T, input []byte) ([]*Block, [][]byte) {
+	decoder := NewDecoder(bytes.NewReader(input))
+	var blocks []*Block
+	var data [][]byte
+	for {
+		block, err := decoder.Next()
+		if err == io.EOF {
+			return blocks, data
+		}
+		require.NoError(t, err)
+		blocks = append(blocks, block)
+		data = append(data, blockData(t, block))
+	}
+}
+
+func blockData(t *testing.T, block *Block) []byte {
+	data, err := io.ReadAll(block.Body)
+	require.NoError(t, err)
+	return data
+}
+
+func TestEncoderDecoder(t *testing.T) {
+	var buffer bytes.Buffer
+	encoder := NewEncoder(&buffer, &Options{OmitChecksum: true})
+	for _, data := range []string{"first", "second"} {
+		w, err := encoder.Encode(constants.PGPMessageHeader)
+		require.NoError(t, err)
+		_, err = encoder.Encode(constants.PGPMessageHeader)
+		assert.Error(t, err, "previous block is not closed")
+		_, err = w.Write([]byte(data))
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+	}
+
+	// Leading whitespace, a byte order mark, and garbage are skipped.
+	input := append([]byte("\xef\xbb\xbf \r\nsome garbage\n"), buffer.Bytes()...)
+	blocks, data := readBlocks(t, input)
+	require.Len(t, blocks, 2)
+	for index, expected := range []string{"first", "second"} {
+		assert.True(t, blocks[index].Armored)
+		assert.Exactly(t, constants.PGPMessageHeader, blocks[index].Type)
+		assert.Exactly(t, expected, string(data[index]))
+	}
+
+	// Unread blocks are skipped.
+	decoder := NewDecoder(bytes.NewReader(input))
+	_, err := decoder.Next()
+	require.NoError(t, err)
+	block, err := decoder.Next()
+	require.NoError(t, err)
+	assert.Exactly(t, "second", string(blockData(t, block)))
+	_, err = decoder.Next()
+	assert.Exactly(t, io.EOF, err)
+}
+
+func TestDecoderBinary(t *testing.T) {
+	binary := []byte{0xc3, 0x04, 0x04, 0x03, 0x00, 0x01}
+	blocks, data := readBlocks(t, binary)
+	require.Len(t, blocks, 1)
+	assert.False(t, blocks[0].Armored)
+	assert.Empty(t, blocks[0].Type)
+	assert.Exactly(t, binary, data[0])
+
+	blocks, _ = readBlocks(t, nil)
+	assert.Empty(t, blocks)
+	_, err := NewDecoder(bytes.NewReader([]byte("no armor"))).Next()
+	assert.Error(t, err)
+}
+
+func BenchmarkArmorWithLineLength(b *testing.B) {
+	data := bytes.Repeat([]byte{0xA5}, 4096)
+	options := &Options{LineLength: 76}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := ArmorWithOptions(data, constants.PGPMessageHeader, options); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
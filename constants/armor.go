@@ -17,4 +17,5 @@ const (
 	PGPSignatureHeader   = "PGP SIGNATURE"
 	PublicKeyHeader      = "PGP PUBLIC KEY BLOCK"
 	PrivateKeyHeader     = "PGP PRIVATE KEY BLOCK"
+	KeyShareHeader       = "PGP PRIVATE KEY SHARE"
 )
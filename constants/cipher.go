@@ -16,6 +16,36 @@ const (
 	SIGNATURE_NO_VERIFIER int = 2
 	SIGNATURE_FAILED      int = 3
 	SIGNATURE_BAD_CONTEXT int = 4
+	// SIGNATURE_BAD_INTENDED_RECIPIENT indicates that the decryption key is not
+	// listed in the intended recipients of the signature, i.e., the message
+	// might have been re-encrypted to a different recipient.
+	SIGNATURE_BAD_INTENDED_RECIPIENT int = 5
+	// SIGNATURE_POLICY_VIOLATION indicates that the signature is valid but
+	// refused by the verification policy, e.g., because of a weak hash algorithm.
+	SIGNATURE_POLICY_VIOLATION int = 6
+)
+
+// Reasons of failed signatures, which further describe the signature status,
+// e.g., to explain to users why a signature of a multi-signature message failed.
+const (
+	// SIGNATURE_REASON_NONE indicates that the signature is valid.
+	SIGNATURE_REASON_NONE int = 0
+	// SIGNATURE_REASON_MISSING_KEY indicates that no verification key matches the issuer.
+	SIGNATURE_REASON_MISSING_KEY int = 1
+	// SIGNATURE_REASON_BAD_HASH indicates that the hash of the data does not match the signature,
+	// i.e., the data has been modified.
+	SIGNATURE_REASON_BAD_HASH int = 2
+	// SIGNATURE_REASON_WRONG_KEY indicates that the signature does not verify
+	// with the verification key that matches its issuer.
+	SIGNATURE_REASON_WRONG_KEY int = 3
+	// SIGNATURE_REASON_EXPIRED indicates that the signature is expired or created in the future.
+	SIGNATURE_REASON_EXPIRED int = 4
+	// SIGNATURE_REASON_KEY_EXPIRED indicates that the signing key was expired at the signature creation time.
+	SIGNATURE_REASON_KEY_EXPIRED int = 5
+	// SIGNATURE_REASON_KEY_REVOKED indicates that the signing key is revoked.
+	SIGNATURE_REASON_KEY_REVOKED int = 6
+	// SIGNATURE_REASON_OTHER indicates any other failure, see the status and the error of the signature.
+	SIGNATURE_REASON_OTHER int = 7
 )
 
 // SecurityLevel constants.
@@ -37,3 +67,18 @@ const (
 	CipherAES192 int8 = 8
 	CipherAES256 int8 = 9
 )
+
+// Wraps the packet.AEADMode enum from go-crypto
+// for go-mobile clients.
+// int8 type for go-mobile support.
+const (
+	AEADModeEAX int8 = 1
+	AEADModeOCB int8 = 2
+	AEADModeGCM int8 = 3
+)
+
+// Bounds of the SEIPDv2 chunk size in bytes as defined in RFC 9580.
+const (
+	AEADMinChunkSize int = 1 << 6
+	AEADMaxChunkSize int = 1 << 22
+)
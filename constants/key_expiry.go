@@ -0,0 +1,11 @@
+package constants
+
+// Statuses of the keys listed by (KeyRing).ExpiryReport.
+const (
+	// KeyExpiryExpiring marks a primary key or subkey that expires within the window of the report.
+	KeyExpiryExpiring = "expiring"
+	// KeyExpiryExpired marks an expired primary key or subkey.
+	KeyExpiryExpired = "expired"
+	// KeyExpiryRevoked marks a revoked primary key or subkey.
+	KeyExpiryRevoked = "revoked"
+)
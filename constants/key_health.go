@@ -0,0 +1,37 @@
+package constants
+
+// Codes of the issues found by (Key).HealthReport.
+const (
+	// KeyHealthWeakAlgorithm flags a primary key or subkey with a deprecated
+	// algorithm, i.e., DSA or ElGamal, or an RSA key of less than 2048 bits.
+	KeyHealthWeakAlgorithm = "weak-algorithm"
+	// KeyHealthSHA1SelfSignature flags a self-signature that uses SHA-1.
+	KeyHealthSHA1SelfSignature = "sha1-self-signature"
+	// KeyHealthMissingCrossCertification flags a signing subkey
+	// whose binding signature has no embedded primary key binding signature.
+	KeyHealthMissingCrossCertification = "missing-cross-certification"
+	// KeyHealthRevokedKey flags a revoked primary key.
+	KeyHealthRevokedKey = "revoked-key"
+	// KeyHealthExpiredKey flags an expired primary key.
+	KeyHealthExpiredKey = "expired-key"
+	// KeyHealthExpiringKey flags a primary key that expires soon.
+	KeyHealthExpiringKey = "expiring-key"
+	// KeyHealthExpiredSubkey flags an expired subkey.
+	KeyHealthExpiredSubkey = "expired-subkey"
+	// KeyHealthExpiringSubkey flags a subkey that expires soon.
+	KeyHealthExpiringSubkey = "expiring-subkey"
+	// KeyHealthNoEncryptionSubkey flags a key without a valid encryption key.
+	KeyHealthNoEncryptionSubkey = "no-encryption-subkey"
+	// KeyHealthOversizedUnhashedArea flags a signature with a large unhashed subpacket area,
+	// which is not covered by the signature and may be used to flood the key.
+	KeyHealthOversizedUnhashedArea = "oversized-unhashed-area"
+)
+
+// Severities of the issues found by (Key).HealthReport.
+// int8 type for go-mobile clients.
+const (
+	// KeyHealthWarning indicates that the key works but should be updated.
+	KeyHealthWarning int8 = 1
+	// KeyHealthError indicates that the key or a part of it is insecure or unusable.
+	KeyHealthError int8 = 2
+)
@@ -0,0 +1,11 @@
+package constants
+
+// Reason codes of key and user id revocations.
+// int8 type for go-mobile clients.
+const (
+	RevocationNoReason       int8 = 0
+	RevocationKeySuperseded  int8 = 1
+	RevocationKeyCompromised int8 = 2
+	RevocationKeyRetired     int8 = 3
+	RevocationUserIDNotValid int8 = 32
+)
@@ -0,0 +1,17 @@
+package constants
+
+// Wraps the s2k.Mode enum from go-crypto
+// for go-mobile clients.
+// int8 type for go-mobile support.
+const (
+	// S2KIteratedSalted derives keys from passphrases with an iterated and salted hash.
+	S2KIteratedSalted int8 = 3
+	// S2KArgon2 derives keys from passphrases with the memory-hard Argon2 function.
+	S2KArgon2 int8 = 4
+)
+
+// Bounds of the iteration count of iterated and salted S2K in bytes as defined in RFC 9580.
+const (
+	S2KMinIterationCount int = 1024
+	S2KMaxIterationCount int = 65011712
+)
@@ -15,4 +15,13 @@ const (
 	SigTypeKeyRevocation           int8 = 0x20
 	SigTypeSubkeyRevocation        int8 = 0x28
 	SigTypeCertificationRevocation int8 = 0x30
+	SigTypeTimestamp               int8 = 0x40
 )
+
+// LocalCertificationName is the name of the critical notation that marks
+// certifications of user ids as local, i.e., not meant to be exported.
+const LocalCertificationName = "local-certification@proton.ch"
+
+// FileModeName is the name of the notation that stores the permission bits
+// of an encrypted file as an octal string, e.g., "0644".
+const FileModeName = "file-mode@proton.ch"
@@ -0,0 +1,18 @@
+package constants
+
+// Codes of the issues found by (Key).AuditSubkeyBindings.
+const (
+	// SubkeyBindingMissing flags a subkey without binding signature.
+	SubkeyBindingMissing = "missing-binding"
+	// SubkeyBindingInvalid flags a binding signature that does not verify.
+	SubkeyBindingInvalid = "invalid-binding"
+	// SubkeyBindingMissingEmbeddedSignature flags a binding signature of a signing subkey
+	// without embedded primary key binding signature, i.e., without cross-certification.
+	SubkeyBindingMissingEmbeddedSignature = "missing-embedded-signature"
+	// SubkeyBindingInvalidEmbeddedSignature flags an embedded primary key binding signature
+	// that does not verify.
+	SubkeyBindingInvalidEmbeddedSignature = "invalid-embedded-signature"
+	// SubkeyBindingWeakHash flags a binding or embedded signature that uses
+	// MD5, SHA-1, or RIPEMD-160.
+	SubkeyBindingWeakHash = "weak-hash"
+)
@@ -0,0 +1,16 @@
+package constants
+
+// Inclusion status of keys in a key transparency log.
+// int8 type for go-mobile clients.
+const (
+	// TransparencyUnknown indicates that the inclusion of the key was not checked.
+	TransparencyUnknown int8 = 0
+	// TransparencyIncluded indicates that the key is included in the transparency log.
+	TransparencyIncluded int8 = 1
+	// TransparencyNotIncluded indicates that the key is absent from the transparency log,
+	// or that the log contains a different version of the key.
+	TransparencyNotIncluded int8 = 2
+	// TransparencyUnverifiable indicates that the inclusion could not be verified,
+	// e.g., since the log is not reachable or its proofs are invalid.
+	TransparencyUnverifiable int8 = 3
+)
@@ -0,0 +1,33 @@
+package constants
+
+// Owner trust levels of the web of trust, i.e., how much a key holder
+// is trusted to certify the keys of others.
+// int8 type for go-mobile clients.
+const (
+	// OwnerTrustUnknown is the default, certifications of the key are ignored.
+	OwnerTrustUnknown int8 = 0
+	// OwnerTrustNever indicates that certifications of the key are ignored.
+	OwnerTrustNever int8 = 1
+	// OwnerTrustMarginal indicates that certifications of the key count as marginal.
+	OwnerTrustMarginal int8 = 2
+	// OwnerTrustFull indicates that certifications of the key count as complete.
+	OwnerTrustFull int8 = 3
+	// OwnerTrustUltimate marks own keys, which are valid without certifications
+	// and whose certifications count as complete.
+	OwnerTrustUltimate int8 = 4
+)
+
+// Validity levels of user ids computed by the web of trust.
+// int8 type for go-mobile clients.
+const (
+	// ValidityUnknown indicates that the user id is not certified by enough trusted keys,
+	// or that the user id or its key is invalid.
+	ValidityUnknown int8 = 0
+	// ValidityMarginal indicates that the user id is certified by trusted keys,
+	// but not by enough to be fully valid.
+	ValidityMarginal int8 = 1
+	// ValidityFull indicates that the user id is certified by enough trusted keys.
+	ValidityFull int8 = 2
+	// ValidityUltimate indicates that the user id belongs to a key with ultimate owner trust.
+	ValidityUltimate int8 = 3
+)
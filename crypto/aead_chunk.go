@@ -0,0 +1,256 @@
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/binary"
+	"io"
+	"sync"
+
+	"github.com/ProtonMail/go-crypto/eax"
+	"github.com/ProtonMail/go-crypto/ocb"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/hkdf"
+)
+
+// aeadChunkCipher holds the message key and parameters to decrypt
+// the chunks of a SEIPDv2 (AEAD) data packet independently of each other.
+type aeadChunkCipher struct {
+	mode           packet.AEADMode
+	messageKey     []byte
+	noncePrefix    []byte
+	associatedData []byte
+	chunkSize      int64
+	tagLength      int64
+}
+
+func newAEADChunkCipher(
+	cipherFunc packet.CipherFunction,
+	mode packet.AEADMode,
+	chunkSizeByte byte,
+	salt []byte,
+	key []byte,
+) (*aeadChunkCipher, error) {
+	switch cipherFunc {
+	case packet.CipherAES128, packet.CipherAES192, packet.CipherAES256:
+	default:
+		// newAEAD only instantiates AES.
+		return nil, errors.Errorf("gopenpgp: unsupported aead cipher %d", cipherFunc)
+	}
+	if cipherFunc.KeySize() != len(key) {
+		return nil, errors.New("gopenpgp: session key does not match the data packet cipher")
+	}
+	if chunkSizeByte > 16 {
+		return nil, errors.New("gopenpgp: invalid aead chunk size")
+	}
+	if !mode.IsSupported() {
+		return nil, errors.Errorf("gopenpgp: unsupported aead mode %d", mode)
+	}
+	associatedData := []byte{0xD2, 2, byte(cipherFunc), byte(mode), chunkSizeByte}
+
+	kdf := hkdf.New(sha256.New, key, salt, associatedData)
+	messageKey := make([]byte, len(key))
+	if _, err := io.ReadFull(kdf, messageKey); err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: unable to derive message key")
+	}
+	noncePrefix := make([]byte, mode.IvLength()-8)
+	if _, err := io.ReadFull(kdf, noncePrefix); err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: unable to derive nonce")
+	}
+	return &aeadChunkCipher{
+		mode:           mode,
+		messageKey:     messageKey,
+		noncePrefix:    noncePrefix,
+		associatedData: associatedData,
+		chunkSize:      int64(1) << (chunkSizeByte + 6),
+		tagLength:      int64(mode.TagLength()),
+	}, nil
+}
+
+// newAEAD returns a new AEAD instance for the message key.
+// AEAD instances must not be shared between goroutines, since OCB is not safe for concurrent use.
+func (c *aeadChunkCipher) newAEAD() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(c.messageKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: unsupported aead cipher")
+	}
+	var aead cipher.AEAD
+	switch c.mode {
+	case packet.AEADModeEAX:
+		aead, err = eax.NewEAX(block)
+	case packet.AEADModeOCB:
+		aead, err = ocb.NewOCB(block)
+	case packet.AEADModeGCM:
+		aead, err = cipher.NewGCM(block)
+	default:
+		err = errors.Errorf("unknown aead mode %d", c.mode)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: unsupported aead mode")
+	}
+	return aead, nil
+}
+
+func (c *aeadChunkCipher) nonce(index int64) []byte {
+	nonce := make([]byte, len(c.noncePrefix)+8)
+	copy(nonce, c.noncePrefix)
+	binary.BigEndian.PutUint64(nonce[len(c.noncePrefix):], uint64(index))
+	return nonce
+}
+
+// openChunk decrypts and authenticates the chunk with the given index,
+// and appends the plaintext to dst.
+// dst must not overlap with ciphertext: OCB writes the computed tag to the output
+// before comparing it, thus decrypting in place would accept any tag.
+func (c *aeadChunkCipher) openChunk(aead cipher.AEAD, index int64, dst, ciphertext []byte) ([]byte, error) {
+	plaintext, err := aead.Open(dst, c.nonce(index), ciphertext, c.associatedData)
+	if err != nil {
+		return nil, errors.Errorf("gopenpgp: authentication of aead chunk %d failed", index)
+	}
+	return plaintext, nil
+}
+
+// openFinalTag checks the final authentication tag, which authenticates the plaintext length.
+func (c *aeadChunkCipher) openFinalTag(aead cipher.AEAD, numChunks int64, tag []byte, plaintextLength int64) error {
+	associatedData := make([]byte, len(c.associatedData)+8)
+	copy(associatedData, c.associatedData)
+	binary.BigEndian.PutUint64(associatedData[len(c.associatedData):], uint64(plaintextLength))
+	if _, err := aead.Open(nil, c.nonce(numChunks), tag, associatedData); err != nil {
+		return errors.New("gopenpgp: final authentication tag verification failed")
+	}
+	return nil
+}
+
+// parallelAEADReader decrypts the chunks of a SEIPDv2 packet body on multiple goroutines.
+// On each refill, the next batch of chunks is read sequentially from the ciphertext
+// and then decrypted in parallel, such that the plaintext order is preserved.
+// No goroutines outlive a call to Read.
+type parallelAEADReader struct {
+	ciphertext      io.Reader
+	chunks          *aeadChunkCipher
+	aeads           []cipher.AEAD
+	buffers         [][]byte
+	plaintexts      [][]byte
+	errs            []error
+	lookahead       []byte
+	nextIndex       int64
+	plaintextLength int64
+	pending         [][]byte
+	eof             bool
+	err             error
+}
+
+func newParallelAEADReader(
+	seipd *packet.SymmetricallyEncrypted,
+	key []byte,
+	parallelism int,
+) (*parallelAEADReader, error) {
+	chunks, err := newAEADChunkCipher(seipd.Cipher, seipd.Mode, seipd.ChunkSizeByte, seipd.Salt[:], key)
+	if err != nil {
+		return nil, err
+	}
+	aeads := make([]cipher.AEAD, parallelism)
+	for i := range aeads {
+		if aeads[i], err = chunks.newAEAD(); err != nil {
+			return nil, err
+		}
+	}
+	return &parallelAEADReader{
+		ciphertext: seipd.Contents,
+		chunks:     chunks,
+		aeads:      aeads,
+		buffers:    make([][]byte, parallelism),
+		plaintexts: make([][]byte, parallelism),
+		errs:       make([]error, parallelism),
+	}, nil
+}
+
+func (r *parallelAEADReader) Read(b []byte) (n int, err error) {
+	for len(r.pending) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		if r.eof {
+			return 0, io.EOF
+		}
+		if r.err = r.refill(); r.err != nil {
+			r.pending = nil
+		}
+	}
+	n = copy(b, r.pending[0])
+	r.pending[0] = r.pending[0][n:]
+	if len(r.pending[0]) == 0 {
+		r.pending = r.pending[1:]
+	}
+	return n, nil
+}
+
+// Close reads and authenticates the remaining chunks and the final tag.
+func (r *parallelAEADReader) Close() error {
+	for !r.eof && r.err == nil {
+		r.pending = nil
+		r.err = r.refill()
+	}
+	return r.err
+}
+
+// refill reads the next batch of chunks and decrypts them in parallel.
+func (r *parallelAEADReader) refill() error {
+	segmentLength := int(r.chunks.chunkSize + r.chunks.tagLength)
+	tagLength := int(r.chunks.tagLength)
+	numChunks := 0
+	for numChunks < len(r.aeads) && !r.eof {
+		// Read a full chunk and one more tag, to detect if the final tag follows.
+		if cap(r.buffers[numChunks]) < segmentLength+tagLength {
+			r.buffers[numChunks] = make([]byte, segmentLength+tagLength)
+		}
+		buffer := r.buffers[numChunks][:segmentLength+tagLength]
+		offset := copy(buffer, r.lookahead)
+		read, err := io.ReadFull(r.ciphertext, buffer[offset:])
+		buffer = buffer[:offset+read]
+		if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+			return errors.Wrap(err, "gopenpgp: unable to read aead chunk")
+		}
+		if len(buffer) == segmentLength+tagLength {
+			r.lookahead = append(r.lookahead[:0], buffer[segmentLength:]...)
+			r.buffers[numChunks] = buffer[:segmentLength]
+			numChunks++
+			continue
+		}
+		// The end of the packet: the buffer contains an optional last chunk and the final tag.
+		if len(buffer) < tagLength {
+			return errors.New("gopenpgp: data packet is truncated")
+		}
+		r.eof = true
+		r.lookahead = append(r.lookahead[:0], buffer[len(buffer)-tagLength:]...)
+		if len(buffer) > tagLength {
+			r.buffers[numChunks] = buffer[:len(buffer)-tagLength]
+			numChunks++
+		}
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < numChunks; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			r.plaintexts[i], r.errs[i] = r.chunks.openChunk(r.aeads[i], r.nextIndex+int64(i), r.plaintexts[i][:0], r.buffers[i])
+		}(i)
+	}
+	wg.Wait()
+	r.pending = r.pending[:0]
+	for i := 0; i < numChunks; i++ {
+		if r.errs[i] != nil {
+			return r.errs[i]
+		}
+		r.plaintextLength += int64(len(r.plaintexts[i]))
+		r.pending = append(r.pending, r.plaintexts[i])
+	}
+	r.nextIndex += int64(numChunks)
+	if r.eof {
+		return r.chunks.openFinalTag(r.aeads[0], r.nextIndex, r.lookahead, r.plaintextLength)
+	}
+	return nil
+}
@@ -0,0 +1,269 @@
+package crypto
+
+import (
+	"archive/tar"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/ProtonMail/gopenpgp/v3/internal"
+	"github.com/pkg/errors"
+)
+
+// DirectoryEncryptionOptions defines optional settings for EncryptDirectory.
+type DirectoryEncryptionOptions struct {
+	// SigningKeys provides unlocked keys to sign the archive.
+	// If nil, the archive is not signed.
+	SigningKeys *KeyRing
+	// Armor indicates that the encrypted archive is armored.
+	Armor bool
+}
+
+// DirectoryDecryptionOptions defines optional settings for DecryptDirectory.
+type DirectoryDecryptionOptions struct {
+	// VerificationKeys provides the keys to verify the signature of the archive.
+	// If nil, the signature is not verified.
+	VerificationKeys *KeyRing
+	// RestoreMode indicates that the permission bits stored in the archive are applied
+	// to the extracted files and directories. If not set, files are created with
+	// permission bits 0600 and directories with 0700.
+	RestoreMode bool
+}
+
+// EncryptDirectory encrypts the directory tree at dirPath to the recipients, and writes
+// the pgp message to output. The tree is written as a tar archive into the encrypted message
+// while it is walked, such that no temporary archive is created.
+// The archive contains the directories and regular files with their permission bits
+// and modification times, and the encryption fails on other file types, e.g., symbolic links.
+// The literal data packet carries the name of the directory with the suffix ".tar".
+// The options can be nil.
+// Not supported on go-mobile clients.
+func (p *PGPHandle) EncryptDirectory(dirPath string, output Writer, recipients *KeyRing, options *DirectoryEncryptionOptions) error {
+	if options == nil {
+		options = &DirectoryEncryptionOptions{}
+	}
+	info, err := os.Stat(dirPath)
+	if err != nil {
+		return errors.Wrap(err, "gopenpgp: unable to open directory")
+	}
+	if !info.IsDir() {
+		return errors.New("gopenpgp: can only encrypt directories")
+	}
+	builder := p.Encryption().
+		Recipients(recipients).
+		PlainMetadata(NewFileMetadata(false, filepath.Base(dirPath)+".tar", info.ModTime().Unix()))
+	if options.SigningKeys != nil {
+		builder.SigningKeys(options.SigningKeys)
+	}
+	encHandle, err := builder.New()
+	if err != nil {
+		return err
+	}
+	encoding := Bytes
+	if options.Armor {
+		encoding = Armor
+	}
+	ptWriter, err := encHandle.EncryptingWriter(output, encoding)
+	if err != nil {
+		return err
+	}
+	archive := tar.NewWriter(ptWriter)
+	if err := filepath.WalkDir(dirPath, func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if filePath == dirPath {
+			return nil
+		}
+		return writeArchiveEntry(archive, dirPath, filePath, entry)
+	}); err != nil {
+		return errors.Wrap(err, "gopenpgp: unable to archive directory")
+	}
+	if err := archive.Close(); err != nil {
+		return errors.Wrap(err, "gopenpgp: unable to archive directory")
+	}
+	return ptWriter.Close()
+}
+
+// writeArchiveEntry writes the tar header of the file, and its content if it is a regular file.
+func writeArchiveEntry(archive *tar.Writer, dirPath, filePath string, entry fs.DirEntry) error {
+	info, err := entry.Info()
+	if err != nil {
+		return err
+	}
+	name, err := filepath.Rel(dirPath, filePath)
+	if err != nil {
+		return err
+	}
+	header := &tar.Header{
+		Name:    filepath.ToSlash(name),
+		Mode:    int64(info.Mode().Perm()),
+		ModTime: info.ModTime(),
+		Format:  tar.FormatPAX,
+	}
+	switch {
+	case info.IsDir():
+		header.Typeflag = tar.TypeDir
+		header.Name += "/"
+		return archive.WriteHeader(header)
+	case info.Mode().IsRegular():
+		header.Typeflag = tar.TypeReg
+		header.Size = info.Size()
+	default:
+		return errors.Errorf("unsupported file type of %s", filePath)
+	}
+	if err := archive.WriteHeader(header); err != nil {
+		return err
+	}
+	file, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = file.Close() }()
+	// The header size limits the content if the file grows while it is archived.
+	_, err = internal.Copy(archive, io.LimitReader(file, info.Size()))
+	return err
+}
+
+// DecryptDirectory decrypts a pgp message created by EncryptDirectory from input with the decryption keys,
+// and extracts the archive into dstDir, which is created if it does not exist.
+// The archive is extracted while it is decrypted. Entries that would be written outside of dstDir,
+// entries other than directories and regular files, and files that already exist are rejected.
+// If an error is returned, e.g., since the message has been tampered with,
+// the files extracted so far must be discarded.
+// The returned VerifyResult contains the result of the signature verification of the whole archive.
+// The options can be nil.
+// Not supported on go-mobile clients.
+func (p *PGPHandle) DecryptDirectory(input Reader, dstDir string, decryptionKeys *KeyRing, options *DirectoryDecryptionOptions) (*VerifyResult, error) {
+	if options == nil {
+		options = &DirectoryDecryptionOptions{}
+	}
+	builder := p.Decryption().DecryptionKeys(decryptionKeys)
+	if options.VerificationKeys != nil {
+		builder.VerificationKeys(options.VerificationKeys)
+	}
+	decHandle, err := builder.New()
+	if err != nil {
+		return nil, err
+	}
+	ptReader, err := decHandle.DecryptingReader(input, Auto)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dstDir, 0700); err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: unable to create directory")
+	}
+	extractor := &archiveExtractor{dstDir: dstDir, restoreMode: options.RestoreMode}
+	archive := tar.NewReader(ptReader)
+	for {
+		header, err := archive.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "gopenpgp: unable to extract archive")
+		}
+		if err := extractor.extract(header, archive); err != nil {
+			return nil, errors.Wrap(err, "gopenpgp: unable to extract archive")
+		}
+	}
+	// Read the remaining padding of the archive to verify the signature.
+	if _, err := internal.Copy(io.Discard, ptReader); err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: unable to extract archive")
+	}
+	if err := extractor.restoreDirectories(); err != nil {
+		return nil, err
+	}
+	return ptReader.VerifySignature()
+}
+
+// archiveExtractor writes the entries of a tar archive to a directory.
+type archiveExtractor struct {
+	dstDir      string
+	restoreMode bool
+	// directories contains the extracted directories, whose modification time
+	// and permission bits are restored after all files are extracted.
+	directories []*tar.Header
+}
+
+func (e *archiveExtractor) extract(header *tar.Header, content io.Reader) error {
+	if header.Typeflag == tar.TypeXGlobalHeader {
+		return nil
+	}
+	dstPath, err := e.destination(header.Name)
+	if err != nil {
+		return err
+	}
+	mode := os.FileMode(header.Mode).Perm()
+	switch header.Typeflag {
+	case tar.TypeDir:
+		if !e.restoreMode {
+			mode = 0700
+		}
+		// The owner must be able to extract the files of the directory.
+		if err := os.MkdirAll(dstPath, mode|0700); err != nil {
+			return err
+		}
+		e.directories = append(e.directories, header)
+		return nil
+	case tar.TypeReg:
+		if !e.restoreMode {
+			mode = 0600
+		}
+		if err := os.MkdirAll(filepath.Dir(dstPath), 0700); err != nil {
+			return err
+		}
+		file, err := os.OpenFile(dstPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode|0600)
+		if err != nil {
+			return err
+		}
+		if _, err := internal.Copy(file, content); err != nil {
+			_ = file.Close()
+			return err
+		}
+		if err := file.Close(); err != nil {
+			return err
+		}
+		if err := os.Chmod(dstPath, mode); err != nil {
+			return err
+		}
+		return os.Chtimes(dstPath, header.ModTime, header.ModTime)
+	default:
+		return errors.Errorf("unsupported entry type %q of %s", header.Typeflag, header.Name)
+	}
+}
+
+// destination returns the path of the archive entry in the destination directory,
+// and fails if the entry would be written outside of it.
+func (e *archiveExtractor) destination(name string) (string, error) {
+	cleaned := path.Clean(name)
+	if path.IsAbs(cleaned) || cleaned == "." || cleaned == ".." || strings.HasPrefix(cleaned, "../") ||
+		strings.Contains(name, "\\") || filepath.VolumeName(filepath.FromSlash(cleaned)) != "" {
+		return "", errors.Errorf("invalid entry name %s", name)
+	}
+	return filepath.Join(e.dstDir, filepath.FromSlash(cleaned)), nil
+}
+
+// restoreDirectories applies the modification times and, if requested, the permission bits
+// of the extracted directories, starting with the innermost directories.
+func (e *archiveExtractor) restoreDirectories() error {
+	for i := len(e.directories) - 1; i >= 0; i-- {
+		header := e.directories[i]
+		dstPath, err := e.destination(header.Name)
+		if err != nil {
+			return err
+		}
+		if err := os.Chtimes(dstPath, header.ModTime, header.ModTime); err != nil {
+			return errors.Wrap(err, "gopenpgp: unable to restore the modification time")
+		}
+		if e.restoreMode {
+			if err := os.Chmod(dstPath, os.FileMode(header.Mode).Perm()); err != nil {
+				return errors.Wrap(err, "gopenpgp: unable to restore the file mode")
+			}
+		}
+	}
+	return nil
+}
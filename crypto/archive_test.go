@@ -0,0 +1,124 @@
+package crypto
+
+import (
+	"archive/tar"
+	"bytes"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncryptDecryptDirectory(t *testing.T) {
+	srcDir := filepath.Join(t.TempDir(), "documents")
+	modTime := time.Unix(1600000000, 0)
+	files := map[string]string{
+		"a.txt":            "first file",
+		"nested/b.txt":     "second file",
+		"nested/deep/c.md": testMessage,
+		"empty.txt":        "",
+	}
+	for name, content := range files {
+		path := filepath.Join(srcDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0640); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var encrypted bytes.Buffer
+	err := testPGP.EncryptDirectory(srcDir, &encrypted, keyRingTestPublic, &DirectoryEncryptionOptions{
+		SigningKeys: keyRingTestPrivate,
+	})
+	if err != nil {
+		t.Fatal("Cannot encrypt directory:", err)
+	}
+
+	dstDir := filepath.Join(t.TempDir(), "restored")
+	verifyResult, err := testPGP.DecryptDirectory(bytes.NewReader(encrypted.Bytes()), dstDir, keyRingTestPrivate, &DirectoryDecryptionOptions{
+		VerificationKeys: keyRingTestPublic,
+		RestoreMode:      true,
+	})
+	if err != nil {
+		t.Fatal("Cannot decrypt directory:", err)
+	}
+	assert.NoError(t, verifyResult.SignatureError())
+	for name, content := range files {
+		path := filepath.Join(dstDir, filepath.FromSlash(name))
+		decrypted, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal("Cannot read extracted file:", err)
+		}
+		assert.Equal(t, content, string(decrypted))
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, modTime.Unix(), info.ModTime().Unix())
+		if runtime.GOOS != "windows" {
+			assert.Equal(t, os.FileMode(0640), info.Mode().Perm())
+		}
+	}
+
+	// The literal data packet carries the name of the archive.
+	decHandle, _ := testPGP.Decryption().DecryptionKeys(keyRingTestPrivate).New()
+	decrypted, err := decHandle.Decrypt(encrypted.Bytes(), Bytes)
+	if err != nil {
+		t.Fatal("Cannot decrypt archive:", err)
+	}
+	assert.Equal(t, "documents.tar", decrypted.Metadata().Filename())
+
+	// Extracting again does not overwrite the files.
+	_, err = testPGP.DecryptDirectory(bytes.NewReader(encrypted.Bytes()), dstDir, keyRingTestPrivate, nil)
+	assert.Error(t, err)
+}
+
+func TestEncryptDirectoryNotADirectory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file.txt")
+	if err := os.WriteFile(path, []byte(testMessage), 0600); err != nil {
+		t.Fatal(err)
+	}
+	var encrypted bytes.Buffer
+	assert.Error(t, testPGP.EncryptDirectory(path, &encrypted, keyRingTestPublic, nil))
+}
+
+func TestDecryptDirectoryRejectsUnsafeEntries(t *testing.T) {
+	for _, header := range []*tar.Header{
+		{Name: "../escape.txt", Typeflag: tar.TypeReg, Mode: 0600},
+		{Name: "/absolute.txt", Typeflag: tar.TypeReg, Mode: 0600},
+		{Name: "nested/../../escape.txt", Typeflag: tar.TypeReg, Mode: 0600},
+		{Name: "link", Typeflag: tar.TypeSymlink, Linkname: "/etc/passwd"},
+	} {
+		t.Run(header.Name, func(t *testing.T) {
+			var archive bytes.Buffer
+			tarWriter := tar.NewWriter(&archive)
+			if err := tarWriter.WriteHeader(header); err != nil {
+				t.Fatal(err)
+			}
+			if err := tarWriter.Close(); err != nil {
+				t.Fatal(err)
+			}
+			encHandle, _ := testPGP.Encryption().Recipients(keyRingTestPublic).New()
+			pgpMessage, err := encHandle.Encrypt(archive.Bytes())
+			if err != nil {
+				t.Fatal(err)
+			}
+			parent := t.TempDir()
+			dstDir := filepath.Join(parent, "dst")
+			_, err = testPGP.DecryptDirectory(bytes.NewReader(pgpMessage.Bytes()), dstDir, keyRingTestPrivate, nil)
+			assert.Error(t, err)
+			entries, _ := os.ReadDir(parent)
+			assert.Len(t, entries, 1)
+			entries, _ = os.ReadDir(dstDir)
+			assert.Empty(t, entries)
+		})
+	}
+}
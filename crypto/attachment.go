@@ -0,0 +1,127 @@
+package crypto
+
+import (
+	"bytes"
+
+	"github.com/pkg/errors"
+)
+
+// AttachmentProcessor encrypts an attachment that is passed in chunks, e.g., by go-mobile clients,
+// and separates the key packets from the data packets of the resulting pgp message.
+// The data packets are either written to a data writer, which throttles the processing
+// to the speed of the writer, or kept in a buffer of fixed capacity, which bounds the memory use.
+type AttachmentProcessor struct {
+	keyPackets      *bytes.Buffer
+	signature       *bytes.Buffer
+	dataPackets     *boundedBuffer
+	plaintextWriter WriteCloser
+	err             error
+	// The options of the returned message, see PGPMessageBuffer.PGPMessageWithOptions.
+	isPlainSignature  bool
+	omitArmorChecksum bool
+}
+
+// NewAttachmentProcessor returns a processor that encrypts an attachment with the encryption handle,
+// and keeps the data packets in a buffer of dataBufferSize bytes.
+// The size needed can be obtained with EstimateEncryptedSize of the handle.
+// Processing fails if the data packets exceed the buffer, such that no more memory is allocated.
+// To set the filename of the attachment, use PlainMetadata of the encryption handle builder.
+func NewAttachmentProcessor(handle PGPEncryption, dataBufferSize int) (*AttachmentProcessor, error) {
+	if dataBufferSize < 0 {
+		return nil, errors.New("gopenpgp: invalid data buffer size")
+	}
+	dataPackets := &boundedBuffer{data: make([]byte, 0, dataBufferSize)}
+	processor, err := newAttachmentProcessor(handle, dataPackets)
+	if err != nil {
+		return nil, err
+	}
+	processor.dataPackets = dataPackets
+	return processor, nil
+}
+
+// NewAttachmentProcessorWithWriter returns a processor that encrypts an attachment with the encryption handle,
+// and writes the data packets to dataWriter, e.g., a connection of an upload.
+// Process only returns once the data packets of the chunk are written,
+// thus, a slow data writer slows down the processing instead of buffering the data packets.
+func NewAttachmentProcessorWithWriter(handle PGPEncryption, dataWriter Writer) (*AttachmentProcessor, error) {
+	return newAttachmentProcessor(handle, dataWriter)
+}
+
+func newAttachmentProcessor(handle PGPEncryption, dataWriter Writer) (*AttachmentProcessor, error) {
+	processor := &AttachmentProcessor{
+		keyPackets: &bytes.Buffer{},
+		signature:  &bytes.Buffer{},
+	}
+	plaintextWriter, err := handle.EncryptingWriter(
+		NewPGPSplitWriter(processor.keyPackets, dataWriter, processor.signature),
+		Bytes,
+	)
+	if err != nil {
+		return nil, err
+	}
+	processor.plaintextWriter = plaintextWriter
+	if eh, ok := handle.(*encryptionHandle); ok {
+		processor.isPlainSignature = eh.PlainDetachedSignature
+		processor.omitArmorChecksum = !eh.armorChecksumRequired()
+	}
+	return processor, nil
+}
+
+// Process encrypts the next chunk of the attachment.
+// The chunk is not retained and can be reused by the caller.
+// Once processing failed, further chunks are ignored and Finish returns the error as well.
+func (ap *AttachmentProcessor) Process(plainData []byte) error {
+	if ap.err != nil {
+		return ap.err
+	}
+	if ap.plaintextWriter == nil {
+		return errors.New("gopenpgp: attachment processor is finished")
+	}
+	if _, err := ap.plaintextWriter.Write(plainData); err != nil {
+		ap.err = errors.Wrap(err, "gopenpgp: unable to process attachment")
+	}
+	return ap.err
+}
+
+// Finish ends the encryption of the attachment and returns the encrypted message.
+// The message contains the key packets, the encrypted detached signature if configured,
+// and the data packets if they are kept in the buffer of the processor.
+func (ap *AttachmentProcessor) Finish() (*PGPMessage, error) {
+	if ap.err != nil {
+		return nil, ap.err
+	}
+	if ap.plaintextWriter == nil {
+		return nil, errors.New("gopenpgp: attachment processor is finished")
+	}
+	err := ap.plaintextWriter.Close()
+	ap.plaintextWriter = nil
+	if err != nil {
+		ap.err = errors.Wrap(err, "gopenpgp: unable to finish attachment")
+		return nil, ap.err
+	}
+	message := &PGPMessage{
+		KeyPacket:                ap.keyPackets.Bytes(),
+		detachedSignatureIsPlain: ap.isPlainSignature,
+		omitArmorChecksum:        ap.omitArmorChecksum,
+	}
+	if ap.dataPackets != nil {
+		message.DataPacket = ap.dataPackets.data
+	}
+	if ap.signature.Len() > 0 {
+		message.DetachedSignature = ap.signature.Bytes()
+	}
+	return message, nil
+}
+
+// boundedBuffer is a buffer that fails writes that exceed its capacity.
+type boundedBuffer struct {
+	data []byte
+}
+
+func (b *boundedBuffer) Write(p []byte) (int, error) {
+	if len(b.data)+len(p) > cap(b.data) {
+		return 0, errors.New("gopenpgp: encrypted attachment exceeds the data buffer size")
+	}
+	b.data = append(b.data, p...)
+	return len(p), nil
+}
@@ -0,0 +1,120 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func processAttachment(t *testing.T, processor *AttachmentProcessor, attachment []byte, chunkSize int) {
+	for offset := 0; offset < len(attachment); offset += chunkSize {
+		end := offset + chunkSize
+		if end > len(attachment) {
+			end = len(attachment)
+		}
+		if err := processor.Process(attachment[offset:end]); err != nil {
+			t.Fatal("Expected no error while processing the attachment, got:", err)
+		}
+	}
+}
+
+func TestAttachmentProcessor(t *testing.T) {
+	attachment := make([]byte, 100000)
+	if _, err := rand.Read(attachment); err != nil {
+		t.Fatal(err)
+	}
+	for _, material := range testMaterialForProfiles {
+		t.Run(material.profileName, func(t *testing.T) {
+			encHandle, _ := material.pgp.Encryption().
+				Recipients(material.keyRingTestPublic).
+				SigningKeys(material.keyRingTestPrivate).
+				PlainMetadata(NewFileMetadata(false, "attachment.bin", 1600000000)).
+				New()
+			decHandle, _ := material.pgp.Decryption().
+				DecryptionKeys(material.keyRingTestPrivate).
+				VerificationKeys(material.keyRingTestPublic).
+				New()
+			estimate, err := encHandle.EstimateEncryptedSize(int64(len(attachment)), Bytes)
+			if err != nil {
+				t.Fatal("Cannot estimate the encrypted size:", err)
+			}
+			processor, err := NewAttachmentProcessor(encHandle, int(estimate))
+			if err != nil {
+				t.Fatal("Cannot create attachment processor:", err)
+			}
+			processAttachment(t, processor, attachment, 7000)
+			message, err := processor.Finish()
+			if err != nil {
+				t.Fatal("Cannot finish attachment:", err)
+			}
+			assert.NotEmpty(t, message.KeyPacket)
+			assert.NotEmpty(t, message.DataPacket)
+			decrypted, err := decHandle.Decrypt(message.Bytes(), Bytes)
+			if err != nil {
+				t.Fatal("Cannot decrypt attachment:", err)
+			}
+			assert.Exactly(t, attachment, decrypted.Bytes())
+			assert.NoError(t, decrypted.SignatureError())
+			assert.Exactly(t, "attachment.bin", decrypted.Metadata().Filename())
+			assert.Exactly(t, int64(1600000000), decrypted.Metadata().Time())
+
+			_, err = processor.Finish()
+			assert.Error(t, err)
+			assert.Error(t, processor.Process(attachment))
+		})
+	}
+}
+
+func TestAttachmentProcessorWithWriter(t *testing.T) {
+	attachment := bytes.Repeat([]byte(testMessage), 1000)
+	encHandle, _ := testPGP.Encryption().
+		Recipients(keyRingTestPublic).
+		SigningKeys(keyRingTestPrivate).
+		DetachedSignature().
+		New()
+	var dataPackets bytes.Buffer
+	processor, err := NewAttachmentProcessorWithWriter(encHandle, &dataPackets)
+	if err != nil {
+		t.Fatal("Cannot create attachment processor:", err)
+	}
+	processAttachment(t, processor, attachment, 1000)
+	message, err := processor.Finish()
+	if err != nil {
+		t.Fatal("Cannot finish attachment:", err)
+	}
+	assert.Nil(t, message.DataPacket)
+	assert.NotEmpty(t, message.EncryptedDetachedSignature())
+
+	decHandle, _ := testPGP.Decryption().
+		DecryptionKeys(keyRingTestPrivate).
+		VerificationKeys(keyRingTestPublic).
+		New()
+	decrypted, err := decHandle.Decrypt(NewPGPSplitMessage(message.KeyPacket, dataPackets.Bytes()).Bytes(), Bytes)
+	if err != nil {
+		t.Fatal("Cannot decrypt attachment:", err)
+	}
+	assert.Exactly(t, attachment, decrypted.Bytes())
+}
+
+func TestAttachmentProcessorBufferSize(t *testing.T) {
+	encHandle, _ := testPGP.Encryption().Recipients(keyRingTestPublic).New()
+	processor, err := NewAttachmentProcessor(encHandle, 1000)
+	if err != nil {
+		t.Fatal("Cannot create attachment processor:", err)
+	}
+	attachment := make([]byte, 10000)
+	for offset := 0; offset < len(attachment) && err == nil; offset += 500 {
+		err = processor.Process(attachment[offset : offset+500])
+	}
+	if err == nil {
+		_, err = processor.Finish()
+	}
+	assert.Error(t, err)
+	_, err = processor.Finish()
+	assert.Error(t, err)
+
+	_, err = NewAttachmentProcessor(encHandle, -1)
+	assert.Error(t, err)
+}
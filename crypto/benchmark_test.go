@@ -0,0 +1,56 @@
+package crypto
+
+import (
+	"testing"
+)
+
+func BenchmarkEncryptDecryptSmallMessage(b *testing.B) {
+	message := []byte(testMessage)
+	encHandle, _ := testPGP.Encryption().Password(password).S2K(NewIteratedSaltedS2KParams(1024)).New()
+	decHandle, _ := testPGP.Decryption().Password(password).New()
+	for _, encoding := range []int8{Bytes, Armor, Auto} {
+		b.Run(encodingName(encoding), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				pgpMessage, err := encHandle.Encrypt(message)
+				if err != nil {
+					b.Fatal(err)
+				}
+				ciphertext := pgpMessage.Bytes()
+				if encoding == Armor {
+					ciphertext, _ = pgpMessage.ArmorBytes()
+				}
+				if _, err := decHandle.Decrypt(ciphertext, encoding); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func encodingName(encoding int8) string {
+	switch encoding {
+	case Armor:
+		return "armor"
+	case Auto:
+		return "auto"
+	default:
+		return "bytes"
+	}
+}
+
+func BenchmarkSignVerifySmallMessage(b *testing.B) {
+	message := []byte(testMessage)
+	signHandle, _ := testPGP.Sign().SigningKeys(keyRingTestPrivate).New()
+	verifyHandle, _ := testPGP.Verify().VerificationKeys(keyRingTestPublic).New()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		signature, err := signHandle.Sign(message, Bytes)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := verifyHandle.VerifyInline(signature, Bytes); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,280 @@
+package crypto
+
+import (
+	"bufio"
+	"bytes"
+	"crypto"
+	"encoding"
+	"hash"
+	"io"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	openpgp "github.com/ProtonMail/go-crypto/openpgp/v2"
+	"github.com/ProtonMail/gopenpgp/v3/constants"
+	"github.com/ProtonMail/gopenpgp/v3/internal"
+	"github.com/pkg/errors"
+)
+
+const (
+	cleartextBegin          = "-----BEGIN PGP SIGNED MESSAGE-----"
+	cleartextSignatureBegin = "-----BEGIN PGP SIGNATURE-----"
+	cleartextSignatureEnd   = "-----END PGP SIGNATURE-----"
+	cleartextHashHeader     = "Hash"
+	// maxCleartextSignatureSize limits the size of the armored signature block.
+	maxCleartextSignatureSize = 1 << 20
+)
+
+// cleartextHashNames maps the names in the Hash armor header to hash algorithms.
+var cleartextHashNames = map[string]crypto.Hash{
+	"SHA1":     crypto.SHA1,
+	"SHA224":   crypto.SHA224,
+	"SHA256":   crypto.SHA256,
+	"SHA384":   crypto.SHA384,
+	"SHA512":   crypto.SHA512,
+	"SHA3-256": crypto.SHA3_256,
+	"SHA3-512": crypto.SHA3_512,
+}
+
+// defaultCleartextHashes are computed if the cleartext message does not declare its hashes.
+var defaultCleartextHashes = []crypto.Hash{crypto.SHA256, crypto.SHA384, crypto.SHA512}
+
+// cleartextReader decodes a cleartext signed message incrementally.
+// It outputs the text, hashes it for each hash algorithm declared in the header,
+// and verifies the armored signature once the text is read entirely.
+// Since the salt of v6 signatures is only known after the text,
+// salted signatures cannot be verified.
+type cleartextReader struct {
+	source    *bufio.Reader
+	keyRing   *KeyRing
+	config    *packet.Config
+	details   *openpgp.MessageDetails
+	hashes    map[crypto.Hash]hash.Hash
+	hashInput io.Writer
+	// pending contains decoded text that is not yet returned.
+	pending []byte
+	// whitespace contains the trailing whitespace of the current line read so far,
+	// which is dropped if the line ends.
+	whitespace  []byte
+	atLineStart bool
+	isFirstLine bool
+	err         error
+}
+
+// newCleartextReader reads the header of the cleartext message from source.
+// Once the returned reader reaches EOF, details contains the verification details.
+func newCleartextReader(
+	source io.Reader,
+	keyRing *KeyRing,
+	config *packet.Config,
+	details *openpgp.MessageDetails,
+) (*cleartextReader, error) {
+	reader := &cleartextReader{
+		source:      bufio.NewReader(source),
+		keyRing:     keyRing,
+		config:      config,
+		details:     details,
+		hashes:      make(map[crypto.Hash]hash.Hash),
+		atLineStart: true,
+		isFirstLine: true,
+	}
+	if err := reader.readHeader(); err != nil {
+		return nil, err
+	}
+	return reader, nil
+}
+
+func (cr *cleartextReader) readHeader() error {
+	for {
+		line, isComplete, err := cr.readLine()
+		if err != nil {
+			return errors.New("gopenpgp: not able to parse cleartext message")
+		}
+		if isComplete && string(line) == cleartextBegin {
+			break
+		}
+	}
+	var declared []crypto.Hash
+	for {
+		line, isComplete, err := cr.readLine()
+		if err != nil || !isComplete {
+			return errors.New("gopenpgp: not able to parse cleartext message header")
+		}
+		if len(bytes.TrimSpace(line)) == 0 {
+			break
+		}
+		if bytes.IndexFunc(line, func(r rune) bool { return r < 0x20 || r > 0x7e }) != -1 {
+			return errors.New("gopenpgp: invalid character in cleartext message header")
+		}
+		header := strings.SplitN(string(line), ":", 2)
+		if len(header) != 2 || strings.TrimSpace(header[0]) != cleartextHashHeader {
+			return errors.New("gopenpgp: only hash headers are allowed in cleartext messages")
+		}
+		for _, name := range strings.Split(header[1], ",") {
+			if hashFunc, ok := cleartextHashNames[strings.TrimSpace(name)]; ok {
+				declared = append(declared, hashFunc)
+			}
+		}
+	}
+	if len(declared) == 0 {
+		declared = defaultCleartextHashes
+	}
+	hashInputs := make([]io.Writer, 0, len(declared))
+	for _, hashFunc := range declared {
+		if _, ok := cr.hashes[hashFunc]; ok || !hashFunc.Available() {
+			continue
+		}
+		cr.hashes[hashFunc] = hashFunc.New()
+		hashInputs = append(hashInputs, cr.hashes[hashFunc])
+	}
+	cr.hashInput = io.MultiWriter(hashInputs...)
+	return nil
+}
+
+// readLine reads the next line without line ending from the source.
+// If the line does not fit into the buffer, only a part of the line is returned
+// and isComplete is false.
+func (cr *cleartextReader) readLine() (line []byte, isComplete bool, err error) {
+	line, err = cr.source.ReadSlice('\n')
+	switch {
+	case errors.Is(err, bufio.ErrBufferFull):
+		return line, false, nil
+	case errors.Is(err, io.EOF) && len(line) > 0:
+		return line, true, nil
+	case err != nil:
+		return nil, false, err
+	}
+	line = bytes.TrimSuffix(line[:len(line)-1], []byte{'\r'})
+	return line, true, nil
+}
+
+// Read implements the io.Reader interface.
+func (cr *cleartextReader) Read(b []byte) (n int, err error) {
+	for len(cr.pending) == 0 && cr.err == nil {
+		cr.err = cr.readText()
+	}
+	n = copy(b, cr.pending)
+	cr.pending = cr.pending[n:]
+	if len(cr.pending) == 0 && cr.err != nil {
+		return n, cr.err
+	}
+	return n, nil
+}
+
+// readText decodes the next part of the text.
+// It returns io.EOF once the signature is verified.
+func (cr *cleartextReader) readText() error {
+	line, isComplete, err := cr.readLine()
+	if errors.Is(err, io.EOF) {
+		return errors.New("gopenpgp: cleartext message has no signature")
+	}
+	if err != nil {
+		return errors.Wrap(err, "gopenpgp: reading cleartext message failed")
+	}
+	if cr.atLineStart {
+		if isComplete && string(line) == cleartextSignatureBegin {
+			return cr.verify()
+		}
+		if !cr.isFirstLine {
+			if _, err := cr.hashInput.Write([]byte("\r\n")); err != nil {
+				return err
+			}
+			cr.pending = append(cr.pending, '\n')
+		}
+		cr.isFirstLine = false
+		line = bytes.TrimPrefix(line, []byte("- "))
+	}
+	cr.atLineStart = isComplete
+	text := append(cr.whitespace, line...)
+	trimmed := bytes.TrimRight(text, " \t")
+	if isComplete {
+		cr.whitespace = cr.whitespace[:0]
+	} else {
+		cr.whitespace = append([]byte(nil), text[len(trimmed):]...)
+	}
+	if _, err := cr.hashInput.Write(trimmed); err != nil {
+		return err
+	}
+	cr.pending = append(cr.pending, trimmed...)
+	return nil
+}
+
+// verify reads the armored signature and verifies it against the hashed text.
+func (cr *cleartextReader) verify() error {
+	armored := bytes.NewBufferString(cleartextSignatureBegin + "\n")
+	for {
+		line, isComplete, err := cr.readLine()
+		if err != nil {
+			return errors.New("gopenpgp: cleartext message has an incomplete signature")
+		}
+		armored.Write(line)
+		if isComplete {
+			armored.WriteByte('\n')
+			if string(line) == cleartextSignatureEnd {
+				break
+			}
+		}
+		if armored.Len() > maxCleartextSignatureSize {
+			return errors.New("gopenpgp: cleartext message signature is too large")
+		}
+	}
+	trailing, err := io.ReadAll(io.LimitReader(cr.source, maxCleartextSignatureSize))
+	if err != nil {
+		return errors.Wrap(err, "gopenpgp: reading cleartext message failed")
+	}
+	if len(bytes.TrimSpace(trailing)) > 0 {
+		return errors.New("gopenpgp: cleartext message has trailing text")
+	}
+	block, err := armor.Decode(armored)
+	if err != nil || block.Type != constants.PGPSignatureHeader {
+		return errors.New("gopenpgp: signature not parsable in cleartext")
+	}
+	md, err := openpgp.VerifyDetachedSignatureReader(
+		keyRings{cr.keyRing},
+		bytes.NewReader(nil),
+		block.Body,
+		cr.config,
+	)
+	if err != nil {
+		return errors.Wrap(err, "gopenpgp: verify signature reader failed")
+	}
+	handedOut := make(map[crypto.Hash]bool)
+	for _, candidate := range md.SignatureCandidates {
+		if candidate.SignedByEntity == nil {
+			continue
+		}
+		signatureHash, err := cr.signatureHash(candidate, handedOut)
+		if err != nil {
+			candidate.SignatureError = err
+			continue
+		}
+		candidate.Hash = signatureHash
+		candidate.WrappedHash = signatureHash
+	}
+	if _, err := internal.Copy(io.Discard, md.UnverifiedBody); err != nil {
+		return errors.Wrap(err, "gopenpgp: verifying cleartext signature failed")
+	}
+	*cr.details = *md
+	return io.EOF
+}
+
+// signatureHash returns the hash of the text for the signature candidate.
+// Since verifying a signature modifies the hash, each candidate receives a copy if possible.
+func (cr *cleartextReader) signatureHash(candidate *openpgp.SignatureCandidate, handedOut map[crypto.Hash]bool) (hash.Hash, error) {
+	if len(candidate.Salt) > 0 {
+		return nil, errors.New("gopenpgp: salted signatures cannot be verified in a streamed cleartext message")
+	}
+	textHash, ok := cr.hashes[candidate.HashAlgorithm]
+	if !ok {
+		return nil, errors.Errorf("gopenpgp: signature hash %s is not declared in the cleartext message", candidate.HashAlgorithm)
+	}
+	if _, ok := textHash.(encoding.BinaryMarshaler); !ok {
+		if handedOut[candidate.HashAlgorithm] {
+			return nil, errors.Errorf("gopenpgp: signature hash %s cannot be reused", candidate.HashAlgorithm)
+		}
+		handedOut[candidate.HashAlgorithm] = true
+		return textHash, nil
+	}
+	return copyHash(textHash, candidate.HashAlgorithm)
+}
@@ -0,0 +1,118 @@
+package crypto
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/ProtonMail/gopenpgp/v3/internal"
+	"github.com/pkg/errors"
+)
+
+// Compressor compresses the plaintext of encrypted messages with its own implementation,
+// e.g., a faster zlib, or zstd once it is an OpenPGP compression algorithm.
+// Its algorithm id is written in the compressed data packet, thus only the algorithms
+// that go-crypto decompresses are accepted, i.e., ZIP, ZLIB, and BZip2,
+// such that the messages can be decrypted.
+// Not supported on go-mobile clients.
+type Compressor interface {
+	// Algorithm returns the OpenPGP id of the compression algorithm.
+	Algorithm() packet.CompressionAlgo
+	// NewWriter returns a writer that writes the compressed data to w.
+	// Closing the writer must flush the compressed data, but not close w.
+	NewWriter(w io.Writer) (io.WriteCloser, error)
+}
+
+const (
+	compressedDataTag = 8
+	// compressionBZIP2 is decompressed by go-crypto, which does not define a constant for it.
+	compressionBZIP2 packet.CompressionAlgo = 3
+)
+
+// compressedChunkSize is the size of the partial bodies of compressed data packets,
+// which is a power of two as required for partial lengths.
+const compressedChunkSize = 1 << 12
+
+// checkCompressor returns an error if messages compressed by the compressor
+// cannot be decrypted.
+func checkCompressor(compressor Compressor) error {
+	if compressor == nil {
+		return errors.New("gopenpgp: no compressor provided")
+	}
+	switch algorithm := compressor.Algorithm(); algorithm {
+	case packet.CompressionZIP, packet.CompressionZLIB, compressionBZIP2:
+		return nil
+	default:
+		return errors.Errorf("gopenpgp: compression algorithm %d cannot be decompressed", algorithm)
+	}
+}
+
+// serializeCompressed writes the header of a compressed data packet to w and returns
+// a writer that compresses the packet contents with the compressor.
+// Closing the returned writer closes w.
+func serializeCompressed(w io.WriteCloser, compressor Compressor) (io.WriteCloser, error) {
+	if _, err := w.Write([]byte{0xc0 | compressedDataTag}); err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: error in compression")
+	}
+	body := &partialLengthWriter{w: w, buffer: internal.GetBuffer()}
+	body.buffer.WriteByte(byte(compressor.Algorithm()))
+	compressed, err := compressor.NewWriter(body)
+	if err != nil {
+		internal.PutSensitiveBuffer(body.buffer)
+		return nil, errors.Wrap(err, "gopenpgp: error in compression")
+	}
+	return &compressedWriter{compressed: compressed, body: body}, nil
+}
+
+// compressedWriter compresses the contents of a compressed data packet.
+type compressedWriter struct {
+	compressed io.WriteCloser
+	body       *partialLengthWriter
+}
+
+func (w *compressedWriter) Write(b []byte) (int, error) {
+	return w.compressed.Write(b)
+}
+
+func (w *compressedWriter) Close() error {
+	if err := w.compressed.Close(); err != nil {
+		return errors.Wrap(err, "gopenpgp: error in compression")
+	}
+	return w.body.Close()
+}
+
+// partialLengthWriter writes a packet body in partial bodies of compressedChunkSize bytes,
+// such that the length of the body does not need to be known in advance.
+// The last part of the body is written with a definite length on Close.
+type partialLengthWriter struct {
+	w      io.WriteCloser
+	buffer *bytes.Buffer
+}
+
+func (w *partialLengthWriter) Write(b []byte) (int, error) {
+	n, _ := w.buffer.Write(b)
+	for w.buffer.Len() > compressedChunkSize {
+		// A partial length of 2^n bytes is encoded as 224 + n.
+		if _, err := w.w.Write([]byte{224 + 12}); err != nil {
+			return 0, err
+		}
+		if _, err := w.w.Write(w.buffer.Next(compressedChunkSize)); err != nil {
+			return 0, err
+		}
+	}
+	return n, nil
+}
+
+func (w *partialLengthWriter) Close() error {
+	buffer := w.buffer
+	defer internal.PutSensitiveBuffer(buffer)
+	var header bytes.Buffer
+	writeSubpacketLength(&header, buffer.Len())
+	if _, err := w.w.Write(header.Bytes()); err != nil {
+		return err
+	}
+	if _, err := w.w.Write(buffer.Bytes()); err != nil {
+		return err
+	}
+	return w.w.Close()
+}
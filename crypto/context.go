@@ -0,0 +1,128 @@
+package crypto
+
+import "context"
+
+// contextIOResult is the result of a read or write performed in the background.
+type contextIOResult struct {
+	n   int
+	err error
+}
+
+// contextReader aborts reads with ctx.Err() once the context is done,
+// even if a read on the underlying reader blocks.
+type contextReader struct {
+	ctx    context.Context
+	reader Reader
+	buffer []byte
+}
+
+func newContextReader(ctx context.Context, reader Reader) Reader {
+	if reader == nil {
+		return nil
+	}
+	return &contextReader{ctx: ctx, reader: reader}
+}
+
+func (r *contextReader) Read(b []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	if r.ctx.Done() == nil {
+		// The context can never be cancelled.
+		return r.reader.Read(b)
+	}
+	// Read into an internal buffer, since a blocked read might
+	// still write to it after returning on cancellation.
+	// Once cancelled, the reader is never used again, thus the buffer can be reused.
+	if cap(r.buffer) < len(b) {
+		r.buffer = make([]byte, len(b))
+	}
+	buffer := r.buffer[:len(b)]
+	done := make(chan contextIOResult, 1)
+	go func() {
+		n, err := r.reader.Read(buffer)
+		done <- contextIOResult{n, err}
+	}()
+	select {
+	case <-r.ctx.Done():
+		return 0, r.ctx.Err()
+	case result := <-done:
+		copy(b, buffer[:result.n])
+		return result.n, result.err
+	}
+}
+
+// contextWriter aborts writes with ctx.Err() once the context is done,
+// even if a write on the underlying writer blocks.
+type contextWriter struct {
+	ctx    context.Context
+	writer Writer
+	buffer []byte
+}
+
+func (w *contextWriter) Write(b []byte) (int, error) {
+	if err := w.ctx.Err(); err != nil {
+		return 0, err
+	}
+	if w.ctx.Done() == nil {
+		// The context can never be cancelled.
+		return w.writer.Write(b)
+	}
+	// The caller might reuse b as soon as Write returns,
+	// thus a blocked write must operate on a copy.
+	w.buffer = append(w.buffer[:0], b...)
+	buffer := w.buffer
+	done := make(chan contextIOResult, 1)
+	go func() {
+		n, err := w.writer.Write(buffer)
+		done <- contextIOResult{n, err}
+	}()
+	select {
+	case <-w.ctx.Done():
+		return 0, w.ctx.Err()
+	case result := <-done:
+		return result.n, result.err
+	}
+}
+
+// wrapOutputContext wraps the output writer such that writes are aborted once the context is done.
+// If the output is a PGPSplitWriter, the returned writer is a PGPSplitWriter as well.
+func wrapOutputContext(ctx context.Context, output Writer) Writer {
+	return wrapOutputWriter(output, func(writer Writer) Writer {
+		return &contextWriter{ctx: ctx, writer: writer}
+	})
+}
+
+// contextCheckReader returns ctx.Err() on reads once the context is done.
+// In contrast to contextReader, it does not interrupt blocked reads.
+type contextCheckReader struct {
+	ctx    context.Context
+	reader Reader
+}
+
+func (r *contextCheckReader) Read(b []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.reader.Read(b)
+}
+
+// contextCheckWriteCloser returns ctx.Err() on writes and close once the context is done.
+type contextCheckWriteCloser struct {
+	ctx    context.Context
+	writer WriteCloser
+}
+
+func (w *contextCheckWriteCloser) Write(b []byte) (int, error) {
+	if err := w.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return w.writer.Write(b)
+}
+
+func (w *contextCheckWriteCloser) Close() error {
+	if err := w.ctx.Err(); err != nil {
+		return err
+	}
+	return w.writer.Close()
+}
@@ -13,14 +13,22 @@
 package crypto
 
 import (
+	"io"
 	"time"
 
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/ProtonMail/gopenpgp/v3/constants"
 	"github.com/ProtonMail/gopenpgp/v3/profile"
+	"github.com/pkg/errors"
 )
 
 type PGPHandle struct {
 	profile     *profile.Custom
 	defaultTime Clock
+	// random overrides the random source of the profile configs if not nil.
+	random   io.Reader
+	observer OperationObserver
+	logger   Logger
 }
 
 // PGP creates a PGPHandle to interact with the API.
@@ -38,42 +46,161 @@ func PGPWithProfile(profile *profile.Custom) *PGPHandle {
 	}
 }
 
+// PGPWithProfileName creates a PGPHandle to interact with the API.
+// Uses the profile registered under the name for configuration,
+// i.e., one of the pre-defined profiles or a profile added with profile.Register.
+func PGPWithProfileName(name string) (*PGPHandle, error) {
+	namedProfile, err := profile.Named(name)
+	if err != nil {
+		return nil, err
+	}
+	return PGPWithProfile(namedProfile), nil
+}
+
+// WithClock returns a copy of the handle that uses the clock as the current time,
+// e.g., for the creation time of keys and signatures, and for the verification of signatures.
+// Times set on the builders, e.g., EncryptionHandleBuilder.SignTime, take precedence.
+// Not supported on go-mobile clients.
+func (p *PGPHandle) WithClock(clock Clock) *PGPHandle {
+	handle := *p
+	handle.defaultTime = clock
+	return &handle
+}
+
+// WithRandom returns a copy of the handle that reads all randomness from random, e.g., for
+// key generation, session keys, salts, and padding. Together with WithClock and a deterministic random,
+// the handle creates byte-identical keys, messages, and signatures, e.g., for tests or reproducible builds.
+// A predictable random breaks the security of all keys and messages created with the handle,
+// thus, it must never be used in production.
+// Not supported on go-mobile clients.
+func (p *PGPHandle) WithRandom(random io.Reader) *PGPHandle {
+	handle := *p
+	handle.random = random
+	return &handle
+}
+
+// WithObserver returns a copy of the handle, whose encryption, decryption, sign, and verify handles
+// notify the observer about completed operations, e.g., to export metrics of all operations.
+// Not supported on go-mobile clients.
+func (p *PGPHandle) WithObserver(observer OperationObserver) *PGPHandle {
+	handle := *p
+	handle.observer = observer
+	return &handle
+}
+
+// WithLogger returns a copy of the handle, whose encryption, decryption, sign, and verify handles
+// log their decisions to the logger, e.g., to diagnose interoperability failures in production.
+// Not supported on go-mobile clients.
+func (p *PGPHandle) WithLogger(logger Logger) *PGPHandle {
+	handle := *p
+	handle.logger = logger
+	return &handle
+}
+
 // Encryption returns a builder to create an EncryptionHandle
 // for encrypting messages.
 func (p *PGPHandle) Encryption() *EncryptionHandleBuilder {
-	return newEncryptionHandleBuilder(p.profile, p.defaultTime)
+	return newEncryptionHandleBuilder(p.configProfile(), p.defaultTime).Observer(p.observer).Logger(p.logger)
 }
 
 // Decryption returns a builder to create a DecryptionHandle
 // for decrypting pgp messages.
 func (p *PGPHandle) Decryption() *DecryptionHandleBuilder {
-	return newDecryptionHandleBuilder(p.profile, p.defaultTime)
+	return newDecryptionHandleBuilder(p.configProfile(), p.defaultTime).Observer(p.observer).Logger(p.logger)
 }
 
 // Sign returns a builder to create a SignHandle
 // for signing messages.
 func (p *PGPHandle) Sign() *SignHandleBuilder {
-	return newSignHandleBuilder(p.profile, p.defaultTime)
+	return newSignHandleBuilder(p.configProfile(), p.defaultTime).Observer(p.observer).Logger(p.logger)
 }
 
 // Verify returns a builder to create an VerifyHandle
 // for verifying signatures.
 func (p *PGPHandle) Verify() *VerifyHandleBuilder {
-	return newVerifyHandleBuilder(p.profile, p.defaultTime)
+	return newVerifyHandleBuilder(p.configProfile(), p.defaultTime).Observer(p.observer).Logger(p.logger)
 }
 
 // KeyGeneration returns a builder to create a KeyGeneration handle.
 func (p *PGPHandle) KeyGeneration() *KeyGenerationBuilder {
-	return newKeyGenerationBuilder(p.profile, p.defaultTime)
+	return newKeyGenerationBuilder(p.configProfile(), p.defaultTime)
 }
 
 // LockKey encrypts the private parts of a copy of the input key with the given passphrase.
 func (p *PGPHandle) LockKey(key *Key, passphrase []byte) (*Key, error) {
-	return key.lock(passphrase, p.profile)
+	return key.lock(passphrase, p.configProfile().KeyEncryptionConfig())
+}
+
+// LockKeyWithS2K encrypts the private parts of a copy of the input key with the given passphrase,
+// where the key encryption key is derived from the passphrase with the given S2K parameters
+// instead of the S2K settings of the profile.
+// Since RFC9580 requires AEAD protection of keys locked with Argon2,
+// such keys are protected with AEAD even if the profile does not configure it.
+func (p *PGPHandle) LockKeyWithS2K(key *Key, passphrase []byte, params *S2KParams) (*Key, error) {
+	if params == nil {
+		return nil, errors.New("gopenpgp: no s2k parameters provided")
+	}
+	if err := params.validate(); err != nil {
+		return nil, err
+	}
+	config := p.configProfile().KeyEncryptionConfig()
+	params.applyTo(config)
+	if params.Mode == constants.S2KArgon2 && config.AEADConfig == nil {
+		config.AEADConfig = &packet.AEADConfig{}
+	}
+	return key.lock(passphrase, config)
 }
 
 // GenerateSessionKey generates a random session key for the profile.
 func (p *PGPHandle) GenerateSessionKey() (*SessionKey, error) {
-	config := p.profile.EncryptionConfig()
+	config := p.configProfile().EncryptionConfig()
 	return generateSessionKey(config)
 }
+
+// handleProfile provides the configs for all operations of a PGPHandle.
+type handleProfile interface {
+	EncryptionProfile
+	KeyEncryptionProfile
+	KeyGenerationProfile
+	SignProfile
+}
+
+// configProfile returns the profile of the handle,
+// with the random source of the handle set in all configs.
+func (p *PGPHandle) configProfile() handleProfile {
+	if p.random == nil {
+		return p.profile
+	}
+	return &randomProfile{Custom: p.profile, random: p.random}
+}
+
+// randomProfile sets a custom random source in the configs of a profile.
+type randomProfile struct {
+	*profile.Custom
+	random io.Reader
+}
+
+func (p *randomProfile) KeyGenerationConfig(securityLevel int8) *packet.Config {
+	return p.withRandom(p.Custom.KeyGenerationConfig(securityLevel))
+}
+
+func (p *randomProfile) EncryptionConfig() *packet.Config {
+	return p.withRandom(p.Custom.EncryptionConfig())
+}
+
+func (p *randomProfile) KeyEncryptionConfig() *packet.Config {
+	return p.withRandom(p.Custom.KeyEncryptionConfig())
+}
+
+func (p *randomProfile) SignConfig() *packet.Config {
+	return p.withRandom(p.Custom.SignConfig())
+}
+
+func (p *randomProfile) CompressionConfig() *packet.Config {
+	return p.withRandom(p.Custom.CompressionConfig())
+}
+
+func (p *randomProfile) withRandom(config *packet.Config) *packet.Config {
+	config.Rand = p.random
+	return config
+}
@@ -0,0 +1,111 @@
+package crypto
+
+import (
+	mathrand "math/rand"
+	"strconv"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/ProtonMail/gopenpgp/v3/constants"
+	"github.com/ProtonMail/gopenpgp/v3/profile"
+	"github.com/stretchr/testify/assert"
+)
+
+func deterministicPGP(p *profile.Custom) *PGPHandle {
+	// A fixed seed makes the output reproducible, never do this outside of tests.
+	return PGPWithProfile(p).
+		WithRandom(mathrand.New(mathrand.NewSource(42))). //nolint:gosec // deterministic on purpose
+		WithClock(NewConstantClock(1600000000))
+}
+
+func TestDeterministicHandle(t *testing.T) {
+	profiles := map[string]*profile.Custom{
+		"default": profile.Default(),
+		"rfc9580": profile.RFC9580(),
+	}
+	for name, p := range profiles {
+		t.Run(name, func(t *testing.T) {
+			createArtifacts := func() [][]byte {
+				pgp := deterministicPGP(p)
+				key, err := pgp.KeyGeneration().AddUserId("test", "test@example.com").New().GenerateKey()
+				if err != nil {
+					t.Fatal("Cannot generate key:", err)
+				}
+				serializedKey, err := key.Serialize()
+				if err != nil {
+					t.Fatal("Cannot serialize key:", err)
+				}
+				sessionKey, err := pgp.GenerateSessionKey()
+				if err != nil {
+					t.Fatal("Cannot generate session key:", err)
+				}
+				encHandle, _ := pgp.Encryption().Recipient(key).SigningKey(key).New()
+				pgpMessage, err := encHandle.Encrypt([]byte(testMessage))
+				if err != nil {
+					t.Fatal("Cannot encrypt:", err)
+				}
+				signHandle, _ := pgp.Sign().SigningKey(key).Detached().New()
+				signature, err := signHandle.Sign([]byte(testMessage), Bytes)
+				if err != nil {
+					t.Fatal("Cannot sign:", err)
+				}
+				return [][]byte{serializedKey, sessionKey.Key, pgpMessage.Bytes(), signature}
+			}
+			first := createArtifacts()
+			second := createArtifacts()
+			assert.Equal(t, first, second)
+
+			key, err := NewKey(first[0])
+			if err != nil {
+				t.Fatal("Cannot read key:", err)
+			}
+			assert.Equal(t, int64(1600000000), key.GetEntity().PrimaryKey.CreationTime.Unix())
+			decHandle, _ := PGPWithProfile(p).Decryption().DecryptionKey(key).VerificationKey(key).New()
+			decrypted, err := decHandle.Decrypt(first[2], Bytes)
+			if err != nil {
+				t.Fatal("Cannot decrypt:", err)
+			}
+			assert.Equal(t, testMessage, decrypted.String())
+			assert.NoError(t, decrypted.SignatureError())
+		})
+	}
+}
+
+func TestPGPWithProfileName(t *testing.T) {
+	pgp, err := PGPWithProfileName(profile.NameRFC9580)
+	if err != nil {
+		t.Fatal("Cannot create handle:", err)
+	}
+	key, err := pgp.KeyGeneration().AddUserId("test", "test@example.com").New().GenerateKey()
+	if err != nil {
+		t.Fatal("Cannot generate key:", err)
+	}
+	assert.Equal(t, 6, key.GetVersion())
+
+	newProfile := func() *profile.Custom {
+		custom := profile.Default()
+		custom.CipherEncryption = packet.CipherAES128
+		return custom
+	}
+	// The registry is global, thus the name must be unique across test runs.
+	name := "test-aes128-" + strconv.FormatInt(mathrand.Int63(), 16) //nolint:gosec // not security relevant
+	if err := profile.Register(name, newProfile); err != nil {
+		t.Fatal("Cannot register profile:", err)
+	}
+	assert.Contains(t, profile.Names(), name)
+	assert.Error(t, profile.Register(name, newProfile), "names cannot be reused")
+	assert.Error(t, profile.Register(profile.NameDefault, newProfile), "pre-defined profiles cannot be replaced")
+	assert.Error(t, profile.Register("test-invalid", func() *profile.Custom { return &profile.Custom{} }))
+
+	pgp, err = PGPWithProfileName(name)
+	if err != nil {
+		t.Fatal("Cannot create handle:", err)
+	}
+	sessionKey, err := pgp.GenerateSessionKey()
+	if err != nil {
+		t.Fatal("Cannot generate session key:", err)
+	}
+	assert.Equal(t, constants.AES128, sessionKey.Algo)
+	_, err = PGPWithProfileName("unknown")
+	assert.Error(t, err)
+}
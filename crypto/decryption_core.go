@@ -6,7 +6,6 @@ import (
 
 	"github.com/ProtonMail/go-crypto/openpgp/packet"
 	openpgp "github.com/ProtonMail/go-crypto/openpgp/v2"
-	"github.com/ProtonMail/gopenpgp/v3/constants"
 	"github.com/ProtonMail/gopenpgp/v3/internal"
 	"github.com/pkg/errors"
 )
@@ -34,48 +33,26 @@ func NewPGPSplitReader(pgpMessage Reader, pgpEncryptedSignature Reader) *pgpSpli
 
 // decryptStream decrypts the stream either with the secret keys or a password.
 func (dh *decryptionHandle) decryptStream(encryptedMessage Reader) (plainMessage *VerifyDataReader, err error) {
-	var entries openpgp.EntityList
-
 	config := dh.decryptionConfig(dh.clock().Unix())
-	if dh.DecryptionKeyRing != nil {
-		entries = dh.DecryptionKeyRing.entities
-	}
-
-	if dh.VerifyKeyRing != nil {
-		entries = append(entries, dh.VerifyKeyRing.entities...)
-	}
-
-	if dh.VerificationContext != nil {
-		config.KnownNotations = map[string]bool{constants.SignatureContextName: true}
-	}
+	entries := keyRings{dh.decryptionKeyRing(), dh.VerifyKeyRing}
 
 	var messageDetails *openpgp.MessageDetails
+	passwordIndex := noPasswordIndex
 	if dh.DecryptionKeyRing != nil {
 		// Private key based decryption
 		messageDetails, err = openpgp.ReadMessage(encryptedMessage, entries, nil, config)
 		if err != nil {
-			return nil, errors.Wrap(err, "gopenpgp: decrypting message with private keys failed")
+			return nil, wrapKeyDecryptionError(err, "gopenpgp: decrypting message with private keys failed")
 		}
 	} else {
 		// Password based decryption
-		var foundPassword = false
-		resetReader := internal.NewResetReader(encryptedMessage)
-		for _, password := range dh.Passwords {
-			prompt := createPasswordPrompt(password)
-			messageDetails, err = openpgp.ReadMessage(resetReader, entries, prompt, config)
-			if err == nil {
-				foundPassword = true
-				resetReader.DisableBuffering()
-				break
-			}
-			if _, err := resetReader.Reset(); err != nil {
-				// Should not happen.
-				return nil, errors.Wrap(err, "gopenpgp: buffer reset failed")
-			}
-		}
-		if !foundPassword {
+		messageDetails, passwordIndex, err = readMessageWithPasswords(encryptedMessage, dh.Passwords, entries, config)
+		if err != nil {
 			// Parsing errors when reading the message are most likely caused by incorrect password, but we cannot know for sure
-			return nil, errors.New("gopenpgp: error in reading password protected message: wrong password or malformed message")
+			return nil, tagError(
+				errors.New("gopenpgp: error in reading password protected message: wrong password or malformed message"),
+				ErrWrongPassword,
+			)
 		}
 	}
 
@@ -90,13 +67,16 @@ func (dh *decryptionHandle) decryptStream(encryptedMessage Reader) (plainMessage
 		internalReader = internal.NewSanitizeReader(internalReader)
 	}
 	return &VerifyDataReader{
-		messageDetails,
-		internalReader,
-		dh.VerifyKeyRing,
-		config.Time().Unix(),
-		dh.DisableVerifyTimeCheck,
-		false,
-		dh.VerificationContext,
+		details:             messageDetails,
+		internalReader:      internalReader,
+		verifyKeyRing:       dh.VerifyKeyRing,
+		verifyTime:          config.Time().Unix(),
+		disableTimeCheck:    dh.DisableVerifyTimeCheck,
+		clockSkew:           dh.AllowedClockSkew,
+		verificationContext: dh.VerificationContext,
+		passwordIndex:       passwordIndex,
+		signaturePolicy:     dh.signaturePolicy(),
+		logger:              dh.Logger,
 	}, nil
 }
 
@@ -117,24 +97,33 @@ func (dh *decryptionHandle) decryptStreamWithSession(dataPacketReader Reader) (p
 		internalReader = internal.NewSanitizeReader(internalReader)
 	}
 	return &VerifyDataReader{
-		messageDetails,
-		internalReader,
-		dh.VerifyKeyRing,
-		verifyTime,
-		dh.DisableVerifyTimeCheck,
-		false,
-		dh.VerificationContext,
+		details:             messageDetails,
+		internalReader:      internalReader,
+		verifyKeyRing:       dh.VerifyKeyRing,
+		verifyTime:          verifyTime,
+		disableTimeCheck:    dh.DisableVerifyTimeCheck,
+		clockSkew:           dh.AllowedClockSkew,
+		verificationContext: dh.VerificationContext,
+		passwordIndex:       noPasswordIndex,
+		signaturePolicy:     dh.signaturePolicy(),
+		logger:              dh.Logger,
 	}, err
 }
 
 func (dh *decryptionHandle) decryptStreamWithSessionAndParse(messageReader io.Reader) (*openpgp.MessageDetails, int64, error) {
-	var keyring openpgp.EntityList
 	var decrypted io.ReadCloser
 	var selectedSessionKey *SessionKey
+	var cipherFunc packet.CipherFunction
 	var err error
 	// Read symmetrically encrypted data packet
 	for _, sessionKeyCandidate := range dh.SessionKeys {
-		decrypted, err = decryptStreamWithSessionKey(sessionKeyCandidate, messageReader)
+		decrypted, cipherFunc, err = decryptStreamWithSessionKey(
+			sessionKeyCandidate,
+			messageReader,
+			dh.InsecureDisableUnauthenticatedMessagesCheck,
+			dh.DecryptionParallelism,
+			dh.MaxBufferSize,
+		)
 		if err == nil { // No error occurred
 			selectedSessionKey = sessionKeyCandidate
 			break
@@ -148,35 +137,39 @@ func (dh *decryptionHandle) decryptStreamWithSessionAndParse(messageReader io.Re
 	checkPacketSequence := false
 	config.CheckPacketSequence = &checkPacketSequence
 
-	if dh.VerificationContext != nil {
-		config.KnownNotations = map[string]bool{constants.SignatureContextName: true}
-	}
-
 	// Push decrypted packet as literal packet and use openpgp's reader
-	if dh.VerifyKeyRing != nil {
-		keyring = append(keyring, dh.VerifyKeyRing.entities...)
-	}
-	if dh.DecryptionKeyRing != nil {
-		keyring = append(keyring, dh.DecryptionKeyRing.entities...)
-	}
+	keyring := keyRings{dh.VerifyKeyRing, dh.decryptionKeyRing()}
 	md, err := openpgp.ReadMessage(decrypted, keyring, nil, config)
 	if err != nil {
 		return nil, 0, errors.Wrap(err, "gopenpgp: unable to decode symmetric packet")
 	}
 	md.SessionKey = selectedSessionKey.Key
+	md.DecryptedWithAlgorithm = cipherFunc
 	md.UnverifiedBody = checkReader{decrypted, md.UnverifiedBody}
 	return md, config.Time().Unix(), nil
 }
 
-func decryptStreamWithSessionKey(sessionKey *SessionKey, messageReader io.Reader) (io.ReadCloser, error) {
+// decryptStreamWithSessionKey decrypts the data packet in messageReader with the session key
+// and returns the decrypting reader together with the cipher of the data packet.
+// Legacy data packets without integrity protection are only decrypted if allowUnauthenticated is set.
+// If parallelism is larger than one, the chunks of SEIPDv2 data packets are decrypted in parallel,
+// with at most as many goroutines as fit into maxBufferSize, if set.
+func decryptStreamWithSessionKey(
+	sessionKey *SessionKey,
+	messageReader io.Reader,
+	allowUnauthenticated bool,
+	parallelism int,
+	maxBufferSize int64,
+) (io.ReadCloser, packet.CipherFunction, error) {
 	var decrypted io.ReadCloser
+	var cipherFunc packet.CipherFunction
 	// Read symmetrically encrypted data packet
 Loop:
 	for {
 		packets := packet.NewReader(messageReader)
 		p, err := packets.Next()
 		if err != nil {
-			return nil, errors.Wrap(err, "gopenpgp: unable to read symmetric packet")
+			return nil, 0, errors.Wrap(err, "gopenpgp: unable to read symmetric packet")
 		}
 
 		// Decrypt data packet
@@ -186,36 +179,52 @@ Loop:
 			continue
 		case *packet.SymmetricallyEncrypted, *packet.AEADEncrypted:
 			if symPacket, ok := p.(*packet.SymmetricallyEncrypted); ok {
-				if !symPacket.IntegrityProtected {
-					return nil, errors.New("gopenpgp: message is not authenticated")
+				if !symPacket.IntegrityProtected && !allowUnauthenticated {
+					return nil, 0, tagError(errors.New("gopenpgp: message is not authenticated"), ErrMessageNotIntegrityProtected)
+				}
+				if symPacket.Version == 2 {
+					cipherFunc = symPacket.Cipher
 				}
 			}
 			var dc packet.CipherFunction
 			if !sessionKey.v6 {
 				dc, err = sessionKey.GetCipherFunc()
 				if err != nil {
-					return nil, errors.Wrap(err, "gopenpgp: unable to decrypt with session key")
+					return nil, 0, errors.Wrap(err, "gopenpgp: unable to decrypt with session key")
+				}
+			}
+			if cipherFunc == 0 {
+				cipherFunc = dc
+			}
+			if symPacket, ok := p.(*packet.SymmetricallyEncrypted); ok && symPacket.Version == 2 {
+				if workers := boundedParallelism(parallelism, symPacket, maxBufferSize); workers > 1 {
+					decrypted, err = newParallelAEADReader(symPacket, sessionKey.Key, workers)
+					if err != nil {
+						return nil, 0, errors.Wrap(err, "gopenpgp: unable to decrypt symmetric packet")
+					}
+					break Loop
 				}
 			}
 			encryptedDataPacket, isDataPacket := p.(packet.EncryptedDataPacket)
 			if !isDataPacket {
-				return nil, errors.Wrap(err, "gopenpgp: unknown data packet")
+				return nil, 0, errors.Wrap(err, "gopenpgp: unknown data packet")
 			}
 			decrypted, err = encryptedDataPacket.Decrypt(dc, sessionKey.Key)
 			if err != nil {
-				return nil, errors.Wrap(err, "gopenpgp: unable to decrypt symmetric packet")
+				return nil, 0, errors.Wrap(err, "gopenpgp: unable to decrypt symmetric packet")
 			}
 			break Loop
 		default:
-			return nil, errors.New("gopenpgp: invalid packet type")
+			return nil, 0, errors.New("gopenpgp: invalid packet type")
 		}
 	}
-	return decrypted, nil
+	return decrypted, cipherFunc, nil
 }
 
 func (dh *decryptionHandle) decryptStreamAndVerifyDetached(encryptedData, encryptedSignature Reader, isPlaintextSignature bool) (plainMessage *VerifyDataReader, err error) {
 	verifyTime := dh.clock().Unix()
 	var mdData *openpgp.MessageDetails
+	passwordIndex := noPasswordIndex
 	signature := encryptedSignature
 	// Decrypt both messages
 	if len(dh.SessionKeys) > 0 {
@@ -235,43 +244,35 @@ func (dh *decryptionHandle) decryptStreamAndVerifyDetached(encryptedData, encryp
 	} else {
 		// Password or private keys
 		config := dh.decryptionConfig(verifyTime)
-		var entries openpgp.EntityList
-		if dh.DecryptionKeyRing != nil {
-			entries = append(entries, dh.DecryptionKeyRing.entities...)
-		}
+		entries := keyRings{dh.decryptionKeyRing()}
 		// Decrypting reader for the encrypted data
 		var selectedPassword []byte
 		if len(dh.Passwords) > 0 {
-			resetReader := internal.NewResetReader(encryptedData)
-			for _, passwordCandidate := range dh.Passwords {
-				prompt := createPasswordPrompt(passwordCandidate)
-				mdData, err = openpgp.ReadMessage(resetReader, entries, prompt, config)
-				if err == nil { // No error occurred
-					selectedPassword = passwordCandidate
-					resetReader.DisableBuffering()
-					break
-				}
-				if _, err := resetReader.Reset(); err != nil {
-					// Should not happen
-					return nil, errors.Wrap(err, "gopenpgp: buffer reset failed")
-				}
-			}
-			if selectedPassword == nil {
-				return nil, errors.Wrap(err, "gopenpgp: error in reading data message: no password matched")
+			mdData, passwordIndex, err = readMessageWithPasswords(encryptedData, dh.Passwords, entries, config)
+			if err != nil {
+				return nil, tagError(
+					errors.Wrap(err, "gopenpgp: error in reading data message: no password matched"),
+					ErrWrongPassword,
+				)
 			}
+			selectedPassword = dh.Passwords[passwordIndex]
 		} else {
 			mdData, err = openpgp.ReadMessage(encryptedData, entries, nil, config)
 			if err != nil {
-				return nil, errors.Wrap(err, "gopenpgp: error in reading data message")
+				return nil, wrapKeyDecryptionError(err, "gopenpgp: error in reading data message")
 			}
 		}
 
 		if !isPlaintextSignature {
 			// Decrypting reader for the encrypted signature
-			prompt := createPasswordPrompt(selectedPassword)
 			noCheckPacketSequence := false
 			config.CheckPacketSequence = &noCheckPacketSequence
-			mdSig, err := openpgp.ReadMessage(encryptedSignature, entries, prompt, config)
+			var mdSig *openpgp.MessageDetails
+			if selectedPassword != nil {
+				mdSig, _, err = readMessageWithPasswords(encryptedSignature, [][]byte{selectedPassword}, entries, config)
+			} else {
+				mdSig, err = openpgp.ReadMessage(encryptedSignature, entries, nil, config)
+			}
 			if err != nil {
 				return nil, errors.Wrap(err, "gopenpgp: error in reading detached signature message")
 			}
@@ -288,9 +289,11 @@ func (dh *decryptionHandle) decryptStreamAndVerifyDetached(encryptedData, encryp
 		dh.VerifyKeyRing,
 		dh.VerificationContext,
 		dh.DisableVerifyTimeCheck,
+		dh.AllowedClockSkew,
 		dh.DisableAutomaticTextSanitize,
 		config,
 		NewConstantClock(verifyTime),
+		dh.signaturePolicy(),
 	)
 	if err != nil {
 		return nil, err
@@ -298,9 +301,131 @@ func (dh *decryptionHandle) decryptStreamAndVerifyDetached(encryptedData, encryp
 	// Update message details with information from the data of the pgp message
 	sigVerifyReader.details.LiteralData = mdData.LiteralData
 	sigVerifyReader.details.SessionKey = mdData.SessionKey
+	sigVerifyReader.details.DecryptedWithAlgorithm = mdData.DecryptedWithAlgorithm
+	sigVerifyReader.passwordIndex = passwordIndex
+	sigVerifyReader.logger = dh.Logger
 	return sigVerifyReader, nil
 }
 
+// readIntegrityProtection checks if the data packet of the message is integrity protected,
+// i.e., if it is not a legacy symmetrically encrypted data packet without modification detection code.
+// Returns a reader that replays the read message.
+func readIntegrityProtection(message Reader) (Reader, bool, error) {
+	resetReader := internal.NewResetReader(message)
+	packets := packet.NewReader(resetReader)
+	integrityProtected := true
+Loop:
+	for {
+		p, err := packets.Next()
+		if err != nil {
+			break
+		}
+		switch p := p.(type) {
+		case *packet.EncryptedKey, *packet.SymmetricKeyEncrypted:
+			continue
+		case *packet.SymmetricallyEncrypted:
+			integrityProtected = p.IntegrityProtected
+			break Loop
+		default:
+			break Loop
+		}
+	}
+	replay, err := resetReader.Reset()
+	if err != nil {
+		return nil, false, err
+	}
+	return replay, integrityProtected, nil
+}
+
+// checkBufferBounds checks that the key packets and the chunks of an AEAD data packet
+// of the message fit into maxBufferSize bytes, before they are buffered for decryption.
+// Returns a reader that replays the read message.
+func checkBufferBounds(message Reader, maxBufferSize int64) (Reader, error) {
+	resetReader := internal.NewResetReader(message)
+	var raw bytes.Buffer
+	packets := packet.NewReader(io.TeeReader(resetReader, &raw))
+	var boundsErr error
+Loop:
+	for {
+		start := raw.Len()
+		p, err := packets.Next()
+		if err != nil {
+			break
+		}
+		switch p := p.(type) {
+		case *packet.EncryptedKey, *packet.SymmetricKeyEncrypted:
+			if int64(raw.Len()) > maxBufferSize {
+				boundsErr = errors.Errorf("gopenpgp: key packets exceed the maximum buffer size of %d bytes", maxBufferSize)
+				break Loop
+			}
+		case *packet.SymmetricallyEncrypted:
+			if p.Version == 2 {
+				boundsErr = checkAEADChunkSize(p.ChunkSizeByte, p.Mode.TagLength(), maxBufferSize)
+			}
+			break Loop
+		case *packet.AEADEncrypted:
+			// go-crypto does not expose the chunk size of legacy AEAD packets,
+			// thus it is read from the packet body: version, cipher, mode, and chunk size.
+			body, ok := packetBody(raw.Bytes()[start:])
+			if !ok || len(body) < 4 {
+				boundsErr = errors.New("gopenpgp: malformed aead data packet")
+			} else {
+				boundsErr = checkAEADChunkSize(body[3], packet.AEADMode(body[2]).TagLength(), maxBufferSize)
+			}
+			break Loop
+		default:
+			break Loop
+		}
+	}
+	replay, err := resetReader.Reset()
+	if err != nil {
+		return nil, err
+	}
+	if boundsErr != nil {
+		return nil, boundsErr
+	}
+	return replay, nil
+}
+
+// checkAEADChunkSize returns an error if an AEAD chunk together with its tag exceeds maxBufferSize.
+func checkAEADChunkSize(chunkSizeByte byte, tagLength int, maxBufferSize int64) error {
+	if chunkSizeByte > 56 || int64(1)<<(chunkSizeByte+6)+int64(tagLength) > maxBufferSize {
+		return errors.Errorf("gopenpgp: aead chunks exceed the maximum buffer size of %d bytes", maxBufferSize)
+	}
+	return nil
+}
+
+// packetBody returns the body of the serialized packet with a new format header.
+func packetBody(serialized []byte) ([]byte, bool) {
+	if len(serialized) < 2 {
+		return nil, false
+	}
+	lengthOctets := 1
+	switch {
+	case serialized[1] >= 192 && serialized[1] < 224:
+		lengthOctets = 2
+	case serialized[1] == 255:
+		lengthOctets = 5
+	}
+	if len(serialized) < 1+lengthOctets {
+		return nil, false
+	}
+	return serialized[1+lengthOctets:], true
+}
+
+// boundedParallelism reduces parallelism such that the buffers of the chunks decrypted in parallel,
+// i.e., the ciphertext and plaintext of each chunk, fit into maxBufferSize, if set.
+func boundedParallelism(parallelism int, seipd *packet.SymmetricallyEncrypted, maxBufferSize int64) int {
+	if maxBufferSize <= 0 {
+		return parallelism
+	}
+	chunkBuffers := 2 * (int64(1)<<(seipd.ChunkSizeByte+6) + int64(seipd.Mode.TagLength()))
+	if bounded := maxBufferSize / chunkBuffers; bounded < int64(parallelism) {
+		return int(bounded)
+	}
+	return parallelism
+}
+
 func getSignaturePacket(sig []byte) (*packet.Signature, error) {
 	p, err := packet.Read(bytes.NewReader(sig))
 	if err != nil {
@@ -313,6 +438,76 @@ func getSignaturePacket(sig []byte) (*packet.Signature, error) {
 	return sigPacket, nil
 }
 
+// readMessageWithPasswords reads the password protected message with the first matching password
+// and returns the message details together with the index of the password.
+// openpgp.ReadMessage aborts if a wrong password seemingly decrypts a v4 key packet,
+// which happens for a small fraction of key packets encrypted with other passwords.
+// Thus, if no password matches, each password encrypted key packet is retried in isolation.
+func readMessageWithPasswords(
+	message io.Reader,
+	passwords [][]byte,
+	entries openpgp.KeyRing,
+	config *packet.Config,
+) (md *openpgp.MessageDetails, passwordIndex int, err error) {
+	resetReader := internal.NewResetReader(message)
+	for index, password := range passwords {
+		md, err = openpgp.ReadMessage(resetReader, entries, createPasswordPrompt(password), config)
+		if err == nil {
+			resetReader.DisableBuffering()
+			return md, index, nil
+		}
+		if _, resetErr := resetReader.Reset(); resetErr != nil {
+			// Should not happen.
+			return nil, 0, errors.Wrap(resetErr, "gopenpgp: buffer reset failed")
+		}
+	}
+	keyPackets, keyPacketsLength, splitErr := splitSymmetricKeyPackets(resetReader)
+	if splitErr != nil || len(keyPackets) < 2 {
+		return nil, 0, err
+	}
+	for index, password := range passwords {
+		for _, keyPacket := range keyPackets {
+			if _, resetErr := resetReader.Reset(); resetErr != nil {
+				// Should not happen.
+				return nil, 0, errors.Wrap(resetErr, "gopenpgp: buffer reset failed")
+			}
+			if _, err = io.CopyN(io.Discard, resetReader, keyPacketsLength); err != nil {
+				return nil, 0, errors.Wrap(err, "gopenpgp: unable to skip key packets")
+			}
+			messageReader := io.MultiReader(bytes.NewReader(keyPacket), resetReader)
+			md, err = openpgp.ReadMessage(messageReader, entries, createPasswordPrompt(password), config)
+			if err == nil {
+				resetReader.DisableBuffering()
+				return md, index, nil
+			}
+		}
+	}
+	return nil, 0, err
+}
+
+// splitSymmetricKeyPackets reads the key packets at the start of the message and returns
+// the serialized password encrypted key packets together with the length of all key packets.
+func splitSymmetricKeyPackets(message io.Reader) (keyPackets [][]byte, keyPacketsLength int64, err error) {
+	var buffer bytes.Buffer
+	packets := packet.NewReader(io.TeeReader(message, &buffer))
+	for {
+		p, err := packets.Next()
+		if err != nil {
+			return nil, 0, err
+		}
+		switch p.(type) {
+		case *packet.SymmetricKeyEncrypted:
+			keyPacket := buffer.Bytes()[keyPacketsLength:]
+			keyPackets = append(keyPackets, append([]byte(nil), keyPacket...))
+			keyPacketsLength = int64(buffer.Len())
+		case *packet.EncryptedKey:
+			keyPacketsLength = int64(buffer.Len())
+		default:
+			return keyPackets, keyPacketsLength, nil
+		}
+	}
+}
+
 func createPasswordPrompt(password []byte) func(keys []openpgp.Key, symmetric bool) ([]byte, error) {
 	if password == nil {
 		return nil
@@ -349,7 +544,10 @@ func (dh *decryptionHandle) decryptionConfig(configTime int64) *packet.Config {
 	}
 
 	// Should the session key be returned.
-	config.CacheSessionKey = dh.RetrieveSessionKey
+	config.CacheSessionKey = dh.RetrieveSessionKey || dh.SessionKeyCache != nil
+
+	// Accept the context and known notations in critical notations of signatures.
+	config.KnownNotations = knownNotations(dh.VerificationContext, dh.KnownNotations)
 
 	// Set time.
 	config.Time = NewConstantClock(configTime)
@@ -2,8 +2,12 @@ package crypto
 
 import (
 	"bytes"
+	"context"
+	goerrors "errors"
+	"io"
 
 	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	openpgp "github.com/ProtonMail/go-crypto/openpgp/v2"
 	"github.com/ProtonMail/gopenpgp/v3/internal"
 
 	"github.com/pkg/errors"
@@ -16,6 +20,9 @@ type decryptionHandle struct {
 	// Assumes that the message was encrypted towards a public key in DecryptionKeyRing.
 	// If nil, set another field for the type of decryption: SessionKey or Password
 	DecryptionKeyRing *KeyRing
+	// DecryptionKeyResolver looks up the decryption keys by the key ids of the key packets
+	// during each decryption, in addition to the keys in DecryptionKeyRing.
+	DecryptionKeyResolver KeyResolver
 	// SessionKeys provides one or more session keys for decrypting the pgp message.
 	// Assumes that the message was encrypted with one of the session keys provided.
 	// If nil, set another field for the type of decryption: DecryptionKeyRing or Password
@@ -27,9 +34,74 @@ type decryptionHandle struct {
 	// VerifyKeyRing provides a set of public keys to verify the signature of the pgp message, if any.
 	// If nil, the signatures are not verified.
 	VerifyKeyRing *KeyRing
+	// VerificationKeyResolver looks up the verification keys by key id during each decryption,
+	// in addition to the keys in VerifyKeyRing.
+	VerificationKeyResolver KeyResolver
+	// SessionKeyCache caches the session keys decrypted with DecryptionKeyRing,
+	// such that messages with the same key packets skip the asymmetric decryption.
+	// If nil, no session keys are cached.
+	SessionKeyCache *SessionKeyCache
+	// DecryptionKeyHints provides the key ids of the keys in DecryptionKeyRing that are
+	// tried first to decrypt the key packets, e.g., as looked up in an index of recipients.
+	// If nil, the keys are tried in the order of DecryptionKeyRing.
+	DecryptionKeyHints []uint64
+	// OnlyHintedDecryptionKeys indicates that only the keys matching DecryptionKeyHints
+	// are used to decrypt the key packets.
+	OnlyHintedDecryptionKeys bool
+	// MaxPlaintextSize limits the size in bytes of the decrypted plaintext.
+	// Reading a larger plaintext fails with an error.
+	// If zero, the plaintext size is not limited.
+	MaxPlaintextSize int64
+	// MaxCompressionRatio limits the ratio between the size of the decrypted plaintext
+	// and the size of the pgp message read so far, to detect decompression bombs.
+	// The ratio is checked once the plaintext exceeds compressionRatioCheckThreshold bytes.
+	// If zero, the compression ratio is not limited.
+	MaxCompressionRatio int64
+	// DecryptionParallelism is the number of goroutines used to decrypt the chunks
+	// of SEIPDv2 (AEAD) data packets when decrypting with SessionKeys.
+	// If zero or one, the chunks are decrypted sequentially.
+	DecryptionParallelism int
+	// MaxBufferSize bounds the bytes that are buffered internally while decrypting a message,
+	// i.e., the key packets and the chunks of AEAD data packets. Messages that need larger
+	// buffers fail to decrypt, and DecryptionParallelism is reduced to fit into the bound.
+	// If zero, the buffering is not bounded.
+	MaxBufferSize int64
+	// ProgressListener is notified about the message bytes read
+	// and the plaintext bytes produced by DecryptingReader.
+	// If nil, no progress is reported.
+	ProgressListener ProgressListener
+	// Observer is notified once a decryption completed, see OperationObserver.
+	// If nil, no operations are reported.
+	Observer OperationObserver
+	// Logger is called with the decryption key and the signature candidates, see Logger.
+	// If nil, nothing is logged.
+	Logger Logger
 	// VerificationContext provides a verification context for the signature of the pgp message, if any.
 	// Only considered if VerifyKeyRing is not nil.
 	VerificationContext *VerificationContext
+	// RequireAllSignatures indicates that the verification only succeeds
+	// if all signatures in the message are valid.
+	RequireAllSignatures bool
+	// RequiredSigners and RequiredSignatureThreshold indicate that the verification only succeeds
+	// if at least RequiredSignatureThreshold distinct keys in RequiredSigners have a valid signature.
+	// If RequiredSigners is nil, any valid signature is accepted.
+	RequiredSigners            *KeyRing
+	RequiredSignatureThreshold int
+	// VerificationPolicy refuses otherwise valid signatures, e.g., with weak algorithms.
+	// If nil, all valid signatures are accepted.
+	VerificationPolicy VerificationPolicy
+	// KnownNotations contains the names of critical notations that are accepted in signatures.
+	// Signatures with unknown critical notations are invalid.
+	KnownNotations []string
+	// KeyLookup fetches the keys of signers that are not among the verification keys.
+	KeyLookup KeyLookup
+	// WebOfTrust computes the validity of the signers.
+	WebOfTrust *WebOfTrust
+	// KeyTransparency attests the inclusion of the signers in a key transparency log.
+	KeyTransparency KeyTransparencyVerifier
+	// AllowedClockSkew is the tolerance in seconds for the creation and expiration time
+	// of signatures, e.g., to accept signatures from clients with slightly fast clocks.
+	AllowedClockSkew int64
 	// PlainDetachedSignature indicates that all provided detached signatures are not encrypted.
 	PlainDetachedSignature bool
 	// DisableIntendedRecipients indicates if the signature verification should not check if
@@ -42,9 +114,12 @@ type decryptionHandle struct {
 	InsecureDisableUnauthenticatedMessagesCheck bool
 	InsecureAllowDecryptionWithSigningKeys      bool
 	RetrieveSessionKey                          bool
-	IsUTF8                                      bool
-	clock                                       Clock
-	profile                                     EncryptionProfile
+	// ClearSessionKeys indicates that the session keys in SessionKeys are cleared from memory
+	// once a message has been fully read.
+	ClearSessionKeys bool
+	IsUTF8           bool
+	clock            Clock
+	profile          EncryptionProfile
 }
 
 // --- Default decryption handle to build from
@@ -62,7 +137,7 @@ func defaultDecryptionHandle(profile EncryptionProfile, clock Clock) *decryption
 // such that any read-operation via the wrapper results in a read from the decrypted pgp message.
 // The returned VerifyDataReader has to be fully read before any potential signatures can be verified.
 // Either read the message fully end then call VerifySignature or use the helper method ReadAllAndVerifySignature.
-// The encoding indicates if the input message should be unarmored or not, i.e., Bytes/Armor/Auto
+// The encoding indicates if the input message should be unarmored or not, i.e., Bytes/Armor/Base64/Auto
 // where Auto tries to detect automatically.
 // If encryptedMessage is of type PGPSplitReader, the method tries to verify an encrypted detached signature
 // that is read from the separate reader.
@@ -71,20 +146,60 @@ func (dh *decryptionHandle) DecryptingReader(encryptedMessage Reader, encoding i
 	if err != nil {
 		return
 	}
+	if dh.Observer != nil {
+		observation := startObservation(dh.Observer, OperationDecrypt, "")
+		handle := *dh
+		handle.Observer = nil
+		plainMessageReader, err = handle.DecryptingReader(encryptedMessage, encoding)
+		return observeReader(observation, plainMessageReader, err)
+	}
 	pgpSplitReader := isPGPSplitReader(encryptedMessage)
 	if pgpSplitReader != nil {
-		return dh.decryptingReader(pgpSplitReader, pgpSplitReader.Signature(), encoding)
+		plainMessageReader, err = dh.decryptingReader(pgpSplitReader, pgpSplitReader.Signature(), encoding)
+	} else {
+		plainMessageReader, err = dh.decryptingReader(encryptedMessage, nil, encoding)
 	}
-	return dh.decryptingReader(encryptedMessage, nil, encoding)
+	dh.logDecryption(plainMessageReader, err)
+	return plainMessageReader, err
+}
+
+// DecryptingReaderContext is like DecryptingReader but aborts the decryption once ctx is done.
+// Reads from the returned VerifyDataReader then fail with ctx.Err(),
+// even if a read from the encrypted message blocks.
+// Not supported on go-mobile clients.
+func (dh *decryptionHandle) DecryptingReaderContext(ctx context.Context, encryptedMessage Reader, encoding int8) (plainMessageReader *VerifyDataReader, err error) {
+	if err = ctx.Err(); err != nil {
+		return nil, err
+	}
+	err = dh.validate()
+	if err != nil {
+		return
+	}
+	var encryptedSignature Reader
+	if pgpSplitReader := isPGPSplitReader(encryptedMessage); pgpSplitReader != nil {
+		encryptedSignature = newContextReader(ctx, pgpSplitReader.Signature())
+	}
+	plainMessageReader, err = dh.decryptingReader(newContextReader(ctx, encryptedMessage), encryptedSignature, encoding)
+	dh.logDecryption(plainMessageReader, err)
+	if err != nil {
+		return nil, err
+	}
+	plainMessageReader.internalReader = &contextCheckReader{ctx: ctx, reader: plainMessageReader.internalReader}
+	return plainMessageReader, nil
 }
 
 // Decrypt decrypts an encrypted pgp message.
 // Returns a VerifiedDataResult, which can be queried for potential signature verification errors,
 // and the plaintext data. Note that on a signature error, the method does not return an error.
 // Instead, the signature error is stored within the VerifiedDataResult.
-// The encoding indicates if the input message should be unarmored or not, i.e., Bytes/Armor/Auto
+// The encoding indicates if the input message should be unarmored or not, i.e., Bytes/Armor/Base64/Auto
 // where Auto tries to detect automatically.
 func (dh *decryptionHandle) Decrypt(pgpMessage []byte, encoding int8) (*VerifiedDataResult, error) {
+	if dh.KeyLookup != nil {
+		return dh.retryWithKeyLookup(func(handle *decryptionHandle) (*VerifiedDataResult, error) {
+			return handle.Decrypt(pgpMessage, encoding)
+		})
+	}
 	messageReader := bytes.NewReader(pgpMessage)
 	plainMessageReader, err := dh.DecryptingReader(messageReader, encoding)
 	if err != nil {
@@ -97,8 +212,13 @@ func (dh *decryptionHandle) Decrypt(pgpMessage []byte, encoding int8) (*Verified
 // to supply an encrypted detached signature that should be decrypted and verified
 // against the data in the pgp message. If encDetachedSignature is nil, the behavior is similar
 // to Decrypt. The encoding indicates if the input message should be unarmored or not,
-// i.e., Bytes/Armor/Auto where Auto tries to detect automatically.
+// i.e., Bytes/Armor/Base64/Auto where Auto tries to detect automatically.
 func (dh *decryptionHandle) DecryptDetached(pgpMessage []byte, encryptedDetachedSig []byte, encoding int8) (*VerifiedDataResult, error) {
+	if dh.KeyLookup != nil {
+		return dh.retryWithKeyLookup(func(handle *decryptionHandle) (*VerifiedDataResult, error) {
+			return handle.DecryptDetached(pgpMessage, encryptedDetachedSig, encoding)
+		})
+	}
 	reader := &pgpSplitReader{
 		encMessage: bytes.NewReader(pgpMessage),
 	}
@@ -124,8 +244,13 @@ func (dh *decryptionHandle) DecryptSessionKey(keyPackets []byte) (sk *SessionKey
 			}
 		}
 		return nil, err
-	case dh.DecryptionKeyRing != nil:
-		return decryptSessionKey(dh.DecryptionKeyRing, keyPackets)
+	case dh.DecryptionKeyRing != nil || dh.DecryptionKeyResolver != nil:
+		handle := dh.withKeyResolvers()
+		sk, err = decryptSessionKey(handle.decryptionKeyRing(), keyPackets, dh.DecryptionKeyHints)
+		if resolutionErr := handle.DecryptionKeyRing.resolutionError(); err != nil && resolutionErr != nil {
+			return nil, resolutionErr
+		}
+		return sk, err
 	}
 	return nil, errors.New("gopenpgp: no decryption key or password provided")
 }
@@ -147,11 +272,83 @@ func (dh *decryptionHandle) ClearPrivateParams() {
 	}
 }
 
+// decryptionKeyRing returns the decryption keys ordered such that the entities
+// matching DecryptionKeyHints come first.
+// If OnlyHintedDecryptionKeys is set, the other entities are omitted.
+func (dh *decryptionHandle) decryptionKeyRing() *KeyRing {
+	if dh.DecryptionKeyRing == nil || len(dh.DecryptionKeyHints) == 0 || dh.DecryptionKeyRing.resolution != nil {
+		return dh.DecryptionKeyRing
+	}
+	var hinted, other openpgp.EntityList
+	for _, entity := range dh.DecryptionKeyRing.entities {
+		if entityMatchesKeyIDs(entity, dh.DecryptionKeyHints) {
+			hinted = append(hinted, entity)
+		} else {
+			other = append(other, entity)
+		}
+	}
+	if !dh.OnlyHintedDecryptionKeys {
+		hinted = append(hinted, other...)
+	}
+	return &KeyRing{
+		entities:   hinted,
+		FirstKeyID: dh.DecryptionKeyRing.FirstKeyID,
+	}
+}
+
 func (dh *decryptionHandle) validate() error {
-	if dh.DecryptionKeyRing == nil && len(dh.Passwords) == 0 && len(dh.SessionKeys) == 0 {
+	if dh.DecryptionKeyRing == nil && dh.DecryptionKeyResolver == nil && len(dh.Passwords) == 0 && len(dh.SessionKeys) == 0 {
 		return errors.New("gopenpgp: no decryption key material provided")
 	}
-	return nil
+	if dh.OnlyHintedDecryptionKeys && len(dh.DecryptionKeyHints) == 0 {
+		return errors.New("gopenpgp: no decryption key hints provided")
+	}
+	if dh.MaxPlaintextSize < 0 || dh.MaxCompressionRatio < 0 {
+		return errors.New("gopenpgp: decryption limits must not be negative")
+	}
+	if dh.DecryptionParallelism < 0 {
+		return errors.New("gopenpgp: decryption parallelism must not be negative")
+	}
+	if dh.MaxBufferSize < 0 {
+		return errors.New("gopenpgp: maximum buffer size must not be negative")
+	}
+	if dh.AllowedClockSkew < 0 {
+		return errors.New("gopenpgp: allowed clock skew must not be negative")
+	}
+	return dh.signaturePolicy().validate()
+}
+
+// retryWithKeyLookup runs decrypt with a copy of the handle without key lookup.
+// If the keys of unknown signers are found with the lookup, decrypt runs again
+// with the found keys added to the verification keys.
+func (dh *decryptionHandle) retryWithKeyLookup(
+	decrypt func(handle *decryptionHandle) (*VerifiedDataResult, error),
+) (*VerifiedDataResult, error) {
+	handle := *dh
+	handle.KeyLookup = nil
+	result, err := decrypt(&handle)
+	if err != nil {
+		return nil, err
+	}
+	if handle.VerifyKeyRing, err = lookupUnknownSigners(dh.KeyLookup, dh.VerifyKeyRing, &result.VerifyResult); err != nil {
+		return nil, err
+	}
+	if handle.VerifyKeyRing == nil {
+		return result, nil
+	}
+	return decrypt(&handle)
+}
+
+func (dh *decryptionHandle) signaturePolicy() *signaturePolicy {
+	return newSignaturePolicy(
+		dh.RequireAllSignatures,
+		dh.RequiredSigners,
+		dh.RequiredSignatureThreshold,
+		dh.VerificationPolicy,
+		dh.WebOfTrust,
+		dh.KeyTransparency,
+		nil,
+	)
 }
 
 func (dh *decryptionHandle) decryptingReader(encryptedMessage Reader, encryptedSignature Reader, encoding int8) (plainMessageReader *VerifyDataReader, err error) {
@@ -159,6 +356,30 @@ func (dh *decryptionHandle) decryptingReader(encryptedMessage Reader, encryptedS
 	if err != nil {
 		return nil, err
 	}
+	if dh.DecryptionKeyResolver != nil || dh.VerificationKeyResolver != nil {
+		handle := dh.withKeyResolvers()
+		plainMessageReader, err = handle.decryptingReader(encryptedMessage, encryptedSignature, encoding)
+		// A failed resolution is the likely cause of a failed decryption.
+		if resolutionErr := handle.DecryptionKeyRing.resolutionError(); err != nil && resolutionErr != nil {
+			return nil, resolutionErr
+		}
+		return plainMessageReader, err
+	}
+	if dh.ProgressListener != nil {
+		progress := &progressTracker{listener: dh.ProgressListener}
+		handle := *dh
+		handle.ProgressListener = nil
+		encryptedMessage = &progressReader{reader: encryptedMessage, count: progress.addIn}
+		if encryptedSignature != nil {
+			encryptedSignature = &progressReader{reader: encryptedSignature, count: progress.addIn}
+		}
+		plainMessageReader, err = handle.decryptingReader(encryptedMessage, encryptedSignature, encoding)
+		if err != nil {
+			return nil, err
+		}
+		plainMessageReader.internalReader = &progressReader{reader: plainMessageReader.internalReader, count: progress.addOut}
+		return plainMessageReader, nil
+	}
 	var armored bool
 	encryptedMessage, armored = unarmorInput(encoding, encryptedMessage)
 	var armoredBlock *armor.Block
@@ -192,6 +413,50 @@ func (dh *decryptionHandle) decryptingReader(encryptedMessage Reader, encryptedS
 		}
 	}
 
+	if dh.MaxBufferSize > 0 {
+		if encryptedMessage, err = checkBufferBounds(encryptedMessage, dh.MaxBufferSize); err != nil {
+			return nil, err
+		}
+	}
+
+	encryptedMessage, integrityProtected, err := readIntegrityProtection(encryptedMessage)
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: reading data packet failed")
+	}
+	if !integrityProtected && !dh.InsecureDisableUnauthenticatedMessagesCheck {
+		return nil, tagError(errors.New("gopenpgp: message is not integrity protected"), ErrMessageNotIntegrityProtected)
+	}
+
+	strictRFC9580 := isStrictRFC9580(dh.profile)
+	var legacyConstructs []string
+	if strictRFC9580 {
+		encryptedMessage, legacyConstructs, err = readLegacyConstructs(encryptedMessage)
+		if err != nil {
+			return nil, errors.Wrap(err, "gopenpgp: reading key and data packets failed")
+		}
+	}
+
+	var messageCounter *countingReader
+	if dh.MaxCompressionRatio > 0 {
+		messageCounter = &countingReader{reader: encryptedMessage}
+		encryptedMessage = messageCounter
+	}
+
+	var sessionKeyCacheIDs []sessionKeyCacheID
+	if dh.SessionKeyCache != nil && dh.DecryptionKeyRing != nil {
+		encryptedMessage, sessionKeyCacheIDs, err = readSessionKeyCacheIDs(encryptedMessage)
+		if err != nil {
+			return nil, errors.Wrap(err, "gopenpgp: reading key packets failed")
+		}
+		sessionKeyCacheIDs = sessionKeyCacheIDsFor(sessionKeyCacheIDs, dh.DecryptionKeyRing)
+		if sessionKey := dh.SessionKeyCache.get(sessionKeyCacheIDs); sessionKey != nil {
+			cachedHandle := *dh
+			cachedHandle.SessionKeys = []*SessionKey{sessionKey}
+			cachedHandle.SessionKeyCache = nil
+			return cachedHandle.decryptingReader(encryptedMessage, encryptedSignature, Bytes)
+		}
+	}
+
 	var decryptionTried bool
 	if len(dh.SessionKeys) > 0 {
 		// Decrypt with session key.
@@ -227,12 +492,106 @@ func (dh *decryptionHandle) decryptingReader(encryptedMessage Reader, encryptedS
 	if err != nil {
 		return nil, err
 	}
+	if len(sessionKeyCacheIDs) > 0 {
+		dh.SessionKeyCache.add(sessionKeyCacheIDs, plainMessageReader.SessionKey())
+		if !dh.RetrieveSessionKey {
+			clearMem(plainMessageReader.details.SessionKey)
+			plainMessageReader.details.SessionKey = nil
+		}
+	}
+	if dh.ClearSessionKeys && len(dh.SessionKeys) > 0 {
+		plainMessageReader.internalReader = &sessionKeyClearingReader{
+			reader:      plainMessageReader.internalReader,
+			sessionKeys: dh.SessionKeys,
+			details:     plainMessageReader.details,
+		}
+	}
+	plainMessageReader.missingIntegrityProtection = !integrityProtected
+	plainMessageReader.strictRFC9580 = strictRFC9580
+	plainMessageReader.legacyConstructs = legacyConstructs
+	if dh.MaxPlaintextSize > 0 || dh.MaxCompressionRatio > 0 {
+		plainMessageReader.internalReader = &plaintextLimitReader{
+			reader:              plainMessageReader.internalReader,
+			maxSize:             dh.MaxPlaintextSize,
+			maxCompressionRatio: dh.MaxCompressionRatio,
+			messageCounter:      messageCounter,
+		}
+	}
 	if dh.IsUTF8 {
 		plainMessageReader.internalReader = internal.NewSanitizeReader(plainMessageReader.internalReader)
 	}
 	return plainMessageReader, nil
 }
 
+// compressionRatioCheckThreshold is the plaintext size in bytes from which on
+// the compression ratio limit of a decryption handle is enforced.
+const compressionRatioCheckThreshold = 1 << 20
+
+// countingReader counts the bytes read from the underlying reader.
+type countingReader struct {
+	reader Reader
+	count  int64
+}
+
+func (r *countingReader) Read(b []byte) (n int, err error) {
+	n, err = r.reader.Read(b)
+	r.count += int64(n)
+	return
+}
+
+// plaintextLimitReader returns an error once the plaintext exceeds the size limit
+// or the ratio between plaintext and message size exceeds the compression ratio limit.
+type plaintextLimitReader struct {
+	reader              Reader
+	maxSize             int64
+	maxCompressionRatio int64
+	messageCounter      *countingReader
+	size                int64
+	err                 error
+}
+
+func (r *plaintextLimitReader) Read(b []byte) (n int, err error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	n, err = r.reader.Read(b)
+	r.size += int64(n)
+	if r.maxSize > 0 && r.size > r.maxSize {
+		// Do not return plaintext beyond the limit.
+		n -= int(r.size - r.maxSize)
+		r.size = r.maxSize
+		r.err = errors.Errorf("gopenpgp: plaintext exceeds the size limit of %d bytes", r.maxSize)
+		return n, r.err
+	}
+	if r.maxCompressionRatio > 0 && r.size > compressionRatioCheckThreshold &&
+		r.size/r.maxCompressionRatio > r.messageCounter.count {
+		r.err = errors.Errorf("gopenpgp: plaintext exceeds the compression ratio limit of %d", r.maxCompressionRatio)
+		return n, r.err
+	}
+	return
+}
+
+// sessionKeyClearingReader clears the session keys once the underlying reader is fully read.
+// The session key in the message details references the key of the selected session key,
+// and is thus removed as well.
+type sessionKeyClearingReader struct {
+	reader      Reader
+	sessionKeys []*SessionKey
+	details     *openpgp.MessageDetails
+}
+
+func (r *sessionKeyClearingReader) Read(b []byte) (n int, err error) {
+	n, err = r.reader.Read(b)
+	if goerrors.Is(err, io.EOF) {
+		for _, sessionKey := range r.sessionKeys {
+			sessionKey.Clear()
+		}
+		r.sessionKeys = nil
+		r.details.SessionKey = nil
+	}
+	return
+}
+
 func isPGPSplitReader(w Reader) PGPSplitReader {
 	v, ok := interface{}(w).(PGPSplitReader)
 	if ok {
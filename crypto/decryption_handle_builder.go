@@ -26,6 +26,19 @@ func (dpb *DecryptionHandleBuilder) DecryptionKeys(decryptionKeyRing *KeyRing) *
 	return dpb
 }
 
+// DecryptionKeyResolver sets a resolver that looks up the secret keys by the key ids
+// of the key packets during each decryption, e.g., in a database,
+// in addition to the keys set with DecryptionKeys.
+// Only the keys of recipients of the message are resolved, key packets with
+// wildcard key ids are only decrypted with the keys set with DecryptionKeys.
+// The resolved keys must be unlocked.
+// See KeyResolverFunc to use a function.
+// Not supported on go-mobile clients.
+func (dpb *DecryptionHandleBuilder) DecryptionKeyResolver(resolver KeyResolver) *DecryptionHandleBuilder {
+	dpb.handle.DecryptionKeyResolver = resolver
+	return dpb
+}
+
 func (dpb *DecryptionHandleBuilder) DecryptionKey(decryptionKey *Key) *DecryptionHandleBuilder {
 	var err error
 	if dpb.handle.DecryptionKeyRing == nil {
@@ -67,6 +80,9 @@ func (dpb *DecryptionHandleBuilder) Password(password []byte) *DecryptionHandleB
 
 // Passwords sets passwords that are used to derive keys to decrypt the pgp message.
 // Assumes that the message was encrypted with one of the keys derived from the passwords.
+// The passwords are tried in order against all password encrypted key packets of the message,
+// e.g., the current and previous recovery phrases. The index of the password that
+// decrypted the message is reported by PasswordIndex on the decryption result.
 // Triggers the password decryption mode.
 // If not set, set another field for the type of decryption: DecryptionKeys or SessionKey.
 // Not supported on go-mobile clients.
@@ -75,6 +91,94 @@ func (dpb *DecryptionHandleBuilder) Passwords(passwords [][]byte) *DecryptionHan
 	return dpb
 }
 
+// SessionKeyCache sets a cache for the session keys that are decrypted with the decryption keys.
+// If a message contains key packets that are already in the cache, the cached session key
+// is used and the asymmetric decryption is skipped, e.g., for chunked attachments
+// that are encrypted with the same session key.
+// The cache can be shared between decryption handles, a cached session key is only used
+// if the decryption keys contain an unlocked private key for one of the key packets,
+// that matches the key id and, for v6 key packets, the fingerprint of the packet.
+// If not set, no session keys are cached.
+func (dpb *DecryptionHandleBuilder) SessionKeyCache(cache *SessionKeyCache) *DecryptionHandleBuilder {
+	dpb.handle.SessionKeyCache = cache
+	return dpb
+}
+
+// DecryptionKeyIDHints sets the key ids of the decryption keys that are tried first
+// to decrypt the key packets, e.g., the recipients of the message as looked up in an index.
+// Avoids trial decryptions with many decryption keys on messages with many key packets
+// or with anonymous recipients.
+// Not supported on go-mobile clients use DecryptionKeyHint instead.
+func (dpb *DecryptionHandleBuilder) DecryptionKeyIDHints(keyIDs ...uint64) *DecryptionHandleBuilder {
+	dpb.handle.DecryptionKeyHints = append(dpb.handle.DecryptionKeyHints, keyIDs...)
+	return dpb
+}
+
+// DecryptionKeyHint adds a decryption key that is tried first to decrypt the key packets,
+// given as hex encoded key id or fingerprint of the primary key or a subkey.
+// See DecryptionKeyIDHints.
+func (dpb *DecryptionHandleBuilder) DecryptionKeyHint(hexID string) *DecryptionHandleBuilder {
+	keyID, err := keyIDFromHex(hexID)
+	if err != nil {
+		dpb.err = err
+		return dpb
+	}
+	dpb.handle.DecryptionKeyHints = append(dpb.handle.DecryptionKeyHints, keyID)
+	return dpb
+}
+
+// OnlyHintedDecryptionKeys restricts the decryption to the decryption keys
+// that match the hints set with DecryptionKeyIDHints or DecryptionKeyHint.
+// Messages that are not encrypted to one of the hinted keys fail to decrypt.
+func (dpb *DecryptionHandleBuilder) OnlyHintedDecryptionKeys() *DecryptionHandleBuilder {
+	dpb.handle.OnlyHintedDecryptionKeys = true
+	return dpb
+}
+
+// MaxPlaintextSize limits the size in bytes of the decrypted plaintext,
+// e.g., to bound the memory used to decrypt messages from untrusted sources.
+// Reading a larger plaintext fails with an error.
+// If not set or zero, the plaintext size is not limited.
+func (dpb *DecryptionHandleBuilder) MaxPlaintextSize(size int64) *DecryptionHandleBuilder {
+	dpb.handle.MaxPlaintextSize = size
+	return dpb
+}
+
+// MaxCompressionRatio limits the ratio between the size of the decrypted plaintext
+// and the size of the encrypted message, to reject decompression bombs,
+// i.e., small compressed messages that expand to a huge plaintext.
+// The ratio is enforced once the plaintext exceeds 1 MiB.
+// Reading a plaintext that exceeds the ratio fails with an error.
+// If not set or zero, the compression ratio is not limited.
+func (dpb *DecryptionHandleBuilder) MaxCompressionRatio(ratio int64) *DecryptionHandleBuilder {
+	dpb.handle.MaxCompressionRatio = ratio
+	return dpb
+}
+
+// DecryptionParallelism sets the number of goroutines used to decrypt
+// the chunks of SEIPDv2 (AEAD) messages in parallel, e.g., to decrypt large attachments on multiple cores.
+// The plaintext is still returned in order.
+// Only applies to decryption with session keys, i.e., the key packets
+// have to be decrypted first with DecryptSessionKey, see PGPMessage.KeyPacket.
+// If not set, zero, or one, the chunks are decrypted sequentially.
+func (dpb *DecryptionHandleBuilder) DecryptionParallelism(workers int) *DecryptionHandleBuilder {
+	dpb.handle.DecryptionParallelism = workers
+	return dpb
+}
+
+// MaxBufferSize bounds the bytes buffered internally by DecryptingReader, e.g., to decrypt
+// messages of several gigabytes within the memory budget of a mobile app.
+// The key packets and the chunks of SEIPDv2 (AEAD) data packets must fit into size bytes,
+// otherwise the decryption fails before allocating the buffers.
+// The plaintext is streamed, thus no buffer grows with the message size.
+// DecryptionParallelism is reduced such that the chunks decrypted in parallel fit into size bytes.
+// Note that Decrypt and DecryptDetached return the entire plaintext, use DecryptingReader instead.
+// If not set or zero, the buffering is not bounded.
+func (dpb *DecryptionHandleBuilder) MaxBufferSize(size int64) *DecryptionHandleBuilder {
+	dpb.handle.MaxBufferSize = size
+	return dpb
+}
+
 // VerificationKeys sets the public keys for verifying the signatures of the pgp message, if any.
 // If not set, the signatures cannot be verified.
 func (dpb *DecryptionHandleBuilder) VerificationKeys(keys *KeyRing) *DecryptionHandleBuilder {
@@ -85,13 +189,17 @@ func (dpb *DecryptionHandleBuilder) VerificationKeys(keys *KeyRing) *DecryptionH
 // VerificationKey sets the public key for verifying the signatures of the pgp message, if any.
 // If not set, the signatures cannot be verified.
 func (dpb *DecryptionHandleBuilder) VerificationKey(key *Key) *DecryptionHandleBuilder {
-	var err error
-	if dpb.handle.VerifyKeyRing == nil {
-		dpb.handle.VerifyKeyRing, err = NewKeyRing(key)
-	} else {
-		err = dpb.handle.VerifyKeyRing.AddKey(key)
-	}
-	dpb.err = err
+	dpb.handle.VerifyKeyRing, dpb.err = keyRingWithKey(dpb.handle.VerifyKeyRing, key)
+	return dpb
+}
+
+// VerificationKeyResolver sets a resolver that looks up the public keys by the key ids
+// of the signatures during each decryption, e.g., in a database,
+// in addition to the keys set with VerificationKeys.
+// See KeyResolverFunc to use a function.
+// Not supported on go-mobile clients.
+func (dpb *DecryptionHandleBuilder) VerificationKeyResolver(resolver KeyResolver) *DecryptionHandleBuilder {
+	dpb.handle.VerificationKeyResolver = resolver
 	return dpb
 }
 
@@ -102,6 +210,74 @@ func (dpb *DecryptionHandleBuilder) VerificationContext(verifyContext *Verificat
 	return dpb
 }
 
+// RequireAllSignatures indicates that the signature verification only succeeds
+// if all signatures in the message are valid, e.g., if a message is signed with multiple keys.
+// Signatures from keys that are not among the verification keys are invalid.
+// If not set, the verification succeeds if at least one signature is valid.
+func (dpb *DecryptionHandleBuilder) RequireAllSignatures() *DecryptionHandleBuilder {
+	dpb.handle.RequireAllSignatures = true
+	return dpb
+}
+
+// RequireSignatures indicates that the signature verification only succeeds
+// if at least threshold distinct keys in signers have a valid signature, i.e., a k-of-n policy.
+// The signers must also be among the verification keys.
+// If not set, the verification succeeds if at least one signature is valid.
+func (dpb *DecryptionHandleBuilder) RequireSignatures(threshold int, signers *KeyRing) *DecryptionHandleBuilder {
+	dpb.handle.RequiredSigners = signers
+	dpb.handle.RequiredSignatureThreshold = threshold
+	return dpb
+}
+
+// VerificationPolicy sets a policy that refuses otherwise valid signatures,
+// e.g., signatures with weak hash algorithms or from small RSA keys, see DefaultAlgorithmPolicy.
+// A refused signature results in a signature error with status constants.SIGNATURE_POLICY_VIOLATION.
+// If not set, all valid signatures are accepted.
+// Not supported on go-mobile clients.
+func (dpb *DecryptionHandleBuilder) VerificationPolicy(policy VerificationPolicy) *DecryptionHandleBuilder {
+	dpb.handle.VerificationPolicy = policy
+	return dpb
+}
+
+// KnownNotation marks the notation name as known, such that signatures with
+// a critical notation of this name are accepted.
+// Signatures with unknown critical notations are invalid.
+// Can be called multiple times to add multiple names.
+func (dpb *DecryptionHandleBuilder) KnownNotation(name string) *DecryptionHandleBuilder {
+	dpb.handle.KnownNotations = append(dpb.handle.KnownNotations, name)
+	return dpb
+}
+
+// KeyLookup sets a lookup for the keys of signers that are not among the verification keys,
+// e.g., to fetch them from a key server or a database.
+// If a signature is from an unknown signer and the lookup finds its key,
+// the verification is repeated with the found key. See KeyLookupFunc to use a function.
+// Only considered by Decrypt and DecryptDetached, since the input
+// of the streaming functions cannot be read twice.
+// Not supported on go-mobile clients.
+func (dpb *DecryptionHandleBuilder) KeyLookup(lookup KeyLookup) *DecryptionHandleBuilder {
+	dpb.handle.KeyLookup = lookup
+	return dpb
+}
+
+// WebOfTrust sets the web of trust that computes the validity of the signers
+// at the verification time, see (VerifyResult).SignedByValidity.
+// The validity does not affect the verification result.
+func (dpb *DecryptionHandleBuilder) WebOfTrust(webOfTrust *WebOfTrust) *DecryptionHandleBuilder {
+	dpb.handle.WebOfTrust = webOfTrust
+	return dpb
+}
+
+// KeyTransparency sets the verifier that attests the inclusion of the signers
+// in a key transparency log, including keys found by the KeyLookup,
+// see (VerifyResult).SignedByTransparency.
+// The attestation does not affect the verification result.
+// Not supported on go-mobile clients.
+func (dpb *DecryptionHandleBuilder) KeyTransparency(verifier KeyTransparencyVerifier) *DecryptionHandleBuilder {
+	dpb.handle.KeyTransparency = verifier
+	return dpb
+}
+
 // VerifyTime sets the verification time to the provided timestamp.
 // If not set, the systems current time is used for signature verification.
 func (dpb *DecryptionHandleBuilder) VerifyTime(unixTime int64) *DecryptionHandleBuilder {
@@ -123,6 +299,16 @@ func (dpb *DecryptionHandleBuilder) PlainDetachedSignature() *DecryptionHandleBu
 	return dpb
 }
 
+// AllowedClockSkew sets the tolerance in seconds for the creation and expiration time of signatures.
+// For example, with a skew of 600 seconds, signatures that were created up to ten minutes
+// after the verification time by a client with a fast clock are accepted,
+// as well as signatures that expired up to ten minutes before it.
+// If not set, the times are checked exactly.
+func (dpb *DecryptionHandleBuilder) AllowedClockSkew(seconds int64) *DecryptionHandleBuilder {
+	dpb.handle.AllowedClockSkew = seconds
+	return dpb
+}
+
 // DisableVerifyTimeCheck disables the check for comparing the signature creation time
 // against the verification time.
 func (dpb *DecryptionHandleBuilder) DisableVerifyTimeCheck() *DecryptionHandleBuilder {
@@ -162,12 +348,26 @@ func (dpb *DecryptionHandleBuilder) DisableAutomaticTextSanitize() *DecryptionHa
 // In case one needs to deal with messages from very old OpenPGP implementations, there
 // might be no other way than to tolerate the missing MDC. Setting this flag, allows this
 // mode of operation. It should be considered a measure of last resort.
+// Decryption results of such messages report an IntegrityWarning.
 // SECURITY HAZARD: Use with care.
 func (dpb *DecryptionHandleBuilder) InsecureDisableUnauthenticatedMessagesCheck() *DecryptionHandleBuilder {
 	dpb.handle.InsecureDisableUnauthenticatedMessagesCheck = true
 	return dpb
 }
 
+// InsecureAllowMissingMDC enables to decrypt legacy symmetrically encrypted data packets
+// without Modification Detection Code (MDC), e.g., to migrate archives
+// that were encrypted with ancient OpenPGP tools.
+// The plaintext of such messages is not integrity protected and might have been modified.
+// Thus, the decryption results of such messages report a non-nil IntegrityWarning,
+// which must be checked before trusting the plaintext.
+// Equivalent to InsecureDisableUnauthenticatedMessagesCheck.
+// SECURITY HAZARD: Use with care.
+func (dpb *DecryptionHandleBuilder) InsecureAllowMissingMDC() *DecryptionHandleBuilder {
+	dpb.handle.InsecureDisableUnauthenticatedMessagesCheck = true
+	return dpb
+}
+
 // InsecureAllowDecryptionWithSigningKeys enables decryption of messages using keys
 // that are designated solely as signing keys.
 // While using the same key for both encryption and signing is discouraged
@@ -182,11 +382,48 @@ func (dpb *DecryptionHandleBuilder) InsecureAllowDecryptionWithSigningKeys() *De
 
 // RetrieveSessionKey sets the flag to indicate if the session key used for decryption
 // should be returned to the caller of the decryption function.
+// The session key is available via SessionKey on the decryption result or reader,
+// e.g., to cache it or to encrypt it to further recipients
+// without decrypting the key packets again.
 func (dpb *DecryptionHandleBuilder) RetrieveSessionKey() *DecryptionHandleBuilder {
 	dpb.handle.RetrieveSessionKey = true
 	return dpb
 }
 
+// ClearSessionKeys indicates that the session keys set on the handle
+// are cleared from memory once a message has been fully decrypted.
+// After the first message is read, the handle cannot decrypt with the session keys anymore.
+func (dpb *DecryptionHandleBuilder) ClearSessionKeys() *DecryptionHandleBuilder {
+	dpb.handle.ClearSessionKeys = true
+	return dpb
+}
+
+// ProgressListener sets a listener that is notified about the progress of the decryption,
+// i.e., the message bytes read and the plaintext bytes produced so far.
+// If not set, no progress is reported.
+func (dpb *DecryptionHandleBuilder) ProgressListener(listener ProgressListener) *DecryptionHandleBuilder {
+	dpb.handle.ProgressListener = listener
+	return dpb
+}
+
+// Observer sets an observer that is notified once an decryption completed,
+// e.g., to export metrics. Overrides the observer set with PGPHandle.WithObserver.
+// If not set, no operations are reported.
+// Not supported on go-mobile clients.
+func (dpb *DecryptionHandleBuilder) Observer(observer OperationObserver) *DecryptionHandleBuilder {
+	dpb.handle.Observer = observer
+	return dpb
+}
+
+// Logger sets a logger that is called with the key or password that decrypted a message, and the status of each signature candidate,
+// e.g., to diagnose interoperability failures. Overrides the logger set with PGPHandle.WithLogger.
+// If not set, nothing is logged.
+// Not supported on go-mobile clients.
+func (dpb *DecryptionHandleBuilder) Logger(logger Logger) *DecryptionHandleBuilder {
+	dpb.handle.Logger = logger
+	return dpb
+}
+
 // New creates a DecryptionHandle and checks that the given
 // combination of parameters is valid. If one of the parameters are invalid
 // the latest error is returned.
@@ -1,6 +1,8 @@
 package crypto
 
 import (
+	"bytes"
+	"encoding/base64"
 	"io"
 
 	armorHelper "github.com/ProtonMail/gopenpgp/v3/armor"
@@ -14,6 +16,10 @@ const (
 	Armor int8 = 0
 	Bytes int8 = 1 // Default for other int8 values.
 	Auto  int8 = 2
+	// Base64 encodes the binary data in standard base64 without armor headers,
+	// line breaks, or checksum, e.g., for transports and database columns that expect base64.
+	// Line breaks are ignored on input.
+	Base64 int8 = 3
 )
 
 func armorOutput(e int8) bool {
@@ -27,6 +33,47 @@ func unarmorInput(e int8, input io.Reader) (reader Reader, unarmor bool) {
 		unarmor = true
 	case Auto:
 		reader, unarmor = armorHelper.IsPGPArmored(input)
+		if !unarmor {
+			var base64Encoded bool
+			if reader, base64Encoded = isBase64Encoded(reader); base64Encoded {
+				reader = newBase64Reader(reader)
+			}
+		}
+	case Base64:
+		reader = newBase64Reader(input)
 	}
 	return
 }
+
+// newBase64Reader returns a reader that decodes the base64 encoded input.
+func newBase64Reader(input io.Reader) Reader {
+	return base64.NewDecoder(base64.StdEncoding, input)
+}
+
+// newBase64Writer returns a writer that base64 encodes the data written to output.
+// The writer must be closed to write the final block.
+func newBase64Writer(output io.Writer) WriteCloser {
+	return base64.NewEncoder(base64.StdEncoding, output)
+}
+
+// encodeBase64 returns the base64 encoding of data.
+func encodeBase64(data []byte) []byte {
+	encoded := make([]byte, base64.StdEncoding.EncodedLen(len(data)))
+	base64.StdEncoding.Encode(encoded, data)
+	return encoded
+}
+
+// isBase64Encoded checks if the input starts with a base64 character.
+// Binary OpenPGP data cannot, since packet tags have the most significant bit set.
+// Returns a reader that is reset to the state of the input reader.
+// Only the first byte is read, instead of buffering the input,
+// since the input is usually already buffered, e.g., by armorHelper.IsPGPArmored.
+func isBase64Encoded(input io.Reader) (Reader, bool) {
+	var prefix [1]byte
+	if n, _ := io.ReadFull(input, prefix[:]); n == 0 {
+		return input, false
+	}
+	reader := io.MultiReader(bytes.NewReader(prefix[:]), input)
+	c := prefix[0]
+	return reader, c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '+' || c == '/'
+}
@@ -1,7 +1,11 @@
 package crypto
 
 import (
+	"errors"
 	"testing"
+	"time"
+
+	pgpErrors "github.com/ProtonMail/go-crypto/openpgp/errors"
 )
 
 const wrongTestContext = "wrong-context"
@@ -23,8 +27,8 @@ func TestDecryptWrongKey(t *testing.T) {
 			if err != nil {
 				t.Fatal(err)
 			}
-			if _, err = decHandle.Decrypt(pgpMessage.Bytes(), Bytes); err == nil {
-				t.Fatal("should not decrypt with wrong key")
+			if _, err = decHandle.Decrypt(pgpMessage.Bytes(), Bytes); !errors.Is(err, ErrNoDecryptionKey) {
+				t.Fatal("should not decrypt with wrong key, expected ErrNoDecryptionKey, got:", err)
 			}
 		})
 	}
@@ -44,8 +48,8 @@ func TestDecryptWrongPassword(t *testing.T) {
 			if err != nil {
 				t.Fatal(err)
 			}
-			if _, err = decHandle.Decrypt(pgpMessage.Bytes(), Bytes); err == nil {
-				t.Fatal("should not decrypt with wrong password")
+			if _, err = decHandle.Decrypt(pgpMessage.Bytes(), Bytes); !errors.Is(err, ErrWrongPassword) {
+				t.Fatal("should not decrypt with wrong password, expected ErrWrongPassword, got:", err)
 			}
 		})
 	}
@@ -235,3 +239,58 @@ func testVerificationSuccess(
 		t.Fatal("Expected no signature failure")
 	}
 }
+
+func TestErrorTaxonomy(t *testing.T) {
+	lockedKey, err := testPGP.LockKey(keyTestEC, keyTestPassphrase)
+	if err != nil {
+		t.Fatal("Cannot lock key:", err)
+	}
+	if _, err = lockedKey.Unlock(wrongPassword); !errors.Is(err, ErrWrongPassphrase) {
+		t.Fatal("Expected ErrWrongPassphrase, got:", err)
+	}
+
+	encHandle, _ := testPGP.Encryption().Recipients(keyRingTestPublic).New()
+	pgpMessage, err := encHandle.Encrypt([]byte(testMessage))
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKeyRing, _ := NewKeyRing(keyTestEC)
+	decHandle, _ := testPGP.Decryption().DecryptionKeys(otherKeyRing).New()
+	if _, err = decHandle.DecryptSessionKey(pgpMessage.KeyPacket); !errors.Is(err, ErrNoDecryptionKey) {
+		t.Fatal("Expected ErrNoDecryptionKey, got:", err)
+	}
+	// A corrupted key packet for the decryption key is not reported as a missing decryption key.
+	corruptedKeyPacket := append([]byte(nil), pgpMessage.KeyPacket...)
+	corruptedKeyPacket[len(corruptedKeyPacket)-1] ^= 0xff
+	decHandle, _ = testPGP.Decryption().DecryptionKeys(keyRingTestPrivate).New()
+	if _, err = decHandle.DecryptSessionKey(corruptedKeyPacket); err == nil || errors.Is(err, ErrNoDecryptionKey) {
+		t.Fatal("Expected an error other than ErrNoDecryptionKey, got:", err)
+	}
+	// Only failures caused by the passphrase are reported as a wrong passphrase.
+	if !isWrongPassphrase(pgpErrors.StructuralError("private key checksum failure")) {
+		t.Fatal("Expected a checksum failure to be caused by the passphrase")
+	}
+	if isWrongPassphrase(pgpErrors.UnsupportedError("unsupported S2K")) || isWrongPassphrase(pgpErrors.ErrDummyPrivateKey("dummy key found")) {
+		t.Fatal("Expected unsupported and dummy keys not to be caused by the passphrase")
+	}
+
+	signTime := time.Now().Unix()
+	signHandle, _ := testPGP.Sign().SigningKeys(keyRingTestPrivate).SignTime(signTime).Detached().New()
+	signature, err := signHandle.Sign([]byte(testMessage), Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The signature is created after the verification time.
+	verifyHandle, _ := testPGP.Verify().VerificationKeys(keyRingTestPublic).VerifyTime(signTime - 3600).New()
+	result, err := verifyHandle.VerifyDetached([]byte(testMessage), signature, Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := result.SignatureError(); !errors.Is(err, ErrSignatureExpired) {
+		t.Fatal("Expected ErrSignatureExpired, got:", err)
+	}
+	var verificationError SignatureVerificationError
+	if !errors.As(result.SignatureError(), &verificationError) {
+		t.Fatal("Expected a SignatureVerificationError, got:", result.SignatureError())
+	}
+}
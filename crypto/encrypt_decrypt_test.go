@@ -2,6 +2,11 @@ package crypto
 
 import (
 	"bytes"
+	"compress/zlib"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -9,8 +14,11 @@ import (
 	"regexp"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
 	openpgp "github.com/ProtonMail/go-crypto/openpgp/v2"
+	"github.com/ProtonMail/gopenpgp/v3/constants"
 	"github.com/ProtonMail/gopenpgp/v3/profile"
 	"github.com/stretchr/testify/assert"
 )
@@ -253,6 +261,55 @@ func TestEncryptDecryptCachedSessionOnDecrypt(t *testing.T) {
 	}
 }
 
+func TestEncryptDecryptRetrievedSessionKeyReencrypt(t *testing.T) {
+	for _, material := range testMaterialForProfiles {
+		t.Run(material.profileName, func(t *testing.T) {
+			sessionKey, err := material.pgp.GenerateSessionKey()
+			if err != nil {
+				t.Fatal(err)
+			}
+			encHandle, _ := material.pgp.Encryption().
+				Recipients(material.keyRingTestPublic).
+				Password(password).
+				SessionKey(sessionKey).
+				New()
+			pgpMessage, err := encHandle.Encrypt([]byte(testMessageString))
+			if err != nil {
+				t.Fatal("Expected no error in encryption, got:", err)
+			}
+			decHandles := map[string]*DecryptionHandleBuilder{
+				"keys":        material.pgp.Decryption().DecryptionKeys(material.keyRingTestPrivate),
+				"password":    material.pgp.Decryption().Password(password),
+				"session key": material.pgp.Decryption().SessionKey(sessionKey),
+			}
+			for name, builder := range decHandles {
+				decHandle, _ := builder.RetrieveSessionKey().New()
+				decResult, err := decHandle.Decrypt(pgpMessage.Bytes(), Bytes)
+				if err != nil {
+					t.Fatalf("Expected no error in decryption with %s, got: %v", name, err)
+				}
+				retrievedSessionKey := decResult.SessionKey()
+				if retrievedSessionKey == nil {
+					t.Fatalf("Expected a session key from decryption with %s", name)
+				}
+				assert.Equal(t, sessionKey.Key, retrievedSessionKey.Key)
+				// Re-encrypt the retrieved session key without decrypting the key packets again.
+				reencryptHandle, _ := material.pgp.Encryption().Recipients(material.keyRingTestPublic).New()
+				keyPackets, err := reencryptHandle.EncryptSessionKey(retrievedSessionKey)
+				if err != nil {
+					t.Fatalf("Expected no error re-encrypting the session key from %s, got: %v", name, err)
+				}
+				decHandle, _ = material.pgp.Decryption().DecryptionKeys(material.keyRingTestPrivate).New()
+				decResult, err = decHandle.Decrypt(append(keyPackets, pgpMessage.DataPacket...), Bytes)
+				if err != nil {
+					t.Fatal("Expected no error in decryption, got:", err)
+				}
+				assert.Equal(t, testMessageString, decResult.String())
+			}
+		})
+	}
+}
+
 func TestSessionEncryptDecryptStream(t *testing.T) {
 	for _, material := range testMaterialForProfiles {
 		t.Run(material.profileName, func(t *testing.T) {
@@ -654,6 +711,65 @@ func TestPasswordEncryptDecryptDetached(t *testing.T) {
 	}
 }
 
+func TestPasswordDecryptPasswordIndex(t *testing.T) {
+	currentPassword := []byte("current recovery phrase")
+	previousPassword := []byte("previous recovery phrase")
+	encHandle, _ := testPGP.Encryption().
+		Passwords([][]byte{currentPassword, previousPassword}).
+		SigningKeys(keyRingTestPrivate).
+		DetachedSignature().
+		New()
+	var message, detachedSignature bytes.Buffer
+	ptWriter, err := encHandle.EncryptingWriter(NewPGPSplitWriterDetachedSignature(&message, &detachedSignature), Bytes)
+	if err != nil {
+		t.Fatal("Expected no error while creating the encrypting writer, got:", err)
+	}
+	if _, err = ptWriter.Write([]byte(testMessage)); err != nil {
+		t.Fatal("Expected no error while writing the plaintext, got:", err)
+	}
+	if err = ptWriter.Close(); err != nil {
+		t.Fatal("Expected no error while closing the encrypting writer, got:", err)
+	}
+
+	decHandle, _ := testPGP.Decryption().
+		Passwords([][]byte{[]byte("wrong"), previousPassword, currentPassword}).
+		VerificationKeys(keyRingTestPublic).
+		New()
+	decResult, err := decHandle.Decrypt(message.Bytes(), Bytes)
+	if err != nil {
+		t.Fatal("Expected no error while decrypting, got:", err)
+	}
+	assert.Equal(t, testMessage, decResult.String())
+	assert.Equal(t, 1, decResult.PasswordIndex())
+
+	decResult, err = decHandle.DecryptDetached(message.Bytes(), detachedSignature.Bytes(), Bytes)
+	if err != nil {
+		t.Fatal("Expected no error while decrypting, got:", err)
+	}
+	if err = decResult.SignatureError(); err != nil {
+		t.Fatal("Expected no signature error, got:", err)
+	}
+	assert.Equal(t, 1, decResult.PasswordIndex())
+
+	// A wrong password seemingly decrypts a v4 key packet now and then, which
+	// DecryptSessionKey cannot detect without the data packet, thus it is left out.
+	decHandle, _ = testPGP.Decryption().Passwords([][]byte{previousPassword, currentPassword}).New()
+	sessionKey, err := decHandle.DecryptSessionKey(message.Bytes())
+	if err != nil {
+		t.Fatal("Expected no error while decrypting the session key, got:", err)
+	}
+	decHandle, _ = testPGP.Decryption().SessionKey(sessionKey).New()
+	decResult, err = decHandle.Decrypt(message.Bytes(), Bytes)
+	if err != nil {
+		t.Fatal("Expected no error while decrypting, got:", err)
+	}
+	assert.Equal(t, -1, decResult.PasswordIndex())
+
+	decHandle, _ = testPGP.Decryption().Passwords([][]byte{[]byte("wrong")}).New()
+	_, err = decHandle.Decrypt(message.Bytes(), Bytes)
+	assert.Error(t, err)
+}
+
 func TestSessionKeyEncryptDecryptDetached(t *testing.T) {
 	for _, material := range testMaterialForProfiles {
 		t.Run(material.profileName, func(t *testing.T) {
@@ -854,6 +970,54 @@ func TestEncryptDecrypt(t *testing.T) {
 	}
 }
 
+func TestEncryptDecryptBase64(t *testing.T) {
+	for _, material := range testMaterialForProfiles {
+		t.Run(material.profileName, func(t *testing.T) {
+			encHandle, _ := material.pgp.Encryption().
+				Recipients(material.keyRingTestPublic).
+				SigningKeys(material.keyRingTestPrivate).
+				New()
+			decHandle, _ := material.pgp.Decryption().
+				DecryptionKeys(material.keyRingTestPrivate).
+				VerificationKeys(material.keyRingTestPublic).
+				New()
+			var ciphertext bytes.Buffer
+			ptWriter, err := encHandle.EncryptingWriter(&ciphertext, Base64)
+			if err != nil {
+				t.Fatal("Cannot create encrypting writer:", err)
+			}
+			if _, err = ptWriter.Write([]byte(testMessage)); err != nil {
+				t.Fatal("Cannot write message:", err)
+			}
+			if err = ptWriter.Close(); err != nil {
+				t.Fatal("Cannot close encrypting writer:", err)
+			}
+			binaryMessage, err := base64.StdEncoding.DecodeString(ciphertext.String())
+			if err != nil {
+				t.Fatal("Expected base64 encoded message, got:", err)
+			}
+			// Line breaks are ignored, and Auto detects the base64 encoding.
+			wrapped := regexp.MustCompile(".{1,64}").ReplaceAllString(ciphertext.String(), "$0\r\n")
+			for _, encoded := range []struct {
+				message  []byte
+				encoding int8
+			}{
+				{ciphertext.Bytes(), Base64},
+				{[]byte(wrapped), Base64},
+				{ciphertext.Bytes(), Auto},
+				{binaryMessage, Auto},
+			} {
+				decrypted, err := decHandle.Decrypt(encoded.message, encoded.encoding)
+				if err != nil {
+					t.Fatal("Cannot decrypt message:", err)
+				}
+				assert.Exactly(t, testMessage, decrypted.String())
+				assert.NoError(t, decrypted.SignatureError())
+			}
+		})
+	}
+}
+
 func TestEncryptDecryptUTF8(t *testing.T) {
 	for _, material := range testMaterialForProfiles {
 		metadata := &LiteralMetadata{
@@ -959,134 +1123,949 @@ func TestEncryptCompressionApplied(t *testing.T) {
 	}
 }
 
-func TestEncryptDecryptPlaintextDetachedArmor(t *testing.T) {
+func TestEncryptCompressionLevelAndThreshold(t *testing.T) {
+	longMessage := bytes.Repeat([]byte(testMessage), 10)
 	for _, material := range testMaterialForProfiles {
 		t.Run(material.profileName, func(t *testing.T) {
-			var ciphertextBuf bytes.Buffer
-			var detachedSignature bytes.Buffer
+			encHandleThreshold, err := material.pgp.Encryption().
+				SessionKey(material.testSessionKey).
+				CompressWith(constants.ZLIBCompression).
+				CompressionLevel(9).
+				CompressionThreshold(len(testMessage) + 1).
+				New()
+			if err != nil {
+				t.Fatal(err)
+			}
 			encHandle, _ := material.pgp.Encryption().
-				Recipients(material.keyRingTestPublic).
-				SigningKeys(material.keyRingTestPrivate).
-				PlainDetachedSignature().
+				SessionKey(material.testSessionKey).
 				New()
 			decHandle, _ := material.pgp.Decryption().
-				DecryptionKeys(material.keyRingTestPrivate).
-				VerificationKeys(material.keyRingTestPublic).
-				PlainDetachedSignature().
+				SessionKey(material.testSessionKey).
 				New()
-			writer := NewPGPSplitWriterDetachedSignature(&ciphertextBuf, &detachedSignature)
-			ctWriter, err := encHandle.EncryptingWriter(writer, Armor)
+
+			// Below the threshold the plaintext is not compressed.
+			belowThreshold, err := encHandleThreshold.Encrypt([]byte(testMessage))
 			if err != nil {
-				t.Fatal("Expected no error while encrypting message, got:", err)
+				t.Fatal(err)
 			}
-			if _, err := ctWriter.Write([]byte(testMessage)); err != nil {
+			uncompressed, err := encHandle.Encrypt([]byte(testMessage))
+			if err != nil {
 				t.Fatal(err)
 			}
-			if err := ctWriter.Close(); err != nil {
+			assert.Equal(t, len(uncompressed.DataPacket), len(belowThreshold.DataPacket))
+
+			// Above the threshold the plaintext is compressed, also if written in small parts.
+			var ciphertext bytes.Buffer
+			ptWriter, err := encHandleThreshold.EncryptingWriter(&ciphertext, Bytes)
+			if err != nil {
 				t.Fatal(err)
 			}
-			decryptionResult, err := decHandle.DecryptDetached(ciphertextBuf.Bytes(), detachedSignature.Bytes(), Armor)
+			for i := 0; i < len(longMessage); i += 5 {
+				end := i + 5
+				if end > len(longMessage) {
+					end = len(longMessage)
+				}
+				if _, err = ptWriter.Write(longMessage[i:end]); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err = ptWriter.Close(); err != nil {
+				t.Fatal(err)
+			}
+			uncompressed, err = encHandle.Encrypt(longMessage)
 			if err != nil {
-				t.Fatal("Expected no error while decrypting message, got:", err)
+				t.Fatal(err)
 			}
-			if !bytes.Equal(decryptionResult.data, []byte(testMessage)) {
-				t.Fatalf("Expected the decrypted data to be %s got %s", string(decryptionResult.data), testMessage)
+			assert.Less(t, ciphertext.Len(), len(uncompressed.DataPacket))
+
+			for _, ct := range [][]byte{belowThreshold.DataPacket, ciphertext.Bytes()} {
+				if _, err = decHandle.Decrypt(ct, Bytes); err != nil {
+					t.Fatal("Expected no error while decrypting, got:", err)
+				}
 			}
-			if err := decryptionResult.SignatureError(); err != nil {
-				t.Fatal("Expected no signature error")
+		})
+	}
+	_, err := testPGP.Encryption().Password(password).CompressionLevel(10).New()
+	assert.Error(t, err)
+	_, err = testPGP.Encryption().Password(password).CompressionThreshold(-1).New()
+	assert.Error(t, err)
+
+	// The handle is validated when the writer is created, not once the threshold is reached.
+	encHandle, err := testPGP.Encryption().
+		Password(password).
+		SigningKeys(keyRingTestPrivate).
+		DetachedSignature().
+		Compress().
+		CompressionThreshold(len(testMessage)).
+		New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = encHandle.EncryptingWriter(&bytes.Buffer{}, Bytes)
+	assert.Error(t, err)
+	expiredKey, err := NewKeyFromArmored(readTestFile("key_expiredKey", false))
+	if err != nil {
+		t.Fatal("Cannot unarmor expired key:", err)
+	}
+	expiredKeyRing, err := NewKeyRing(expiredKey)
+	if err != nil {
+		t.Fatal("Cannot create key ring:", err)
+	}
+	encHandle, err = testPGP.Encryption().
+		Recipients(expiredKeyRing).
+		Compress().
+		CompressionThreshold(len(testMessage)).
+		New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = encHandle.EncryptingWriter(&bytes.Buffer{}, Bytes)
+	assert.Error(t, err)
+}
+
+// testCompressor compresses with the zlib implementation of the standard library.
+type testCompressor struct {
+	algorithm packet.CompressionAlgo
+}
+
+func (c testCompressor) Algorithm() packet.CompressionAlgo {
+	return c.algorithm
+}
+
+func (c testCompressor) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return zlib.NewWriterLevel(w, zlib.BestSpeed)
+}
+
+func TestEncryptCompressor(t *testing.T) {
+	compressible := bytes.Repeat([]byte(testMessage), 1000)
+	incompressible := make([]byte, 3*compressedChunkSize)
+	if _, err := rand.Read(incompressible); err != nil {
+		t.Fatal(err)
+	}
+	compressor := testCompressor{algorithm: packet.CompressionZLIB}
+	for _, material := range testMaterialForProfiles {
+		t.Run(material.profileName, func(t *testing.T) {
+			handles := map[string]*EncryptionHandleBuilder{
+				"recipients": material.pgp.Encryption().
+					Recipients(material.keyRingTestPublic).
+					SigningKeys(material.keyRingTestPrivate),
+				"password": material.pgp.Encryption().Password(password),
+				"session key": material.pgp.Encryption().
+					SessionKey(material.testSessionKey).
+					SigningKeys(material.keyRingTestPrivate),
+				"threshold": material.pgp.Encryption().
+					Recipients(material.keyRingTestPublic).
+					CompressionThreshold(len(testMessage) + 1),
 			}
-			decHandleNotPlaintext, _ := material.pgp.Decryption().
-				DecryptionKeys(material.keyRingTestPrivate).
-				VerificationKeys(material.keyRingTestPublic).
-				New()
-			_, err = decHandleNotPlaintext.DecryptDetached(ciphertextBuf.Bytes(), detachedSignature.Bytes(), Armor)
-			if err == nil {
-				t.Fatal("Expected that decrypting an non encrypted plaintext signature fails")
+			for name, builder := range handles {
+				encHandle, err := builder.CompressWithCompressor(compressor).New()
+				if err != nil {
+					t.Fatal(name, err)
+				}
+				decBuilder := material.pgp.Decryption().VerificationKeys(material.keyRingTestPublic)
+				switch name {
+				case "password":
+					decBuilder.Password(password)
+				case "session key":
+					decBuilder.SessionKey(material.testSessionKey)
+				default:
+					decBuilder.DecryptionKeys(material.keyRingTestPrivate)
+				}
+				decHandle, _ := decBuilder.New()
+				for _, plaintext := range [][]byte{compressible, incompressible, []byte(testMessage)} {
+					pgpMessage, err := encHandle.Encrypt(plaintext)
+					if err != nil {
+						t.Fatal(name, err)
+					}
+					result, err := decHandle.Decrypt(pgpMessage.Bytes(), Bytes)
+					if err != nil {
+						t.Fatal(name, "Expected no error while decrypting, got:", err)
+					}
+					assert.Equal(t, plaintext, result.Bytes(), name)
+					if name != "password" && name != "threshold" {
+						assert.NoError(t, result.SignatureError(), name)
+					}
+					if len(plaintext) == len(compressible) {
+						assert.Less(t, len(pgpMessage.DataPacket), len(compressible)/10, name)
+					}
+				}
 			}
 		})
 	}
+	_, err := testPGP.Encryption().Password(password).CompressWithCompressor(testCompressor{algorithm: 4}).New()
+	assert.Error(t, err)
+	_, err = testPGP.Encryption().Password(password).CompressWithCompressor(nil).New()
+	assert.Error(t, err)
 }
 
-func TestEncryptArmor(t *testing.T) {
+func TestDecryptIntendedRecipientMismatch(t *testing.T) {
 	for _, material := range testMaterialForProfiles {
 		t.Run(material.profileName, func(t *testing.T) {
-			isV6 := material.keyRingTestPublic.GetKeys()[0].isV6()
+			if material.keyWrong == nil {
+				t.Skip("no additional key in test material")
+			}
+			forwardKeyRing, err := NewKeyRing(material.keyWrong)
+			if err != nil {
+				t.Fatal(err)
+			}
 			encHandle, _ := material.pgp.Encryption().
 				Recipients(material.keyRingTestPublic).
 				SigningKeys(material.keyRingTestPrivate).
 				New()
-			pgpMsg, err := encHandle.Encrypt([]byte(testMessageString))
+			pgpMessage, err := encHandle.Encrypt([]byte(testMessage))
 			if err != nil {
-				t.Fatal("Expected no error in encryption, got:", err)
+				t.Fatal("Expected no error while encrypting, got:", err)
 			}
-			armoredData, err := pgpMsg.Armor()
+			// The recipient re-encrypts the signed message to another key.
+			decHandle, _ := material.pgp.Decryption().DecryptionKeys(material.keyRingTestPrivate).New()
+			sessionKey, err := decHandle.DecryptSessionKey(pgpMessage.KeyPacket)
 			if err != nil {
-				t.Fatal("Armoring failed, got:", err)
+				t.Fatal(err)
 			}
-			hasChecksum := containsChecksum(armoredData)
-			if isV6 && hasChecksum {
-				t.Fatalf("V6 messages should not have a checksum")
+			forwarded, err := pgpMessage.AddRecipients(sessionKey, forwardKeyRing)
+			if err != nil {
+				t.Fatal(err)
+			}
+			forwarded, err = forwarded.RemoveRecipients(material.keyRingTestPublic)
+			if err != nil {
+				t.Fatal(err)
 			}
+
+			decHandle, _ = material.pgp.Decryption().
+				DecryptionKeys(forwardKeyRing).
+				VerificationKeys(material.keyRingTestPublic).
+				New()
+			result, err := decHandle.Decrypt(forwarded.Bytes(), Bytes)
+			if err != nil {
+				t.Fatal("Expected no error while decrypting, got:", err)
+			}
+			checkVerificationError(t, result.SignatureError(), constants.SIGNATURE_BAD_INTENDED_RECIPIENT)
+
+			decHandle, _ = material.pgp.Decryption().
+				DecryptionKeys(forwardKeyRing).
+				VerificationKeys(material.keyRingTestPublic).
+				DisableIntendedRecipients().
+				New()
+			result, err = decHandle.Decrypt(forwarded.Bytes(), Bytes)
+			if err != nil {
+				t.Fatal("Expected no error while decrypting, got:", err)
+			}
+			assert.Nil(t, result.SignatureError())
 		})
 	}
 }
 
-func containsChecksum(armored string) bool {
-	re := regexp.MustCompile(`=([A-Za-z0-9+/]{4})\s*-----END PGP MESSAGE-----`)
-	return re.MatchString(armored)
-}
+func TestEncryptDecryptHiddenRecipients(t *testing.T) {
+	for _, material := range testMaterialForProfiles {
+		t.Run(material.profileName, func(t *testing.T) {
+			if material.keyWrong == nil {
+				t.Skip("no additional key in test material")
+			}
+			encHandle, _ := material.pgp.Encryption().
+				HiddenRecipients(material.keyRingTestPublic).
+				New()
+			pgpMessage, err := encHandle.Encrypt([]byte(testMessage))
+			if err != nil {
+				t.Fatal("Expected no error while encrypting, got:", err)
+			}
+			keyIDs, ok := pgpMessage.EncryptionKeyIDs()
+			assert.True(t, ok)
+			for _, keyID := range keyIDs {
+				assert.Exactly(t, uint64(0), keyID)
+			}
 
-func testEncryptDecrypt(
-	t *testing.T,
-	messageBytes []byte,
-	metadata *LiteralMetadata,
-	encHandle PGPEncryption,
-	decHandle PGPDecryption,
-) {
-	expectedMetadata := metadata
-	pgpMessage, err := encHandle.Encrypt(messageBytes)
-	if err != nil {
-		t.Fatal("Expected no error while encrypting with key ring, got:", err)
-	}
-	ciphertextBytes := pgpMessage.Bytes()
-	decryptionResult, err := decHandle.Decrypt(ciphertextBytes, Bytes)
-	if err != nil {
-		t.Fatal("Expected no error while calling decrypt with key ring, got:", err)
-	}
-	if err = decryptionResult.SignatureError(); err != nil {
-		t.Fatal("Expected no signature verification error, got:", err)
-	}
-	if !bytes.Equal(decryptionResult.Bytes(), messageBytes) {
-		t.Fatalf("Expected the decrypted data to be %s got %s", string(decryptionResult.Bytes()), string(messageBytes))
+			// All decryption keys are tried for wildcard key ids.
+			decryptionKeys, err := NewKeyRing(material.keyWrong)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, key := range material.keyRingTestPrivate.GetKeys() {
+				if err = decryptionKeys.AddKey(key); err != nil {
+					t.Fatal(err)
+				}
+			}
+			decHandle, _ := material.pgp.Decryption().DecryptionKeys(decryptionKeys).New()
+			decryptionResult, err := decHandle.Decrypt(pgpMessage.Bytes(), Bytes)
+			if err != nil {
+				t.Fatal("Expected no error while decrypting, got:", err)
+			}
+			assert.Equal(t, testMessage, decryptionResult.String())
+			sessionKey, err := decHandle.DecryptSessionKey(pgpMessage.KeyPacket)
+			if err != nil {
+				t.Fatal("Expected no error while decrypting the session key, got:", err)
+			}
+			assert.NotNil(t, sessionKey)
+		})
 	}
-	decryptedMeta := decryptionResult.Metadata()
-	if expectedMetadata == nil {
-		expectedMetadata = &LiteralMetadata{
-			filename: metadata.Filename(),
-			isUTF8:   metadata.IsUtf8(),
-			ModTime:  metadata.Time(),
+}
+
+// keyRingResolver returns a resolver for the keys of the key ring,
+// which records the resolved key ids.
+func keyRingResolver(keyRing *KeyRing, resolved *[]uint64) KeyResolver {
+	return KeyResolverFunc(func(keyID uint64) (*KeyRing, error) {
+		*resolved = append(*resolved, keyID)
+		found := &KeyRing{}
+		for _, entity := range keyRing.entities {
+			if entityMatchesKeyIDs(entity, []uint64{keyID}) {
+				found.entities = append(found.entities, entity)
+			}
 		}
-	}
-	if !reflect.DeepEqual(expectedMetadata, decryptedMeta) {
-		t.Fatalf("Expected the decrypted metadata to be %v got %v", metadata, decryptedMeta)
+		return found, nil
+	})
+}
+
+func TestDecryptWithKeyResolvers(t *testing.T) {
+	for _, material := range testMaterialForProfiles {
+		t.Run(material.profileName, func(t *testing.T) {
+			encHandle, _ := material.pgp.Encryption().
+				Recipients(material.keyRingTestPublic).
+				SigningKeys(material.keyRingTestPrivate).
+				New()
+			pgpMessage, err := encHandle.Encrypt([]byte(testMessage))
+			if err != nil {
+				t.Fatal("Expected no error while encrypting, got:", err)
+			}
+			keyIDs, _ := pgpMessage.EncryptionKeyIDs()
+
+			var decryptionKeyIDs, verificationKeyIDs []uint64
+			decHandle, err := material.pgp.Decryption().
+				DecryptionKeyResolver(keyRingResolver(material.keyRingTestPrivate, &decryptionKeyIDs)).
+				VerificationKeyResolver(keyRingResolver(material.keyRingTestPublic, &verificationKeyIDs)).
+				New()
+			if err != nil {
+				t.Fatal("Expected no error while creating the decryption handle, got:", err)
+			}
+			decryptionResult, err := decHandle.Decrypt(pgpMessage.Bytes(), Bytes)
+			if err != nil {
+				t.Fatal("Expected no error while decrypting, got:", err)
+			}
+			assert.Equal(t, testMessage, decryptionResult.String())
+			assert.NoError(t, decryptionResult.SignatureError())
+			// The keys of the recipients are resolved.
+			assert.Contains(t, decryptionKeyIDs, keyIDs[0])
+			assert.NotEmpty(t, verificationKeyIDs)
+
+			decryptionKeyIDs = nil
+			sessionKey, err := decHandle.DecryptSessionKey(pgpMessage.KeyPacket)
+			if err != nil {
+				t.Fatal("Expected no error while decrypting the session key, got:", err)
+			}
+			assert.NotNil(t, sessionKey)
+			assert.NotEmpty(t, decryptionKeyIDs)
+
+			// Unknown keys are not resolved.
+			decHandle, _ = material.pgp.Decryption().
+				DecryptionKeyResolver(keyRingResolver(&KeyRing{}, &decryptionKeyIDs)).
+				New()
+			_, err = decHandle.Decrypt(pgpMessage.Bytes(), Bytes)
+			assert.Error(t, err)
+
+			// Resolver errors abort the decryption.
+			failure := errors.New("database unavailable")
+			decHandle, _ = material.pgp.Decryption().
+				DecryptionKeyResolver(KeyResolverFunc(func(keyID uint64) (*KeyRing, error) {
+					return nil, failure
+				})).
+				New()
+			_, err = decHandle.Decrypt(pgpMessage.Bytes(), Bytes)
+			assert.ErrorIs(t, err, failure)
+			decHandle, _ = material.pgp.Decryption().
+				DecryptionKeys(material.keyRingTestPrivate).
+				VerificationKeyResolver(KeyResolverFunc(func(keyID uint64) (*KeyRing, error) {
+					return nil, failure
+				})).
+				New()
+			_, err = decHandle.Decrypt(pgpMessage.Bytes(), Bytes)
+			assert.ErrorIs(t, err, failure)
+		})
 	}
 }
 
-func testEncryptSplitDecryptStream(
-	t *testing.T,
-	messageBytes []byte,
-	metadata *LiteralMetadata, //nolint:unparam
-	encHandle PGPEncryption,
-	decHandle PGPDecryption,
-	multiWriterCreator func(Writer, Writer, Writer) PGPSplitWriter,
-	numberOfSigsToVerify int,
-	encoding int8, //nolint:unparam
-) {
-	messageReader := bytes.NewReader(messageBytes)
-	var keyPackets bytes.Buffer
-	var ciphertextBuf bytes.Buffer
-	var detachedSignature bytes.Buffer
-	expectedMetadata := metadata
+func TestEncryptDecryptRecipientsAndPasswords(t *testing.T) {
+	recoveryPassword := []byte("recovery passphrase")
+	for _, material := range testMaterialForProfiles {
+		t.Run(material.profileName, func(t *testing.T) {
+			encHandle, err := material.pgp.Encryption().
+				Recipients(material.keyRingTestPublic).
+				Passwords([][]byte{password, recoveryPassword}).
+				New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			pgpMessage, err := encHandle.Encrypt([]byte(testMessage))
+			if err != nil {
+				t.Fatal("Expected no error while encrypting, got:", err)
+			}
+			sessionKey, err := material.pgp.GenerateSessionKey()
+			if err != nil {
+				t.Fatal(err)
+			}
+			keyPackets, err := encHandle.EncryptSessionKey(sessionKey)
+			if err != nil {
+				t.Fatal("Expected no error while encrypting the session key, got:", err)
+			}
+
+			decHandles := make([]PGPDecryption, 0, 3)
+			decHandle, _ := material.pgp.Decryption().DecryptionKeys(material.keyRingTestPrivate).New()
+			decHandles = append(decHandles, decHandle)
+			for _, pw := range [][]byte{password, recoveryPassword} {
+				decHandle, _ = material.pgp.Decryption().Password(pw).New()
+				decHandles = append(decHandles, decHandle)
+			}
+			for _, decHandle := range decHandles {
+				decryptionResult, err := decHandle.Decrypt(pgpMessage.Bytes(), Bytes)
+				if err != nil {
+					t.Fatal("Expected no error while decrypting, got:", err)
+				}
+				assert.Equal(t, testMessage, decryptionResult.String())
+				decryptedSessionKey, err := decHandle.DecryptSessionKey(keyPackets)
+				if err != nil {
+					t.Fatal("Expected no error while decrypting the session key, got:", err)
+				}
+				assert.Equal(t, sessionKey.Key, decryptedSessionKey.Key)
+			}
+		})
+	}
+	_, err := testPGP.Encryption().Passwords(nil).New()
+	assert.Error(t, err)
+}
+
+func TestEncryptDecryptPasswordS2K(t *testing.T) {
+	s2kParams := map[string]*S2KParams{
+		"iterated": NewIteratedSaltedS2KParams(constants.S2KMinIterationCount),
+		"argon2":   NewArgon2S2KParams(1024, 1, 1),
+	}
+	for _, material := range testMaterialForProfiles {
+		for name, params := range s2kParams {
+			t.Run(material.profileName+"/"+name, func(t *testing.T) {
+				encHandle, err := material.pgp.Encryption().Password(password).S2K(params).New()
+				if err != nil {
+					t.Fatal(err)
+				}
+				pgpMessage, err := encHandle.Encrypt([]byte(testMessage))
+				if err != nil {
+					t.Fatal("Expected no error while encrypting, got:", err)
+				}
+				// The key packet is the first packet with a one-octet length.
+				keyPacket := pgpMessage.Bytes()
+				s2kTypeOffset := 4
+				if keyPacket[2] == 6 {
+					s2kTypeOffset = 7
+				}
+				assert.Equal(t, byte(params.Mode), keyPacket[s2kTypeOffset])
+
+				decHandle, _ := material.pgp.Decryption().Password(password).New()
+				decryptionResult, err := decHandle.Decrypt(pgpMessage.Bytes(), Bytes)
+				if err != nil {
+					t.Fatal("Expected no error while decrypting, got:", err)
+				}
+				assert.Equal(t, testMessage, decryptionResult.String())
+			})
+		}
+	}
+	_, err := testPGP.Encryption().Password(password).S2K(NewIteratedSaltedS2KParams(1)).New()
+	assert.Error(t, err)
+	_, err = testPGP.Encryption().Password(password).S2K(&S2KParams{}).New()
+	assert.Error(t, err)
+}
+
+func TestSessionKeyEncryptAEADMode(t *testing.T) {
+	for _, material := range testMaterialForProfiles {
+		t.Run(material.profileName, func(t *testing.T) {
+			encHandle, err := material.pgp.Encryption().
+				SessionKey(material.testSessionKey).
+				AEADMode(constants.AEADModeGCM).
+				New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			decHandle, _ := material.pgp.Decryption().
+				SessionKey(material.testSessionKey).
+				New()
+			pgpMessage, err := encHandle.Encrypt([]byte(testMessage))
+			if err != nil {
+				t.Fatal("Expected no error while encrypting, got:", err)
+			}
+			p, err := packet.Read(bytes.NewReader(pgpMessage.DataPacket))
+			if err != nil {
+				t.Fatal(err)
+			}
+			dataPacket, ok := p.(*packet.SymmetricallyEncrypted)
+			if !ok {
+				t.Fatal("Expected a symmetrically encrypted data packet")
+			}
+			assert.Equal(t, 2, dataPacket.Version)
+			assert.Equal(t, packet.AEADModeGCM, dataPacket.Mode)
+			decryptionResult, err := decHandle.Decrypt(pgpMessage.DataPacket, Bytes)
+			if err != nil {
+				t.Fatal("Expected no error while decrypting, got:", err)
+			}
+			assert.Equal(t, testMessage, decryptionResult.String())
+		})
+	}
+	_, err := testPGP.Encryption().Password(password).AEADMode(10).New()
+	assert.Error(t, err)
+}
+
+func TestSessionKeyEncryptAEADChunkSize(t *testing.T) {
+	message := bytes.Repeat([]byte(testMessage), 100)
+	for _, material := range testMaterialForProfiles {
+		t.Run(material.profileName, func(t *testing.T) {
+			encHandle, err := material.pgp.Encryption().
+				SessionKey(material.testSessionKey).
+				AEADChunkSize(constants.AEADMinChunkSize).
+				New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			decHandle, _ := material.pgp.Decryption().
+				SessionKey(material.testSessionKey).
+				New()
+			pgpMessage, err := encHandle.Encrypt(message)
+			if err != nil {
+				t.Fatal("Expected no error while encrypting, got:", err)
+			}
+			p, err := packet.Read(bytes.NewReader(pgpMessage.DataPacket))
+			if err != nil {
+				t.Fatal(err)
+			}
+			dataPacket, ok := p.(*packet.SymmetricallyEncrypted)
+			if !ok {
+				t.Fatal("Expected a symmetrically encrypted data packet")
+			}
+			assert.Equal(t, 2, dataPacket.Version)
+			assert.Equal(t, byte(0), dataPacket.ChunkSizeByte)
+			decryptionResult, err := decHandle.Decrypt(pgpMessage.DataPacket, Bytes)
+			if err != nil {
+				t.Fatal("Expected no error while decrypting, got:", err)
+			}
+			assert.Equal(t, message, decryptionResult.Bytes())
+		})
+	}
+	_, err := testPGP.Encryption().Password(password).AEADChunkSize(100).New()
+	assert.Error(t, err)
+	_, err = testPGP.Encryption().Password(password).AEADChunkSize(constants.AEADMaxChunkSize * 2).New()
+	assert.Error(t, err)
+}
+
+func TestEncryptDecryptEncryptedDetachedArmor(t *testing.T) {
+	for _, material := range testMaterialForProfiles {
+		t.Run(material.profileName, func(t *testing.T) {
+			encHandle, _ := material.pgp.Encryption().
+				Recipients(material.keyRingTestPublic).
+				SigningKeys(material.keyRingTestPrivate).
+				DetachedSignature().
+				New()
+			decHandle, _ := material.pgp.Decryption().
+				DecryptionKeys(material.keyRingTestPrivate).
+				VerificationKeys(material.keyRingTestPublic).
+				New()
+			pgpMessage, err := encHandle.Encrypt([]byte(testMessage))
+			if err != nil {
+				t.Fatal("Expected no error while encrypting message, got:", err)
+			}
+			encryptedSignature := pgpMessage.EncryptedDetachedSignature()
+			if encryptedSignature == nil {
+				t.Fatal("Expected an encrypted detached signature")
+			}
+			armoredMessage, err := pgpMessage.Armor()
+			if err != nil {
+				t.Fatal(err)
+			}
+			armoredSignature, err := encryptedSignature.Armor()
+			if err != nil {
+				t.Fatal(err)
+			}
+			decryptionResult, err := decHandle.DecryptDetached([]byte(armoredMessage), []byte(armoredSignature), Armor)
+			if err != nil {
+				t.Fatal("Expected no error while decrypting message, got:", err)
+			}
+			assert.Equal(t, testMessage, decryptionResult.String())
+			if err := decryptionResult.SignatureError(); err != nil {
+				t.Fatal("Expected no signature error, got:", err)
+			}
+
+			// The detached signature must not verify against a different message.
+			otherMessage, err := encHandle.Encrypt([]byte("other message"))
+			if err != nil {
+				t.Fatal("Expected no error while encrypting message, got:", err)
+			}
+			decryptionResult, err = decHandle.DecryptDetached(otherMessage.Bytes(), encryptedSignature.Bytes(), Bytes)
+			if err != nil {
+				t.Fatal("Expected no error while decrypting message, got:", err)
+			}
+			checkVerificationError(t, decryptionResult.SignatureError(), constants.SIGNATURE_FAILED)
+		})
+	}
+}
+
+func TestEncryptingWriterPartialLengths(t *testing.T) {
+	const chunkSize = 1 << 16
+	const plaintextSize = 1 << 22
+	chunk := bytes.Repeat([]byte{'a'}, chunkSize)
+	for _, material := range testMaterialForProfiles {
+		t.Run(material.profileName, func(t *testing.T) {
+			encHandle, _ := material.pgp.Encryption().
+				Recipients(material.keyRingTestPublic).
+				New()
+			var keyPackets, dataPackets bytes.Buffer
+			ctWriter, err := encHandle.EncryptingWriter(NewPGPSplitWriterKeyAndData(&keyPackets, &dataPackets), Bytes)
+			if err != nil {
+				t.Fatal("Expected no error while encrypting message, got:", err)
+			}
+			for written := 0; written < plaintextSize; written += chunkSize {
+				if _, err := ctWriter.Write(chunk); err != nil {
+					t.Fatal(err)
+				}
+			}
+			// The ciphertext must be written before the writer is closed.
+			assert.Greater(t, dataPackets.Len(), plaintextSize/2)
+			if err := ctWriter.Close(); err != nil {
+				t.Fatal(err)
+			}
+			// Data packet with a partial body length header.
+			header := dataPackets.Bytes()
+			assert.Equal(t, byte(0xc0|18), header[0])
+			assert.True(t, header[1] >= 224 && header[1] < 255)
+
+			decHandle, _ := material.pgp.Decryption().
+				DecryptionKeys(material.keyRingTestPrivate).
+				New()
+			ptReader, err := decHandle.DecryptingReader(io.MultiReader(&keyPackets, &dataPackets), Bytes)
+			if err != nil {
+				t.Fatal("Expected no error while decrypting message, got:", err)
+			}
+			n, err := io.Copy(io.Discard, ptReader)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, int64(plaintextSize), n)
+		})
+	}
+}
+
+// TestEncryptDecryptLargeStream streams a multi-gigabyte message through
+// encryption and decryption without buffering it.
+// Only runs if GOPENPGP_TEST_LARGE_STREAM is set, as it takes several minutes.
+func TestEncryptDecryptLargeStream(t *testing.T) {
+	if os.Getenv("GOPENPGP_TEST_LARGE_STREAM") == "" {
+		t.Skip("set GOPENPGP_TEST_LARGE_STREAM to run")
+	}
+	const plaintextSize = 5 << 30
+	encHandle, _ := testPGP.Encryption().Password(password).New()
+	decHandle, _ := testPGP.Decryption().Password(password).New()
+	chunk := bytes.Repeat([]byte(testMessage), 1<<12)
+
+	pipeReader, pipeWriter := io.Pipe()
+	go func() {
+		ctWriter, err := encHandle.EncryptingWriter(pipeWriter, Bytes)
+		if err != nil {
+			_ = pipeWriter.CloseWithError(err)
+			return
+		}
+		for written := int64(0); written < plaintextSize; {
+			toWrite := chunk
+			if remaining := plaintextSize - written; remaining < int64(len(toWrite)) {
+				toWrite = toWrite[:remaining]
+			}
+			if _, err := ctWriter.Write(toWrite); err != nil {
+				_ = pipeWriter.CloseWithError(err)
+				return
+			}
+			written += int64(len(toWrite))
+		}
+		if err := ctWriter.Close(); err != nil {
+			_ = pipeWriter.CloseWithError(err)
+			return
+		}
+		_ = pipeWriter.Close()
+	}()
+	ptReader, err := decHandle.DecryptingReader(pipeReader, Bytes)
+	if err != nil {
+		t.Fatal("Expected no error while decrypting message, got:", err)
+	}
+	n, err := io.Copy(io.Discard, ptReader)
+	if err != nil {
+		t.Fatal("Expected no error while reading the plaintext, got:", err)
+	}
+	assert.Equal(t, int64(plaintextSize), n)
+}
+
+func TestEstimateEncryptedSize(t *testing.T) {
+	plaintextSizes := []int{0, 1, 1000, 100000}
+	for _, material := range testMaterialForProfiles {
+		t.Run(material.profileName, func(t *testing.T) {
+			keysHandle, _ := material.pgp.Encryption().
+				Recipients(material.keyRingTestPublic).
+				SigningKeys(material.keyRingTestPrivate).
+				New()
+			sessionKey, err := material.pgp.GenerateSessionKey()
+			if err != nil {
+				t.Fatal(err)
+			}
+			sessionKeyHandle, _ := material.pgp.Encryption().
+				SessionKey(sessionKey).
+				Compress().
+				New()
+			detachedHandle, _ := material.pgp.Encryption().
+				Recipients(material.keyRingTestPublic).
+				SigningKeys(material.keyRingTestPrivate).
+				DetachedSignature().
+				New()
+			for i, encHandle := range []PGPEncryption{keysHandle, sessionKeyHandle, detachedHandle} {
+				for _, size := range plaintextSizes {
+					plaintext := make([]byte, size)
+					if _, err := rand.Read(plaintext); err != nil {
+						t.Fatal(err)
+					}
+					for _, encoding := range []int8{Bytes, Armor, Base64} {
+						var message, signature bytes.Buffer
+						ctWriter, err := encHandle.EncryptingWriter(NewPGPSplitWriterDetachedSignature(&message, &signature), encoding)
+						if err != nil {
+							t.Fatal(err)
+						}
+						if _, err = ctWriter.Write(plaintext); err != nil {
+							t.Fatal(err)
+						}
+						if err = ctWriter.Close(); err != nil {
+							t.Fatal(err)
+						}
+						actual := int64(message.Len() + signature.Len())
+						estimate, err := encHandle.EstimateEncryptedSize(int64(size), encoding)
+						if err != nil {
+							t.Fatal("Expected no error while estimating the size, got:", err)
+						}
+						assert.GreaterOrEqual(t, estimate, actual, "handle %d, plaintext size %d", i, size)
+						assert.LessOrEqual(t, estimate, actual+actual/100+256)
+					}
+				}
+			}
+		})
+	}
+	encHandle, _ := testPGP.Encryption().Password(password).New()
+	_, err := encHandle.EstimateEncryptedSize(-1, Bytes)
+	assert.Error(t, err)
+
+	// The estimate is not reported as an encryption.
+	var notified bool
+	encHandle, _ = testPGP.Encryption().
+		Recipients(keyRingTestPublic).
+		Observer(OperationObserverFunc(func(*OperationInfo) { notified = true })).
+		ProgressListener(ProgressFunc(func(int64, int64) { notified = true })).
+		Logger(LoggerFunc(func(*LogEntry) { notified = true })).
+		New()
+	if _, err = encHandle.EstimateEncryptedSize(1000, Bytes); err != nil {
+		t.Fatal("Expected no error while estimating the size, got:", err)
+	}
+	assert.False(t, notified)
+}
+
+func TestEncryptDecryptPlaintextDetachedArmor(t *testing.T) {
+	for _, material := range testMaterialForProfiles {
+		t.Run(material.profileName, func(t *testing.T) {
+			var ciphertextBuf bytes.Buffer
+			var detachedSignature bytes.Buffer
+			encHandle, _ := material.pgp.Encryption().
+				Recipients(material.keyRingTestPublic).
+				SigningKeys(material.keyRingTestPrivate).
+				PlainDetachedSignature().
+				New()
+			decHandle, _ := material.pgp.Decryption().
+				DecryptionKeys(material.keyRingTestPrivate).
+				VerificationKeys(material.keyRingTestPublic).
+				PlainDetachedSignature().
+				New()
+			writer := NewPGPSplitWriterDetachedSignature(&ciphertextBuf, &detachedSignature)
+			ctWriter, err := encHandle.EncryptingWriter(writer, Armor)
+			if err != nil {
+				t.Fatal("Expected no error while encrypting message, got:", err)
+			}
+			if _, err := ctWriter.Write([]byte(testMessage)); err != nil {
+				t.Fatal(err)
+			}
+			if err := ctWriter.Close(); err != nil {
+				t.Fatal(err)
+			}
+			decryptionResult, err := decHandle.DecryptDetached(ciphertextBuf.Bytes(), detachedSignature.Bytes(), Armor)
+			if err != nil {
+				t.Fatal("Expected no error while decrypting message, got:", err)
+			}
+			if !bytes.Equal(decryptionResult.data, []byte(testMessage)) {
+				t.Fatalf("Expected the decrypted data to be %s got %s", string(decryptionResult.data), testMessage)
+			}
+			if err := decryptionResult.SignatureError(); err != nil {
+				t.Fatal("Expected no signature error")
+			}
+			decHandleNotPlaintext, _ := material.pgp.Decryption().
+				DecryptionKeys(material.keyRingTestPrivate).
+				VerificationKeys(material.keyRingTestPublic).
+				New()
+			_, err = decHandleNotPlaintext.DecryptDetached(ciphertextBuf.Bytes(), detachedSignature.Bytes(), Armor)
+			if err == nil {
+				t.Fatal("Expected that decrypting an non encrypted plaintext signature fails")
+			}
+		})
+	}
+}
+
+func TestEncryptArmor(t *testing.T) {
+	for _, material := range testMaterialForProfiles {
+		t.Run(material.profileName, func(t *testing.T) {
+			isV6 := material.keyRingTestPublic.GetKeys()[0].isV6()
+			encHandle, _ := material.pgp.Encryption().
+				Recipients(material.keyRingTestPublic).
+				SigningKeys(material.keyRingTestPrivate).
+				New()
+			pgpMsg, err := encHandle.Encrypt([]byte(testMessageString))
+			if err != nil {
+				t.Fatal("Expected no error in encryption, got:", err)
+			}
+			armoredData, err := pgpMsg.Armor()
+			if err != nil {
+				t.Fatal("Armoring failed, got:", err)
+			}
+			hasChecksum := containsChecksum(armoredData)
+			if isV6 && hasChecksum {
+				t.Fatalf("V6 messages should not have a checksum")
+			}
+		})
+	}
+}
+
+func TestEncryptArmorOptions(t *testing.T) {
+	for _, material := range testMaterialForProfiles {
+		t.Run(material.profileName, func(t *testing.T) {
+			encHandle, err := material.pgp.Encryption().
+				Recipients(material.keyRingTestPublic).
+				SigningKeys(material.keyRingTestPrivate).
+				ArmorHeader("", "test comment").
+				ArmorLineLength(76).
+				OmitArmorChecksum().
+				New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var ciphertext bytes.Buffer
+			ctWriter, err := encHandle.EncryptingWriter(&ciphertext, Armor)
+			if err != nil {
+				t.Fatal("Expected no error in encryption, got:", err)
+			}
+			if _, err = ctWriter.Write(bytes.Repeat([]byte(testMessageString), 100)); err != nil {
+				t.Fatal(err)
+			}
+			if err = ctWriter.Close(); err != nil {
+				t.Fatal(err)
+			}
+			armored := ciphertext.String()
+			assert.Contains(t, armored, "Comment: test comment\n")
+			assert.NotContains(t, armored, "Version:")
+			assert.False(t, containsChecksum(armored))
+			checkArmorLineLength(t, armored, 76)
+
+			estimate, err := encHandle.EstimateEncryptedSize(int64(len(testMessageString)*100), Armor)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.GreaterOrEqual(t, estimate, int64(ciphertext.Len()))
+
+			decHandle, _ := material.pgp.Decryption().
+				DecryptionKeys(material.keyRingTestPrivate).
+				VerificationKeys(material.keyRingTestPublic).
+				New()
+			decryptionResult, err := decHandle.Decrypt(ciphertext.Bytes(), Armor)
+			if err != nil {
+				t.Fatal("Expected no error while decrypting, got:", err)
+			}
+			assert.Equal(t, strings.Repeat(testMessageString, 100), decryptionResult.String())
+			if err = decryptionResult.SignatureError(); err != nil {
+				t.Fatal("Expected no signature error, got:", err)
+			}
+		})
+	}
+	_, err := testPGP.Encryption().Password(password).ArmorLineLength(78).New()
+	assert.Error(t, err)
+	_, err = testPGP.Encryption().Password(password).ArmorLineLength(30).New()
+	assert.Error(t, err)
+}
+
+// checkArmorLineLength checks that the base64 lines of the armored data have the given length,
+// except for the last line.
+func checkArmorLineLength(t *testing.T, armored string, lineLength int) {
+	body := armored[strings.Index(armored, "\n\n")+2:]
+	lines := strings.Split(body, "\n")
+	var bodyLines []string
+	for _, line := range lines {
+		if strings.HasPrefix(line, "=") || strings.HasPrefix(line, "-----END") {
+			break
+		}
+		bodyLines = append(bodyLines, line)
+	}
+	if len(bodyLines) < 2 {
+		t.Fatal("Expected multiple base64 lines in the armored data")
+	}
+	for _, line := range bodyLines[:len(bodyLines)-1] {
+		assert.Len(t, line, lineLength)
+	}
+	assert.LessOrEqual(t, len(bodyLines[len(bodyLines)-1]), lineLength)
+	assert.NotEmpty(t, bodyLines[len(bodyLines)-1])
+}
+
+func containsChecksum(armored string) bool {
+	re := regexp.MustCompile(`=([A-Za-z0-9+/]{4})\s*-----END PGP MESSAGE-----`)
+	return re.MatchString(armored)
+}
+
+func testEncryptDecrypt(
+	t *testing.T,
+	messageBytes []byte,
+	metadata *LiteralMetadata,
+	encHandle PGPEncryption,
+	decHandle PGPDecryption,
+) {
+	expectedMetadata := metadata
+	pgpMessage, err := encHandle.Encrypt(messageBytes)
+	if err != nil {
+		t.Fatal("Expected no error while encrypting with key ring, got:", err)
+	}
+	ciphertextBytes := pgpMessage.Bytes()
+	decryptionResult, err := decHandle.Decrypt(ciphertextBytes, Bytes)
+	if err != nil {
+		t.Fatal("Expected no error while calling decrypt with key ring, got:", err)
+	}
+	if err = decryptionResult.SignatureError(); err != nil {
+		t.Fatal("Expected no signature verification error, got:", err)
+	}
+	if !bytes.Equal(decryptionResult.Bytes(), messageBytes) {
+		t.Fatalf("Expected the decrypted data to be %s got %s", string(decryptionResult.Bytes()), string(messageBytes))
+	}
+	decryptedMeta := decryptionResult.Metadata()
+	if expectedMetadata == nil {
+		expectedMetadata = &LiteralMetadata{
+			filename: metadata.Filename(),
+			isUTF8:   metadata.IsUtf8(),
+			ModTime:  metadata.Time(),
+		}
+	}
+	if !reflect.DeepEqual(expectedMetadata, decryptedMeta) {
+		t.Fatalf("Expected the decrypted metadata to be %v got %v", metadata, decryptedMeta)
+	}
+}
+
+func testEncryptSplitDecryptStream(
+	t *testing.T,
+	messageBytes []byte,
+	metadata *LiteralMetadata, //nolint:unparam
+	encHandle PGPEncryption,
+	decHandle PGPDecryption,
+	multiWriterCreator func(Writer, Writer, Writer) PGPSplitWriter,
+	numberOfSigsToVerify int,
+	encoding int8, //nolint:unparam
+) {
+	messageReader := bytes.NewReader(messageBytes)
+	var keyPackets bytes.Buffer
+	var ciphertextBuf bytes.Buffer
+	var detachedSignature bytes.Buffer
+	expectedMetadata := metadata
 	splitOutput := multiWriterCreator(&keyPackets, &ciphertextBuf, &detachedSignature)
 	messageWriter, err := encHandle.EncryptingWriter(splitOutput, encoding)
 	if err != nil {
@@ -1240,3 +2219,380 @@ func splitWriter(w1 Writer, w2 Writer, w3 Writer) PGPSplitWriter {
 func splitWriterDetachedSignature(w1 Writer, w2 Writer, w3 Writer) PGPSplitWriter {
 	return NewPGPSplitWriter(w1, w2, w3)
 }
+
+func TestDecryptPlaintextLimits(t *testing.T) {
+	sessionKey, err := testPGP.GenerateSessionKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Highly compressible plaintext
+	plaintext := make([]byte, 4*compressionRatioCheckThreshold)
+	encHandle, _ := testPGP.Encryption().SessionKey(sessionKey).Compress().New()
+	pgpMessage, err := encHandle.Encrypt(plaintext)
+	if err != nil {
+		t.Fatal("Expected no error while encrypting, got:", err)
+	}
+
+	decHandle, _ := testPGP.Decryption().SessionKey(sessionKey).MaxPlaintextSize(int64(len(plaintext))).New()
+	decResult, err := decHandle.Decrypt(pgpMessage.Bytes(), Bytes)
+	if err != nil {
+		t.Fatal("Expected no error while decrypting within the size limit, got:", err)
+	}
+	assert.Equal(t, plaintext, decResult.Bytes())
+
+	decHandle, _ = testPGP.Decryption().SessionKey(sessionKey).MaxPlaintextSize(int64(len(plaintext) - 1)).New()
+	_, err = decHandle.Decrypt(pgpMessage.Bytes(), Bytes)
+	assert.Error(t, err)
+	ptReader, err := decHandle.DecryptingReader(bytes.NewReader(pgpMessage.Bytes()), Bytes)
+	if err != nil {
+		t.Fatal("Expected no error while creating the decrypting reader, got:", err)
+	}
+	read, err := io.ReadAll(ptReader)
+	assert.Error(t, err)
+	assert.Len(t, read, len(plaintext)-1)
+
+	decHandle, _ = testPGP.Decryption().SessionKey(sessionKey).MaxCompressionRatio(100).New()
+	_, err = decHandle.Decrypt(pgpMessage.Bytes(), Bytes)
+	assert.Error(t, err)
+
+	// Incompressible plaintext
+	if _, err = rand.Read(plaintext); err != nil {
+		t.Fatal(err)
+	}
+	pgpMessage, err = encHandle.Encrypt(plaintext)
+	if err != nil {
+		t.Fatal("Expected no error while encrypting, got:", err)
+	}
+	decResult, err = decHandle.Decrypt(pgpMessage.Bytes(), Bytes)
+	if err != nil {
+		t.Fatal("Expected no error while decrypting within the compression ratio limit, got:", err)
+	}
+	assert.Equal(t, plaintext, decResult.Bytes())
+
+	_, err = testPGP.Decryption().SessionKey(sessionKey).MaxPlaintextSize(-1).New()
+	assert.Error(t, err)
+}
+
+func TestDecryptMaxBufferSize(t *testing.T) {
+	pgp := PGPWithProfile(profile.RFC9580())
+	sessionKey, err := pgp.GenerateSessionKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	plaintext := make([]byte, 100000)
+	if _, err = rand.Read(plaintext); err != nil {
+		t.Fatal(err)
+	}
+	chunkSize := 4096
+	encHandle, _ := pgp.Encryption().Password(testSymmetricKey).SessionKey(sessionKey).AEADChunkSize(chunkSize).New()
+	pgpMessage, err := encHandle.Encrypt(plaintext)
+	if err != nil {
+		t.Fatal("Expected no error while encrypting, got:", err)
+	}
+
+	decHandle, _ := pgp.Decryption().Password(testSymmetricKey).MaxBufferSize(int64(2 * chunkSize)).New()
+	ptReader, err := decHandle.DecryptingReader(bytes.NewReader(pgpMessage.Bytes()), Bytes)
+	if err != nil {
+		t.Fatal("Expected no error while decrypting within the buffer bound, got:", err)
+	}
+	decrypted, err := io.ReadAll(ptReader)
+	if err != nil {
+		t.Fatal("Expected no error while reading the plaintext, got:", err)
+	}
+	assert.Equal(t, plaintext, decrypted)
+
+	decHandle, _ = pgp.Decryption().Password(testSymmetricKey).MaxBufferSize(int64(chunkSize)).New()
+	_, err = decHandle.DecryptingReader(bytes.NewReader(pgpMessage.Bytes()), Bytes)
+	assert.Error(t, err)
+
+	// The parallelism is reduced to fit into the buffer bound.
+	decHandle, _ = pgp.Decryption().SessionKey(sessionKey).DecryptionParallelism(8).MaxBufferSize(int64(4 * chunkSize)).New()
+	decResult, err := decHandle.Decrypt(pgpMessage.DataPacket, Bytes)
+	if err != nil {
+		t.Fatal("Expected no error while decrypting in parallel within the buffer bound, got:", err)
+	}
+	assert.Equal(t, plaintext, decResult.Bytes())
+
+	_, err = pgp.Decryption().SessionKey(sessionKey).MaxBufferSize(-1).New()
+	assert.Error(t, err)
+}
+
+func TestEncryptDecryptProgressListener(t *testing.T) {
+	plaintext := make([]byte, 100000)
+	if _, err := rand.Read(plaintext); err != nil {
+		t.Fatal(err)
+	}
+	for _, material := range testMaterialForProfiles {
+		t.Run(material.profileName, func(t *testing.T) {
+			var lastIn, lastOut int64
+			listener := ProgressFunc(func(bytesIn, bytesOut int64) {
+				if bytesIn < lastIn || bytesOut < lastOut {
+					t.Fatal("Expected the progress to be monotonic")
+				}
+				lastIn, lastOut = bytesIn, bytesOut
+			})
+			encHandle, _ := material.pgp.Encryption().
+				Recipients(material.keyRingTestPublic).
+				SigningKeys(material.keyRingTestPrivate).
+				ProgressListener(listener).
+				New()
+			var ciphertext bytes.Buffer
+			ptWriter, err := encHandle.EncryptingWriter(&ciphertext, Armor)
+			if err != nil {
+				t.Fatal("Expected no error while creating the encrypting writer, got:", err)
+			}
+			for offset := 0; offset < len(plaintext); offset += 1000 {
+				if _, err = ptWriter.Write(plaintext[offset : offset+1000]); err != nil {
+					t.Fatal("Expected no error while writing the plaintext, got:", err)
+				}
+			}
+			if err = ptWriter.Close(); err != nil {
+				t.Fatal("Expected no error while closing the encrypting writer, got:", err)
+			}
+			assert.Equal(t, int64(len(plaintext)), lastIn)
+			assert.Equal(t, int64(ciphertext.Len()), lastOut)
+
+			lastIn, lastOut = 0, 0
+			pgpMessage, err := encHandle.Encrypt(plaintext)
+			if err != nil {
+				t.Fatal("Expected no error while encrypting, got:", err)
+			}
+			assert.Equal(t, int64(len(plaintext)), lastIn)
+			assert.Equal(t, int64(len(pgpMessage.Bytes())), lastOut)
+
+			lastIn, lastOut = 0, 0
+			decHandle, _ := material.pgp.Decryption().
+				DecryptionKeys(material.keyRingTestPrivate).
+				VerificationKeys(material.keyRingTestPublic).
+				ProgressListener(listener).
+				New()
+			decResult, err := decHandle.Decrypt(ciphertext.Bytes(), Armor)
+			if err != nil {
+				t.Fatal("Expected no error while decrypting, got:", err)
+			}
+			if err = decResult.SignatureError(); err != nil {
+				t.Fatal("Expected no signature error, got:", err)
+			}
+			assert.Equal(t, plaintext, decResult.Bytes())
+			assert.Equal(t, int64(ciphertext.Len()), lastIn)
+			assert.Equal(t, int64(len(plaintext)), lastOut)
+		})
+	}
+}
+
+// blockingReadWriter blocks on every read and write until unblock is closed.
+type blockingReadWriter struct {
+	unblock chan struct{}
+}
+
+func (b *blockingReadWriter) Read([]byte) (int, error) {
+	<-b.unblock
+	return 0, io.EOF
+}
+
+func (b *blockingReadWriter) Write(p []byte) (int, error) {
+	<-b.unblock
+	return len(p), nil
+}
+
+func TestEncryptDecryptContext(t *testing.T) {
+	encHandle, _ := testPGP.Encryption().Recipients(keyRingTestPublic).New()
+	decHandle, _ := testPGP.Decryption().DecryptionKeys(keyRingTestPrivate).New()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var ciphertext bytes.Buffer
+	ptWriter, err := encHandle.EncryptingWriterContext(ctx, &ciphertext, Armor)
+	if err != nil {
+		t.Fatal("Expected no error while creating the encrypting writer, got:", err)
+	}
+	if _, err = ptWriter.Write([]byte(testMessage)); err != nil {
+		t.Fatal("Expected no error while writing the plaintext, got:", err)
+	}
+	if err = ptWriter.Close(); err != nil {
+		t.Fatal("Expected no error while closing the encrypting writer, got:", err)
+	}
+	ptReader, err := decHandle.DecryptingReaderContext(ctx, bytes.NewReader(ciphertext.Bytes()), Armor)
+	if err != nil {
+		t.Fatal("Expected no error while creating the decrypting reader, got:", err)
+	}
+	firstByte := make([]byte, 1)
+	if _, err = ptReader.Read(firstByte); err != nil {
+		t.Fatal("Expected no error while reading the plaintext, got:", err)
+	}
+	cancel()
+	if _, err = ptReader.Read(firstByte); !errors.Is(err, context.Canceled) {
+		t.Fatal("Expected a context error after cancellation, got:", err)
+	}
+	if _, err = encHandle.EncryptingWriterContext(ctx, &ciphertext, Armor); !errors.Is(err, context.Canceled) {
+		t.Fatal("Expected a context error for a cancelled context, got:", err)
+	}
+
+	blocking := &blockingReadWriter{unblock: make(chan struct{})}
+	defer close(blocking.unblock)
+	timeoutCtx, timeoutCancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer timeoutCancel()
+	if _, err = decHandle.DecryptingReaderContext(timeoutCtx, blocking, Bytes); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatal("Expected a deadline error for a blocking reader, got:", err)
+	}
+	ptWriter, err = encHandle.EncryptingWriterContext(timeoutCtx, blocking, Bytes)
+	if err == nil {
+		_, err = ptWriter.Write([]byte(testMessage))
+	}
+	if err == nil {
+		err = ptWriter.Close()
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatal("Expected a deadline error for a blocking writer, got:", err)
+	}
+}
+
+func TestEncryptDecryptWithPassword(t *testing.T) {
+	ciphertext, err := EncryptWithPassword(password, []byte(testMessage))
+	if err != nil {
+		t.Fatal("Expected no error while encrypting, got:", err)
+	}
+	packets, err := NewPGPMessage(ciphertext).InspectPackets()
+	if err != nil {
+		t.Fatal("Expected no error while inspecting, got:", err)
+	}
+	if len(packets) != 2 {
+		t.Fatalf("Expected two packets, got %d", len(packets))
+	}
+	assert.Equal(t, 3, packets[0].Tag)
+	assert.Equal(t, 6, packets[0].Version)
+	assert.Equal(t, byte(constants.S2KArgon2), ciphertext[7])
+	assert.Equal(t, 18, packets[1].Tag)
+	assert.Equal(t, 2, packets[1].Version)
+
+	decrypted, err := DecryptWithPassword(password, ciphertext)
+	if err != nil {
+		t.Fatal("Expected no error while decrypting, got:", err)
+	}
+	assert.Equal(t, testMessage, string(decrypted))
+
+	armored, err := TranscodeToArmor(ciphertext)
+	if err != nil {
+		t.Fatal("Expected no error while armoring, got:", err)
+	}
+	decrypted, err = DecryptWithPassword(password, []byte(armored))
+	if err != nil {
+		t.Fatal("Expected no error while decrypting armored message, got:", err)
+	}
+	assert.Equal(t, testMessage, string(decrypted))
+
+	_, err = DecryptWithPassword([]byte("wrongPassword"), ciphertext)
+	assert.Error(t, err)
+	_, err = EncryptWithPassword(nil, []byte(testMessage))
+	assert.Error(t, err)
+	_, err = DecryptWithPassword(nil, ciphertext)
+	assert.Error(t, err)
+
+	// Messages of other profiles are decrypted as well.
+	encHandle, _ := PGPWithProfile(profile.RFC4880()).Encryption().Password(password).New()
+	pgpMessage, err := encHandle.Encrypt([]byte(testMessage))
+	if err != nil {
+		t.Fatal("Expected no error while encrypting, got:", err)
+	}
+	decrypted, err = DecryptWithPassword(password, pgpMessage.Bytes())
+	if err != nil {
+		t.Fatal("Expected no error while decrypting, got:", err)
+	}
+	assert.Equal(t, testMessage, string(decrypted))
+}
+
+func TestEncryptDecryptStreamWithPassword(t *testing.T) {
+	plaintext := bytes.Repeat([]byte(testMessage), 1000)
+	var ciphertext bytes.Buffer
+	encWriter, err := EncryptStreamWithPassword(password, &ciphertext)
+	if err != nil {
+		t.Fatal("Expected no error while creating the encrypting writer, got:", err)
+	}
+	if _, err := io.Copy(encWriter, bytes.NewReader(plaintext)); err != nil {
+		t.Fatal("Expected no error while encrypting, got:", err)
+	}
+	if err := encWriter.Close(); err != nil {
+		t.Fatal("Expected no error while closing the encrypting writer, got:", err)
+	}
+
+	decReader, err := DecryptStreamWithPassword(password, bytes.NewReader(ciphertext.Bytes()))
+	if err != nil {
+		t.Fatal("Expected no error while creating the decrypting reader, got:", err)
+	}
+	decrypted, err := decReader.ReadAll()
+	if err != nil {
+		t.Fatal("Expected no error while decrypting, got:", err)
+	}
+	assert.Equal(t, plaintext, decrypted)
+
+	// Tampering is detected while reading.
+	tampered := ciphertext.Bytes()
+	tampered[len(tampered)-20] ^= 1
+	decReader, err = DecryptStreamWithPassword(password, bytes.NewReader(tampered))
+	if err == nil {
+		_, err = decReader.ReadAll()
+	}
+	assert.Error(t, err)
+}
+
+func TestStrictRFC9580Profile(t *testing.T) {
+	strictPGP := PGPWithProfile(profile.StrictRFC9580())
+	key, err := strictPGP.KeyGeneration().AddUserId("test", "test@example.com").New().GenerateKey()
+	if err != nil {
+		t.Fatal("Cannot generate key:", err)
+	}
+	assert.Equal(t, 6, key.GetVersion())
+	_, err = strictPGP.KeyGeneration().
+		AddUserId("test", "test@example.com").
+		OverrideProfileAlgorithm(KeyGenerationCurve25519Legacy).
+		New().
+		GenerateKey()
+	assert.Error(t, err, "v4 keys are refused")
+
+	keyRing, _ := NewKeyRing(key)
+	encHandle, err := strictPGP.Encryption().Recipients(keyRing).SigningKeys(keyRing).New()
+	if err != nil {
+		t.Fatal("Cannot create encryption handle:", err)
+	}
+	pgpMessage, err := encHandle.Encrypt([]byte(testMessage))
+	if err != nil {
+		t.Fatal("Expected no error while encrypting, got:", err)
+	}
+	decHandle, _ := strictPGP.Decryption().DecryptionKeys(keyRing).VerificationKeys(keyRing).New()
+	decrypted, err := decHandle.Decrypt(pgpMessage.Bytes(), Bytes)
+	if err != nil {
+		t.Fatal("Expected no error while decrypting, got:", err)
+	}
+	assert.NoError(t, decrypted.SignatureError())
+	assert.NoError(t, decrypted.LegacyWarning())
+
+	_, err = strictPGP.Encryption().Recipients(keyRingTestPublic).New()
+	assert.Error(t, err, "recipients without SEIPDv2 support are refused")
+	_, err = strictPGP.Encryption().Recipients(keyRing).SigningKeys(keyRingTestPrivate).New()
+	assert.Error(t, err, "v4 signatures are refused")
+	_, err = strictPGP.Sign().SigningKeys(keyRingTestPrivate).New()
+	assert.Error(t, err, "v4 signatures are refused")
+
+	legacyHandle, _ := testPGP.Encryption().Recipients(keyRingTestPublic).SigningKeys(keyRingTestPrivate).New()
+	legacyMessage, err := legacyHandle.Encrypt([]byte(testMessage))
+	if err != nil {
+		t.Fatal("Expected no error while encrypting, got:", err)
+	}
+	decHandle, _ = strictPGP.Decryption().DecryptionKeys(keyRingTestPrivate).VerificationKeys(keyRingTestPublic).New()
+	decrypted, err = decHandle.Decrypt(legacyMessage.Bytes(), Bytes)
+	if err != nil {
+		t.Fatal("Expected no error while decrypting, got:", err)
+	}
+	assert.Exactly(t, testMessage, decrypted.String())
+	assert.EqualError(
+		t,
+		decrypted.LegacyWarning(),
+		"gopenpgp: message contains legacy constructs: v3 public-key encrypted session key, SEIPDv1 data, v4 signature",
+	)
+	decHandle, _ = testPGP.Decryption().DecryptionKeys(keyRingTestPrivate).New()
+	decrypted, err = decHandle.Decrypt(legacyMessage.Bytes(), Bytes)
+	if err != nil {
+		t.Fatal("Expected no error while decrypting, got:", err)
+	}
+	assert.NoError(t, decrypted.LegacyWarning(), "legacy constructs are only reported with a strict profile")
+}
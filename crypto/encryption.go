@@ -1,6 +1,10 @@
 package crypto
 
-import "github.com/ProtonMail/go-crypto/openpgp/packet"
+import (
+	"context"
+
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+)
 
 type EncryptionProfile interface {
 	EncryptionConfig() *packet.Config
@@ -15,14 +19,27 @@ type PGPEncryption interface {
 	// If the output Writer is of type PGPSplitWriter, the output can be split to multiple writers
 	// for different parts of the message. For example to write key packets and encrypted data packets
 	// to different writers or to write a detached signature separately.
-	// The encoding argument defines the output encoding, i.e., Bytes or Armored
+	// The encoding argument defines the output encoding, i.e., Bytes, Armor, or Base64
 	// The returned pgp message WriteCloser must be closed after the plaintext has been written.
+	// The plaintext is not buffered, it is written with partial length packets,
+	// such that its size does not need to be known in advance.
 	EncryptingWriter(output Writer, encoding int8) (WriteCloser, error)
+	// EncryptingWriterContext is like EncryptingWriter but aborts the encryption once ctx is done.
+	// Writes to the returned WriteCloser and to the output then fail with ctx.Err(),
+	// even if a write to the output blocks.
+	// Not supported on go-mobile clients.
+	EncryptingWriterContext(ctx context.Context, output Writer, encoding int8) (WriteCloser, error)
 	// Encrypt encrypts a plaintext message.
 	Encrypt(message []byte) (*PGPMessage, error)
 	// EncryptSessionKey encrypts a session key with the encryption handle.
-	// To encrypt a session key, the handle must contain either recipients or a password.
+	// To encrypt a session key, the handle must contain recipients, passwords, or both.
 	EncryptSessionKey(sessionKey *SessionKey) ([]byte, error)
+	// EstimateEncryptedSize returns an upper bound for the size in bytes of the pgp message
+	// that results from encrypting a plaintext of plaintextLen bytes, without encrypting the plaintext.
+	// The estimate includes key packets, signatures, packet overhead, and the armor expansion
+	// if the encoding is Armor. It can be used for quota checks or to allocate buffers.
+	// If the handle has signing keys, the estimate creates a signature with each of them.
+	EstimateEncryptedSize(plaintextLen int64, encoding int8) (int64, error)
 	// ClearPrivateParams clears all private key material contained in EncryptionHandle from memory.
 	ClearPrivateParams()
 }
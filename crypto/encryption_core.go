@@ -1,6 +1,7 @@
 package crypto
 
 import (
+	"bytes"
 	"io"
 	"time"
 
@@ -82,6 +83,56 @@ func (w *signAndEncryptWriteCloser) Close() error {
 	return w.encryptWriter.Close()
 }
 
+// compressionThresholdWriter buffers the plaintext in a pooled buffer until the compression threshold
+// is reached and opens the encrypting writer with compression only if the threshold is reached.
+type compressionThresholdWriter struct {
+	threshold int
+	open      func(compress bool) (WriteCloser, error)
+	buffer    *bytes.Buffer
+	writer    WriteCloser
+	err       error
+}
+
+func (w *compressionThresholdWriter) Write(b []byte) (int, error) {
+	if w.writer != nil {
+		return w.writer.Write(b)
+	}
+	if w.err != nil {
+		return 0, w.err
+	}
+	w.buffer.Write(b)
+	if w.buffer.Len() >= w.threshold {
+		if err := w.flush(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+func (w *compressionThresholdWriter) Close() error {
+	if w.writer == nil {
+		if err := w.flush(false); err != nil {
+			return err
+		}
+	}
+	return w.writer.Close()
+}
+
+func (w *compressionThresholdWriter) flush(compress bool) (err error) {
+	if w.err != nil {
+		return w.err
+	}
+	buffer := w.buffer
+	w.buffer = nil
+	defer internal.PutSensitiveBuffer(buffer)
+	w.writer, w.err = w.open(compress)
+	if w.err != nil {
+		return w.err
+	}
+	_, err = w.writer.Write(buffer.Bytes())
+	return err
+}
+
 func (eh *encryptionHandle) prepareEncryptAndSign(
 	plainMessageMetadata *LiteralMetadata,
 ) (hints *openpgp.FileHints, config *packet.Config, signEntities []*openpgp.Entity, err error) {
@@ -91,7 +142,7 @@ func (eh *encryptionHandle) prepareEncryptAndSign(
 		ModTime:  time.Unix(plainMessageMetadata.Time(), 0),
 	}
 
-	config = eh.profile.EncryptionConfig()
+	config = eh.encryptionConfig()
 	config.Time = eh.clock
 
 	compressionConfig := eh.selectCompression()
@@ -101,6 +152,7 @@ func (eh *encryptionHandle) prepareEncryptAndSign(
 	if eh.SigningContext != nil {
 		config.SignatureNotations = append(config.SignatureNotations, eh.SigningContext.getNotation())
 	}
+	appendNotations(config, eh.SigningNotations)
 
 	if eh.SignKeyRing != nil && len(eh.SignKeyRing.entities) > 0 {
 		signEntities, err = eh.SignKeyRing.signingEntities()
@@ -122,7 +174,7 @@ func (eh *encryptionHandle) encryptStream(
 		sessionKeyBytes = eh.SessionKey.Key
 	}
 	if eh.Password != nil {
-		additionalPasswords = [][]byte{eh.Password}
+		additionalPasswords = eh.passwords()
 	}
 	hints, config, signers, err := eh.prepareEncryptAndSign(plainMessageMetadata)
 	if err != nil {
@@ -135,8 +187,8 @@ func (eh *encryptionHandle) encryptStream(
 	}
 	plainMessageWriter, err = openpgp.EncryptWithParams(
 		dataPacketWriter,
-		eh.Recipients.getEntities(),
-		eh.HiddenRecipients.getEntities(),
+		eh.Recipients.isolatedEntities(),
+		eh.HiddenRecipients.isolatedEntities(),
 		&openpgp.EncryptParams{
 			KeyWriter:      keyPacketWriter,
 			Signers:        signers,
@@ -176,6 +228,7 @@ func (eh *encryptionHandle) encryptStreamWithPassword(
 			Signers:    signers,
 			Hints:      hints,
 			SessionKey: sessionKeyBytes,
+			Passwords:  eh.AdditionalPasswords,
 			Config:     config,
 			TextSig:    eh.IsUTF8,
 			OutsideSig: eh.ExternalSignature,
@@ -236,7 +289,12 @@ func (eh *encryptionHandle) encryptStreamWithSessionKeyHelper(
 		return nil, nil, errors.Wrap(err, "gopenpgp: unable to encrypt")
 	}
 
-	if algo := config.Compression(); algo != packet.CompressionNone {
+	if eh.Compressor != nil {
+		encryptWriter, err = serializeCompressed(encryptWriter, eh.Compressor)
+		if err != nil {
+			return nil, nil, err
+		}
+	} else if algo := config.Compression(); algo != packet.CompressionNone {
 		encryptWriter, err = packet.SerializeCompressed(encryptWriter, algo, config.CompressionConfig)
 		if err != nil {
 			return nil, nil, errors.Wrap(err, "gopenpgp: error in compression")
@@ -318,13 +376,15 @@ func (eh *encryptionHandle) encryptSignDetachedStreamWithSessionKey(
 		sigToCiphertextWriter = internal.NewNoOpWriteCloser(encryptedSignatureWriter)
 	}
 	// Create a writer to sign the message.
+	signConfig := eh.profile.EncryptionConfig()
+	appendNotations(signConfig, eh.SigningNotations)
 	ptToEncSigWriter, err := signMessageDetachedWriter(
 		signKeyRing,
 		sigToCiphertextWriter,
 		eh.IsUTF8,
 		eh.SigningContext,
 		eh.clock,
-		eh.profile.EncryptionConfig(),
+		signConfig,
 	)
 	if err != nil {
 		return nil, err
@@ -345,23 +405,62 @@ func (eh *encryptionHandle) encryptSignDetachedStreamToRecipients(
 	keyPacketWriter io.Writer,
 	encryptSignature bool,
 ) (plaintextWriter io.WriteCloser, err error) {
-	configInput := eh.profile.EncryptionConfig()
+	if keyPacketWriter == nil {
+		// If no separate keyPacketWriter is given, write the key packets
+		// as prefix to the encrypted data and encrypted signature.
+		keyPacketWriter = io.MultiWriter(encryptedDataWriter, encryptedSignatureWriter)
+	}
+	clearSessionKey, err := eh.encryptSessionKeyToRecipients(keyPacketWriter)
+	if err != nil {
+		return nil, err
+	}
+	defer clearSessionKey()
+
+	// Use the session key to encrypt message + signature of the message.
+	plaintextWriter, err = eh.encryptSignDetachedStreamWithSessionKey(
+		plainMessageMetadata,
+		encryptedSignatureWriter,
+		encryptedDataWriter,
+		encryptSignature,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return plaintextWriter, err
+}
+
+// encryptStreamToRecipientsWithSessionKey writes the key packets of a session key and encrypts
+// the message with it, instead of go-crypto, which cannot compress with a Compressor.
+func (eh *encryptionHandle) encryptStreamToRecipientsWithSessionKey(
+	keyPacketWriter Writer,
+	dataPacketWriter Writer,
+	plainMessageMetadata *LiteralMetadata,
+) (plainMessageWriter WriteCloser, err error) {
+	clearSessionKey, err := eh.encryptSessionKeyToRecipients(keyPacketWriter)
+	if err != nil {
+		return nil, err
+	}
+	defer clearSessionKey()
+	return eh.encryptStreamWithSessionKey(dataPacketWriter, plainMessageMetadata)
+}
+
+// encryptSessionKeyToRecipients writes the key packets of the session key of the handle
+// for the recipients and passwords, where a session key is generated if the handle has none.
+// The returned function clears the generated session key once the message writers are created.
+func (eh *encryptionHandle) encryptSessionKeyToRecipients(keyPacketWriter io.Writer) (clearSessionKey func(), err error) {
+	configInput := eh.encryptionConfig()
 	configInput.Time = NewConstantClock(eh.clock().Unix())
+	clearSessionKey = func() {}
 	// Generate a session key for encryption.
 	if eh.SessionKey == nil {
 		eh.SessionKey, err = generateSessionKey(configInput)
 		if err != nil {
 			return nil, err
 		}
-		defer func() {
+		clearSessionKey = func() {
 			eh.SessionKey.Clear()
 			eh.SessionKey = nil
-		}()
-	}
-	if keyPacketWriter == nil {
-		// If no separate keyPacketWriter is given, write the key packets
-		// as prefix to the encrypted data and encrypted signature.
-		keyPacketWriter = io.MultiWriter(encryptedDataWriter, encryptedSignatureWriter)
+		}
 	}
 
 	encryptionTimeOverride := configInput.Now()
@@ -378,35 +477,25 @@ func (eh *encryptionHandle) encryptSignDetachedStreamToRecipients(
 			encryptionTimeOverride,
 			configInput,
 		); err != nil {
+			clearSessionKey()
 			return nil, err
 		}
 	}
-	if eh.Password != nil {
-		// If not recipients present use the provided password
-		if err = encryptSessionKeyWithPasswordToWriter(
-			eh.Password,
-			eh.SessionKey,
-			keyPacketWriter,
-			configInput,
-		); err != nil {
-			return nil, err
-		}
+	// Add a key packet for each password
+	if err = encryptSessionKeyWithPasswordsToWriter(
+		eh.passwords(),
+		eh.SessionKey,
+		keyPacketWriter,
+		configInput,
+	); err != nil {
+		clearSessionKey()
+		return nil, err
 	}
 	if eh.Password == nil && eh.Recipients == nil && eh.HiddenRecipients == nil {
+		clearSessionKey()
 		return nil, errors.New("openpgp: no key material to encrypt")
 	}
-
-	// Use the session key to encrypt message + signature of the message.
-	plaintextWriter, err = eh.encryptSignDetachedStreamWithSessionKey(
-		plainMessageMetadata,
-		encryptedSignatureWriter,
-		encryptedDataWriter,
-		encryptSignature,
-	)
-	if err != nil {
-		return nil, err
-	}
-	return plaintextWriter, err
+	return clearSessionKey, nil
 }
 
 func (eh *encryptionHandle) selectCompression() (config *packet.Config) {
@@ -425,5 +514,10 @@ func (eh *encryptionHandle) selectCompression() (config *packet.Config) {
 			Level: 6,
 		}
 	}
+	if eh.CompressionLevel != 0 && config.DefaultCompressionAlgo != packet.CompressionNone {
+		config.CompressionConfig = &packet.CompressionConfig{
+			Level: eh.CompressionLevel,
+		}
+	}
 	return config
 }
@@ -1,10 +1,14 @@
 package crypto
 
 import (
+	"bytes"
+	"context"
 	"io"
+	"strconv"
 
-	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
 	openpgp "github.com/ProtonMail/go-crypto/openpgp/v2"
+	armorHelper "github.com/ProtonMail/gopenpgp/v3/armor"
 	"github.com/ProtonMail/gopenpgp/v3/constants"
 	"github.com/ProtonMail/gopenpgp/v3/internal"
 	"github.com/pkg/errors"
@@ -35,19 +39,52 @@ type encryptionHandle struct {
 	// Triggers password based encryption with a key derived from the password.
 	// If nil, set another field for the type of encryption: Recipients, HiddenRecipients, or SessionKey
 	Password []byte
+	// AdditionalPasswords defines further passwords the message should be encrypted with.
+	// For each password a separate key packet is added to the message.
+	// Only considered if Password is set.
+	AdditionalPasswords [][]byte
+	// S2KParams defines how the key encryption keys are derived from the passwords
+	// instead of the S2K settings of the profile.
+	// Only considered if Password is set.
+	S2KParams *S2KParams
 	// SignKeyRing provides an unlocked key ring to include signature in the message.
 	// If nil, no signature is included.
 	SignKeyRing *KeyRing
 	// SigningContext provides a signing context for the signature in the message.
 	// SignKeyRing has to be set if a SigningContext is provided.
 	SigningContext *SigningContext
+	// SigningNotations provides notations for the signature in the message.
+	// SignKeyRing has to be set if SigningNotations are provided.
+	SigningNotations []*Notation
 	// ArmorHeaders provides armor headers if the message is armored.
 	// Only considered if Armored is set to true.
 	ArmorHeaders map[string]string
+	// ArmorLineLength defines the number of base64 characters per armored line.
+	// If zero, the default line length of 64 characters is used.
+	ArmorLineLength int
+	// OmitArmorChecksum indicates that no CRC24 checksum is appended to the armored message.
+	// If not set, the checksum is only omitted if all recipients support RFC9580.
+	OmitArmorChecksum bool
 	// Compression indicates if the plaintext should be compressed before encryption.
 	// constants.NoCompression: none, constants.DefaultCompression: profile default
 	// constants.ZIPCompression: zip, constants.ZLIBCompression: zlib
 	Compression int8
+	// CompressionLevel defines the compression level from 1 (fastest) to 9 (best compression).
+	// Only considered if Compression is set. If zero, the level of the selected compression is used.
+	CompressionLevel int
+	// Compressor compresses the plaintext before encryption instead of Compression, if not nil.
+	Compressor Compressor
+	// CompressionThreshold defines the plaintext size in bytes below which the plaintext
+	// is not compressed. Only considered if Compression or Compressor is set.
+	CompressionThreshold int
+	// AEADMode defines the aead mode to use for SEIPDv2 encryption instead of the profile default.
+	// constants.AEADModeEAX: eax, constants.AEADModeOCB: ocb, constants.AEADModeGCM: gcm
+	// If zero, the aead mode of the profile is used.
+	AEADMode int8
+	// AEADChunkSize defines the chunk size in bytes for SEIPDv2 encryption instead of the profile default.
+	// Must be a power of two between constants.AEADMinChunkSize and constants.AEADMaxChunkSize.
+	// If zero, the chunk size of the profile is used.
+	AEADChunkSize int
 	// DetachedSignature indicates if a separate encrypted detached signature
 	// should be created
 	DetachedSignature bool
@@ -55,10 +92,23 @@ type encryptionHandle struct {
 	// Is only considered if DetachedSignature is not set.
 	PlainDetachedSignature bool
 	IsUTF8                 bool
+	// PlainMetadata defines the filename and modification time of the plaintext
+	// in the literal data packet. If nil, both are empty.
+	PlainMetadata *LiteralMetadata
 	// ExternalSignature allows to include an external signature into
 	// the encrypted message.
 	ExternalSignature []byte
-	profile           EncryptionProfile
+	// ProgressListener is notified about the plaintext bytes written
+	// and the message bytes produced by EncryptingWriter.
+	// If nil, no progress is reported.
+	ProgressListener ProgressListener
+	// Observer is notified once an encryption completed, see OperationObserver.
+	// If nil, no operations are reported.
+	Observer OperationObserver
+	// Logger is called with the selected keys and the negotiated algorithms, see Logger.
+	// If nil, nothing is logged.
+	Logger  Logger
+	profile EncryptionProfile
 
 	encryptionTimeOverride Clock
 	clock                  Clock
@@ -80,17 +130,69 @@ func defaultEncryptionHandle(profile EncryptionProfile, clock Clock) *encryption
 // If the output Writer is of type PGPSplitWriter, the output can be split to multiple writers
 // for different parts of the message. For example to write key packets and encrypted data packets
 // to different writers or to write a detached signature separately.
-// The encoding argument defines the output encoding, i.e., Bytes or Armored
+// The encoding argument defines the output encoding, i.e., Bytes, Armor, or Base64
 // The returned pgp message WriteCloser must be closed after the plaintext has been written.
+// The plaintext is not buffered, it is written with partial length packets,
+// such that its size does not need to be known in advance.
 func (eh *encryptionHandle) EncryptingWriter(outputWriter Writer, encoding int8) (messageWriter WriteCloser, err error) {
+	if eh.Observer != nil {
+		observation := startObservation(eh.Observer, OperationEncrypt, eh.cipherName())
+		handle := *eh
+		handle.Observer = nil
+		messageWriter, err = handle.EncryptingWriter(outputWriter, encoding)
+		return observeWriter(observation, messageWriter, err)
+	}
+	if eh.ProgressListener != nil {
+		progress := &progressTracker{listener: eh.ProgressListener}
+		handle := *eh
+		handle.ProgressListener = nil
+		messageWriter, err = handle.EncryptingWriter(progress.wrapOutput(outputWriter), encoding)
+		if err != nil {
+			return nil, err
+		}
+		return newProgressWriteCloser(messageWriter, progress.addIn), nil
+	}
+	if (eh.Compression != constants.NoCompression || eh.Compressor != nil) && eh.CompressionThreshold > 0 {
+		if err := eh.validateDeferredWriter(outputWriter); err != nil {
+			return nil, err
+		}
+		return &compressionThresholdWriter{
+			threshold: eh.CompressionThreshold,
+			buffer:    internal.GetBuffer(),
+			open: func(compress bool) (WriteCloser, error) {
+				handle := *eh
+				handle.CompressionThreshold = 0
+				if !compress {
+					handle.Compression = constants.NoCompression
+					handle.Compressor = nil
+				}
+				return handle.EncryptingWriter(outputWriter, encoding)
+			},
+		}, nil
+	}
 	pgpSplitWriter := castToPGPSplitWriter(outputWriter)
 	if pgpSplitWriter != nil {
-		return eh.encryptingWriters(pgpSplitWriter.Keys(), pgpSplitWriter, pgpSplitWriter.Signature(), nil, armorOutput(encoding))
+		return eh.encryptingWriters(pgpSplitWriter.Keys(), pgpSplitWriter, pgpSplitWriter.Signature(), eh.literalMetadata(), encoding)
 	}
 	if eh.DetachedSignature {
 		return nil, errors.New("gopenpgp: no pgp split writer provided for the detached signature")
 	}
-	return eh.encryptingWriters(nil, outputWriter, nil, nil, armorOutput(encoding))
+	return eh.encryptingWriters(nil, outputWriter, nil, eh.literalMetadata(), encoding)
+}
+
+// EncryptingWriterContext is like EncryptingWriter but aborts the encryption once ctx is done.
+// Writes to the returned WriteCloser and to the output then fail with ctx.Err(),
+// even if a write to the output blocks.
+// Not supported on go-mobile clients.
+func (eh *encryptionHandle) EncryptingWriterContext(ctx context.Context, outputWriter Writer, encoding int8) (WriteCloser, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	messageWriter, err := eh.EncryptingWriter(wrapOutputContext(ctx, outputWriter), encoding)
+	if err != nil {
+		return nil, err
+	}
+	return &contextCheckWriteCloser{ctx: ctx, writer: messageWriter}, nil
 }
 
 // Encrypt encrypts a plaintext message.
@@ -114,21 +216,40 @@ func (eh *encryptionHandle) Encrypt(message []byte) (*PGPMessage, error) {
 }
 
 // EncryptSessionKey encrypts a session key with the encryption handle.
-// To encrypt a session key, the handle must contain either recipients or a password.
+// To encrypt a session key, the handle must contain recipients, passwords, or both.
+// The key packets for the recipients precede the key packets for the passwords.
 func (eh *encryptionHandle) EncryptSessionKey(sessionKey *SessionKey) ([]byte, error) {
-	config := eh.profile.EncryptionConfig()
+	config := eh.encryptionConfig()
 	config.Time = NewConstantClock(eh.clock().Unix())
-	switch {
-	case eh.Password != nil:
-		return encryptSessionKeyWithPassword(sessionKey, eh.Password, config)
-	case eh.Recipients != nil || eh.HiddenRecipients != nil:
+	hasRecipients := eh.Recipients != nil || eh.HiddenRecipients != nil
+	if !hasRecipients && eh.Password == nil {
+		return nil, errors.New("gopenpgp: no password or recipients in encryption handle")
+	}
+	keyPackets := &bytes.Buffer{}
+	if hasRecipients {
 		encryptionTimeOverride := config.Now()
 		if eh.encryptionTimeOverride != nil {
 			encryptionTimeOverride = eh.encryptionTimeOverride()
 		}
-		return encryptSessionKey(eh.Recipients, eh.HiddenRecipients, sessionKey, encryptionTimeOverride, config)
+		if err := encryptSessionKeyToWriter(
+			eh.Recipients,
+			eh.HiddenRecipients,
+			sessionKey,
+			keyPackets,
+			encryptionTimeOverride,
+			config,
+		); err != nil {
+			return nil, err
+		}
+		if !supportSEIPDv2(encryptionTimeOverride, config, eh.Recipients, eh.HiddenRecipients) {
+			// The password key packets must match the version of the recipient key packets.
+			config.AEADConfig = nil
+		}
+	}
+	if err := encryptSessionKeyWithPasswordsToWriter(eh.passwords(), sessionKey, keyPackets, config); err != nil {
+		return nil, err
 	}
-	return nil, errors.New("gopenpgp: no password or recipients in encryption handle")
+	return keyPackets.Bytes(), nil
 }
 
 // --- Helper methods on encryption handle
@@ -145,27 +266,134 @@ func (eh *encryptionHandle) validate() error {
 		return errors.New("gopenpgp: no signing key but signing context provided")
 	}
 
+	if eh.SignKeyRing == nil && len(eh.SigningNotations) > 0 {
+		return errors.New("gopenpgp: no signing key but signing notations provided")
+	}
+
 	if eh.SignKeyRing == nil && eh.DetachedSignature {
 		return errors.New("gopenpgp: no signing key provided for detached signature")
 	}
+	if isStrictRFC9580(eh.profile) {
+		if err := eh.validateStrictRFC9580(); err != nil {
+			return err
+		}
+	}
+	return validateNotations(eh.SigningNotations)
+}
+
+// validateDeferredWriter checks the handle for a compressionThresholdWriter, which opens the
+// encrypting writer only once the threshold is reached or the writer is closed, such that
+// EncryptingWriter returns the errors of invalid handles as if the writer was opened at once.
+func (eh *encryptionHandle) validateDeferredWriter(outputWriter Writer) error {
+	if err := eh.validate(); err != nil {
+		return err
+	}
+	pgpSplitWriter := castToPGPSplitWriter(outputWriter)
+	if eh.DetachedSignature && pgpSplitWriter == nil {
+		return errors.New("gopenpgp: no pgp split writer provided for the detached signature")
+	}
+	if eh.PlainDetachedSignature && (pgpSplitWriter == nil || pgpSplitWriter.Signature() == nil) {
+		return errors.New("gopenpgp: no output provided for the detached signature")
+	}
+	if eh.SignKeyRing != nil {
+		if _, err := eh.SignKeyRing.signingEntities(); err != nil {
+			return err
+		}
+	}
+	date := eh.clock()
+	if eh.encryptionTimeOverride != nil {
+		date = eh.encryptionTimeOverride()
+	}
+	config := eh.encryptionConfig()
+	for _, recipients := range []*KeyRing{eh.Recipients, eh.HiddenRecipients} {
+		for _, entity := range recipients.isolatedEntities() {
+			if _, ok := entity.EncryptionKey(date, config); !ok {
+				return errors.New("gopenpgp: encryption key is unavailable for key id " + strconv.FormatUint(entity.PrimaryKey.KeyId, 16))
+			}
+		}
+	}
 	return nil
 }
 
+// validateStrictRFC9580 checks that the handle creates a SEIPDv2 message
+// with v6 signatures, as required by a strict RFC9580 profile.
+func (eh *encryptionHandle) validateStrictRFC9580() error {
+	if eh.SessionKey != nil && !eh.SessionKey.v6 {
+		return errors.New("gopenpgp: strict RFC9580 profile refuses to encrypt with a v4 session key")
+	}
+	config := eh.encryptionConfig()
+	if err := checkStrictRecipients(eh.Recipients, config, eh.clock()); err != nil {
+		return err
+	}
+	if err := checkStrictRecipients(eh.HiddenRecipients, config, eh.clock()); err != nil {
+		return err
+	}
+	return checkStrictSigners(eh.SignKeyRing)
+}
+
+// encryptionConfig returns the encryption config of the profile
+// with the encryption options of the handle applied.
+func (eh *encryptionHandle) encryptionConfig() *packet.Config {
+	config := eh.profile.EncryptionConfig()
+	if eh.AEADMode != 0 || eh.AEADChunkSize != 0 {
+		aeadConfig := &packet.AEADConfig{}
+		if config.AEADConfig != nil {
+			*aeadConfig = *config.AEADConfig
+		}
+		if eh.AEADMode != 0 {
+			aeadConfig.DefaultMode = packet.AEADMode(eh.AEADMode)
+		}
+		if eh.AEADChunkSize != 0 {
+			aeadConfig.ChunkSize = uint64(eh.AEADChunkSize)
+		}
+		config.AEADConfig = aeadConfig
+	}
+	if eh.S2KParams != nil {
+		eh.S2KParams.applyTo(config)
+	}
+	return config
+}
+
+// cipherName returns the name of the symmetric cipher the message is encrypted with.
+func (eh *encryptionHandle) cipherName() string {
+	if eh.SessionKey != nil && eh.SessionKey.Algo != "" {
+		return eh.SessionKey.Algo
+	}
+	return algosToSymKey[eh.encryptionConfig().Cipher()]
+}
+
+// passwords returns all passwords the message should be encrypted with.
+func (eh *encryptionHandle) passwords() [][]byte {
+	if eh.Password == nil {
+		return nil
+	}
+	return append([][]byte{eh.Password}, eh.AdditionalPasswords...)
+}
+
+// armorOptions returns the options for armoring the encrypted message.
+func (eh *encryptionHandle) armorOptions() *armorHelper.Options {
+	return &armorHelper.Options{
+		Headers:      eh.ArmorHeaders,
+		LineLength:   eh.ArmorLineLength,
+		OmitChecksum: !eh.armorChecksumRequired(),
+	}
+}
+
 // armorChecksumRequired determines if an armor checksum should be appended or not.
 // The OpenPGP Crypto-Refresh mandates that no checksum should be appended with the new packets.
 func (eh *encryptionHandle) armorChecksumRequired() bool {
-	if !constants.ArmorChecksumEnabled {
+	if !constants.ArmorChecksumEnabled || eh.OmitArmorChecksum {
 		// If the default behavior is no checksum, we can ignore
 		// the logic for the RFC9580 check.
 		return false
 	}
-	encryptionConfig := eh.profile.EncryptionConfig()
+	encryptionConfig := eh.encryptionConfig()
 	if encryptionConfig.AEADConfig == nil {
 		return true
 	}
 	checkTime := eh.clock()
 	if eh.Recipients != nil {
-		for _, recipient := range eh.Recipients.entities {
+		for _, recipient := range eh.Recipients.isolatedEntities() {
 			primarySelfSignature, err := recipient.PrimarySelfSignature(checkTime, encryptionConfig)
 			if err != nil {
 				return true
@@ -176,7 +404,7 @@ func (eh *encryptionHandle) armorChecksumRequired() bool {
 		}
 	}
 	if eh.HiddenRecipients != nil {
-		for _, recipient := range eh.HiddenRecipients.entities {
+		for _, recipient := range eh.HiddenRecipients.isolatedEntities() {
 			primarySelfSignature, err := recipient.PrimarySelfSignature(checkTime, encryptionConfig)
 			if err != nil {
 				return true
@@ -219,8 +447,8 @@ func (eh *encryptionHandle) ClearPrivateParams() {
 	if eh.SessionKey != nil {
 		eh.SessionKey.Clear()
 	}
-	if eh.Password != nil {
-		clearMem(eh.Password)
+	for _, password := range eh.passwords() {
+		clearMem(password)
 	}
 }
 
@@ -231,25 +459,22 @@ func (eh *encryptionHandle) handleArmor(keys, data, detachedSignature Writer) (
 	armorSigWriter WriteCloser,
 	err error,
 ) {
-	writeChecksum := eh.armorChecksumRequired()
+	armorOptions := eh.armorOptions()
 	detachedSignatureOut = detachedSignature
 	// Wrap armored writer
-	if eh.ArmorHeaders == nil {
-		eh.ArmorHeaders = internal.ArmorHeaders
-	}
-	armorWriter, err = armor.EncodeWithChecksumOption(data, constants.PGPMessageHeader, eh.ArmorHeaders, writeChecksum)
+	armorWriter, err = armorHelper.ArmorWriterWithOptions(data, constants.PGPMessageHeader, armorOptions)
 	dataOut = armorWriter
 	if err != nil {
 		return nil, nil, nil, nil, err
 	}
 	if eh.DetachedSignature {
-		armorSigWriter, err = armor.EncodeWithChecksumOption(detachedSignature, constants.PGPMessageHeader, eh.ArmorHeaders, writeChecksum)
+		armorSigWriter, err = armorHelper.ArmorWriterWithOptions(detachedSignature, constants.PGPMessageHeader, armorOptions)
 		detachedSignatureOut = armorSigWriter
 		if err != nil {
 			return nil, nil, nil, nil, err
 		}
 	} else if eh.PlainDetachedSignature {
-		armorSigWriter, err = armor.EncodeWithChecksumOption(detachedSignature, constants.PGPSignatureHeader, eh.ArmorHeaders, writeChecksum)
+		armorSigWriter, err = armorHelper.ArmorWriterWithOptions(detachedSignature, constants.PGPSignatureHeader, armorOptions)
 		detachedSignatureOut = armorSigWriter
 		if err != nil {
 			return nil, nil, nil, nil, err
@@ -261,7 +486,26 @@ func (eh *encryptionHandle) handleArmor(keys, data, detachedSignature Writer) (
 	return dataOut, detachedSignatureOut, armorWriter, armorSigWriter, nil
 }
 
-func (eh *encryptionHandle) encryptingWriters(keys, data, detachedSignature Writer, meta *LiteralMetadata, armorOutput bool) (messageWriter WriteCloser, err error) {
+// handleBase64 wraps the data and detached signature writers in base64 encoders.
+func (eh *encryptionHandle) handleBase64(keys, data, detachedSignature Writer) (
+	dataOut Writer,
+	detachedSignatureOut Writer,
+	base64Writer WriteCloser,
+	base64SigWriter WriteCloser,
+	err error,
+) {
+	if keys != nil {
+		return nil, nil, nil, nil, errors.New("gopenpgp: base64 encoding is not allowed if key packets are written separately")
+	}
+	base64Writer = newBase64Writer(data)
+	if eh.DetachedSignature || eh.PlainDetachedSignature {
+		base64SigWriter = newBase64Writer(detachedSignature)
+		return base64Writer, base64SigWriter, base64Writer, base64SigWriter, nil
+	}
+	return base64Writer, detachedSignature, base64Writer, nil, nil
+}
+
+func (eh *encryptionHandle) encryptingWriters(keys, data, detachedSignature Writer, meta *LiteralMetadata, encoding int8) (messageWriter WriteCloser, err error) {
 	var armorWriter WriteCloser
 	var armorSigWriter WriteCloser
 	if err = eh.validate(); err != nil {
@@ -273,10 +517,19 @@ func (eh *encryptionHandle) encryptingWriters(keys, data, detachedSignature Writ
 		return nil, errors.New("gopenpgp: no output provided for the detached signature")
 	}
 
-	if armorOutput {
+	encodeOutput := armorOutput(encoding) || encoding == Base64
+	if armorOutput(encoding) {
 		data, detachedSignature, armorWriter, armorSigWriter, err = eh.handleArmor(keys, data, detachedSignature)
-		if err != nil {
-			return nil, err
+	} else if encoding == Base64 {
+		data, detachedSignature, armorWriter, armorSigWriter, err = eh.handleBase64(keys, data, detachedSignature)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if algorithms := eh.logEncryption(); algorithms != nil {
+		data = algorithms.writer(data)
+		if keys != nil {
+			keys = algorithms.writer(keys)
 		}
 	}
 	if keys == nil {
@@ -291,7 +544,9 @@ func (eh *encryptionHandle) encryptingWriters(keys, data, detachedSignature Writ
 	switch {
 	case eh.Recipients.CountEntities() > 0 || eh.HiddenRecipients.CountEntities() > 0:
 		// Encrypt towards recipients
-		if !doDetachedSignature {
+		if !doDetachedSignature && eh.Compressor != nil {
+			messageWriter, err = eh.encryptStreamToRecipientsWithSessionKey(keys, data, meta)
+		} else if !doDetachedSignature {
 			// Signature is inside the ciphertext.
 			messageWriter, err = eh.encryptStream(keys, data, meta)
 		} else {
@@ -300,7 +555,9 @@ func (eh *encryptionHandle) encryptingWriters(keys, data, detachedSignature Writ
 		}
 	case eh.Password != nil:
 		// Encrypt with a password
-		if !doDetachedSignature {
+		if !doDetachedSignature && eh.Compressor != nil {
+			messageWriter, err = eh.encryptStreamToRecipientsWithSessionKey(keys, data, meta)
+		} else if !doDetachedSignature {
 			messageWriter, err = eh.encryptStreamWithPassword(keys, data, meta)
 		} else {
 			messageWriter, err = eh.encryptSignDetachedStreamToRecipients(meta, detachedSignature, data, keys, eh.DetachedSignature)
@@ -317,10 +574,11 @@ func (eh *encryptionHandle) encryptingWriters(keys, data, detachedSignature Writ
 		err = errors.New("gopenpgp: no encryption key ring, session key, or password provided")
 	}
 	if err != nil {
+		logEntry(eh.Logger, LogLevelWarning, OperationEncrypt, "encryption failed", map[string]string{"error": err.Error()})
 		return nil, err
 	}
-	if armorOutput {
-		// Wrap armored writer
+	if encodeOutput {
+		// Wrap armored or base64 writer
 		messageWriter = &armoredWriteCloser{
 			armorWriter:    armorWriter,
 			messageWriter:  messageWriter,
@@ -335,6 +593,15 @@ func (eh *encryptionHandle) encryptingWriters(keys, data, detachedSignature Writ
 	return messageWriter, nil
 }
 
+// literalMetadata returns the metadata of the literal data packet,
+// where the utf-8 flag is determined by IsUTF8.
+func (eh *encryptionHandle) literalMetadata() *LiteralMetadata {
+	if eh.PlainMetadata == nil {
+		return nil
+	}
+	return NewFileMetadata(eh.IsUTF8, eh.PlainMetadata.Filename(), eh.PlainMetadata.Time())
+}
+
 func castToPGPSplitWriter(w Writer) PGPSplitWriter {
 	v, ok := interface{}(w).(PGPSplitWriter)
 	if ok {
@@ -346,3 +613,21 @@ func castToPGPSplitWriter(w Writer) PGPSplitWriter {
 	}
 	return nil
 }
+
+// wrapOutputWriter applies wrap to the output writer.
+// If the output is a PGPSplitWriter, wrap is applied to each of its writers
+// and the returned writer is a PGPSplitWriter as well.
+func wrapOutputWriter(output Writer, wrap func(Writer) Writer) Writer {
+	splitWriter := castToPGPSplitWriter(output)
+	if splitWriter == nil {
+		return wrap(output)
+	}
+	wrapped := &pgpSplitWriter{ciphertext: wrap(splitWriter)}
+	if keys := splitWriter.Keys(); keys != nil {
+		wrapped.keyPackets = wrap(keys)
+	}
+	if signature := splitWriter.Signature(); signature != nil {
+		wrapped.detachedSignature = wrap(signature)
+	}
+	return wrapped
+}
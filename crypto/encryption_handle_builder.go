@@ -1,6 +1,10 @@
 package crypto
 
-import "github.com/ProtonMail/gopenpgp/v3/constants"
+import (
+	armorHelper "github.com/ProtonMail/gopenpgp/v3/armor"
+	"github.com/ProtonMail/gopenpgp/v3/constants"
+	"github.com/pkg/errors"
+)
 
 // EncryptionHandleBuilder allows to configure a decryption handle to decrypt an OpenPGP message.
 type EncryptionHandleBuilder struct {
@@ -22,13 +26,7 @@ func newEncryptionHandleBuilder(profile EncryptionProfile, clock Clock) *Encrypt
 // of the signature, if a signature is present.
 // If not set, set another type of encryption: HiddenRecipients, SessionKey, or Password.
 func (ehb *EncryptionHandleBuilder) Recipient(key *Key) *EncryptionHandleBuilder {
-	var err error
-	if ehb.handle.Recipients == nil {
-		ehb.handle.Recipients, err = NewKeyRing(key)
-	} else {
-		err = ehb.handle.Recipients.AddKey(key)
-	}
-	ehb.err = err
+	ehb.handle.Recipients, ehb.err = keyRingWithKey(ehb.handle.Recipients, key)
 	return ehb
 }
 
@@ -46,15 +44,12 @@ func (ehb *EncryptionHandleBuilder) Recipients(recipients *KeyRing) *EncryptionH
 // Triggers hybrid encryption with public keys of the recipients and hidden recipients.
 // The hidden recipients are NOT included in the intended recipient fingerprint list
 // of the signature, if a signature is present.
+// The key packets of hidden recipients contain a wildcard key id instead of the recipient key id,
+// such that the recipients are not leaked in the message metadata.
+// On decryption, all available decryption keys are tried for such key packets.
 // If not set, set another type of encryption: Recipients, SessionKey, or Password.
 func (ehb *EncryptionHandleBuilder) HiddenRecipient(key *Key) *EncryptionHandleBuilder {
-	var err error
-	if ehb.handle.HiddenRecipients == nil {
-		ehb.handle.HiddenRecipients, err = NewKeyRing(key)
-	} else {
-		err = ehb.handle.HiddenRecipients.AddKey(key)
-	}
-	ehb.err = err
+	ehb.handle.HiddenRecipients, ehb.err = keyRingWithKey(ehb.handle.HiddenRecipients, key)
 	return ehb
 }
 
@@ -62,6 +57,9 @@ func (ehb *EncryptionHandleBuilder) HiddenRecipient(key *Key) *EncryptionHandleB
 // Triggers hybrid encryption with public keys of the recipients and hidden recipients.
 // The hidden recipients are NOT included in the intended recipient fingerprint list
 // of the signature, if a signature is present.
+// The key packets of hidden recipients contain a wildcard key id instead of the recipient key id,
+// such that the recipients are not leaked in the message metadata.
+// On decryption, all available decryption keys are tried for such key packets.
 // If not set, set another type of encryption: Recipients, SessionKey, or Password.
 func (ehb *EncryptionHandleBuilder) HiddenRecipients(hiddenRecipients *KeyRing) *EncryptionHandleBuilder {
 	ehb.handle.HiddenRecipients = hiddenRecipients
@@ -72,13 +70,7 @@ func (ehb *EncryptionHandleBuilder) HiddenRecipients(hiddenRecipients *KeyRing)
 // Triggers that signatures are created for each signing key.
 // If not set, no signature is included.
 func (ehb *EncryptionHandleBuilder) SigningKey(key *Key) *EncryptionHandleBuilder {
-	var err error
-	if ehb.handle.SignKeyRing == nil {
-		ehb.handle.SignKeyRing, err = NewKeyRing(key)
-	} else {
-		err = ehb.handle.SignKeyRing.AddKey(key)
-	}
-	ehb.err = err
+	ehb.handle.SignKeyRing, ehb.err = keyRingWithKey(ehb.handle.SignKeyRing, key)
 	return ehb
 }
 
@@ -98,6 +90,14 @@ func (ehb *EncryptionHandleBuilder) SigningContext(siningContext *SigningContext
 	return ehb
 }
 
+// SigningNotation adds a notation to the signature in the message, see NewNotation and NewBinaryNotation.
+// Can be called multiple times to add multiple notations.
+// SigningKeys have to be set if a SigningNotation is provided.
+func (ehb *EncryptionHandleBuilder) SigningNotation(notation *Notation) *EncryptionHandleBuilder {
+	ehb.handle.SigningNotations = append(ehb.handle.SigningNotations, notation)
+	return ehb
+}
+
 // SessionKey sets the session key the message should be encrypted with.
 // Triggers session key encryption with the included session key.
 // If not set, set another the type of encryption: Recipients, HiddenRecipients, or Password.
@@ -114,12 +114,57 @@ func (ehb *EncryptionHandleBuilder) Password(password []byte) *EncryptionHandleB
 	return ehb
 }
 
+// Passwords sets multiple passwords the message should be encrypted with.
+// For each password a separate key packet is added, such that the message can be decrypted with any of them.
+// Can be combined with recipients, e.g., to add a recovery passphrase to a message encrypted to keys.
+// Triggers password based encryption if no recipients are set.
+// Not supported on go-mobile clients, use AddPassword instead.
+func (ehb *EncryptionHandleBuilder) Passwords(passwords [][]byte) *EncryptionHandleBuilder {
+	if len(passwords) == 0 {
+		ehb.err = errors.New("gopenpgp: no passwords provided")
+		return ehb
+	}
+	ehb.handle.Password = passwords[0]
+	ehb.handle.AdditionalPasswords = passwords[1:]
+	return ehb
+}
+
+// AddPassword adds a further password the message should be encrypted with.
+// For each password a separate key packet is added, such that the message can be decrypted with any of them.
+// Can be combined with recipients, e.g., to add a recovery passphrase to a message encrypted to keys.
+// Triggers password based encryption if no recipients are set.
+func (ehb *EncryptionHandleBuilder) AddPassword(password []byte) *EncryptionHandleBuilder {
+	if ehb.handle.Password == nil {
+		ehb.handle.Password = password
+	} else {
+		ehb.handle.AdditionalPasswords = append(ehb.handle.AdditionalPasswords, password)
+	}
+	return ehb
+}
+
+// S2K sets how the key encryption keys are derived from the passwords (string-to-key)
+// instead of the S2K settings of the profile, e.g., Argon2 with custom parameters.
+// Only considered if the message is encrypted with passwords.
+func (ehb *EncryptionHandleBuilder) S2K(params *S2KParams) *EncryptionHandleBuilder {
+	if params == nil {
+		ehb.err = errors.New("gopenpgp: no s2k parameters provided")
+		return ehb
+	}
+	if err := params.validate(); err != nil {
+		ehb.err = err
+		return ehb
+	}
+	ehb.handle.S2KParams = params
+	return ehb
+}
+
 // Compress indicates if the plaintext should be compressed before encryption.
 // Compression affects security and opens the door for side-channel attacks, which
 // might allow to extract the plaintext data without a decryption key.
 // RFC9580 recommends to not use compression.
 func (ehb *EncryptionHandleBuilder) Compress() *EncryptionHandleBuilder {
 	ehb.handle.Compression = constants.DefaultCompression
+	ehb.handle.Compressor = nil
 	return ehb
 }
 
@@ -137,7 +182,113 @@ func (ehb *EncryptionHandleBuilder) CompressWith(config int8) *EncryptionHandleB
 		constants.ZIPCompression,
 		constants.ZLIBCompression:
 		ehb.handle.Compression = config
+		ehb.handle.Compressor = nil
+	}
+	return ehb
+}
+
+// CompressWithCompressor indicates that the plaintext should be compressed with the compressor
+// before encryption, e.g., to use another compression implementation, see Compressor.
+// Compression affects security and opens the door for side-channel attacks, which
+// might allow to extract the plaintext data without a decryption key.
+// RFC9580 recommends to not use compression.
+// Replaces the compression selected with CompressWith.
+// Not supported on go-mobile clients.
+func (ehb *EncryptionHandleBuilder) CompressWithCompressor(compressor Compressor) *EncryptionHandleBuilder {
+	if err := checkCompressor(compressor); err != nil {
+		ehb.err = err
+		return ehb
+	}
+	ehb.handle.Compression = constants.NoCompression
+	ehb.handle.Compressor = compressor
+	return ehb
+}
+
+// CompressionLevel sets the compression level from 1 (fastest) to 9 (best compression)
+// for the compression selected with CompressWith.
+// If not set, the level of the selected compression is used.
+func (ehb *EncryptionHandleBuilder) CompressionLevel(level int) *EncryptionHandleBuilder {
+	if level < 1 || level > 9 {
+		ehb.err = errors.New("gopenpgp: invalid compression level")
+		return ehb
 	}
+	ehb.handle.CompressionLevel = level
+	return ehb
+}
+
+// CompressionThreshold sets the plaintext size in bytes below which the plaintext is not compressed,
+// since small plaintexts do not benefit from compression.
+// Only considered if a compression is selected with CompressWith or CompressWithCompressor.
+// The encrypting writer buffers the plaintext until the threshold is reached.
+func (ehb *EncryptionHandleBuilder) CompressionThreshold(threshold int) *EncryptionHandleBuilder {
+	if threshold < 0 {
+		ehb.err = errors.New("gopenpgp: invalid compression threshold")
+		return ehb
+	}
+	ehb.handle.CompressionThreshold = threshold
+	return ehb
+}
+
+// AEADMode sets the aead mode for SEIPDv2 encryption instead of the aead mode of the profile.
+// Triggers SEIPDv2 encryption with the given mode if the message is encrypted with a
+// session key or a password, or if all recipient keys support SEIPDv2.
+// Allowed inputs (integer enum for go-mobile compatibility):
+// constants.AEADModeEAX, constants.AEADModeOCB, constants.AEADModeGCM.
+func (ehb *EncryptionHandleBuilder) AEADMode(mode int8) *EncryptionHandleBuilder {
+	switch mode {
+	case constants.AEADModeEAX,
+		constants.AEADModeOCB,
+		constants.AEADModeGCM:
+		ehb.handle.AEADMode = mode
+	default:
+		ehb.err = errors.New("gopenpgp: unsupported aead mode")
+	}
+	return ehb
+}
+
+// AEADChunkSize sets the chunk size in bytes for SEIPDv2 encryption instead of the chunk size of the profile.
+// Small chunks allow constrained receivers to release plaintext early, while large chunks
+// reduce the overhead for archival data.
+// Triggers SEIPDv2 encryption if the message is encrypted with a
+// session key or a password, or if all recipient keys support SEIPDv2.
+// The chunk size must be a power of two between constants.AEADMinChunkSize (64 bytes)
+// and constants.AEADMaxChunkSize (4 MiB).
+func (ehb *EncryptionHandleBuilder) AEADChunkSize(chunkSize int) *EncryptionHandleBuilder {
+	if chunkSize < constants.AEADMinChunkSize ||
+		chunkSize > constants.AEADMaxChunkSize ||
+		chunkSize&(chunkSize-1) != 0 {
+		ehb.err = errors.New("gopenpgp: invalid aead chunk size")
+		return ehb
+	}
+	ehb.handle.AEADChunkSize = chunkSize
+	return ehb
+}
+
+// ArmorHeader indicates that the armored message should have
+// the given version and comment as header. Empty parameters are omitted from the headers.
+// Only considered if the message is armored.
+func (ehb *EncryptionHandleBuilder) ArmorHeader(version, comment string) *EncryptionHandleBuilder {
+	ehb.handle.ArmorHeaders = armorHelper.HeadersWithVersionAndComment(version, comment)
+	return ehb
+}
+
+// ArmorLineLength sets the number of base64 characters per line of the armored message.
+// Must be a multiple of four and at most 76. The default line length is 64.
+// Only considered if the message is armored.
+func (ehb *EncryptionHandleBuilder) ArmorLineLength(lineLength int) *EncryptionHandleBuilder {
+	if !armorHelper.ValidLineLength(lineLength) {
+		ehb.err = errors.Errorf("gopenpgp: invalid armor line length %d", lineLength)
+		return ehb
+	}
+	ehb.handle.ArmorLineLength = lineLength
+	return ehb
+}
+
+// OmitArmorChecksum indicates that no CRC24 checksum should be appended to the armored message.
+// By default, the checksum is only omitted if all recipients support RFC9580.
+// Only considered if the message is armored.
+func (ehb *EncryptionHandleBuilder) OmitArmorChecksum() *EncryptionHandleBuilder {
+	ehb.handle.OmitArmorChecksum = true
 	return ehb
 }
 
@@ -148,6 +299,14 @@ func (ehb *EncryptionHandleBuilder) Utf8() *EncryptionHandleBuilder {
 	return ehb
 }
 
+// PlainMetadata sets the filename and modification time of the plaintext
+// in the encrypted message, e.g., created with NewFileMetadata.
+// The utf-8 flag of the metadata is ignored, see Utf8.
+func (ehb *EncryptionHandleBuilder) PlainMetadata(metadata *LiteralMetadata) *EncryptionHandleBuilder {
+	ehb.handle.PlainMetadata = metadata
+	return ehb
+}
+
 // DetachedSignature indicates that the message should be signed,
 // but the signature should not be included in the same pgp message as the input data.
 // Instead the detached signature is encrypted in a separate pgp message.
@@ -194,6 +353,34 @@ func (ehb *EncryptionHandleBuilder) SignTime(unixTime int64) *EncryptionHandleBu
 	return ehb
 }
 
+// ProgressListener sets a listener that is notified about the progress of the encryption,
+// i.e., the plaintext bytes written and the message bytes produced so far.
+// If not set, no progress is reported.
+func (ehb *EncryptionHandleBuilder) ProgressListener(listener ProgressListener) *EncryptionHandleBuilder {
+	ehb.handle.ProgressListener = listener
+	return ehb
+}
+
+// Observer sets an observer that is notified once an encryption completed,
+// e.g., to export metrics. Overrides the observer set with PGPHandle.WithObserver.
+// If not set, no operations are reported.
+// Not supported on go-mobile clients.
+func (ehb *EncryptionHandleBuilder) Observer(observer OperationObserver) *EncryptionHandleBuilder {
+	ehb.handle.Observer = observer
+	return ehb
+}
+
+// Logger sets a logger that is called with the selected encryption and signing keys, and the algorithms
+// of the written data packet, e.g., to diagnose interoperability failures.
+// The keys of hidden recipients are not logged, only their number.
+// Overrides the logger set with PGPHandle.WithLogger.
+// If not set, nothing is logged.
+// Not supported on go-mobile clients.
+func (ehb *EncryptionHandleBuilder) Logger(logger Logger) *EncryptionHandleBuilder {
+	ehb.handle.Logger = logger
+	return ehb
+}
+
 // New creates an EncryptionHandle and checks that the given
 // combination of parameters is valid. If the parameters are invalid
 // an error is returned.
@@ -7,17 +7,17 @@ import (
 	"time"
 
 	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	openpgp "github.com/ProtonMail/go-crypto/openpgp/v2"
 	"github.com/pkg/errors"
 )
 
 // decryptSessionKey returns the decrypted session key from one or multiple binary encrypted session key packets.
-func decryptSessionKey(keyRing *KeyRing, keyPacket []byte) (*SessionKey, error) {
+// The key packets addressed to one of the hinted key ids are tried first,
+// key packets with a wildcard key id are tried last.
+func decryptSessionKey(keyRing *KeyRing, keyPacket []byte, hints []uint64) (*SessionKey, error) {
 	var p packet.Packet
-	var ek *packet.EncryptedKey
-
+	var encryptedKeys, hintedKeys, wildcardKeys []*packet.EncryptedKey
 	var err error
-	var hasPacket = false
-	var decryptErr error
 
 	keyReader := bytes.NewReader(keyPacket)
 	packets := packet.NewReader(keyReader)
@@ -30,21 +30,13 @@ Loop:
 
 		switch p := p.(type) {
 		case *packet.EncryptedKey:
-			hasPacket = true
-			ek = p
-			unverifiedEntities := keyRing.entities.EntitiesById(p.KeyId)
-			for _, unverifiedEntity := range unverifiedEntities {
-				keys := unverifiedEntity.DecryptionKeys(p.KeyId, time.Time{}, &packet.Config{})
-				for _, key := range keys {
-					priv := key.PrivateKey
-					if priv.Encrypted {
-						continue
-					}
-
-					if decryptErr = ek.Decrypt(priv, nil); decryptErr == nil {
-						break Loop
-					}
-				}
+			switch {
+			case p.KeyId == 0:
+				wildcardKeys = append(wildcardKeys, p)
+			case containsKeyID(hints, p.KeyId):
+				hintedKeys = append(hintedKeys, p)
+			default:
+				encryptedKeys = append(encryptedKeys, p)
 			}
 		case *packet.SymmetricallyEncrypted,
 			*packet.AEADEncrypted,
@@ -57,7 +49,8 @@ Loop:
 		}
 	}
 
-	if !hasPacket {
+	encryptedKeys = append(append(hintedKeys, encryptedKeys...), wildcardKeys...)
+	if len(encryptedKeys) == 0 {
 		if err != nil {
 			return nil, errors.Wrap(err, "gopenpgp: couldn't find a session key packet")
 		} else {
@@ -65,31 +58,50 @@ Loop:
 		}
 	}
 
-	if decryptErr != nil {
-		return nil, errors.Wrap(decryptErr, "gopenpgp: error in decrypting")
+	var decryptErr error
+	for _, ek := range encryptedKeys {
+		unverifiedEntities := keyRings{keyRing}.EntitiesById(ek.KeyId)
+		for _, unverifiedEntity := range unverifiedEntities {
+			keys := unverifiedEntity.DecryptionKeys(ek.KeyId, time.Time{}, &packet.Config{})
+			for _, key := range keys {
+				priv := key.PrivateKey
+				if priv.Encrypted {
+					continue
+				}
+
+				if decryptErr = ek.Decrypt(priv, nil); decryptErr == nil {
+					return newSessionKeyFromEncrypted(ek)
+				}
+			}
+		}
 	}
 
-	if ek == nil || ek.Key == nil {
-		return nil, errors.New("gopenpgp: unable to decrypt session key: no valid decryption key")
+	if decryptErr != nil {
+		return nil, wrapKeyDecryptionError(decryptErr, "gopenpgp: error in decrypting")
 	}
+	return nil, tagError(errors.New("gopenpgp: unable to decrypt session key: no valid decryption key"), ErrNoDecryptionKey)
+}
 
-	return newSessionKeyFromEncrypted(ek)
+// entityMatchesKeyIDs checks if the primary key or a subkey of the entity has one of the key ids.
+func entityMatchesKeyIDs(entity *openpgp.Entity, keyIDs []uint64) bool {
+	if containsKeyID(keyIDs, entity.PrimaryKey.KeyId) {
+		return true
+	}
+	for _, subkey := range entity.Subkeys {
+		if containsKeyID(keyIDs, subkey.PublicKey.KeyId) {
+			return true
+		}
+	}
+	return false
 }
 
-// encryptSessionKey encrypts the session key with the unarmored
-// publicKey and returns a binary public-key encrypted session key packet.
-func encryptSessionKey(
-	recipients *KeyRing,
-	hiddenRecipients *KeyRing,
-	sk *SessionKey,
-	date time.Time,
-	config *packet.Config) ([]byte, error) {
-	outbuf := &bytes.Buffer{}
-	err := encryptSessionKeyToWriter(recipients, hiddenRecipients, sk, outbuf, date, config)
-	if err != nil {
-		return nil, err
+func containsKeyID(keyIDs []uint64, keyID uint64) bool {
+	for _, id := range keyIDs {
+		if id == keyID {
+			return true
+		}
 	}
-	return outbuf.Bytes(), nil
+	return false
 }
 
 // EncryptSessionKeyToWriter encrypts the session key with the unarmored
@@ -111,9 +123,11 @@ func encryptSessionKeyToWriter(
 	if err != nil {
 		return errors.Wrap(err, "gopenpgp: unable to encrypt session key")
 	}
-	pubKeys := make([]*packet.PublicKey, 0, len(recipients.getEntities())+len(hiddenRecipients.getEntities()))
+	recipientEntities := recipients.isolatedEntities()
+	hiddenRecipientEntities := hiddenRecipients.isolatedEntities()
+	pubKeys := make([]*packet.PublicKey, 0, len(recipientEntities)+len(hiddenRecipientEntities))
 	aeadSupport := config.AEAD() != nil
-	for _, e := range append(recipients.getEntities(), hiddenRecipients.getEntities()...) {
+	for _, e := range append(recipientEntities, hiddenRecipientEntities...) {
 		encryptionKey, ok := e.EncryptionKey(date, config)
 		if !ok {
 			return errors.New("gopenpgp: encryption key is unavailable for key id " + strconv.FormatUint(e.PrimaryKey.KeyId, 16))
@@ -133,7 +147,7 @@ func encryptSessionKeyToWriter(
 	}
 
 	for index, pub := range pubKeys {
-		isHidden := index >= len(recipients.getEntities())
+		isHidden := index >= len(recipientEntities)
 		err := packet.SerializeEncryptedKeyAEADwithHiddenOption(outputWriter, pub, cf, aeadSupport, sk.Key, isHidden, nil)
 		if err != nil {
 			return errors.Wrap(err, "gopenpgp: cannot set key")
@@ -142,8 +156,25 @@ func encryptSessionKeyToWriter(
 	return nil
 }
 
+// supportSEIPDv2 checks if all entities in the key rings announce support for SEIPDv2
+// in the self-signature of their primary key.
+func supportSEIPDv2(date time.Time, config *packet.Config, keyRings ...*KeyRing) bool {
+	for _, keyRing := range keyRings {
+		for _, e := range keyRing.isolatedEntities() {
+			primarySelfSignature, _ := e.PrimarySelfSignature(date, config)
+			if primarySelfSignature == nil || !primarySelfSignature.SEIPDv2 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
 // decryptSessionKeyWithPassword decrypts the binary symmetrically encrypted
 // session key packet and returns the session key.
+// As v4 key packets are not authenticated, a wrong password might seemingly decrypt
+// the v4 key packet of another password. Hence, all v4 key packets are tried and an error
+// is returned if they decrypt to different session keys.
 func decryptSessionKeyWithPassword(keyPacket, password []byte) (*SessionKey, error) {
 	keyReader := bytes.NewReader(keyPacket)
 	packets := packet.NewReader(keyReader)
@@ -162,26 +193,102 @@ func decryptSessionKeyWithPassword(keyPacket, password []byte) (*SessionKey, err
 	}
 
 	// Try the symmetric passphrase first
+	var candidate *SessionKey
 	if len(symKeys) != 0 && password != nil {
 		for _, s := range symKeys {
-			key, cipherFunc, err := s.Decrypt(password)
-			if err == nil {
-				sk := &SessionKey{
-					Key:  key,
-					Algo: getAlgo(cipherFunc),
-					v6:   cipherFunc == 0, // for v6 there is not algorithm specified
-				}
+			sk, ok := decryptSymmetricKeyPacket(s, password)
+			if !ok {
+				continue
+			}
+			if s.Version != 4 {
+				// The key packet is authenticated.
+				return sk, nil
+			}
+			if candidate != nil && (candidate.Algo != sk.Algo || !bytes.Equal(candidate.Key, sk.Key)) {
+				return nil, tagError(
+					errors.New("gopenpgp: the password decrypts several key packets to different session keys"),
+					ErrWrongPassword,
+				)
+			}
+			candidate = sk
+		}
+	}
+	if candidate != nil {
+		return candidate, nil
+	}
 
-				if err = sk.checkSize(); !sk.v6 && err != nil {
-					return nil, errors.Wrap(err, "gopenpgp: unable to decrypt session key with password")
-				}
+	return nil, tagError(errors.New("gopenpgp: unable to decrypt any packet"), ErrWrongPassword)
+}
 
-				return sk, nil
+// decryptSymmetricKeyPacket decrypts the session key of the symmetric key packet with the password.
+// A v4 key packet is only considered decrypted if the session key has the size of its algorithm,
+// since a wrong password might yield a key of invalid size.
+func decryptSymmetricKeyPacket(s *packet.SymmetricKeyEncrypted, password []byte) (*SessionKey, bool) {
+	key, cipherFunc, err := s.Decrypt(password)
+	if err != nil {
+		return nil, false
+	}
+	sk := &SessionKey{
+		Key:  key,
+		Algo: getAlgo(cipherFunc),
+		v6:   cipherFunc == 0, // for v6 there is not algorithm specified
+	}
+	if err = sk.checkSize(); !sk.v6 && err != nil {
+		return nil, false
+	}
+	return sk, true
+}
+
+// maxSymmetricKeyPacketAttempts bounds the number of salts that are tried to encrypt
+// a session key with a password in a v4 key packet that no other password decrypts.
+const maxSymmetricKeyPacketAttempts = 16
+
+// encryptSessionKeyWithPasswordsToWriter writes a key packet with the session key for each password.
+// Since v4 key packets are not authenticated, each v4 key packet is re-encrypted with a new salt
+// until none of the other passwords seemingly decrypts it, such that decryptSessionKeyWithPassword
+// returns the session key for each of the passwords.
+func encryptSessionKeyWithPasswordsToWriter(passwords [][]byte, sk *SessionKey, outputWriter io.Writer, config *packet.Config) error {
+	for index, password := range passwords {
+		var keyPacket bytes.Buffer
+		for attempt := 0; ; attempt++ {
+			if attempt == maxSymmetricKeyPacketAttempts {
+				return errors.New("gopenpgp: unable to encrypt session key with password: the key packet is ambiguous")
+			}
+			keyPacket.Reset()
+			if err := encryptSessionKeyWithPasswordToWriter(password, sk, &keyPacket, config); err != nil {
+				return err
+			}
+			if !isAmbiguousKeyPacket(keyPacket.Bytes(), passwords, index) {
+				break
 			}
 		}
+		if _, err := outputWriter.Write(keyPacket.Bytes()); err != nil {
+			return errors.Wrap(err, "gopenpgp: unable to encrypt session key with password")
+		}
 	}
+	return nil
+}
 
-	return nil, errors.New("gopenpgp: unable to decrypt any packet")
+// isAmbiguousKeyPacket reports whether the key packet is a v4 key packet that any password
+// other than the one at index seemingly decrypts.
+func isAmbiguousKeyPacket(keyPacket []byte, passwords [][]byte, index int) bool {
+	p, err := packet.Read(bytes.NewReader(keyPacket))
+	if err != nil {
+		return false
+	}
+	s, ok := p.(*packet.SymmetricKeyEncrypted)
+	if !ok || s.Version != 4 {
+		return false
+	}
+	for otherIndex, password := range passwords {
+		if otherIndex == index || bytes.Equal(password, passwords[index]) {
+			continue
+		}
+		if _, ok := decryptSymmetricKeyPacket(s, password); ok {
+			return true
+		}
+	}
+	return false
 }
 
 // encryptSessionKeyWithPassword encrypts the session key with the password and
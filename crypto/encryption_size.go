@@ -0,0 +1,147 @@
+package crypto
+
+import (
+	"bytes"
+	"encoding/base64"
+
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	armorHelper "github.com/ProtonMail/gopenpgp/v3/armor"
+	"github.com/ProtonMail/gopenpgp/v3/constants"
+	"github.com/pkg/errors"
+)
+
+// EstimateEncryptedSize returns an upper bound for the size in bytes of the pgp message
+// that results from encrypting a plaintext of plaintextLen bytes with the handle.
+// The plaintext itself is not required, the size of the key packets, signatures,
+// and the message metadata is determined by encrypting an empty plaintext.
+// The estimate includes the packet overhead and, if the encoding is Armor or Base64, the encoding expansion.
+// If a detached signature is created, its size is included in the estimate.
+// If compression is enabled, the estimate assumes that the plaintext is incompressible,
+// and for a Compressor, that it stores incompressible data with the overhead of ZIP.
+// Since the empty plaintext is signed with the signing keys, the estimate costs one signature
+// per signing key, e.g., an operation on a hardware or PKCS#11 token.
+// The observer, progress listener, and logger of the handle are not notified.
+func (eh *encryptionHandle) EstimateEncryptedSize(plaintextLen int64, encoding int8) (int64, error) {
+	if plaintextLen < 0 {
+		return 0, errors.New("gopenpgp: plaintext length must not be negative")
+	}
+	handle := *eh
+	handle.Observer = nil
+	handle.ProgressListener = nil
+	handle.Logger = nil
+	compress := eh.Compressor != nil || eh.selectCompression().DefaultCompressionAlgo != packet.CompressionNone
+	if compress && plaintextLen < int64(eh.CompressionThreshold) {
+		compress = false
+		handle.Compression = constants.NoCompression
+		handle.Compressor = nil
+	}
+	handle.CompressionThreshold = 0
+
+	var message, detachedSignature bytes.Buffer
+	ptWriter, err := handle.EncryptingWriter(
+		NewPGPSplitWriterDetachedSignature(&message, &detachedSignature),
+		Bytes,
+	)
+	if err != nil {
+		return 0, errors.Wrap(err, "gopenpgp: unable to estimate the encrypted size")
+	}
+	if err = ptWriter.Close(); err != nil {
+		return 0, errors.Wrap(err, "gopenpgp: unable to estimate the encrypted size")
+	}
+
+	// Overhead that grows with the plaintext, from the innermost to the outermost packet.
+	growth := plaintextLen
+	growth += partialLengthOverhead(growth)
+	if compress {
+		// Incompressible data is stored in blocks with a few bytes of overhead each.
+		growth += growth/1024 + 64
+		growth += partialLengthOverhead(growth)
+	}
+	chunkSize, err := aeadChunkSizeOfMessage(message.Bytes())
+	if err != nil {
+		return 0, errors.Wrap(err, "gopenpgp: unable to estimate the encrypted size")
+	}
+	if chunkSize > 0 {
+		// Each SEIPDv2 chunk carries an authentication tag.
+		growth += 16 * (growth/chunkSize + 1)
+	}
+	growth += partialLengthOverhead(growth)
+
+	if encoding == Base64 {
+		// Four characters for each started group of three bytes.
+		return (int64(message.Len())+growth+2)/3*4 + int64(base64.StdEncoding.EncodedLen(detachedSignature.Len())), nil
+	}
+	if !armorOutput(encoding) {
+		return int64(message.Len()+detachedSignature.Len()) + growth, nil
+	}
+	armorOptions := eh.armorOptions()
+	armoredMessageLen, err := armoredLen(message.Bytes(), constants.PGPMessageHeader, armorOptions)
+	if err != nil {
+		return 0, errors.Wrap(err, "gopenpgp: unable to estimate the encrypted size")
+	}
+	// Replace the base64 encoding of the empty message with the encoding of the full message.
+	lineLength := int64(armorOptions.LineLength)
+	if lineLength == 0 {
+		lineLength = armorHelper.DefaultLineLength
+	}
+	size := armoredMessageLen - base64Len(int64(message.Len()), lineLength) +
+		base64Len(int64(message.Len())+growth, lineLength) + 1
+	if detachedSignature.Len() > 0 {
+		signatureType := constants.PGPMessageHeader
+		if eh.PlainDetachedSignature && !eh.DetachedSignature {
+			signatureType = constants.PGPSignatureHeader
+		}
+		armoredSignatureLen, err := armoredLen(detachedSignature.Bytes(), signatureType, armorOptions)
+		if err != nil {
+			return 0, errors.Wrap(err, "gopenpgp: unable to estimate the encrypted size")
+		}
+		size += armoredSignatureLen
+	}
+	return size, nil
+}
+
+// aeadChunkSizeOfMessage returns the chunk size of the SEIPDv2 packet in the message,
+// or zero if the message is encrypted with a SEIPDv1 packet.
+func aeadChunkSizeOfMessage(message []byte) (int64, error) {
+	packets := packet.NewReader(bytes.NewReader(message))
+	for {
+		p, err := packets.Next()
+		if err != nil {
+			return 0, err
+		}
+		switch p := p.(type) {
+		case *packet.EncryptedKey, *packet.SymmetricKeyEncrypted:
+			continue
+		case *packet.SymmetricallyEncrypted:
+			if p.Version != 2 {
+				return 0, nil
+			}
+			return int64(1) << (p.ChunkSizeByte + 6), nil
+		default:
+			return 0, errors.New("gopenpgp: no encrypted data packet found")
+		}
+	}
+}
+
+// partialLengthOverhead returns an upper bound for the length headers of a packet
+// with the given content length that is written with partial lengths.
+// Each partial body is at least 512 bytes long and has a one byte length header,
+// the final body has a length header of at most five bytes.
+func partialLengthOverhead(contentLen int64) int64 {
+	return contentLen/512 + 6
+}
+
+// base64Len returns the length of the armored base64 encoding of dataLen bytes including line breaks.
+func base64Len(dataLen, lineLength int64) int64 {
+	encodedLen := 4 * ((dataLen + 2) / 3)
+	return encodedLen + encodedLen/lineLength
+}
+
+// armoredLen returns the length of the armored data.
+func armoredLen(data []byte, armorType string, options *armorHelper.Options) (int64, error) {
+	armored, err := armorHelper.ArmorWithOptions(data, armorType, options)
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(armored)), nil
+}
@@ -0,0 +1,68 @@
+package crypto
+
+import (
+	pgpErrors "github.com/ProtonMail/go-crypto/openpgp/errors"
+	"github.com/pkg/errors"
+)
+
+// Errors returned by the crypto package, to be matched with errors.Is instead of the
+// error message, e.g., errors.Is(err, crypto.ErrWrongPassphrase).
+// The returned errors keep their messages and causes, the errors below are only attached for matching.
+// Signature errors are further described by a SignatureVerificationError, see errors.As.
+var (
+	// ErrWrongPassphrase is returned if a key cannot be unlocked with the passphrase.
+	ErrWrongPassphrase = errors.New("gopenpgp: wrong passphrase")
+	// ErrWrongPassword is returned if none of the passwords decrypts a message.
+	ErrWrongPassword = errors.New("gopenpgp: wrong password")
+	// ErrNoDecryptionKey is returned if none of the decryption keys decrypts a message,
+	// e.g., if the message is not encrypted to any of them.
+	ErrNoDecryptionKey = errors.New("gopenpgp: no decryption key for the message")
+	// ErrMessageNotIntegrityProtected is returned if a message without modification detection code
+	// is decrypted without InsecureAllowMissingMDC, and by IntegrityWarning if it is decrypted with it.
+	ErrMessageNotIntegrityProtected = errors.New("gopenpgp: message is not integrity protected")
+	// ErrSignatureExpired is the cause of signature errors of expired signatures.
+	ErrSignatureExpired = pgpErrors.ErrSignatureExpired
+)
+
+// taggedError attaches an error of the package to an error, such that
+// errors.Is matches both, while the message of the error is unchanged.
+type taggedError struct {
+	error
+	tag error
+}
+
+func (e *taggedError) Is(target error) bool {
+	return target == e.tag
+}
+
+func (e *taggedError) Unwrap() error {
+	return e.error
+}
+
+// tagError attaches the tag to err.
+func tagError(err, tag error) error {
+	if err == nil {
+		return nil
+	}
+	return &taggedError{error: err, tag: tag}
+}
+
+// wrapKeyDecryptionError wraps an error of decrypting a message with private keys,
+// and attaches ErrNoDecryptionKey if applicable.
+func wrapKeyDecryptionError(err error, message string) error {
+	wrapped := errors.Wrap(err, message)
+	if errors.Is(err, pgpErrors.ErrKeyIncorrect) {
+		return tagError(wrapped, ErrNoDecryptionKey)
+	}
+	return wrapped
+}
+
+// isWrongPassphrase returns true if decrypting private keys failed because of the passphrase,
+// i.e., the decrypted secret material fails the checksum or authentication,
+// as opposed to unsupported or dummy private keys.
+func isWrongPassphrase(err error) bool {
+	var unsupported pgpErrors.UnsupportedError
+	var invalidArgument pgpErrors.InvalidArgumentError
+	var dummy pgpErrors.ErrDummyPrivateKey
+	return !errors.As(err, &unsupported) && !errors.As(err, &invalidArgument) && !errors.As(err, &dummy)
+}
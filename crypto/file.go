@@ -0,0 +1,203 @@
+package crypto
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/ProtonMail/gopenpgp/v3/constants"
+	"github.com/ProtonMail/gopenpgp/v3/internal"
+	"github.com/pkg/errors"
+)
+
+// FileEncryptionOptions defines optional settings for EncryptFile.
+type FileEncryptionOptions struct {
+	// SigningKeys provides unlocked keys to sign the file.
+	// If nil, the file is not signed.
+	SigningKeys *KeyRing
+	// PreserveMode indicates that the permission bits of the file are stored in
+	// the constants.FileModeName notation of the signature. Requires SigningKeys.
+	PreserveMode bool
+	// Armor indicates that the encrypted file is armored.
+	Armor bool
+}
+
+// FileDecryptionOptions defines optional settings for DecryptFile.
+type FileDecryptionOptions struct {
+	// VerificationKeys provides the keys to verify the signature of the file.
+	// If nil, the signature is not verified.
+	VerificationKeys *KeyRing
+	// RestoreMode indicates that the permission bits stored by EncryptFile with PreserveMode
+	// are applied to the decrypted file. They are only applied if the signature is valid.
+	RestoreMode bool
+}
+
+// EncryptFile encrypts the file at srcPath to the recipients and writes the pgp message to dstPath.
+// The file is streamed, and its name and modification time are stored in the literal data packet,
+// such that DecryptFile can restore them.
+// The output is written to a temporary file that replaces dstPath once the encryption succeeded.
+// The options can be nil.
+// Not supported on go-mobile clients.
+func (p *PGPHandle) EncryptFile(srcPath, dstPath string, recipients *KeyRing, options *FileEncryptionOptions) error {
+	if options == nil {
+		options = &FileEncryptionOptions{}
+	}
+	if options.PreserveMode && options.SigningKeys == nil {
+		return errors.New("gopenpgp: preserving the file mode requires signing keys")
+	}
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return errors.Wrap(err, "gopenpgp: unable to open file")
+	}
+	defer func() { _ = src.Close() }()
+	info, err := src.Stat()
+	if err != nil {
+		return errors.Wrap(err, "gopenpgp: unable to open file")
+	}
+	if !info.Mode().IsRegular() {
+		return errors.New("gopenpgp: can only encrypt regular files")
+	}
+
+	builder := p.Encryption().
+		Recipients(recipients).
+		PlainMetadata(NewFileMetadata(false, filepath.Base(srcPath), info.ModTime().Unix()))
+	if options.SigningKeys != nil {
+		builder.SigningKeys(options.SigningKeys)
+	}
+	if options.PreserveMode {
+		builder.SigningNotation(NewNotation(constants.FileModeName, fmt.Sprintf("%04o", info.Mode().Perm()), false))
+	}
+	encHandle, err := builder.New()
+	if err != nil {
+		return err
+	}
+	encoding := Bytes
+	if options.Armor {
+		encoding = Armor
+	}
+	return writeFileAtomically(dstPath, func(dst io.Writer) error {
+		ptWriter, err := encHandle.EncryptingWriter(dst, encoding)
+		if err != nil {
+			return err
+		}
+		if _, err := internal.Copy(ptWriter, src); err != nil {
+			return errors.Wrap(err, "gopenpgp: unable to encrypt file")
+		}
+		return ptWriter.Close()
+	})
+}
+
+// DecryptFile decrypts the pgp message in the file at srcPath with the decryption keys
+// and writes the plaintext to dstPath.
+// If dstPath is a directory, the plaintext is written to a file in the directory with
+// the filename stored in the message, e.g., by EncryptFile.
+// The stored modification time is restored, and the stored permission bits if
+// requested in the options. The options can be nil.
+// The plaintext is written to a temporary file that replaces the output file once the decryption succeeded,
+// i.e., the output file is not written if the message has been tampered with.
+// The returned VerifyResult contains the result of the signature verification,
+// and the output file is written even if the signature is not valid.
+// Not supported on go-mobile clients.
+func (p *PGPHandle) DecryptFile(srcPath, dstPath string, decryptionKeys *KeyRing, options *FileDecryptionOptions) (*VerifyResult, error) {
+	if options == nil {
+		options = &FileDecryptionOptions{}
+	}
+	builder := p.Decryption().DecryptionKeys(decryptionKeys)
+	if options.VerificationKeys != nil {
+		builder.VerificationKeys(options.VerificationKeys)
+	}
+	decHandle, err := builder.New()
+	if err != nil {
+		return nil, err
+	}
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: unable to open file")
+	}
+	defer func() { _ = src.Close() }()
+	ptReader, err := decHandle.DecryptingReader(src, Auto)
+	if err != nil {
+		return nil, err
+	}
+	metadata := ptReader.GetMetadata()
+	if info, err := os.Stat(dstPath); err == nil && info.IsDir() {
+		filename, err := storedFilename(metadata)
+		if err != nil {
+			return nil, err
+		}
+		dstPath = filepath.Join(dstPath, filename)
+	}
+
+	var verifyResult *VerifyResult
+	if err := writeFileAtomically(dstPath, func(dst io.Writer) error {
+		if _, err := internal.Copy(dst, ptReader); err != nil {
+			return errors.Wrap(err, "gopenpgp: unable to decrypt file")
+		}
+		result, err := ptReader.VerifySignature()
+		verifyResult = result
+		return err
+	}); err != nil {
+		return nil, err
+	}
+
+	if metadata != nil && metadata.ModTime > 0 {
+		modTime := time.Unix(metadata.ModTime, 0)
+		if err := os.Chtimes(dstPath, modTime, modTime); err != nil {
+			return nil, errors.Wrap(err, "gopenpgp: unable to restore the modification time")
+		}
+	}
+	if options.RestoreMode && verifyResult.SignatureError() == nil {
+		if notation := verifyResult.Notation(constants.FileModeName); notation != nil {
+			mode, err := strconv.ParseUint(notation.GetValueString(), 8, 32)
+			if err != nil {
+				return nil, errors.Wrap(err, "gopenpgp: invalid file mode notation")
+			}
+			if err := os.Chmod(dstPath, os.FileMode(mode).Perm()); err != nil {
+				return nil, errors.Wrap(err, "gopenpgp: unable to restore the file mode")
+			}
+		}
+	}
+	return verifyResult, nil
+}
+
+// storedFilename returns the filename of the literal data packet,
+// without any directories, such that it cannot point outside of the output directory.
+func storedFilename(metadata *LiteralMetadata) (string, error) {
+	if metadata == nil {
+		return "", errors.New("gopenpgp: the message does not contain a filename")
+	}
+	filename := filepath.Base(filepath.FromSlash(metadata.Filename()))
+	if filename == "." || filename == ".." || filename == string(filepath.Separator) {
+		return "", errors.New("gopenpgp: the message does not contain a valid filename")
+	}
+	return filename, nil
+}
+
+// writeFileAtomically calls write with a temporary file next to path,
+// and renames the temporary file to path if write succeeds.
+// The temporary file is removed otherwise.
+func writeFileAtomically(path string, write func(io.Writer) error) (err error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return errors.Wrap(err, "gopenpgp: unable to create file")
+	}
+	defer func() {
+		if err != nil {
+			_ = tmp.Close()
+			_ = os.Remove(tmp.Name())
+		}
+	}()
+	if err = write(tmp); err != nil {
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return errors.Wrap(err, "gopenpgp: unable to write file")
+	}
+	if err = os.Rename(tmp.Name(), path); err != nil {
+		return errors.Wrap(err, "gopenpgp: unable to write file")
+	}
+	return nil
+}
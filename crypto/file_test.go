@@ -0,0 +1,138 @@
+package crypto
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/ProtonMail/gopenpgp/v3/constants"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeTestFile(t *testing.T, dir string, content []byte, mode os.FileMode, modTime time.Time) string {
+	path := filepath.Join(dir, "document.txt")
+	if err := os.WriteFile(path, content, mode); err != nil {
+		t.Fatal("Cannot write test file:", err)
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		t.Fatal("Cannot change mode of test file:", err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal("Cannot change modification time of test file:", err)
+	}
+	return path
+}
+
+func TestEncryptDecryptFile(t *testing.T) {
+	dir := t.TempDir()
+	content := []byte(testMessage)
+	modTime := time.Unix(1600000000, 0)
+	srcPath := writeTestFile(t, dir, content, 0640, modTime)
+	encPath := filepath.Join(dir, "document.txt.gpg")
+
+	err := testPGP.EncryptFile(srcPath, encPath, keyRingTestPublic, &FileEncryptionOptions{
+		SigningKeys:  keyRingTestPrivate,
+		PreserveMode: true,
+	})
+	if err != nil {
+		t.Fatal("Cannot encrypt file:", err)
+	}
+
+	outDir := filepath.Join(dir, "out")
+	if err := os.Mkdir(outDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	verifyResult, err := testPGP.DecryptFile(encPath, outDir, keyRingTestPrivate, &FileDecryptionOptions{
+		VerificationKeys: keyRingTestPublic,
+		RestoreMode:      true,
+	})
+	if err != nil {
+		t.Fatal("Cannot decrypt file:", err)
+	}
+	assert.NoError(t, verifyResult.SignatureError())
+	assert.Equal(t, "0640", verifyResult.Notation(constants.FileModeName).GetValueString())
+
+	decPath := filepath.Join(outDir, "document.txt")
+	decrypted, err := os.ReadFile(decPath)
+	if err != nil {
+		t.Fatal("Cannot read decrypted file:", err)
+	}
+	assert.Equal(t, content, decrypted)
+	info, err := os.Stat(decPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, modTime.Unix(), info.ModTime().Unix())
+	if runtime.GOOS != "windows" {
+		assert.Equal(t, os.FileMode(0640), info.Mode().Perm())
+	}
+	entries, _ := os.ReadDir(outDir)
+	assert.Len(t, entries, 1)
+}
+
+func TestEncryptDecryptFileArmored(t *testing.T) {
+	dir := t.TempDir()
+	srcPath := writeTestFile(t, dir, []byte(testMessage), 0600, time.Unix(1600000000, 0))
+	encPath := filepath.Join(dir, "document.txt.asc")
+	if err := testPGP.EncryptFile(srcPath, encPath, keyRingTestPublic, &FileEncryptionOptions{Armor: true}); err != nil {
+		t.Fatal("Cannot encrypt file:", err)
+	}
+	encrypted, err := os.ReadFile(encPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Contains(t, string(encrypted), constants.PGPMessageHeader)
+
+	decPath := filepath.Join(dir, "decrypted.txt")
+	verifyResult, err := testPGP.DecryptFile(encPath, decPath, keyRingTestPrivate, nil)
+	if err != nil {
+		t.Fatal("Cannot decrypt file:", err)
+	}
+	assert.Error(t, verifyResult.SignatureError())
+	decrypted, err := os.ReadFile(decPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, testMessage, string(decrypted))
+}
+
+func TestDecryptFileTampered(t *testing.T) {
+	dir := t.TempDir()
+	srcPath := writeTestFile(t, dir, []byte(testMessage), 0600, time.Now())
+	encPath := filepath.Join(dir, "document.txt.gpg")
+	if err := testPGP.EncryptFile(srcPath, encPath, keyRingTestPublic, nil); err != nil {
+		t.Fatal("Cannot encrypt file:", err)
+	}
+	encrypted, err := os.ReadFile(encPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encrypted[len(encrypted)-5] ^= 1
+	if err := os.WriteFile(encPath, encrypted, 0600); err != nil {
+		t.Fatal(err)
+	}
+	decPath := filepath.Join(dir, "decrypted.txt")
+	_, err = testPGP.DecryptFile(encPath, decPath, keyRingTestPrivate, nil)
+	assert.Error(t, err)
+	_, err = os.Stat(decPath)
+	assert.True(t, os.IsNotExist(err))
+	entries, _ := os.ReadDir(dir)
+	assert.Len(t, entries, 2)
+
+	err = testPGP.EncryptFile(srcPath, encPath, keyRingTestPublic, &FileEncryptionOptions{PreserveMode: true})
+	assert.Error(t, err)
+}
+
+func TestStoredFilename(t *testing.T) {
+	filename, err := storedFilename(NewFileMetadata(false, "../../etc/passwd", 0))
+	assert.NoError(t, err)
+	assert.Equal(t, "passwd", filename)
+	_, err = storedFilename(NewFileMetadata(false, "", 0))
+	assert.Error(t, err)
+	_, err = storedFilename(NewFileMetadata(false, "..", 0))
+	assert.Error(t, err)
+	_, err = storedFilename(nil)
+	assert.Error(t, err)
+}
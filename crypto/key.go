@@ -3,6 +3,7 @@ package crypto
 import (
 	"bytes"
 	"crypto/sha256"
+	"encoding/binary"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
@@ -47,6 +48,8 @@ func NewKeyFromReaderExplicit(r io.Reader, encoding int8) (key *Key, err error)
 		armored = true
 	case Bytes:
 		armored = false
+	case Base64:
+		r = newBase64Reader(r)
 	default:
 		return nil, errors.New("gopenpgp: encoding is not supported")
 	}
@@ -127,7 +130,7 @@ func (key *Key) Copy() (*Key, error) {
 }
 
 // lock locks a copy of the key.
-func (key *Key) lock(passphrase []byte, profile KeyEncryptionProfile) (*Key, error) {
+func (key *Key) lock(passphrase []byte, config *packet.Config) (*Key, error) {
 	unlocked, err := key.IsUnlocked()
 	if err != nil {
 		return nil, err
@@ -146,7 +149,7 @@ func (key *Key) lock(passphrase []byte, profile KeyEncryptionProfile) (*Key, err
 		return lockedKey, nil
 	}
 
-	err = lockedKey.entity.EncryptPrivateKeys(passphrase, profile.KeyEncryptionConfig())
+	err = lockedKey.entity.EncryptPrivateKeys(passphrase, config)
 	if err != nil {
 		return nil, errors.Wrap(err, "gopenpgp: error in locking key")
 	}
@@ -183,7 +186,10 @@ func (key *Key) Unlock(passphrase []byte) (*Key, error) {
 
 	err = unlockedKey.entity.DecryptPrivateKeys(passphrase)
 	if err != nil {
-		return nil, errors.New("gopenpgp: error in unlocking key")
+		if isWrongPassphrase(err) {
+			return nil, tagError(errors.New("gopenpgp: error in unlocking key"), ErrWrongPassphrase)
+		}
+		return nil, errors.Wrap(err, "gopenpgp: error in unlocking key")
 	}
 
 	isUnlocked, err := unlockedKey.IsUnlocked()
@@ -200,20 +206,12 @@ func (key *Key) Unlock(passphrase []byte) (*Key, error) {
 // --- Export key
 
 func (key *Key) Serialize() ([]byte, error) {
-	var buffer bytes.Buffer
-	var err error
-
-	if key.entity.PrivateKey == nil {
-		err = key.entity.Serialize(&buffer)
-	} else {
-		err = key.entity.SerializePrivateWithoutSigning(&buffer, nil)
-	}
-
+	serialized, err := serializeEntity(key.entity)
 	if err != nil {
 		return nil, errors.Wrap(err, "gopenpgp: error in serializing key")
 	}
 
-	return buffer.Bytes(), nil
+	return serialized, nil
 }
 
 // Armor returns the armored key as a string with default gopenpgp headers.
@@ -241,6 +239,22 @@ func (key *Key) ArmorWithCustomHeaders(comment, version string) (string, error)
 	return armor.ArmorWithTypeAndCustomHeadersChecksum(serialized, constants.PrivateKeyHeader, version, comment, !key.isV6())
 }
 
+// ArmorWithOptions returns the armored key as a string, with the given
+// armor options, e.g., custom headers or line length.
+// For v6 keys the checksum is always omitted.
+// Not supported on go-mobile clients.
+func (key *Key) ArmorWithOptions(options *armor.Options) (string, error) {
+	serialized, err := key.Serialize()
+	if err != nil {
+		return "", err
+	}
+	armorType := constants.PublicKeyHeader
+	if key.IsPrivate() {
+		armorType = constants.PrivateKeyHeader
+	}
+	return key.armorWithOptions(serialized, armorType, options)
+}
+
 // GetArmoredPublicKey returns the armored public keys from this keyring.
 func (key *Key) GetArmoredPublicKey() (s string, err error) {
 	serialized, err := key.GetPublicKey()
@@ -262,6 +276,18 @@ func (key *Key) GetArmoredPublicKeyWithCustomHeaders(comment, version string) (s
 	return armor.ArmorWithTypeAndCustomHeadersChecksum(serialized, constants.PublicKeyHeader, version, comment, !key.isV6())
 }
 
+// GetArmoredPublicKeyWithOptions returns the armored public key as a string,
+// with the given armor options, e.g., custom headers or line length.
+// For v6 keys the checksum is always omitted.
+// Not supported on go-mobile clients.
+func (key *Key) GetArmoredPublicKeyWithOptions(options *armor.Options) (string, error) {
+	serialized, err := key.GetPublicKey()
+	if err != nil {
+		return "", err
+	}
+	return key.armorWithOptions(serialized, constants.PublicKeyHeader, options)
+}
+
 // GetPublicKey returns the unarmored public keys from this keyring.
 func (key *Key) GetPublicKey() (b []byte, err error) {
 	var outBuf bytes.Buffer
@@ -463,11 +489,7 @@ func (key *Key) readFrom(r io.Reader, armored bool) error {
 	var err error
 	var entities openpgp.EntityList
 
-	if armored {
-		entities, err = openpgp.ReadArmoredKeyRing(r)
-	} else {
-		entities, err = openpgp.ReadKeyRing(r)
-	}
+	entities, err = readKeyRing(r, armored)
 	if err != nil {
 		return errors.Wrap(err, "gopenpgp: error in reading key ring")
 	}
@@ -507,3 +529,36 @@ func generateKeyWithConfig(
 func keyIDToHex(keyID uint64) string {
 	return fmt.Sprintf("%016v", strconv.FormatUint(keyID, 16))
 }
+
+// keyIDFromHex parses a hex encoded key id, or derives the key id from
+// a hex encoded v4 or v6 fingerprint.
+func keyIDFromHex(hexID string) (uint64, error) {
+	id, err := hex.DecodeString(hexID)
+	if err != nil {
+		return 0, errors.Wrap(err, "gopenpgp: invalid hex key id")
+	}
+	switch len(id) {
+	case 8:
+		return binary.BigEndian.Uint64(id), nil
+	case 20:
+		// v4 key ids are the low 64 bits of the fingerprint
+		return binary.BigEndian.Uint64(id[12:]), nil
+	case 32:
+		// v6 key ids are the high 64 bits of the fingerprint
+		return binary.BigEndian.Uint64(id[:8]), nil
+	}
+	return 0, errors.New("gopenpgp: hex key id must be a key id or a v4 or v6 fingerprint")
+}
+
+func (key *Key) armorWithOptions(serialized []byte, armorType string, options *armor.Options) (string, error) {
+	keyOptions := armor.Options{}
+	if options != nil {
+		keyOptions = *options
+	}
+	keyOptions.OmitChecksum = keyOptions.OmitChecksum || key.isV6()
+	armored, err := armor.ArmorWithOptions(serialized, armorType, &keyOptions)
+	if err != nil {
+		return "", err
+	}
+	return string(armored), nil
+}
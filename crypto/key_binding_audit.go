@@ -0,0 +1,134 @@
+package crypto
+
+import (
+	"encoding/json"
+
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/ProtonMail/gopenpgp/v3/constants"
+)
+
+// SubkeyBindingAudit is the result of (Key).AuditSubkeyBindings for one subkey.
+type SubkeyBindingAudit struct {
+	// KeyID is the hex encoded key id of the subkey.
+	KeyID string `json:"keyId"`
+	// Valid is true if a binding signature of the subkey verifies, including
+	// its embedded signature for signing subkeys, such that the subkey is usable.
+	Valid bool `json:"valid"`
+	// Issues contains the codes of the issues found in the binding signatures
+	// of the subkey without duplicates, see constants.SubkeyBinding...
+	Issues []string `json:"issues"`
+}
+
+// SubkeyBindingReport lists the results of (Key).AuditSubkeyBindings.
+type SubkeyBindingReport struct {
+	// Subkeys contains an audit for each subkey in key order.
+	Subkeys []*SubkeyBindingAudit `json:"subkeys"`
+}
+
+// IsValid returns true if all subkeys have a valid binding signature and no issues were found.
+func (report *SubkeyBindingReport) IsValid() bool {
+	for _, audit := range report.Subkeys {
+		if !audit.Valid || len(audit.Issues) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// AuditSubkeyBindings verifies every binding signature of the subkeys and the embedded
+// primary key binding signatures, i.e., the cross-certifications of signing subkeys,
+// e.g., to review imported keys.
+// Unlike the selection of keys, which only needs one valid binding signature,
+// all binding signatures are checked regardless of time, expiration, and revocation.
+// Not supported on go-mobile clients use key.AuditSubkeyBindingsJson() instead.
+func (key *Key) AuditSubkeyBindings() *SubkeyBindingReport {
+	report := &SubkeyBindingReport{Subkeys: []*SubkeyBindingAudit{}}
+	primaryKey := key.entity.PrimaryKey
+	for _, subkey := range key.entity.Subkeys {
+		audit := &SubkeyBindingAudit{
+			KeyID:  keyIDToHex(subkey.PublicKey.KeyId),
+			Issues: []string{},
+		}
+		addIssue := func(code string) {
+			for _, issue := range audit.Issues {
+				if issue == code {
+					return
+				}
+			}
+			audit.Issues = append(audit.Issues, code)
+		}
+		if len(subkey.Bindings) == 0 {
+			addIssue(constants.SubkeyBindingMissing)
+		}
+		for _, binding := range subkey.Bindings {
+			sig := binding.Packet
+			if isWeakHash(sig) {
+				addIssue(constants.SubkeyBindingWeakHash)
+			}
+			if verifySubkeyBinding(primaryKey, primaryKey, subkey.PublicKey, sig) != nil {
+				addIssue(constants.SubkeyBindingInvalid)
+				continue
+			}
+			if !sig.FlagsValid || !sig.FlagSign {
+				audit.Valid = true
+				continue
+			}
+			embedded := sig.EmbeddedSignature
+			if embedded == nil {
+				addIssue(constants.SubkeyBindingMissingEmbeddedSignature)
+				continue
+			}
+			if isWeakHash(embedded) {
+				addIssue(constants.SubkeyBindingWeakHash)
+			}
+			if embedded.SigType != packet.SigTypePrimaryKeyBinding ||
+				verifySubkeyBinding(subkey.PublicKey, primaryKey, subkey.PublicKey, embedded) != nil {
+				addIssue(constants.SubkeyBindingInvalidEmbeddedSignature)
+				continue
+			}
+			audit.Valid = true
+		}
+		report.Subkeys = append(report.Subkeys, audit)
+	}
+	return report
+}
+
+// AuditSubkeyBindingsJson returns the subkey binding report of the key as JSON,
+// see AuditSubkeyBindings.
+// If an error occurs it returns nil.
+// Helper function for go-mobile clients.
+func (key *Key) AuditSubkeyBindingsJson() []byte {
+	report, err := json.Marshal(key.AuditSubkeyBindings())
+	if err != nil {
+		return nil
+	}
+	return report
+}
+
+// verifySubkeyBinding verifies the signature of the signer over the primary key and the subkey,
+// i.e., a binding signature if the signer is the primary key,
+// or an embedded primary key binding signature if the signer is the subkey.
+func verifySubkeyBinding(signer, primaryKey, subkey *packet.PublicKey, sig *packet.Signature) error {
+	preparedHash, err := sig.PrepareVerify()
+	if err != nil {
+		return err
+	}
+	if err := primaryKey.SerializeForHash(preparedHash); err != nil {
+		return err
+	}
+	if err := subkey.SerializeForHash(preparedHash); err != nil {
+		return err
+	}
+	return signer.VerifySignature(preparedHash, sig)
+}
+
+// isWeakHash returns true if the signature uses a hash algorithm
+// that is rejected by the DefaultAlgorithmPolicy.
+func isWeakHash(sig *packet.Signature) bool {
+	for _, hash := range DefaultAlgorithmPolicy().RejectedHashes {
+		if sig.Hash == hash {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,390 @@
+package crypto
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	openpgp "github.com/ProtonMail/go-crypto/openpgp/v2"
+	"github.com/ProtonMail/gopenpgp/v3/constants"
+	"github.com/pkg/errors"
+)
+
+// GnuPG stores secret keys without secret material as stubs with a GNU S2K specifier,
+// see doc/DETAILS of GnuPG: GNU dummy keys (protection mode 1) have no secret material at all,
+// and divert-to-card stubs (protection mode 2) are followed by the serial number of the
+// OpenPGP card that holds the secret material.
+// go-crypto only parses GNU dummy keys, hence divert-to-card stubs are read as GNU dummy keys
+// that remember the card serial number, and are written back as divert-to-card stubs.
+
+const (
+	packetTagSecretKey    = 5
+	packetTagPublicKey    = 6
+	packetTagSecretSubkey = 7
+	packetTagPublicSubkey = 14
+
+	gnuS2KModeDummy        = 1
+	gnuS2KModeDivertToCard = 2
+	// maxCardSerialNumberSize is the maximal size of a card serial number in a divert-to-card stub.
+	maxCardSerialNumberSize = 16
+)
+
+// cardStub is the private key of a GNU dummy key whose secret material lives on an OpenPGP card.
+type cardStub struct {
+	serialNumber []byte
+}
+
+// GetCardSerialNumbers returns the hex encoded serial numbers of the OpenPGP cards
+// that hold the secret material of the primary key and subkeys, by key id.
+// Keys whose secret material is not on a card are omitted.
+// Not supported on go-mobile clients use key.GetCardSerialNumbersJson() instead.
+func (key *Key) GetCardSerialNumbers() map[uint64]string {
+	serialNumbers := make(map[uint64]string)
+	forEachPrivateKey(key.entity, func(privateKey *packet.PrivateKey) {
+		if stub, ok := privateKey.PrivateKey.(*cardStub); ok {
+			serialNumbers[privateKey.KeyId] = hex.EncodeToString(stub.serialNumber)
+		}
+	})
+	return serialNumbers
+}
+
+// GetCardSerialNumbersJson returns the card serial numbers as a JSON object
+// that maps hex encoded key ids to hex encoded serial numbers, see GetCardSerialNumbers.
+// If an error occurs it returns nil.
+// Helper function for go-mobile clients.
+func (key *Key) GetCardSerialNumbersJson() []byte {
+	serialNumbers := make(map[string]string)
+	for keyID, serialNumber := range key.GetCardSerialNumbers() {
+		serialNumbers[keyIDToHex(keyID)] = serialNumber
+	}
+	serialized, err := json.Marshal(serialNumbers)
+	if err != nil {
+		return nil
+	}
+	return serialized
+}
+
+// ToCardStub returns a copy of the private key in which the secret material of the primary key
+// or subkey with keyID is replaced by a stub that refers to the OpenPGP card with the serial number,
+// e.g., after the secret material was moved to the card.
+// If serialNumber is empty, the secret material is removed without referring to a card,
+// i.e., the key becomes a GNU dummy key.
+// Not supported on go-mobile clients.
+func (key *Key) ToCardStub(keyID uint64, serialNumber []byte) (*Key, error) {
+	if !key.IsPrivate() {
+		return nil, errors.New("gopenpgp: a public key cannot be a card stub")
+	}
+	if len(serialNumber) > maxCardSerialNumberSize {
+		return nil, errors.New("gopenpgp: card serial number is too long")
+	}
+	stub, err := key.Copy()
+	if err != nil {
+		return nil, err
+	}
+	privateKeys := []**packet.PrivateKey{&stub.entity.PrivateKey}
+	for index := range stub.entity.Subkeys {
+		privateKeys = append(privateKeys, &stub.entity.Subkeys[index].PrivateKey)
+	}
+	for _, privateKey := range privateKeys {
+		if *privateKey == nil || (*privateKey).KeyId != keyID {
+			continue
+		}
+		dummy, err := privateKeyFromSecret(&(*privateKey).PublicKey, gnuDummySecret((*privateKey).Version))
+		if err != nil {
+			return nil, err
+		}
+		if len(serialNumber) > 0 {
+			dummy.PrivateKey = &cardStub{serialNumber: append([]byte(nil), serialNumber...)}
+		}
+		_ = clearPrivateKey((*privateKey).PrivateKey)
+		*privateKey = dummy
+		return stub, nil
+	}
+	return nil, errors.Errorf("gopenpgp: private key %s not found", keyIDToHex(keyID))
+}
+
+// readKeyRing reads the keys of an unarmored or armored key ring including divert-to-card stubs.
+func readKeyRing(r io.Reader, armored bool) (openpgp.EntityList, error) {
+	if armored {
+		block, err := armor.Decode(r)
+		if err != nil {
+			return nil, err
+		}
+		if block.Type != constants.PublicKeyHeader && block.Type != constants.PrivateKeyHeader {
+			return nil, errors.New("gopenpgp: expected public or private key block, got: " + block.Type)
+		}
+		r = block.Body
+	}
+	// go-crypto skips keys with divert-to-card stubs, hence the key ring is only read again
+	// with GNU dummy keys in their place if the data contains a divert-to-card S2K specifier.
+	var data bytes.Buffer
+	entities, err := openpgp.ReadKeyRing(io.TeeReader(r, &data))
+	if !bytes.Contains(data.Bytes(), []byte{'G', 'N', 'U', gnuS2KModeDivertToCard}) {
+		return entities, err
+	}
+	if _, err := io.Copy(&data, r); err != nil {
+		return nil, err
+	}
+	rewritten, serialNumbers := cardStubsToDummies(data.Bytes())
+	entities, err = openpgp.ReadKeyRing(bytes.NewReader(rewritten))
+	if err != nil {
+		return nil, err
+	}
+	for _, entity := range entities {
+		setCardSerialNumbers(entity, serialNumbers)
+	}
+	return entities, nil
+}
+
+// serializeEntity serializes the entity with its private keys, if any,
+// and writes its card stubs as divert-to-card stubs.
+func serializeEntity(entity *openpgp.Entity) ([]byte, error) {
+	var buffer bytes.Buffer
+	var err error
+	if entity.PrivateKey == nil {
+		err = entity.Serialize(&buffer)
+	} else {
+		err = entity.SerializePrivateWithoutSigning(&buffer, nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+	serialNumbers := cardSerialNumbers(entity)
+	if len(serialNumbers) == 0 {
+		return buffer.Bytes(), nil
+	}
+	return dummiesToCardStubs(buffer.Bytes(), serialNumbers)
+}
+
+// cardSerialNumbers returns the card serial numbers of the card stubs of the entity by hex fingerprint.
+func cardSerialNumbers(entity *openpgp.Entity) map[string][]byte {
+	serialNumbers := make(map[string][]byte)
+	forEachPrivateKey(entity, func(privateKey *packet.PrivateKey) {
+		if stub, ok := privateKey.PrivateKey.(*cardStub); ok {
+			serialNumbers[hex.EncodeToString(privateKey.Fingerprint)] = stub.serialNumber
+		}
+	})
+	return serialNumbers
+}
+
+// setCardSerialNumbers turns the GNU dummy keys of the entity with a card serial number
+// by hex fingerprint into card stubs.
+func setCardSerialNumbers(entity *openpgp.Entity, serialNumbers map[string][]byte) {
+	if len(serialNumbers) == 0 {
+		return
+	}
+	forEachPrivateKey(entity, func(privateKey *packet.PrivateKey) {
+		serialNumber, ok := serialNumbers[hex.EncodeToString(privateKey.Fingerprint)]
+		if ok && privateKey.Dummy() {
+			privateKey.PrivateKey = &cardStub{serialNumber: serialNumber}
+		}
+	})
+}
+
+func forEachPrivateKey(entity *openpgp.Entity, f func(privateKey *packet.PrivateKey)) {
+	if entity.PrivateKey != nil {
+		f(entity.PrivateKey)
+	}
+	for _, subkey := range entity.Subkeys {
+		if subkey.PrivateKey != nil {
+			f(subkey.PrivateKey)
+		}
+	}
+}
+
+// cardStubsToDummies replaces the divert-to-card stubs in the serialized keys by GNU dummy keys,
+// and returns the card serial numbers by hex fingerprint.
+// Malformed data is returned unchanged, such that go-crypto reports the error.
+func cardStubsToDummies(data []byte) ([]byte, map[string][]byte) {
+	serialNumbers := make(map[string][]byte)
+	rewritten, err := rewriteSecretKeyPackets(data, func(publicKey *packet.PublicKey, secret []byte) []byte {
+		mode, serialNumber, ok := parseGNUSecret(publicKey.Version, secret)
+		if !ok || mode != gnuS2KModeDivertToCard {
+			return nil
+		}
+		serialNumbers[hex.EncodeToString(publicKey.Fingerprint)] = serialNumber
+		return gnuDummySecret(publicKey.Version)
+	})
+	if err != nil || len(serialNumbers) == 0 {
+		return data, nil
+	}
+	return rewritten, serialNumbers
+}
+
+// dummiesToCardStubs replaces the GNU dummy keys in the serialized keys that have
+// a card serial number by hex fingerprint with divert-to-card stubs.
+func dummiesToCardStubs(data []byte, serialNumbers map[string][]byte) ([]byte, error) {
+	return rewriteSecretKeyPackets(data, func(publicKey *packet.PublicKey, secret []byte) []byte {
+		serialNumber, ok := serialNumbers[hex.EncodeToString(publicKey.Fingerprint)]
+		if !ok {
+			return nil
+		}
+		if mode, _, ok := parseGNUSecret(publicKey.Version, secret); !ok || mode != gnuS2KModeDummy {
+			return nil
+		}
+		return gnuSecret(publicKey.Version, gnuS2KModeDivertToCard, serialNumber)
+	})
+}
+
+// rewriteSecretKeyPackets replaces the secret part of each secret key packet in data
+// by the result of rewrite, unless it returns nil.
+// Rewritten packets have new format headers, the other packets are copied unchanged.
+func rewriteSecretKeyPackets(data []byte, rewrite func(publicKey *packet.PublicKey, secret []byte) []byte) ([]byte, error) {
+	var output bytes.Buffer
+	for remaining := data; len(remaining) > 0; {
+		tag, headerLength, bodyLength, err := parsePacketHeader(remaining)
+		if err != nil {
+			return nil, err
+		}
+		serialized := remaining[:headerLength+bodyLength]
+		remaining = remaining[headerLength+bodyLength:]
+		if tag != packetTagSecretKey && tag != packetTagSecretSubkey {
+			output.Write(serialized)
+			continue
+		}
+		publicKey, publicBody, err := parseSecretKeyPublicPart(tag, serialized[headerLength:])
+		if err != nil {
+			return nil, err
+		}
+		secret := rewrite(publicKey, serialized[headerLength+len(publicBody):])
+		if secret == nil {
+			output.Write(serialized)
+			continue
+		}
+		writePacketHeader(&output, tag, len(publicBody)+len(secret))
+		output.Write(publicBody)
+		output.Write(secret)
+	}
+	return output.Bytes(), nil
+}
+
+// parseSecretKeyPublicPart parses the public key at the start of the body of a secret key packet.
+func parseSecretKeyPublicPart(tag byte, body []byte) (*packet.PublicKey, []byte, error) {
+	publicTag := byte(packetTagPublicKey)
+	if tag == packetTagSecretSubkey {
+		publicTag = packetTagPublicSubkey
+	}
+	var serialized bytes.Buffer
+	writePacketHeader(&serialized, publicTag, len(body))
+	serialized.Write(body)
+	p, err := packet.Read(&serialized)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "gopenpgp: error in parsing public key")
+	}
+	publicKey, ok := p.(*packet.PublicKey)
+	if !ok {
+		return nil, nil, errors.New("gopenpgp: public key packet expected")
+	}
+	publicBody, err := publicKeyPacketBody(publicKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !bytes.HasPrefix(body, publicBody) {
+		return nil, nil, errors.New("gopenpgp: unexpected secret key packet")
+	}
+	return publicKey, publicBody, nil
+}
+
+// parsePacketHeader parses the old or new format packet header at the start of data,
+// and checks that data holds the whole packet body.
+// Packets of indeterminate or partial length are not supported.
+func parsePacketHeader(data []byte) (tag byte, headerLength, bodyLength int, err error) {
+	if len(data) < 2 || data[0]&0x80 == 0 {
+		return 0, 0, 0, errors.New("gopenpgp: invalid packet header")
+	}
+	// The length is kept in an uint64, such that it cannot overflow an int on 32-bit platforms.
+	var length uint64
+	if data[0]&0x40 == 0 {
+		// Old format: the two low bits of the tag octet encode the size of the length.
+		tag = (data[0] & 0x3f) >> 2
+		switch lengthType := data[0] & 3; {
+		case lengthType == 0:
+			headerLength, length = 2, uint64(data[1])
+		case lengthType == 1 && len(data) >= 3:
+			headerLength, length = 3, uint64(binary.BigEndian.Uint16(data[1:]))
+		case lengthType == 2 && len(data) >= 5:
+			headerLength, length = 5, uint64(binary.BigEndian.Uint32(data[1:]))
+		default:
+			return 0, 0, 0, errors.New("gopenpgp: unsupported packet length")
+		}
+	} else {
+		tag = data[0] & 0x3f
+		switch {
+		case data[1] < 192:
+			headerLength, length = 2, uint64(data[1])
+		case data[1] < 224 && len(data) >= 3:
+			headerLength, length = 3, (uint64(data[1])-192)<<8+uint64(data[2])+192
+		case data[1] == 255 && len(data) >= 6:
+			headerLength, length = 6, uint64(binary.BigEndian.Uint32(data[2:]))
+		default:
+			return 0, 0, 0, errors.New("gopenpgp: unsupported packet length")
+		}
+	}
+	if length > uint64(len(data)-headerLength) {
+		return 0, 0, 0, errors.New("gopenpgp: truncated packet")
+	}
+	return tag, headerLength, int(length), nil
+}
+
+// writePacketHeader writes a new format packet header with a five-octet length.
+func writePacketHeader(w *bytes.Buffer, tag byte, bodyLength int) {
+	var header [6]byte
+	header[0], header[1] = 0xc0|tag, 0xff
+	binary.BigEndian.PutUint32(header[2:], uint32(bodyLength))
+	w.Write(header[:])
+}
+
+// gnuDummySecret returns the secret part of a GNU dummy key, i.e., a key without secret material.
+func gnuDummySecret(version int) []byte {
+	return gnuSecret(version, gnuS2KModeDummy, nil)
+}
+
+// gnuSecret returns the secret part of a secret key packet with a GNU S2K specifier:
+// the S2K usage octet 254, the cipher 0, and the GNU S2K specifier with the protection mode,
+// which is followed by the length of the card serial number and the serial number for divert-to-card stubs.
+// v6 keys have additional octet counts of the optional fields and the S2K specifier.
+func gnuSecret(version int, mode byte, serialNumber []byte) []byte {
+	specifier := []byte{101, 0, 'G', 'N', 'U', mode}
+	if mode == gnuS2KModeDivertToCard {
+		specifier = append(append(specifier, byte(len(serialNumber))), serialNumber...)
+	}
+	if version == 6 {
+		return append([]byte{254, byte(2 + len(specifier)), 0, byte(len(specifier))}, specifier...)
+	}
+	return append([]byte{254, 0}, specifier...)
+}
+
+// parseGNUSecret returns the protection mode and, for divert-to-card stubs, the card serial number
+// of the secret part of a secret key packet with a GNU S2K specifier.
+// It returns false if the secret part has no GNU S2K specifier.
+func parseGNUSecret(version int, secret []byte) (mode byte, serialNumber []byte, ok bool) {
+	// The S2K usage octet and the cipher octet precede the S2K specifier,
+	// and v6 keys have the octet counts of the optional fields and the S2K specifier.
+	offset := 2
+	if version == 6 {
+		offset = 4
+	}
+	if len(secret) < offset+6 || (secret[0] != 254 && secret[0] != 255) {
+		return 0, nil, false
+	}
+	specifier := secret[offset:]
+	if specifier[0] != 101 || !bytes.Equal(specifier[2:5], []byte("GNU")) {
+		return 0, nil, false
+	}
+	mode = specifier[5]
+	if mode != gnuS2KModeDivertToCard {
+		return mode, nil, true
+	}
+	if len(specifier) < 7 {
+		return 0, nil, false
+	}
+	length := int(specifier[6])
+	if length > maxCardSerialNumberSize || len(specifier) < 7+length {
+		return 0, nil, false
+	}
+	return mode, append([]byte(nil), specifier[7:7+length]...), true
+}
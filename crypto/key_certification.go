@@ -0,0 +1,88 @@
+package crypto
+
+import (
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/ProtonMail/gopenpgp/v3/constants"
+	"github.com/pkg/errors"
+)
+
+// CertificationOptions configures the certification of the user id of another key,
+// see (*Key).CertifyUserId.
+// Not supported on go-mobile clients.
+type CertificationOptions struct {
+	// Level is the certification level from 0 to 3, which states how carefully
+	// the identity of the key holder was checked, as in GnuPG:
+	// 0 makes no claim, 1 did not check, 2 checked casually, and 3 checked extensively.
+	Level int8
+	// Local marks the certification as local, i.e., it is meant for the own key ring and
+	// is removed by (*Key).Minimize.
+	// Since go-crypto cannot write the exportable certification subpacket, the
+	// certification carries the critical notation constants.LocalCertificationName,
+	// such that other implementations ignore it.
+	Local bool
+	// LifetimeSecs is the lifetime of the certification in seconds,
+	// where zero means that the certification does not expire.
+	LifetimeSecs int32
+}
+
+// CertifyUserId returns a copy of the target key, in which the user id is certified
+// by the primary key of the key at unixTime, e.g., after verifying the identity
+// of the key holder in a key signing workflow.
+// The user id must match exactly, e.g., "name <email>".
+// If options is nil, the certification is exportable, has level 0, and does not expire.
+// The primary key must be unlocked.
+// Not supported on go-mobile clients.
+func (key *Key) CertifyUserId(target *Key, userId string, options *CertificationOptions, unixTime int64) (*Key, error) {
+	if !key.IsPrivate() || key.entity.PrivateKey.Encrypted {
+		return nil, errors.New("gopenpgp: the primary key must be unlocked to certify a user id")
+	}
+	if options == nil {
+		options = &CertificationOptions{}
+	}
+	if options.Level < 0 || options.Level > 3 {
+		return nil, errors.Errorf("gopenpgp: invalid certification level %d", options.Level)
+	}
+	if options.LifetimeSecs < 0 {
+		return nil, errors.New("gopenpgp: the certification lifetime must not be negative")
+	}
+	if key.entity.PrimaryKey.KeyId == target.entity.PrimaryKey.KeyId {
+		return nil, errors.New("gopenpgp: a key cannot certify its own user ids, use (*Key).AddUserId")
+	}
+	certifiedKey, err := target.Copy()
+	if err != nil {
+		return nil, err
+	}
+	identity, ok := certifiedKey.entity.Identities[userId]
+	if !ok {
+		return nil, errors.Errorf("gopenpgp: user id %q not found", userId)
+	}
+	config := key.managementConfig(unixTime)
+	sigType := packet.SignatureType(constants.SigTypeGenericCert + options.Level)
+	certification := newSelfSignature(key.entity.PrimaryKey, sigType, config)
+	lifetimeSecs := uint32(options.LifetimeSecs)
+	certification.SigLifetimeSecs = &lifetimeSecs
+	if options.Local {
+		certification.Notations = []*packet.Notation{{
+			Name:            constants.LocalCertificationName,
+			Value:           []byte{},
+			IsHumanReadable: true,
+			IsCritical:      true,
+		}}
+	}
+	if err := certification.SignUserId(userId, certifiedKey.entity.PrimaryKey, key.entity.PrivateKey, config); err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: error in certifying user id")
+	}
+	identity.OtherCertifications = append(identity.OtherCertifications, packet.NewVerifiableSig(certification))
+	return certifiedKey, nil
+}
+
+// isLocalCertification returns true if the certification is marked as local,
+// see CertificationOptions.
+func isLocalCertification(sig *packet.Signature) bool {
+	for _, notation := range sig.Notations {
+		if notation.Name == constants.LocalCertificationName {
+			return true
+		}
+	}
+	return false
+}
@@ -17,8 +17,15 @@ import (
 )
 
 // Clear zeroes the sensitive data in the session key.
+// If the key is stored in locked memory, the memory is released and Key is set to nil.
 func (sk *SessionKey) Clear() (ok bool) {
 	clearMem(sk.Key)
+	if sk.locked {
+		ok = freeLockedMemory(sk.Key) == nil
+		sk.Key = nil
+		sk.locked = false
+		return ok
+	}
 	return true
 }
 
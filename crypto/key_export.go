@@ -0,0 +1,94 @@
+package crypto
+
+import (
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	openpgp "github.com/ProtonMail/go-crypto/openpgp/v2"
+	"github.com/pkg/errors"
+)
+
+// KeyExportOptions selects the parts of a key that are kept by (Key).Minimize,
+// e.g., to produce minimal certificates for WKD or Autocrypt.
+// Not supported on go-mobile clients.
+type KeyExportOptions struct {
+	// StripThirdPartyCertifications removes the certifications of user ids by other keys.
+	StripThirdPartyCertifications bool
+	// UserId keeps only the user id that matches either the full user id,
+	// e.g., "name <email>", or its email address.
+	// If empty, all user ids are kept.
+	UserId string
+	// SubkeyIDs keeps only the subkeys with the given hex encoded key ids or fingerprints.
+	// If empty, all subkeys are kept.
+	SubkeyIDs []string
+}
+
+// Minimize returns a copy of the key that only contains the parts selected by the options.
+// The primary key, its direct-key signatures, and its revocations are always kept,
+// whereas local certifications of user ids are always removed, see CertificationOptions.
+// Private keys remain private, use (Key).GetPublicKey or (Key).GetArmoredPublicKey
+// to export the minimal certificate.
+// Not supported on go-mobile clients.
+func (key *Key) Minimize(options *KeyExportOptions) (*Key, error) {
+	newKey, err := key.Copy()
+	if err != nil {
+		return nil, err
+	}
+	entity := newKey.entity
+	for _, identity := range entity.Identities {
+		var certifications []*packet.VerifiableSignature
+		for _, certification := range identity.OtherCertifications {
+			if !isLocalCertification(certification.Packet) {
+				certifications = append(certifications, certification)
+			}
+		}
+		identity.OtherCertifications = certifications
+	}
+	if options == nil {
+		return newKey, nil
+	}
+	if options.UserId != "" {
+		identity := findIdentity(entity, options.UserId)
+		if identity == nil {
+			return nil, errors.Errorf("gopenpgp: user id %q not found", options.UserId)
+		}
+		entity.Identities = map[string]*openpgp.Identity{identity.Name: identity}
+	}
+	if options.StripThirdPartyCertifications {
+		for _, identity := range entity.Identities {
+			identity.OtherCertifications = nil
+		}
+	}
+	if len(options.SubkeyIDs) > 0 {
+		selected := make(map[uint64]bool, len(options.SubkeyIDs))
+		for _, hexID := range options.SubkeyIDs {
+			subkey, err := newKey.subkeyByHexID(hexID)
+			if err != nil {
+				return nil, err
+			}
+			selected[subkey.PublicKey.KeyId] = true
+		}
+		var subkeys []openpgp.Subkey
+		for _, subkey := range entity.Subkeys {
+			if selected[subkey.PublicKey.KeyId] {
+				subkeys = append(subkeys, subkey)
+			}
+		}
+		entity.Subkeys = subkeys
+	}
+	return newKey, nil
+}
+
+// findIdentity returns the identity with the given user id or email address.
+// Email addresses are compared case-insensitively.
+func findIdentity(entity *openpgp.Entity, userId string) *openpgp.Identity {
+	if identity, ok := entity.Identities[userId]; ok {
+		return identity
+	}
+	for _, identity := range sortedIdentities(entity) {
+		if identity.UserId != nil && strings.EqualFold(identity.UserId.Email, userId) {
+			return identity
+		}
+	}
+	return nil
+}
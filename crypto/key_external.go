@@ -0,0 +1,142 @@
+package crypto
+
+import (
+	"crypto"
+	"io"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/pkg/errors"
+)
+
+// ExternalPrivateKey is a private key that is held outside of the process,
+// e.g., in a hardware security module, a cloud key management service, or a remote signing service.
+// It must implement crypto.Signer to sign, crypto.Decrypter to decrypt, or both.
+// RSA keys can sign and decrypt, ECDSA keys on the NIST curves can sign.
+// Signers of ECDSA keys must return ASN.1 encoded signatures like crypto/ecdsa.
+// Not supported on go-mobile clients.
+type ExternalPrivateKey interface {
+	// Public returns the public key, e.g., a *rsa.PublicKey or a *ecdsa.PublicKey.
+	Public() crypto.PublicKey
+}
+
+// NewKeyWithExternalPrivateKeys returns a private key with the public key material of publicKey,
+// which delegates the private key operations of its primary key and subkeys
+// to the external private keys with the same public key.
+// Each external private key must match the primary key or a subkey.
+// Subkeys without an external private key are public, and the primary key is
+// a GNU dummy key without secret material if it has no external private key.
+// The returned key can be used to sign and decrypt, but its private key material
+// cannot be serialized, locked, or copied.
+// Not supported on go-mobile clients.
+func NewKeyWithExternalPrivateKeys(publicKey *Key, externalKeys ...ExternalPrivateKey) (*Key, error) {
+	if len(externalKeys) == 0 {
+		return nil, errors.New("gopenpgp: no external private key provided")
+	}
+	serialized, err := publicKey.GetPublicKey()
+	if err != nil {
+		return nil, err
+	}
+	key, err := NewKey(serialized)
+	if err != nil {
+		return nil, err
+	}
+	entity := key.entity
+	for _, externalKey := range externalKeys {
+		matched := false
+		if externalKeyMatches(entity.PrimaryKey, externalKey) {
+			entity.PrivateKey = newExternalPrivateKey(entity.PrimaryKey, externalKey)
+			matched = true
+		}
+		for index := range entity.Subkeys {
+			subkey := &entity.Subkeys[index]
+			if externalKeyMatches(subkey.PublicKey, externalKey) {
+				subkey.PrivateKey = newExternalPrivateKey(subkey.PublicKey, externalKey)
+				matched = true
+			}
+		}
+		if !matched {
+			return nil, errors.New("gopenpgp: external private key does not match the primary key or a subkey")
+		}
+	}
+	if entity.PrivateKey == nil {
+		if entity.PrivateKey, err = privateKeyFromSecret(entity.PrimaryKey, gnuDummySecret(entity.PrimaryKey.Version)); err != nil {
+			return nil, err
+		}
+	}
+	return key, nil
+}
+
+// NewKeyFromExternalPrivateKey creates a v4 key around an external private key,
+// which becomes the primary key that can sign and certify, with the given user id.
+// The user id is self-certified at creationTime with the external private key,
+// which must implement crypto.Signer.
+// Since the creation time is part of the OpenPGP fingerprint, the same creation time
+// must be used to recreate the same key, e.g., after a restart.
+// Not supported on go-mobile clients.
+func NewKeyFromExternalPrivateKey(externalKey ExternalPrivateKey, name, email string, creationTime int64) (*Key, error) {
+	if _, ok := externalKey.(crypto.Signer); !ok {
+		return nil, errors.New("gopenpgp: external private key cannot sign")
+	}
+	publicKey, err := publicKeyFromStandard(externalKey.Public(), time.Unix(creationTime, 0))
+	if err != nil {
+		return nil, err
+	}
+	if !externalKeyMatches(publicKey, externalKey) {
+		return nil, errors.Errorf("gopenpgp: key algorithm %d is not supported for external private keys", publicKey.PubKeyAlgo)
+	}
+	return newKeyFromPrimaryKey(newExternalPrivateKey(publicKey, externalKey), name, email, creationTime)
+}
+
+// externalKeyMatches returns true if the external key has the public key material of publicKey,
+// and go-crypto delegates the private key operations of the key algorithm to crypto.Signer or crypto.Decrypter.
+func externalKeyMatches(publicKey *packet.PublicKey, externalKey ExternalPrivateKey) bool {
+	switch publicKey.PubKeyAlgo {
+	case packet.PubKeyAlgoRSA, packet.PubKeyAlgoRSASignOnly, packet.PubKeyAlgoRSAEncryptOnly, packet.PubKeyAlgoECDSA:
+	default:
+		return false
+	}
+	cryptoPublicKey, err := standardPublicKey(publicKey)
+	if err != nil {
+		return false
+	}
+	comparable, ok := cryptoPublicKey.(interface{ Equal(crypto.PublicKey) bool })
+	return ok && comparable.Equal(externalKey.Public())
+}
+
+func newExternalPrivateKey(publicKey *packet.PublicKey, externalKey ExternalPrivateKey) *packet.PrivateKey {
+	return &packet.PrivateKey{
+		PublicKey:  *publicKey,
+		PrivateKey: externalSignerDecrypter{externalKey},
+	}
+}
+
+// externalSignerDecrypter implements crypto.Signer and crypto.Decrypter for every external key,
+// such that go-crypto returns an error instead of panicking if the external key lacks an operation.
+type externalSignerDecrypter struct {
+	ExternalPrivateKey
+}
+
+func (key externalSignerDecrypter) Sign(random io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	signer, ok := key.ExternalPrivateKey.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("gopenpgp: external private key cannot sign")
+	}
+	signature, err := signer.Sign(random, digest, opts)
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: external signing failed")
+	}
+	return signature, nil
+}
+
+func (key externalSignerDecrypter) Decrypt(random io.Reader, ciphertext []byte, opts crypto.DecrypterOpts) ([]byte, error) {
+	decrypter, ok := key.ExternalPrivateKey.(crypto.Decrypter)
+	if !ok {
+		return nil, errors.New("gopenpgp: external private key cannot decrypt")
+	}
+	plaintext, err := decrypter.Decrypt(random, ciphertext, opts)
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: external decryption failed")
+	}
+	return plaintext, nil
+}
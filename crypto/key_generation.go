@@ -12,8 +12,34 @@ const (
 	KeyGenerationCurve25519Legacy int = 2
 	// KeyGenerationCurve25519 allows to override the output key algorithm in key generation to curve25519 (as defined in RFC9580).
 	KeyGenerationCurve25519 int = 3
-	// KeyGenerationCurve448 allows to override the output key algorithm in key generation to curve448 (as defined in RFC9580).
+	// KeyGenerationCurve448 allows to override the output key algorithm in key generation to curve448 (as defined in RFC9580),
+	// i.e., an Ed448 signing key with an X448 encryption subkey. The key version is defined by the profile,
+	// e.g., the RFC9580 profile generates v6 keys.
 	KeyGenerationCurve448 int = 4
+	// KeyGenerationRSA2048 allows to override the output key algorithm in key generation to rsa 2048.
+	KeyGenerationRSA2048 int = 5
+	// KeyGenerationRSA3072 allows to override the output key algorithm in key generation to rsa 3072.
+	KeyGenerationRSA3072 int = 6
+	// KeyGenerationNistP256 allows to override the output key algorithm in key generation to ecdsa/ecdh with nist p-256.
+	KeyGenerationNistP256 int = 7
+	// KeyGenerationNistP384 allows to override the output key algorithm in key generation to ecdsa/ecdh with nist p-384.
+	KeyGenerationNistP384 int = 8
+	// KeyGenerationNistP521 allows to override the output key algorithm in key generation to ecdsa/ecdh with nist p-521.
+	KeyGenerationNistP521 int = 9
+	// KeyGenerationBrainpoolP256 allows to override the output key algorithm in key generation to ecdsa/ecdh with brainpool p-256.
+	KeyGenerationBrainpoolP256 int = 10
+	// KeyGenerationBrainpoolP384 allows to override the output key algorithm in key generation to ecdsa/ecdh with brainpool p-384.
+	KeyGenerationBrainpoolP384 int = 11
+	// KeyGenerationBrainpoolP512 allows to override the output key algorithm in key generation to ecdsa/ecdh with brainpool p-512.
+	KeyGenerationBrainpoolP512 int = 12
+)
+
+// Integer enum for go-mobile compatibility.
+const (
+	// KeyCapabilitySign indicates a subkey that creates signatures.
+	KeyCapabilitySign int = 1
+	// KeyCapabilityEncrypt indicates a subkey that decrypts messages.
+	KeyCapabilityEncrypt int = 2
 )
 
 type KeyGenerationProfile interface {
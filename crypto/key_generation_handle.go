@@ -11,12 +11,22 @@ type identity struct {
 	name, comment, email string
 }
 
+type subkeyOptions struct {
+	algorithm       int
+	capability      int
+	keyLifetimeSecs int32
+}
+
 type keyGenerationHandle struct {
 	identities        []identity
 	keyLifetimeSecs   uint32
 	overrideAlgorithm int
 	profile           KeyGenerationProfile
 	clock             Clock
+	// subkeys replace the default encryption subkey, if not empty.
+	subkeys []subkeyOptions
+	// designatedRevokers are the primary keys that may revoke the generated key.
+	designatedRevokers []*packet.PublicKey
 }
 
 // --- Default key generation handle to build from
@@ -40,6 +50,9 @@ func (kgh *keyGenerationHandle) GenerateKey() (key *Key, err error) {
 func (kgh *keyGenerationHandle) GenerateKeyWithSecurity(security int8) (key *Key, err error) {
 	config := kgh.profile.KeyGenerationConfig(security)
 	updateConfig(config, kgh.overrideAlgorithm)
+	if isStrictRFC9580(kgh.profile) && !config.V6() {
+		return nil, errors.New("gopenpgp: strict RFC9580 profile refuses to generate v4 keys")
+	}
 	config.Time = NewConstantClock(kgh.clock().Unix())
 	config.KeyLifetimeSecs = kgh.keyLifetimeSecs
 	key = &Key{}
@@ -83,9 +96,36 @@ func (kgh *keyGenerationHandle) GenerateKeyWithSecurity(security int8) (key *Key
 	if key.entity.PrivateKey == nil {
 		return nil, errors.New("gopenpgp: error in generating private key")
 	}
+	if len(kgh.subkeys) > 0 {
+		key.entity.Subkeys = nil
+		for _, subkey := range kgh.subkeys {
+			if err = kgh.addSubkey(key, subkey, security); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, revoker := range kgh.designatedRevokers {
+		if err = addDesignatedRevoker(key, revoker, config); err != nil {
+			return nil, err
+		}
+	}
 	return key, nil
 }
 
+func (kgh *keyGenerationHandle) addSubkey(key *Key, subkey subkeyOptions, security int8) error {
+	if subkey.keyLifetimeSecs < 0 {
+		return errors.New("gopenpgp: subkey lifetime must not be negative")
+	}
+	config := kgh.profile.KeyGenerationConfig(security)
+	updateConfig(config, kgh.overrideAlgorithm)
+	updateConfig(config, subkey.algorithm)
+	// The subkey has the same version as the primary key.
+	config.V6Keys = key.entity.PrimaryKey.Version == 6
+	config.Time = NewConstantClock(kgh.clock().Unix())
+	config.KeyLifetimeSecs = uint32(subkey.keyLifetimeSecs)
+	return addSubkey(key.entity, config, subkey.capability)
+}
+
 func (id identity) valid() error {
 	if len(id.email) == 0 && len(id.name) == 0 {
 		return errors.New("gopenpgp: neither name nor email set in user id")
@@ -106,5 +146,29 @@ func updateConfig(config *packet.Config, algorithm int) {
 		config.Algorithm = packet.PubKeyAlgoEd25519
 	case KeyGenerationCurve448:
 		config.Algorithm = packet.PubKeyAlgoEd448
+	case KeyGenerationRSA2048:
+		config.Algorithm = packet.PubKeyAlgoRSA
+		config.RSABits = 2048
+	case KeyGenerationRSA3072:
+		config.Algorithm = packet.PubKeyAlgoRSA
+		config.RSABits = 3072
+	case KeyGenerationNistP256:
+		config.Algorithm = packet.PubKeyAlgoECDSA
+		config.Curve = packet.CurveNistP256
+	case KeyGenerationNistP384:
+		config.Algorithm = packet.PubKeyAlgoECDSA
+		config.Curve = packet.CurveNistP384
+	case KeyGenerationNistP521:
+		config.Algorithm = packet.PubKeyAlgoECDSA
+		config.Curve = packet.CurveNistP521
+	case KeyGenerationBrainpoolP256:
+		config.Algorithm = packet.PubKeyAlgoECDSA
+		config.Curve = packet.CurveBrainpoolP256
+	case KeyGenerationBrainpoolP384:
+		config.Algorithm = packet.PubKeyAlgoECDSA
+		config.Curve = packet.CurveBrainpoolP384
+	case KeyGenerationBrainpoolP512:
+		config.Algorithm = packet.PubKeyAlgoECDSA
+		config.Curve = packet.CurveBrainpoolP512
 	}
 }
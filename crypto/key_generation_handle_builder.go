@@ -36,13 +36,38 @@ func (kgb *KeyGenerationBuilder) AddUserId(name, email string) *KeyGenerationBui
 // algorithm with the respective security level.
 //
 // Allowed inputs (integer enum for go-mobile compatibility):
-// crypto.KeyGenerationRSA4096, crypto.KeyGenerationC25519, crypto.KeyGenerationC25519Refresh
-// crypto.KeyGenerationC448, crypto.KeyGenerationC448Refresh.
+// crypto.KeyGenerationRSA2048, crypto.KeyGenerationRSA3072, crypto.KeyGenerationRSA4096,
+// crypto.KeyGenerationCurve25519Legacy, crypto.KeyGenerationCurve25519, crypto.KeyGenerationCurve448,
+// crypto.KeyGenerationNistP256, crypto.KeyGenerationNistP384, crypto.KeyGenerationNistP521,
+// crypto.KeyGenerationBrainpoolP256, crypto.KeyGenerationBrainpoolP384, crypto.KeyGenerationBrainpoolP512.
 func (kgb *KeyGenerationBuilder) OverrideProfileAlgorithm(algorithm int) *KeyGenerationBuilder {
 	kgb.handle.overrideAlgorithm = algorithm
 	return kgb
 }
 
+// AddSubkey adds a subkey with the given algorithm, capability, and lifetime in seconds
+// to any generated key. A lifetime of zero means infinite lifetime.
+// If subkeys are added, they replace the default encryption subkey.
+// The algorithm is one of the inputs of OverrideProfileAlgorithm,
+// or zero to use the algorithm of the primary key.
+// The capability is either crypto.KeyCapabilitySign or crypto.KeyCapabilityEncrypt.
+func (kgb *KeyGenerationBuilder) AddSubkey(algorithm, capability int, lifetimeSecs int32) *KeyGenerationBuilder {
+	kgb.handle.subkeys = append(kgb.handle.subkeys, subkeyOptions{
+		algorithm:       algorithm,
+		capability:      capability,
+		keyLifetimeSecs: lifetimeSecs,
+	})
+	return kgb
+}
+
+// AddDesignatedRevoker designates the primary key of the revoker to revoke any generated key,
+// see (*Key).RevokeAsDesignatedRevoker.
+// RFC 9580 deprecates designated revokers, thus key generation fails for v6 keys.
+func (kgb *KeyGenerationBuilder) AddDesignatedRevoker(revoker *Key) *KeyGenerationBuilder {
+	kgb.handle.designatedRevokers = append(kgb.handle.designatedRevokers, revoker.entity.PrimaryKey)
+	return kgb
+}
+
 // New creates a new key generation handle from the internal configuration
 // that allows to generate pgp keys.
 func (kgb *KeyGenerationBuilder) New() PGPKeyGeneration {
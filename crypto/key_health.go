@@ -0,0 +1,282 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	openpgp "github.com/ProtonMail/go-crypto/openpgp/v2"
+	"github.com/ProtonMail/gopenpgp/v3/constants"
+	"github.com/pkg/errors"
+)
+
+const (
+	// keyHealthExpiryWarningPeriod is the period before the expiration of a key
+	// in which the key is reported as expiring.
+	keyHealthExpiryWarningPeriod = 30 * 24 * time.Hour
+	// keyHealthMinRSABits is the minimal size of RSA keys that is not reported as weak.
+	keyHealthMinRSABits = 2048
+	// keyHealthMaxUnhashedAreaSize is the maximal size of the unhashed subpacket area
+	// of a signature in bytes that is not reported as oversized.
+	// Regular signatures only have an issuer key id in the unhashed area.
+	keyHealthMaxUnhashedAreaSize = 1024
+)
+
+// KeyHealthIssue is a problem of a key found by (Key).HealthReport.
+type KeyHealthIssue struct {
+	// Code identifies the kind of the issue, see constants.KeyHealth...
+	Code string `json:"code"`
+	// Severity is either constants.KeyHealthWarning or constants.KeyHealthError.
+	Severity int8 `json:"severity"`
+	// KeyID is the hex encoded key id of the primary key or subkey the issue concerns.
+	KeyID string `json:"keyId"`
+	// UserID is the user id the issue concerns, if any.
+	UserID string `json:"userId,omitempty"`
+	// Message describes the issue for humans.
+	Message string `json:"message"`
+}
+
+// KeyHealthReport lists the issues found by (Key).HealthReport.
+type KeyHealthReport struct {
+	// Issues contains the found issues, or is empty if the key is healthy.
+	Issues []*KeyHealthIssue `json:"issues"`
+}
+
+// IsHealthy returns true if no issues were found.
+func (report *KeyHealthReport) IsHealthy() bool {
+	return len(report.Issues) == 0
+}
+
+// HasErrors returns true if an issue with severity constants.KeyHealthError was found.
+func (report *KeyHealthReport) HasErrors() bool {
+	for _, issue := range report.Issues {
+		if issue.Severity == constants.KeyHealthError {
+			return true
+		}
+	}
+	return false
+}
+
+// HasIssue returns true if an issue with the given code was found, see constants.KeyHealth...
+func (report *KeyHealthReport) HasIssue(code string) bool {
+	for _, issue := range report.Issues {
+		if issue.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+func (report *KeyHealthReport) add(code string, severity int8, keyID uint64, userID, format string, args ...interface{}) {
+	report.Issues = append(report.Issues, &KeyHealthIssue{
+		Code:     code,
+		Severity: severity,
+		KeyID:    keyIDToHex(keyID),
+		UserID:   userID,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// HealthReport checks the key for weak algorithms, SHA-1 self-signatures,
+// signing subkeys without cross-certification, expired or soon expiring keys,
+// a missing encryption key, and signatures with oversized unhashed areas at unixTime.
+// Keys are reported as expiring within 30 days before their expiration.
+// Revoked subkeys and user ids are not checked.
+// Not supported on go-mobile clients use key.HealthReportJson() instead.
+func (key *Key) HealthReport(unixTime int64) *KeyHealthReport {
+	report := &KeyHealthReport{Issues: []*KeyHealthIssue{}}
+	entity := key.entity
+	primaryKey := entity.PrimaryKey
+	current := time.Unix(unixTime, 0)
+
+	if entity.Revoked(current) {
+		report.add(constants.KeyHealthRevokedKey, constants.KeyHealthError, primaryKey.KeyId, "",
+			"the key is revoked")
+	}
+	checkKeyAlgorithm(report, primaryKey)
+	if primarySelfSignature, err := entity.PrimarySelfSignature(time.Time{}, nil); err == nil {
+		checkKeyExpiration(report, primaryKey, primarySelfSignature, current,
+			constants.KeyHealthExpiredKey, constants.KeyHealthExpiringKey, "key")
+	}
+	if directSignature, err := entity.LatestValidDirectSignature(time.Time{}, nil); err == nil {
+		checkSelfSignatureHash(report, primaryKey, directSignature, "", "direct-key signature")
+	}
+	for _, identity := range sortedIdentities(entity) {
+		selfCertification, err := identity.Verify(time.Time{}, nil)
+		if err != nil {
+			continue
+		}
+		checkSelfSignatureHash(report, primaryKey, selfCertification, identity.Name, "self-certification")
+	}
+	for index := range entity.Subkeys {
+		subkey := &entity.Subkeys[index]
+		binding, err := subkey.LatestValidBindingSignature(time.Time{}, nil)
+		if err != nil {
+			if latest := latestSignature(subkey.Bindings); latest != nil && latest.FlagSign && latest.EmbeddedSignature == nil {
+				report.add(constants.KeyHealthMissingCrossCertification, constants.KeyHealthError, subkey.PublicKey.KeyId, "",
+					"the signing subkey is not cross-certified by an embedded signature")
+			}
+			continue
+		}
+		if subkey.Revoked(binding, current) {
+			continue
+		}
+		checkKeyAlgorithm(report, subkey.PublicKey)
+		checkKeyExpiration(report, subkey.PublicKey, binding, current,
+			constants.KeyHealthExpiredSubkey, constants.KeyHealthExpiringSubkey, "subkey")
+		checkSelfSignatureHash(report, subkey.PublicKey, binding, "", "binding signature")
+		if binding.FlagSign && binding.EmbeddedSignature != nil {
+			checkSelfSignatureHash(report, subkey.PublicKey, binding.EmbeddedSignature, "", "cross-certification")
+		}
+	}
+	if !key.CanEncrypt(unixTime) {
+		report.add(constants.KeyHealthNoEncryptionSubkey, constants.KeyHealthError, primaryKey.KeyId, "",
+			"the key has no valid encryption key")
+	}
+	checkUnhashedAreas(report, key)
+	return report
+}
+
+// HealthReportJson returns the health report of the key as JSON, see HealthReport.
+// If an error occurs it returns nil.
+// Helper function for go-mobile clients.
+func (key *Key) HealthReportJson(unixTime int64) []byte {
+	report, err := json.Marshal(key.HealthReport(unixTime))
+	if err != nil {
+		return nil
+	}
+	return report
+}
+
+func checkKeyAlgorithm(report *KeyHealthReport, publicKey *packet.PublicKey) {
+	switch publicKey.PubKeyAlgo {
+	case packet.PubKeyAlgoDSA, packet.PubKeyAlgoElGamal:
+		report.add(constants.KeyHealthWeakAlgorithm, constants.KeyHealthError, publicKey.KeyId, "",
+			"the key uses the deprecated algorithm %d", publicKey.PubKeyAlgo)
+	case packet.PubKeyAlgoRSA, packet.PubKeyAlgoRSASignOnly, packet.PubKeyAlgoRSAEncryptOnly:
+		bits, err := publicKey.BitLength()
+		if err == nil && bits < keyHealthMinRSABits {
+			report.add(constants.KeyHealthWeakAlgorithm, constants.KeyHealthError, publicKey.KeyId, "",
+				"the key is a %d bit RSA key", bits)
+		}
+	}
+}
+
+func checkKeyExpiration(
+	report *KeyHealthReport,
+	publicKey *packet.PublicKey,
+	selfSignature *packet.Signature,
+	current time.Time,
+	expiredCode, expiringCode, keyName string,
+) {
+	expiredAt := func(date time.Time) bool {
+		return publicKey.KeyExpired(selfSignature, date) || selfSignature.SigExpired(date)
+	}
+	switch {
+	case expiredAt(current):
+		report.add(expiredCode, constants.KeyHealthError, publicKey.KeyId, "",
+			"the %s is expired", keyName)
+	case expiredAt(current.Add(keyHealthExpiryWarningPeriod)):
+		report.add(expiringCode, constants.KeyHealthWarning, publicKey.KeyId, "",
+			"the %s expires within %d days", keyName, keyHealthExpiryWarningPeriod/(24*time.Hour))
+	}
+}
+
+func checkSelfSignatureHash(report *KeyHealthReport, publicKey *packet.PublicKey, sig *packet.Signature, userID, sigName string) {
+	if sig.Hash == crypto.SHA1 {
+		report.add(constants.KeyHealthSHA1SelfSignature, constants.KeyHealthWarning, publicKey.KeyId, userID,
+			"the %s uses SHA-1", sigName)
+	}
+}
+
+// checkUnhashedAreas reports all signatures on the key,
+// including third-party certifications and revocations, with oversized unhashed areas.
+func checkUnhashedAreas(report *KeyHealthReport, key *Key) {
+	entity := key.entity
+	check := func(keyID uint64, userID string, signatures []*packet.VerifiableSignature) {
+		for _, signature := range signatures {
+			size, err := unhashedAreaSize(signature.Packet)
+			if err != nil || size <= keyHealthMaxUnhashedAreaSize {
+				continue
+			}
+			report.add(constants.KeyHealthOversizedUnhashedArea, constants.KeyHealthWarning, keyID, userID,
+				"a signature of type %d has an unhashed area of %d bytes", signature.Packet.SigType, size)
+		}
+	}
+	primaryKeyID := entity.PrimaryKey.KeyId
+	check(primaryKeyID, "", entity.Revocations)
+	check(primaryKeyID, "", entity.DirectSignatures)
+	for _, identity := range sortedIdentities(entity) {
+		check(primaryKeyID, identity.Name, identity.SelfCertifications)
+		check(primaryKeyID, identity.Name, identity.OtherCertifications)
+		check(primaryKeyID, identity.Name, identity.Revocations)
+	}
+	for _, subkey := range entity.Subkeys {
+		check(subkey.PublicKey.KeyId, "", subkey.Bindings)
+		check(subkey.PublicKey.KeyId, "", subkey.Revocations)
+	}
+}
+
+// unhashedAreaSize returns the size of the unhashed subpacket area of the signature,
+// which go-crypto does not expose, by reading it from the serialized signature.
+func unhashedAreaSize(sig *packet.Signature) (int, error) {
+	var serialized bytes.Buffer
+	if err := sig.Serialize(&serialized); err != nil {
+		return 0, errors.Wrap(err, "gopenpgp: serializing signature failed")
+	}
+	encoded := serialized.Bytes()
+	_, offset, _, err := parsePacketHeader(encoded)
+	if err != nil {
+		return 0, err
+	}
+	// The serialized signature starts with the hashed fields in HashSuffix,
+	// followed by the length of the unhashed area.
+	// The lengths of both areas have four bytes in v6 signatures and two bytes otherwise.
+	readLength := func(b []byte) int {
+		if sig.Version == 6 {
+			return int(binary.BigEndian.Uint32(b))
+		}
+		return int(binary.BigEndian.Uint16(b))
+	}
+	lengthSize := 2
+	if sig.Version == 6 {
+		lengthSize = 4
+	}
+	if len(sig.HashSuffix) < 4+lengthSize {
+		return 0, errors.New("gopenpgp: invalid signature packet")
+	}
+	offset += 4 + lengthSize + readLength(sig.HashSuffix[4:])
+	if len(encoded) < offset+lengthSize {
+		return 0, errors.New("gopenpgp: invalid signature packet")
+	}
+	return readLength(encoded[offset:]), nil
+}
+
+// sortedIdentities returns the identities of the entity ordered by user id,
+// such that reports are deterministic.
+func sortedIdentities(entity *openpgp.Entity) []*openpgp.Identity {
+	identities := make([]*openpgp.Identity, 0, len(entity.Identities))
+	for _, identity := range entity.Identities {
+		identities = append(identities, identity)
+	}
+	sort.Slice(identities, func(i, j int) bool {
+		return identities[i].Name < identities[j].Name
+	})
+	return identities
+}
+
+// latestSignature returns the most recent signature, regardless of its validity.
+func latestSignature(signatures []*packet.VerifiableSignature) *packet.Signature {
+	var latest *packet.Signature
+	for _, signature := range signatures {
+		if latest == nil || !signature.Packet.CreationTime.Before(latest.CreationTime) {
+			latest = signature.Packet
+		}
+	}
+	return latest
+}
@@ -0,0 +1,268 @@
+package crypto
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// jsonWebKey is a JSON Web Key as defined in RFC 7517,
+// with the key parameters of RFC 7518 and RFC 8037.
+// All binary values are base64url encoded without padding.
+type jsonWebKey struct {
+	KeyType string `json:"kty"`
+	KeyID   string `json:"kid,omitempty"`
+	Curve   string `json:"crv,omitempty"`
+	// X and Y are the coordinates of EC keys, and X is the public key of OKP keys.
+	X string `json:"x,omitempty"`
+	Y string `json:"y,omitempty"`
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// D is the private exponent of RSA keys, the scalar of EC keys, and the seed of OKP keys.
+	D  string `json:"d,omitempty"`
+	P  string `json:"p,omitempty"`
+	Q  string `json:"q,omitempty"`
+	DP string `json:"dp,omitempty"`
+	DQ string `json:"dq,omitempty"`
+	QI string `json:"qi,omitempty"`
+}
+
+// NewKeyFromJWK creates a v4 OpenPGP key from a private JSON Web Key, such that
+// the same key material can be used for JOSE and OpenPGP signatures.
+// OKP keys on Ed25519, RSA keys with their primes, and EC keys on the NIST curves are supported.
+// The JWK becomes the primary key, which can sign and certify, with the given user id.
+// Since the creation time is part of the OpenPGP fingerprint, converting the same JWK
+// with the same creation time results in the same OpenPGP key.
+// The returned key is unlocked.
+func NewKeyFromJWK(jwk []byte, name, email string, creationTime int64) (*Key, error) {
+	var webKey jsonWebKey
+	if err := json.Unmarshal(jwk, &webKey); err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: error in parsing jwk")
+	}
+	if webKey.D == "" {
+		return nil, errors.New("gopenpgp: the jwk does not contain a private key")
+	}
+	standardKey, err := webKey.privateKey()
+	if err != nil {
+		return nil, err
+	}
+	privateKey, err := privateKeyFromStandard(standardKey, time.Unix(creationTime, 0))
+	if err != nil {
+		return nil, err
+	}
+	return newKeyFromPrimaryKey(privateKey, name, email, creationTime)
+}
+
+// GetPublicJWK returns the primary key as public JSON Web Key,
+// with the hex encoded fingerprint as key id.
+// Ed25519, RSA, and ECDSA keys on the NIST curves are supported.
+func (key *Key) GetPublicJWK() ([]byte, error) {
+	publicKey, err := standardPublicKey(key.entity.PrimaryKey)
+	if err != nil {
+		return nil, err
+	}
+	webKey, err := jsonWebKeyFromPublic(publicKey)
+	if err != nil {
+		return nil, err
+	}
+	return key.marshalJWK(webKey)
+}
+
+// GetJWK returns the primary key as private JSON Web Key,
+// with the hex encoded fingerprint as key id.
+// Ed25519, RSA, and ECDSA keys on the NIST curves are supported.
+// The key must be unlocked.
+func (key *Key) GetJWK() ([]byte, error) {
+	unlocked, err := key.IsUnlocked()
+	if err != nil {
+		return nil, err
+	}
+	if !unlocked {
+		return nil, errors.New("gopenpgp: the key must be unlocked to export it to jwk")
+	}
+	privateKey, err := standardPrivateKey(key.entity.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	var webKey *jsonWebKey
+	switch standardKey := privateKey.(type) {
+	case *rsa.PrivateKey:
+		if webKey, err = jsonWebKeyFromPublic(&standardKey.PublicKey); err != nil {
+			return nil, err
+		}
+		standardKey.Precompute()
+		webKey.D = encodeJWKInt(standardKey.D)
+		if len(standardKey.Primes) == 2 {
+			webKey.P = encodeJWKInt(standardKey.Primes[0])
+			webKey.Q = encodeJWKInt(standardKey.Primes[1])
+			webKey.DP = encodeJWKInt(standardKey.Precomputed.Dp)
+			webKey.DQ = encodeJWKInt(standardKey.Precomputed.Dq)
+			webKey.QI = encodeJWKInt(standardKey.Precomputed.Qinv)
+		}
+	case *ecdsa.PrivateKey:
+		if webKey, err = jsonWebKeyFromPublic(&standardKey.PublicKey); err != nil {
+			return nil, err
+		}
+		webKey.D = encodeJWKBytes(standardKey.D.FillBytes(make([]byte, curveByteSize(standardKey.Curve))))
+	case ed25519.PrivateKey:
+		if webKey, err = jsonWebKeyFromPublic(standardKey.Public()); err != nil {
+			return nil, err
+		}
+		webKey.D = encodeJWKBytes(standardKey.Seed())
+	default:
+		return nil, errors.Errorf("gopenpgp: unsupported private key type %T", privateKey)
+	}
+	return key.marshalJWK(webKey)
+}
+
+func (key *Key) marshalJWK(webKey *jsonWebKey) ([]byte, error) {
+	webKey.KeyID = hex.EncodeToString(key.entity.PrimaryKey.Fingerprint)
+	jwk, err := json.Marshal(webKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: error in encoding jwk")
+	}
+	return jwk, nil
+}
+
+func jsonWebKeyFromPublic(publicKey interface{}) (*jsonWebKey, error) {
+	switch standardKey := publicKey.(type) {
+	case *rsa.PublicKey:
+		return &jsonWebKey{
+			KeyType: "RSA",
+			N:       encodeJWKInt(standardKey.N),
+			E:       encodeJWKInt(big.NewInt(int64(standardKey.E))),
+		}, nil
+	case *ecdsa.PublicKey:
+		size := curveByteSize(standardKey.Curve)
+		return &jsonWebKey{
+			KeyType: "EC",
+			Curve:   standardKey.Curve.Params().Name,
+			X:       encodeJWKBytes(standardKey.X.FillBytes(make([]byte, size))),
+			Y:       encodeJWKBytes(standardKey.Y.FillBytes(make([]byte, size))),
+		}, nil
+	case ed25519.PublicKey:
+		return &jsonWebKey{
+			KeyType: "OKP",
+			Curve:   "Ed25519",
+			X:       encodeJWKBytes(standardKey),
+		}, nil
+	}
+	return nil, errors.Errorf("gopenpgp: unsupported public key type %T", publicKey)
+}
+
+// privateKey decodes the private key of the standard library
+// and checks that it matches the public parameters.
+func (webKey *jsonWebKey) privateKey() (interface{}, error) {
+	switch webKey.KeyType {
+	case "RSA":
+		var n, e, d, p, q big.Int
+		for _, parameter := range []struct {
+			value   *big.Int
+			encoded string
+		}{{&n, webKey.N}, {&e, webKey.E}, {&d, webKey.D}, {&p, webKey.P}, {&q, webKey.Q}} {
+			if parameter.encoded == "" {
+				return nil, errors.New("gopenpgp: incomplete rsa jwk, the primes are required")
+			}
+			decoded, err := decodeJWKBytes(parameter.encoded)
+			if err != nil {
+				return nil, err
+			}
+			parameter.value.SetBytes(decoded)
+		}
+		if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, errors.New("gopenpgp: invalid rsa exponent in jwk")
+		}
+		privateKey := &rsa.PrivateKey{
+			PublicKey: rsa.PublicKey{N: &n, E: int(e.Int64())},
+			D:         &d,
+			Primes:    []*big.Int{&p, &q},
+		}
+		if err := privateKey.Validate(); err != nil {
+			return nil, errors.Wrap(err, "gopenpgp: invalid rsa jwk")
+		}
+		privateKey.Precompute()
+		return privateKey, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch webKey.Curve {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, errors.Errorf("gopenpgp: unsupported jwk curve %s", webKey.Curve)
+		}
+		size := curveByteSize(curve)
+		var coordinates [3][]byte
+		for i, encoded := range []string{webKey.X, webKey.Y, webKey.D} {
+			decoded, err := decodeJWKBytes(encoded)
+			if err != nil {
+				return nil, err
+			}
+			if len(decoded) != size {
+				return nil, errors.New("gopenpgp: invalid ec jwk")
+			}
+			coordinates[i] = decoded
+		}
+		privateKey := &ecdsa.PrivateKey{D: new(big.Int).SetBytes(coordinates[2])}
+		privateKey.Curve = curve
+		//nolint:staticcheck // crypto/ecdh is not available in the supported Go versions
+		privateKey.X, privateKey.Y = curve.ScalarBaseMult(coordinates[2])
+		if privateKey.X.Cmp(new(big.Int).SetBytes(coordinates[0])) != 0 ||
+			privateKey.Y.Cmp(new(big.Int).SetBytes(coordinates[1])) != 0 {
+			return nil, errors.New("gopenpgp: the private key of the ec jwk does not match its public key")
+		}
+		return privateKey, nil
+	case "OKP":
+		if webKey.Curve != "Ed25519" {
+			return nil, errors.Errorf("gopenpgp: unsupported jwk curve %s", webKey.Curve)
+		}
+		seed, err := decodeJWKBytes(webKey.D)
+		if err != nil {
+			return nil, err
+		}
+		publicKey, err := decodeJWKBytes(webKey.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(seed) != ed25519.SeedSize {
+			return nil, errors.New("gopenpgp: invalid ed25519 jwk")
+		}
+		privateKey := ed25519.NewKeyFromSeed(seed)
+		if !privateKey.Public().(ed25519.PublicKey).Equal(ed25519.PublicKey(publicKey)) {
+			return nil, errors.New("gopenpgp: the private key of the ed25519 jwk does not match its public key")
+		}
+		return privateKey, nil
+	}
+	return nil, errors.Errorf("gopenpgp: unsupported jwk key type %s", webKey.KeyType)
+}
+
+func curveByteSize(curve elliptic.Curve) int {
+	return (curve.Params().BitSize + 7) / 8
+}
+
+func encodeJWKInt(value *big.Int) string {
+	return encodeJWKBytes(value.Bytes())
+}
+
+func encodeJWKBytes(value []byte) string {
+	return base64.RawURLEncoding.EncodeToString(value)
+}
+
+func decodeJWKBytes(encoded string) ([]byte, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: error in decoding jwk parameter")
+	}
+	return decoded, nil
+}
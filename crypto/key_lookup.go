@@ -0,0 +1,56 @@
+package crypto
+
+import (
+	"github.com/ProtonMail/gopenpgp/v3/constants"
+	"github.com/pkg/errors"
+)
+
+// KeyLookup fetches the public key of a signer that is not among the verification keys,
+// e.g., from a key server or a database.
+// Not supported on go-mobile clients.
+type KeyLookup interface {
+	// LookupKey returns the key with the given key id, or nil if the key is unknown.
+	// The fingerprint is nil if the signature does not include the issuer fingerprint.
+	LookupKey(keyID uint64, fingerprint []byte) (*Key, error)
+}
+
+// KeyLookupFunc is an adapter to use a function as KeyLookup.
+// Not supported on go-mobile clients.
+type KeyLookupFunc func(keyID uint64, fingerprint []byte) (*Key, error)
+
+// LookupKey calls f(keyID, fingerprint).
+func (f KeyLookupFunc) LookupKey(keyID uint64, fingerprint []byte) (*Key, error) {
+	return f(keyID, fingerprint)
+}
+
+// lookupUnknownSigners looks up the keys of all signatures in the result that have no verifier.
+// It returns the verification keys extended by the found keys,
+// or nil if no key was found.
+func lookupUnknownSigners(lookup KeyLookup, verifyKeyRing *KeyRing, result *VerifyResult) (*KeyRing, error) {
+	keyRing := &KeyRing{}
+	keyRing.entities = append(keyRing.entities, verifyKeyRing.getEntities()...)
+	found := false
+	for _, signature := range result.Signatures {
+		if signature.Signature == nil ||
+			signature.Signature.IssuerKeyId == nil ||
+			signature.SignatureError == nil ||
+			signature.SignatureError.Status != constants.SIGNATURE_NO_VERIFIER {
+			continue
+		}
+		key, err := lookup.LookupKey(*signature.Signature.IssuerKeyId, signature.Signature.IssuerFingerprint)
+		if err != nil {
+			return nil, errors.Wrap(err, "gopenpgp: key lookup failed")
+		}
+		if key == nil {
+			continue
+		}
+		if err := keyRing.AddKey(key); err != nil {
+			return nil, errors.Wrap(err, "gopenpgp: key lookup failed")
+		}
+		found = true
+	}
+	if !found {
+		return nil, nil
+	}
+	return keyRing, nil
+}
@@ -0,0 +1,448 @@
+package crypto
+
+import (
+	"crypto"
+	"math"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	openpgp "github.com/ProtonMail/go-crypto/openpgp/v2"
+	"github.com/ProtonMail/gopenpgp/v3/constants"
+	"github.com/pkg/errors"
+)
+
+// AddSubkey returns a copy of the key with a new subkey, which is bound to the key
+// with a binding signature of the primary key created at unixTime.
+// The algorithm is one of the inputs of (KeyGenerationBuilder).OverrideProfileAlgorithm,
+// or zero to use the algorithm of the primary key.
+// The capability is either KeyCapabilitySign or KeyCapabilityEncrypt.
+// The lifetime is given in seconds, where zero means infinite lifetime.
+// The primary key must be unlocked.
+func (key *Key) AddSubkey(algorithm, capability int, lifetimeSecs int32, unixTime int64) (*Key, error) {
+	if lifetimeSecs < 0 {
+		return nil, errors.New("gopenpgp: subkey lifetime must not be negative")
+	}
+	newKey, err := key.copyForModification()
+	if err != nil {
+		return nil, err
+	}
+	config, err := subkeyConfigFromPublicKey(newKey.entity.PrimaryKey)
+	if err != nil {
+		return nil, err
+	}
+	updateConfig(config, algorithm)
+	// The subkey has the same version as the primary key.
+	config.V6Keys = newKey.entity.PrimaryKey.Version == 6
+	config.Time = NewConstantClock(unixTime)
+	config.KeyLifetimeSecs = uint32(lifetimeSecs)
+	if err := addSubkey(newKey.entity, config, capability); err != nil {
+		return nil, err
+	}
+	return newKey, nil
+}
+
+// RevokeSubkey returns a copy of the key, in which the subkey with the given
+// hex encoded key id or fingerprint is revoked at unixTime.
+// The reason is a reason code, see constants.Revocation..., and reasonText
+// describes the reason for humans.
+// The primary key must be unlocked.
+func (key *Key) RevokeSubkey(hexID string, reason int8, reasonText string, unixTime int64) (*Key, error) {
+	newKey, err := key.copyForModification()
+	if err != nil {
+		return nil, err
+	}
+	subkey, err := newKey.subkeyByHexID(hexID)
+	if err != nil {
+		return nil, err
+	}
+	config := newKey.managementConfig(unixTime)
+	if err := subkey.Revoke(packet.NewReasonForRevocation(byte(reason)), reasonText, config); err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: error in revoking subkey")
+	}
+	return newKey, nil
+}
+
+// RotateSubkey returns a copy of the key, in which the subkey with the given
+// hex encoded key id or fingerprint is revoked as superseded at unixTime,
+// and replaced by a new subkey with the same algorithm and capability.
+// The lifetime of the new subkey is given in seconds, where zero means infinite lifetime.
+// The primary key must be unlocked.
+func (key *Key) RotateSubkey(hexID string, lifetimeSecs int32, unixTime int64) (*Key, error) {
+	if lifetimeSecs < 0 {
+		return nil, errors.New("gopenpgp: subkey lifetime must not be negative")
+	}
+	newKey, err := key.copyForModification()
+	if err != nil {
+		return nil, err
+	}
+	subkey, err := newKey.subkeyByHexID(hexID)
+	if err != nil {
+		return nil, err
+	}
+	binding, err := subkey.LatestValidBindingSignature(time.Time{}, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: subkey has no valid binding signature")
+	}
+	var capability int
+	switch {
+	case binding.FlagsValid && binding.FlagSign:
+		capability = KeyCapabilitySign
+	case binding.FlagsValid && (binding.FlagEncryptCommunications || binding.FlagEncryptStorage):
+		capability = KeyCapabilityEncrypt
+	default:
+		return nil, errors.New("gopenpgp: subkey can neither sign nor encrypt")
+	}
+	config, err := subkeyConfigFromPublicKey(subkey.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	config.V6Keys = newKey.entity.PrimaryKey.Version == 6
+	config.Time = NewConstantClock(unixTime)
+	config.KeyLifetimeSecs = uint32(lifetimeSecs)
+	reason := packet.NewReasonForRevocation(byte(constants.RevocationKeySuperseded))
+	if err := subkey.Revoke(reason, "", config); err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: error in revoking subkey")
+	}
+	if err := addSubkey(newKey.entity, config, capability); err != nil {
+		return nil, err
+	}
+	return newKey, nil
+}
+
+// AddUserId returns a copy of the key with a new user id of the form "name <email>",
+// which is certified by the primary key at unixTime.
+// The certification carries the key properties of the current primary user id,
+// e.g., the key expiration and algorithm preferences.
+// The primary key must be unlocked.
+func (key *Key) AddUserId(name, email string, unixTime int64) (*Key, error) {
+	if err := (identity{name: name, email: email}).valid(); err != nil {
+		return nil, err
+	}
+	newKey, err := key.copyForModification()
+	if err != nil {
+		return nil, err
+	}
+	uid := packet.NewUserId(name, "", email)
+	if uid == nil {
+		return nil, errors.New("gopenpgp: user id contains invalid characters")
+	}
+	if _, ok := newKey.entity.Identities[uid.Id]; ok {
+		return nil, errors.Errorf("gopenpgp: user id %q already exists", uid.Id)
+	}
+	config := newKey.managementConfig(unixTime)
+	var selfSignature *packet.Signature
+	if primarySignature, _ := newKey.entity.PrimaryIdentity(config.Now(), config); primarySignature != nil {
+		if selfSignature, err = reissueSelfSignature(primarySignature, config); err != nil {
+			return nil, err
+		}
+	} else {
+		selfSignature = newSelfSignature(newKey.entity.PrimaryKey, packet.SigTypePositiveCert, config)
+	}
+	isPrimaryId := len(newKey.entity.Identities) == 0
+	selfSignature.IsPrimaryId = &isPrimaryId
+	if err := selfSignature.SignUserId(uid.Id, newKey.entity.PrimaryKey, newKey.entity.PrivateKey, config); err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: error in certifying user id")
+	}
+	newKey.entity.Identities[uid.Id] = &openpgp.Identity{
+		Primary:            newKey.entity,
+		Name:               uid.Id,
+		UserId:             uid,
+		SelfCertifications: []*packet.VerifiableSignature{packet.NewVerifiableSig(selfSignature)},
+	}
+	return newKey, nil
+}
+
+// RevokeUserId returns a copy of the key, in which the user id is revoked at unixTime.
+// The user id must match exactly, e.g., "name <email>".
+// The reason is a reason code, see constants.Revocation..., and reasonText
+// describes the reason for humans.
+// The primary key must be unlocked.
+func (key *Key) RevokeUserId(userId string, reason int8, reasonText string, unixTime int64) (*Key, error) {
+	newKey, err := key.copyForModification()
+	if err != nil {
+		return nil, err
+	}
+	identity, ok := newKey.entity.Identities[userId]
+	if !ok {
+		return nil, errors.Errorf("gopenpgp: user id %q not found", userId)
+	}
+	config := newKey.managementConfig(unixTime)
+	revocation := newSelfSignature(newKey.entity.PrimaryKey, packet.SigTypeCertificationRevocation, config)
+	revocationReason := packet.NewReasonForRevocation(byte(reason))
+	revocation.RevocationReason = &revocationReason
+	revocation.RevocationReasonText = reasonText
+	if err := revocation.SignUserId(userId, newKey.entity.PrimaryKey, newKey.entity.PrivateKey, config); err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: error in revoking user id")
+	}
+	identity.Revocations = append(identity.Revocations, packet.NewVerifiableSig(revocation))
+	return newKey, nil
+}
+
+// SetPrimaryUserId returns a copy of the key, in which the user id is marked as primary
+// with a new certification created at unixTime.
+// Other user ids that are marked as primary are certified anew without the mark.
+// The user id must match exactly, e.g., "name <email>".
+// The primary key must be unlocked.
+func (key *Key) SetPrimaryUserId(userId string, unixTime int64) (*Key, error) {
+	newKey, err := key.copyForModification()
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := newKey.entity.Identities[userId]; !ok {
+		return nil, errors.Errorf("gopenpgp: user id %q not found", userId)
+	}
+	config := newKey.managementConfig(unixTime)
+	for _, identity := range newKey.entity.Identities {
+		selfSignature, err := identity.Verify(config.Now(), config)
+		if err != nil {
+			if identity.Name == userId {
+				return nil, errors.Wrap(err, "gopenpgp: user id is not valid")
+			}
+			continue
+		}
+		isPrimaryId := identity.Name == userId
+		wasPrimaryId := selfSignature.IsPrimaryId != nil && *selfSignature.IsPrimaryId
+		if !isPrimaryId && !wasPrimaryId {
+			continue
+		}
+		if selfSignature, err = reissueSelfSignature(selfSignature, config); err != nil {
+			return nil, err
+		}
+		selfSignature.IsPrimaryId = &isPrimaryId
+		if err := selfSignature.SignUserId(identity.Name, newKey.entity.PrimaryKey, newKey.entity.PrivateKey, config); err != nil {
+			return nil, errors.Wrap(err, "gopenpgp: error in certifying user id")
+		}
+		identity.SelfCertifications = append(identity.SelfCertifications, packet.NewVerifiableSig(selfSignature))
+	}
+	return newKey, nil
+}
+
+// SetExpiration returns a copy of the key, in which the self-signatures of the primary key
+// and of the given subkeys are re-issued at unixTime with a new expiration.
+// The primary key expires the primary duration after unixTime,
+// and each subkey in subkeys expires the mapped duration after unixTime,
+// where the subkeys are indexed by key id. A zero duration means that the key never expires.
+// Subkeys that are not in subkeys keep their expiration.
+// The primary key must be unlocked.
+// Not supported on go-mobile clients.
+func (key *Key) SetExpiration(primary time.Duration, subkeys map[uint64]time.Duration, unixTime int64) (*Key, error) {
+	newKey, err := key.copyForModification()
+	if err != nil {
+		return nil, err
+	}
+	config := newKey.managementConfig(unixTime)
+	entity := newKey.entity
+	keyLifetimeSecs, err := keyLifetimeAt(entity.PrimaryKey, primary, unixTime)
+	if err != nil {
+		return nil, err
+	}
+	if entity.PrimaryKey.Version == 6 {
+		// The key properties of v6 keys are stored in the direct-key signature.
+		selfSignature, err := entity.LatestValidDirectSignature(config.Now(), config)
+		if err != nil {
+			return nil, errors.Wrap(err, "gopenpgp: key has no valid direct-key signature")
+		}
+		if selfSignature, err = reissueSelfSignature(selfSignature, config); err != nil {
+			return nil, err
+		}
+		selfSignature.KeyLifetimeSecs = &keyLifetimeSecs
+		if err := selfSignature.SignDirectKeyBinding(entity.PrimaryKey, entity.PrivateKey, config); err != nil {
+			return nil, errors.Wrap(err, "gopenpgp: error in signing direct-key signature")
+		}
+		entity.DirectSignatures = append(entity.DirectSignatures, packet.NewVerifiableSig(selfSignature))
+	} else {
+		// The key properties of v4 keys are stored in the self-certifications of the user ids.
+		for _, identity := range entity.Identities {
+			selfSignature, err := identity.Verify(config.Now(), config)
+			if err != nil {
+				continue
+			}
+			if selfSignature, err = reissueSelfSignature(selfSignature, config); err != nil {
+				return nil, err
+			}
+			selfSignature.KeyLifetimeSecs = &keyLifetimeSecs
+			if err := selfSignature.SignUserId(identity.Name, entity.PrimaryKey, entity.PrivateKey, config); err != nil {
+				return nil, errors.Wrap(err, "gopenpgp: error in certifying user id")
+			}
+			identity.SelfCertifications = append(identity.SelfCertifications, packet.NewVerifiableSig(selfSignature))
+		}
+	}
+	for keyID, duration := range subkeys {
+		subkey, err := newKey.subkeyByID(keyID)
+		if err != nil {
+			return nil, err
+		}
+		keyLifetimeSecs, err := keyLifetimeAt(subkey.PublicKey, duration, unixTime)
+		if err != nil {
+			return nil, err
+		}
+		binding, err := subkey.LatestValidBindingSignature(config.Now(), config)
+		if err != nil {
+			return nil, errors.Wrap(err, "gopenpgp: subkey has no valid binding signature")
+		}
+		if binding, err = reissueSelfSignature(binding, config); err != nil {
+			return nil, err
+		}
+		// The embedded back signature of signing subkeys remains valid.
+		binding.KeyLifetimeSecs = &keyLifetimeSecs
+		if err := binding.SignKey(subkey.PublicKey, entity.PrivateKey, config); err != nil {
+			return nil, errors.Wrap(err, "gopenpgp: error in signing subkey binding")
+		}
+		subkey.Bindings = append(subkey.Bindings, packet.NewVerifiableSig(binding))
+	}
+	return newKey, nil
+}
+
+// --- Private key management logic
+
+// copyForModification returns a copy of the key that can be modified
+// with self-signatures of the primary key.
+func (key *Key) copyForModification() (*Key, error) {
+	if !key.IsPrivate() || key.entity.PrivateKey.Encrypted {
+		return nil, errors.New("gopenpgp: the primary key must be unlocked to modify the key")
+	}
+	return key.Copy()
+}
+
+// subkeyByHexID returns the subkey with the given hex encoded key id or fingerprint.
+func (key *Key) subkeyByHexID(hexID string) (*openpgp.Subkey, error) {
+	keyID, err := keyIDFromHex(hexID)
+	if err != nil {
+		return nil, err
+	}
+	return key.subkeyByID(keyID)
+}
+
+// subkeyByID returns the subkey with the given key id.
+func (key *Key) subkeyByID(keyID uint64) (*openpgp.Subkey, error) {
+	for index := range key.entity.Subkeys {
+		if key.entity.Subkeys[index].PublicKey.KeyId == keyID {
+			return &key.entity.Subkeys[index], nil
+		}
+	}
+	return nil, errors.Errorf("gopenpgp: no subkey with id %016x found", keyID)
+}
+
+// subkeyConfigFromPublicKey returns a key generation config
+// for a subkey with the same algorithm as the public key.
+func subkeyConfigFromPublicKey(publicKey *packet.PublicKey) (*packet.Config, error) {
+	config := &packet.Config{
+		Algorithm: publicKey.PubKeyAlgo,
+		V6Keys:    publicKey.Version == 6,
+	}
+	switch publicKey.PubKeyAlgo {
+	case packet.PubKeyAlgoRSA, packet.PubKeyAlgoRSASignOnly, packet.PubKeyAlgoRSAEncryptOnly:
+		bitLength, err := publicKey.BitLength()
+		if err != nil {
+			return nil, errors.Wrap(err, "gopenpgp: unable to determine the key length")
+		}
+		config.Algorithm = packet.PubKeyAlgoRSA
+		config.RSABits = int(bitLength)
+	case packet.PubKeyAlgoECDSA, packet.PubKeyAlgoECDH, packet.PubKeyAlgoEdDSA:
+		curve, err := publicKey.Curve()
+		if err != nil {
+			return nil, errors.Wrap(err, "gopenpgp: unable to determine the key curve")
+		}
+		config.Curve = curve
+	}
+	return config, nil
+}
+
+// addSubkey adds a new subkey with the given capability to the entity.
+func addSubkey(entity *openpgp.Entity, config *packet.Config, capability int) error {
+	var err error
+	switch capability {
+	case KeyCapabilitySign:
+		err = entity.AddSigningSubkey(config)
+	case KeyCapabilityEncrypt:
+		err = entity.AddEncryptionSubkey(config)
+	default:
+		return errors.Errorf("gopenpgp: unknown subkey capability %d", capability)
+	}
+	if err != nil {
+		return errors.Wrap(err, "gopenpgp: error in adding subkey")
+	}
+	return nil
+}
+
+// managementConfig returns the config to create self-signatures at unixTime.
+func (key *Key) managementConfig(unixTime int64) *packet.Config {
+	return &packet.Config{
+		Time:   NewConstantClock(unixTime),
+		V6Keys: key.entity.PrimaryKey.Version == 6,
+	}
+}
+
+// newSelfSignature creates an unsigned signature of the primary key with the given type.
+func newSelfSignature(primaryKey *packet.PublicKey, sigType packet.SignatureType, config *packet.Config) *packet.Signature {
+	sigLifetimeSecs := config.SigLifetime()
+	return &packet.Signature{
+		Version:           primaryKey.Version,
+		SigType:           sigType,
+		PubKeyAlgo:        primaryKey.PubKeyAlgo,
+		Hash:              selfSignatureHash(primaryKey, config.Hash()),
+		CreationTime:      config.Now(),
+		IssuerKeyId:       &primaryKey.KeyId,
+		IssuerFingerprint: primaryKey.Fingerprint,
+		SigLifetimeSecs:   &sigLifetimeSecs,
+	}
+}
+
+// reissueSelfSignature returns an unsigned copy of the self-signature
+// that is created at the config time.
+func reissueSelfSignature(selfSignature *packet.Signature, config *packet.Config) (*packet.Signature, error) {
+	reissued := *selfSignature
+	reissued.CreationTime = config.Now()
+	if reissued.Version == 6 {
+		// The copy must not reuse the salt of the original signature.
+		salt, err := packet.SignatureSaltForHash(reissued.Hash, config.Random())
+		if err != nil {
+			return nil, errors.Wrap(err, "gopenpgp: error in generating signature salt")
+		}
+		if err := reissued.SetSalt(salt); err != nil {
+			return nil, errors.Wrap(err, "gopenpgp: error in generating signature salt")
+		}
+	}
+	return &reissued, nil
+}
+
+// selfSignatureHash returns the hash algorithm for self-signatures of the primary key,
+// which is at least as strong as the key.
+func selfSignatureHash(primaryKey *packet.PublicKey, hashAlgorithm crypto.Hash) crypto.Hash {
+	minSize := crypto.SHA256.Size()
+	switch primaryKey.PubKeyAlgo {
+	case packet.PubKeyAlgoEd448:
+		minSize = crypto.SHA512.Size()
+	case packet.PubKeyAlgoECDSA, packet.PubKeyAlgoEdDSA:
+		curve, _ := primaryKey.Curve()
+		switch curve {
+		case packet.Curve448, packet.CurveNistP521, packet.CurveBrainpoolP512:
+			minSize = crypto.SHA512.Size()
+		case packet.CurveNistP384, packet.CurveBrainpoolP384:
+			minSize = crypto.SHA384.Size()
+		}
+	}
+	if hashAlgorithm.Size() >= minSize {
+		return hashAlgorithm
+	}
+	if minSize > crypto.SHA384.Size() {
+		return crypto.SHA512
+	}
+	return crypto.SHA384
+}
+
+// keyLifetimeAt returns the key lifetime in seconds such that the key
+// expires the duration after unixTime, or zero if the duration is zero.
+func keyLifetimeAt(publicKey *packet.PublicKey, duration time.Duration, unixTime int64) (uint32, error) {
+	if duration == 0 {
+		return 0, nil
+	}
+	if duration < 0 {
+		return 0, errors.New("gopenpgp: key expiration must not be negative")
+	}
+	lifetime := unixTime - publicKey.CreationTime.Unix() + int64(duration/time.Second)
+	if lifetime <= 0 || lifetime > math.MaxUint32 {
+		return 0, errors.New("gopenpgp: key expiration is out of range")
+	}
+	return uint32(lifetime), nil
+}
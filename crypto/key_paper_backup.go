@@ -0,0 +1,272 @@
+package crypto
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/pkg/errors"
+)
+
+// A paper backup contains only the secret key material of a private key,
+// i.e., the secret part of each secret key packet, as line-checksummed hex
+// that can be printed and transcribed, similar to paperkey.
+// The binary content is:
+// version | (fingerprint length | fingerprint | secret length | secret)*.
+// Each line holds a line number, up to paperBackupBytesPerLine bytes in hex,
+// and the CRC-24 of the bytes of the line, such that typos are located on import.
+// The last line holds the CRC-24 of the binary content.
+
+const (
+	paperBackupVersion      = 1
+	paperBackupBytesPerLine = 20
+	paperBackupCRCPrefix    = "CRC:"
+)
+
+// ExportPaperBackup exports the secret key material of the private key as
+// a paper backup, i.e., as hex with line checksums for printing and transcribing.
+// The public key is not included, but must be provided on import with NewKeyFromPaperBackup.
+// If the key is locked, the secret key material remains encrypted with the passphrase.
+// Subkeys without secret key material, e.g., gnu-dummy keys, are omitted.
+func (key *Key) ExportPaperBackup() (string, error) {
+	if !key.IsPrivate() {
+		return "", errors.New("gopenpgp: paper backup requires a private key")
+	}
+	content := []byte{paperBackupVersion}
+	privateKeys := []*packet.PrivateKey{key.entity.PrivateKey}
+	for _, subkey := range key.entity.Subkeys {
+		privateKeys = append(privateKeys, subkey.PrivateKey)
+	}
+	for _, privateKey := range privateKeys {
+		if privateKey == nil || privateKey.Dummy() {
+			continue
+		}
+		secret, err := secretKeyMaterial(privateKey)
+		if err != nil {
+			return "", err
+		}
+		if len(secret) > 0xffff {
+			return "", errors.New("gopenpgp: secret key material is too large for a paper backup")
+		}
+		fingerprint := privateKey.Fingerprint
+		content = append(content, byte(len(fingerprint)))
+		content = append(content, fingerprint...)
+		content = append(content, byte(len(secret)>>8), byte(len(secret)))
+		content = append(content, secret...)
+		clearMem(secret)
+	}
+	defer clearMem(content)
+	if len(content) == 1 {
+		return "", errors.New("gopenpgp: key contains no secret key material")
+	}
+
+	var backup strings.Builder
+	fmt.Fprintf(&backup, "# Secret key material of %s\n", key.GetFingerprint())
+	backup.WriteString("# Import with the public key and NewKeyFromPaperBackup.\n")
+	backup.WriteString("# Each line: line number, hex data, CRC-24 of the line data.\n")
+	for line := 0; line*paperBackupBytesPerLine < len(content); line++ {
+		end := (line + 1) * paperBackupBytesPerLine
+		if end > len(content) {
+			end = len(content)
+		}
+		data := content[line*paperBackupBytesPerLine : end]
+		fmt.Fprintf(&backup, "%3d:", line+1)
+		for _, b := range data {
+			fmt.Fprintf(&backup, " %02x", b)
+		}
+		fmt.Fprintf(&backup, " %06x\n", crc24(data))
+	}
+	fmt.Fprintf(&backup, "%s %06x\n", paperBackupCRCPrefix, crc24(content))
+	return backup.String(), nil
+}
+
+// NewKeyFromPaperBackup reconstructs the private key from the public key and
+// a paper backup created with (*Key).ExportPaperBackup.
+// Whitespace is ignored within lines, and lines starting with # are comments.
+// If the key was locked on export, the reconstructed key is locked with the same passphrase.
+func NewKeyFromPaperBackup(publicKey *Key, backup string) (*Key, error) {
+	content, err := parsePaperBackup(backup)
+	if err != nil {
+		return nil, err
+	}
+	defer clearMem(content)
+	if len(content) == 0 || content[0] != paperBackupVersion {
+		return nil, errors.New("gopenpgp: unsupported paper backup version")
+	}
+	key, err := publicKey.Copy()
+	if err != nil {
+		return nil, err
+	}
+	publicKeys := []*packet.PublicKey{key.entity.PrimaryKey}
+	for _, subkey := range key.entity.Subkeys {
+		publicKeys = append(publicKeys, subkey.PublicKey)
+	}
+	privateKeys := make([]*packet.PrivateKey, len(publicKeys))
+	for remaining := content[1:]; len(remaining) > 0; {
+		if int(remaining[0])+3 > len(remaining) {
+			return nil, errors.New("gopenpgp: malformed paper backup")
+		}
+		fingerprint := remaining[1 : 1+int(remaining[0])]
+		remaining = remaining[1+len(fingerprint):]
+		secretLength := int(binary.BigEndian.Uint16(remaining))
+		if 2+secretLength > len(remaining) {
+			return nil, errors.New("gopenpgp: malformed paper backup")
+		}
+		secret := remaining[2 : 2+secretLength]
+		remaining = remaining[2+secretLength:]
+		index := -1
+		for candidate, publicKey := range publicKeys {
+			if bytes.Equal(publicKey.Fingerprint, fingerprint) {
+				index = candidate
+			}
+		}
+		if index == -1 {
+			return nil, errors.Errorf("gopenpgp: paper backup contains an unknown key %x", fingerprint)
+		}
+		if privateKeys[index], err = privateKeyFromSecret(publicKeys[index], secret); err != nil {
+			return nil, err
+		}
+	}
+	if privateKeys[0] == nil {
+		return nil, errors.New("gopenpgp: paper backup does not contain the primary key")
+	}
+	key.entity.PrivateKey = privateKeys[0]
+	for index := range key.entity.Subkeys {
+		key.entity.Subkeys[index].PrivateKey = privateKeys[index+1]
+	}
+	serialized, err := key.Serialize()
+	if err != nil {
+		return nil, err
+	}
+	return NewKey(serialized)
+}
+
+// secretKeyMaterial returns the secret part of the body of the secret key packet,
+// which follows the public part.
+func secretKeyMaterial(privateKey *packet.PrivateKey) ([]byte, error) {
+	publicBody, err := publicKeyPacketBody(&privateKey.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	var serialized bytes.Buffer
+	if err := privateKey.Serialize(&serialized); err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: error in serializing private key")
+	}
+	defer clearMem(serialized.Bytes())
+	// The packet header has at most 6 bytes.
+	offset := bytes.Index(serialized.Bytes(), publicBody)
+	if offset < 0 || offset > 6 {
+		return nil, errors.New("gopenpgp: unexpected secret key packet")
+	}
+	return append([]byte(nil), serialized.Bytes()[offset+len(publicBody):]...), nil
+}
+
+// privateKeyFromSecret parses the secret key packet that consists of the public key and the secret part.
+func privateKeyFromSecret(publicKey *packet.PublicKey, secret []byte) (*packet.PrivateKey, error) {
+	publicBody, err := publicKeyPacketBody(publicKey)
+	if err != nil {
+		return nil, err
+	}
+	tag := byte(packetTagSecretKey)
+	if publicKey.IsSubkey {
+		tag = packetTagSecretSubkey
+	}
+	var serialized bytes.Buffer
+	writePacketHeader(&serialized, tag, len(publicBody)+len(secret))
+	serialized.Write(publicBody)
+	serialized.Write(secret)
+	defer clearMem(serialized.Bytes())
+	p, err := packet.Read(bytes.NewReader(serialized.Bytes()))
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: error in parsing secret key")
+	}
+	privateKey, ok := p.(*packet.PrivateKey)
+	if !ok {
+		return nil, errors.New("gopenpgp: secret key packet expected")
+	}
+	return privateKey, nil
+}
+
+// publicKeyPacketBody returns the body of the public key packet.
+func publicKeyPacketBody(publicKey *packet.PublicKey) ([]byte, error) {
+	var serialized bytes.Buffer
+	if err := publicKey.SerializeForHash(&serialized); err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: error in serializing public key")
+	}
+	// The hash prefix is the octet 0x99 and a two-octet length for v4 keys,
+	// or the octet 0x9b and a four-octet length for v6 keys.
+	prefixLength := 3
+	if publicKey.Version >= 5 {
+		prefixLength = 5
+	}
+	return serialized.Bytes()[prefixLength:], nil
+}
+
+// parsePaperBackup decodes the binary content of the paper backup and checks all checksums.
+func parsePaperBackup(backup string) ([]byte, error) {
+	var content []byte
+	lineNumber := 0
+	scanner := bufio.NewScanner(strings.NewReader(backup))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, paperBackupCRCPrefix) {
+			checksum, err := strconv.ParseUint(strings.TrimSpace(line[len(paperBackupCRCPrefix):]), 16, 32)
+			if err != nil || uint32(checksum) != crc24(content) {
+				clearMem(content)
+				return nil, errors.New("gopenpgp: paper backup checksum mismatch")
+			}
+			return content, nil
+		}
+		lineNumber++
+		data, err := parsePaperBackupLine(line, lineNumber)
+		if err != nil {
+			clearMem(content)
+			return nil, err
+		}
+		content = append(content, data...)
+	}
+	clearMem(content)
+	return nil, errors.New("gopenpgp: paper backup is incomplete")
+}
+
+func parsePaperBackupLine(line string, lineNumber int) ([]byte, error) {
+	separator := strings.Index(line, ":")
+	if separator < 0 || strings.TrimSpace(line[:separator]) != strconv.Itoa(lineNumber) {
+		return nil, errors.Errorf("gopenpgp: paper backup line %d is missing", lineNumber)
+	}
+	digits := strings.Join(strings.Fields(line[separator+1:]), "")
+	// The last three bytes are the checksum of the line.
+	decoded, err := hex.DecodeString(digits)
+	if err != nil || len(decoded) <= 3 {
+		return nil, errors.Errorf("gopenpgp: paper backup line %d is malformed", lineNumber)
+	}
+	data := decoded[:len(decoded)-3]
+	checksum := uint32(decoded[len(decoded)-3])<<16 | uint32(decoded[len(decoded)-2])<<8 | uint32(decoded[len(decoded)-1])
+	if checksum != crc24(data) {
+		return nil, errors.Errorf("gopenpgp: paper backup line %d has a typo", lineNumber)
+	}
+	return data, nil
+}
+
+// crc24 computes the CRC-24 checksum of OpenPGP armor, see RFC 9580 section 6.1.
+func crc24(data []byte) uint32 {
+	crc := uint32(0xb704ce)
+	for _, b := range data {
+		crc ^= uint32(b) << 16
+		for bit := 0; bit < 8; bit++ {
+			crc <<= 1
+			if crc&0x1000000 != 0 {
+				crc ^= 0x1864cfb
+			}
+		}
+	}
+	return crc & 0xffffff
+}
@@ -0,0 +1,196 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base32"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// QR fragments split a private key or key share into short strings that each fit
+// in a single QR code. A fragment reads
+//
+//	PGPQR1:<kind>:<index>/<total>:<set id>:<crc>:<data>
+//
+// with the kind KEY or SHARE, the 1-based index of the fragment, the set id as the
+// first bytes of the SHA-256 of the complete payload in hex, the CRC-24 of the fragment
+// data in hex, and the fragment data in unpadded base32. All characters are in the
+// QR alphanumeric set, which QR codes encode more compactly than bytes.
+
+const (
+	qrFragmentPrefix      = "PGPQR1"
+	qrFragmentKindKey     = "KEY"
+	qrFragmentKindShare   = "SHARE"
+	qrFragmentSetIDLength = 4
+)
+
+var qrFragmentEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// ExportQRFragments exports the private key as fragments of at most maxFragmentLength
+// characters, each to be encoded in a separate QR code, e.g., to transfer the key to
+// another device. The fragments only use characters of the QR alphanumeric mode;
+// a version 40 QR code with low error correction holds up to 4296 of them.
+// If the key is locked, the secret key material remains encrypted with the passphrase.
+// The key is reassembled with NewKeyFromQRFragments.
+func (key *Key) ExportQRFragments(maxFragmentLength int) ([]string, error) {
+	if !key.IsPrivate() {
+		return nil, errors.New("gopenpgp: QR export requires a private key")
+	}
+	serialized, err := key.Serialize()
+	if err != nil {
+		return nil, err
+	}
+	defer clearMem(serialized)
+	return splitQRFragments(qrFragmentKindKey, serialized, maxFragmentLength)
+}
+
+// NewKeyFromQRFragments reassembles a private key from all fragments created with
+// (*Key).ExportQRFragments, given in any order.
+func NewKeyFromQRFragments(fragments []string) (*Key, error) {
+	payload, err := joinQRFragments(qrFragmentKindKey, fragments)
+	if err != nil {
+		return nil, err
+	}
+	key, err := NewKey(payload)
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: QR fragments do not contain a key")
+	}
+	return key, nil
+}
+
+// KeyShareToQRFragments exports an armored key share created with (*Key).SplitIntoShares
+// as fragments of at most maxFragmentLength characters, each to be encoded in a separate
+// QR code. The share is reassembled with KeyShareFromQRFragments.
+// Not supported on go-mobile clients.
+func KeyShareToQRFragments(share string, maxFragmentLength int) ([]string, error) {
+	if _, err := parseKeyShare(share); err != nil {
+		return nil, err
+	}
+	return splitQRFragments(qrFragmentKindShare, []byte(share), maxFragmentLength)
+}
+
+// KeyShareFromQRFragments reassembles the armored key share from all fragments created
+// with KeyShareToQRFragments, given in any order.
+// Not supported on go-mobile clients.
+func KeyShareFromQRFragments(fragments []string) (string, error) {
+	payload, err := joinQRFragments(qrFragmentKindShare, fragments)
+	if err != nil {
+		return "", err
+	}
+	share := string(payload)
+	if _, err := parseKeyShare(share); err != nil {
+		return "", err
+	}
+	return share, nil
+}
+
+func qrFragmentHeader(kind string, index, total int, setID []byte, checksum uint32) string {
+	return fmt.Sprintf("%s:%s:%d/%d:%X:%06X:", qrFragmentPrefix, kind, index, total, setID, checksum)
+}
+
+func splitQRFragments(kind string, payload []byte, maxFragmentLength int) ([]string, error) {
+	if len(payload) == 0 {
+		return nil, errors.New("gopenpgp: no data to export as QR fragments")
+	}
+	digest := sha256.Sum256(payload)
+	setID := digest[:qrFragmentSetIDLength]
+	// The header grows with the number of digits of the total, thus the
+	// total is increased until the data of all fragments fits.
+	var dataLength int
+	total := 1
+	for {
+		// Base32 encodes 5 bytes without padding in 8 characters.
+		dataLength = (maxFragmentLength - len(qrFragmentHeader(kind, total, total, setID, 0))) / 8 * 5
+		if dataLength <= 0 {
+			return nil, errors.Errorf("gopenpgp: maximum QR fragment length %d is too small", maxFragmentLength)
+		}
+		needed := (len(payload) + dataLength - 1) / dataLength
+		fits := needed <= total
+		total = needed
+		if fits {
+			break
+		}
+	}
+	fragments := make([]string, 0, total)
+	for index := 0; index < total; index++ {
+		end := (index + 1) * dataLength
+		if end > len(payload) {
+			end = len(payload)
+		}
+		data := payload[index*dataLength : end]
+		header := qrFragmentHeader(kind, index+1, total, setID, crc24(data))
+		fragments = append(fragments, header+qrFragmentEncoding.EncodeToString(data))
+	}
+	return fragments, nil
+}
+
+func joinQRFragments(kind string, fragments []string) ([]byte, error) {
+	if len(fragments) == 0 {
+		return nil, errors.New("gopenpgp: no QR fragments given")
+	}
+	var setID string
+	var parts [][]byte
+	for _, fragment := range fragments {
+		fields := strings.SplitN(strings.TrimSpace(fragment), ":", 6)
+		if len(fields) != 6 || fields[0] != qrFragmentPrefix {
+			return nil, errors.New("gopenpgp: unsupported QR fragment format")
+		}
+		if fields[1] != kind {
+			return nil, errors.Errorf("gopenpgp: QR fragment contains %s data, expected %s", fields[1], kind)
+		}
+		index, total, err := parseQRFragmentPosition(fields[2])
+		if err != nil {
+			return nil, err
+		}
+		if total > len(fragments) {
+			return nil, errors.Errorf("gopenpgp: %d QR fragments given, but %d are required", len(fragments), total)
+		}
+		if parts == nil {
+			setID = fields[3]
+			parts = make([][]byte, total)
+		}
+		if fields[3] != setID || total != len(parts) {
+			return nil, errors.New("gopenpgp: QR fragments belong to different exports")
+		}
+		checksum, err := strconv.ParseUint(fields[4], 16, 32)
+		if err != nil {
+			return nil, errors.Errorf("gopenpgp: malformed checksum in QR fragment %d", index)
+		}
+		data, err := qrFragmentEncoding.DecodeString(fields[5])
+		if err != nil || uint32(checksum) != crc24(data) {
+			return nil, errors.Errorf("gopenpgp: QR fragment %d is corrupted", index)
+		}
+		parts[index-1] = data
+	}
+	var payload bytes.Buffer
+	for index, data := range parts {
+		if data == nil {
+			return nil, errors.Errorf("gopenpgp: QR fragment %d of %d is missing", index+1, len(parts))
+		}
+		payload.Write(data)
+	}
+	digest := sha256.Sum256(payload.Bytes())
+	if fmt.Sprintf("%X", digest[:qrFragmentSetIDLength]) != setID {
+		clearMem(payload.Bytes())
+		return nil, errors.New("gopenpgp: QR fragments checksum mismatch")
+	}
+	return payload.Bytes(), nil
+}
+
+func parseQRFragmentPosition(position string) (index, total int, err error) {
+	fields := strings.SplitN(position, "/", 2)
+	if len(fields) == 2 {
+		index, err = strconv.Atoi(fields[0])
+		if err == nil {
+			total, err = strconv.Atoi(fields[1])
+		}
+	}
+	if len(fields) != 2 || err != nil || index < 1 || index > total {
+		return 0, 0, errors.Errorf("gopenpgp: malformed QR fragment position %q", position)
+	}
+	return index, total, nil
+}
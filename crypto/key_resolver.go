@@ -0,0 +1,135 @@
+package crypto
+
+import (
+	"encoding/hex"
+	"sync"
+
+	openpgp "github.com/ProtonMail/go-crypto/openpgp/v2"
+	"github.com/pkg/errors"
+)
+
+// KeyResolver looks up keys by key id once a message references them,
+// e.g., in a database, such that the keys do not have to be loaded into a key ring upfront.
+// Not supported on go-mobile clients.
+type KeyResolver interface {
+	// ResolveKeys returns the keys whose primary key or a subkey has the given key id,
+	// or nil if no key is known. Keys for decryption must be unlocked.
+	// An error aborts the decryption or verification.
+	ResolveKeys(keyID uint64) (*KeyRing, error)
+}
+
+// KeyResolverFunc is an adapter to use a function as KeyResolver.
+// Not supported on go-mobile clients.
+type KeyResolverFunc func(keyID uint64) (*KeyRing, error)
+
+// ResolveKeys calls f(keyID).
+func (f KeyResolverFunc) ResolveKeys(keyID uint64) (*KeyRing, error) {
+	return f(keyID)
+}
+
+// keyResolution tracks the key ids that were resolved for a key ring.
+type keyResolution struct {
+	resolver KeyResolver
+	mutex    sync.Mutex
+	resolved map[uint64]bool
+	err      error
+}
+
+// newResolvingKeyRing returns a key ring with the keys of keyRing, if not nil,
+// that adds the keys of the resolver when a key id is looked up.
+// Since resolved keys are added, a resolving key ring is used for a single operation.
+func newResolvingKeyRing(resolver KeyResolver, keyRing *KeyRing) *KeyRing {
+	resolving := &KeyRing{
+		resolution: &keyResolution{
+			resolver: resolver,
+			resolved: make(map[uint64]bool),
+		},
+	}
+	if keyRing != nil {
+		resolving.entities = append(resolving.entities, keyRing.entities...)
+		resolving.FirstKeyID = keyRing.FirstKeyID
+	}
+	return resolving
+}
+
+// resolve adds the keys with the key id from the resolver of the key ring, if any.
+// Each key id is resolved once, and the wildcard key id 0 is not resolved.
+// After the first error of the resolver, no keys are resolved anymore.
+func (keyRing *KeyRing) resolve(keyID uint64) {
+	resolution := keyRing.resolution
+	if resolution == nil || keyID == 0 {
+		return
+	}
+	resolution.mutex.Lock()
+	defer resolution.mutex.Unlock()
+	if resolution.resolved[keyID] || resolution.err != nil {
+		return
+	}
+	resolution.resolved[keyID] = true
+	resolved, err := resolution.resolver.ResolveKeys(keyID)
+	if err != nil {
+		resolution.err = errors.Wrap(err, "gopenpgp: key resolution failed")
+		return
+	}
+	if resolved == nil {
+		return
+	}
+	// Keys that are already in the key ring, or resolved twice, are skipped.
+	index := keyRing.getIndex()
+	var added openpgp.EntityList
+	addedFingerprints := make(map[string]bool)
+	for _, entity := range resolved.entities {
+		fingerprint := hex.EncodeToString(entity.PrimaryKey.Fingerprint)
+		if index.byFingerprint[fingerprint] == nil && !addedFingerprints[fingerprint] {
+			addedFingerprints[fingerprint] = true
+			added = append(added, entity)
+		}
+	}
+	if len(added) == 0 {
+		return
+	}
+	keyRing.mutex.Lock()
+	defer keyRing.mutex.Unlock()
+	keyRing.entities = append(keyRing.entities, added...)
+	keyRing.index = nil
+}
+
+// resolutionError returns the error of the resolver of the key ring, if any.
+func (keyRing *KeyRing) resolutionError() error {
+	if keyRing == nil || keyRing.resolution == nil {
+		return nil
+	}
+	keyRing.resolution.mutex.Lock()
+	defer keyRing.resolution.mutex.Unlock()
+	return keyRing.resolution.err
+}
+
+// withKeyResolvers returns a copy of the handle whose key rings resolve keys with
+// DecryptionKeyResolver and VerificationKeyResolver, or the handle itself if it has no resolvers.
+func (dh *decryptionHandle) withKeyResolvers() *decryptionHandle {
+	if dh.DecryptionKeyResolver == nil && dh.VerificationKeyResolver == nil {
+		return dh
+	}
+	handle := *dh
+	if dh.DecryptionKeyResolver != nil {
+		handle.DecryptionKeyRing = newResolvingKeyRing(dh.DecryptionKeyResolver, dh.DecryptionKeyRing)
+		handle.DecryptionKeyResolver = nil
+	}
+	if dh.VerificationKeyResolver != nil {
+		handle.VerifyKeyRing = newResolvingKeyRing(dh.VerificationKeyResolver, dh.VerifyKeyRing)
+		handle.VerificationKeyResolver = nil
+	}
+	return &handle
+}
+
+// withKeyResolver returns a copy of the handle whose verification key ring resolves keys
+// with VerificationKeyResolver, or the handle itself if it has no resolver.
+func (vh *verifyHandle) withKeyResolver() *verifyHandle {
+	if vh.VerificationKeyResolver == nil {
+		return vh
+	}
+	handle := *vh
+	handle.VerifyKeyRing = newResolvingKeyRing(vh.VerificationKeyResolver, vh.VerifyKeyRing)
+	handle.VerificationKeyResolver = nil
+	return &handle
+}
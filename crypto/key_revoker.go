@@ -0,0 +1,365 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"encoding/asn1"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"math/big"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	openpgp "github.com/ProtonMail/go-crypto/openpgp/v2"
+	"github.com/pkg/errors"
+)
+
+// Signature subpacket types that are written by addDesignatedRevoker.
+const (
+	creationTimeSubpacket      = 2
+	revocationKeySubpacket     = 12
+	issuerSubpacket            = 16
+	issuerFingerprintSubpacket = 33
+)
+
+// revocationKeyClass is the class of revocation key subpackets, which must have the bit 0x80 set.
+const revocationKeyClass = 0x80
+
+// AddDesignatedRevoker returns a copy of the key, which designates the primary key of
+// the revoker to revoke the key, with a direct-key signature created at unixTime.
+// The designated revoker can revoke the key without access to its private key,
+// see (*Key).RevokeAsDesignatedRevoker.
+// RFC 9580 deprecates designated revokers, thus only v4 keys are supported.
+// Ed25519, RSA, and ECDSA primary keys on the NIST curves are supported.
+// The primary key must be unlocked.
+func (key *Key) AddDesignatedRevoker(revoker *Key, unixTime int64) (*Key, error) {
+	newKey, err := key.copyForModification()
+	if err != nil {
+		return nil, err
+	}
+	if err := addDesignatedRevoker(newKey, revoker.entity.PrimaryKey, newKey.managementConfig(unixTime)); err != nil {
+		return nil, err
+	}
+	return newKey, nil
+}
+
+// GetDesignatedRevokers returns the hex encoded fingerprints of the designated revokers of the key.
+// Not supported on go-mobile clients.
+func (key *Key) GetDesignatedRevokers() []string {
+	var fingerprints []string
+	for _, fingerprint := range designatedRevokers(key.entity) {
+		fingerprints = append(fingerprints, hex.EncodeToString(fingerprint))
+	}
+	return fingerprints
+}
+
+// RevokeAsDesignatedRevoker returns a copy of the target key, which is revoked at unixTime
+// by the key as designated revoker of the target key, see (*Key).AddDesignatedRevoker.
+// The reason is a reason code, see constants.Revocation..., and reasonText
+// describes the reason for humans.
+// Since the revocation is not issued by the target key itself, it is only honored
+// if the key of the designated revoker is known, see (*Key).IsRevokedByDesignatedRevoker.
+// The primary key must be unlocked.
+func (key *Key) RevokeAsDesignatedRevoker(target *Key, reason int8, reasonText string, unixTime int64) (*Key, error) {
+	if !key.IsPrivate() || key.entity.PrivateKey.Encrypted {
+		return nil, errors.New("gopenpgp: the primary key must be unlocked to revoke a key")
+	}
+	if !isDesignatedRevoker(target.entity, key.entity.PrimaryKey) {
+		return nil, errors.New("gopenpgp: the key is not a designated revoker of the target key")
+	}
+	revokedKey, err := target.Copy()
+	if err != nil {
+		return nil, err
+	}
+	config := key.managementConfig(unixTime)
+	revocation := newSelfSignature(key.entity.PrimaryKey, packet.SigTypeKeyRevocation, config)
+	revocationReason := packet.NewReasonForRevocation(byte(reason))
+	revocation.RevocationReason = &revocationReason
+	revocation.RevocationReasonText = reasonText
+	if err := revocation.RevokeKey(revokedKey.entity.PrimaryKey, key.entity.PrivateKey, config); err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: error in revoking key")
+	}
+	revokedKey.entity.Revocations = append(revokedKey.entity.Revocations, packet.NewVerifiableSig(revocation))
+	return revokedKey, nil
+}
+
+// IsRevokedByDesignatedRevoker returns true if the key is revoked at unixTime by a designated
+// revoker, whose key is in the key ring revokers.
+func (key *Key) IsRevokedByDesignatedRevoker(revokers *KeyRing, unixTime int64) bool {
+	return isRevokedByDesignatedRevoker(key.entity, revokers, time.Unix(unixTime, 0))
+}
+
+// isRevokedByDesignatedRevoker returns true if the entity has a valid key revocation
+// by a designated revoker in the key ring revokers at date.
+// Like for self-revocations, a revocation without reason or because of a compromised key
+// revokes the key at any time.
+func isRevokedByDesignatedRevoker(entity *openpgp.Entity, revokers *KeyRing, date time.Time) bool {
+	if len(entity.Revocations) == 0 || revokers == nil {
+		return false
+	}
+	for _, fingerprint := range designatedRevokers(entity) {
+		revoker := revokers.getIndex().byFingerprint[hex.EncodeToString(fingerprint)]
+		if revoker == nil || !bytes.Equal(revoker.PrimaryKey.Fingerprint, fingerprint) {
+			continue
+		}
+		for _, revocation := range entity.Revocations {
+			sig := revocation.Packet
+			if !sig.CheckKeyIdOrFingerprint(revoker.PrimaryKey) {
+				continue
+			}
+			preparedHash, err := sig.PrepareVerify()
+			if err != nil {
+				continue
+			}
+			if err := entity.PrimaryKey.SerializeForHash(preparedHash); err != nil {
+				continue
+			}
+			if err := revoker.PrimaryKey.VerifySignature(preparedHash, sig); err != nil {
+				continue
+			}
+			if sig.RevocationReason == nil ||
+				*sig.RevocationReason == packet.Unknown ||
+				*sig.RevocationReason == packet.NoReason ||
+				*sig.RevocationReason == packet.KeyCompromised ||
+				!sig.SigExpired(date) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isDesignatedRevoker returns true if the public key is a designated revoker of the entity.
+func isDesignatedRevoker(entity *openpgp.Entity, publicKey *packet.PublicKey) bool {
+	for _, fingerprint := range designatedRevokers(entity) {
+		if bytes.Equal(fingerprint, publicKey.Fingerprint) {
+			return true
+		}
+	}
+	return false
+}
+
+// designatedRevokers returns the fingerprints of the revocation key subpackets
+// in the valid direct-key self-signatures of the entity.
+// go-crypto does not parse revocation key subpackets, thus they are read from
+// the serialized signatures.
+func designatedRevokers(entity *openpgp.Entity) (fingerprints [][]byte) {
+	for _, directSignature := range entity.DirectSignatures {
+		sig := directSignature.Packet
+		if sig.Version != 4 || entity.PrimaryKey.VerifyDirectKeySignature(sig) != nil {
+			continue
+		}
+		var serialized bytes.Buffer
+		if err := sig.Serialize(&serialized); err != nil {
+			continue
+		}
+		subpackets, err := hashedSubpacketsV4(serialized.Bytes())
+		if err != nil {
+			continue
+		}
+		for _, subpacket := range subpackets {
+			// The class octet and the algorithm octet precede the fingerprint.
+			if subpacket.subpacketType == revocationKeySubpacket &&
+				len(subpacket.contents) == 2+20 &&
+				subpacket.contents[0]&revocationKeyClass != 0 {
+				fingerprints = append(fingerprints, subpacket.contents[2:])
+			}
+		}
+	}
+	return fingerprints
+}
+
+// signatureSubpacket is a raw subpacket of a signature.
+type signatureSubpacket struct {
+	subpacketType byte
+	contents      []byte
+}
+
+// hashedSubpacketsV4 returns the hashed subpackets of a serialized v4 signature packet.
+func hashedSubpacketsV4(serialized []byte) ([]signatureSubpacket, error) {
+	if len(serialized) < 1 || serialized[0]&0x40 == 0 {
+		return nil, errors.New("gopenpgp: unexpected signature packet format")
+	}
+	_, body, err := readSubpacketLength(serialized[1:])
+	if err != nil {
+		return nil, err
+	}
+	// The version, type, public key algorithm, and hash algorithm octets
+	// precede the two octet length of the hashed subpackets.
+	if len(body) < 6 || body[0] != 4 {
+		return nil, errors.New("gopenpgp: unexpected signature version")
+	}
+	hashedLength := int(binary.BigEndian.Uint16(body[4:6]))
+	if len(body) < 6+hashedLength {
+		return nil, errors.New("gopenpgp: truncated signature subpackets")
+	}
+	hashed := body[6 : 6+hashedLength]
+	var subpackets []signatureSubpacket
+	for len(hashed) > 0 {
+		length, rest, err := readSubpacketLength(hashed)
+		if err != nil {
+			return nil, err
+		}
+		if length < 1 || len(rest) < length {
+			return nil, errors.New("gopenpgp: truncated signature subpacket")
+		}
+		subpackets = append(subpackets, signatureSubpacket{
+			subpacketType: rest[0] & 0x7f,
+			contents:      rest[1:length],
+		})
+		hashed = rest[length:]
+	}
+	return subpackets, nil
+}
+
+// readSubpacketLength reads a length in the format of new packet headers and subpackets.
+func readSubpacketLength(data []byte) (length int, rest []byte, err error) {
+	switch {
+	case len(data) >= 1 && data[0] < 192:
+		return int(data[0]), data[1:], nil
+	case len(data) >= 2 && data[0] < 255:
+		return (int(data[0])-192)<<8 + int(data[1]) + 192, data[2:], nil
+	case len(data) >= 5 && data[0] == 255:
+		return int(binary.BigEndian.Uint32(data[1:5])), data[5:], nil
+	}
+	return 0, nil, errors.New("gopenpgp: invalid subpacket length")
+}
+
+// writeSubpacketLength writes a length in the format of new packet headers and subpackets.
+func writeSubpacketLength(w io.Writer, length int) {
+	switch {
+	case length < 192:
+		_, _ = w.Write([]byte{byte(length)})
+	case length < 8384:
+		length -= 192
+		_, _ = w.Write([]byte{byte(length>>8) + 192, byte(length)})
+	default:
+		_, _ = w.Write([]byte{255, byte(length >> 24), byte(length >> 16), byte(length >> 8), byte(length)})
+	}
+}
+
+func writeSubpacket(w io.Writer, subpacketType byte, contents []byte) {
+	writeSubpacketLength(w, 1+len(contents))
+	_, _ = w.Write([]byte{subpacketType})
+	_, _ = w.Write(contents)
+}
+
+// addDesignatedRevoker adds a direct-key signature with a revocation key subpacket
+// for the revoker to the unlocked v4 key.
+// Since go-crypto cannot write revocation key subpackets, the signature is assembled here.
+func addDesignatedRevoker(key *Key, revoker *packet.PublicKey, config *packet.Config) error {
+	primaryKey := key.entity.PrimaryKey
+	if primaryKey.Version != 4 || revoker.Version != 4 {
+		return errors.New("gopenpgp: designated revokers are only supported for v4 keys")
+	}
+	if bytes.Equal(primaryKey.Fingerprint, revoker.Fingerprint) {
+		return errors.New("gopenpgp: a key cannot be its own designated revoker")
+	}
+	signer, err := standardSigner(key)
+	if err != nil {
+		return err
+	}
+	hashAlgorithm := selfSignatureHash(primaryKey, config.Hash())
+	hashID, ok := openpgp.HashToHashId(hashAlgorithm)
+	if !ok {
+		return errors.New("gopenpgp: unsupported hash algorithm")
+	}
+
+	var hashed bytes.Buffer
+	creationTime := make([]byte, 4)
+	binary.BigEndian.PutUint32(creationTime, uint32(config.Now().Unix()))
+	writeSubpacket(&hashed, creationTimeSubpacket, creationTime)
+	writeSubpacket(&hashed, revocationKeySubpacket, append([]byte{revocationKeyClass, byte(revoker.PubKeyAlgo)}, revoker.Fingerprint...))
+	writeSubpacket(&hashed, issuerFingerprintSubpacket, append([]byte{4}, primaryKey.Fingerprint...))
+	var unhashed bytes.Buffer
+	issuer := make([]byte, 8)
+	binary.BigEndian.PutUint64(issuer, primaryKey.KeyId)
+	writeSubpacket(&unhashed, issuerSubpacket, issuer)
+
+	fields := []byte{
+		4,
+		byte(packet.SigTypeDirectSignature),
+		byte(primaryKey.PubKeyAlgo),
+		hashID,
+		byte(hashed.Len() >> 8),
+		byte(hashed.Len()),
+	}
+	hashedLength := len(fields) + hashed.Len()
+	signed := hashAlgorithm.New()
+	if err := primaryKey.SerializeForHash(signed); err != nil {
+		return errors.Wrap(err, "gopenpgp: error in hashing key")
+	}
+	signed.Write(fields)
+	signed.Write(hashed.Bytes())
+	signed.Write([]byte{4, 0xff, byte(hashedLength >> 24), byte(hashedLength >> 16), byte(hashedLength >> 8), byte(hashedLength)})
+	digest := signed.Sum(nil)
+	signature, err := signDigest(signer, primaryKey.PubKeyAlgo, hashAlgorithm, digest)
+	if err != nil {
+		return err
+	}
+
+	var body bytes.Buffer
+	body.Write(fields)
+	body.Write(hashed.Bytes())
+	body.Write([]byte{byte(unhashed.Len() >> 8), byte(unhashed.Len())})
+	body.Write(unhashed.Bytes())
+	body.Write(digest[:2])
+	body.Write(signature)
+	var serialized bytes.Buffer
+	serialized.WriteByte(0xc0 | 2)
+	writeSubpacketLength(&serialized, body.Len())
+	serialized.Write(body.Bytes())
+	p, err := packet.Read(&serialized)
+	if err != nil {
+		return errors.Wrap(err, "gopenpgp: error in creating designated revoker signature")
+	}
+	sig, ok := p.(*packet.Signature)
+	if !ok {
+		return errors.New("gopenpgp: error in creating designated revoker signature")
+	}
+	if err := primaryKey.VerifyDirectKeySignature(sig); err != nil {
+		return errors.Wrap(err, "gopenpgp: error in creating designated revoker signature")
+	}
+	key.entity.DirectSignatures = append(key.entity.DirectSignatures, packet.NewVerifiableSig(sig))
+	return nil
+}
+
+// signDigest signs the digest and returns the signature in the encoding of OpenPGP signature packets.
+func signDigest(signer crypto.Signer, algorithm packet.PublicKeyAlgorithm, hashAlgorithm crypto.Hash, digest []byte) ([]byte, error) {
+	var opts crypto.SignerOpts = hashAlgorithm
+	if algorithm == packet.PubKeyAlgoEdDSA || algorithm == packet.PubKeyAlgoEd25519 {
+		// EdDSA signs the digest as message.
+		opts = crypto.Hash(0)
+	}
+	signature, err := signer.Sign(rand.Reader, digest, opts)
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: error in signing")
+	}
+	switch algorithm {
+	case packet.PubKeyAlgoRSA, packet.PubKeyAlgoRSASignOnly:
+		return encodeMPI(signature), nil
+	case packet.PubKeyAlgoECDSA:
+		var ecdsaSignature struct{ R, S *big.Int }
+		if _, err := asn1.Unmarshal(signature, &ecdsaSignature); err != nil {
+			return nil, errors.Wrap(err, "gopenpgp: error in parsing ecdsa signature")
+		}
+		return append(encodeMPI(ecdsaSignature.R.Bytes()), encodeMPI(ecdsaSignature.S.Bytes())...), nil
+	case packet.PubKeyAlgoEdDSA:
+		return append(encodeMPI(signature[:32]), encodeMPI(signature[32:])...), nil
+	case packet.PubKeyAlgoEd25519:
+		return signature, nil
+	}
+	return nil, errors.Errorf("gopenpgp: key algorithm %d is not supported", algorithm)
+}
+
+// encodeMPI encodes the big-endian integer as OpenPGP multiprecision integer.
+func encodeMPI(value []byte) []byte {
+	value = bytes.TrimLeft(value, "\x00")
+	bitLength := 0
+	if len(value) > 0 {
+		bitLength = (len(value)-1)*8 + new(big.Int).SetBytes(value[:1]).BitLen()
+	}
+	return append([]byte{byte(bitLength >> 8), byte(bitLength)}, value...)
+}
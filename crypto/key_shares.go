@@ -0,0 +1,154 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"strconv"
+
+	"github.com/ProtonMail/gopenpgp/v3/armor"
+	"github.com/ProtonMail/gopenpgp/v3/constants"
+	"github.com/ProtonMail/gopenpgp/v3/internal"
+	"github.com/pkg/errors"
+)
+
+// keyShareVersion is the version of the binary key share format:
+// version | threshold | share index | fingerprint length | fingerprint | share data.
+const keyShareVersion = 1
+
+const (
+	keyShareHeaderShare       = "Share"
+	keyShareHeaderThreshold   = "Threshold"
+	keyShareHeaderFingerprint = "Fingerprint"
+)
+
+// keyShare is a parsed share of a private key.
+type keyShare struct {
+	threshold   int
+	fingerprint []byte
+	share       internal.ShamirShare
+}
+
+// SplitIntoShares splits the unlocked private key into the given number of shares
+// with Shamir's secret sharing, e.g., for key escrow or disaster recovery.
+// Any threshold of the shares reconstruct the key with NewKeyFromShares,
+// while fewer shares reveal nothing about the key.
+// Each share is armored, with the share index, the threshold, and the key fingerprint as headers.
+// Not supported on go-mobile clients.
+func (key *Key) SplitIntoShares(shares, threshold int) ([]string, error) {
+	unlocked, err := key.IsUnlocked()
+	if err != nil {
+		return nil, err
+	}
+	if !unlocked {
+		return nil, errors.New("gopenpgp: key must be unlocked to be split")
+	}
+	serialized, err := key.Serialize()
+	if err != nil {
+		return nil, err
+	}
+	defer clearMem(serialized)
+	secretShares, err := internal.ShamirSplit(serialized, shares, threshold, rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	fingerprint := key.GetFingerprintBytes()
+	armoredShares := make([]string, 0, len(secretShares))
+	for _, share := range secretShares {
+		var buffer bytes.Buffer
+		buffer.Write([]byte{keyShareVersion, byte(threshold), share.X, byte(len(fingerprint))})
+		buffer.Write(fingerprint)
+		buffer.Write(share.Y)
+		armored, err := key.armorWithOptions(buffer.Bytes(), constants.KeyShareHeader, &armor.Options{
+			Headers: map[string]string{
+				keyShareHeaderShare:       strconv.Itoa(int(share.X)) + "/" + strconv.Itoa(shares),
+				keyShareHeaderThreshold:   strconv.Itoa(threshold),
+				keyShareHeaderFingerprint: hex.EncodeToString(fingerprint),
+			},
+		})
+		clearMem(buffer.Bytes())
+		if err != nil {
+			return nil, err
+		}
+		armoredShares = append(armoredShares, armored)
+	}
+	return armoredShares, nil
+}
+
+// NewKeyFromShares reconstructs a private key from armored shares created with
+// (*Key).SplitIntoShares. At least the threshold of the shares must be given.
+// The reconstructed key is unlocked.
+// Not supported on go-mobile clients.
+func NewKeyFromShares(shares []string) (*Key, error) {
+	if len(shares) == 0 {
+		return nil, errors.New("gopenpgp: no key shares given")
+	}
+	var parsed []*keyShare
+	for _, armored := range shares {
+		share, err := parseKeyShare(armored)
+		if err != nil {
+			return nil, err
+		}
+		if len(parsed) > 0 && (share.threshold != parsed[0].threshold ||
+			!bytes.Equal(share.fingerprint, parsed[0].fingerprint)) {
+			return nil, errors.New("gopenpgp: key shares belong to different keys")
+		}
+		duplicate := false
+		for _, other := range parsed {
+			duplicate = duplicate || other.share.X == share.share.X
+		}
+		if !duplicate {
+			parsed = append(parsed, share)
+		}
+	}
+	threshold := parsed[0].threshold
+	if len(parsed) < threshold {
+		return nil, errors.Errorf("gopenpgp: %d distinct key shares given, but %d are required", len(parsed), threshold)
+	}
+	secretShares := make([]internal.ShamirShare, threshold)
+	for index := range secretShares {
+		secretShares[index] = parsed[index].share
+	}
+	serialized, err := internal.ShamirCombine(secretShares)
+	if err != nil {
+		return nil, err
+	}
+	key, err := NewKey(serialized)
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: key shares do not reconstruct a key")
+	}
+	if !bytes.Equal(key.GetFingerprintBytes(), parsed[0].fingerprint) {
+		return nil, errors.New("gopenpgp: key shares do not reconstruct the key")
+	}
+	return key, nil
+}
+
+func parseKeyShare(armored string) (*keyShare, error) {
+	block, err := internal.Unarmor(armored)
+	if err != nil {
+		return nil, err
+	}
+	if block.Type != constants.KeyShareHeader {
+		return nil, errors.New("gopenpgp: armored data is not a key share")
+	}
+	var buffer bytes.Buffer
+	if _, err := buffer.ReadFrom(block.Body); err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: error in reading key share")
+	}
+	data := buffer.Bytes()
+	if len(data) < 4 || data[0] != keyShareVersion {
+		return nil, errors.New("gopenpgp: unsupported key share format")
+	}
+	fingerprintLength := int(data[3])
+	if len(data) <= 4+fingerprintLength || data[1] < 2 || data[2] == 0 {
+		return nil, errors.New("gopenpgp: malformed key share")
+	}
+	return &keyShare{
+		threshold:   int(data[1]),
+		fingerprint: data[4 : 4+fingerprintLength],
+		share: internal.ShamirShare{
+			X: data[2],
+			Y: data[4+fingerprintLength:],
+		},
+	}, nil
+}
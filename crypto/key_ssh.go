@@ -0,0 +1,84 @@
+package crypto
+
+import (
+	"encoding/pem"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ssh"
+)
+
+// NewKeyFromSSHPrivateKey creates a v4 OpenPGP key from an OpenSSH private key, e.g.,
+// the content of ~/.ssh/id_ed25519, such that the same key material
+// can be used for SSH and OpenPGP signatures.
+// Ed25519, RSA, and ECDSA keys on the NIST curves are supported.
+// The passphrase decrypts the OpenSSH key and is ignored if the key is not encrypted.
+// The SSH key becomes the primary key, which can sign and certify, with the given user id.
+// Since the creation time is part of the OpenPGP fingerprint, converting the same SSH key
+// with the same creation time results in the same OpenPGP key.
+// The returned key is unlocked.
+func NewKeyFromSSHPrivateKey(sshPrivateKey, passphrase []byte, name, email string, creationTime int64) (*Key, error) {
+	var rawKey interface{}
+	var err error
+	if len(passphrase) > 0 {
+		rawKey, err = ssh.ParseRawPrivateKeyWithPassphrase(sshPrivateKey, passphrase)
+	} else {
+		rawKey, err = ssh.ParseRawPrivateKey(sshPrivateKey)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: error in parsing ssh private key")
+	}
+	privateKey, err := privateKeyFromStandard(rawKey, time.Unix(creationTime, 0))
+	if err != nil {
+		return nil, err
+	}
+	return newKeyFromPrimaryKey(privateKey, name, email, creationTime)
+}
+
+// GetSSHPublicKey returns the primary key in the OpenSSH authorized_keys format,
+// followed by the comment if it is not empty.
+// Ed25519, RSA, and ECDSA keys on the NIST curves are supported.
+func (key *Key) GetSSHPublicKey(comment string) (string, error) {
+	cryptoPublicKey, err := standardPublicKey(key.entity.PrimaryKey)
+	if err != nil {
+		return "", err
+	}
+	sshPublicKey, err := ssh.NewPublicKey(cryptoPublicKey)
+	if err != nil {
+		return "", errors.Wrap(err, "gopenpgp: error in converting public key to ssh")
+	}
+	authorizedKey := strings.TrimSuffix(string(ssh.MarshalAuthorizedKey(sshPublicKey)), "\n")
+	if comment != "" {
+		authorizedKey += " " + comment
+	}
+	return authorizedKey, nil
+}
+
+// GetSSHPrivateKey returns the primary key in the OpenSSH private key format,
+// encrypted with the passphrase if it is not empty.
+// Ed25519, RSA, and ECDSA keys on the NIST curves are supported.
+// The key must be unlocked.
+func (key *Key) GetSSHPrivateKey(comment string, passphrase []byte) ([]byte, error) {
+	unlocked, err := key.IsUnlocked()
+	if err != nil {
+		return nil, err
+	}
+	if !unlocked {
+		return nil, errors.New("gopenpgp: the key must be unlocked to export it to ssh")
+	}
+	cryptoPrivateKey, err := standardPrivateKey(key.entity.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	var block *pem.Block
+	if len(passphrase) > 0 {
+		block, err = ssh.MarshalPrivateKeyWithPassphrase(cryptoPrivateKey, comment, passphrase)
+	} else {
+		block, err = ssh.MarshalPrivateKey(cryptoPrivateKey, comment)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: error in converting private key to ssh")
+	}
+	return pem.EncodeToMemory(block), nil
+}
@@ -0,0 +1,200 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/binary"
+	"math/big"
+	"math/bits"
+	"time"
+
+	pgpEcdsa "github.com/ProtonMail/go-crypto/openpgp/ecdsa"
+	pgpEd25519 "github.com/ProtonMail/go-crypto/openpgp/ed25519"
+	"github.com/ProtonMail/go-crypto/openpgp/eddsa"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	openpgp "github.com/ProtonMail/go-crypto/openpgp/v2"
+	"github.com/ProtonMail/gopenpgp/v3/constants"
+	"github.com/ProtonMail/gopenpgp/v3/profile"
+	"github.com/pkg/errors"
+)
+
+// Conversion between OpenPGP keys and the keys of the standard library.
+
+// OpenPGP object identifiers of the curves of the standard library, see RFC 9580 section 9.2.
+var (
+	curveOidP256    = []byte{0x2a, 0x86, 0x48, 0xce, 0x3d, 0x03, 0x01, 0x07}
+	curveOidP384    = []byte{0x2b, 0x81, 0x04, 0x00, 0x22}
+	curveOidP521    = []byte{0x2b, 0x81, 0x04, 0x00, 0x23}
+	curveOidEd25519 = []byte{0x2b, 0x06, 0x01, 0x04, 0x01, 0xda, 0x47, 0x0f, 0x01}
+)
+
+// newKeyFromPrimaryKey creates a v4 key with the given private primary key,
+// which can sign and certify, and the user id self-certified at creationTime.
+func newKeyFromPrimaryKey(privateKey *packet.PrivateKey, name, email string, creationTime int64) (*Key, error) {
+	entity := &openpgp.Entity{
+		PrimaryKey: &privateKey.PublicKey,
+		PrivateKey: privateKey,
+		Identities: make(map[string]*openpgp.Identity),
+	}
+	config := profile.Default().KeyGenerationConfig(constants.StandardSecurity)
+	config.V6Keys = false
+	config.Time = NewConstantClock(creationTime)
+	config.DefaultHash = selfSignatureHash(entity.PrimaryKey, config.Hash())
+	if err := entity.AddUserId(name, "", email, config); err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: error in adding user id")
+	}
+	return NewKeyFromEntity(entity)
+}
+
+// publicKeyFromStandard converts a public key of the standard library into a v4 OpenPGP public key.
+// Ed25519 keys use the EdDSA algorithm, which is supported by v4 implementations.
+func publicKeyFromStandard(publicKey crypto.PublicKey, creationTime time.Time) (*packet.PublicKey, error) {
+	switch standardKey := publicKey.(type) {
+	case *rsa.PublicKey:
+		return packet.NewRSAPublicKey(creationTime, standardKey), nil
+	case *ecdsa.PublicKey:
+		oid, err := standardCurveOid(standardKey.Curve)
+		if err != nil {
+			return nil, err
+		}
+		//nolint:staticcheck // the uncompressed point encoding is required by OpenPGP
+		point := elliptic.Marshal(standardKey.Curve, standardKey.X, standardKey.Y)
+		return newV4PublicKey(creationTime, packet.PubKeyAlgoECDSA, oid, point)
+	case ed25519.PublicKey:
+		// Legacy EdDSA points are prefixed with 0x40.
+		point := append([]byte{0x40}, standardKey...)
+		return newV4PublicKey(creationTime, packet.PubKeyAlgoEdDSA, curveOidEd25519, point)
+	}
+	return nil, errors.Errorf("gopenpgp: unsupported public key type %T", publicKey)
+}
+
+// privateKeyFromStandard converts a private key of the standard library,
+// e.g., parsed by ssh.ParseRawPrivateKey, into a v4 OpenPGP private key.
+// Ed25519 keys use the EdDSA algorithm, which is supported by v4 implementations.
+func privateKeyFromStandard(rawKey interface{}, creationTime time.Time) (*packet.PrivateKey, error) {
+	switch standardKey := rawKey.(type) {
+	case *rsa.PrivateKey:
+		return packet.NewRSAPrivateKey(creationTime, standardKey), nil
+	case *ecdsa.PrivateKey:
+		publicKey, err := publicKeyFromStandard(&standardKey.PublicKey, creationTime)
+		if err != nil {
+			return nil, err
+		}
+		privateKey := pgpEcdsa.NewPrivateKey(*publicKey.PublicKey.(*pgpEcdsa.PublicKey))
+		privateKey.D = new(big.Int).Set(standardKey.D)
+		return packet.NewSignerPrivateKey(creationTime, privateKey), nil
+	case *ed25519.PrivateKey:
+		return privateKeyFromStandard(*standardKey, creationTime)
+	case ed25519.PrivateKey:
+		publicKey, err := publicKeyFromStandard(standardKey.Public(), creationTime)
+		if err != nil {
+			return nil, err
+		}
+		privateKey := eddsa.NewPrivateKey(*publicKey.PublicKey.(*eddsa.PublicKey))
+		privateKey.D = append([]byte(nil), standardKey.Seed()...)
+		return packet.NewSignerPrivateKey(creationTime, privateKey), nil
+	}
+	return nil, errors.Errorf("gopenpgp: unsupported private key type %T", rawKey)
+}
+
+// newV4PublicKey parses a v4 elliptic curve public key with the given curve and point.
+func newV4PublicKey(creationTime time.Time, algorithm packet.PublicKeyAlgorithm, oid, point []byte) (*packet.PublicKey, error) {
+	// The body of a public key packet is the version, the creation time,
+	// the algorithm, the curve oid with a length octet, and the point as MPI.
+	body := make([]byte, 6, 6+1+len(oid)+2+len(point))
+	body[0] = 4
+	binary.BigEndian.PutUint32(body[1:], uint32(creationTime.Unix()))
+	body[5] = byte(algorithm)
+	body = append(body, byte(len(oid)))
+	body = append(body, oid...)
+	bitLength := (len(point)-1)*8 + bits.Len8(point[0])
+	body = append(body, byte(bitLength>>8), byte(bitLength))
+	body = append(body, point...)
+
+	serialized := make([]byte, 6, 6+len(body))
+	serialized[0], serialized[1] = 0xc0|6, 0xff // public key packet
+	binary.BigEndian.PutUint32(serialized[2:], uint32(len(body)))
+	serialized = append(serialized, body...)
+	p, err := packet.Read(bytes.NewReader(serialized))
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: error in converting public key")
+	}
+	publicKey, ok := p.(*packet.PublicKey)
+	if !ok {
+		return nil, errors.New("gopenpgp: error in converting public key")
+	}
+	return publicKey, nil
+}
+
+func standardCurveOid(curve elliptic.Curve) ([]byte, error) {
+	switch curve {
+	case elliptic.P256():
+		return curveOidP256, nil
+	case elliptic.P384():
+		return curveOidP384, nil
+	case elliptic.P521():
+		return curveOidP521, nil
+	}
+	return nil, errors.Errorf("gopenpgp: unsupported ecdsa curve %s", curve.Params().Name)
+}
+
+func standardCurve(publicKey *packet.PublicKey) (elliptic.Curve, error) {
+	curve, err := publicKey.Curve()
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: error in reading key curve")
+	}
+	switch curve {
+	case packet.CurveNistP256:
+		return elliptic.P256(), nil
+	case packet.CurveNistP384:
+		return elliptic.P384(), nil
+	case packet.CurveNistP521:
+		return elliptic.P521(), nil
+	}
+	return nil, errors.Errorf("gopenpgp: curve %s is not supported", curve)
+}
+
+// standardPublicKey converts an OpenPGP public key into a public key of the standard library.
+func standardPublicKey(publicKey *packet.PublicKey) (interface{}, error) {
+	switch pgpKey := publicKey.PublicKey.(type) {
+	case *rsa.PublicKey:
+		return pgpKey, nil
+	case *pgpEcdsa.PublicKey:
+		curve, err := standardCurve(publicKey)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: pgpKey.X, Y: pgpKey.Y}, nil
+	case *eddsa.PublicKey:
+		if curve, _ := publicKey.Curve(); curve != packet.Curve25519 {
+			return nil, errors.Errorf("gopenpgp: curve %s is not supported", curve)
+		}
+		return ed25519.PublicKey(pgpKey.X), nil
+	case *pgpEd25519.PublicKey:
+		return ed25519.PublicKey(pgpKey.Point), nil
+	}
+	return nil, errors.Errorf("gopenpgp: key algorithm %d is not supported", publicKey.PubKeyAlgo)
+}
+
+// standardPrivateKey converts an OpenPGP private key into a private key of the standard library.
+func standardPrivateKey(privateKey *packet.PrivateKey) (interface{}, error) {
+	publicKey, err := standardPublicKey(&privateKey.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	switch pgpKey := privateKey.PrivateKey.(type) {
+	case *rsa.PrivateKey:
+		return pgpKey, nil
+	case *pgpEcdsa.PrivateKey:
+		return &ecdsa.PrivateKey{PublicKey: *publicKey.(*ecdsa.PublicKey), D: pgpKey.D}, nil
+	case *eddsa.PrivateKey:
+		return ed25519.NewKeyFromSeed(pgpKey.D), nil
+	case *pgpEd25519.PrivateKey:
+		return ed25519.PrivateKey(pgpKey.Key), nil
+	}
+	return nil, errors.Errorf("gopenpgp: key algorithm %d is not supported", privateKey.PubKeyAlgo)
+}
@@ -0,0 +1,80 @@
+package crypto
+
+import (
+	"github.com/ProtonMail/gopenpgp/v3/constants"
+	"github.com/pkg/errors"
+)
+
+// TransparencyAttestation is the result of checking the inclusion of a key
+// in a key transparency log.
+type TransparencyAttestation struct {
+	// Status is the inclusion status, see constants.Transparency...
+	Status int8
+	// LogID identifies the transparency log, e.g., its URL or the hash of its public key.
+	LogID string
+	// Proof is the serialized inclusion proof in the format of the log, if any.
+	Proof []byte
+}
+
+// KeyTransparencyVerifier checks that keys are included in a key transparency log,
+// e.g., a client of the transparency service of a key directory.
+// Not supported on go-mobile clients.
+type KeyTransparencyVerifier interface {
+	// VerifyKeyTransparency returns the attestation for the inclusion of the key
+	// in the log at the unix time.
+	// An error aborts the verification. Unreachable logs should instead be reported
+	// with the status constants.TransparencyUnverifiable.
+	VerifyKeyTransparency(key *Key, unixTime int64) (*TransparencyAttestation, error)
+}
+
+// KeyTransparencyVerifierFunc is an adapter to use a function as KeyTransparencyVerifier.
+// Not supported on go-mobile clients.
+type KeyTransparencyVerifierFunc func(key *Key, unixTime int64) (*TransparencyAttestation, error)
+
+// VerifyKeyTransparency calls f(key, unixTime).
+func (f KeyTransparencyVerifierFunc) VerifyKeyTransparency(key *Key, unixTime int64) (*TransparencyAttestation, error) {
+	return f(key, unixTime)
+}
+
+// SignedByTransparency returns the transparency attestation of the key that was used
+// to verify the selected signature, or nil if no key transparency verifier was set
+// or no key was found.
+func (vr *VerifyResult) SignedByTransparency() *TransparencyAttestation {
+	if vr.selectedSignature == nil {
+		return nil
+	}
+	return vr.selectedSignature.Transparency
+}
+
+// SignedByTransparencyStatus returns the inclusion status of the key that was used
+// to verify the selected signature in the key transparency log, see constants.Transparency...
+// Returns constants.TransparencyUnknown if no key transparency verifier was set or no key was found.
+func (vr *VerifyResult) SignedByTransparencyStatus() int8 {
+	attestation := vr.SignedByTransparency()
+	if attestation == nil {
+		return constants.TransparencyUnknown
+	}
+	return attestation.Status
+}
+
+// attestSigners attaches the transparency attestations of the signers to the signatures.
+// Each signer is checked once.
+func attestSigners(verifier KeyTransparencyVerifier, signatures []*VerifiedSignature, verifyTime int64) error {
+	attestations := make(map[string]*TransparencyAttestation)
+	for _, signature := range signatures {
+		if signature.SignedBy == nil {
+			continue
+		}
+		fingerprint := signature.SignedBy.GetFingerprint()
+		attestation, ok := attestations[fingerprint]
+		if !ok {
+			var err error
+			if attestation, err = verifier.VerifyKeyTransparency(signature.SignedBy, verifyTime); err != nil {
+				return errors.Wrap(err, "gopenpgp: key transparency verification failed")
+			}
+			attestations[fingerprint] = attestation
+		}
+		signature.Transparency = attestation
+	}
+	return nil
+}
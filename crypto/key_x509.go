@@ -0,0 +1,151 @@
+package crypto
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// NewKeyFromX509PrivateKey creates a v4 OpenPGP key from the private key of an X.509 certificate,
+// i.e., a PEM or DER encoded PKCS #8, PKCS #1, or SEC 1 private key, such that
+// the same key material can be used for X.509 and OpenPGP signatures.
+// Ed25519, RSA, and ECDSA keys on the NIST curves are supported.
+// The X.509 key becomes the primary key, which can sign and certify, with the given user id.
+// Since the creation time is part of the OpenPGP fingerprint, converting the same X.509 key
+// with the same creation time results in the same OpenPGP key.
+// The returned key is unlocked.
+func NewKeyFromX509PrivateKey(x509PrivateKey []byte, name, email string, creationTime int64) (*Key, error) {
+	der := decodePEMOrDER(x509PrivateKey)
+	var rawKey interface{}
+	var err error
+	if rawKey, err = x509.ParsePKCS8PrivateKey(der); err != nil {
+		if rawKey, err = x509.ParsePKCS1PrivateKey(der); err != nil {
+			if rawKey, err = x509.ParseECPrivateKey(der); err != nil {
+				return nil, errors.New("gopenpgp: error in parsing x509 private key")
+			}
+		}
+	}
+	privateKey, err := privateKeyFromStandard(rawKey, time.Unix(creationTime, 0))
+	if err != nil {
+		return nil, err
+	}
+	return newKeyFromPrimaryKey(privateKey, name, email, creationTime)
+}
+
+// GetX509PrivateKey returns the primary key as PEM encoded PKCS #8 private key.
+// Ed25519, RSA, and ECDSA keys on the NIST curves are supported.
+// The key must be unlocked.
+func (key *Key) GetX509PrivateKey() ([]byte, error) {
+	unlocked, err := key.IsUnlocked()
+	if err != nil {
+		return nil, err
+	}
+	if !unlocked {
+		return nil, errors.New("gopenpgp: the key must be unlocked to export it to x509")
+	}
+	cryptoPrivateKey, err := standardPrivateKey(key.entity.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(cryptoPrivateKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: error in converting private key to x509")
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+// GetX509Certificate returns a DER encoded self-signed X.509 certificate for the primary key,
+// with the given common name, which is valid from notBefore until notAfter.
+// Ed25519, RSA, and ECDSA keys on the NIST curves are supported,
+// as well as primary keys with an external private key that can sign.
+// The key must be unlocked.
+func (key *Key) GetX509Certificate(commonName string, notBefore, notAfter int64) ([]byte, error) {
+	unlocked, err := key.IsUnlocked()
+	if err != nil {
+		return nil, err
+	}
+	if !unlocked {
+		return nil, errors.New("gopenpgp: the key must be unlocked to create a x509 certificate")
+	}
+	signer, err := standardSigner(key)
+	if err != nil {
+		return nil, err
+	}
+	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: error in generating certificate serial number")
+	}
+	template := &x509.Certificate{
+		SerialNumber: serialNumber,
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Unix(notBefore, 0),
+		NotAfter:     time.Unix(notAfter, 0),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	if _, ok := signer.Public().(*rsa.PublicKey); ok {
+		template.KeyUsage |= x509.KeyUsageKeyEncipherment
+	}
+	certificate, err := x509.CreateCertificate(rand.Reader, template, template, signer.Public(), signer)
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: error in creating x509 certificate")
+	}
+	return certificate, nil
+}
+
+// MatchesX509Certificate returns true if the primary key or a subkey has the key material
+// of the PEM or DER encoded X.509 certificate.
+func (key *Key) MatchesX509Certificate(x509Certificate []byte) (bool, error) {
+	certificate, err := x509.ParseCertificate(decodePEMOrDER(x509Certificate))
+	if err != nil {
+		return false, errors.Wrap(err, "gopenpgp: error in parsing x509 certificate")
+	}
+	comparable, ok := certificate.PublicKey.(interface{ Equal(crypto.PublicKey) bool })
+	if !ok {
+		return false, errors.Errorf("gopenpgp: unsupported x509 public key type %T", certificate.PublicKey)
+	}
+	if publicKey, err := standardPublicKey(key.entity.PrimaryKey); err == nil && comparable.Equal(publicKey) {
+		return true, nil
+	}
+	for _, subkey := range key.entity.Subkeys {
+		if publicKey, err := standardPublicKey(subkey.PublicKey); err == nil && comparable.Equal(publicKey) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// standardSigner returns a crypto.Signer for the private primary key,
+// which may be an external private key.
+func standardSigner(key *Key) (crypto.Signer, error) {
+	if external, ok := key.entity.PrivateKey.PrivateKey.(externalSignerDecrypter); ok {
+		signer, ok := external.ExternalPrivateKey.(crypto.Signer)
+		if !ok {
+			return nil, errors.New("gopenpgp: external private key cannot sign")
+		}
+		return signer, nil
+	}
+	cryptoPrivateKey, err := standardPrivateKey(key.entity.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := cryptoPrivateKey.(crypto.Signer)
+	if !ok {
+		return nil, errors.Errorf("gopenpgp: unsupported private key type %T", cryptoPrivateKey)
+	}
+	return signer, nil
+}
+
+// decodePEMOrDER returns the content of the first PEM block, or data if it is not PEM encoded.
+func decodePEMOrDER(data []byte) []byte {
+	if block, _ := pem.Decode(data); block != nil {
+		return block.Bytes
+	}
+	return data
+}
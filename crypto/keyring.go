@@ -3,6 +3,7 @@ package crypto
 import (
 	"bytes"
 	"encoding/json"
+	"sync"
 	"time"
 
 	"github.com/ProtonMail/go-crypto/openpgp/packet"
@@ -11,12 +12,22 @@ import (
 )
 
 // KeyRing contains multiple private and public keys.
+// A key ring can be used by many goroutines at once, as long as it is not modified,
+// e.g., with AddKey. Use SyncKeyRing to update a key ring that is in use.
 type KeyRing struct {
 	// PGP entities in this keyring.
 	entities openpgp.EntityList
 
 	// FirstKeyID as obtained from API to match salt
 	FirstKeyID string
+
+	// mutex guards the index, which is built on first use and holds the
+	// isolated copies of the entities, and the entities added by a key resolution.
+	mutex sync.Mutex
+	index *keyRingIndex
+
+	// resolution adds keys from a KeyResolver on lookup, if not nil.
+	resolution *keyResolution
 }
 
 // Identity contains the name and the email of a key holder.
@@ -53,7 +64,7 @@ func (keyRing *KeyRing) AddKey(key *Key) error {
 // NewKeyRingFromBinary creates a new keyring with all the keys contained in the unarmored binary data.
 // Note that it accepts only unlocked or public keys, as KeyRing cannot contain locked keys.
 func NewKeyRingFromBinary(binKeys []byte) (*KeyRing, error) {
-	entities, err := openpgp.ReadKeyRing(bytes.NewReader(binKeys))
+	entities, err := readKeyRing(bytes.NewReader(binKeys), false)
 	if err != nil {
 		return nil, errors.Wrap(err, "gopenpgp: error in reading keyring")
 	}
@@ -95,7 +106,7 @@ func (keyRing *KeyRing) GetKey(n int) (*Key, error) {
 
 func (keyRing *KeyRing) signingEntities() ([]*openpgp.Entity, error) {
 	var signEntity []*openpgp.Entity
-	for _, e := range keyRing.entities {
+	for _, e := range keyRing.isolatedEntities() {
 		// Entity.PrivateKey must be a signing key
 		if e.PrivateKey != nil && !e.PrivateKey.Encrypted {
 			signEntity = append(signEntity, e)
@@ -106,6 +117,20 @@ func (keyRing *KeyRing) signingEntities() ([]*openpgp.Entity, error) {
 	return signEntity, nil
 }
 
+// keyRingWithKey returns a new key ring with the keys of keyRing and the given key.
+// The keyRing is not modified, since it might be shared by the caller.
+func keyRingWithKey(keyRing *KeyRing, key *Key) (*KeyRing, error) {
+	newKeyRing := &KeyRing{}
+	newKeyRing.entities = append(newKeyRing.entities, keyRing.getEntities()...)
+	if key == nil {
+		return newKeyRing, nil
+	}
+	if err := newKeyRing.AddKey(key); err != nil {
+		return nil, err
+	}
+	return newKeyRing, nil
+}
+
 // getEntities returns the internal EntityList if the key ring is not nil.
 func (keyRing *KeyRing) getEntities() openpgp.EntityList {
 	if keyRing == nil {
@@ -114,20 +139,27 @@ func (keyRing *KeyRing) getEntities() openpgp.EntityList {
 	return keyRing.entities
 }
 
+// isolatedEntities returns isolated copies of the entities if the key ring is not nil,
+// which must be used for all operations of go-crypto that verify the entities,
+// such that the key ring can be used by many goroutines at once.
+// The copies are made once per change of the key ring and shared, see getIndex.
+func (keyRing *KeyRing) isolatedEntities() openpgp.EntityList {
+	if keyRing == nil || len(keyRing.entities) == 0 {
+		return nil
+	}
+	return keyRing.getIndex().entities
+}
+
 // Serialize serializes a KeyRing to binary data.
 func (keyRing *KeyRing) Serialize() ([]byte, error) {
 	var buffer bytes.Buffer
 
 	for _, entity := range keyRing.entities {
-		var err error
-		if entity.PrivateKey == nil {
-			err = entity.Serialize(&buffer)
-		} else {
-			err = entity.SerializePrivateWithoutSigning(&buffer, nil)
-		}
+		serialized, err := serializeEntity(entity)
 		if err != nil {
 			return nil, errors.Wrap(err, "gopenpgp: error in serializing keyring")
 		}
+		buffer.Write(serialized)
 	}
 
 	return buffer.Bytes(), nil
@@ -152,7 +184,7 @@ func (keyRing *KeyRing) CountDecryptionEntities(unixTime int64) int {
 	if unixTime != 0 {
 		checkTime = time.Unix(unixTime, 0)
 	}
-	for _, entity := range keyRing.entities {
+	for _, entity := range keyRing.isolatedEntities() {
 		decryptionKeys := entity.DecryptionKeys(0, checkTime, &packet.Config{})
 		count += len(decryptionKeys)
 	}
@@ -187,8 +219,8 @@ func (keyRing *KeyRing) GetIdentitiesJson() []byte {
 
 // CanVerify returns true if any of the keys in the keyring can be used for verification.
 func (keyRing *KeyRing) CanVerify(unixTime int64) bool {
-	keys := keyRing.GetKeys()
-	for _, key := range keys {
+	for _, entity := range keyRing.isolatedEntities() {
+		key := &Key{entity}
 		if key.CanVerify(unixTime) {
 			return true
 		}
@@ -198,8 +230,8 @@ func (keyRing *KeyRing) CanVerify(unixTime int64) bool {
 
 // CanEncrypt returns true if any of the keys in the keyring can be used for encryption.
 func (keyRing *KeyRing) CanEncrypt(unixTime int64) bool {
-	keys := keyRing.GetKeys()
-	for _, key := range keys {
+	for _, entity := range keyRing.isolatedEntities() {
+		key := &Key{entity}
 		if key.CanEncrypt(unixTime) {
 			return true
 		}
@@ -207,6 +239,48 @@ func (keyRing *KeyRing) CanEncrypt(unixTime int64) bool {
 	return false
 }
 
+// FilterValid returns a key ring with the keys that have a valid primary key or subkey
+// with the capability at unixTime, i.e., that is neither expired nor revoked.
+// The capability is either KeyCapabilitySign or KeyCapabilityEncrypt.
+// The returned keys only contain the subkeys with the capability, such that
+// any key selected for signing or encryption is valid at unixTime.
+func (keyRing *KeyRing) FilterValid(capability int, unixTime int64) (*KeyRing, error) {
+	if capability != KeyCapabilitySign && capability != KeyCapabilityEncrypt {
+		return nil, errors.Errorf("gopenpgp: invalid key capability %d", capability)
+	}
+	date := time.Unix(unixTime, 0)
+	// canUse checks a single key of the entity as go-crypto selects keys for the capability.
+	canUse := func(entity *openpgp.Entity, keyID uint64) bool {
+		var key openpgp.Key
+		var ok bool
+		if capability == KeyCapabilitySign {
+			key, ok = entity.SigningKeyById(date, keyID, nil)
+		} else {
+			key, ok = entity.EncryptionKey(date, nil)
+		}
+		return ok && key.PublicKey.KeyId == keyID
+	}
+	filtered := &KeyRing{FirstKeyID: keyRing.FirstKeyID}
+	for _, entity := range keyRing.getEntities() {
+		// The subkeys of the copy are replaced, thus it is not shared with the key ring.
+		entity = isolateEntity(entity)
+		subkeys := entity.Subkeys
+		var validSubkeys []openpgp.Subkey
+		for _, subkey := range subkeys {
+			entity.Subkeys = []openpgp.Subkey{subkey}
+			if canUse(entity, subkey.PublicKey.KeyId) {
+				validSubkeys = append(validSubkeys, subkey)
+			}
+		}
+		entity.Subkeys = nil
+		if len(validSubkeys) > 0 || canUse(entity, entity.PrimaryKey.KeyId) {
+			entity.Subkeys = validSubkeys
+			filtered.entities = append(filtered.entities, entity)
+		}
+	}
+	return filtered, nil
+}
+
 // GetKeyIDs returns array of IDs of keys in this KeyRing.
 // Not supported on go-mobile clients.
 func (keyRing *KeyRing) GetKeyIDs() []uint64 {
@@ -320,6 +394,7 @@ func (keyRing *KeyRing) Copy() (*KeyRing, error) {
 		if err != nil {
 			return nil, errors.Wrap(err, "gopenpgp: unable to copy key: error in reading entity")
 		}
+		setCardSerialNumbers(entities[id], cardSerialNumbers(entity))
 	}
 	newKeyRing.entities = entities
 	newKeyRing.FirstKeyID = keyRing.FirstKeyID
@@ -0,0 +1,128 @@
+package crypto
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/ProtonMail/gopenpgp/v3/constants"
+)
+
+// KeyExpiry is a primary key or subkey listed by (KeyRing).ExpiryReport.
+type KeyExpiry struct {
+	// Fingerprint is the hex encoded fingerprint of the primary key.
+	Fingerprint string `json:"fingerprint"`
+	// KeyID is the hex encoded key id of the listed primary key or subkey.
+	KeyID string `json:"keyId"`
+	// IsSubkey is true if the listed key is a subkey.
+	IsSubkey bool `json:"isSubkey"`
+	// Status is one of constants.KeyExpiry...
+	Status string `json:"status"`
+	// ExpirationTime is the last unix time at which the key is valid,
+	// or zero if the key is revoked and does not expire.
+	ExpirationTime int64 `json:"expirationTime"`
+}
+
+// KeyExpiryReport lists the keys found by (KeyRing).ExpiryReport.
+type KeyExpiryReport struct {
+	// Keys contains the listed keys in key ring order, each primary key before its subkeys.
+	Keys []*KeyExpiry `json:"keys"`
+}
+
+// ExpiryReport lists the primary keys and subkeys of the key ring that are revoked or expired
+// at unixTime, or that expire within the window after unixTime, e.g., for tools that rotate
+// keys before they expire.
+// The subkeys of revoked or expired primary keys are not listed.
+// Not supported on go-mobile clients use keyRing.ExpiryReportJson() instead.
+func (keyRing *KeyRing) ExpiryReport(window time.Duration, unixTime int64) *KeyExpiryReport {
+	report := &KeyExpiryReport{Keys: []*KeyExpiry{}}
+	current := time.Unix(unixTime, 0)
+	for _, entity := range keyRing.isolatedEntities() {
+		fingerprint := hex.EncodeToString(entity.PrimaryKey.Fingerprint)
+		add := func(publicKey *packet.PublicKey, status string, expiration time.Time) {
+			keyExpiry := &KeyExpiry{
+				Fingerprint: fingerprint,
+				KeyID:       keyIDToHex(publicKey.KeyId),
+				IsSubkey:    publicKey != entity.PrimaryKey,
+				Status:      status,
+			}
+			if !expiration.IsZero() {
+				keyExpiry.ExpirationTime = expiration.Unix()
+			}
+			report.Keys = append(report.Keys, keyExpiry)
+		}
+		primarySelfSignature, err := entity.PrimarySelfSignature(time.Time{}, nil)
+		if err != nil {
+			continue
+		}
+		expiration := keyExpirationTime(entity.PrimaryKey, primarySelfSignature)
+		if entity.Revoked(current) {
+			add(entity.PrimaryKey, constants.KeyExpiryRevoked, expiration)
+			continue
+		}
+		if status := expiryStatus(expiration, current, window); status != "" {
+			add(entity.PrimaryKey, status, expiration)
+			if status == constants.KeyExpiryExpired {
+				continue
+			}
+		}
+		for index := range entity.Subkeys {
+			subkey := &entity.Subkeys[index]
+			binding, err := subkey.LatestValidBindingSignature(time.Time{}, nil)
+			if err != nil {
+				continue
+			}
+			expiration := keyExpirationTime(subkey.PublicKey, binding)
+			if subkey.Revoked(binding, current) {
+				add(subkey.PublicKey, constants.KeyExpiryRevoked, expiration)
+			} else if status := expiryStatus(expiration, current, window); status != "" {
+				add(subkey.PublicKey, status, expiration)
+			}
+		}
+	}
+	return report
+}
+
+// ExpiryReportJson returns the expiry report of the key ring as JSON, see ExpiryReport.
+// The window is given in seconds.
+// If an error occurs it returns nil.
+// Helper function for go-mobile clients.
+func (keyRing *KeyRing) ExpiryReportJson(windowSecs int64, unixTime int64) []byte {
+	report, err := json.Marshal(keyRing.ExpiryReport(time.Duration(windowSecs)*time.Second, unixTime))
+	if err != nil {
+		return nil
+	}
+	return report
+}
+
+// keyExpirationTime returns the time at which the key expires according to its self-signature,
+// i.e., the earlier of the key expiration and the signature expiration,
+// or the zero time if the key does not expire.
+func keyExpirationTime(publicKey *packet.PublicKey, selfSignature *packet.Signature) time.Time {
+	var expiration time.Time
+	if selfSignature.KeyLifetimeSecs != nil && *selfSignature.KeyLifetimeSecs != 0 {
+		expiration = publicKey.CreationTime.Add(time.Duration(*selfSignature.KeyLifetimeSecs) * time.Second)
+	}
+	if selfSignature.SigLifetimeSecs != nil && *selfSignature.SigLifetimeSecs != 0 {
+		sigExpiration := selfSignature.CreationTime.Add(time.Duration(*selfSignature.SigLifetimeSecs) * time.Second)
+		if expiration.IsZero() || sigExpiration.Before(expiration) {
+			expiration = sigExpiration
+		}
+	}
+	return expiration
+}
+
+// expiryStatus returns the status of a key with the expiration time,
+// or an empty string if the key does not expire within the window after current.
+func expiryStatus(expiration, current time.Time, window time.Duration) string {
+	switch {
+	case expiration.IsZero():
+		return ""
+	case current.After(expiration):
+		return constants.KeyExpiryExpired
+	case !current.Add(window).Before(expiration):
+		return constants.KeyExpiryExpiring
+	}
+	return ""
+}
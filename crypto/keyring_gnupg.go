@@ -0,0 +1,181 @@
+package crypto
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// keyboxBlobHeaderSize is the size of the blob length, type, and version in a keybox.
+	keyboxBlobHeaderSize = 6
+	keyboxBlobFirst      = 1
+	keyboxBlobOpenPGP    = 2
+	keyboxMagic          = "KBXf"
+)
+
+// KeyRingImport contains the certificates read from a GnuPG keybox or keyring file.
+// Certificates that cannot be read are skipped, such that one broken certificate
+// does not prevent using the others.
+// Not supported on go-mobile clients.
+type KeyRingImport struct {
+	// KeyRing contains the certificates that were read.
+	KeyRing *KeyRing
+	// Errors contains an error for each certificate that was skipped,
+	// which reports the offset of the certificate in the file.
+	Errors []error
+}
+
+// NewKeyRingFromKeybox reads the OpenPGP certificates from a GnuPG keybox,
+// i.e., the pubring.kbx file of GnuPG 2.1 and later.
+// X.509 certificates in the keybox are ignored.
+// It fails if the data is not a keybox.
+// Not supported on go-mobile clients.
+func NewKeyRingFromKeybox(r io.Reader) (*KeyRingImport, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: error in reading keybox")
+	}
+	// The keybox starts with a header blob with the magic at offset 8.
+	if len(data) < 12 || data[4] != keyboxBlobFirst || string(data[8:12]) != keyboxMagic {
+		return nil, errors.New("gopenpgp: the data is not a keybox")
+	}
+	result := &KeyRingImport{KeyRing: &KeyRing{}}
+	for offset := 0; offset < len(data); {
+		if len(data)-offset < keyboxBlobHeaderSize {
+			result.Errors = append(result.Errors, errors.Errorf("gopenpgp: truncated keybox blob at offset %d", offset))
+			break
+		}
+		length := int(binary.BigEndian.Uint32(data[offset:]))
+		if length < keyboxBlobHeaderSize || length > len(data)-offset {
+			result.Errors = append(result.Errors, errors.Errorf("gopenpgp: invalid keybox blob length at offset %d", offset))
+			break
+		}
+		blob := data[offset : offset+length]
+		if blob[4] == keyboxBlobOpenPGP {
+			if err := result.importKeyboxBlob(blob); err != nil {
+				result.Errors = append(result.Errors, errors.Wrapf(err, "gopenpgp: skipped certificate at offset %d", offset))
+			}
+		}
+		offset += length
+	}
+	return result, nil
+}
+
+// NewKeyRingFromGnuPGKeyRing reads the certificates from a legacy GnuPG keyring,
+// i.e., the pubring.gpg file of GnuPG 2.0 and earlier, which is a sequence of
+// transferable public keys with GnuPG trust packets.
+// Not supported on go-mobile clients.
+func NewKeyRingFromGnuPGKeyRing(r io.Reader) (*KeyRingImport, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: error in reading keyring")
+	}
+	result := &KeyRingImport{KeyRing: &KeyRing{}}
+	// Each certificate starts with a public or secret key packet.
+	start := 0
+	importUntil := func(end int) {
+		if end > start {
+			if err := result.importCertificate(data[start:end]); err != nil {
+				result.Errors = append(result.Errors, errors.Wrapf(err, "gopenpgp: skipped certificate at offset %d", start))
+			}
+		}
+		start = end
+	}
+	for offset := 0; offset < len(data); {
+		tag, headerLength, bodyLength, err := parsePacketHeader(data[offset:])
+		if err != nil {
+			// The following packets cannot be found without a valid packet header.
+			importUntil(offset)
+			result.Errors = append(result.Errors, errors.Wrapf(err, "gopenpgp: skipped data at offset %d", offset))
+			return result, nil
+		}
+		if tag == packetTagPublicKey || tag == packetTagSecretKey {
+			importUntil(offset)
+		}
+		offset += headerLength + bodyLength
+	}
+	importUntil(len(data))
+	return result, nil
+}
+
+// NewKeyRingFromGnuPGHome reads the certificates of the GnuPG home directory,
+// i.e., pubring.kbx, or pubring.gpg if there is no keybox.
+// If homeDir is empty, the directory in the GNUPGHOME environment variable
+// or the default home directory of GnuPG is used.
+// Secret keys are not read, since GnuPG 2.1 and later keep them in its agent.
+// Not supported on go-mobile clients.
+func NewKeyRingFromGnuPGHome(homeDir string) (*KeyRingImport, error) {
+	if homeDir == "" {
+		var err error
+		if homeDir, err = defaultGnuPGHome(); err != nil {
+			return nil, err
+		}
+	}
+	keybox, err := os.Open(filepath.Join(homeDir, "pubring.kbx"))
+	if err == nil {
+		defer func() { _ = keybox.Close() }()
+		return NewKeyRingFromKeybox(keybox)
+	}
+	if !os.IsNotExist(err) {
+		return nil, errors.Wrap(err, "gopenpgp: error in opening keybox")
+	}
+	keyRing, err := os.Open(filepath.Join(homeDir, "pubring.gpg"))
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: error in opening keyring")
+	}
+	defer func() { _ = keyRing.Close() }()
+	return NewKeyRingFromGnuPGKeyRing(keyRing)
+}
+
+// importKeyboxBlob adds the certificate in the keyblock of an OpenPGP blob.
+// After the blob header, the blob has flags, and the offset and length of the keyblock.
+func (result *KeyRingImport) importKeyboxBlob(blob []byte) error {
+	if len(blob) < 16 {
+		return errors.New("gopenpgp: truncated keybox blob")
+	}
+	if blob[5] != 1 {
+		return errors.Errorf("gopenpgp: unsupported keybox blob version %d", blob[5])
+	}
+	keyblockOffset := int(binary.BigEndian.Uint32(blob[8:]))
+	keyblockLength := int(binary.BigEndian.Uint32(blob[12:]))
+	if keyblockOffset > len(blob) || keyblockLength > len(blob)-keyblockOffset {
+		return errors.New("gopenpgp: invalid keyblock in keybox blob")
+	}
+	return result.importCertificate(blob[keyblockOffset : keyblockOffset+keyblockLength])
+}
+
+func (result *KeyRingImport) importCertificate(data []byte) error {
+	entities, err := readKeyRing(bytes.NewReader(data), false)
+	if err != nil {
+		return err
+	}
+	keyRing := &KeyRing{}
+	for _, entity := range entities {
+		if err := keyRing.AddKey(&Key{entity}); err != nil {
+			return err
+		}
+	}
+	result.KeyRing.entities = append(result.KeyRing.entities, keyRing.entities...)
+	return nil
+}
+
+// defaultGnuPGHome returns the GnuPG home directory like GnuPG does.
+func defaultGnuPGHome() (string, error) {
+	if homeDir := os.Getenv("GNUPGHOME"); homeDir != "" {
+		return homeDir, nil
+	}
+	if runtime.GOOS == "windows" {
+		return filepath.Join(os.Getenv("APPDATA"), "gnupg"), nil
+	}
+	userHome, err := os.UserHomeDir()
+	if err != nil {
+		return "", errors.Wrap(err, "gopenpgp: cannot find the GnuPG home directory")
+	}
+	return filepath.Join(userHome, ".gnupg"), nil
+}
@@ -0,0 +1,139 @@
+package crypto
+
+import (
+	"encoding/hex"
+	"strings"
+
+	openpgp "github.com/ProtonMail/go-crypto/openpgp/v2"
+	"github.com/pkg/errors"
+)
+
+// keyRingIndex maps the key ids, fingerprints, and email addresses of the keys
+// in a key ring to their entities, such that lookups do not scan the key ring.
+// The indexed entities are isolated copies of the entities of the key ring,
+// which are shared by all operations until keys are added, see isolateEntity.
+// The entities of each entry are in key ring order.
+type keyRingIndex struct {
+	// size is the number of entities when the index was built.
+	size int
+	// entities are the isolated copies in key ring order.
+	entities      openpgp.EntityList
+	byKeyID       map[uint64]openpgp.EntityList
+	byFingerprint map[string]*openpgp.Entity
+	byEmail       map[string]openpgp.EntityList
+}
+
+// newKeyRingIndex isolates the entities and indexes the primary keys and subkeys
+// by key id and fingerprint, and the user ids by lower case email address.
+func newKeyRingIndex(entities openpgp.EntityList) *keyRingIndex {
+	entities = isolateEntities(entities)
+	index := &keyRingIndex{
+		size:          len(entities),
+		entities:      entities,
+		byKeyID:       make(map[uint64]openpgp.EntityList, len(entities)),
+		byFingerprint: make(map[string]*openpgp.Entity, len(entities)),
+		byEmail:       make(map[string]openpgp.EntityList, len(entities)),
+	}
+	for _, entity := range entities {
+		index.addKey(entity, entity.PrimaryKey.KeyId, entity.PrimaryKey.Fingerprint)
+		for _, subkey := range entity.Subkeys {
+			index.addKey(entity, subkey.PublicKey.KeyId, subkey.PublicKey.Fingerprint)
+		}
+		for _, identity := range entity.Identities {
+			if identity.UserId == nil || identity.UserId.Email == "" {
+				continue
+			}
+			email := strings.ToLower(identity.UserId.Email)
+			if !containsEntity(index.byEmail[email], entity) {
+				index.byEmail[email] = append(index.byEmail[email], entity)
+			}
+		}
+	}
+	return index
+}
+
+func (index *keyRingIndex) addKey(entity *openpgp.Entity, keyID uint64, fingerprint []byte) {
+	if !containsEntity(index.byKeyID[keyID], entity) {
+		index.byKeyID[keyID] = append(index.byKeyID[keyID], entity)
+	}
+	hexFingerprint := hex.EncodeToString(fingerprint)
+	if _, ok := index.byFingerprint[hexFingerprint]; !ok {
+		index.byFingerprint[hexFingerprint] = entity
+	}
+}
+
+// containsEntity returns true if the entity is the last one of the list,
+// which is sufficient since entities are indexed one after the other.
+func containsEntity(entities openpgp.EntityList, entity *openpgp.Entity) bool {
+	return len(entities) > 0 && entities[len(entities)-1] == entity
+}
+
+// getIndex returns the index of the key ring, which is built on first use
+// and rebuilt when keys were added to the key ring, such that the entities
+// are isolated once per change of the key ring rather than once per operation.
+func (keyRing *KeyRing) getIndex() *keyRingIndex {
+	keyRing.mutex.Lock()
+	defer keyRing.mutex.Unlock()
+	if keyRing.index == nil || keyRing.index.size != len(keyRing.entities) {
+		keyRing.index = newKeyRingIndex(keyRing.entities)
+	}
+	return keyRing.index
+}
+
+// GetKeyByFingerprint returns the key in the key ring whose primary key or subkey
+// has the given hex encoded fingerprint.
+func (keyRing *KeyRing) GetKeyByFingerprint(fingerprint string) (*Key, error) {
+	entity := keyRing.getIndex().byFingerprint[strings.ToLower(fingerprint)]
+	if entity == nil {
+		return nil, errors.Errorf("gopenpgp: no key with fingerprint %s in the key ring", fingerprint)
+	}
+	return &Key{entity}, nil
+}
+
+// GetKeysByEmail returns a key ring with the keys that have a user id with the given email address.
+// Email addresses are compared case-insensitively.
+func (keyRing *KeyRing) GetKeysByEmail(email string) *KeyRing {
+	entities := keyRing.getIndex().byEmail[strings.ToLower(email)]
+	return &KeyRing{entities: append(openpgp.EntityList(nil), entities...)}
+}
+
+// keyRings combines key rings into an openpgp.KeyRing that looks up keys
+// with the indices of the key rings, in the order of the key rings.
+// The found entities are the isolated copies of the index, see isolateEntity.
+// Nil key rings are skipped.
+type keyRings []*KeyRing
+
+// KeysById implements openpgp.KeyRing.
+func (rings keyRings) KeysById(id uint64) (keys []openpgp.Key) {
+	for _, entities := range rings.entitiesByID(id) {
+		keys = append(keys, entities.KeysById(id)...)
+	}
+	return keys
+}
+
+// EntitiesById implements openpgp.KeyRing.
+func (rings keyRings) EntitiesById(id uint64) (entities []*openpgp.Entity) {
+	for _, ringEntities := range rings.entitiesByID(id) {
+		entities = append(entities, ringEntities...)
+	}
+	return entities
+}
+
+// entitiesByID returns the entities of each key ring that contain a key with the id.
+// As for openpgp.EntityList, the id 0 matches all keys.
+func (rings keyRings) entitiesByID(id uint64) []openpgp.EntityList {
+	var result []openpgp.EntityList
+	for _, keyRing := range rings {
+		if keyRing == nil {
+			continue
+		}
+		keyRing.resolve(id)
+		index := keyRing.getIndex()
+		if id == 0 {
+			result = append(result, index.entities)
+		} else if entities := index.byKeyID[id]; len(entities) > 0 {
+			result = append(result, entities)
+		}
+	}
+	return result
+}
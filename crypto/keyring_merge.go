@@ -0,0 +1,145 @@
+package crypto
+
+import (
+	"bytes"
+	"encoding/hex"
+	"sort"
+
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	openpgp "github.com/ProtonMail/go-crypto/openpgp/v2"
+	"github.com/pkg/errors"
+)
+
+// Merge returns a new key ring with the keys of both key rings, in which keys with
+// the same primary key are consolidated into one key, like the import of GnuPG.
+// The merged key has the user ids and subkeys of both keys, and the union of their
+// signatures without duplicates. Since the newest valid self-signatures take precedence,
+// merging an updated certificate, e.g., with a new expiration time or a revocation,
+// updates the key.
+// If only one of the keys is private, the merged key is private.
+// Neither key ring is modified.
+func (keyRing *KeyRing) Merge(other *KeyRing) (*KeyRing, error) {
+	merged, err := keyRing.Copy()
+	if err != nil {
+		return nil, err
+	}
+	otherCopy, err := other.Copy()
+	if err != nil {
+		return nil, err
+	}
+	byFingerprint := make(map[string]*openpgp.Entity, len(merged.entities))
+	for _, entity := range merged.entities {
+		byFingerprint[hex.EncodeToString(entity.PrimaryKey.Fingerprint)] = entity
+	}
+	for _, entity := range otherCopy.entities {
+		fingerprint := hex.EncodeToString(entity.PrimaryKey.Fingerprint)
+		existing, ok := byFingerprint[fingerprint]
+		if !ok {
+			merged.entities = append(merged.entities, entity)
+			byFingerprint[fingerprint] = entity
+			continue
+		}
+		if err := mergeEntity(existing, entity); err != nil {
+			return nil, err
+		}
+	}
+	return merged, nil
+}
+
+// Merge returns a copy of the key consolidated with other, which must have the same
+// primary key, see (*KeyRing).Merge.
+// Unlike key rings, the keys may be locked.
+// Not supported on go-mobile clients.
+func (key *Key) Merge(other *Key) (*Key, error) {
+	if !bytes.Equal(key.entity.PrimaryKey.Fingerprint, other.entity.PrimaryKey.Fingerprint) {
+		return nil, errors.New("gopenpgp: cannot merge keys with different primary keys")
+	}
+	merged, err := key.Copy()
+	if err != nil {
+		return nil, err
+	}
+	otherCopy, err := other.Copy()
+	if err != nil {
+		return nil, err
+	}
+	if err := mergeEntity(merged.entity, otherCopy.entity); err != nil {
+		return nil, err
+	}
+	return merged, nil
+}
+
+// mergeEntity merges the user ids, subkeys, and signatures of the entity from
+// into the entity with the same primary key.
+func mergeEntity(entity, from *openpgp.Entity) error {
+	if entity.PrivateKey == nil && from.PrivateKey != nil {
+		entity.PrivateKey = from.PrivateKey
+	}
+	entity.Revocations = mergeSignatures(entity.Revocations, from.Revocations)
+	entity.DirectSignatures = mergeSignatures(entity.DirectSignatures, from.DirectSignatures)
+	for name, fromIdentity := range from.Identities {
+		identity, ok := entity.Identities[name]
+		if !ok {
+			fromIdentity.Primary = entity
+			entity.Identities[name] = fromIdentity
+			continue
+		}
+		identity.SelfCertifications = mergeSignatures(identity.SelfCertifications, fromIdentity.SelfCertifications)
+		identity.OtherCertifications = mergeSignatures(identity.OtherCertifications, fromIdentity.OtherCertifications)
+		identity.Revocations = mergeSignatures(identity.Revocations, fromIdentity.Revocations)
+	}
+	for _, fromSubkey := range from.Subkeys {
+		index := -1
+		for i, subkey := range entity.Subkeys {
+			if bytes.Equal(subkey.PublicKey.Fingerprint, fromSubkey.PublicKey.Fingerprint) {
+				index = i
+				break
+			}
+		}
+		if index < 0 {
+			fromSubkey.Primary = entity
+			entity.Subkeys = append(entity.Subkeys, fromSubkey)
+			continue
+		}
+		subkey := &entity.Subkeys[index]
+		if subkey.PrivateKey == nil && fromSubkey.PrivateKey != nil {
+			subkey.PrivateKey = fromSubkey.PrivateKey
+		}
+		subkey.Bindings = mergeSignatures(subkey.Bindings, fromSubkey.Bindings)
+		subkey.Revocations = mergeSignatures(subkey.Revocations, fromSubkey.Revocations)
+	}
+	if entity.PrivateKey != nil {
+		for _, subkey := range entity.Subkeys {
+			if subkey.PrivateKey == nil {
+				return errors.Errorf(
+					"gopenpgp: cannot merge key %x, the private subkey %x is missing",
+					entity.PrimaryKey.Fingerprint, subkey.PublicKey.Fingerprint,
+				)
+			}
+		}
+	}
+	return nil
+}
+
+// mergeSignatures returns the union of the signatures without duplicates,
+// ordered by creation time.
+func mergeSignatures(signatures, from []*packet.VerifiableSignature) []*packet.VerifiableSignature {
+	seen := make(map[string]bool, len(signatures)+len(from))
+	var merged []*packet.VerifiableSignature
+	for _, sig := range append(append([]*packet.VerifiableSignature(nil), signatures...), from...) {
+		var serialized bytes.Buffer
+		if err := sig.Packet.Serialize(&serialized); err != nil {
+			// Keep signatures that cannot be compared.
+			merged = append(merged, sig)
+			continue
+		}
+		if seen[serialized.String()] {
+			continue
+		}
+		seen[serialized.String()] = true
+		merged = append(merged, sig)
+	}
+	sort.SliceStable(merged, func(i, j int) bool {
+		return merged[i].Packet.CreationTime.Before(merged[j].Packet.CreationTime)
+	})
+	return merged
+}
@@ -0,0 +1,181 @@
+package crypto
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	openpgp "github.com/ProtonMail/go-crypto/openpgp/v2"
+	"github.com/pkg/errors"
+)
+
+// SyncKeyRing holds a key ring that is shared by many goroutines and updated occasionally,
+// e.g., the verification keys of a server.
+// Readers get the current snapshot of the key ring without locking, and updates replace
+// the snapshot with an updated copy, such that readers never wait for writers.
+// Not supported on go-mobile clients.
+type SyncKeyRing struct {
+	// mutex serializes the updates.
+	mutex    sync.Mutex
+	snapshot atomic.Value
+}
+
+// NewSyncKeyRing creates a SyncKeyRing with the keys of the key ring, empty if keyRing is nil.
+// Later changes of keyRing do not affect the SyncKeyRing.
+// Not supported on go-mobile clients.
+func NewSyncKeyRing(keyRing *KeyRing) *SyncKeyRing {
+	syncKeyRing := &SyncKeyRing{}
+	syncKeyRing.Replace(keyRing)
+	return syncKeyRing
+}
+
+// Snapshot returns the current key ring, which can be used by many goroutines at once.
+// The returned key ring must not be modified, e.g., with (*KeyRing).AddKey.
+func (syncKeyRing *SyncKeyRing) Snapshot() *KeyRing {
+	return syncKeyRing.snapshot.Load().(*KeyRing)
+}
+
+// AddKey adds the key to a copy of the current key ring, which becomes the new snapshot.
+func (syncKeyRing *SyncKeyRing) AddKey(key *Key) error {
+	syncKeyRing.mutex.Lock()
+	defer syncKeyRing.mutex.Unlock()
+	newKeyRing, err := keyRingWithKey(syncKeyRing.Snapshot(), key)
+	if err != nil {
+		return err
+	}
+	syncKeyRing.snapshot.Store(newKeyRing)
+	return nil
+}
+
+// Merge merges the keys of other into a copy of the current key ring,
+// which becomes the new snapshot, see (*KeyRing).Merge.
+func (syncKeyRing *SyncKeyRing) Merge(other *KeyRing) error {
+	syncKeyRing.mutex.Lock()
+	defer syncKeyRing.mutex.Unlock()
+	newKeyRing, err := syncKeyRing.Snapshot().Merge(other)
+	if err != nil {
+		return err
+	}
+	syncKeyRing.snapshot.Store(newKeyRing)
+	return nil
+}
+
+// Replace replaces the current key ring with the keys of keyRing, empty if keyRing is nil.
+// Later changes of keyRing do not affect the SyncKeyRing.
+func (syncKeyRing *SyncKeyRing) Replace(keyRing *KeyRing) {
+	syncKeyRing.mutex.Lock()
+	defer syncKeyRing.mutex.Unlock()
+	newKeyRing := &KeyRing{}
+	if keyRing != nil {
+		newKeyRing.entities = append(newKeyRing.entities, keyRing.entities...)
+		newKeyRing.FirstKeyID = keyRing.FirstKeyID
+	}
+	syncKeyRing.snapshot.Store(newKeyRing)
+}
+
+// isolateEntities returns isolated copies of the entities, see isolateEntity.
+func isolateEntities(entities openpgp.EntityList) openpgp.EntityList {
+	if entities == nil {
+		return nil
+	}
+	isolated := make(openpgp.EntityList, len(entities))
+	for i, entity := range entities {
+		isolated[i] = isolateEntity(entity)
+	}
+	return isolated
+}
+
+// isolateEntity returns a shallow copy of the entity, which shares the keys and
+// signature packets with the entity, but not the validity of the signatures.
+// go-crypto caches the validity of self-signatures in the entity when it is used,
+// thus operations on the same entity in different goroutines would race.
+// The validity of the self-signatures of the copy is determined up front,
+// such that go-crypto only reads it and the copy can be used by many goroutines at once.
+// As in go-crypto, the cached validity does not include the expiration of the signatures,
+// which is checked on each use.
+func isolateEntity(entity *openpgp.Entity) *openpgp.Entity {
+	isolated := *entity
+	primaryKey := entity.PrimaryKey
+	isolated.Revocations = verifiedSignatures(entity.Revocations, func(sig *packet.Signature) error {
+		return primaryKey.VerifyRevocationSignature(sig)
+	})
+	isolated.DirectSignatures = verifiedSignatures(entity.DirectSignatures, func(sig *packet.Signature) error {
+		if err := primaryKey.VerifyDirectKeySignature(sig); err != nil {
+			return err
+		}
+		return checkSelfSignatureDetails(primaryKey, sig)
+	})
+	isolated.Identities = make(map[string]*openpgp.Identity, len(entity.Identities))
+	for name, identity := range entity.Identities {
+		isolatedIdentity := *identity
+		isolatedIdentity.Primary = &isolated
+		verifyCertification := func(sig *packet.Signature) error {
+			if err := primaryKey.VerifyUserIdSignature(identity.Name, primaryKey, sig); err != nil {
+				return err
+			}
+			return checkSelfSignatureDetails(primaryKey, sig)
+		}
+		isolatedIdentity.SelfCertifications = verifiedSignatures(identity.SelfCertifications, verifyCertification)
+		isolatedIdentity.OtherCertifications = isolateSignatures(identity.OtherCertifications)
+		isolatedIdentity.Revocations = verifiedSignatures(identity.Revocations, verifyCertification)
+		isolated.Identities[name] = &isolatedIdentity
+	}
+	isolated.Subkeys = make([]openpgp.Subkey, len(entity.Subkeys))
+	for i, subkey := range entity.Subkeys {
+		publicKey := subkey.PublicKey
+		subkey.Primary = &isolated
+		subkey.Bindings = verifiedSignatures(subkey.Bindings, func(sig *packet.Signature) error {
+			if err := primaryKey.VerifyKeySignature(publicKey, sig); err != nil {
+				return err
+			}
+			return checkSelfSignatureDetails(publicKey, sig)
+		})
+		subkey.Revocations = verifiedSignatures(subkey.Revocations, func(sig *packet.Signature) error {
+			return primaryKey.VerifySubkeyRevocationSignature(sig, publicKey)
+		})
+		isolated.Subkeys[i] = subkey
+	}
+	return &isolated
+}
+
+// verifiedSignatures returns copies of the signatures whose validity is set by verify.
+func verifiedSignatures(
+	signatures []*packet.VerifiableSignature,
+	verify func(sig *packet.Signature) error,
+) []*packet.VerifiableSignature {
+	isolated := isolateSignatures(signatures)
+	for _, sig := range isolated {
+		valid := verify(sig.Packet) == nil
+		sig.Valid = &valid
+	}
+	return isolated
+}
+
+// checkSelfSignatureDetails checks the self-signature as go-crypto does before it caches
+// the validity of the signature, with the default configuration and without the expiration.
+func checkSelfSignatureDetails(publicKey *packet.PublicKey, sig *packet.Signature) error {
+	var config *packet.Config
+	if config.RejectHashAlgorithm(sig.Hash) {
+		return errors.New("gopenpgp: insecure hash algorithm in self-signature")
+	}
+	if publicKey.CreationTime.Unix() > sig.CreationTime.Unix() {
+		return errors.New("gopenpgp: self-signature is older than the key")
+	}
+	for _, notation := range sig.Notations {
+		if notation.IsCritical && !config.KnownNotation(notation.Name) {
+			return errors.New("gopenpgp: unknown critical notation in self-signature")
+		}
+	}
+	return nil
+}
+
+func isolateSignatures(signatures []*packet.VerifiableSignature) []*packet.VerifiableSignature {
+	if signatures == nil {
+		return nil
+	}
+	isolated := make([]*packet.VerifiableSignature, len(signatures))
+	for i, sig := range signatures {
+		isolated[i] = packet.NewVerifiableSig(sig.Packet)
+	}
+	return isolated
+}
@@ -1,13 +1,28 @@
 package crypto
 
 import (
+	"bytes"
+	"crypto"
 	"crypto/rsa"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
 	"github.com/ProtonMail/go-crypto/openpgp/ecdh"
 	"github.com/ProtonMail/go-crypto/openpgp/eddsa"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	openpgp "github.com/ProtonMail/go-crypto/openpgp/v2"
+	"github.com/ProtonMail/gopenpgp/v3/constants"
+	"github.com/ProtonMail/gopenpgp/v3/profile"
+	"github.com/pkg/errors"
 )
 
 var testSymmetricKey []byte
@@ -145,6 +160,329 @@ func TestMultipleKeyRing(t *testing.T) {
 	assert.Exactly(t, 1, singleKeyRing.CountDecryptionEntities(testTime))
 }
 
+func TestKeyRingIndex(t *testing.T) {
+	keyRing, err := NewKeyRing(keyTestRSA)
+	if err != nil {
+		t.Fatal("Cannot create key ring:", err)
+	}
+	assert.Exactly(t, 1, keyRing.GetKeysByEmail(strings.ToUpper(keyTestDomain)).CountEntities())
+
+	// The index is rebuilt after adding a key.
+	if err := keyRing.AddKey(keyTestEC); err != nil {
+		t.Fatal("Cannot add key:", err)
+	}
+	assert.Exactly(t, 2, keyRing.GetKeysByEmail(keyTestDomain).CountEntities())
+	assert.Exactly(t, 0, keyRing.GetKeysByEmail("unknown@protonmail.ch").CountEntities())
+
+	subkey := keyTestRSA.entity.Subkeys[0].PublicKey
+	key, err := keyRing.GetKeyByFingerprint(strings.ToUpper(hex.EncodeToString(subkey.Fingerprint)))
+	if err != nil {
+		t.Fatal("Cannot get key by fingerprint:", err)
+	}
+	assert.Exactly(t, keyTestRSA.GetFingerprint(), key.GetFingerprint())
+	key, err = keyRing.GetKeyByFingerprint(keyTestEC.GetFingerprint())
+	if err != nil {
+		t.Fatal("Cannot get key by fingerprint:", err)
+	}
+	assert.Exactly(t, keyTestEC.GetFingerprint(), key.GetFingerprint())
+	_, err = keyRing.GetKeyByFingerprint(keyRingTestPublic.GetKeys()[0].GetFingerprint())
+	assert.Error(t, err)
+
+	rings := keyRings{nil, keyRingTestPublic, keyRing}
+	// The found entities are isolated copies, which do not share the cached signature validity.
+	assert.Exactly(t, []*openpgp.Entity{isolateEntity(keyTestRSA.entity)}, rings.EntitiesById(subkey.KeyId))
+	// The copies are made once per change of the key ring and shared by all lookups.
+	isolated := keyRing.isolatedEntities()
+	assert.NotSame(t, keyTestRSA.entity, isolated[0])
+	assert.Same(t, isolated[0], rings.EntitiesById(subkey.KeyId)[0])
+	assert.Same(t, isolated[0], keyRing.isolatedEntities()[0])
+	for _, binding := range isolated[0].Subkeys[0].Bindings {
+		assert.NotNil(t, binding.Valid)
+	}
+	assert.Len(t, rings.KeysById(keyTestEC.GetKeyID()), 1)
+	assert.Len(t, rings.EntitiesById(0), 3)
+	assert.Empty(t, rings.EntitiesById(1))
+}
+
+func TestKeyRingMerge(t *testing.T) {
+	publicKey, err := keyTestEC.ToPublic()
+	if err != nil {
+		t.Fatal("Cannot get public key:", err)
+	}
+	publicKeyRing, err := NewKeyRing(publicKey)
+	if err != nil {
+		t.Fatal("Cannot create key ring:", err)
+	}
+	updatedKey, err := keyTestEC.AddUserId("Merged", "merged@protonmail.ch", testTime+1)
+	if err != nil {
+		t.Fatal("Cannot add user id:", err)
+	}
+	updatedKey, err = updatedKey.AddSubkey(0, KeyCapabilityEncrypt, 0, testTime+1)
+	if err != nil {
+		t.Fatal("Cannot add subkey:", err)
+	}
+	updatedKeyRing, err := NewKeyRing(updatedKey)
+	if err != nil {
+		t.Fatal("Cannot create key ring:", err)
+	}
+	if err := updatedKeyRing.AddKey(keyTestRSA); err != nil {
+		t.Fatal("Cannot add key:", err)
+	}
+
+	merged, err := publicKeyRing.Merge(updatedKeyRing)
+	if err != nil {
+		t.Fatal("Cannot merge key rings:", err)
+	}
+	assert.Exactly(t, 1, publicKeyRing.CountEntities())
+	assert.Exactly(t, 2, merged.CountEntities())
+	mergedKey := merged.GetKeys()[0]
+	assert.Exactly(t, keyTestEC.GetFingerprint(), mergedKey.GetFingerprint())
+	assert.True(t, mergedKey.IsPrivate())
+	assert.Len(t, mergedKey.entity.Identities, 2)
+	assert.Len(t, mergedKey.entity.Subkeys, len(keyTestEC.entity.Subkeys)+1)
+	assert.Exactly(t, keyTestRSA.GetFingerprint(), merged.GetKeys()[1].GetFingerprint())
+	assert.Exactly(t, 1, merged.GetKeysByEmail("merged@protonmail.ch").CountEntities())
+
+	// Merging the same keys again does not duplicate signatures.
+	again, err := merged.Merge(updatedKeyRing)
+	if err != nil {
+		t.Fatal("Cannot merge key rings:", err)
+	}
+	signatureCount := func(entity *openpgp.Entity) int {
+		count := len(entity.Revocations) + len(entity.DirectSignatures)
+		for _, identity := range entity.Identities {
+			count += len(identity.SelfCertifications) + len(identity.OtherCertifications) + len(identity.Revocations)
+		}
+		for _, subkey := range entity.Subkeys {
+			count += len(subkey.Bindings) + len(subkey.Revocations)
+		}
+		return count
+	}
+	assert.Exactly(t, 2, again.CountEntities())
+	assert.Exactly(t, signatureCount(mergedKey.entity), signatureCount(again.entities[0]))
+	assert.Exactly(t, signatureCount(updatedKey.entity), signatureCount(again.entities[0]))
+
+	// A private key cannot be merged with a public subkey.
+	publicUpdatedKey, err := updatedKey.ToPublic()
+	if err != nil {
+		t.Fatal("Cannot get public key:", err)
+	}
+	publicUpdatedKeyRing, err := NewKeyRing(publicUpdatedKey)
+	if err != nil {
+		t.Fatal("Cannot create key ring:", err)
+	}
+	privateKeyRing, err := NewKeyRing(keyTestEC)
+	if err != nil {
+		t.Fatal("Cannot create key ring:", err)
+	}
+	_, err = privateKeyRing.Merge(publicUpdatedKeyRing)
+	assert.Error(t, err)
+
+	// Single keys can be merged even if they are locked.
+	lockedKey, err := testPGP.LockKey(keyTestEC, []byte("password"))
+	if err != nil {
+		t.Fatal("Cannot lock key:", err)
+	}
+	mergedLockedKey, err := lockedKey.Merge(publicKey)
+	if err != nil {
+		t.Fatal("Cannot merge keys:", err)
+	}
+	locked, err := mergedLockedKey.IsLocked()
+	if err != nil {
+		t.Fatal("Cannot check key:", err)
+	}
+	assert.True(t, locked)
+	mergedPublicKey, err := publicKey.Merge(publicUpdatedKey)
+	if err != nil {
+		t.Fatal("Cannot merge keys:", err)
+	}
+	assert.Len(t, mergedPublicKey.entity.Identities, 2)
+	_, err = publicKey.Merge(keyTestRSA)
+	assert.Error(t, err)
+}
+
+func TestSyncKeyRing(t *testing.T) {
+	publicKey, err := keyTestEC.ToPublic()
+	if err != nil {
+		t.Fatal("Cannot get public key:", err)
+	}
+	keyRing, err := NewKeyRing(publicKey)
+	if err != nil {
+		t.Fatal("Cannot create key ring:", err)
+	}
+	syncKeyRing := NewSyncKeyRing(keyRing)
+	signer, err := testPGP.Sign().SigningKey(keyTestEC).Detached().New()
+	if err != nil {
+		t.Fatal("Cannot create signer:", err)
+	}
+	message := []byte("concurrent verification")
+	signature, err := signer.Sign(message, Bytes)
+	if err != nil {
+		t.Fatal("Cannot sign message:", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			verifier, err := testPGP.Verify().VerificationKeys(syncKeyRing.Snapshot()).New()
+			if err != nil {
+				t.Error("Cannot create verifier:", err)
+				return
+			}
+			result, err := verifier.VerifyDetached(message, signature, Bytes)
+			if err != nil {
+				t.Error("Cannot verify signature:", err)
+				return
+			}
+			assert.NoError(t, result.SignatureError())
+		}()
+	}
+	if err := syncKeyRing.AddKey(keyTestRSA); err != nil {
+		t.Fatal("Cannot add key:", err)
+	}
+	wg.Wait()
+	assert.Exactly(t, 2, syncKeyRing.Snapshot().CountEntities())
+	assert.Exactly(t, 1, keyRing.CountEntities())
+
+	if err := syncKeyRing.Merge(keyRingTestPublic); err != nil {
+		t.Fatal("Cannot merge key ring:", err)
+	}
+	assert.Exactly(t, 3, syncKeyRing.Snapshot().CountEntities())
+	syncKeyRing.Replace(nil)
+	assert.Exactly(t, 0, syncKeyRing.Snapshot().CountEntities())
+}
+
+func TestKeyRingFilterValid(t *testing.T) {
+	pgp := PGPWithProfile(profile.RFC4880())
+	pgp.defaultTime = NewConstantClock(testTime)
+	key, err := pgp.KeyGeneration().
+		AddUserId(keyTestName, keyTestDomain).
+		AddSubkey(0, KeyCapabilityEncrypt, 3600).
+		AddSubkey(0, KeyCapabilitySign, 0).
+		AddSubkey(0, KeyCapabilityEncrypt, 0).
+		New().
+		GenerateKey()
+	if err != nil {
+		t.Fatal("Cannot generate key:", err)
+	}
+	subkeys := key.entity.Subkeys
+	revokedID := subkeys[len(subkeys)-1].PublicKey.KeyIdString()
+	key, err = key.RevokeSubkey(revokedID, constants.RevocationKeyRetired, "", testTime)
+	if err != nil {
+		t.Fatal("Cannot revoke subkey:", err)
+	}
+	keyRing, err := NewKeyRing(key)
+	if err != nil {
+		t.Fatal("Cannot create key ring:", err)
+	}
+
+	subkeyIDs := func(keyRing *KeyRing) (ids []uint64) {
+		for _, entity := range keyRing.entities {
+			for _, subkey := range entity.Subkeys {
+				ids = append(ids, subkey.PublicKey.KeyId)
+			}
+		}
+		return ids
+	}
+	encryptionKeys, err := keyRing.FilterValid(KeyCapabilityEncrypt, testTime+60)
+	if err != nil {
+		t.Fatal("Cannot filter keys:", err)
+	}
+	assert.Exactly(t, []uint64{subkeys[0].PublicKey.KeyId}, subkeyIDs(encryptionKeys))
+	assert.True(t, encryptionKeys.CanEncrypt(testTime+60))
+	assert.Exactly(t, len(subkeys), len(keyRing.entities[0].Subkeys))
+
+	// The expiring subkey is not valid anymore, and the primary key cannot encrypt.
+	encryptionKeys, err = keyRing.FilterValid(KeyCapabilityEncrypt, testTime+7200)
+	if err != nil {
+		t.Fatal("Cannot filter keys:", err)
+	}
+	assert.Exactly(t, 0, encryptionKeys.CountEntities())
+
+	signingKeys, err := keyRing.FilterValid(KeyCapabilitySign, testTime+60)
+	if err != nil {
+		t.Fatal("Cannot filter keys:", err)
+	}
+	assert.Exactly(t, []uint64{subkeys[1].PublicKey.KeyId}, subkeyIDs(signingKeys))
+
+	// Keys that are not valid yet are removed.
+	signingKeys, err = keyRing.FilterValid(KeyCapabilitySign, testTime-60)
+	if err != nil {
+		t.Fatal("Cannot filter keys:", err)
+	}
+	assert.Exactly(t, 0, signingKeys.CountEntities())
+
+	_, err = keyRing.FilterValid(3, testTime)
+	assert.Error(t, err)
+}
+
+func TestKeyRingExpiryReport(t *testing.T) {
+	pgp := PGPWithProfile(profile.RFC4880())
+	pgp.defaultTime = NewConstantClock(testTime)
+	key, err := pgp.KeyGeneration().
+		AddUserId(keyTestName, keyTestDomain).
+		Lifetime(86400).
+		AddSubkey(0, KeyCapabilityEncrypt, 3600).
+		AddSubkey(0, KeyCapabilitySign, 0).
+		AddSubkey(0, KeyCapabilityEncrypt, 0).
+		New().
+		GenerateKey()
+	if err != nil {
+		t.Fatal("Cannot generate key:", err)
+	}
+	subkeys := key.entity.Subkeys
+	key, err = key.RevokeSubkey(subkeys[2].PublicKey.KeyIdString(), constants.RevocationKeyRetired, "", testTime)
+	if err != nil {
+		t.Fatal("Cannot revoke subkey:", err)
+	}
+	keyRing, err := NewKeyRing(keyTestEC)
+	if err != nil {
+		t.Fatal("Cannot create key ring:", err)
+	}
+	if err := keyRing.AddKey(key); err != nil {
+		t.Fatal("Cannot add key:", err)
+	}
+	type listedKey struct {
+		keyID      string
+		status     string
+		expiration int64
+	}
+	listedKeys := func(report *KeyExpiryReport) (keys []listedKey) {
+		for _, keyExpiry := range report.Keys {
+			assert.Exactly(t, key.GetFingerprint(), keyExpiry.Fingerprint)
+			assert.Exactly(t, keyExpiry.KeyID != key.GetHexKeyID(), keyExpiry.IsSubkey)
+			keys = append(keys, listedKey{keyExpiry.KeyID, keyExpiry.Status, keyExpiry.ExpirationTime})
+		}
+		return keys
+	}
+	subkeyID := func(index int) string {
+		return keyIDToHex(subkeys[index].PublicKey.KeyId)
+	}
+
+	assert.Exactly(t, []listedKey{
+		{subkeyID(0), constants.KeyExpiryExpiring, testTime + 3600},
+		{subkeyID(2), constants.KeyExpiryRevoked, 0},
+	}, listedKeys(keyRing.ExpiryReport(2*time.Hour, testTime+60)))
+	assert.Exactly(t, []listedKey{
+		{key.GetHexKeyID(), constants.KeyExpiryExpiring, testTime + 86400},
+		{subkeyID(0), constants.KeyExpiryExpired, testTime + 3600},
+		{subkeyID(2), constants.KeyExpiryRevoked, 0},
+	}, listedKeys(keyRing.ExpiryReport(48*time.Hour, testTime+4000)))
+	// The subkeys of expired keys are not listed.
+	assert.Exactly(t, []listedKey{
+		{key.GetHexKeyID(), constants.KeyExpiryExpired, testTime + 86400},
+	}, listedKeys(keyRing.ExpiryReport(0, testTime+86401)))
+
+	var report KeyExpiryReport
+	if err := json.Unmarshal(keyRing.ExpiryReportJson(7200, testTime+60), &report); err != nil {
+		t.Fatal("Cannot parse report:", err)
+	}
+	assert.Len(t, report.Keys, 2)
+	assert.Empty(t, (&KeyRing{}).ExpiryReport(time.Hour, testTime).Keys)
+}
+
 func TestSerializeParse(t *testing.T) {
 	serialized, err := keyRingTestMultiple.Serialize()
 	assert.Nil(t, err)
@@ -212,3 +550,419 @@ func TestClearPrivateParams(t *testing.T) {
 		assert.False(t, key.ClearPrivateParams())
 	}
 }
+
+var gnupgKeyRingFingerprints = []string{
+	"43a58683ca55147e32805dd724b2940c8c053caa",
+	"7ce198006511f16e5d871bded5a41425225849d5",
+}
+
+func keyRingFingerprints(keyRing *KeyRing) []string {
+	var fingerprints []string
+	for _, key := range keyRing.GetKeys() {
+		fingerprints = append(fingerprints, key.GetFingerprint())
+	}
+	return fingerprints
+}
+
+func TestKeyRingFromKeybox(t *testing.T) {
+	keybox := []byte(readTestFile("keyring_gnupg.kbx", false))
+	result, err := NewKeyRingFromKeybox(bytes.NewReader(keybox))
+	if err != nil {
+		t.Fatal("Expected no error while reading keybox, got:", err)
+	}
+	assert.Empty(t, result.Errors)
+	assert.Exactly(t, gnupgKeyRingFingerprints, keyRingFingerprints(result.KeyRing))
+
+	// A broken OpenPGP blob and an X.509 blob between the certificates.
+	blob := func(blobType byte, keyblock []byte) []byte {
+		header := make([]byte, 16)
+		binary.BigEndian.PutUint32(header, uint32(len(header)+len(keyblock)))
+		header[4], header[5] = blobType, 1
+		binary.BigEndian.PutUint32(header[8:], uint32(len(header)))
+		binary.BigEndian.PutUint32(header[12:], uint32(len(keyblock)))
+		return append(header, keyblock...)
+	}
+	firstBlobEnd := 32 + int(binary.BigEndian.Uint32(keybox[32:]))
+	var modified []byte
+	modified = append(modified, keybox[:firstBlobEnd]...)
+	modified = append(modified, blob(keyboxBlobOpenPGP, []byte{0x98, 0x03, 0x04, 0x00, 0x00})...)
+	modified = append(modified, blob(3, []byte{0x30, 0x00})...)
+	modified = append(modified, keybox[firstBlobEnd:]...)
+	result, err = NewKeyRingFromKeybox(bytes.NewReader(modified))
+	if err != nil {
+		t.Fatal("Expected no error while reading keybox, got:", err)
+	}
+	assert.Len(t, result.Errors, 1)
+	assert.Exactly(t, gnupgKeyRingFingerprints, keyRingFingerprints(result.KeyRing))
+
+	// A truncated last blob.
+	result, err = NewKeyRingFromKeybox(bytes.NewReader(keybox[:len(keybox)-1]))
+	if err != nil {
+		t.Fatal("Expected no error while reading keybox, got:", err)
+	}
+	assert.Len(t, result.Errors, 1)
+	assert.Exactly(t, gnupgKeyRingFingerprints[:1], keyRingFingerprints(result.KeyRing))
+
+	_, err = NewKeyRingFromKeybox(bytes.NewReader([]byte(readTestFile("keyring_gnupg.gpg", false))))
+	assert.Error(t, err)
+}
+
+func TestKeyRingFromGnuPGKeyRing(t *testing.T) {
+	keyRingData := []byte(readTestFile("keyring_gnupg.gpg", false))
+	result, err := NewKeyRingFromGnuPGKeyRing(bytes.NewReader(keyRingData))
+	if err != nil {
+		t.Fatal("Expected no error while reading keyring, got:", err)
+	}
+	assert.Empty(t, result.Errors)
+	assert.Exactly(t, gnupgKeyRingFingerprints, keyRingFingerprints(result.KeyRing))
+
+	// A broken certificate between the certificates.
+	// The second public key packet, see gpg --list-packets.
+	const secondCertificate = 262
+	var modified []byte
+	modified = append(modified, keyRingData[:secondCertificate]...)
+	modified = append(modified, 0x98, 0x03, 0x04, 0x00, 0x00)
+	modified = append(modified, keyRingData[secondCertificate:]...)
+	result, err = NewKeyRingFromGnuPGKeyRing(bytes.NewReader(modified))
+	if err != nil {
+		t.Fatal("Expected no error while reading keyring, got:", err)
+	}
+	assert.Len(t, result.Errors, 1)
+	assert.Exactly(t, gnupgKeyRingFingerprints, keyRingFingerprints(result.KeyRing))
+
+	// Trailing garbage.
+	result, err = NewKeyRingFromGnuPGKeyRing(bytes.NewReader(append(keyRingData, 0x00)))
+	if err != nil {
+		t.Fatal("Expected no error while reading keyring, got:", err)
+	}
+	assert.Len(t, result.Errors, 1)
+	assert.Exactly(t, gnupgKeyRingFingerprints, keyRingFingerprints(result.KeyRing))
+}
+
+func TestKeyRingFromGnuPGHome(t *testing.T) {
+	homeDir := t.TempDir()
+	_, err := NewKeyRingFromGnuPGHome(homeDir)
+	assert.Error(t, err)
+
+	if err := os.WriteFile(filepath.Join(homeDir, "pubring.gpg"), []byte(readTestFile("keyring_gnupg.gpg", false)), 0600); err != nil {
+		t.Fatal("Cannot write keyring:", err)
+	}
+	result, err := NewKeyRingFromGnuPGHome(homeDir)
+	if err != nil {
+		t.Fatal("Expected no error while reading home directory, got:", err)
+	}
+	assert.Exactly(t, gnupgKeyRingFingerprints, keyRingFingerprints(result.KeyRing))
+
+	if err := os.WriteFile(filepath.Join(homeDir, "pubring.kbx"), []byte(readTestFile("keyring_gnupg.kbx", false)[:32]), 0600); err != nil {
+		t.Fatal("Cannot write keybox:", err)
+	}
+	t.Setenv("GNUPGHOME", homeDir)
+	result, err = NewKeyRingFromGnuPGHome("")
+	if err != nil {
+		t.Fatal("Expected no error while reading home directory, got:", err)
+	}
+	assert.Zero(t, result.KeyRing.CountEntities())
+}
+
+// certifyUserIds adds certifications or certification revocations
+// of all user ids of key by certifier created at unixTime.
+func certifyUserIds(t *testing.T, key, certifier *Key, sigType packet.SignatureType, unixTime int64) {
+	for name, identity := range key.entity.Identities {
+		sig := &packet.Signature{
+			Version:           certifier.entity.PrimaryKey.Version,
+			SigType:           sigType,
+			PubKeyAlgo:        certifier.entity.PrimaryKey.PubKeyAlgo,
+			Hash:              crypto.SHA256,
+			CreationTime:      time.Unix(unixTime, 0),
+			IssuerKeyId:       &certifier.entity.PrimaryKey.KeyId,
+			IssuerFingerprint: certifier.entity.PrimaryKey.Fingerprint,
+		}
+		if err := sig.SignUserId(name, key.entity.PrimaryKey, certifier.entity.PrivateKey, nil); err != nil {
+			t.Fatal("Cannot certify user id:", err)
+		}
+		identity.OtherCertifications = append(identity.OtherCertifications, packet.NewVerifiableSig(sig))
+	}
+}
+
+func TestWebOfTrust(t *testing.T) {
+	keys := make(map[string]*Key)
+	keyRing := &KeyRing{}
+	for _, name := range []string{"alice", "bob", "carol", "dave", "erin", "frank"} {
+		key, err := testPGP.KeyGeneration().AddUserId(name, name+"@example.com").New().GenerateKey()
+		if err != nil {
+			t.Fatal("Cannot generate key:", err)
+		}
+		keys[name] = key
+		keyRing.appendKey(key)
+	}
+	certifyUserIds(t, keys["bob"], keys["alice"], packet.SigTypeGenericCert, testTime)
+	certifyUserIds(t, keys["carol"], keys["bob"], packet.SigTypePositiveCert, testTime)
+	certifyUserIds(t, keys["dave"], keys["carol"], packet.SigTypeGenericCert, testTime)
+	certifyUserIds(t, keys["erin"], keys["alice"], packet.SigTypeGenericCert, testTime)
+	certifyUserIds(t, keys["erin"], keys["alice"], packet.SigTypeCertificationRevocation, testTime+10)
+	// Certifications by keys without owner trust are ignored.
+	certifyUserIds(t, keys["frank"], keys["dave"], packet.SigTypeGenericCert, testTime)
+
+	wot := NewWebOfTrust(keyRing)
+	for name, ownerTrust := range map[string]int8{
+		"alice": constants.OwnerTrustUltimate,
+		"bob":   constants.OwnerTrustFull,
+		"carol": constants.OwnerTrustMarginal,
+	} {
+		if err := wot.SetOwnerTrust(strings.ToUpper(keys[name].GetFingerprint()), ownerTrust); err != nil {
+			t.Fatal("Cannot set owner trust:", err)
+		}
+	}
+	unixTime := int64(testTime + 100)
+	expected := map[string]int8{
+		"alice": constants.ValidityUltimate,
+		"bob":   constants.ValidityFull,
+		"carol": constants.ValidityFull,
+		"dave":  constants.ValidityMarginal,
+		"erin":  constants.ValidityUnknown,
+		"frank": constants.ValidityUnknown,
+	}
+	for name, validity := range expected {
+		assert.Exactly(t, validity, wot.GetKeyValidity(keys[name].GetFingerprint(), unixTime), name)
+		assert.Exactly(t, validity, wot.GetUserIdValidity(keys[name].GetFingerprint(), name+" <"+name+"@example.com>", unixTime), name)
+	}
+	// Before the revocation, the certification of erin is valid.
+	assert.Exactly(t, constants.ValidityFull, wot.GetKeyValidity(keys["erin"].GetFingerprint(), testTime+5))
+
+	assert.Exactly(t, 1, wot.GetValidKeysByEmail("BOB@example.com", constants.ValidityFull, unixTime).CountEntities())
+	assert.Exactly(t, 0, wot.GetValidKeysByEmail("dave@example.com", constants.ValidityFull, unixTime).CountEntities())
+	assert.Exactly(t, 1, wot.GetValidKeysByEmail("dave@example.com", constants.ValidityMarginal, unixTime).CountEntities())
+
+	lookup := wot.KeyLookup(constants.ValidityFull, unixTime)
+	found, err := lookup.LookupKey(keys["carol"].GetKeyID(), nil)
+	if err != nil {
+		t.Fatal("Cannot look up key:", err)
+	}
+	assert.Exactly(t, keys["carol"].GetFingerprint(), found.GetFingerprint())
+	found, err = lookup.LookupKey(keys["dave"].GetKeyID(), nil)
+	if err != nil {
+		t.Fatal("Cannot look up key:", err)
+	}
+	assert.Nil(t, found)
+
+	// A single marginal certification is enough with one marginal needed,
+	// and carol is not reached with a depth of one.
+	if err := wot.SetThresholds(1, 1, 1); err != nil {
+		t.Fatal("Cannot set thresholds:", err)
+	}
+	assert.Exactly(t, constants.ValidityFull, wot.GetKeyValidity(keys["bob"].GetFingerprint(), unixTime))
+	assert.Exactly(t, constants.ValidityUnknown, wot.GetKeyValidity(keys["carol"].GetFingerprint(), unixTime))
+	if err := wot.SetThresholds(1, 1, 5); err != nil {
+		t.Fatal("Cannot set thresholds:", err)
+	}
+	assert.Exactly(t, constants.ValidityFull, wot.GetKeyValidity(keys["dave"].GetFingerprint(), unixTime))
+
+	assert.Error(t, wot.SetThresholds(0, 1, 5))
+	assert.Error(t, wot.SetOwnerTrust(keyTestEC.GetFingerprint(), constants.OwnerTrustFull))
+	assert.Error(t, wot.SetOwnerTrust(keys["dave"].GetFingerprint(), 5))
+}
+
+func TestWebOfTrustVerification(t *testing.T) {
+	alice, err := testPGP.KeyGeneration().AddUserId("alice", "alice@example.com").New().GenerateKey()
+	if err != nil {
+		t.Fatal("Cannot generate key:", err)
+	}
+	bob, err := testPGP.KeyGeneration().AddUserId("bob", "bob@example.com").New().GenerateKey()
+	if err != nil {
+		t.Fatal("Cannot generate key:", err)
+	}
+	certifyUserIds(t, bob, alice, packet.SigTypeGenericCert, testTime)
+	keyRing := &KeyRing{}
+	keyRing.appendKey(alice)
+	keyRing.appendKey(bob)
+	wot := NewWebOfTrust(keyRing)
+	if err := wot.SetOwnerTrust(alice.GetFingerprint(), constants.OwnerTrustUltimate); err != nil {
+		t.Fatal("Cannot set owner trust:", err)
+	}
+
+	signer, err := testPGP.Sign().SigningKey(bob).Detached().New()
+	if err != nil {
+		t.Fatal("Cannot create signer:", err)
+	}
+	signature, err := signer.Sign([]byte("message"), Bytes)
+	if err != nil {
+		t.Fatal("Cannot sign:", err)
+	}
+	verifier, err := testPGP.Verify().VerificationKeys(keyRing).WebOfTrust(wot).New()
+	if err != nil {
+		t.Fatal("Cannot create verifier:", err)
+	}
+	result, err := verifier.VerifyDetached([]byte("message"), signature, Bytes)
+	if err != nil {
+		t.Fatal("Cannot verify:", err)
+	}
+	assert.NoError(t, result.SignatureError())
+	assert.Exactly(t, constants.ValidityFull, result.SignedByValidity())
+	assert.Exactly(t, int(constants.ValidityFull), result.Summary().Signatures[0].SignerValidity)
+
+	verifier, err = testPGP.Verify().VerificationKeys(keyRing).New()
+	if err != nil {
+		t.Fatal("Cannot create verifier:", err)
+	}
+	result, err = verifier.VerifyDetached([]byte("message"), signature, Bytes)
+	if err != nil {
+		t.Fatal("Cannot verify:", err)
+	}
+	assert.Exactly(t, constants.ValidityUnknown, result.SignedByValidity())
+}
+
+func TestKeyTransparencyVerification(t *testing.T) {
+	bob, err := testPGP.KeyGeneration().AddUserId("bob", "bob@example.com").New().GenerateKey()
+	if err != nil {
+		t.Fatal("Cannot generate key:", err)
+	}
+	var attested []string
+	transparency := KeyTransparencyVerifierFunc(func(key *Key, unixTime int64) (*TransparencyAttestation, error) {
+		attested = append(attested, key.GetFingerprint())
+		if key.GetFingerprint() != keyTestEC.GetFingerprint() {
+			return &TransparencyAttestation{Status: constants.TransparencyNotIncluded}, nil
+		}
+		return &TransparencyAttestation{Status: constants.TransparencyIncluded, LogID: "log", Proof: []byte{1}}, nil
+	})
+
+	signer, err := testPGP.Sign().SigningKeys(&KeyRing{entities: []*openpgp.Entity{keyTestEC.entity, bob.entity}}).New()
+	if err != nil {
+		t.Fatal("Cannot create signer:", err)
+	}
+	message, err := signer.Sign([]byte("message"), Bytes)
+	if err != nil {
+		t.Fatal("Cannot sign:", err)
+	}
+	verificationKeys := &KeyRing{entities: []*openpgp.Entity{bob.entity, keyTestEC.entity}}
+	verifier, err := testPGP.Verify().VerificationKeys(verificationKeys).KeyTransparency(transparency).New()
+	if err != nil {
+		t.Fatal("Cannot create verifier:", err)
+	}
+	result, err := verifier.VerifyInline(message, Bytes)
+	if err != nil {
+		t.Fatal("Cannot verify:", err)
+	}
+	assert.NoError(t, result.SignatureError())
+	assert.ElementsMatch(t, []string{keyTestEC.GetFingerprint(), bob.GetFingerprint()}, attested)
+	for _, signature := range result.Signatures {
+		if signature.SignedBy.GetFingerprint() == bob.GetFingerprint() {
+			assert.Exactly(t, constants.TransparencyNotIncluded, signature.Transparency.Status)
+			assert.Exactly(t, int(constants.TransparencyNotIncluded), signature.summary().TransparencyStatus)
+		} else {
+			assert.Exactly(t, constants.TransparencyIncluded, signature.Transparency.Status)
+			assert.Exactly(t, "log", signature.Transparency.LogID)
+		}
+	}
+	assert.Same(t, result.selectedSignature.Transparency, result.SignedByTransparency())
+	assert.Exactly(t, result.selectedSignature.Transparency.Status, result.SignedByTransparencyStatus())
+
+	// Verifier errors abort the verification.
+	verifier, err = testPGP.Verify().VerificationKeys(verificationKeys).KeyTransparency(
+		KeyTransparencyVerifierFunc(func(key *Key, unixTime int64) (*TransparencyAttestation, error) {
+			return nil, errors.New("unavailable")
+		}),
+	).New()
+	if err != nil {
+		t.Fatal("Cannot create verifier:", err)
+	}
+	_, err = verifier.VerifyInline(message, Bytes)
+	assert.Error(t, err)
+
+	verifier, err = testPGP.Verify().VerificationKeys(verificationKeys).New()
+	if err != nil {
+		t.Fatal("Cannot create verifier:", err)
+	}
+	result, err = verifier.VerifyInline(message, Bytes)
+	if err != nil {
+		t.Fatal("Cannot verify:", err)
+	}
+	assert.Nil(t, result.SignedByTransparency())
+	assert.Exactly(t, constants.TransparencyUnknown, result.SignedByTransparencyStatus())
+}
+
+var _ KeyStore = (*FileKeyStore)(nil)
+
+func TestFileKeyStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.pgp")
+	store, err := NewFileKeyStore(path, keyTestPassphrase)
+	if err != nil {
+		t.Fatal("Cannot open key store:", err)
+	}
+	fingerprints, err := store.List()
+	if err != nil {
+		t.Fatal("Cannot list keys:", err)
+	}
+	assert.Empty(t, fingerprints)
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+
+	publicKey, err := keyTestRSA.ToPublic()
+	if err != nil {
+		t.Fatal("Cannot get public key:", err)
+	}
+	for _, key := range []*Key{keyTestEC, publicKey, keyTestRSA} {
+		if err := store.Put(key); err != nil {
+			t.Fatal("Cannot put key:", err)
+		}
+	}
+	// The private key replaced the public key with the same fingerprint.
+	fingerprints, err = store.List()
+	if err != nil {
+		t.Fatal("Cannot list keys:", err)
+	}
+	assert.Exactly(t, []string{keyTestEC.GetFingerprint(), keyTestRSA.GetFingerprint()}, fingerprints)
+
+	plaintext, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal("Cannot read key store:", err)
+	}
+	serialized, err := keyTestEC.Serialize()
+	if err != nil {
+		t.Fatal("Cannot serialize key:", err)
+	}
+	assert.False(t, bytes.Contains(plaintext, serialized[:64]))
+	// The key encryption key of the store is derived with Argon2.
+	assert.Equal(t, byte(constants.S2KArgon2), plaintext[7])
+
+	reopened, err := NewFileKeyStore(path, keyTestPassphrase)
+	if err != nil {
+		t.Fatal("Cannot open key store:", err)
+	}
+	key, err := reopened.Get(strings.ToUpper(keyTestRSA.GetFingerprint()))
+	if err != nil {
+		t.Fatal("Cannot get key:", err)
+	}
+	assert.True(t, key.IsPrivate())
+	assert.Exactly(t, keyTestRSA.GetFingerprint(), key.GetFingerprint())
+	keys, err := reopened.GetByUserId(strings.ToUpper(keyTestDomain))
+	if err != nil {
+		t.Fatal("Cannot get keys by user id:", err)
+	}
+	assert.Len(t, keys, 2)
+	key, err = reopened.Get(keyTestEC.GetFingerprint()[:8])
+	if err != nil {
+		t.Fatal("Cannot get key:", err)
+	}
+	assert.Nil(t, key)
+
+	if err := reopened.Delete(keyTestEC.GetFingerprint()); err != nil {
+		t.Fatal("Cannot delete key:", err)
+	}
+	if err := reopened.Delete(keyTestEC.GetFingerprint()); err != nil {
+		t.Fatal("Cannot delete missing key:", err)
+	}
+	reopened, err = NewFileKeyStore(path, keyTestPassphrase)
+	if err != nil {
+		t.Fatal("Cannot open key store:", err)
+	}
+	fingerprints, err = reopened.List()
+	if err != nil {
+		t.Fatal("Cannot list keys:", err)
+	}
+	assert.Exactly(t, []string{keyTestRSA.GetFingerprint()}, fingerprints)
+
+	_, err = NewFileKeyStore(path, []byte("wrong password"))
+	assert.Error(t, err)
+	_, err = NewFileKeyStore(path, nil)
+	assert.Error(t, err)
+}
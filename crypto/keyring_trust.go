@@ -0,0 +1,296 @@
+package crypto
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	openpgp "github.com/ProtonMail/go-crypto/openpgp/v2"
+	"github.com/ProtonMail/gopenpgp/v3/constants"
+	"github.com/pkg/errors"
+)
+
+// Default thresholds of the web of trust, like in GnuPG.
+const (
+	defaultMarginalsNeeded = 3
+	defaultCompletesNeeded = 1
+	defaultMaxCertDepth    = 5
+)
+
+// WebOfTrust computes the validity of the user ids in a key ring from owner trust
+// assignments and the certifications in the key ring, like the classic trust model of GnuPG.
+// The user ids of keys with ultimate owner trust are valid.
+// A user id of another key is fully valid if it is certified by enough fully valid keys
+// with owner trust, and marginally valid if it is certified by fewer such keys.
+// Certifications are followed up to a maximal depth from the keys with ultimate owner trust.
+// Revoked and expired keys, and user ids without valid self-certification are never valid.
+// The key ring must not be modified while it is used by the web of trust.
+type WebOfTrust struct {
+	keyRing         *KeyRing
+	ownerTrust      map[string]int8
+	marginalsNeeded int
+	completesNeeded int
+	maxDepth        int
+	// mutex guards the owner trust and the cached validities.
+	mutex sync.Mutex
+	// validities are the validities computed at validitiesTime by fingerprint and user id.
+	validities     map[string]map[string]int8
+	validitiesTime int64
+}
+
+// NewWebOfTrust creates a web of trust for the keys in the key ring,
+// in which all keys have unknown owner trust.
+// By default, a user id is fully valid if it is certified by one key with full owner trust
+// or by three keys with marginal owner trust, and the maximal depth is five.
+func NewWebOfTrust(keyRing *KeyRing) *WebOfTrust {
+	return &WebOfTrust{
+		keyRing:         keyRing,
+		ownerTrust:      make(map[string]int8),
+		marginalsNeeded: defaultMarginalsNeeded,
+		completesNeeded: defaultCompletesNeeded,
+		maxDepth:        defaultMaxCertDepth,
+	}
+}
+
+// SetOwnerTrust sets the owner trust of the key with the given hex encoded fingerprint,
+// see constants.OwnerTrust...
+func (wot *WebOfTrust) SetOwnerTrust(fingerprint string, ownerTrust int8) error {
+	if ownerTrust < constants.OwnerTrustUnknown || ownerTrust > constants.OwnerTrustUltimate {
+		return errors.Errorf("gopenpgp: invalid owner trust %d", ownerTrust)
+	}
+	fingerprint = strings.ToLower(fingerprint)
+	found := false
+	for _, entity := range wot.keyRing.entities {
+		if hex.EncodeToString(entity.PrimaryKey.Fingerprint) == fingerprint {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return errors.Errorf("gopenpgp: no key with fingerprint %s in the key ring", fingerprint)
+	}
+	wot.mutex.Lock()
+	defer wot.mutex.Unlock()
+	wot.ownerTrust[fingerprint] = ownerTrust
+	wot.validities = nil
+	return nil
+}
+
+// SetThresholds sets the number of certifications by keys with marginal owner trust
+// and by keys with full owner trust that make a user id fully valid,
+// and the maximal length of certification chains from keys with ultimate owner trust,
+// like the marginals-needed, completes-needed, and max-cert-depth options of GnuPG.
+func (wot *WebOfTrust) SetThresholds(marginalsNeeded, completesNeeded, maxDepth int) error {
+	if marginalsNeeded < 1 || completesNeeded < 1 || maxDepth < 1 {
+		return errors.New("gopenpgp: the web of trust thresholds must be positive")
+	}
+	wot.mutex.Lock()
+	defer wot.mutex.Unlock()
+	wot.marginalsNeeded = marginalsNeeded
+	wot.completesNeeded = completesNeeded
+	wot.maxDepth = maxDepth
+	wot.validities = nil
+	return nil
+}
+
+// GetUserIdValidity returns the validity of the user id, e.g., "name <email>",
+// of the key with the given hex encoded fingerprint at unixTime, see constants.Validity...
+func (wot *WebOfTrust) GetUserIdValidity(fingerprint, userId string, unixTime int64) int8 {
+	return wot.validitiesAt(unixTime)[strings.ToLower(fingerprint)][userId]
+}
+
+// GetKeyValidity returns the highest validity of the user ids of the key
+// with the given hex encoded fingerprint at unixTime, see constants.Validity...
+func (wot *WebOfTrust) GetKeyValidity(fingerprint string, unixTime int64) int8 {
+	return keyValidity(wot.validitiesAt(unixTime)[strings.ToLower(fingerprint)])
+}
+
+// GetValidKeysByEmail returns the keys with a user id with the given email address,
+// whose validity is at least minimumValidity at unixTime.
+// Email addresses are compared case-insensitively.
+func (wot *WebOfTrust) GetValidKeysByEmail(email string, minimumValidity int8, unixTime int64) *KeyRing {
+	validities := wot.validitiesAt(unixTime)
+	keyRing := &KeyRing{}
+	for _, entity := range wot.keyRing.entities {
+		userIds := validities[hex.EncodeToString(entity.PrimaryKey.Fingerprint)]
+		for name, validity := range userIds {
+			identity := entity.Identities[name]
+			if validity >= minimumValidity && strings.EqualFold(identity.UserId.Email, email) {
+				keyRing.entities = append(keyRing.entities, entity)
+				break
+			}
+		}
+	}
+	return keyRing
+}
+
+// KeyLookup returns a key lookup for verification handles that finds the keys in the key ring
+// whose validity is at least minimumValidity at unixTime.
+// Not supported on go-mobile clients.
+func (wot *WebOfTrust) KeyLookup(minimumValidity int8, unixTime int64) KeyLookup {
+	return KeyLookupFunc(func(keyID uint64, fingerprint []byte) (*Key, error) {
+		for _, entity := range wot.keyRing.getIndex().byKeyID[keyID] {
+			if !entityHasKey(entity, keyID, fingerprint) {
+				continue
+			}
+			if wot.GetKeyValidity(hex.EncodeToString(entity.PrimaryKey.Fingerprint), unixTime) >= minimumValidity {
+				return &Key{entity}, nil
+			}
+		}
+		return nil, nil
+	})
+}
+
+// validitiesAt returns the validities at unixTime by fingerprint and user id.
+// The returned maps must not be modified.
+func (wot *WebOfTrust) validitiesAt(unixTime int64) map[string]map[string]int8 {
+	wot.mutex.Lock()
+	defer wot.mutex.Unlock()
+	if wot.validities != nil && wot.validitiesTime == unixTime {
+		return wot.validities
+	}
+	date := time.Unix(unixTime, 0)
+	config := &packet.Config{Time: NewConstantClock(unixTime)}
+	issuers := make(map[string]*openpgp.Entity)
+	for _, entity := range wot.keyRing.entities {
+		issuers[hex.EncodeToString(entity.PrimaryKey.Fingerprint)] = entity
+		issuers[keyIDToHex(entity.PrimaryKey.KeyId)] = entity
+	}
+	validities := make(map[string]map[string]int8)
+	// certifiers are the fingerprints of the keys that certified a user id, by fingerprint and user id.
+	certifiers := make(map[string]map[string][]string)
+	for _, entity := range wot.keyRing.entities {
+		key := &Key{entity}
+		if key.IsRevoked(unixTime) || key.IsExpired(unixTime) || key.IsRevokedByDesignatedRevoker(wot.keyRing, unixTime) {
+			continue
+		}
+		fingerprint := key.GetFingerprint()
+		userIds := make(map[string]int8)
+		certifiers[fingerprint] = make(map[string][]string)
+		for name, identity := range entity.Identities {
+			if _, err := identity.Verify(date, config); err != nil {
+				continue
+			}
+			userIds[name] = constants.ValidityUnknown
+			if wot.ownerTrust[fingerprint] == constants.OwnerTrustUltimate {
+				userIds[name] = constants.ValidityUltimate
+			}
+			certifiers[fingerprint][name] = userIdCertifiers(entity, identity, issuers, date)
+		}
+		validities[fingerprint] = userIds
+	}
+	// Each round adds one level of certifications by the keys that were valid before.
+	for depth := 0; depth < wot.maxDepth; depth++ {
+		introducers := make(map[string]int8)
+		for fingerprint, userIds := range validities {
+			if ownerTrust := wot.ownerTrust[fingerprint]; ownerTrust >= constants.OwnerTrustMarginal &&
+				keyValidity(userIds) >= constants.ValidityFull {
+				introducers[fingerprint] = ownerTrust
+			}
+		}
+		changed := false
+		for fingerprint, userIds := range validities {
+			for name, validity := range userIds {
+				var completes, marginals int
+				for _, certifier := range certifiers[fingerprint][name] {
+					switch introducers[certifier] {
+					case constants.OwnerTrustFull, constants.OwnerTrustUltimate:
+						completes++
+					case constants.OwnerTrustMarginal:
+						marginals++
+					}
+				}
+				newValidity := constants.ValidityUnknown
+				switch {
+				case completes >= wot.completesNeeded || marginals >= wot.marginalsNeeded:
+					newValidity = constants.ValidityFull
+				case completes > 0 || marginals > 0:
+					newValidity = constants.ValidityMarginal
+				}
+				if newValidity > validity {
+					userIds[name] = newValidity
+					changed = true
+				}
+			}
+		}
+		if !changed {
+			break
+		}
+	}
+	wot.validities = validities
+	wot.validitiesTime = unixTime
+	return validities
+}
+
+// userIdCertifiers returns the fingerprints of the other keys whose latest certification
+// of the user id is valid at date and not a revocation.
+// The issuers are the keys of the key ring by hex encoded fingerprint and key id.
+func userIdCertifiers(
+	entity *openpgp.Entity,
+	identity *openpgp.Identity,
+	issuers map[string]*openpgp.Entity,
+	date time.Time,
+) []string {
+	latest := make(map[*openpgp.Entity]*packet.Signature)
+	for _, certification := range identity.OtherCertifications {
+		sig := certification.Packet
+		if sig.CreationTime.After(date) {
+			continue
+		}
+		var certifier *openpgp.Entity
+		switch {
+		case sig.IssuerFingerprint != nil:
+			certifier = issuers[hex.EncodeToString(sig.IssuerFingerprint)]
+		case sig.IssuerKeyId != nil:
+			certifier = issuers[keyIDToHex(*sig.IssuerKeyId)]
+		}
+		if certifier == nil || certifier == entity {
+			continue
+		}
+		if previous := latest[certifier]; previous != nil && previous.CreationTime.After(sig.CreationTime) {
+			continue
+		}
+		if err := certifier.PrimaryKey.VerifyUserIdSignature(identity.Name, entity.PrimaryKey, sig); err != nil {
+			continue
+		}
+		latest[certifier] = sig
+	}
+	var fingerprints []string
+	for certifier, sig := range latest {
+		if sig.SigType != packet.SigTypeCertificationRevocation && !sig.SigExpired(date) {
+			fingerprints = append(fingerprints, hex.EncodeToString(certifier.PrimaryKey.Fingerprint))
+		}
+	}
+	return fingerprints
+}
+
+// keyValidity returns the highest validity of the user ids.
+func keyValidity(userIds map[string]int8) int8 {
+	validity := constants.ValidityUnknown
+	for _, userIdValidity := range userIds {
+		if userIdValidity > validity {
+			validity = userIdValidity
+		}
+	}
+	return validity
+}
+
+// entityHasKey returns true if the primary key or a subkey of the entity has the key id,
+// and the fingerprint if it is not nil.
+func entityHasKey(entity *openpgp.Entity, keyID uint64, fingerprint []byte) bool {
+	matches := func(publicKey *packet.PublicKey) bool {
+		return publicKey.KeyId == keyID && (fingerprint == nil || bytes.Equal(publicKey.Fingerprint, fingerprint))
+	}
+	if matches(entity.PrimaryKey) {
+		return true
+	}
+	for _, subkey := range entity.Subkeys {
+		if matches(subkey.PublicKey) {
+			return true
+		}
+	}
+	return false
+}
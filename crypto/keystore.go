@@ -0,0 +1,211 @@
+package crypto
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// KeyStore persists keys, e.g., the private keys of a user and the public keys of contacts,
+// such that applications do not need to persist their key rings themselves.
+// Keys are identified by their hex encoded fingerprint.
+// Implementations must be safe for concurrent use.
+// Not supported on go-mobile clients.
+type KeyStore interface {
+	// Get returns the key with the given fingerprint, or nil if the key is not in the store.
+	Get(fingerprint string) (*Key, error)
+	// GetByUserId returns the keys with a user id that matches either the full user id,
+	// e.g., "name <email>", or its email address.
+	GetByUserId(userId string) ([]*Key, error)
+	// Put adds the key to the store, or replaces the stored key with the same fingerprint.
+	Put(key *Key) error
+	// Delete removes the key with the given fingerprint from the store.
+	// Deleting a key that is not in the store is not an error.
+	Delete(fingerprint string) error
+	// List returns the fingerprints of all keys in the store.
+	List() ([]string, error)
+}
+
+// FileKeyStore is a KeyStore that keeps all keys in a single file,
+// which is encrypted with a password as OpenPGP message, see EncryptWithPassword.
+// The keys are read once when the store is opened, and the file is
+// replaced atomically on every change.
+// Not supported on go-mobile clients.
+type FileKeyStore struct {
+	path     string
+	password []byte
+	pgp      *PGPHandle
+	// mutex guards keys and the file.
+	mutex sync.Mutex
+	keys  []*Key
+}
+
+// NewFileKeyStore opens the key store in the file at path, which is encrypted with password.
+// If the file does not exist, the store is empty and the file is created on the first change.
+// Not supported on go-mobile clients.
+func NewFileKeyStore(path string, password []byte) (*FileKeyStore, error) {
+	if len(password) == 0 {
+		return nil, errors.New("gopenpgp: the key store password must not be empty")
+	}
+	store := &FileKeyStore{
+		path:     path,
+		password: append([]byte(nil), password...),
+		pgp:      passwordPGP(),
+	}
+	ciphertext, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: error in reading key store")
+	}
+	decryptor, err := store.pgp.Decryption().Password(password).New()
+	if err != nil {
+		return nil, err
+	}
+	decrypted, err := decryptor.Decrypt(ciphertext, Bytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: error in decrypting key store")
+	}
+	if len(decrypted.Bytes()) == 0 {
+		return store, nil
+	}
+	entities, err := readKeyRing(bytes.NewReader(decrypted.Bytes()), false)
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: error in reading key store")
+	}
+	for _, entity := range entities {
+		store.keys = append(store.keys, &Key{entity})
+	}
+	return store, nil
+}
+
+// Get returns a copy of the key with the given fingerprint,
+// or nil if the key is not in the store.
+func (store *FileKeyStore) Get(fingerprint string) (*Key, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	if index := store.index(fingerprint); index >= 0 {
+		return store.keys[index].Copy()
+	}
+	return nil, nil
+}
+
+// GetByUserId returns copies of the keys with a user id that matches either the full user id,
+// e.g., "name <email>", or its email address.
+// Email addresses are compared case-insensitively.
+func (store *FileKeyStore) GetByUserId(userId string) ([]*Key, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	var keys []*Key
+	for _, key := range store.keys {
+		if findIdentity(key.entity, userId) == nil {
+			continue
+		}
+		keyCopy, err := key.Copy()
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, keyCopy)
+	}
+	return keys, nil
+}
+
+// Put adds a copy of the key to the store, or replaces the stored key with the same fingerprint.
+// Private keys are stored as they are, i.e., unlocked keys are only protected
+// by the password of the store.
+func (store *FileKeyStore) Put(key *Key) error {
+	keyCopy, err := key.Copy()
+	if err != nil {
+		return err
+	}
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	keys := append([]*Key(nil), store.keys...)
+	if index := store.index(key.GetFingerprint()); index >= 0 {
+		keys[index] = keyCopy
+	} else {
+		keys = append(keys, keyCopy)
+	}
+	return store.write(keys)
+}
+
+// Delete removes the key with the given fingerprint from the store.
+func (store *FileKeyStore) Delete(fingerprint string) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	index := store.index(fingerprint)
+	if index < 0 {
+		return nil
+	}
+	keys := append(append([]*Key(nil), store.keys[:index]...), store.keys[index+1:]...)
+	return store.write(keys)
+}
+
+// List returns the fingerprints of all keys in the store in the order they were added.
+func (store *FileKeyStore) List() ([]string, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	fingerprints := make([]string, len(store.keys))
+	for index, key := range store.keys {
+		fingerprints[index] = key.GetFingerprint()
+	}
+	return fingerprints, nil
+}
+
+// index returns the index of the key with the fingerprint, or -1 if it is not in the store.
+func (store *FileKeyStore) index(fingerprint string) int {
+	fingerprint = strings.ToLower(fingerprint)
+	for index, key := range store.keys {
+		if key.GetFingerprint() == fingerprint {
+			return index
+		}
+	}
+	return -1
+}
+
+// write encrypts the keys and replaces the file, and then the keys of the store.
+func (store *FileKeyStore) write(keys []*Key) error {
+	var plaintext bytes.Buffer
+	for _, key := range keys {
+		serialized, err := key.Serialize()
+		if err != nil {
+			return err
+		}
+		plaintext.Write(serialized)
+	}
+	encryptor, err := store.pgp.Encryption().Password(store.password).New()
+	if err != nil {
+		return err
+	}
+	ciphertext, err := encryptor.Encrypt(plaintext.Bytes())
+	if err != nil {
+		return errors.Wrap(err, "gopenpgp: error in encrypting key store")
+	}
+	// The temporary file is only readable by the owner and replaces the store atomically.
+	temporary, err := os.CreateTemp(filepath.Dir(store.path), filepath.Base(store.path)+".*.tmp")
+	if err != nil {
+		return errors.Wrap(err, "gopenpgp: error in writing key store")
+	}
+	_, err = temporary.Write(ciphertext.Bytes())
+	if err == nil {
+		// The content must be on disk before the rename, otherwise a crash might leave an empty store.
+		err = temporary.Sync()
+	}
+	if closeErr := temporary.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(temporary.Name(), store.path)
+	}
+	if err != nil {
+		_ = os.Remove(temporary.Name())
+		return errors.Wrap(err, "gopenpgp: error in writing key store")
+	}
+	store.keys = keys
+	return nil
+}
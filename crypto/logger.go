@@ -0,0 +1,355 @@
+package crypto
+
+import (
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	openpgp "github.com/ProtonMail/go-crypto/openpgp/v2"
+)
+
+// Levels of the entries passed to a Logger.
+const (
+	LogLevelDebug int8 = iota
+	LogLevelInfo
+	LogLevelWarning
+)
+
+// LogEntry describes a decision of a handle, e.g., the selected key of a recipient,
+// the negotiated algorithms of an encryption, or the status of a signature candidate.
+// The fields only contain key ids, fingerprints, algorithms, packet versions, statuses,
+// and error messages. Private keys, passphrases, passwords, session keys, and plaintext
+// are never logged, thus entries can be written to production logs.
+// Not supported on go-mobile clients.
+type LogEntry struct {
+	// Level is one of LogLevelDebug, LogLevelInfo, or LogLevelWarning.
+	Level int8
+	// Operation is one of OperationEncrypt, OperationDecrypt, OperationSign, or OperationVerify.
+	Operation string
+	// Message describes the decision, e.g., "selected encryption key".
+	Message string
+	// Fields contains the details of the decision, e.g., "keyId" or "cipher".
+	Fields map[string]string
+}
+
+// Logger is called by the handles at key decision points, i.e., the selection of keys,
+// the negotiation of algorithms, and the verification of signature candidates,
+// to diagnose interoperability failures, e.g., with the keys of other implementations.
+// Log is called synchronously, and might be called concurrently by different handles.
+// Not supported on go-mobile clients.
+type Logger interface {
+	Log(entry *LogEntry)
+}
+
+// LoggerFunc is an adapter to use a function as Logger.
+// Not supported on go-mobile clients.
+type LoggerFunc func(entry *LogEntry)
+
+// Log calls f(entry).
+func (f LoggerFunc) Log(entry *LogEntry) {
+	f(entry)
+}
+
+// logEntry passes the entry to the logger, if it is not nil.
+func logEntry(logger Logger, level int8, operation, message string, fields map[string]string) {
+	if logger == nil {
+		return
+	}
+	logger.Log(&LogEntry{
+		Level:     level,
+		Operation: operation,
+		Message:   message,
+		Fields:    fields,
+	})
+}
+
+func keyIDsToHex(keyIDs []uint64) string {
+	hexKeyIDs := make([]string, len(keyIDs))
+	for i, keyID := range keyIDs {
+		hexKeyIDs[i] = keyIDToHex(keyID)
+	}
+	return strings.Join(hexKeyIDs, ",")
+}
+
+// keyAndSubkeyIDs returns the ids of the primary keys and subkeys in the key ring,
+// which are matched against the key ids of the key packets of a message.
+func keyAndSubkeyIDs(keyRing *KeyRing) []uint64 {
+	var keyIDs []uint64
+	for _, entity := range keyRing.entities {
+		keyIDs = append(keyIDs, entity.PrimaryKey.KeyId)
+		for _, subkey := range entity.Subkeys {
+			keyIDs = append(keyIDs, subkey.PublicKey.KeyId)
+		}
+	}
+	return keyIDs
+}
+
+func aeadModeName(mode packet.AEADMode) string {
+	switch mode {
+	case packet.AEADModeEAX:
+		return "eax"
+	case packet.AEADModeOCB:
+		return "ocb"
+	case packet.AEADModeGCM:
+		return "gcm"
+	}
+	return strconv.Itoa(int(mode))
+}
+
+// logEncryptionKeys logs the selected encryption key of each recipient.
+func logEncryptionKeys(logger Logger, recipients []*openpgp.Entity, config *packet.Config, now time.Time) {
+	if logger == nil {
+		return
+	}
+	for _, recipient := range recipients {
+		fields := map[string]string{
+			"fingerprint": hex.EncodeToString(recipient.PrimaryKey.Fingerprint),
+		}
+		encryptionKey, ok := recipient.EncryptionKey(now, config)
+		if !ok {
+			logEntry(logger, LogLevelWarning, OperationEncrypt, "no valid encryption key", fields)
+			continue
+		}
+		fields["keyId"] = keyIDToHex(encryptionKey.PublicKey.KeyId)
+		fields["keyVersion"] = strconv.Itoa(encryptionKey.PublicKey.Version)
+		fields["algorithm"] = strconv.Itoa(int(encryptionKey.PublicKey.PubKeyAlgo))
+		selfSignature, err := recipient.PrimarySelfSignature(now, config)
+		if err != nil {
+			fields["error"] = err.Error()
+			logEntry(logger, LogLevelWarning, OperationEncrypt, "no valid self-signature", fields)
+			continue
+		}
+		fields["seipdv2"] = strconv.FormatBool(selfSignature.SEIPDv2)
+		logEntry(logger, LogLevelDebug, OperationEncrypt, "selected encryption key", fields)
+	}
+}
+
+const (
+	encryptedKeyTag          = 1
+	symmetricKeyEncryptedTag = 3
+)
+
+// algorithmLog logs the algorithms of a message, once the header of its
+// encrypted data packet is written.
+type algorithmLog struct {
+	logger  Logger
+	message string
+	fields  map[string]string
+	logged  bool
+}
+
+// writer returns a writer that passes the packets of the message through to w,
+// and reads the algorithms from the headers of the written packets.
+// Thus, the entry reports the algorithms go-crypto encrypted the message with.
+func (l *algorithmLog) writer(w Writer) Writer {
+	return &algorithmLogWriter{Writer: w, log: l}
+}
+
+func (l *algorithmLog) logDataPacket(body []byte) {
+	l.logged = true
+	l.fields["seipdVersion"] = strconv.Itoa(int(body[0]))
+	if body[0] == 2 {
+		l.fields["cipher"] = algosToSymKey[packet.CipherFunction(body[1])]
+		l.fields["aeadMode"] = aeadModeName(packet.AEADMode(body[2]))
+		l.fields["aeadChunkSize"] = strconv.Itoa(1 << (body[3] + 6))
+	}
+	logEntry(l.logger, LogLevelInfo, OperationEncrypt, l.message, l.fields)
+}
+
+// algorithmLogWriter skips the key packets and reads the start of the encrypted data packet.
+// go-crypto writes new format packet headers only, other packets stop the parsing.
+type algorithmLogWriter struct {
+	Writer
+	log    *algorithmLog
+	packet []byte
+	skip   int
+	done   bool
+}
+
+func (w *algorithmLogWriter) Write(b []byte) (int, error) {
+	n, err := w.Writer.Write(b)
+	for remaining := b[:n]; len(remaining) > 0 && !w.done && !w.log.logged; {
+		if w.skip > 0 {
+			skipped := w.skip
+			if skipped > len(remaining) {
+				skipped = len(remaining)
+			}
+			w.skip -= skipped
+			remaining = remaining[skipped:]
+			continue
+		}
+		w.packet = append(w.packet, remaining[0])
+		remaining = remaining[1:]
+		w.readPacketStart()
+	}
+	return n, err
+}
+
+// readPacketStart parses the buffered start of the current packet,
+// and waits for more bytes if they are needed.
+func (w *algorithmLogWriter) readPacketStart() {
+	if w.packet[0]&0xc0 != 0xc0 {
+		w.done = true
+		return
+	}
+	bodyLength, lengthBytes, partial, err := readNewFormatLength(w.packet[1:])
+	if err != nil {
+		// The length takes at most five octets.
+		w.done = len(w.packet) > 5
+		return
+	}
+	body := w.packet[1+lengthBytes:]
+	switch w.packet[0] & 0x3f {
+	case encryptedKeyTag:
+	case symmetricKeyEncryptedTag:
+		if len(body) < 2 {
+			return
+		}
+		if body[0] == 4 {
+			// go-crypto encrypts the session key of v4 key packets with the cipher of the message.
+			w.log.fields["cipher"] = algosToSymKey[packet.CipherFunction(body[1])]
+		}
+	case seipdTag:
+		if len(body) < 1 || body[0] == 2 && len(body) < 4 {
+			return
+		}
+		w.log.logDataPacket(body)
+		return
+	default:
+		w.done = true
+		return
+	}
+	if partial || len(body) > bodyLength {
+		w.done = true
+		return
+	}
+	w.skip = bodyLength - len(body)
+	w.packet = w.packet[:0]
+}
+
+// logSigningKeys logs the selected signing key of each key in the key ring.
+func logSigningKeys(logger Logger, operation string, signers *KeyRing, config *packet.Config, now time.Time) {
+	if logger == nil || signers == nil {
+		return
+	}
+	for _, signer := range signers.entities {
+		fields := map[string]string{
+			"fingerprint": hex.EncodeToString(signer.PrimaryKey.Fingerprint),
+		}
+		signingKey, ok := signer.SigningKey(now, config)
+		if !ok {
+			logEntry(logger, LogLevelWarning, operation, "no valid signing key", fields)
+			continue
+		}
+		fields["keyId"] = keyIDToHex(signingKey.PublicKey.KeyId)
+		fields["keyVersion"] = strconv.Itoa(signingKey.PublicKey.Version)
+		fields["algorithm"] = strconv.Itoa(int(signingKey.PublicKey.PubKeyAlgo))
+		logEntry(logger, LogLevelDebug, operation, "selected signing key", fields)
+	}
+}
+
+// logEncryption logs the selected keys of the recipients and signers, and returns
+// the algorithm log that logs the algorithms the message is encrypted with.
+// Hidden recipients are only counted, their keys are not logged.
+func (eh *encryptionHandle) logEncryption() *algorithmLog {
+	if eh.Logger == nil {
+		return nil
+	}
+	config := eh.encryptionConfig()
+	now := eh.clock()
+	logSigningKeys(eh.Logger, OperationEncrypt, eh.SignKeyRing, config, now)
+	if eh.encryptionTimeOverride != nil {
+		now = eh.encryptionTimeOverride()
+	}
+	log := &algorithmLog{
+		logger:  eh.Logger,
+		message: "selected algorithms",
+		fields:  map[string]string{},
+	}
+	if recipients := eh.Recipients.getEntities(); len(recipients) > 0 {
+		logEncryptionKeys(eh.Logger, recipients, config, now)
+		log.message = "negotiated algorithms"
+	}
+	if hiddenRecipients := eh.HiddenRecipients.CountEntities(); hiddenRecipients > 0 {
+		log.fields["hiddenRecipients"] = strconv.Itoa(hiddenRecipients)
+		log.message = "negotiated algorithms"
+	}
+	if eh.SessionKey != nil && !eh.SessionKey.v6 {
+		// The cipher of SEIPDv1 packets is not stated in the packet.
+		log.fields["cipher"] = eh.SessionKey.Algo
+	}
+	return log
+}
+
+// logDecryption logs the key or password that decrypted the message and its cipher,
+// or the available decryption keys if the decryption failed.
+func (dh *decryptionHandle) logDecryption(reader *VerifyDataReader, err error) {
+	if dh.Logger == nil {
+		return
+	}
+	if err != nil {
+		fields := map[string]string{"error": err.Error()}
+		if dh.DecryptionKeyRing != nil {
+			fields["decryptionKeyIds"] = keyIDsToHex(keyAndSubkeyIDs(dh.DecryptionKeyRing))
+		}
+		logEntry(dh.Logger, LogLevelWarning, OperationDecrypt, "decryption failed", fields)
+		return
+	}
+	details := reader.details
+	if !details.IsEncrypted {
+		return
+	}
+	fields := map[string]string{
+		"cipher":            algosToSymKey[details.DecryptedWithAlgorithm],
+		"encryptedToKeyIds": keyIDsToHex(details.EncryptedToKeyIds),
+	}
+	switch {
+	case details.DecryptedWith.PublicKey != nil:
+		fields["keyId"] = keyIDToHex(details.DecryptedWith.PublicKey.KeyId)
+		if details.DecryptedWith.Entity != nil {
+			fields["fingerprint"] = hex.EncodeToString(details.DecryptedWith.Entity.PrimaryKey.Fingerprint)
+		}
+	case reader.passwordIndex != noPasswordIndex:
+		fields["passwordIndex"] = strconv.Itoa(reader.passwordIndex)
+	}
+	logEntry(dh.Logger, LogLevelInfo, OperationDecrypt, "decrypted message", fields)
+}
+
+// operation returns the operation the reader was created for.
+func (msg *VerifyDataReader) operation() string {
+	if msg.details.IsEncrypted || msg.details.DecryptedWithAlgorithm != 0 {
+		return OperationDecrypt
+	}
+	return OperationVerify
+}
+
+// logSignatureCandidates logs the issuer, algorithms, and status of each signature candidate
+// of the message with the corresponding signature in the result.
+func logSignatureCandidates(logger Logger, operation string, details *openpgp.MessageDetails, result *VerifyResult) {
+	if logger == nil || len(details.SignatureCandidates) != len(result.Signatures) {
+		return
+	}
+	for index, candidate := range details.SignatureCandidates {
+		fields := map[string]string{
+			"issuerKeyId": keyIDToHex(candidate.IssuerKeyId),
+			"hash":        candidate.HashAlgorithm.String(),
+			"algorithm":   strconv.Itoa(int(candidate.PubKeyAlgo)),
+			"status":      "ok",
+		}
+		if candidate.IssuerFingerprint != nil {
+			fields["issuerFingerprint"] = hex.EncodeToString(candidate.IssuerFingerprint)
+		}
+		if candidate.CorrespondingSig != nil {
+			fields["version"] = strconv.Itoa(candidate.CorrespondingSig.Version)
+		}
+		level := LogLevelDebug
+		if signatureError := result.Signatures[index].SignatureError; signatureError != nil {
+			level = LogLevelWarning
+			fields["status"] = strconv.Itoa(signatureError.Status)
+			fields["error"] = signatureError.Error()
+		}
+		logEntry(logger, level, operation, "verified signature candidate", fields)
+	}
+}
@@ -6,11 +6,14 @@ import (
 	goerrors "errors"
 	"io"
 	"regexp"
+	"strconv"
+	"time"
 
 	"github.com/ProtonMail/go-crypto/openpgp/packet"
 	"github.com/ProtonMail/gopenpgp/v3/armor"
 	"github.com/ProtonMail/gopenpgp/v3/constants"
 	"github.com/ProtonMail/gopenpgp/v3/internal"
+	"github.com/ProtonMail/gopenpgp/v3/profile"
 	"github.com/pkg/errors"
 )
 
@@ -113,6 +116,57 @@ func NewPGPSplitMessage(keyPacket []byte, dataPacket []byte) *PGPMessage {
 	}
 }
 
+// NewPGPSplitMessageFromArmored generates a new PGPMessage from separately armored
+// key packets and data packets, e.g., as produced by ArmorKeyPacket and ArmorDataPacket.
+func NewPGPSplitMessageFromArmored(armoredKeyPacket, armoredDataPacket string) (*PGPMessage, error) {
+	keyPacket, err := armor.Unarmor(armoredKeyPacket)
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: error in unarmoring key packets")
+	}
+	dataPacket, err := armor.Unarmor(armoredDataPacket)
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: error in unarmoring data packets")
+	}
+	return &PGPMessage{
+		KeyPacket:  keyPacket,
+		DataPacket: dataPacket,
+	}, nil
+}
+
+// SplitPGPMessage splits an encrypted pgp message into its key packets and data packets.
+// In contrast to NewPGPMessage, it returns an error if the message cannot be parsed
+// or does not contain an encrypted data packet.
+// The encoding indicates if the input message should be unarmored or not, i.e., Bytes/Armor/Base64/Auto
+// where Auto tries to detect automatically.
+func SplitPGPMessage(message []byte, encoding int8) (*PGPMessage, error) {
+	reader, armored := unarmorInput(encoding, bytes.NewReader(message))
+	if armored {
+		armoredBlock, err := armor.ArmorReader(reader)
+		if err != nil {
+			return nil, errors.Wrap(err, "gopenpgp: error in unarmoring message")
+		}
+		reader = armoredBlock
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: error in reading message")
+	}
+	pgpMessage, err := (&PGPMessage{DataPacket: data}).splitMessage()
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: error in splitting message")
+	}
+	dataPacket, err := packet.Read(bytes.NewReader(pgpMessage.DataPacket))
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: unable to parse data packet")
+	}
+	switch dataPacket.(type) {
+	case *packet.SymmetricallyEncrypted, *packet.AEADEncrypted:
+		return pgpMessage, nil
+	default:
+		return nil, errors.New("gopenpgp: message does not contain an encrypted data packet")
+	}
+}
+
 // NewPGPMessageBuffer creates a message buffer.
 func NewPGPMessageBuffer() *PGPMessageBuffer {
 	return &PGPMessageBuffer{
@@ -125,15 +179,32 @@ func NewPGPMessageBuffer() *PGPMessageBuffer {
 // ---- MODEL METHODS -----
 
 // Bytes returns the unarmored binary content of the message as a []byte.
+// The key packets and data packets are copied into a new slice.
 func (msg *PGPMessage) Bytes() []byte {
-	return append(msg.KeyPacket, msg.DataPacket...)
+	data := make([]byte, 0, len(msg.KeyPacket)+len(msg.DataPacket))
+	data = append(data, msg.KeyPacket...)
+	return append(data, msg.DataPacket...)
 }
 
 // NewReader returns a New io.Reader for the unarmored binary data of the
 // message.
+// The reader reads the key packets and data packets in place, without copying them.
 // Not supported on go-mobile clients.
 func (msg *PGPMessage) NewReader() io.Reader {
-	return bytes.NewReader(msg.Bytes())
+	return io.MultiReader(bytes.NewReader(msg.KeyPacket), bytes.NewReader(msg.DataPacket))
+}
+
+// WriteTo writes the unarmored binary data of the message to w,
+// without concatenating the key packets and data packets into a new slice.
+// It implements io.WriterTo.
+// Not supported on go-mobile clients.
+func (msg *PGPMessage) WriteTo(w io.Writer) (int64, error) {
+	n, err := w.Write(msg.KeyPacket)
+	if err != nil {
+		return int64(n), err
+	}
+	m, err := w.Write(msg.DataPacket)
+	return int64(n + m), err
 }
 
 // Armor returns the armored message as a string.
@@ -167,6 +238,43 @@ func (msg *PGPMessage) ArmorWithCustomHeaders(comment, version string) (string,
 	return armor.ArmorWithTypeAndCustomHeaders(msg.Bytes(), constants.PGPMessageHeader, version, comment)
 }
 
+// ArmorWithOptions returns the armored message as a string, with the given
+// armor options, e.g., custom headers or line length.
+// Not supported on go-mobile clients.
+func (msg *PGPMessage) ArmorWithOptions(options *armor.Options) (string, error) {
+	if msg.KeyPacket == nil {
+		return "", errors.New("gopenpgp: missing key packets in pgp message")
+	}
+	messageOptions := armor.Options{}
+	if options != nil {
+		messageOptions = *options
+	}
+	messageOptions.OmitChecksum = messageOptions.OmitChecksum || msg.omitArmorChecksum
+	armored, err := armor.ArmorWithOptions(msg.Bytes(), constants.PGPMessageHeader, &messageOptions)
+	if err != nil {
+		return "", err
+	}
+	return string(armored), nil
+}
+
+// ArmorKeyPacket returns the key packets of the message armored
+// as a separate pgp message.
+func (msg *PGPMessage) ArmorKeyPacket() (string, error) {
+	if len(msg.KeyPacket) == 0 {
+		return "", errors.New("gopenpgp: missing key packets in pgp message")
+	}
+	return armor.ArmorPGPMessageChecksum(msg.KeyPacket, !msg.omitArmorChecksum)
+}
+
+// ArmorDataPacket returns the data packets of the message armored
+// as a separate pgp message.
+func (msg *PGPMessage) ArmorDataPacket() (string, error) {
+	if len(msg.DataPacket) == 0 {
+		return "", errors.New("gopenpgp: missing data packets in pgp message")
+	}
+	return armor.ArmorPGPMessageChecksum(msg.DataPacket, !msg.omitArmorChecksum)
+}
+
 // EncryptionKeyIDs Returns the key IDs of the keys to which the session key is encrypted.
 // Not supported on go-mobile clients use msg.HexEncryptionKeyIDsJson() instead.
 func (msg *PGPMessage) EncryptionKeyIDs() ([]uint64, bool) {
@@ -307,6 +415,97 @@ func (msg *PGPMessage) GetNumberOfKeyPackets() (int, error) {
 	return keyPacketCount, nil
 }
 
+// AddRecipients returns a new message where the session key is additionally encrypted
+// to the recipients in the key ring.
+// The sessionKey must be the decrypted session key of the message.
+// The data packet and the existing key packets are reused byte-for-byte,
+// thus the data does not have to be re-encrypted.
+func (msg *PGPMessage) AddRecipients(sessionKey *SessionKey, recipients *KeyRing) (*PGPMessage, error) {
+	if sessionKey == nil || recipients.CountEntities() == 0 {
+		return nil, errors.New("gopenpgp: no session key or recipients provided")
+	}
+	dataPacket, err := msg.parseDataPacketHeader()
+	if err != nil {
+		return nil, err
+	}
+	aeadSupport := dataPacket.Version == 2 // SEIPDv2 requires v6 PKESKs
+	cipherFunc := dataPacket.Cipher
+	if !aeadSupport {
+		if cipherFunc, err = sessionKey.GetCipherFunc(); err != nil {
+			return nil, errors.Wrap(err, "gopenpgp: unable to add recipients")
+		}
+	}
+
+	keyPackets := bytes.NewBuffer(clone(msg.KeyPacket))
+	now := time.Now()
+	config := profile.Default().EncryptionConfig()
+	for _, entity := range recipients.isolatedEntities() {
+		encryptionKey, ok := entity.EncryptionKey(now, config)
+		if !ok {
+			return nil, errors.New("gopenpgp: encryption key is unavailable for key id " + strconv.FormatUint(entity.PrimaryKey.KeyId, 16))
+		}
+		err = packet.SerializeEncryptedKeyAEAD(keyPackets, encryptionKey.PublicKey, cipherFunc, aeadSupport, sessionKey.Key, nil)
+		if err != nil {
+			return nil, errors.Wrap(err, "gopenpgp: unable to add recipients")
+		}
+	}
+	return msg.withKeyPacket(keyPackets.Bytes()), nil
+}
+
+// RemoveRecipients returns a new message without the key packets that are
+// encrypted to any of the keys in the key ring.
+// The data packet and the remaining key packets are reused byte-for-byte.
+// Key packets of hidden recipients cannot be attributed to a key and are kept.
+func (msg *PGPMessage) RemoveRecipients(recipients *KeyRing) (*PGPMessage, error) {
+	if recipients == nil {
+		return nil, errors.New("gopenpgp: no recipients provided")
+	}
+	var keyPackets bytes.Buffer
+	bytesReader := bytes.NewReader(msg.KeyPacket)
+	packets := packet.NewReader(bytesReader)
+	start := int64(0)
+	for {
+		p, err := packets.Next()
+		if goerrors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "gopenpgp: unable to parse key packets")
+		}
+		end := bytesReader.Size() - int64(bytesReader.Len())
+		ek, ok := p.(*packet.EncryptedKey)
+		if !ok || ek.KeyId == 0 || len(keyRings{recipients}.KeysById(ek.KeyId)) == 0 {
+			keyPackets.Write(msg.KeyPacket[start:end])
+		}
+		start = end
+	}
+	return msg.withKeyPacket(keyPackets.Bytes()), nil
+}
+
+// parseDataPacketHeader parses the header of the SEIPD data packet of the message.
+func (msg *PGPMessage) parseDataPacketHeader() (*packet.SymmetricallyEncrypted, error) {
+	p, err := packet.Read(bytes.NewReader(msg.DataPacket))
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: unable to parse data packet")
+	}
+	dataPacket, ok := p.(*packet.SymmetricallyEncrypted)
+	if !ok {
+		return nil, errors.New("gopenpgp: data packet is not a SEIPD packet")
+	}
+	return dataPacket, nil
+}
+
+// withKeyPacket returns a copy of the message with the key packets replaced.
+func (msg *PGPMessage) withKeyPacket(keyPacket []byte) *PGPMessage {
+	return &PGPMessage{
+		KeyPacket:                keyPacket,
+		DataPacket:               msg.DataPacket,
+		DetachedSignature:        msg.DetachedSignature,
+		detachedSignatureIsPlain: msg.detachedSignatureIsPlain,
+		omitArmorChecksum:        msg.omitArmorChecksum,
+	}
+}
+
 // splitMessage splits the message into key and data packet(s).
 func (msg *PGPMessage) splitMessage() (*PGPMessage, error) {
 	data := msg.DataPacket
@@ -0,0 +1,248 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto"
+	"encoding/binary"
+	"encoding/json"
+
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	openpgp "github.com/ProtonMail/go-crypto/openpgp/v2"
+	"github.com/pkg/errors"
+)
+
+// PacketInfo describes an OpenPGP packet of a pgp message.
+// Fields that do not apply to the packet type are zero.
+type PacketInfo struct {
+	// Tag is the packet type tag, e.g., 1 for a public-key encrypted session key packet.
+	Tag int `json:"tag"`
+	// Name is the name of the packet type.
+	Name string `json:"name"`
+	// Offset is the position of the packet header in the message.
+	Offset int `json:"offset"`
+	// HeaderLength is the length of the first packet header.
+	HeaderLength int `json:"headerLength"`
+	// Length is the length of the packet body.
+	// For packets with partial body lengths, it is the sum of all partial bodies.
+	Length int `json:"length"`
+	// PartialLength indicates that the body is encoded with partial body lengths.
+	PartialLength bool `json:"partialLength"`
+	// Version is the packet version.
+	Version int `json:"version,omitempty"`
+	// KeyID is the key id of the recipient or issuer key. Zero for wildcard key ids.
+	KeyID uint64 `json:"keyId,omitempty"`
+	// KeyFingerprint is the fingerprint of the recipient key for v6 packets.
+	KeyFingerprint []byte `json:"keyFingerprint,omitempty"`
+	// PublicKeyAlgorithm is the public-key algorithm id of key and signature packets.
+	PublicKeyAlgorithm int `json:"publicKeyAlgorithm,omitempty"`
+	// Cipher is the symmetric cipher algorithm id, if it is stated in the packet.
+	Cipher int `json:"cipher,omitempty"`
+	// AEADMode is the AEAD mode id of AEAD encrypted packets.
+	AEADMode int `json:"aeadMode,omitempty"`
+	// AEADChunkSize is the chunk size in bytes of SEIPDv2 packets.
+	AEADChunkSize int `json:"aeadChunkSize,omitempty"`
+	// Hash is the OpenPGP hash algorithm id of signature packets.
+	Hash int `json:"hash,omitempty"`
+	// SignatureType is the signature type of signature packets.
+	SignatureType int `json:"signatureType,omitempty"`
+}
+
+var packetNames = map[int]string{
+	1:  "Public-Key Encrypted Session Key",
+	2:  "Signature",
+	3:  "Symmetric-Key Encrypted Session Key",
+	4:  "One-Pass Signature",
+	5:  "Secret-Key",
+	6:  "Public-Key",
+	7:  "Secret-Subkey",
+	8:  "Compressed Data",
+	9:  "Symmetrically Encrypted Data",
+	10: "Marker",
+	11: "Literal Data",
+	12: "Trust",
+	13: "User ID",
+	14: "Public-Subkey",
+	17: "User Attribute",
+	18: "Symmetrically Encrypted and Integrity Protected Data",
+	19: "Modification Detection Code",
+	20: "AEAD Encrypted Data",
+	21: "Padding",
+}
+
+// InspectPackets returns a description of each top-level packet in the message,
+// e.g., for debugging interoperability issues.
+// Encrypted packets are not decrypted, thus, their contents are not inspected.
+// Not supported on go-mobile clients use msg.InspectPacketsJson() instead.
+func (msg *PGPMessage) InspectPackets() ([]*PacketInfo, error) {
+	return inspectPackets(msg.Bytes())
+}
+
+// InspectPacketsJson returns a description of each top-level packet in the message
+// as a JSON array. See InspectPackets.
+func (msg *PGPMessage) InspectPacketsJson() ([]byte, error) {
+	packets, err := msg.InspectPackets()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(packets)
+}
+
+func inspectPackets(data []byte) ([]*PacketInfo, error) {
+	var packets []*PacketInfo
+	for offset := 0; offset < len(data); {
+		info, packetLength, err := readPacketInfo(data[offset:])
+		if err != nil {
+			return nil, errors.Wrapf(err, "gopenpgp: unable to inspect packet at offset %d", offset)
+		}
+		info.Offset = offset
+		addPacketDetails(info, data[offset:offset+packetLength])
+		packets = append(packets, info)
+		offset += packetLength
+	}
+	return packets, nil
+}
+
+// readPacketInfo parses the packet headers at the start of data
+// and returns the packet info together with the total encoded length of the packet.
+func readPacketInfo(data []byte) (info *PacketInfo, packetLength int, err error) {
+	if len(data) == 0 || data[0]&0x80 == 0 {
+		return nil, 0, errors.New("invalid packet tag")
+	}
+	info = &PacketInfo{}
+	if data[0]&0x40 == 0 {
+		// Legacy packet format
+		info.Tag = int(data[0]&0x3f) >> 2
+		lengthType := data[0] & 3
+		if lengthType == 3 {
+			// Indeterminate length extends to the end of the message.
+			info.HeaderLength = 1
+			info.Length = len(data) - 1
+		} else {
+			lengthBytes := 1 << lengthType
+			if len(data) < 1+lengthBytes {
+				return nil, 0, errors.New("truncated packet header")
+			}
+			var length uint64
+			for _, b := range data[1 : 1+lengthBytes] {
+				length = length<<8 | uint64(b)
+			}
+			info.HeaderLength = 1 + lengthBytes
+			if length > uint64(len(data)-info.HeaderLength) {
+				return nil, 0, errors.New("truncated packet body")
+			}
+			info.Length = int(length)
+		}
+		packetLength = info.HeaderLength + info.Length
+	} else {
+		info.Tag = int(data[0] & 0x3f)
+		position := 1
+		for first := true; ; first = false {
+			bodyLength, lengthBytes, partial, err := readNewFormatLength(data[position:])
+			if err != nil {
+				return nil, 0, err
+			}
+			if first {
+				info.HeaderLength = 1 + lengthBytes
+			}
+			// Compare before adding, such that large lengths cannot overflow the position.
+			if bodyLength > len(data)-position-lengthBytes {
+				return nil, 0, errors.New("truncated packet body")
+			}
+			position += lengthBytes + bodyLength
+			info.Length += bodyLength
+			if !partial {
+				break
+			}
+			info.PartialLength = true
+		}
+		packetLength = position
+	}
+	if packetLength > len(data) {
+		return nil, 0, errors.New("truncated packet body")
+	}
+	info.Name = packetNames[info.Tag]
+	if info.Name == "" {
+		info.Name = "Unknown"
+	}
+	return info, packetLength, nil
+}
+
+const maxInt = int(^uint(0) >> 1)
+
+// readNewFormatLength parses an OpenPGP new format body length.
+func readNewFormatLength(data []byte) (bodyLength, lengthBytes int, partial bool, err error) {
+	if len(data) == 0 {
+		return 0, 0, false, errors.New("truncated packet header")
+	}
+	switch {
+	case data[0] < 192:
+		return int(data[0]), 1, false, nil
+	case data[0] < 224:
+		if len(data) < 2 {
+			return 0, 0, false, errors.New("truncated packet header")
+		}
+		return (int(data[0])-192)<<8 + int(data[1]) + 192, 2, false, nil
+	case data[0] < 255:
+		return 1 << (data[0] & 0x1f), 1, true, nil
+	default:
+		if len(data) < 5 {
+			return 0, 0, false, errors.New("truncated packet header")
+		}
+		length := binary.BigEndian.Uint32(data[1:5])
+		if uint64(length) > uint64(maxInt) {
+			// The length does not fit into an int on 32-bit platforms.
+			return 0, 0, false, errors.New("packet length too large")
+		}
+		return int(length), 5, false, nil
+	}
+}
+
+// addPacketDetails parses the packet and adds the packet specific fields to the info.
+// Packets that cannot be parsed are described by their header only.
+func addPacketDetails(info *PacketInfo, encodedPacket []byte) {
+	p, err := packet.Read(bytes.NewReader(encodedPacket))
+	if err != nil {
+		return
+	}
+	switch p := p.(type) {
+	case *packet.EncryptedKey:
+		info.Version = p.Version
+		info.KeyID = p.KeyId
+		info.KeyFingerprint = p.KeyFingerprint
+		info.PublicKeyAlgorithm = int(p.Algo)
+	case *packet.SymmetricKeyEncrypted:
+		info.Version = p.Version
+		info.Cipher = int(p.CipherFunc)
+		info.AEADMode = int(p.Mode)
+	case *packet.SymmetricallyEncrypted:
+		info.Version = p.Version
+		if p.Version == 2 {
+			info.Cipher = int(p.Cipher)
+			info.AEADMode = int(p.Mode)
+			info.AEADChunkSize = 1 << (p.ChunkSizeByte + 6)
+		}
+	case *packet.Signature:
+		info.Version = p.Version
+		info.PublicKeyAlgorithm = int(p.PubKeyAlgo)
+		info.Hash = hashID(p.Hash)
+		info.SignatureType = int(p.SigType)
+		if p.IssuerKeyId != nil {
+			info.KeyID = *p.IssuerKeyId
+		}
+	case *packet.OnePassSignature:
+		info.Version = p.Version
+		info.PublicKeyAlgorithm = int(p.PubKeyAlgo)
+		info.Hash = hashID(p.Hash)
+		info.SignatureType = int(p.SigType)
+		info.KeyID = p.KeyId
+	}
+}
+
+// hashID returns the OpenPGP id of the hash algorithm, or zero if it has none.
+func hashID(hash crypto.Hash) int {
+	id, ok := openpgp.HashToHashId(hash)
+	if !ok {
+		return 0
+	}
+	return int(id)
+}
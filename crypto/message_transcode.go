@@ -0,0 +1,132 @@
+package crypto
+
+import (
+	"bytes"
+	"io"
+
+	goarmor "github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/gopenpgp/v3/armor"
+	"github.com/ProtonMail/gopenpgp/v3/constants"
+	"github.com/pkg/errors"
+)
+
+// Transcode converts OpenPGP data, e.g., a message, signature, or key, from the input
+// encoding Bytes/Armor/Base64/Auto to the output encoding Bytes/Armor/Base64.
+// The packets are only checked to be well-formed and re-encoded as they are,
+// thus, no key material is needed and encrypted data is not decrypted.
+// Armored input keeps its armor type. For binary input, the armor type is derived
+// from the first packet, e.g., "PGP SIGNATURE" for a signature packet.
+// Not supported on go-mobile clients use TranscodeToBinary, TranscodeToArmor,
+// or TranscodeToArmorWithCustomHeaders instead.
+func Transcode(input []byte, inputEncoding, outputEncoding int8, options *armor.Options) ([]byte, error) {
+	data, armorType, err := decodeTranscodeInput(input, inputEncoding)
+	if err != nil {
+		return nil, err
+	}
+	packets, err := inspectPackets(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(packets) == 0 {
+		return nil, errors.New("gopenpgp: no packets to transcode")
+	}
+	switch outputEncoding {
+	case Armor:
+		if armorType == "" {
+			armorType = armorTypeForPacket(packets[0].Tag)
+		}
+		armorOptions := armor.Options{}
+		if options != nil {
+			armorOptions = *options
+		}
+		armorOptions.OmitChecksum = armorOptions.OmitChecksum || hasRFC9580Packets(data, packets)
+		return armor.ArmorWithOptions(data, armorType, &armorOptions)
+	case Base64:
+		return encodeBase64(data), nil
+	default:
+		return data, nil
+	}
+}
+
+// TranscodeToBinary converts armored or base64 encoded OpenPGP data to binary, see Transcode.
+func TranscodeToBinary(input []byte) ([]byte, error) {
+	return Transcode(input, Auto, Bytes, nil)
+}
+
+// TranscodeToArmor converts binary, base64 encoded, or armored OpenPGP data to armor
+// with the default armor headers, see Transcode.
+func TranscodeToArmor(input []byte) (string, error) {
+	armored, err := Transcode(input, Auto, Armor, nil)
+	if err != nil {
+		return "", err
+	}
+	return string(armored), nil
+}
+
+// TranscodeToArmorWithCustomHeaders converts binary, base64 encoded, or armored OpenPGP data
+// to armor with the given headers, see Transcode. Empty parameters are omitted from the headers.
+func TranscodeToArmorWithCustomHeaders(input []byte, comment, version string) (string, error) {
+	armored, err := Transcode(input, Auto, Armor, &armor.Options{
+		Headers: armor.HeadersWithVersionAndComment(version, comment),
+	})
+	if err != nil {
+		return "", err
+	}
+	return string(armored), nil
+}
+
+// decodeTranscodeInput returns the binary data of the input, and its armor type if it is armored.
+func decodeTranscodeInput(input []byte, encoding int8) (data []byte, armorType string, err error) {
+	reader, unarmor := unarmorInput(encoding, bytes.NewReader(input))
+	if unarmor {
+		block, err := goarmor.Decode(reader)
+		if err != nil {
+			return nil, "", errors.Wrap(err, "gopenpgp: unable to unarmor")
+		}
+		reader = block.Body
+		armorType = block.Type
+	}
+	data, err = io.ReadAll(reader)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "gopenpgp: unable to decode input")
+	}
+	return data, armorType, nil
+}
+
+// armorTypeForPacket returns the armor type of data that starts with a packet with the tag.
+func armorTypeForPacket(tag int) string {
+	switch tag {
+	case 2:
+		return constants.PGPSignatureHeader
+	case 5:
+		return constants.PrivateKeyHeader
+	case 6:
+		return constants.PublicKeyHeader
+	default:
+		return constants.PGPMessageHeader
+	}
+}
+
+// hasRFC9580Packets checks if the data contains v6 packets or SEIPDv2 packets,
+// for which RFC 9580 forbids an armor checksum.
+func hasRFC9580Packets(data []byte, packets []*PacketInfo) bool {
+	for _, info := range packets {
+		switch info.Tag {
+		case 5, 6:
+			// The key version is the first byte of the packet body.
+			versionOffset := info.Offset + info.HeaderLength
+			if info.Length > 0 && versionOffset < len(data) && data[versionOffset] == 6 {
+				return true
+			}
+		case 18:
+			if info.Version == 2 {
+				return true
+			}
+		default:
+			if info.Version == 6 {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,119 @@
+package crypto
+
+import (
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/ProtonMail/gopenpgp/v3/constants"
+	"github.com/pkg/errors"
+)
+
+// Notation is a notation data subpacket of a signature, i.e.,
+// a name-value pair that is covered by the signature.
+type Notation struct {
+	// Name is the notation name, e.g., "name@example.com".
+	Name string
+	// Value is the notation value.
+	Value []byte
+	// IsHumanReadable indicates that the value is UTF-8 text.
+	IsHumanReadable bool
+	// IsCritical indicates that verifiers must reject the signature
+	// if they do not know the notation.
+	IsCritical bool
+}
+
+// NewNotation creates a new human-readable notation with the given name and text value.
+// The name must be of the form "name@domain", where the domain is controlled by the creator.
+// isCritical controls whether the notation is flagged as critical, i.e.,
+// signatures are only valid for verifiers that know the notation.
+func NewNotation(name, value string, isCritical bool) *Notation {
+	return &Notation{
+		Name:            name,
+		Value:           []byte(value),
+		IsHumanReadable: true,
+		IsCritical:      isCritical,
+	}
+}
+
+// NewBinaryNotation creates a new notation with the given name and binary value.
+// The name must be of the form "name@domain", where the domain is controlled by the creator.
+// isCritical controls whether the notation is flagged as critical, i.e.,
+// signatures are only valid for verifiers that know the notation.
+func NewBinaryNotation(name string, value []byte, isCritical bool) *Notation {
+	return &Notation{
+		Name:       name,
+		Value:      value,
+		IsCritical: isCritical,
+	}
+}
+
+// GetValueString returns the value of the notation as a string.
+func (notation *Notation) GetValueString() string {
+	return string(notation.Value)
+}
+
+func (notation *Notation) validate() error {
+	if !strings.Contains(notation.Name, "@") {
+		return errors.Errorf("gopenpgp: notation name %q is not of the form name@domain", notation.Name)
+	}
+	if notation.Name == constants.SignatureContextName {
+		return errors.New("gopenpgp: the context notation must be set with a signing context")
+	}
+	if len(notation.Name) > 0xffff || len(notation.Value) > 0xffff {
+		return errors.Errorf("gopenpgp: notation %q is too long", notation.Name)
+	}
+	return nil
+}
+
+func (notation *Notation) getNotation() *packet.Notation {
+	return &packet.Notation{
+		Name:            notation.Name,
+		Value:           notation.Value,
+		IsHumanReadable: notation.IsHumanReadable,
+		IsCritical:      notation.IsCritical,
+	}
+}
+
+func validateNotations(notations []*Notation) error {
+	for _, notation := range notations {
+		if err := notation.validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// appendNotations adds the notations to the signatures created with the config.
+func appendNotations(config *packet.Config, notations []*Notation) {
+	for _, notation := range notations {
+		config.SignatureNotations = append(config.SignatureNotations, notation.getNotation())
+	}
+}
+
+// knownNotations returns the notation names a verifier accepts in critical notations.
+func knownNotations(verificationContext *VerificationContext, names []string) map[string]bool {
+	if verificationContext == nil && len(names) == 0 {
+		return nil
+	}
+	known := make(map[string]bool, len(names)+1)
+	if verificationContext != nil {
+		known[constants.SignatureContextName] = true
+	}
+	for _, name := range names {
+		known[name] = true
+	}
+	return known
+}
+
+func notationsFromSignature(sig *packet.Signature) []*Notation {
+	notations := make([]*Notation, 0, len(sig.Notations))
+	for _, notation := range sig.Notations {
+		notations = append(notations, &Notation{
+			Name:            notation.Name,
+			Value:           notation.Value,
+			IsHumanReadable: notation.IsHumanReadable,
+			IsCritical:      notation.IsCritical,
+		})
+	}
+	return notations
+}
@@ -0,0 +1,168 @@
+package crypto
+
+import (
+	"io"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Operations reported to an OperationObserver.
+const (
+	OperationEncrypt = "encrypt"
+	OperationDecrypt = "decrypt"
+	OperationSign    = "sign"
+	OperationVerify  = "verify"
+)
+
+// OperationInfo describes a completed operation of a handle.
+// Not supported on go-mobile clients.
+type OperationInfo struct {
+	// Operation is one of OperationEncrypt, OperationDecrypt, OperationSign, or OperationVerify.
+	Operation string
+	// Algorithm is the symmetric cipher for encryption and decryption, e.g., constants.AES256,
+	// and the hash algorithm for signing and verification, e.g., "SHA-256".
+	// It is empty if the algorithm is not known, e.g., since the operation failed early.
+	Algorithm string
+	// BytesProcessed is the number of plaintext bytes that were encrypted, decrypted, signed, or verified.
+	BytesProcessed int64
+	// Duration is the time from the start of the operation until it completed,
+	// i.e., for streaming operations it includes the time spent by the caller between reads or writes.
+	Duration time.Duration
+	// Err is the error the operation failed with, or nil.
+	// Signature errors are not operation errors, see VerifyResult.SignatureError.
+	Err error
+}
+
+// OperationObserver is notified about completed operations of the handles,
+// e.g., to export metrics or traces.
+// An operation completes once the writer of a streaming encryption or signing is closed,
+// or once the reader of a streaming decryption or verification returned io.EOF or an error.
+// Observe is called synchronously, and might be called concurrently by different handles.
+// Not supported on go-mobile clients.
+type OperationObserver interface {
+	Observe(info *OperationInfo)
+}
+
+// OperationObserverFunc is an adapter to use a function as OperationObserver.
+// Not supported on go-mobile clients.
+type OperationObserverFunc func(info *OperationInfo)
+
+// Observe calls f(info).
+func (f OperationObserverFunc) Observe(info *OperationInfo) {
+	f(info)
+}
+
+// observation measures an operation and reports it to the observer once it completed.
+type observation struct {
+	observer OperationObserver
+	info     OperationInfo
+	start    time.Time
+	// algorithm returns the algorithm of the operation once it completed, if it is not known at the start.
+	algorithm func() string
+	done      bool
+}
+
+func startObservation(observer OperationObserver, operation, algorithm string) *observation {
+	return &observation{
+		observer: observer,
+		info:     OperationInfo{Operation: operation, Algorithm: algorithm},
+		start:    time.Now(),
+	}
+}
+
+func (o *observation) count(n int) {
+	if n > 0 {
+		o.info.BytesProcessed += int64(n)
+	}
+}
+
+// finish reports the operation to the observer, if it has not been reported yet.
+func (o *observation) finish(err error) {
+	if o.done {
+		return
+	}
+	o.done = true
+	o.info.Duration = time.Since(o.start)
+	o.info.Err = err
+	if o.algorithm != nil {
+		o.info.Algorithm = o.algorithm()
+	}
+	info := o.info
+	o.observer.Observe(&info)
+}
+
+// observedWriteCloser reports the operation once the underlying write closer is closed or fails.
+type observedWriteCloser struct {
+	writer      WriteCloser
+	observation *observation
+}
+
+func (w *observedWriteCloser) Write(b []byte) (n int, err error) {
+	n, err = w.writer.Write(b)
+	w.observation.count(n)
+	if err != nil {
+		w.observation.finish(err)
+	}
+	return
+}
+
+func (w *observedWriteCloser) Close() error {
+	err := w.writer.Close()
+	w.observation.finish(err)
+	return err
+}
+
+// observedReader reports the operation once the underlying reader is read entirely or fails.
+type observedReader struct {
+	reader      Reader
+	observation *observation
+}
+
+func (r *observedReader) Read(b []byte) (n int, err error) {
+	n, err = r.reader.Read(b)
+	r.observation.count(n)
+	if errors.Is(err, io.EOF) {
+		r.observation.finish(nil)
+	} else if err != nil {
+		r.observation.finish(err)
+	}
+	return
+}
+
+// observeReader reports the operation of the reader to the observer once it is read entirely.
+// If creating the reader failed, the failure is reported instead.
+func observeReader(observation *observation, reader *VerifyDataReader, err error) (*VerifyDataReader, error) {
+	if err != nil {
+		observation.finish(err)
+		return nil, err
+	}
+	observation.algorithm = reader.algorithm
+	reader.internalReader = &observedReader{reader: reader.internalReader, observation: observation}
+	return reader, nil
+}
+
+// observeWriter reports the operation of the writer to the observer once it is closed.
+// If creating the writer failed, the failure is reported instead.
+func observeWriter(observation *observation, writer WriteCloser, err error) (WriteCloser, error) {
+	if err != nil {
+		observation.finish(err)
+		return nil, err
+	}
+	return &observedWriteCloser{writer: writer, observation: observation}, nil
+}
+
+// algorithm returns the cipher of a decrypted message, or the hash of the first signature
+// of a verified message.
+func (msg *VerifyDataReader) algorithm() string {
+	if msg.details == nil {
+		return ""
+	}
+	if msg.details.IsEncrypted {
+		return algosToSymKey[msg.details.DecryptedWithAlgorithm]
+	}
+	if len(msg.details.SignatureCandidates) > 0 {
+		return msg.details.SignatureCandidates[0].HashAlgorithm.String()
+	}
+	return ""
+}
@@ -0,0 +1,322 @@
+package crypto
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/ProtonMail/gopenpgp/v3/constants"
+	"github.com/ProtonMail/gopenpgp/v3/profile"
+	"github.com/stretchr/testify/assert"
+)
+
+type testObserver struct {
+	mutex      sync.Mutex
+	operations []*OperationInfo
+}
+
+func (o *testObserver) Observe(info *OperationInfo) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	o.operations = append(o.operations, info)
+}
+
+func (o *testObserver) reset() []*OperationInfo {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	operations := o.operations
+	o.operations = nil
+	return operations
+}
+
+func TestOperationObserver(t *testing.T) {
+	observer := &testObserver{}
+	pgp := testPGP.WithObserver(observer)
+	message := []byte(testMessage)
+
+	encHandle, _ := pgp.Encryption().Recipients(keyRingTestPublic).SigningKeys(keyRingTestPrivate).New()
+	pgpMessage, err := encHandle.Encrypt(message)
+	if err != nil {
+		t.Fatal("Cannot encrypt:", err)
+	}
+	operations := observer.reset()
+	if assert.Len(t, operations, 1) {
+		assert.Equal(t, OperationEncrypt, operations[0].Operation)
+		assert.Equal(t, constants.AES256, operations[0].Algorithm)
+		assert.Equal(t, int64(len(message)), operations[0].BytesProcessed)
+		assert.NoError(t, operations[0].Err)
+	}
+
+	decHandle, _ := pgp.Decryption().DecryptionKeys(keyRingTestPrivate).VerificationKeys(keyRingTestPublic).New()
+	if _, err := decHandle.Decrypt(pgpMessage.Bytes(), Bytes); err != nil {
+		t.Fatal("Cannot decrypt:", err)
+	}
+	operations = observer.reset()
+	if assert.Len(t, operations, 1) {
+		assert.Equal(t, OperationDecrypt, operations[0].Operation)
+		assert.Equal(t, constants.AES256, operations[0].Algorithm)
+		assert.Equal(t, int64(len(message)), operations[0].BytesProcessed)
+		assert.NoError(t, operations[0].Err)
+	}
+
+	_, err = decHandle.Decrypt(pgpMessage.Bytes()[:len(pgpMessage.Bytes())-10], Bytes)
+	assert.Error(t, err)
+	operations = observer.reset()
+	if assert.Len(t, operations, 1) {
+		assert.Error(t, operations[0].Err)
+	}
+
+	signHandle, _ := pgp.Sign().SigningKeys(keyRingTestPrivate).Detached().New()
+	signature, err := signHandle.Sign(message, Bytes)
+	if err != nil {
+		t.Fatal("Cannot sign:", err)
+	}
+	verifyHandle, _ := pgp.Verify().VerificationKeys(keyRingTestPublic).New()
+	verifyResult, err := verifyHandle.VerifyDetached(message, signature, Bytes)
+	if err != nil {
+		t.Fatal("Cannot verify:", err)
+	}
+	assert.NoError(t, verifyResult.SignatureError())
+	operations = observer.reset()
+	if assert.Len(t, operations, 2) {
+		assert.Equal(t, OperationSign, operations[0].Operation)
+		assert.Equal(t, "SHA-256", operations[0].Algorithm)
+		assert.Equal(t, int64(len(message)), operations[0].BytesProcessed)
+		assert.Equal(t, OperationVerify, operations[1].Operation)
+		assert.Equal(t, "SHA-256", operations[1].Algorithm)
+		assert.Equal(t, int64(len(message)), operations[1].BytesProcessed)
+	}
+
+	cleartext, err := signHandle.SignCleartext(message)
+	if err != nil {
+		t.Fatal("Cannot sign cleartext:", err)
+	}
+	if _, err := verifyHandle.VerifyCleartext(cleartext); err != nil {
+		t.Fatal("Cannot verify cleartext:", err)
+	}
+	operations = observer.reset()
+	if assert.Len(t, operations, 2) {
+		assert.Equal(t, OperationSign, operations[0].Operation)
+		assert.Equal(t, OperationVerify, operations[1].Operation)
+		assert.Equal(t, "SHA-256", operations[1].Algorithm)
+	}
+}
+
+func TestOperationObserverStreaming(t *testing.T) {
+	var observed []*OperationInfo
+	encHandle, _ := testPGP.Encryption().
+		Password(password).
+		Observer(OperationObserverFunc(func(info *OperationInfo) {
+			observed = append(observed, info)
+		})).
+		New()
+	var ciphertext bytes.Buffer
+	ptWriter, err := encHandle.EncryptingWriter(&ciphertext, Armor)
+	if err != nil {
+		t.Fatal("Cannot create encrypting writer:", err)
+	}
+	for i := 0; i < 10; i++ {
+		if _, err := ptWriter.Write([]byte(testMessage)); err != nil {
+			t.Fatal("Cannot encrypt:", err)
+		}
+	}
+	assert.Empty(t, observed)
+	if err := ptWriter.Close(); err != nil {
+		t.Fatal("Cannot close encrypting writer:", err)
+	}
+	if assert.Len(t, observed, 1) {
+		assert.Equal(t, int64(10*len(testMessage)), observed[0].BytesProcessed)
+	}
+
+	// Handles without observer are not instrumented.
+	decHandle, _ := testPGP.Decryption().Password(password).New()
+	if _, err := decHandle.Decrypt(ciphertext.Bytes(), Armor); err != nil {
+		t.Fatal("Cannot decrypt:", err)
+	}
+	assert.Len(t, observed, 1)
+}
+
+type testLogger struct {
+	mutex   sync.Mutex
+	entries []*LogEntry
+}
+
+func (l *testLogger) Log(entry *LogEntry) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.entries = append(l.entries, entry)
+}
+
+func (l *testLogger) reset() []*LogEntry {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	entries := l.entries
+	l.entries = nil
+	return entries
+}
+
+func logMessages(entries []*LogEntry) []string {
+	var messages []string
+	for _, entry := range entries {
+		messages = append(messages, entry.Message)
+	}
+	return messages
+}
+
+func TestLogger(t *testing.T) {
+	logger := &testLogger{}
+	pgp := testPGP.WithLogger(logger)
+	message := []byte(testMessage)
+	publicKey, _ := keyRingTestPublic.GetKey(0)
+	keyID := keyIDToHex(publicKey.GetKeyID())
+
+	encHandle, _ := pgp.Encryption().Recipients(keyRingTestPublic).SigningKeys(keyRingTestPrivate).New()
+	pgpMessage, err := encHandle.Encrypt(message)
+	if err != nil {
+		t.Fatal("Cannot encrypt:", err)
+	}
+	entries := logger.reset()
+	assert.Equal(t, []string{"selected signing key", "selected encryption key", "negotiated algorithms"}, logMessages(entries))
+	if assert.Len(t, entries, 3) {
+		assert.Equal(t, LogLevelDebug, entries[1].Level)
+		assert.Equal(t, OperationEncrypt, entries[1].Operation)
+		assert.Equal(t, publicKey.GetFingerprint(), entries[1].Fields["fingerprint"])
+		assert.Equal(t, LogLevelInfo, entries[2].Level)
+		// The entry reports the data packet that was written.
+		packets, err := pgpMessage.InspectPackets()
+		if err != nil {
+			t.Fatal("Cannot inspect packets:", err)
+		}
+		dataPacket := packets[len(packets)-1]
+		assert.Equal(t, strconv.Itoa(dataPacket.Version), entries[2].Fields["seipdVersion"])
+	}
+
+	decHandle, _ := pgp.Decryption().DecryptionKeys(keyRingTestPrivate).VerificationKeys(keyRingTestPublic).New()
+	if _, err := decHandle.Decrypt(pgpMessage.Bytes(), Bytes); err != nil {
+		t.Fatal("Cannot decrypt:", err)
+	}
+	entries = logger.reset()
+	assert.Equal(t, []string{"decrypted message", "verified signature candidate"}, logMessages(entries))
+	if assert.Len(t, entries, 2) {
+		assert.Equal(t, OperationDecrypt, entries[0].Operation)
+		assert.Equal(t, publicKey.GetFingerprint(), entries[0].Fields["fingerprint"])
+		assert.Equal(t, OperationDecrypt, entries[1].Operation)
+		assert.Equal(t, keyID, entries[1].Fields["issuerKeyId"])
+		assert.Equal(t, "ok", entries[1].Fields["status"])
+	}
+
+	otherKey, _ := testPGP.KeyGeneration().AddUserId("other", "other@proton.me").New().GenerateKey()
+	otherKeyRing, _ := NewKeyRing(otherKey)
+	decHandle, _ = pgp.Decryption().DecryptionKeys(otherKeyRing).New()
+	_, err = decHandle.Decrypt(pgpMessage.Bytes(), Bytes)
+	assert.Error(t, err)
+	entries = logger.reset()
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, LogLevelWarning, entries[0].Level)
+		assert.Equal(t, "decryption failed", entries[0].Message)
+		assert.Contains(t, entries[0].Fields["decryptionKeyIds"], keyIDToHex(otherKey.GetKeyID()))
+	}
+
+	signHandle, _ := pgp.Sign().SigningKeys(keyRingTestPrivate).Detached().New()
+	signature, err := signHandle.Sign(message, Bytes)
+	if err != nil {
+		t.Fatal("Cannot sign:", err)
+	}
+	verifyHandle, _ := pgp.Verify().VerificationKeys(otherKeyRing).New()
+	if _, err := verifyHandle.VerifyDetached(message, signature, Bytes); err != nil {
+		t.Fatal("Cannot verify:", err)
+	}
+	entries = logger.reset()
+	assert.Equal(t, []string{"selected signing key", "verified signature candidate"}, logMessages(entries))
+	if assert.Len(t, entries, 2) {
+		assert.Equal(t, OperationSign, entries[0].Operation)
+		assert.Equal(t, OperationVerify, entries[1].Operation)
+		assert.Equal(t, LogLevelWarning, entries[1].Level)
+		assert.Equal(t, "SHA-256", entries[1].Fields["hash"])
+		assert.NotEmpty(t, entries[1].Fields["error"])
+	}
+
+	// Passwords are never logged.
+	encHandle, _ = testPGP.Encryption().Password(password).Logger(logger).New()
+	if _, err := encHandle.Encrypt(message); err != nil {
+		t.Fatal("Cannot encrypt:", err)
+	}
+	entries = logger.reset()
+	if assert.Len(t, entries, 1) {
+		for _, value := range entries[0].Fields {
+			assert.NotContains(t, value, string(password))
+		}
+	}
+}
+
+func TestLoggerEncryptionAlgorithms(t *testing.T) {
+	logger := &testLogger{}
+	message := []byte(testMessage)
+
+	// A recipient without encryption key does not stop the logging of the other recipients.
+	expiredKey, err := NewKeyFromArmored(readTestFile("key_expiredKey", false))
+	if err != nil {
+		t.Fatal("Cannot unarmor key:", err)
+	}
+	recipients, _ := NewKeyRing(expiredKey)
+	publicKey, _ := keyRingTestPublic.GetKey(0)
+	if err := recipients.AddKey(publicKey); err != nil {
+		t.Fatal("Cannot add key:", err)
+	}
+	encHandle, _ := testPGP.Encryption().Recipients(recipients).Logger(logger).New()
+	_, err = encHandle.Encrypt(message)
+	assert.Error(t, err)
+	assert.Equal(t, []string{"no valid encryption key", "selected encryption key", "encryption failed"}, logMessages(logger.reset()))
+
+	// The algorithms are the ones of the written data packet.
+	pgpV6 := PGPWithProfile(profile.RFC9580())
+	v6Key, err := pgpV6.KeyGeneration().AddUserId("v6", "v6@proton.me").New().GenerateKey()
+	if err != nil {
+		t.Fatal("Cannot generate key:", err)
+	}
+	v6KeyRing, _ := NewKeyRing(v6Key)
+	encHandle, _ = pgpV6.Encryption().Recipients(v6KeyRing).Logger(logger).New()
+	pgpMessage, err := encHandle.Encrypt(message)
+	if err != nil {
+		t.Fatal("Cannot encrypt:", err)
+	}
+	packets, err := pgpMessage.InspectPackets()
+	if err != nil {
+		t.Fatal("Cannot inspect packets:", err)
+	}
+	dataPacket := packets[len(packets)-1]
+	entries := logger.reset()
+	assert.Equal(t, []string{"selected encryption key", "negotiated algorithms"}, logMessages(entries))
+	if assert.Len(t, entries, 2) {
+		assert.Equal(t, "2", entries[1].Fields["seipdVersion"])
+		assert.Equal(t, algosToSymKey[packet.CipherFunction(dataPacket.Cipher)], entries[1].Fields["cipher"])
+		assert.Equal(t, aeadModeName(packet.AEADMode(dataPacket.AEADMode)), entries[1].Fields["aeadMode"])
+		assert.Equal(t, strconv.Itoa(dataPacket.AEADChunkSize), entries[1].Fields["aeadChunkSize"])
+	}
+
+	// Hidden recipients are counted, but their keys are never logged.
+	encHandle, _ = pgpV6.Encryption().Recipients(v6KeyRing).HiddenRecipients(keyRingTestPublic).Logger(logger).New()
+	if _, err := encHandle.Encrypt(message); err != nil {
+		t.Fatal("Cannot encrypt:", err)
+	}
+	entries = logger.reset()
+	assert.Equal(t, []string{"selected encryption key", "negotiated algorithms"}, logMessages(entries))
+	if assert.Len(t, entries, 2) {
+		assert.Equal(t, "1", entries[1].Fields["hiddenRecipients"])
+	}
+	hiddenKeyIDs := []string{publicKey.GetFingerprint(), publicKey.GetHexKeyID()}
+	for _, subkey := range publicKey.entity.Subkeys {
+		hiddenKeyIDs = append(hiddenKeyIDs, keyIDToHex(subkey.PublicKey.KeyId), hex.EncodeToString(subkey.PublicKey.Fingerprint))
+	}
+	for _, entry := range entries {
+		for _, value := range entry.Fields {
+			for _, hiddenKeyID := range hiddenKeyIDs {
+				assert.False(t, strings.Contains(value, hiddenKeyID), "hidden recipient %s is logged", hiddenKeyID)
+			}
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package crypto
+
+import (
+	"crypto"
+	"encoding"
+	"hash"
+	"io"
+	"sync"
+
+	openpgp "github.com/ProtonMail/go-crypto/openpgp/v2"
+	"github.com/pkg/errors"
+)
+
+// signatureHashGroup contains the signature candidates that hash the data identically,
+// i.e., with the same hash algorithm, signature type, and salt.
+type signatureHashGroup struct {
+	hash        hash.Hash
+	wrappedHash hash.Hash
+	candidates  []*openpgp.SignatureCandidate
+}
+
+type signatureHashKey struct {
+	hashAlgorithm crypto.Hash
+	sigType       uint8
+	salt          string
+}
+
+// parallelHashReader reads the data of a detached signature and hashes it
+// once per group of signature candidates, where each group is hashed in its own goroutine.
+// It must be passed as the signed data to openpgp.VerifyDetachedSignatureReader
+// and set up with the returned signature candidates before the data is read.
+type parallelHashReader struct {
+	reader io.Reader
+	groups []*signatureHashGroup
+}
+
+func newParallelHashReader(reader io.Reader) *parallelHashReader {
+	return &parallelHashReader{reader: reader}
+}
+
+// setCandidates takes over hashing the data for the candidates.
+// If all candidates hash the data with a single hash, the reader does not hash the data.
+func (pr *parallelHashReader) setCandidates(candidates []*openpgp.SignatureCandidate) {
+	var groups []*signatureHashGroup
+	groupsByKey := make(map[signatureHashKey]*signatureHashGroup)
+	for _, candidate := range candidates {
+		if candidate.SignatureError != nil || candidate.SignedByEntity == nil {
+			// These candidates are not hashed.
+			continue
+		}
+		key := signatureHashKey{
+			hashAlgorithm: candidate.HashAlgorithm,
+			sigType:       uint8(candidate.SigType),
+			salt:          string(candidate.Salt),
+		}
+		group, ok := groupsByKey[key]
+		if ok {
+			group.candidates = append(group.candidates, candidate)
+			continue
+		}
+		group = &signatureHashGroup{
+			hash:        candidate.Hash,
+			wrappedHash: candidate.WrappedHash,
+			candidates:  []*openpgp.SignatureCandidate{candidate},
+		}
+		groups = append(groups, group)
+		if _, isCopyable := candidate.Hash.(encoding.BinaryMarshaler); isCopyable {
+			groupsByKey[key] = group
+		}
+	}
+	if len(groups) < 2 && (len(groups) == 0 || len(groups[0].candidates) < 2) {
+		return
+	}
+	for _, group := range groups {
+		for _, candidate := range group.candidates {
+			candidate.WrappedHash = discardHash{candidate.WrappedHash}
+		}
+	}
+	pr.groups = groups
+}
+
+// Read implements the io.Reader interface.
+func (pr *parallelHashReader) Read(b []byte) (n int, err error) {
+	n, err = pr.reader.Read(b)
+	if len(pr.groups) > 0 && n > 0 {
+		pr.hash(b[:n])
+	}
+	if errors.Is(err, io.EOF) {
+		pr.finish()
+	}
+	return
+}
+
+func (pr *parallelHashReader) hash(data []byte) {
+	var wg sync.WaitGroup
+	for _, group := range pr.groups[1:] {
+		wg.Add(1)
+		go func(group *signatureHashGroup) {
+			defer wg.Done()
+			_, _ = group.wrappedHash.Write(data)
+		}(group)
+	}
+	_, _ = pr.groups[0].wrappedHash.Write(data)
+	wg.Wait()
+}
+
+// finish hands each candidate of a group its own copy of the group hash,
+// since verifying a signature modifies the hash.
+func (pr *parallelHashReader) finish() {
+	for _, group := range pr.groups {
+		for _, candidate := range group.candidates[1:] {
+			copied, err := copyHash(group.hash, candidate.HashAlgorithm)
+			if err != nil {
+				candidate.SignatureError = err
+				continue
+			}
+			candidate.Hash = copied
+		}
+	}
+	pr.groups = nil
+}
+
+// discardHash ignores all data written to it.
+type discardHash struct {
+	hash.Hash
+}
+
+func (discardHash) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+// copyHash returns a copy of the hash state.
+func copyHash(h hash.Hash, hashAlgorithm crypto.Hash) (hash.Hash, error) {
+	marshaler, ok := h.(encoding.BinaryMarshaler)
+	if !ok {
+		return nil, errors.Errorf("gopenpgp: hash %s cannot be copied", hashAlgorithm)
+	}
+	state, err := marshaler.MarshalBinary()
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: copying hash failed")
+	}
+	copied := hashAlgorithm.New()
+	unmarshaler, ok := copied.(encoding.BinaryUnmarshaler)
+	if !ok {
+		return nil, errors.Errorf("gopenpgp: hash %s cannot be copied", hashAlgorithm)
+	}
+	if err := unmarshaler.UnmarshalBinary(state); err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: copying hash failed")
+	}
+	return copied, nil
+}
@@ -0,0 +1,82 @@
+package crypto
+
+import (
+	"github.com/ProtonMail/gopenpgp/v3/profile"
+	"github.com/pkg/errors"
+)
+
+// passwordPGP returns the handle for the password helpers, which encrypts with SEIPDv2
+// and derives the key encryption key with Argon2 using the parameters recommended by RFC9580,
+// i.e., 64 MiB of memory, three passes, and four lanes.
+func passwordPGP() *PGPHandle {
+	return PGPWithProfile(profile.RFC9580())
+}
+
+// EncryptWithPassword encrypts the plaintext with a key derived from the password,
+// and returns the binary pgp message.
+// The message is protected with SEIPDv2, and the key is derived with the memory-hard
+// Argon2 function, which slows down guessing of human chosen passwords.
+// To customize the encryption, use an encryption handle with Password instead.
+func EncryptWithPassword(password, plaintext []byte) ([]byte, error) {
+	encHandle, err := newPasswordEncryptionHandle(password)
+	if err != nil {
+		return nil, err
+	}
+	pgpMessage, err := encHandle.Encrypt(plaintext)
+	if err != nil {
+		return nil, err
+	}
+	return pgpMessage.Bytes(), nil
+}
+
+// EncryptStreamWithPassword is like EncryptWithPassword, but returns a WriteCloser
+// that writes the binary pgp message of the plaintext written to it to the output.
+// The returned WriteCloser must be closed after the plaintext has been written.
+func EncryptStreamWithPassword(password []byte, output Writer) (WriteCloser, error) {
+	encHandle, err := newPasswordEncryptionHandle(password)
+	if err != nil {
+		return nil, err
+	}
+	return encHandle.EncryptingWriter(output, Bytes)
+}
+
+// DecryptWithPassword decrypts a pgp message that is encrypted with the password,
+// e.g., by EncryptWithPassword, and returns the plaintext.
+// The message can be binary or armored.
+// Messages encrypted with other settings, e.g., SEIPDv1 or iterated and salted S2K, are decrypted as well.
+func DecryptWithPassword(password, ciphertext []byte) ([]byte, error) {
+	decHandle, err := newPasswordDecryptionHandle(password)
+	if err != nil {
+		return nil, err
+	}
+	decrypted, err := decHandle.Decrypt(ciphertext, Auto)
+	if err != nil {
+		return nil, err
+	}
+	return decrypted.Bytes(), nil
+}
+
+// DecryptStreamWithPassword is like DecryptWithPassword, but returns a reader
+// for the plaintext of the pgp message read from ciphertext.
+// The integrity of the plaintext is only guaranteed once the reader is fully read without error.
+func DecryptStreamWithPassword(password []byte, ciphertext Reader) (*VerifyDataReader, error) {
+	decHandle, err := newPasswordDecryptionHandle(password)
+	if err != nil {
+		return nil, err
+	}
+	return decHandle.DecryptingReader(ciphertext, Auto)
+}
+
+func newPasswordEncryptionHandle(password []byte) (PGPEncryption, error) {
+	if len(password) == 0 {
+		return nil, errors.New("gopenpgp: no password provided")
+	}
+	return passwordPGP().Encryption().Password(password).New()
+}
+
+func newPasswordDecryptionHandle(password []byte) (PGPDecryption, error) {
+	if len(password) == 0 {
+		return nil, errors.New("gopenpgp: no password provided")
+	}
+	return passwordPGP().Decryption().Password(password).New()
+}
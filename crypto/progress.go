@@ -0,0 +1,94 @@
+package crypto
+
+// ProgressListener is notified about the progress of streaming operations,
+// e.g., to display a progress bar when encrypting or decrypting large files.
+// OnProgress is called synchronously on every read or write and should return quickly.
+type ProgressListener interface {
+	// OnProgress is called with the total number of bytes consumed and produced so far.
+	// When encrypting or signing, bytesIn counts the plaintext bytes written,
+	// and bytesOut counts the bytes of the pgp message written to the output.
+	// When decrypting or verifying, bytesIn counts the bytes read from the input messages,
+	// and bytesOut counts the plaintext bytes read. Trailing input that is not required,
+	// e.g., an armor footer, might not be read.
+	OnProgress(bytesIn, bytesOut int64)
+}
+
+// ProgressFunc is an adapter to use a function as ProgressListener.
+// Not supported on go-mobile clients.
+type ProgressFunc func(bytesIn, bytesOut int64)
+
+// OnProgress calls f(bytesIn, bytesOut).
+func (f ProgressFunc) OnProgress(bytesIn, bytesOut int64) {
+	f(bytesIn, bytesOut)
+}
+
+// progressTracker counts the bytes consumed and produced by an operation
+// and reports them to the listener.
+type progressTracker struct {
+	listener ProgressListener
+	bytesIn  int64
+	bytesOut int64
+}
+
+func (p *progressTracker) addIn(n int) {
+	if n > 0 {
+		p.bytesIn += int64(n)
+		p.listener.OnProgress(p.bytesIn, p.bytesOut)
+	}
+}
+
+func (p *progressTracker) addOut(n int) {
+	if n > 0 {
+		p.bytesOut += int64(n)
+		p.listener.OnProgress(p.bytesIn, p.bytesOut)
+	}
+}
+
+// progressReader reports the bytes read from the underlying reader.
+type progressReader struct {
+	reader Reader
+	count  func(n int)
+}
+
+func (r *progressReader) Read(b []byte) (n int, err error) {
+	n, err = r.reader.Read(b)
+	r.count(n)
+	return
+}
+
+// progressWriter reports the bytes written to the underlying writer.
+type progressWriter struct {
+	writer Writer
+	count  func(n int)
+}
+
+func (w *progressWriter) Write(b []byte) (n int, err error) {
+	n, err = w.writer.Write(b)
+	w.count(n)
+	return
+}
+
+// progressWriteCloser reports the bytes written to the underlying write closer.
+type progressWriteCloser struct {
+	progressWriter
+	closer WriteCloser
+}
+
+func (w *progressWriteCloser) Close() error {
+	return w.closer.Close()
+}
+
+func newProgressWriteCloser(writeCloser WriteCloser, count func(n int)) *progressWriteCloser {
+	return &progressWriteCloser{
+		progressWriter: progressWriter{writer: writeCloser, count: count},
+		closer:         writeCloser,
+	}
+}
+
+// wrapOutput wraps the output writer such that all bytes written to it are reported as produced.
+// If the output is a PGPSplitWriter, the returned writer is a PGPSplitWriter as well.
+func (p *progressTracker) wrapOutput(output Writer) Writer {
+	return wrapOutputWriter(output, func(writer Writer) Writer {
+		return &progressWriter{writer: writer, count: p.addOut}
+	})
+}
@@ -0,0 +1,85 @@
+package crypto
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/ProtonMail/gopenpgp/v3/armor"
+	"github.com/ProtonMail/gopenpgp/v3/constants"
+	"github.com/pkg/errors"
+)
+
+// revocationCertificateComment is the armor comment of revocation certificates.
+const revocationCertificateComment = "This is a revocation certificate"
+
+// GenerateRevocationCertificate creates a revocation certificate for the key, i.e.,
+// a standalone revocation signature of the primary key created at unixTime.
+// The certificate is armored as a public key block, like the certificates of GnuPG.
+// It is meant to be generated along with the key and stored separately,
+// such that the key can still be revoked if the private key or its passphrase is lost,
+// see (*Key).ApplyRevocationCertificate.
+// The reason is a reason code, see constants.Revocation..., and reasonText
+// describes the reason for humans.
+// The primary key must be unlocked.
+func (key *Key) GenerateRevocationCertificate(reason int8, reasonText string, unixTime int64) (string, error) {
+	revokedKey, err := key.copyForModification()
+	if err != nil {
+		return "", err
+	}
+	config := revokedKey.managementConfig(unixTime)
+	if err := revokedKey.entity.Revoke(packet.NewReasonForRevocation(byte(reason)), reasonText, config); err != nil {
+		return "", errors.Wrap(err, "gopenpgp: error in generating revocation certificate")
+	}
+	revocation := revokedKey.entity.Revocations[len(revokedKey.entity.Revocations)-1].Packet
+	var serialized bytes.Buffer
+	if err := revocation.Serialize(&serialized); err != nil {
+		return "", errors.Wrap(err, "gopenpgp: error in serializing revocation certificate")
+	}
+	return key.armorWithOptions(serialized.Bytes(), constants.PublicKeyHeader, &armor.Options{
+		Headers: map[string]string{"Comment": revocationCertificateComment},
+	})
+}
+
+// ApplyRevocationCertificate returns a copy of the key that is revoked by the
+// given revocation certificate, see (*Key).GenerateRevocationCertificate.
+// The certificate is either armored or binary, and must contain a key revocation
+// signature of the primary key. The key may be public or locked.
+func (key *Key) ApplyRevocationCertificate(certificate []byte) (*Key, error) {
+	if _, armored := armor.IsPGPArmored(bytes.NewReader(certificate)); armored {
+		var err error
+		if certificate, err = armor.UnarmorBytes(certificate); err != nil {
+			return nil, errors.Wrap(err, "gopenpgp: error in unarmoring revocation certificate")
+		}
+	}
+	var revocations []*packet.Signature
+	packets := packet.NewReader(bytes.NewReader(certificate))
+	for {
+		p, err := packets.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "gopenpgp: error in parsing revocation certificate")
+		}
+		sig, ok := p.(*packet.Signature)
+		if !ok || sig.SigType != packet.SigTypeKeyRevocation {
+			return nil, errors.New("gopenpgp: revocation certificate contains no key revocation")
+		}
+		if err := key.entity.PrimaryKey.VerifyRevocationSignature(sig); err != nil {
+			return nil, errors.Wrap(err, "gopenpgp: revocation certificate is not valid for the key")
+		}
+		revocations = append(revocations, sig)
+	}
+	if len(revocations) == 0 {
+		return nil, errors.New("gopenpgp: revocation certificate contains no key revocation")
+	}
+	revokedKey, err := key.Copy()
+	if err != nil {
+		return nil, err
+	}
+	for _, revocation := range revocations {
+		revokedKey.entity.Revocations = append(revokedKey.entity.Revocations, packet.NewVerifiableSig(revocation))
+	}
+	return revokedKey, nil
+}
@@ -0,0 +1,80 @@
+package crypto
+
+import (
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/ProtonMail/go-crypto/openpgp/s2k"
+	"github.com/ProtonMail/gopenpgp/v3/constants"
+	"github.com/pkg/errors"
+)
+
+// S2KParams defines how a key is derived from a passphrase (string-to-key),
+// when locking keys or encrypting messages with a password,
+// instead of the S2K settings of the profile.
+// Memory-hard Argon2 suits high-value keys, while iterated and salted S2K
+// with a low iteration count suits constrained devices.
+type S2KParams struct {
+	// Mode is the S2K function,
+	// either constants.S2KIteratedSalted or constants.S2KArgon2.
+	Mode int8
+	// IterationCount is the number of bytes hashed with iterated and salted S2K,
+	// between constants.S2KMinIterationCount and constants.S2KMaxIterationCount.
+	// Counts that cannot be encoded are rounded up. If zero, the go-crypto default is used.
+	IterationCount int
+	// Argon2 defines the Argon2 parameters, if nil the defaults are used.
+	Argon2 *Argon2Params
+}
+
+// NewIteratedSaltedS2KParams returns S2K parameters for iterated and salted S2K,
+// where iterationCount is the number of hashed bytes.
+func NewIteratedSaltedS2KParams(iterationCount int) *S2KParams {
+	return &S2KParams{
+		Mode:           constants.S2KIteratedSalted,
+		IterationCount: iterationCount,
+	}
+}
+
+// NewArgon2S2KParams returns S2K parameters for Argon2,
+// where memory is given in kibibytes. Zero values are replaced by the defaults of go-crypto.
+func NewArgon2S2KParams(memory uint32, iterations, parallelism uint8) *S2KParams {
+	return &S2KParams{
+		Mode: constants.S2KArgon2,
+		Argon2: &Argon2Params{
+			Memory:      memory,
+			Iterations:  iterations,
+			Parallelism: parallelism,
+		},
+	}
+}
+
+func (params *S2KParams) validate() error {
+	switch params.Mode {
+	case constants.S2KIteratedSalted:
+		if params.IterationCount != 0 && (params.IterationCount < constants.S2KMinIterationCount ||
+			params.IterationCount > constants.S2KMaxIterationCount) {
+			return errors.New("gopenpgp: invalid s2k iteration count")
+		}
+	case constants.S2KArgon2:
+		if params.Argon2 != nil && params.Argon2.Memory != 0 &&
+			params.Argon2.Parallelism != 0 && params.Argon2.Memory < 8*uint32(params.Argon2.Parallelism) {
+			return errors.New("gopenpgp: argon2 memory must be at least 8 KiB per lane")
+		}
+	default:
+		return errors.New("gopenpgp: unsupported s2k mode")
+	}
+	return nil
+}
+
+// applyTo replaces the S2K settings of the config.
+func (params *S2KParams) applyTo(config *packet.Config) {
+	s2kConfig := &s2k.Config{}
+	if config.S2KConfig != nil {
+		s2kConfig.Hash = config.S2KConfig.Hash
+	}
+	s2kConfig.S2KMode = s2k.Mode(params.Mode)
+	if params.Mode == constants.S2KArgon2 {
+		s2kConfig.Argon2Config = params.Argon2.argon2Config()
+	} else {
+		s2kConfig.S2KCount = params.IterationCount
+	}
+	config.S2KConfig = s2kConfig
+}
@@ -1,15 +1,21 @@
 package crypto
 
 import (
+	"bytes"
 	"encoding/base64"
 	"fmt"
 	"io"
+	"time"
 
+	"github.com/ProtonMail/gopenpgp/v3/armor"
 	"github.com/ProtonMail/gopenpgp/v3/constants"
+	"github.com/ProtonMail/gopenpgp/v3/profile"
 	"github.com/pkg/errors"
 
+	goarmor "github.com/ProtonMail/go-crypto/openpgp/armor"
 	pgpErrors "github.com/ProtonMail/go-crypto/openpgp/errors"
 	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/ProtonMail/go-crypto/openpgp/s2k"
 )
 
 // SessionKey stores a decrypted session key.
@@ -21,6 +27,8 @@ type SessionKey struct {
 	Algo string
 	// v6 is a flag to indicate that the session key was parsed from a v6 PKESK or SKESK packet
 	v6 bool
+	// locked indicates that Key is stored in locked memory, which is released on Clear.
+	locked bool
 }
 
 var symKeyAlgos = map[string]packet.CipherFunction{
@@ -89,11 +97,150 @@ func (sk *SessionKey) GetBase64Key() string {
 	return base64.StdEncoding.EncodeToString(sk.Key)
 }
 
+// EncryptKeyPackets encrypts the session key with the recipients or the password
+// of the provided encryption handle and returns the standalone key packets (PKESK/SKESK).
+// The encoding argument defines the output encoding, i.e., Bytes, Armor, or Base64.
+// Armored key packets are encoded as a PGP MESSAGE block.
+func (sk *SessionKey) EncryptKeyPackets(handle PGPEncryption, encoding int8) ([]byte, error) {
+	if handle == nil {
+		return nil, errors.New("gopenpgp: no encryption handle provided")
+	}
+	keyPackets, err := handle.EncryptSessionKey(sk)
+	if err != nil {
+		return nil, err
+	}
+	if encoding == Base64 {
+		return encodeBase64(keyPackets), nil
+	}
+	if !armorOutput(encoding) {
+		return keyPackets, nil
+	}
+	checksum := constants.ArmorChecksumEnabled
+	if eh, ok := handle.(*encryptionHandle); ok {
+		checksum = eh.armorChecksumRequired()
+	}
+	return armor.ArmorWithTypeBytesChecksum(keyPackets, constants.PGPMessageHeader, checksum)
+}
+
+// NewSessionKeyFromKeyPackets decrypts standalone key packets (PKESK/SKESK)
+// with the decryption keys or passwords of the provided decryption handle.
+// The encoding indicates if the input key packets should be unarmored or not,
+// i.e., Bytes/Armor/Base64/Auto where Auto tries to detect it automatically.
+func NewSessionKeyFromKeyPackets(keyPackets []byte, handle PGPDecryption, encoding int8) (*SessionKey, error) {
+	if handle == nil {
+		return nil, errors.New("gopenpgp: no decryption handle provided")
+	}
+	reader, armored := unarmorInput(encoding, bytes.NewReader(keyPackets))
+	if armored {
+		block, err := goarmor.Decode(reader)
+		if err != nil {
+			return nil, errors.Wrap(err, "gopenpgp: unarmor failed for key packets")
+		}
+		reader = block.Body
+	}
+	binaryKeyPackets, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: reading key packets failed")
+	}
+	return handle.DecryptSessionKey(binaryKeyPackets)
+}
+
+// EncryptToKeyRing encrypts the session key to the encryption keys of all recipients in the key ring
+// and returns the serialized binary key packets.
+// Together with data packets encrypted once with the session key, this allows to wrap the
+// session key separately for each recipient.
+// Uses the default profile, create an encryption handle with recipients for further options.
+func (sk *SessionKey) EncryptToKeyRing(recipients *KeyRing) ([]byte, error) {
+	if recipients == nil {
+		return nil, errors.New("gopenpgp: no recipients provided")
+	}
+	handle := defaultEncryptionHandle(profile.Default(), time.Now)
+	handle.Recipients = recipients
+	return handle.EncryptSessionKey(sk)
+}
+
+// Argon2Params defines the Argon2 parameters used to derive the key encryption key
+// from a password when wrapping a session key, see https://www.rfc-editor.org/rfc/rfc9106#section-4.
+// Zero values are replaced by the defaults of go-crypto.
+type Argon2Params struct {
+	// Memory is the memory cost in kibibytes, e.g., 64*1024 for ~64 MB.
+	Memory uint32
+	// Iterations is the number of passes over the memory.
+	Iterations uint8
+	// Parallelism is the number of lanes.
+	Parallelism uint8
+}
+
+// EncryptWithPasswordArgon2 encrypts the session key with the password
+// and returns the resulting binary v6 symmetric-key encrypted session key packet (SKESK).
+// The key encryption key is derived from the password with Argon2 using the given parameters,
+// if params is nil the default parameters are used.
+// Since it is a v6 SKESK, the key packet can only be combined with SEIPDv2 (AEAD) data packets.
+func (sk *SessionKey) EncryptWithPasswordArgon2(password []byte, params *Argon2Params) ([]byte, error) {
+	config := profile.RFC9580().EncryptionConfig()
+	config.Time = time.Now
+	config.S2KConfig = &s2k.Config{
+		S2KMode:      s2k.Argon2S2K,
+		Argon2Config: params.argon2Config(),
+	}
+	return encryptSessionKeyWithPassword(sk, password, config)
+}
+
+// NewSessionKeyFromPassword decrypts the session key from the binary symmetric-key encrypted
+// session key packets with the password.
+func NewSessionKeyFromPassword(keyPackets []byte, password []byte) (*SessionKey, error) {
+	if len(password) == 0 {
+		return nil, errors.New("gopenpgp: password can't be empty")
+	}
+	return decryptSessionKeyWithPassword(keyPackets, password)
+}
+
+func (params *Argon2Params) argon2Config() *s2k.Argon2Config {
+	if params == nil {
+		return &s2k.Argon2Config{}
+	}
+	return &s2k.Argon2Config{
+		NumberOfPasses:      params.Iterations,
+		DegreeOfParallelism: params.Parallelism,
+		Memory:              params.Memory,
+	}
+}
+
+// EncryptStream returns a WriteCloser that encrypts the plaintext written to it with the session key
+// and writes the resulting data packets to dataPacketWriter without buffering the plaintext.
+// The plaintextMetadata is stored in the literal data packet and can be nil.
+// If signKeyRing is not nil, the plaintext is signed with the keys in the key ring.
+// Uses the default profile, create an encryption handle with a session key for further options.
+// The returned WriteCloser must be closed after the plaintext has been written.
+func (sk *SessionKey) EncryptStream(dataPacketWriter Writer, plaintextMetadata *LiteralMetadata, signKeyRing *KeyRing) (WriteCloser, error) {
+	handle := defaultEncryptionHandle(profile.Default(), time.Now)
+	handle.SessionKey = sk
+	handle.SignKeyRing = signKeyRing
+	handle.IsUTF8 = plaintextMetadata.IsUtf8()
+	return handle.encryptingWriters(nil, dataPacketWriter, nil, plaintextMetadata, Bytes)
+}
+
+// DecryptStream returns a VerifyDataReader that decrypts the data packets read from dataPacketReader
+// with the session key on the fly.
+// If verifyKeyRing is not nil, the embedded signatures are verified against the keys in the key ring
+// at the unix time verifyTime once all data has been read, see VerifyDataReader.VerifySignature.
+// Uses the default profile, create a decryption handle with a session key for further options.
+func (sk *SessionKey) DecryptStream(dataPacketReader Reader, verifyKeyRing *KeyRing, verifyTime int64) (*VerifyDataReader, error) {
+	handle := defaultDecryptionHandle(profile.Default(), NewConstantClock(verifyTime))
+	handle.SessionKeys = []*SessionKey{sk}
+	handle.VerifyKeyRing = verifyKeyRing
+	return handle.decryptingReader(dataPacketReader, nil, Bytes)
+}
+
 // RandomToken generates a random token with the specified key size.
 func RandomToken(size int) ([]byte, error) {
 	config := &packet.Config{DefaultCipher: packet.CipherAES256}
+	return randomToken(size, config.Random())
+}
+
+func randomToken(size int, random io.Reader) ([]byte, error) {
 	symKey := make([]byte, size)
-	if _, err := io.ReadFull(config.Random(), symKey); err != nil {
+	if _, err := io.ReadFull(random, symKey); err != nil {
 		return nil, errors.Wrap(err, "gopenpgp: error in generating random token")
 	}
 	return symKey, nil
@@ -102,11 +249,16 @@ func RandomToken(size int) ([]byte, error) {
 // GenerateSessionKeyAlgo generates a random key of the correct length for the
 // specified algorithm.
 func GenerateSessionKeyAlgo(algo string) (sk *SessionKey, err error) {
+	config := &packet.Config{}
+	return generateSessionKeyAlgo(algo, config.Random())
+}
+
+func generateSessionKeyAlgo(algo string, random io.Reader) (sk *SessionKey, err error) {
 	cf, ok := symKeyAlgos[algo]
 	if !ok {
 		return nil, errors.New("gopenpgp: unknown symmetric key generation algorithm")
 	}
-	r, err := RandomToken(cf.KeySize())
+	r, err := randomToken(cf.KeySize(), random)
 	if err != nil {
 		return nil, err
 	}
@@ -124,7 +276,21 @@ func generateSessionKey(config *packet.Config) (*SessionKey, error) {
 	if !ok {
 		return nil, errors.New("gopenpgp: unsupported cipher function")
 	}
-	return GenerateSessionKeyAlgo(cf)
+	return generateSessionKeyAlgo(cf, config.Random())
+}
+
+// GenerateSessionKeyForProfile generates a random session key that matches the
+// encryption settings of the profile, e.g., profile.RFC4880() or profile.RFC9580().
+// If the profile enables AEAD encryption (SEIPDv2), the result is a v6 session key,
+// whose cipher is not encoded in the key packets but selected by the data packet.
+func GenerateSessionKeyForProfile(profile EncryptionProfile) (*SessionKey, error) {
+	config := profile.EncryptionConfig()
+	sk, err := generateSessionKey(config)
+	if err != nil {
+		return nil, err
+	}
+	sk.v6 = config.AEAD() != nil
+	return sk, nil
 }
 
 // NewSessionKeyFromToken creates a SessionKey struct with the given token and algorithm.
@@ -161,6 +327,14 @@ func newSessionKeyFromEncrypted(ek *packet.EncryptedKey) (*SessionKey, error) {
 	return sk, nil
 }
 
+func (sk *SessionKey) copy() *SessionKey {
+	return &SessionKey{
+		Key:  clone(sk.Key),
+		Algo: sk.Algo,
+		v6:   sk.v6,
+	}
+}
+
 func (sk *SessionKey) checkSize() error {
 	if sk.v6 {
 		// cannot check size
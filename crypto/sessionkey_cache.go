@@ -0,0 +1,165 @@
+package crypto
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"io"
+	"sync"
+
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/ProtonMail/gopenpgp/v3/internal"
+)
+
+// SessionKeyCache is a least-recently-used cache that maps public-key encrypted session key
+// packets (PKESK) to their decrypted session keys.
+// If set on a decryption handle, repeated decryptions of messages that share the same
+// key packets, e.g., chunks of an attachment, skip the expensive asymmetric decryption.
+// A SessionKeyCache is safe for concurrent use and can be shared between decryption handles.
+type SessionKeyCache struct {
+	mutex    sync.Mutex
+	capacity int
+	entries  *list.List
+	index    map[sessionKeyCacheID]*list.Element
+}
+
+// sessionKeyCacheID identifies a PKESK packet by the recipient key id, the recipient
+// key fingerprint of v6 packets, and the SHA-256 digest of the serialized packet.
+type sessionKeyCacheID struct {
+	keyID          uint64
+	keyFingerprint string
+	digest         [sha256.Size]byte
+}
+
+type sessionKeyCacheEntry struct {
+	ids        []sessionKeyCacheID
+	sessionKey *SessionKey
+}
+
+// NewSessionKeyCache creates a new session key cache that holds at most
+// capacity session keys. If capacity is smaller than one, the cache holds a single session key.
+func NewSessionKeyCache(capacity int) *SessionKeyCache {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &SessionKeyCache{
+		capacity: capacity,
+		entries:  list.New(),
+		index:    make(map[sessionKeyCacheID]*list.Element),
+	}
+}
+
+// Len returns the number of session keys in the cache.
+func (c *SessionKeyCache) Len() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.entries.Len()
+}
+
+// Clear removes all session keys from the cache and clears them from memory.
+func (c *SessionKeyCache) Clear() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for element := c.entries.Front(); element != nil; element = element.Next() {
+		element.Value.(*sessionKeyCacheEntry).sessionKey.Clear()
+	}
+	c.entries.Init()
+	c.index = make(map[sessionKeyCacheID]*list.Element)
+}
+
+// get returns a copy of the cached session key for any of the key packet ids, or nil.
+func (c *SessionKeyCache) get(ids []sessionKeyCacheID) *SessionKey {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for _, id := range ids {
+		if element, ok := c.index[id]; ok {
+			c.entries.MoveToFront(element)
+			return element.Value.(*sessionKeyCacheEntry).sessionKey.copy()
+		}
+	}
+	return nil
+}
+
+// add stores a copy of the session key for the key packet ids and evicts
+// the least recently used session key if the cache is full.
+func (c *SessionKeyCache) add(ids []sessionKeyCacheID, sessionKey *SessionKey) {
+	if len(ids) == 0 || sessionKey == nil {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for _, id := range ids {
+		if _, ok := c.index[id]; ok {
+			return
+		}
+	}
+	element := c.entries.PushFront(&sessionKeyCacheEntry{
+		ids:        ids,
+		sessionKey: sessionKey.copy(),
+	})
+	for _, id := range ids {
+		c.index[id] = element
+	}
+	for c.entries.Len() > c.capacity {
+		oldest := c.entries.Back()
+		entry := c.entries.Remove(oldest).(*sessionKeyCacheEntry)
+		for _, id := range entry.ids {
+			delete(c.index, id)
+		}
+		entry.sessionKey.Clear()
+	}
+}
+
+// sessionKeyCacheIDsFor returns the ids of the key packets that are encrypted to an unlocked private key
+// of the key ring, such that a shared cache only serves session keys that the key ring could decrypt.
+// The key of v6 packets must match the fingerprint, and not only the key id, of the packet.
+func sessionKeyCacheIDsFor(ids []sessionKeyCacheID, keyRing *KeyRing) (matching []sessionKeyCacheID) {
+	for _, id := range ids {
+		for _, key := range (keyRings{keyRing}).KeysById(id.keyID) {
+			if key.PrivateKey == nil || key.PrivateKey.Encrypted || key.PrivateKey.Dummy() {
+				continue
+			}
+			if id.keyFingerprint != "" && id.keyFingerprint != string(key.PublicKey.Fingerprint) {
+				continue
+			}
+			matching = append(matching, id)
+			break
+		}
+	}
+	return matching
+}
+
+// readSessionKeyCacheIDs reads the leading PKESK packets of the message and returns
+// their cache ids together with a reader that replays the full message.
+// Parsing errors are ignored here and reported by the actual decryption.
+func readSessionKeyCacheIDs(message Reader) (Reader, []sessionKeyCacheID, error) {
+	resetReader := internal.NewResetReader(message)
+	var raw bytes.Buffer
+	packets := packet.NewReader(io.TeeReader(resetReader, &raw))
+	var ids []sessionKeyCacheID
+Loop:
+	for {
+		start := raw.Len()
+		p, err := packets.Next()
+		if err != nil {
+			break
+		}
+		switch p := p.(type) {
+		case *packet.EncryptedKey:
+			ids = append(ids, sessionKeyCacheID{
+				keyID:          p.KeyId,
+				keyFingerprint: string(p.KeyFingerprint),
+				digest:         sha256.Sum256(raw.Bytes()[start:]),
+			})
+		case *packet.SymmetricKeyEncrypted:
+			continue
+		default:
+			break Loop
+		}
+	}
+	replay, err := resetReader.Reset()
+	if err != nil {
+		return nil, nil, err
+	}
+	return replay, ids, nil
+}
@@ -0,0 +1,53 @@
+package crypto
+
+import "github.com/pkg/errors"
+
+// NewLockedSessionKeyFromToken creates a SessionKey with the given token and algorithm,
+// where the key is copied to memory that is locked into RAM (mlock/VirtualLock).
+// Locked memory is never swapped to disk, and on unix systems it is further
+// allocated outside the Go heap, thus the key does not linger in swap files or heap dumps.
+// The locked memory is only released by calling Clear on the session key.
+func NewLockedSessionKeyFromToken(token []byte, algo string) (*SessionKey, error) {
+	sk := &SessionKey{
+		Key:  token,
+		Algo: algo,
+	}
+	if err := sk.lock(); err != nil {
+		return nil, err
+	}
+	return sk, nil
+}
+
+// Lock moves the key to memory that is locked into RAM (mlock/VirtualLock)
+// and zeroes the previous key buffer.
+// The locked memory is only released by calling Clear on the session key.
+func (sk *SessionKey) Lock() error {
+	if sk.locked {
+		return nil
+	}
+	previous := sk.Key
+	if err := sk.lock(); err != nil {
+		return err
+	}
+	clearMem(previous)
+	return nil
+}
+
+// IsLocked returns true if the key is stored in locked memory.
+func (sk *SessionKey) IsLocked() bool {
+	return sk.locked
+}
+
+func (sk *SessionKey) lock() error {
+	if len(sk.Key) == 0 {
+		return errors.New("gopenpgp: cannot lock an empty session key")
+	}
+	lockedKey, err := allocLockedMemory(len(sk.Key))
+	if err != nil {
+		return errors.Wrap(err, "gopenpgp: unable to allocate locked memory")
+	}
+	copy(lockedKey, sk.Key)
+	sk.Key = lockedKey
+	sk.locked = true
+	return nil
+}
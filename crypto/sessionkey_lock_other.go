@@ -0,0 +1,14 @@
+//go:build !aix && !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !solaris && !windows
+// +build !aix,!darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!solaris,!windows
+
+package crypto
+
+import "github.com/pkg/errors"
+
+func allocLockedMemory(size int) ([]byte, error) {
+	return nil, errors.New("gopenpgp: locked memory is not supported on this platform")
+}
+
+func freeLockedMemory(mem []byte) error {
+	return errors.New("gopenpgp: locked memory is not supported on this platform")
+}
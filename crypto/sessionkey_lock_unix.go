@@ -0,0 +1,27 @@
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build aix darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package crypto
+
+import "golang.org/x/sys/unix"
+
+// allocLockedMemory maps anonymous memory outside the Go heap and locks it into RAM.
+func allocLockedMemory(size int) ([]byte, error) {
+	mem, err := unix.Mmap(-1, 0, size, unix.PROT_READ|unix.PROT_WRITE, unix.MAP_ANON|unix.MAP_PRIVATE)
+	if err != nil {
+		return nil, err
+	}
+	if err = unix.Mlock(mem); err != nil {
+		_ = unix.Munmap(mem)
+		return nil, err
+	}
+	return mem, nil
+}
+
+// freeLockedMemory unlocks and unmaps memory allocated with allocLockedMemory.
+func freeLockedMemory(mem []byte) error {
+	if err := unix.Munlock(mem); err != nil {
+		return err
+	}
+	return unix.Munmap(mem)
+}
@@ -0,0 +1,24 @@
+//go:build windows
+// +build windows
+
+package crypto
+
+import (
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// allocLockedMemory allocates memory and locks it into RAM.
+func allocLockedMemory(size int) ([]byte, error) {
+	mem := make([]byte, size)
+	if err := windows.VirtualLock(uintptr(unsafe.Pointer(&mem[0])), uintptr(size)); err != nil {
+		return nil, err
+	}
+	return mem, nil
+}
+
+// freeLockedMemory unlocks memory allocated with allocLockedMemory.
+func freeLockedMemory(mem []byte) error {
+	return windows.VirtualUnlock(uintptr(unsafe.Pointer(&mem[0])), uintptr(len(mem)))
+}
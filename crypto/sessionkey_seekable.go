@@ -0,0 +1,413 @@
+package crypto
+
+import (
+	"crypto/cipher"
+	"encoding/binary"
+	"io"
+	"sync"
+
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/pkg/errors"
+)
+
+const (
+	seipdTag            = 18
+	literalDataTag      = 11
+	onePassSignatureTag = 4
+	// seipdV2HeaderLength is the length of version, cipher, mode, chunk size byte, and salt.
+	seipdV2HeaderLength = 4 + 32
+)
+
+// SeekableDecryptor provides random access to the plaintext of a SEIPDv2 (AEAD) data packet.
+// Only the AEAD chunks that cover a requested range are read and decrypted,
+// which allows to read parts of large messages stored on disk or in an object store.
+// Each decrypted chunk is authenticated, and the final authentication tag
+// is checked on creation to detect truncated messages.
+// Signatures in the message are not verified and compressed messages are not supported.
+// A SeekableDecryptor is safe for concurrent calls of ReadAt.
+// Not supported on go-mobile clients.
+type SeekableDecryptor struct {
+	mutex    sync.Mutex
+	literal  *packetBodyReaderAt
+	start    int64
+	offset   int64
+	metadata *LiteralMetadata
+}
+
+// NewSeekableDecryptor creates a SeekableDecryptor for the SEIPDv2 data packet
+// in dataPacket, which has a total size of size bytes.
+// The data packet must not contain key packets, see PGPMessage.DataPacket or PGPSplitWriter.
+// Not supported on go-mobile clients.
+func (sk *SessionKey) NewSeekableDecryptor(dataPacket io.ReaderAt, size int64) (*SeekableDecryptor, error) {
+	tag, body, err := newPacketBodyReaderAt(dataPacket, 0, size)
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: unable to read data packet")
+	}
+	if tag != seipdTag {
+		return nil, errors.New("gopenpgp: data packet is not a SEIPD packet")
+	}
+	chunks, err := newAEADChunkReaderAt(body, sk.Key)
+	if err != nil {
+		return nil, err
+	}
+	// Skip one-pass signatures to find the literal data packet.
+	var offset int64
+	for {
+		tag, literal, err := newPacketBodyReaderAt(chunks, offset, chunks.plaintextLength)
+		if err != nil {
+			return nil, errors.Wrap(err, "gopenpgp: unable to read decrypted packets")
+		}
+		switch tag {
+		case onePassSignatureTag:
+			length, err := literal.length()
+			if err != nil {
+				return nil, errors.Wrap(err, "gopenpgp: unable to read decrypted packets")
+			}
+			offset = literal.segments[0].readerOffset + length
+		case literalDataTag:
+			return newSeekableDecryptor(literal)
+		case compressedDataTag:
+			return nil, errors.New("gopenpgp: random access to compressed messages is not supported")
+		default:
+			return nil, errors.Errorf("gopenpgp: unexpected packet with tag %d in encrypted data", tag)
+		}
+	}
+}
+
+func newSeekableDecryptor(literal *packetBodyReaderAt) (*SeekableDecryptor, error) {
+	// Literal data header: format, filename length, filename, and date.
+	var header [2]byte
+	if _, err := literal.ReadAt(header[:], 0); err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: unable to read literal data header")
+	}
+	filename := make([]byte, header[1])
+	if _, err := literal.ReadAt(filename, 2); err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: unable to read literal data header")
+	}
+	var date [4]byte
+	if _, err := literal.ReadAt(date[:], 2+int64(len(filename))); err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: unable to read literal data header")
+	}
+	return &SeekableDecryptor{
+		literal: literal,
+		start:   6 + int64(len(filename)),
+		metadata: &LiteralMetadata{
+			isUTF8:   header[0] == 'u' || header[0] == 't',
+			filename: string(filename),
+			ModTime:  int64(binary.BigEndian.Uint32(date[:])),
+		},
+	}, nil
+}
+
+// ReadAt reads len(b) plaintext bytes starting at offset off.
+// Implements the io.ReaderAt interface.
+func (d *SeekableDecryptor) ReadAt(b []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("gopenpgp: negative offset")
+	}
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.literal.ReadAt(b, d.start+off)
+}
+
+// Read reads plaintext bytes from the current offset.
+// Implements the io.Reader interface.
+func (d *SeekableDecryptor) Read(b []byte) (int, error) {
+	n, err := d.ReadAt(b, d.offset)
+	d.offset += int64(n)
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+	return n, err
+}
+
+// Seek sets the offset for the next Read.
+// Implements the io.Seeker interface.
+func (d *SeekableDecryptor) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += d.offset
+	case io.SeekEnd:
+		size, err := d.Size()
+		if err != nil {
+			return 0, err
+		}
+		offset += size
+	default:
+		return 0, errors.New("gopenpgp: invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("gopenpgp: negative offset")
+	}
+	d.offset = offset
+	return offset, nil
+}
+
+// Size returns the size of the plaintext in bytes.
+// If the plaintext is encoded with partial lengths,
+// the chunks that contain the length headers are decrypted.
+func (d *SeekableDecryptor) Size() (int64, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	length, err := d.literal.length()
+	if err != nil {
+		return 0, err
+	}
+	return length - d.start, nil
+}
+
+// Metadata returns the metadata of the literal data packet.
+func (d *SeekableDecryptor) Metadata() *LiteralMetadata {
+	return d.metadata
+}
+
+// aeadChunkReaderAt provides random access to the decrypted contents of a SEIPDv2 packet body.
+type aeadChunkReaderAt struct {
+	body            *packetBodyReaderAt
+	chunks          *aeadChunkCipher
+	aead            cipher.AEAD
+	numChunks       int64
+	plaintextLength int64
+	cachedIndex     int64
+	cachedChunk     []byte
+}
+
+func newAEADChunkReaderAt(body *packetBodyReaderAt, key []byte) (*aeadChunkReaderAt, error) {
+	var header [seipdV2HeaderLength]byte
+	if _, err := body.ReadAt(header[:], 0); err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: unable to read data packet header")
+	}
+	if header[0] != 2 {
+		return nil, errors.New("gopenpgp: random access requires a SEIPDv2 data packet")
+	}
+	chunks, err := newAEADChunkCipher(
+		packet.CipherFunction(header[1]),
+		packet.AEADMode(header[2]),
+		header[3],
+		header[4:],
+		key,
+	)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := chunks.newAEAD()
+	if err != nil {
+		return nil, err
+	}
+
+	bodyLength, err := body.length()
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: unable to read data packet")
+	}
+	tagLength := chunks.tagLength
+	ciphertextLength := bodyLength - seipdV2HeaderLength - tagLength
+	if ciphertextLength < 0 {
+		return nil, errors.New("gopenpgp: data packet is truncated")
+	}
+	numChunks := (ciphertextLength + chunks.chunkSize + tagLength - 1) / (chunks.chunkSize + tagLength)
+	plaintextLength := ciphertextLength - numChunks*tagLength
+	if plaintextLength < 0 {
+		return nil, errors.New("gopenpgp: data packet is truncated")
+	}
+	reader := &aeadChunkReaderAt{
+		body:            body,
+		chunks:          chunks,
+		aead:            aead,
+		numChunks:       numChunks,
+		plaintextLength: plaintextLength,
+		cachedIndex:     -1,
+	}
+	tag := make([]byte, tagLength)
+	if _, err := body.ReadAt(tag, bodyLength-tagLength); err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: unable to read the final authentication tag")
+	}
+	if err := chunks.openFinalTag(aead, numChunks, tag, plaintextLength); err != nil {
+		return nil, err
+	}
+	return reader, nil
+}
+
+// chunk returns the decrypted chunk with the given index.
+func (r *aeadChunkReaderAt) chunk(index int64) ([]byte, error) {
+	if index == r.cachedIndex {
+		return r.cachedChunk, nil
+	}
+	tagLength := r.chunks.tagLength
+	offset := seipdV2HeaderLength + index*(r.chunks.chunkSize+tagLength)
+	length := r.chunks.chunkSize
+	if index == r.numChunks-1 {
+		length = r.plaintextLength - index*r.chunks.chunkSize
+	}
+	ciphertext := make([]byte, length+tagLength)
+	if _, err := r.body.ReadAt(ciphertext, offset); err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: unable to read aead chunk")
+	}
+	plaintext, err := r.chunks.openChunk(r.aead, index, nil, ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	r.cachedIndex = index
+	r.cachedChunk = plaintext
+	return plaintext, nil
+}
+
+func (r *aeadChunkReaderAt) ReadAt(b []byte, off int64) (n int, err error) {
+	for n < len(b) {
+		position := off + int64(n)
+		if position >= r.plaintextLength {
+			return n, io.EOF
+		}
+		chunk, err := r.chunk(position / r.chunks.chunkSize)
+		if err != nil {
+			return n, err
+		}
+		n += copy(b[n:], chunk[position%r.chunks.chunkSize:])
+	}
+	return n, nil
+}
+
+// packetBodyReaderAt provides random access to the body of an OpenPGP packet,
+// which might be split into partial bodies.
+// The partial body length headers are read lazily, when a body offset is accessed.
+type packetBodyReaderAt struct {
+	reader     io.ReaderAt
+	limit      int64
+	segments   []packetBodySegment
+	nextHeader int64
+	complete   bool
+}
+
+type packetBodySegment struct {
+	bodyOffset   int64
+	readerOffset int64
+	length       int64
+}
+
+// newPacketBodyReaderAt parses the packet header at offset in reader
+// and returns the packet tag together with a reader for the packet body.
+// The reader must not be read beyond limit.
+func newPacketBodyReaderAt(reader io.ReaderAt, offset, limit int64) (int, *packetBodyReaderAt, error) {
+	var tagByte [1]byte
+	if _, err := reader.ReadAt(tagByte[:], offset); err != nil {
+		return 0, nil, err
+	}
+	if tagByte[0]&0x80 == 0 {
+		return 0, nil, errors.New("invalid packet tag")
+	}
+	body := &packetBodyReaderAt{reader: reader, limit: limit}
+	if tagByte[0]&0x40 != 0 {
+		body.nextHeader = offset + 1
+		if err := body.readSegment(); err != nil {
+			return 0, nil, err
+		}
+		return int(tagByte[0] & 0x3f), body, nil
+	}
+	// Legacy packet format
+	tag := int(tagByte[0]&0x3f) >> 2
+	lengthType := tagByte[0] & 3
+	var length int64
+	headerLength := int64(1)
+	if lengthType == 3 {
+		length = limit - offset - 1
+	} else {
+		lengthBytes := make([]byte, 1<<lengthType)
+		if _, err := reader.ReadAt(lengthBytes, offset+1); err != nil {
+			return 0, nil, err
+		}
+		for _, b := range lengthBytes {
+			length = length<<8 | int64(b)
+		}
+		headerLength += int64(len(lengthBytes))
+	}
+	body.segments = []packetBodySegment{{readerOffset: offset + headerLength, length: length}}
+	body.complete = true
+	return tag, body, nil
+}
+
+// readSegment reads the next length header and adds the segment it describes.
+func (r *packetBodyReaderAt) readSegment() error {
+	var header [5]byte
+	available := r.limit - r.nextHeader
+	if available > int64(len(header)) {
+		available = int64(len(header))
+	}
+	if available <= 0 {
+		return io.ErrUnexpectedEOF
+	}
+	if _, err := r.reader.ReadAt(header[:available], r.nextHeader); err != nil && err != io.EOF {
+		return err
+	}
+	length, lengthBytes, partial, err := readNewFormatLength(header[:available])
+	if err != nil {
+		return err
+	}
+	var bodyOffset int64
+	if len(r.segments) > 0 {
+		last := r.segments[len(r.segments)-1]
+		bodyOffset = last.bodyOffset + last.length
+	}
+	segment := packetBodySegment{
+		bodyOffset:   bodyOffset,
+		readerOffset: r.nextHeader + int64(lengthBytes),
+		length:       int64(length),
+	}
+	if segment.readerOffset+segment.length > r.limit {
+		return io.ErrUnexpectedEOF
+	}
+	r.segments = append(r.segments, segment)
+	r.nextHeader = segment.readerOffset + segment.length
+	r.complete = !partial
+	return nil
+}
+
+// extendTo reads length headers until the body offset is covered or the body is complete.
+func (r *packetBodyReaderAt) extendTo(bodyOffset int64) error {
+	for !r.complete {
+		last := r.segments[len(r.segments)-1]
+		if bodyOffset < last.bodyOffset+last.length {
+			return nil
+		}
+		if err := r.readSegment(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// length returns the length of the packet body.
+func (r *packetBodyReaderAt) length() (int64, error) {
+	if err := r.extendTo(r.limit); err != nil {
+		return 0, err
+	}
+	last := r.segments[len(r.segments)-1]
+	return last.bodyOffset + last.length, nil
+}
+
+func (r *packetBodyReaderAt) ReadAt(b []byte, off int64) (n int, err error) {
+	if err = r.extendTo(off + int64(len(b)) - 1); err != nil {
+		return 0, err
+	}
+	for _, segment := range r.segments {
+		if n == len(b) {
+			break
+		}
+		position := off + int64(n)
+		if position >= segment.bodyOffset+segment.length {
+			continue
+		}
+		toRead := segment.bodyOffset + segment.length - position
+		if toRead > int64(len(b)-n) {
+			toRead = int64(len(b) - n)
+		}
+		read, err := r.reader.ReadAt(b[n:n+int(toRead)], segment.readerOffset+position-segment.bodyOffset)
+		n += read
+		if err != nil && !(err == io.EOF && int64(read) == toRead) {
+			return n, err
+		}
+	}
+	if n < len(b) {
+		return n, io.EOF
+	}
+	return n, nil
+}
@@ -1,12 +1,21 @@
 package crypto
 
 import (
+	"bytes"
+	"crypto/rand"
 	"encoding/base64"
 	"encoding/hex"
+	"io"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/ProtonMail/go-crypto/openpgp/s2k"
+	openpgp "github.com/ProtonMail/go-crypto/openpgp/v2"
 	"github.com/ProtonMail/gopenpgp/v3/constants"
+	"github.com/ProtonMail/gopenpgp/v3/profile"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -66,6 +75,86 @@ func TestMultipleAsymmetricKeyPacket(t *testing.T) {
 	assert.Exactly(t, testSessionKey, outputSymmetricKey)
 }
 
+func TestAsymmetricKeyPacketDecryptionKeyHints(t *testing.T) {
+	decryptionKeys, err := NewKeyRing(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		key, err := testPGP.KeyGeneration().AddUserId("hint", "hint@test.test").New().GenerateKey()
+		if err != nil {
+			t.Fatal("Cannot generate key:", err)
+		}
+		if err = decryptionKeys.AddKey(key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	recipient, _ := decryptionKeys.GetKey(1)
+	other, _ := decryptionKeys.GetKey(2)
+	sessionKey, err := testPGP.GenerateSessionKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, builder := range map[string]*EncryptionHandleBuilder{
+		"recipient":        testPGP.Encryption().Recipient(recipient),
+		"hidden recipient": testPGP.Encryption().HiddenRecipient(recipient),
+	} {
+		t.Run(name, func(t *testing.T) {
+			encHandle, _ := builder.SessionKey(sessionKey).New()
+			pgpMessage, err := encHandle.Encrypt([]byte(testMessage))
+			if err != nil {
+				t.Fatal("Expected no error while encrypting, got:", err)
+			}
+			hints := map[string]*DecryptionHandleBuilder{
+				"key id":      testPGP.Decryption().DecryptionKeyIDHints(recipient.GetKeyID()),
+				"fingerprint": testPGP.Decryption().DecryptionKeyHint(recipient.GetFingerprint()),
+				"subkey id":   testPGP.Decryption().DecryptionKeyHint(keyIDToHex(recipient.entity.Subkeys[0].PublicKey.KeyId)),
+			}
+			for hintName, hintBuilder := range hints {
+				decHandle, err := hintBuilder.DecryptionKeys(decryptionKeys).OnlyHintedDecryptionKeys().New()
+				if err != nil {
+					t.Fatal("Expected no error while creating the decryption handle, got:", err)
+				}
+				decryptedSessionKey, err := decHandle.DecryptSessionKey(pgpMessage.KeyPacket)
+				if err != nil {
+					t.Fatalf("Expected no error while decrypting the key packet with %s hint, got: %v", hintName, err)
+				}
+				assert.Exactly(t, sessionKey.Key, decryptedSessionKey.Key)
+				decrypted, err := decHandle.Decrypt(pgpMessage.Bytes(), Bytes)
+				if err != nil {
+					t.Fatalf("Expected no error while decrypting with %s hint, got: %v", hintName, err)
+				}
+				assert.Exactly(t, testMessage, decrypted.String())
+			}
+
+			// Hinted keys are only tried first
+			decHandle, _ := testPGP.Decryption().
+				DecryptionKeys(decryptionKeys).
+				DecryptionKeyIDHints(other.GetKeyID()).
+				New()
+			if _, err = decHandle.DecryptSessionKey(pgpMessage.KeyPacket); err != nil {
+				t.Fatal("Expected no error while decrypting the key packet, got:", err)
+			}
+
+			// Only hinted keys are tried
+			decHandle, _ = testPGP.Decryption().
+				DecryptionKeys(decryptionKeys).
+				DecryptionKeyIDHints(other.GetKeyID()).
+				OnlyHintedDecryptionKeys().
+				New()
+			_, err = decHandle.DecryptSessionKey(pgpMessage.KeyPacket)
+			assert.Error(t, err)
+			_, err = decHandle.Decrypt(pgpMessage.Bytes(), Bytes)
+			assert.Error(t, err)
+		})
+	}
+
+	_, err = testPGP.Decryption().DecryptionKeys(decryptionKeys).DecryptionKeyHint("not hex").New()
+	assert.Error(t, err)
+	_, err = testPGP.Decryption().DecryptionKeys(decryptionKeys).OnlyHintedDecryptionKeys().New()
+	assert.Error(t, err)
+}
+
 func TestSymmetricKeyPacket(t *testing.T) {
 	password := []byte("I like encryption")
 
@@ -91,6 +180,41 @@ func TestSymmetricKeyPacket(t *testing.T) {
 	assert.Exactly(t, testSessionKey, outputSymmetricKey)
 }
 
+func TestSessionKeyKeyPacketsArmored(t *testing.T) {
+	sessionKey, err := GenerateSessionKeyAlgo(constants.AES256)
+	if err != nil {
+		t.Fatal("Expected no error while generating session key, got:", err)
+	}
+	password := []byte("I like encryption")
+
+	encHandle, _ := testPGP.Encryption().Recipients(keyRingTestPublic).New()
+	armored, err := sessionKey.EncryptKeyPackets(encHandle, Armor)
+	if err != nil {
+		t.Fatal("Expected no error while generating armored key packets, got:", err)
+	}
+	assert.Contains(t, string(armored), "-----BEGIN PGP MESSAGE-----")
+
+	decHandle, _ := testPGP.Decryption().DecryptionKeys(keyRingTestPrivate).New()
+	decryptedSessionKey, err := NewSessionKeyFromKeyPackets(armored, decHandle, Auto)
+	if err != nil {
+		t.Fatal("Expected no error while decrypting armored key packets, got:", err)
+	}
+	assert.Exactly(t, sessionKey, decryptedSessionKey)
+
+	encHandle, _ = testPGP.Encryption().Password(password).New()
+	binary, err := sessionKey.EncryptKeyPackets(encHandle, Bytes)
+	if err != nil {
+		t.Fatal("Expected no error while generating binary key packets, got:", err)
+	}
+
+	decHandle, _ = testPGP.Decryption().Password(password).New()
+	decryptedSessionKey, err = NewSessionKeyFromKeyPackets(binary, decHandle, Bytes)
+	if err != nil {
+		t.Fatal("Expected no error while decrypting binary key packets, got:", err)
+	}
+	assert.Exactly(t, sessionKey, decryptedSessionKey)
+}
+
 func TestSymmetricKeyPacketWrongSize(t *testing.T) {
 	r, err := RandomToken(symKeyAlgos[constants.AES256].KeySize())
 	if err != nil {
@@ -110,6 +234,45 @@ func TestSymmetricKeyPacketWrongSize(t *testing.T) {
 	}
 }
 
+func TestSymmetricKeyPacketAmbiguous(t *testing.T) {
+	passwords := [][]byte{[]byte("first password"), []byte("second password")}
+	config := &packet.Config{S2KConfig: &s2k.Config{S2KMode: s2k.IteratedSaltedS2K, S2KCount: 1024}}
+
+	// Find a v4 key packet of the first password that the second password seemingly decrypts.
+	var ambiguous []byte
+	for attempt := 0; ambiguous == nil; attempt++ {
+		if attempt == 4096 {
+			t.Fatal("Expected an ambiguous key packet")
+		}
+		keyPacket, err := encryptSessionKeyWithPassword(testSessionKey, passwords[0], config)
+		if err != nil {
+			t.Fatal("Expected no error while encrypting session key, got:", err)
+		}
+		if isAmbiguousKeyPacket(keyPacket, passwords, 0) {
+			ambiguous = keyPacket
+		}
+	}
+	keyPacket, err := encryptSessionKeyWithPassword(testSessionKey, passwords[1], config)
+	if err != nil {
+		t.Fatal("Expected no error while encrypting session key, got:", err)
+	}
+	_, err = decryptSessionKeyWithPassword(append(ambiguous, keyPacket...), passwords[1])
+	assert.ErrorIs(t, err, ErrWrongPassword)
+
+	// Key packets for several passwords are never ambiguous.
+	var keyPackets bytes.Buffer
+	if err := encryptSessionKeyWithPasswordsToWriter(passwords, testSessionKey, &keyPackets, config); err != nil {
+		t.Fatal("Expected no error while encrypting session key, got:", err)
+	}
+	for _, password := range passwords {
+		sessionKey, err := decryptSessionKeyWithPassword(keyPackets.Bytes(), password)
+		if err != nil {
+			t.Fatal("Expected no error while decrypting session key, got:", err)
+		}
+		assert.Exactly(t, testSessionKey.Key, sessionKey.Key)
+	}
+}
+
 func TestDataPacketEncryption(t *testing.T) {
 	var message = []byte(
 		"The secret code is... 1, 2, 3, 4, 5. I repeat: the secret code is... 1, 2, 3, 4, 5",
@@ -176,6 +339,279 @@ func TestDataPacketEncryption(t *testing.T) {
 	assert.Exactly(t, message, finalMessageResult.Bytes())
 }
 
+func TestSessionKeyEncryptStream(t *testing.T) {
+	sessionKey, err := GenerateSessionKeyAlgo(constants.AES256)
+	if err != nil {
+		t.Fatal("Expected no error while generating session key, got:", err)
+	}
+	message := []byte("The secret code is... 1, 2, 3, 4, 5")
+	metadata := NewFileMetadata(false, "secret.bin", testTime)
+
+	var dataPackets bytes.Buffer
+	ptWriter, err := sessionKey.EncryptStream(&dataPackets, metadata, keyRingTestPrivate)
+	if err != nil {
+		t.Fatal("Expected no error while creating the encrypting writer, got:", err)
+	}
+	if _, err = ptWriter.Write(message); err != nil {
+		t.Fatal("Expected no error while writing the plaintext, got:", err)
+	}
+	if err = ptWriter.Close(); err != nil {
+		t.Fatal("Expected no error while closing the encrypting writer, got:", err)
+	}
+
+	decryptor, _ := testPGP.Decryption().
+		SessionKey(sessionKey).
+		VerificationKeys(keyRingTestPublic).
+		VerifyTime(time.Now().Unix()).
+		New()
+	decrypted, err := decryptor.Decrypt(dataPackets.Bytes(), Bytes)
+	if err != nil {
+		t.Fatal("Expected no error when decrypting, got:", err)
+	}
+	if err = decrypted.SignatureError(); err != nil {
+		t.Fatal("Expected no signature error when verifying, got:", err)
+	}
+	assert.Exactly(t, message, decrypted.Bytes())
+	assert.Exactly(t, "secret.bin", decrypted.Metadata().Filename())
+	assert.Exactly(t, int64(testTime), decrypted.Metadata().Time())
+}
+
+func TestSessionKeyDecryptStream(t *testing.T) {
+	sessionKey, err := GenerateSessionKeyAlgo(constants.AES256)
+	if err != nil {
+		t.Fatal("Expected no error while generating session key, got:", err)
+	}
+	message := []byte("The secret code is... 1, 2, 3, 4, 5")
+
+	encryptor, _ := testPGP.Encryption().SessionKey(sessionKey).SigningKeys(keyRingTestPrivate).New()
+	pgpMessage, err := encryptor.Encrypt(message)
+	if err != nil {
+		t.Fatal("Expected no error when encrypting and signing, got:", err)
+	}
+
+	ptReader, err := sessionKey.DecryptStream(bytes.NewReader(pgpMessage.DataPacket), keyRingTestPublic, testTime)
+	if err != nil {
+		t.Fatal("Expected no error while creating the decrypting reader, got:", err)
+	}
+	decrypted, err := ptReader.ReadAll()
+	if err != nil {
+		t.Fatal("Expected no error while reading the plaintext, got:", err)
+	}
+	assert.Exactly(t, message, decrypted)
+	verifyResult, err := ptReader.VerifySignature()
+	if err != nil {
+		t.Fatal("Expected no error while verifying, got:", err)
+	}
+	assert.Nil(t, verifyResult.SignatureError())
+
+	// SEIPDv1 does not reject a wrong key up front, so the error
+	// can also surface while reading the plaintext.
+	wrongKey := NewSessionKeyFromToken(make([]byte, 32), constants.AES256)
+	ptReader, err = wrongKey.DecryptStream(bytes.NewReader(pgpMessage.DataPacket), nil, testTime)
+	if err == nil {
+		_, err = ptReader.ReadAll()
+	}
+	assert.Error(t, err)
+}
+
+func TestSessionKeyParallelDecryption(t *testing.T) {
+	pgp := PGPWithProfile(profile.RFC9580())
+	sessionKey, err := pgp.GenerateSessionKey()
+	if err != nil {
+		t.Fatal("Expected no error while generating session key, got:", err)
+	}
+	for _, size := range []int{0, 1000, 100000} {
+		message := make([]byte, size)
+		if _, err = rand.Read(message); err != nil {
+			t.Fatal(err)
+		}
+		encryptor, _ := pgp.Encryption().
+			SessionKey(sessionKey).
+			SigningKeys(keyRingTestPrivate).
+			AEADChunkSize(constants.AEADMinChunkSize).
+			New()
+		pgpMessage, err := encryptor.Encrypt(message)
+		if err != nil {
+			t.Fatal("Expected no error while encrypting, got:", err)
+		}
+		decryptor, _ := pgp.Decryption().
+			SessionKey(sessionKey).
+			VerificationKeys(keyRingTestPublic).
+			DecryptionParallelism(4).
+			New()
+		decrypted, err := decryptor.Decrypt(pgpMessage.DataPacket, Bytes)
+		if err != nil {
+			t.Fatal("Expected no error while decrypting, got:", err)
+		}
+		if err = decrypted.SignatureError(); err != nil {
+			t.Fatal("Expected no signature error, got:", err)
+		}
+		assert.Exactly(t, message, decrypted.Bytes())
+
+		tampered := clone(pgpMessage.DataPacket)
+		tampered[len(tampered)/2] ^= 1
+		_, err = decryptor.Decrypt(tampered, Bytes)
+		assert.Error(t, err)
+		_, err = decryptor.Decrypt(pgpMessage.DataPacket[:len(pgpMessage.DataPacket)-1], Bytes)
+		assert.Error(t, err)
+	}
+}
+
+func TestSessionKeySeekableDecryptor(t *testing.T) {
+	pgp := PGPWithProfile(profile.RFC9580())
+	sessionKey, err := pgp.GenerateSessionKey()
+	if err != nil {
+		t.Fatal("Expected no error while generating session key, got:", err)
+	}
+	message := make([]byte, 100000)
+	if _, err = rand.Read(message); err != nil {
+		t.Fatal(err)
+	}
+	encryptor, _ := pgp.Encryption().
+		SessionKey(sessionKey).
+		SigningKeys(keyRingTestPrivate).
+		AEADChunkSize(constants.AEADMinChunkSize).
+		New()
+	var dataPacket bytes.Buffer
+	ptWriter, err := encryptor.EncryptingWriter(&dataPacket, Bytes)
+	if err != nil {
+		t.Fatal("Expected no error while creating the encrypting writer, got:", err)
+	}
+	// Write in small pieces such that partial lengths are used.
+	for offset := 0; offset < len(message); offset += 1000 {
+		if _, err = ptWriter.Write(message[offset : offset+1000]); err != nil {
+			t.Fatal("Expected no error while writing the plaintext, got:", err)
+		}
+	}
+	if err = ptWriter.Close(); err != nil {
+		t.Fatal("Expected no error while closing the encrypting writer, got:", err)
+	}
+
+	decryptor, err := sessionKey.NewSeekableDecryptor(bytes.NewReader(dataPacket.Bytes()), int64(dataPacket.Len()))
+	if err != nil {
+		t.Fatal("Expected no error while creating the seekable decryptor, got:", err)
+	}
+	size, err := decryptor.Size()
+	if err != nil {
+		t.Fatal("Expected no error while reading the size, got:", err)
+	}
+	assert.Exactly(t, int64(len(message)), size)
+	for _, offset := range []int64{0, 1, 63, 64, 5000, 54321, 99000} {
+		buffer := make([]byte, 1000)
+		n, err := decryptor.ReadAt(buffer, offset)
+		if offset+1000 > size {
+			assert.ErrorIs(t, err, io.EOF)
+		} else {
+			assert.NoError(t, err)
+		}
+		assert.Exactly(t, message[offset:offset+int64(n)], buffer[:n])
+	}
+	if _, err = decryptor.Seek(-100, io.SeekEnd); err != nil {
+		t.Fatal("Expected no error while seeking, got:", err)
+	}
+	tail, err := io.ReadAll(decryptor)
+	if err != nil {
+		t.Fatal("Expected no error while reading, got:", err)
+	}
+	assert.Exactly(t, message[len(message)-100:], tail)
+
+	wrongKey := NewSessionKeyFromToken(make([]byte, 32), constants.AES256)
+	_, err = wrongKey.NewSeekableDecryptor(bytes.NewReader(dataPacket.Bytes()), int64(dataPacket.Len()))
+	assert.Error(t, err)
+	truncated := dataPacket.Bytes()[:dataPacket.Len()-100]
+	_, err = sessionKey.NewSeekableDecryptor(bytes.NewReader(truncated), int64(len(truncated)))
+	assert.Error(t, err)
+	tampered := clone(dataPacket.Bytes())
+	tampered[dataPacket.Len()/2] ^= 1
+	decryptor, err = sessionKey.NewSeekableDecryptor(bytes.NewReader(tampered), int64(len(tampered)))
+	if err != nil {
+		t.Fatal("Expected no error while creating the seekable decryptor, got:", err)
+	}
+	_, err = io.ReadAll(decryptor)
+	assert.Error(t, err)
+}
+
+func TestSessionKeySeekableDecryptorUnsupported(t *testing.T) {
+	sessionKey, err := GenerateSessionKeyAlgo(constants.AES256)
+	if err != nil {
+		t.Fatal("Expected no error while generating session key, got:", err)
+	}
+	// SEIPDv1 data packet
+	encryptor, _ := PGPWithProfile(profile.RFC4880()).Encryption().SessionKey(sessionKey).New()
+	pgpMessage, err := encryptor.Encrypt([]byte(testMessage))
+	if err != nil {
+		t.Fatal("Expected no error when encrypting, got:", err)
+	}
+	_, err = sessionKey.NewSeekableDecryptor(bytes.NewReader(pgpMessage.DataPacket), int64(len(pgpMessage.DataPacket)))
+	assert.Error(t, err)
+
+	// Compressed data
+	encryptor, _ = PGPWithProfile(profile.RFC9580()).Encryption().SessionKey(sessionKey).Compress().New()
+	pgpMessage, err = encryptor.Encrypt([]byte(testMessage))
+	if err != nil {
+		t.Fatal("Expected no error when encrypting, got:", err)
+	}
+	_, err = sessionKey.NewSeekableDecryptor(bytes.NewReader(pgpMessage.DataPacket), int64(len(pgpMessage.DataPacket)))
+	assert.Error(t, err)
+
+	// Non-AES ciphers
+	_, err = newAEADChunkCipher(packet.CipherCAST5, packet.AEADModeOCB, 0, make([]byte, 32), make([]byte, 16))
+	assert.Error(t, err)
+}
+
+func TestSessionKeyEncryptToKeyRing(t *testing.T) {
+	sessionKey, err := GenerateSessionKeyAlgo(constants.AES256)
+	if err != nil {
+		t.Fatal("Expected no error while generating session key, got:", err)
+	}
+	keyPackets, err := sessionKey.EncryptToKeyRing(keyRingTestPublic)
+	if err != nil {
+		t.Fatal("Expected no error while encrypting session key to key ring, got:", err)
+	}
+
+	decryptor, _ := testPGP.Decryption().DecryptionKeys(keyRingTestPrivate).New()
+	decryptedSessionKey, err := decryptor.DecryptSessionKey(keyPackets)
+	if err != nil {
+		t.Fatal("Expected no error while decrypting session key, got:", err)
+	}
+	assert.Exactly(t, sessionKey.Key, decryptedSessionKey.Key)
+	assert.Exactly(t, sessionKey.Algo, decryptedSessionKey.Algo)
+
+	_, err = sessionKey.EncryptToKeyRing(nil)
+	assert.Error(t, err)
+}
+
+func TestSessionKeyPasswordArgon2(t *testing.T) {
+	sessionKey, err := GenerateSessionKeyAlgo(constants.AES256)
+	if err != nil {
+		t.Fatal("Expected no error while generating session key, got:", err)
+	}
+	params := &Argon2Params{Memory: 1 << 10, Iterations: 1, Parallelism: 2}
+	keyPacket, err := sessionKey.EncryptWithPasswordArgon2(password, params)
+	if err != nil {
+		t.Fatal("Expected no error while encrypting session key with password, got:", err)
+	}
+
+	p, err := packet.Read(bytes.NewReader(keyPacket))
+	if err != nil {
+		t.Fatal("Expected no error while parsing key packet, got:", err)
+	}
+	skesk, ok := p.(*packet.SymmetricKeyEncrypted)
+	if !ok {
+		t.Fatal("Expected a symmetric-key encrypted session key packet")
+	}
+	assert.Exactly(t, 6, skesk.Version)
+
+	decryptedSessionKey, err := NewSessionKeyFromPassword(keyPacket, password)
+	if err != nil {
+		t.Fatal("Expected no error while decrypting session key with password, got:", err)
+	}
+	assert.Exactly(t, sessionKey.Key, decryptedSessionKey.Key)
+
+	_, err = NewSessionKeyFromPassword(keyPacket, []byte("wrong password"))
+	assert.Error(t, err)
+}
+
 func TestSessionKeyClear(t *testing.T) {
 	testSessionKey.Clear()
 	assertMemCleared(t, testSessionKey.Key)
@@ -345,6 +781,56 @@ func TestMDCFailDecryption(t *testing.T) {
 	assert.NotNil(t, err)
 }
 
+func TestDecryptMissingMDC(t *testing.T) {
+	key, err := NewKeyFromArmored(readTestFile("sed_key", false))
+	if err != nil {
+		t.Fatal("Expected no error while reading the key, got:", err)
+	}
+	message := []byte(readTestFile("sed_message", false))
+
+	decHandle, _ := testPGP.Decryption().DecryptionKey(key).New()
+	_, err = decHandle.Decrypt(message, Armor)
+	assert.ErrorIs(t, err, ErrMessageNotIntegrityProtected)
+
+	decHandle, _ = testPGP.Decryption().DecryptionKey(key).RetrieveSessionKey().InsecureAllowMissingMDC().New()
+	decResult, err := decHandle.Decrypt(message, Armor)
+	if err != nil {
+		t.Fatal("Expected no error while decrypting, got:", err)
+	}
+	assert.NotEmpty(t, decResult.Bytes())
+	assert.ErrorIs(t, decResult.IntegrityWarning(), ErrMessageNotIntegrityProtected)
+
+	// Decrypt with the session key
+	pgpMessage, err := NewPGPMessageFromArmored(string(message))
+	if err != nil {
+		t.Fatal("Expected no error while unarmoring, got:", err)
+	}
+	decHandle, _ = testPGP.Decryption().SessionKey(decResult.SessionKey()).New()
+	_, err = decHandle.Decrypt(pgpMessage.DataPacket, Bytes)
+	assert.ErrorIs(t, err, ErrMessageNotIntegrityProtected)
+	decHandle, _ = testPGP.Decryption().SessionKey(decResult.SessionKey()).InsecureAllowMissingMDC().New()
+	sessionKeyResult, err := decHandle.Decrypt(pgpMessage.DataPacket, Bytes)
+	if err != nil {
+		t.Fatal("Expected no error while decrypting with the session key, got:", err)
+	}
+	assert.Equal(t, decResult.Bytes(), sessionKeyResult.Bytes())
+	assert.Error(t, sessionKeyResult.IntegrityWarning())
+
+	// Integrity protected messages have no warning
+	encHandle, _ := testPGP.Encryption().Recipients(keyRingTestPublic).New()
+	pgpMessage, err = encHandle.Encrypt([]byte(testMessage))
+	if err != nil {
+		t.Fatal("Expected no error while encrypting, got:", err)
+	}
+	decHandle, _ = testPGP.Decryption().DecryptionKeys(keyRingTestPrivate).InsecureAllowMissingMDC().New()
+	decResult, err = decHandle.Decrypt(pgpMessage.Bytes(), Bytes)
+	if err != nil {
+		t.Fatal("Expected no error while decrypting, got:", err)
+	}
+	assert.Equal(t, testMessage, decResult.String())
+	assert.NoError(t, decResult.IntegrityWarning())
+}
+
 func TestAsymmetricKeyPacketDecryptionFailure(t *testing.T) {
 	passphrase := []byte("passphrase")
 	keyPacket, err := base64.StdEncoding.DecodeString(readTestFile("sessionkey_packet", false))
@@ -373,3 +859,182 @@ func TestAsymmetricKeyPacketDecryptionFailure(t *testing.T) {
 	_, err = decryptor.DecryptSessionKey(keyPacket)
 	assert.Error(t, err, "gopenpgp: unable to decrypt session key")
 }
+
+func TestSessionKeyCache(t *testing.T) {
+	for _, material := range testMaterialForProfiles {
+		t.Run(material.profileName, func(t *testing.T) {
+			if material.keyWrong == nil {
+				t.Skip("no additional key in test material")
+			}
+			wrongKeyRing, err := NewKeyRing(material.keyWrong)
+			if err != nil {
+				t.Fatal("Expected no error while creating keyring, got:", err)
+			}
+			encryptor, _ := material.pgp.Encryption().Recipients(material.keyRingTestPublic).New()
+			first, err := encryptor.Encrypt([]byte(testMessage))
+			if err != nil {
+				t.Fatal("Expected no error while encrypting, got:", err)
+			}
+			second, err := encryptor.Encrypt([]byte(testMessage))
+			if err != nil {
+				t.Fatal("Expected no error while encrypting, got:", err)
+			}
+
+			cache := NewSessionKeyCache(1)
+			decryptor, _ := material.pgp.Decryption().
+				DecryptionKeys(material.keyRingTestPrivate).
+				SessionKeyCache(cache).
+				New()
+			decrypted, err := decryptor.Decrypt(first.Bytes(), Bytes)
+			if err != nil {
+				t.Fatal("Expected no error while decrypting, got:", err)
+			}
+			assert.Exactly(t, testMessage, string(decrypted.Bytes()))
+			assert.Nil(t, decrypted.SessionKey())
+			assert.Exactly(t, 1, cache.Len())
+
+			// The cached session key is not used for a key ring without the matching decryption key.
+			otherDecryptor, _ := material.pgp.Decryption().
+				DecryptionKeys(wrongKeyRing).
+				SessionKeyCache(cache).
+				New()
+			_, err = otherDecryptor.Decrypt(first.Bytes(), Bytes)
+			assert.Error(t, err)
+
+			// The cached session key is not used for a key ring with the matching key locked.
+			unlockedKey, err := material.keyRingTestPrivate.GetKey(0)
+			if err != nil {
+				t.Fatal("Expected no error while getting key, got:", err)
+			}
+			lockedKey, err := material.pgp.LockKey(unlockedKey, testMailboxPassword)
+			if err != nil {
+				t.Fatal("Expected no error while locking key, got:", err)
+			}
+			// NewKeyRing refuses locked keys, but key resolvers are not checked.
+			lockedKeyRing := &KeyRing{entities: openpgp.EntityList{lockedKey.entity}}
+			lockedDecryptor, _ := material.pgp.Decryption().
+				DecryptionKeys(lockedKeyRing).
+				SessionKeyCache(cache).
+				New()
+			_, err = lockedDecryptor.Decrypt(first.Bytes(), Bytes)
+			assert.Error(t, err)
+
+			// The cached session key is not used for a key with the key id, but another fingerprint.
+			ids := sessionKeyCacheIDsFor(cache.entries.Front().Value.(*sessionKeyCacheEntry).ids, material.keyRingTestPrivate)
+			if assert.NotEmpty(t, ids) {
+				ids[0].keyFingerprint = strings.Repeat("\x00", 32)
+				assert.Empty(t, sessionKeyCacheIDsFor(ids[:1], material.keyRingTestPrivate))
+			}
+
+			// The cached session key is used by another handle with the matching decryption key.
+			cachedDecryptor, _ := material.pgp.Decryption().
+				DecryptionKeys(material.keyRingTestPrivate).
+				SessionKeyCache(cache).
+				New()
+			decrypted, err = cachedDecryptor.Decrypt(first.Bytes(), Bytes)
+			if err != nil {
+				t.Fatal("Expected no error while decrypting with cached session key, got:", err)
+			}
+			assert.Exactly(t, testMessage, string(decrypted.Bytes()))
+
+			// Decrypting another message evicts the least recently used session key.
+			if _, err = decryptor.Decrypt(second.Bytes(), Bytes); err != nil {
+				t.Fatal("Expected no error while decrypting, got:", err)
+			}
+			assert.Exactly(t, 1, cache.Len())
+
+			cache.Clear()
+			assert.Exactly(t, 0, cache.Len())
+		})
+	}
+}
+
+func TestSessionKeyLocked(t *testing.T) {
+	token, err := RandomToken(32)
+	if err != nil {
+		t.Fatal("Expected no error while generating token, got:", err)
+	}
+	sessionKey, err := NewLockedSessionKeyFromToken(token, constants.AES256)
+	if err != nil {
+		t.Skip("Locked memory not available:", err)
+	}
+	assert.True(t, sessionKey.IsLocked())
+	assert.Exactly(t, token, sessionKey.Key)
+
+	encryptor, _ := testPGP.Encryption().SessionKey(sessionKey).New()
+	pgpMessage, err := encryptor.Encrypt([]byte(testMessage))
+	if err != nil {
+		t.Fatal("Expected no error while encrypting with locked session key, got:", err)
+	}
+
+	decryptor, _ := testPGP.Decryption().SessionKey(sessionKey).ClearSessionKeys().New()
+	decrypted, err := decryptor.Decrypt(pgpMessage.DataPacket, Bytes)
+	if err != nil {
+		t.Fatal("Expected no error while decrypting with locked session key, got:", err)
+	}
+	assert.Exactly(t, testMessage, string(decrypted.Bytes()))
+	assert.False(t, sessionKey.IsLocked())
+	assert.Nil(t, sessionKey.Key)
+
+	_, err = NewLockedSessionKeyFromToken(nil, constants.AES256)
+	assert.Error(t, err)
+}
+
+func TestSessionKeyClearAfterDecryption(t *testing.T) {
+	sessionKey, err := GenerateSessionKeyAlgo(constants.AES256)
+	if err != nil {
+		t.Fatal("Expected no error while generating session key, got:", err)
+	}
+	if err = sessionKey.Lock(); err != nil {
+		t.Skip("Locked memory not available:", err)
+	}
+	sessionKey.Clear()
+	assert.Nil(t, sessionKey.Key)
+
+	sessionKey, _ = GenerateSessionKeyAlgo(constants.AES256)
+	encryptor, _ := testPGP.Encryption().SessionKey(sessionKey).New()
+	pgpMessage, err := encryptor.Encrypt([]byte(testMessage))
+	if err != nil {
+		t.Fatal("Expected no error while encrypting, got:", err)
+	}
+	key := sessionKey.Key
+	decryptor, _ := testPGP.Decryption().SessionKey(sessionKey).ClearSessionKeys().New()
+	reader, err := decryptor.DecryptingReader(bytes.NewReader(pgpMessage.DataPacket), Bytes)
+	if err != nil {
+		t.Fatal("Expected no error while decrypting, got:", err)
+	}
+	assert.NotEqual(t, make([]byte, len(key)), key)
+	if _, err = reader.ReadAll(); err != nil {
+		t.Fatal("Expected no error while reading, got:", err)
+	}
+	assertMemCleared(t, key)
+}
+
+func TestGenerateSessionKeyForProfile(t *testing.T) {
+	for _, material := range testMaterialForProfiles {
+		t.Run(material.profileName, func(t *testing.T) {
+			sessionKey, err := GenerateSessionKeyForProfile(material.pgp.profile)
+			if err != nil {
+				t.Fatal("Expected no error while generating session key, got:", err)
+			}
+			config := material.pgp.profile.EncryptionConfig()
+			assert.Exactly(t, config.AEAD() != nil, sessionKey.v6)
+			assert.Len(t, sessionKey.Key, config.Cipher().KeySize())
+
+			encryptor, _ := material.pgp.Encryption().
+				Recipients(material.keyRingTestPublic).
+				SessionKey(sessionKey).
+				New()
+			pgpMessage, err := encryptor.Encrypt([]byte(testMessage))
+			if err != nil {
+				t.Fatal("Expected no error while encrypting, got:", err)
+			}
+			decryptor, _ := material.pgp.Decryption().DecryptionKeys(material.keyRingTestPrivate).New()
+			decrypted, err := decryptor.Decrypt(pgpMessage.Bytes(), Bytes)
+			if err != nil {
+				t.Fatal("Expected no error while decrypting, got:", err)
+			}
+			assert.Exactly(t, testMessage, string(decrypted.Bytes()))
+		})
+	}
+}
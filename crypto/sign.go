@@ -1,6 +1,11 @@
 package crypto
 
-import "github.com/ProtonMail/go-crypto/openpgp/packet"
+import (
+	"context"
+	"crypto"
+
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+)
 
 type SignProfile interface {
 	SignConfig() *packet.Config
@@ -10,16 +15,28 @@ type SignProfile interface {
 type PGPSign interface {
 	// SigningWriter returns a wrapper around underlying output Writer,
 	// such that any write-operation via the wrapper results in a write to a detached or inline signature message.
-	// The encoding argument defines the output encoding, i.e., Bytes or Armored
+	// The encoding argument defines the output encoding, i.e., Bytes, Armor, or Base64
 	// Once close is called on the returned WriteCloser the final signature is written to the output.
 	// Thus, the returned WriteCloser must be closed after the plaintext has been written.
 	SigningWriter(output Writer, encoding int8) (WriteCloser, error)
+	// SigningWriterContext is like SigningWriter but aborts the signing once ctx is done.
+	// Writes to the returned WriteCloser and to the output then fail with ctx.Err(),
+	// even if a write to the output blocks.
+	// Not supported on go-mobile clients.
+	SigningWriterContext(ctx context.Context, output Writer, encoding int8) (WriteCloser, error)
 	// Sign creates a detached or inline signature from the provided byte slice.
-	// The encoding argument defines the output encoding, i.e., Bytes or Armored
+	// The encoding argument defines the output encoding, i.e., Bytes, Armor, or Base64
 	Sign(message []byte, encoding int8) ([]byte, error)
 	// SignCleartext produces an armored cleartext message according to the specification.
 	// Returns an armored message even if the PGPSign is not configured for armored output.
 	SignCleartext(message []byte) ([]byte, error)
+	// SignTimestamp creates a timestamp signature (type 0x40) with each signing key
+	// over the digest of a document, which is computed with hashAlgorithm.
+	// The signature proves that the document existed at the signature creation time
+	// without revealing the document to the signer, e.g., a timestamping service.
+	// The encoding argument defines the output encoding, i.e., Bytes, Armor, or Base64.
+	// Not supported on go-mobile clients.
+	SignTimestamp(digest []byte, hashAlgorithm crypto.Hash, encoding int8) ([]byte, error)
 	// ClearPrivateParams clears all secret key material contained in the PGPSign from memory,
 	ClearPrivateParams()
 }
@@ -1,28 +1,35 @@
 package crypto
 
 import (
-	"bytes"
+	"context"
+	"crypto"
 	"io"
 	"time"
 	"unicode/utf8"
 
-	"github.com/ProtonMail/go-crypto/openpgp/armor"
 	"github.com/ProtonMail/go-crypto/openpgp/clearsign"
 	"github.com/ProtonMail/go-crypto/openpgp/packet"
 	openpgp "github.com/ProtonMail/go-crypto/openpgp/v2"
+	armorHelper "github.com/ProtonMail/gopenpgp/v3/armor"
 	"github.com/ProtonMail/gopenpgp/v3/constants"
 	"github.com/ProtonMail/gopenpgp/v3/internal"
 	"github.com/pkg/errors"
 )
 
 type signatureHandle struct {
-	SignKeyRing  *KeyRing
-	SignContext  *SigningContext
-	IsUTF8       bool
-	Detached     bool
-	ArmorHeaders map[string]string
-	profile      SignProfile
-	clock        Clock
+	SignKeyRing       *KeyRing
+	SignContext       *SigningContext
+	Notations         []*Notation
+	IsUTF8            bool
+	Detached          bool
+	ArmorHeaders      map[string]string
+	ArmorLineLength   int
+	OmitArmorChecksum bool
+	ProgressListener  ProgressListener
+	Observer          OperationObserver
+	Logger            Logger
+	profile           SignProfile
+	clock             Clock
 }
 
 // --- Default signature handle to build from
@@ -39,25 +46,54 @@ func defaultSignatureHandle(profile SignProfile, clock Clock) *signatureHandle {
 
 // SigningWriter returns a wrapper around underlying output Writer,
 // such that any write-operation via the wrapper results in a write to a detached or inline signature message.
-// The encoding argument defines the output encoding, i.e., Bytes or Armored
+// The encoding argument defines the output encoding, i.e., Bytes, Armor, or Base64
 // Once close is called on the returned WriteCloser the final signature is written to the output.
 // Thus, the returned WriteCloser must be closed after the plaintext has been written.
 func (sh *signatureHandle) SigningWriter(outputWriter Writer, encoding int8) (messageWriter WriteCloser, err error) {
+	if sh.Observer != nil {
+		observation := startObservation(sh.Observer, OperationSign, sh.signConfig().Hash().String())
+		handle := *sh
+		handle.Observer = nil
+		messageWriter, err = handle.SigningWriter(outputWriter, encoding)
+		return observeWriter(observation, messageWriter, err)
+	}
+	if sh.ProgressListener != nil {
+		progress := &progressTracker{listener: sh.ProgressListener}
+		handle := *sh
+		handle.ProgressListener = nil
+		messageWriter, err = handle.SigningWriter(progress.wrapOutput(outputWriter), encoding)
+		if err != nil {
+			return nil, err
+		}
+		return newProgressWriteCloser(messageWriter, progress.addIn), nil
+	}
+	if encoding == Base64 {
+		base64Writer := newBase64Writer(outputWriter)
+		messageWriter, err = sh.SigningWriter(base64Writer, Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return &armoredWriteCloser{armorWriter: base64Writer, messageWriter: messageWriter}, nil
+	}
 	var armorWriter WriteCloser
 	armorOutput := armorOutput(encoding)
 	if armorOutput {
-		writeChecksum := sh.armorChecksumRequired()
 		var err error
 		header := constants.PGPMessageHeader
 		if sh.Detached {
 			header = constants.PGPSignatureHeader
 		}
-		armorWriter, err = armor.EncodeWithChecksumOption(outputWriter, header, sh.ArmorHeaders, writeChecksum)
+		armorWriter, err = armorHelper.ArmorWriterWithOptions(outputWriter, header, &armorHelper.Options{
+			Headers:      sh.ArmorHeaders,
+			LineLength:   sh.ArmorLineLength,
+			OmitChecksum: !sh.armorChecksumRequired(),
+		})
 		if err != nil {
 			return nil, err
 		}
 		outputWriter = armorWriter
 	}
+	logSigningKeys(sh.Logger, OperationSign, sh.SignKeyRing, sh.signConfig(), sh.clock())
 	if sh.Detached {
 		// Detached signature
 		messageWriter, err = signMessageDetachedWriter(
@@ -66,7 +102,7 @@ func (sh *signatureHandle) SigningWriter(outputWriter Writer, encoding int8) (me
 			sh.IsUTF8,
 			sh.SignContext,
 			sh.clock,
-			sh.profile.SignConfig(),
+			sh.signConfig(),
 		)
 	} else {
 		// Inline signature
@@ -90,31 +126,63 @@ func (sh *signatureHandle) SigningWriter(outputWriter Writer, encoding int8) (me
 	return messageWriter, nil
 }
 
-// Sign creates a detached or inline signature from the provided byte slice.
-// The encoding argument defines the output encoding, i.e., Bytes or Armored.
-func (sh *signatureHandle) Sign(message []byte, encoding int8) ([]byte, error) {
-	var writer bytes.Buffer
-	ptWriter, err := sh.SigningWriter(&writer, encoding)
-	if err != nil {
+// SigningWriterContext is like SigningWriter but aborts the signing once ctx is done.
+// Writes to the returned WriteCloser and to the output then fail with ctx.Err(),
+// even if a write to the output blocks.
+// Not supported on go-mobile clients.
+func (sh *signatureHandle) SigningWriterContext(ctx context.Context, outputWriter Writer, encoding int8) (WriteCloser, error) {
+	if err := ctx.Err(); err != nil {
 		return nil, err
 	}
-	_, err = ptWriter.Write(message)
+	messageWriter, err := sh.SigningWriter(&contextWriter{ctx: ctx, writer: outputWriter}, encoding)
 	if err != nil {
 		return nil, err
 	}
-	err = ptWriter.Close()
+	return &contextCheckWriteCloser{ctx: ctx, writer: messageWriter}, nil
+}
+
+// Sign creates a detached or inline signature from the provided byte slice.
+// The encoding argument defines the output encoding, i.e., Bytes, Armor, or Base64.
+func (sh *signatureHandle) Sign(message []byte, encoding int8) ([]byte, error) {
+	// Inline signed messages contain the plaintext, thus, the pooled buffer is cleared.
+	writer := internal.GetBuffer()
+	ptWriter, err := sh.SigningWriter(writer, encoding)
+	if err == nil {
+		_, err = ptWriter.Write(message)
+	}
+	if err == nil {
+		err = ptWriter.Close()
+	}
 	if err != nil {
+		internal.PutSensitiveBuffer(writer)
 		return nil, err
 	}
-	return writer.Bytes(), nil
+	return internal.TakeBytes(writer), nil
 }
 
 // SignCleartext produces an armored cleartext message according to the specification.
 // Returns an armored message even if the PGPSign is not configured for armored output.
 func (sh *signatureHandle) SignCleartext(message []byte) ([]byte, error) {
+	if sh.Observer != nil {
+		observation := startObservation(sh.Observer, OperationSign, sh.signConfig().Hash().String())
+		signature, err := sh.signCleartext(message)
+		observation.count(len(message))
+		observation.finish(err)
+		return signature, err
+	}
 	return sh.signCleartext(message)
 }
 
+// SignTimestamp creates a timestamp signature (type 0x40) with each signing key
+// over the digest of a document, which is computed with hashAlgorithm.
+// The signature proves that the document existed at the signature creation time
+// without revealing the document to the signer, e.g., a timestamping service.
+// The encoding argument defines the output encoding, i.e., Bytes, Armor, or Base64.
+// Not supported on go-mobile clients.
+func (sh *signatureHandle) SignTimestamp(digest []byte, hashAlgorithm crypto.Hash, encoding int8) ([]byte, error) {
+	return sh.signTimestamp(digest, hashAlgorithm, encoding)
+}
+
 // ClearPrivateParams clears all secret key material contained in the PGPSign from memory.
 func (sh *signatureHandle) ClearPrivateParams() {
 	if sh.SignKeyRing != nil {
@@ -128,11 +196,16 @@ func (sh *signatureHandle) validate() error {
 	if sh.SignKeyRing == nil {
 		return errors.New("gopenpgp: no signing key provided")
 	}
-	return nil
+	if isStrictRFC9580(sh.profile) {
+		if err := checkStrictSigners(sh.SignKeyRing); err != nil {
+			return err
+		}
+	}
+	return validateNotations(sh.Notations)
 }
 
 func (sh *signatureHandle) armorChecksumRequired() bool {
-	if !constants.ArmorChecksumEnabled {
+	if !constants.ArmorChecksumEnabled || sh.OmitArmorChecksum {
 		// If the default behavior is no checksum, we can ignore
 		// the logic for the RFC9580 check.
 		return false
@@ -148,15 +221,22 @@ func (sh *signatureHandle) armorChecksumRequired() bool {
 	return false
 }
 
-func (sh *signatureHandle) signCleartext(message []byte) ([]byte, error) {
+// signConfig returns the sign config of the profile with the notations of the handle applied.
+func (sh *signatureHandle) signConfig() *packet.Config {
 	config := sh.profile.SignConfig()
+	appendNotations(config, sh.Notations)
+	return config
+}
+
+func (sh *signatureHandle) signCleartext(message []byte) ([]byte, error) {
+	config := sh.signConfig()
 	config.Time = NewConstantClock(sh.clock().Unix())
-	var buffer bytes.Buffer
+	logSigningKeys(sh.Logger, OperationSign, sh.SignKeyRing, config, config.Now())
 	var privateKeys []*packet.PrivateKey
 	if !utf8.Valid(message) {
 		return nil, internal.ErrIncorrectUtf8
 	}
-	for _, entity := range sh.SignKeyRing.entities {
+	for _, entity := range sh.SignKeyRing.isolatedEntities() {
 		key, ok := entity.SigningKey(config.Now(), config)
 		if ok &&
 			key.PrivateKey != nil &&
@@ -166,23 +246,24 @@ func (sh *signatureHandle) signCleartext(message []byte) ([]byte, error) {
 			return nil, errors.New("gopenpgp: no signing key found for entity")
 		}
 	}
-	writer, err := clearsign.EncodeMultiWithHeader(&buffer, privateKeys, config, sh.ArmorHeaders)
-	if err != nil {
-		return nil, err
+	// The cleartext message contains the plaintext, thus, the pooled buffer is cleared.
+	buffer := internal.GetBuffer()
+	writer, err := clearsign.EncodeMultiWithHeader(buffer, privateKeys, config, sh.ArmorHeaders)
+	if err == nil {
+		_, err = writer.Write(message)
 	}
-	_, err = writer.Write(message)
-	if err != nil {
-		return nil, err
+	if err == nil {
+		err = writer.Close()
 	}
-	err = writer.Close()
 	if err != nil {
+		internal.PutSensitiveBuffer(buffer)
 		return nil, err
 	}
-	return buffer.Bytes(), nil
+	return internal.TakeBytes(buffer), nil
 }
 
 func (sh *signatureHandle) signingWriter(messageWriter Writer, literalData *LiteralMetadata) (WriteCloser, error) {
-	config := sh.profile.SignConfig()
+	config := sh.signConfig()
 	config.Time = NewConstantClock(sh.clock().Unix())
 	signers, err := sh.SignKeyRing.signingEntities()
 	if err != nil {
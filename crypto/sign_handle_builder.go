@@ -1,5 +1,10 @@
 package crypto
 
+import (
+	armorHelper "github.com/ProtonMail/gopenpgp/v3/armor"
+	"github.com/pkg/errors"
+)
+
 // SignHandleBuilder allows to configure a sign handle
 // to sign data with OpenPGP.
 type SignHandleBuilder struct {
@@ -15,19 +20,17 @@ func newSignHandleBuilder(profile SignProfile, clock Clock) *SignHandleBuilder {
 	}
 }
 
-// SigningKey sets the signing key that is used to create signature of the message.
+// SigningKey adds a signing key that is used to create signature of the message.
+// Can be called multiple times to sign with multiple keys, see SigningKeys.
 func (shb *SignHandleBuilder) SigningKey(key *Key) *SignHandleBuilder {
-	var err error
-	if shb.handle.SignKeyRing == nil {
-		shb.handle.SignKeyRing, err = NewKeyRing(key)
-	} else {
-		err = shb.handle.SignKeyRing.AddKey(key)
-	}
-	shb.err = err
+	shb.handle.SignKeyRing, shb.err = keyRingWithKey(shb.handle.SignKeyRing, key)
 	return shb
 }
 
 // SigningKeys sets the signing keys that are used to create signature of the message.
+// Triggers that a signature is created for each signing key in one pass over the data,
+// e.g., for workflows that require signatures from multiple parties.
+// The resulting inline, detached, or cleartext message contains all signatures.
 func (shb *SignHandleBuilder) SigningKeys(signingKeys *KeyRing) *SignHandleBuilder {
 	shb.handle.SignKeyRing = signingKeys
 	return shb
@@ -40,6 +43,13 @@ func (shb *SignHandleBuilder) SigningContext(signingContext *SigningContext) *Si
 	return shb
 }
 
+// Notation adds a notation to each signature in the message, see NewNotation and NewBinaryNotation.
+// Can be called multiple times to add multiple notations.
+func (shb *SignHandleBuilder) Notation(notation *Notation) *SignHandleBuilder {
+	shb.handle.Notations = append(shb.handle.Notations, notation)
+	return shb
+}
+
 // Detached indicates if a detached signature should be produced.
 // The sign output will be a detached signature message without the data included.
 func (shb *SignHandleBuilder) Detached() *SignHandleBuilder {
@@ -48,15 +58,32 @@ func (shb *SignHandleBuilder) Detached() *SignHandleBuilder {
 }
 
 // ArmorHeader indicates that the produced signature should be armored
-// with the given version and comment as header.
+// with the given version and comment as header. Empty parameters are omitted from the headers.
 // Note that this option only affects the method SignHandle.SigningWriter
 // and the headers in SignHandle.SignCleartext.
 func (shb *SignHandleBuilder) ArmorHeader(version, comment string) *SignHandleBuilder {
-	if shb.handle.ArmorHeaders == nil {
-		shb.handle.ArmorHeaders = make(map[string]string)
+	shb.handle.ArmorHeaders = armorHelper.HeadersWithVersionAndComment(version, comment)
+	return shb
+}
+
+// ArmorLineLength sets the number of base64 characters per line of the armored signature.
+// Must be a multiple of four and at most 76. The default line length is 64.
+// Note that this option only affects the method SignHandle.SigningWriter
+// and not the signature block of SignHandle.SignCleartext.
+func (shb *SignHandleBuilder) ArmorLineLength(lineLength int) *SignHandleBuilder {
+	if !armorHelper.ValidLineLength(lineLength) {
+		shb.err = errors.Errorf("gopenpgp: invalid armor line length %d", lineLength)
+		return shb
 	}
-	shb.handle.ArmorHeaders["Version"] = version
-	shb.handle.ArmorHeaders["Comment"] = comment
+	shb.handle.ArmorLineLength = lineLength
+	return shb
+}
+
+// OmitArmorChecksum indicates that no CRC24 checksum should be appended to the armored signature.
+// By default, the checksum is only omitted if all signing keys are v6 keys.
+// Note that this option only affects the method SignHandle.SigningWriter.
+func (shb *SignHandleBuilder) OmitArmorChecksum() *SignHandleBuilder {
+	shb.handle.OmitArmorChecksum = true
 	return shb
 }
 
@@ -75,6 +102,32 @@ func (shb *SignHandleBuilder) SignTime(unixTime int64) *SignHandleBuilder {
 	return shb
 }
 
+// ProgressListener sets a listener that is notified about the progress of the signing,
+// i.e., the plaintext bytes written and the message bytes produced so far.
+// If not set, no progress is reported.
+func (shb *SignHandleBuilder) ProgressListener(listener ProgressListener) *SignHandleBuilder {
+	shb.handle.ProgressListener = listener
+	return shb
+}
+
+// Observer sets an observer that is notified once an signing completed,
+// e.g., to export metrics. Overrides the observer set with PGPHandle.WithObserver.
+// If not set, no operations are reported.
+// Not supported on go-mobile clients.
+func (shb *SignHandleBuilder) Observer(observer OperationObserver) *SignHandleBuilder {
+	shb.handle.Observer = observer
+	return shb
+}
+
+// Logger sets a logger that is called with the selected signing keys,
+// e.g., to diagnose interoperability failures. Overrides the logger set with PGPHandle.WithLogger.
+// If not set, nothing is logged.
+// Not supported on go-mobile clients.
+func (shb *SignHandleBuilder) Logger(logger Logger) *SignHandleBuilder {
+	shb.handle.Logger = logger
+	return shb
+}
+
 // New creates a SignHandle and checks that the given
 // combination of parameters is valid. If the parameters are invalid
 // an error is returned.
@@ -2,8 +2,18 @@ package crypto
 
 import (
 	"bytes"
+	"context"
+	"crypto"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/ProtonMail/gopenpgp/v3/constants"
+	"github.com/ProtonMail/gopenpgp/v3/profile"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -100,6 +110,44 @@ func TestSignVerifyDetached(t *testing.T) {
 	}
 }
 
+func TestSignVerifyBase64(t *testing.T) {
+	for _, material := range testMaterialForProfiles {
+		t.Run(material.profileName, func(t *testing.T) {
+			verifier, _ := material.pgp.Verify().
+				VerificationKeys(material.keyRingTestPublic).
+				New()
+			for _, detached := range []bool{false, true} {
+				builder := material.pgp.Sign().SigningKeys(material.keyRingTestPrivate)
+				if detached {
+					builder = builder.Detached()
+				}
+				signer, _ := builder.New()
+				testSignVerify(t, signer, verifier, detached, Base64, len(material.keyRingTestPrivate.entities))
+			}
+		})
+	}
+}
+
+func TestSignVerifyWithKeyResolver(t *testing.T) {
+	for _, material := range testMaterialForProfiles {
+		t.Run(material.profileName, func(t *testing.T) {
+			var resolved []uint64
+			verifier, _ := material.pgp.Verify().
+				VerificationKeyResolver(keyRingResolver(material.keyRingTestPublic, &resolved)).
+				New()
+			for _, detached := range []bool{false, true} {
+				builder := material.pgp.Sign().SigningKeys(material.keyRingTestPrivate)
+				if detached {
+					builder = builder.Detached()
+				}
+				signer, _ := builder.New()
+				testSignVerify(t, signer, verifier, detached, Bytes, len(material.keyRingTestPrivate.entities))
+			}
+			assert.NotEmpty(t, resolved)
+		})
+	}
+}
+
 func TestSignVerifyDetachedUtf8(t *testing.T) {
 	for _, material := range testMaterialForProfiles {
 		t.Run(material.profileName, func(t *testing.T) {
@@ -178,6 +226,68 @@ func TestSignVerifyCleartext(t *testing.T) {
 	}
 }
 
+func TestSignVerifyCleartextReader(t *testing.T) {
+	longLine := strings.Repeat("long line ", 1000)
+	message := "-- dash line\n" + longLine + "  \t\n- \nend  "
+	for _, material := range testMaterialForProfiles {
+		t.Run(material.profileName, func(t *testing.T) {
+			isV6 := material.keyRingTestPrivate.GetKeys()[0].isV6()
+			signer, _ := material.pgp.Sign().
+				SigningKeys(material.keyRingTestPrivate).
+				New()
+			verifier, _ := material.pgp.Verify().
+				VerificationKeys(material.keyRingTestPublic).
+				New()
+			cleartextMessage, err := signer.SignCleartext([]byte(message))
+			if err != nil {
+				t.Fatal(err)
+			}
+			expected, err := verifier.VerifyCleartext(cleartextMessage)
+			if err != nil {
+				t.Fatal(err)
+			}
+			reader, err := verifier.VerifyingCleartextReader(bytes.NewReader(cleartextMessage))
+			if err != nil {
+				t.Fatal(err)
+			}
+			cleartext, err := reader.ReadAll()
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Exactly(t, string(expected.Cleartext()), string(cleartext))
+			result, err := reader.VerifySignature()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if isV6 {
+				assert.Equal(t, constants.SIGNATURE_FAILED, result.SignatureErrorExplicit().Status)
+				return
+			}
+			if err := result.SignatureError(); err != nil {
+				t.Fatal("Expected no signature error, got:", err)
+			}
+
+			tampered := bytes.Replace(cleartextMessage, []byte("end"), []byte("End"), 1)
+			reader, err = verifier.VerifyingCleartextReader(bytes.NewReader(tampered))
+			if err != nil {
+				t.Fatal(err)
+			}
+			result, err = reader.DiscardAllAndVerifySignature()
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Error(t, result.SignatureError())
+
+			truncated := cleartextMessage[:bytes.Index(cleartextMessage, []byte("-----BEGIN PGP SIGNATURE-----"))]
+			reader, err = verifier.VerifyingCleartextReader(bytes.NewReader(truncated))
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Error(t, reader.DiscardAll())
+		})
+	}
+}
+
 func TestSignArmor(t *testing.T) {
 	for _, material := range testMaterialForProfiles {
 		t.Run(material.profileName, func(t *testing.T) {
@@ -197,12 +307,50 @@ func TestSignArmor(t *testing.T) {
 	}
 }
 
+func TestSignArmorOptions(t *testing.T) {
+	for _, material := range testMaterialForProfiles {
+		t.Run(material.profileName, func(t *testing.T) {
+			signer, err := material.pgp.Sign().
+				SigningKeys(material.keyRingTestPrivate).
+				ArmorHeader("test version", "").
+				ArmorLineLength(48).
+				OmitArmorChecksum().
+				New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			armoredSignature, err := signer.Sign([]byte(messageToSign), Armor)
+			if err != nil {
+				t.Fatal("Expected no error in signing, got:", err)
+			}
+			armored := string(armoredSignature)
+			assert.Contains(t, armored, "Version: test version\n")
+			assert.NotContains(t, armored, "Comment:")
+			assert.False(t, containsChecksum(armored))
+			checkArmorLineLength(t, armored, 48)
+
+			verifier, _ := material.pgp.Verify().
+				VerificationKeys(material.keyRingTestPublic).
+				New()
+			verifyResult, err := verifier.VerifyInline(armoredSignature, Armor)
+			if err != nil {
+				t.Fatal("Expected no error while verifying, got:", err)
+			}
+			if err = verifyResult.SignatureError(); err != nil {
+				t.Fatal("Expected no signature error, got:", err)
+			}
+		})
+	}
+	_, err := testPGP.Sign().SigningKeys(keyRingTestPrivate).ArmorLineLength(0).New()
+	assert.Error(t, err)
+}
+
 func testSignVerify(
 	t *testing.T,
 	signer PGPSign,
 	verifier PGPVerify,
 	detached bool,
-	encoding int8, //nolint:unparam
+	encoding int8,
 	numberOfSigsToVerify int,
 ) {
 	messageBytes := []byte(messageToSign)
@@ -344,3 +492,822 @@ func testSignVerifyCleartext(t *testing.T, signer PGPSign, verifier PGPVerify) {
 	}
 	assert.Exactly(t, expectedMessageCleartext, string(result.Cleartext()))
 }
+
+func TestSignVerifyProgressListener(t *testing.T) {
+	var lastIn, lastOut int64
+	listener := ProgressFunc(func(bytesIn, bytesOut int64) {
+		lastIn, lastOut = bytesIn, bytesOut
+	})
+	for _, material := range testMaterialForProfiles {
+		t.Run(material.profileName, func(t *testing.T) {
+			lastIn, lastOut = 0, 0
+			signer, _ := material.pgp.Sign().
+				SigningKeys(material.keyRingTestPrivate).
+				ProgressListener(listener).
+				New()
+			signature, err := signer.Sign([]byte(testMessage), Bytes)
+			if err != nil {
+				t.Fatal("Expected no error while signing, got:", err)
+			}
+			assert.Equal(t, int64(len(testMessage)), lastIn)
+			assert.Equal(t, int64(len(signature)), lastOut)
+
+			lastIn, lastOut = 0, 0
+			verifier, _ := material.pgp.Verify().
+				VerificationKeys(material.keyRingTestPublic).
+				ProgressListener(listener).
+				New()
+			verifyResult, err := verifier.VerifyInline(signature, Bytes)
+			if err != nil {
+				t.Fatal("Expected no error while verifying, got:", err)
+			}
+			if err = verifyResult.SignatureError(); err != nil {
+				t.Fatal("Expected no signature error, got:", err)
+			}
+			assert.Equal(t, int64(len(signature)), lastIn)
+			assert.Equal(t, int64(len(testMessage)), lastOut)
+		})
+	}
+}
+
+func TestSignVerifyContext(t *testing.T) {
+	signer, _ := testPGP.Sign().SigningKeys(keyRingTestPrivate).New()
+	verifier, _ := testPGP.Verify().VerificationKeys(keyRingTestPublic).New()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var signature bytes.Buffer
+	messageWriter, err := signer.SigningWriterContext(ctx, &signature, Bytes)
+	if err != nil {
+		t.Fatal("Expected no error while creating the signing writer, got:", err)
+	}
+	if _, err = messageWriter.Write([]byte(messageToSign)); err != nil {
+		t.Fatal("Expected no error while writing the message, got:", err)
+	}
+	if err = messageWriter.Close(); err != nil {
+		t.Fatal("Expected no error while closing the signing writer, got:", err)
+	}
+	verifyReader, err := verifier.VerifyingReaderContext(ctx, nil, bytes.NewReader(signature.Bytes()), Bytes)
+	if err != nil {
+		t.Fatal("Expected no error while creating the verifying reader, got:", err)
+	}
+	firstByte := make([]byte, 1)
+	if _, err = verifyReader.Read(firstByte); err != nil {
+		t.Fatal("Expected no error while reading the message, got:", err)
+	}
+	cancel()
+	if _, err = verifyReader.Read(firstByte); !errors.Is(err, context.Canceled) {
+		t.Fatal("Expected a context error after cancellation, got:", err)
+	}
+	if _, err = signer.SigningWriterContext(ctx, &signature, Bytes); !errors.Is(err, context.Canceled) {
+		t.Fatal("Expected a context error for a cancelled context, got:", err)
+	}
+
+	blocking := &blockingReadWriter{unblock: make(chan struct{})}
+	defer close(blocking.unblock)
+	timeoutCtx, timeoutCancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer timeoutCancel()
+	if _, err = verifier.VerifyingReaderContext(timeoutCtx, nil, blocking, Bytes); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatal("Expected a deadline error for a blocking reader, got:", err)
+	}
+}
+
+func TestVerifyResultSummary(t *testing.T) {
+	signer, _ := testPGP.Sign().SigningKeys(keyRingTestPrivate).Detached().New()
+	verifier, _ := testPGP.Verify().VerificationKeys(keyRingTestPublic).New()
+	signature, err := signer.Sign([]byte(messageToSign), Bytes)
+	if err != nil {
+		t.Fatal("Expected no error while signing, got:", err)
+	}
+	verifyResult, err := verifier.VerifyDetached([]byte(messageToSign), signature, Bytes)
+	if err != nil {
+		t.Fatal("Expected no error while verifying, got:", err)
+	}
+	summary := verifyResult.Summary()
+	assert.Len(t, summary.Signatures, 1)
+	assert.Equal(t, 0, summary.SelectedSignature)
+	assert.Equal(t, constants.SIGNATURE_OK, summary.Status)
+	assert.Empty(t, summary.ErrorClass)
+	signatureSummary := summary.Signatures[0]
+	assert.Equal(t, verifyResult.SignedByKeyIdHex(), signatureSummary.IssuerKeyID)
+	assert.Equal(t, keyRingTestPublic.GetKeys()[0].GetFingerprint(), signatureSummary.SignedByFingerprint)
+	assert.Equal(t, verifyResult.SignatureCreationTime(), signatureSummary.CreationTime)
+	assert.Equal(t, int(constants.SigTypeBinary), signatureSummary.SignatureType)
+	assert.NotZero(t, signatureSummary.HashAlgorithm)
+	assert.NotZero(t, signatureSummary.PublicKeyAlgorithm)
+	assert.Equal(t, verifyResult.SignedWithAlgorithmInt(), signatureSummary.PublicKeyAlgorithm)
+	assert.Equal(t, keyRingTestPublic.GetKeys()[0].GetEntity().PrimaryKey.PubKeyAlgo, verifyResult.SignedWithAlgorithm())
+
+	var decoded VerifyResultSummary
+	if err = json.Unmarshal(verifyResult.SummaryJson(), &decoded); err != nil {
+		t.Fatal("Expected no error while decoding the summary, got:", err)
+	}
+	assert.Equal(t, summary, &decoded)
+	marshalled, err := json.Marshal(verifyResult)
+	if err != nil {
+		t.Fatal("Expected no error while encoding the result, got:", err)
+	}
+	assert.JSONEq(t, string(verifyResult.SummaryJson()), string(marshalled))
+
+	verifyResult, err = verifier.VerifyDetached([]byte("modified"), signature, Bytes)
+	if err != nil {
+		t.Fatal("Expected no error while verifying, got:", err)
+	}
+	summary = verifyResult.Summary()
+	assert.Equal(t, constants.SIGNATURE_FAILED, summary.Status)
+	assert.Equal(t, "failed", summary.ErrorClass)
+	assert.Equal(t, "failed", summary.Signatures[0].ErrorClass)
+	assert.NotEmpty(t, summary.Signatures[0].Error)
+}
+
+func TestSignVerifySignaturePolicy(t *testing.T) {
+	otherKey, err := testPGP.KeyGeneration().AddUserId("other", "other@example.com").New().GenerateKey()
+	if err != nil {
+		t.Fatal("Expected no error while generating a key, got:", err)
+	}
+	otherPublicKey, err := otherKey.ToPublic()
+	if err != nil {
+		t.Fatal("Expected no error while extracting the public key, got:", err)
+	}
+	signingKeys, _ := NewKeyRing(keyRingTestPrivate.GetKeys()[0])
+	_ = signingKeys.AddKey(otherKey)
+	bothSigners, _ := NewKeyRing(keyRingTestPublic.GetKeys()[0])
+	_ = bothSigners.AddKey(otherPublicKey)
+
+	signer, _ := testPGP.Sign().SigningKeys(keyRingTestPrivate).New()
+	signedOnce, err := signer.Sign([]byte(messageToSign), Bytes)
+	if err != nil {
+		t.Fatal("Expected no error while signing, got:", err)
+	}
+	signer, _ = testPGP.Sign().SigningKeys(signingKeys).New()
+	signedTwice, err := signer.Sign([]byte(messageToSign), Bytes)
+	if err != nil {
+		t.Fatal("Expected no error while signing, got:", err)
+	}
+
+	verify := func(message []byte, builder *VerifyHandleBuilder) error {
+		verifier, err := builder.New()
+		if err != nil {
+			t.Fatal("Expected no error while creating the verifier, got:", err)
+		}
+		result, err := verifier.VerifyInline(message, Bytes)
+		if err != nil {
+			t.Fatal("Expected no error while verifying, got:", err)
+		}
+		return result.SignatureError()
+	}
+	assert.NoError(t, verify(signedOnce, testPGP.Verify().VerificationKeys(bothSigners)))
+	assert.NoError(t, verify(signedOnce, testPGP.Verify().VerificationKeys(bothSigners).RequireAllSignatures()))
+	assert.NoError(t, verify(signedOnce, testPGP.Verify().VerificationKeys(bothSigners).RequireSignatures(1, bothSigners)))
+	assert.Error(t, verify(signedOnce, testPGP.Verify().VerificationKeys(bothSigners).RequireSignatures(2, bothSigners)))
+
+	assert.NoError(t, verify(signedTwice, testPGP.Verify().VerificationKeys(bothSigners).RequireAllSignatures()))
+	assert.NoError(t, verify(signedTwice, testPGP.Verify().VerificationKeys(bothSigners).RequireSignatures(2, bothSigners)))
+	assert.NoError(t, verify(signedTwice, testPGP.Verify().VerificationKeys(keyRingTestPublic)))
+	assert.Error(t, verify(signedTwice, testPGP.Verify().VerificationKeys(keyRingTestPublic).RequireAllSignatures()))
+
+	_, err = testPGP.Verify().VerificationKeys(bothSigners).RequireSignatures(3, bothSigners).New()
+	assert.Error(t, err)
+
+	encHandle, _ := testPGP.Encryption().Recipients(keyRingTestPublic).SigningKeys(keyRingTestPrivate).New()
+	pgpMessage, err := encHandle.Encrypt([]byte(messageToSign))
+	if err != nil {
+		t.Fatal("Expected no error while encrypting, got:", err)
+	}
+	decHandle, _ := testPGP.Decryption().
+		DecryptionKeys(keyRingTestPrivate).
+		VerificationKeys(bothSigners).
+		RequireSignatures(2, bothSigners).
+		New()
+	decrypted, err := decHandle.Decrypt(pgpMessage.Bytes(), Bytes)
+	if err != nil {
+		t.Fatal("Expected no error while decrypting, got:", err)
+	}
+	assert.Error(t, decrypted.SignatureError())
+}
+
+func TestSignVerifyRequireSignatureFrom(t *testing.T) {
+	otherKey, err := testPGP.KeyGeneration().AddUserId("other", "other@proton.me").New().GenerateKey()
+	if err != nil {
+		t.Fatal("Expected no error while generating a key, got:", err)
+	}
+	otherPublicKey, _ := otherKey.ToPublic()
+	signingKeys, _ := NewKeyRing(keyRingTestPrivate.GetKeys()[0])
+	_ = signingKeys.AddKey(otherKey)
+	bothSigners, _ := NewKeyRing(keyRingTestPublic.GetKeys()[0])
+	_ = bothSigners.AddKey(otherPublicKey)
+	testFingerprint := keyRingTestPublic.GetKeys()[0].GetFingerprint()
+
+	signer, _ := testPGP.Sign().SigningKeys(keyRingTestPrivate).New()
+	signedOnce, err := signer.Sign([]byte(messageToSign), Bytes)
+	if err != nil {
+		t.Fatal("Expected no error while signing, got:", err)
+	}
+	signer, _ = testPGP.Sign().SigningKeys(signingKeys).New()
+	signedTwice, err := signer.Sign([]byte(messageToSign), Bytes)
+	if err != nil {
+		t.Fatal("Expected no error while signing, got:", err)
+	}
+	verify := func(message []byte, verificationKeys *KeyRing, fingerprint string) *VerifyResult {
+		verifier, err := testPGP.Verify().VerificationKeys(verificationKeys).RequireSignatureFrom(fingerprint).New()
+		if err != nil {
+			t.Fatal("Expected no error while creating the verifier, got:", err)
+		}
+		result, err := verifier.VerifyInline(message, Bytes)
+		if err != nil {
+			t.Fatal("Expected no error while verifying, got:", err)
+		}
+		return &result.VerifyResult
+	}
+
+	result := verify(signedTwice, bothSigners, otherKey.GetFingerprint())
+	assert.NoError(t, result.SignatureError())
+	assert.Equal(t, otherKey.GetFingerprint(), result.SignedByKey().GetFingerprint())
+	result = verify(signedTwice, bothSigners, testFingerprint)
+	assert.NoError(t, result.SignatureError())
+	assert.Equal(t, testFingerprint, result.SignedByKey().GetFingerprint())
+
+	// The valid signature of another key does not count.
+	result = verify(signedTwice, keyRingTestPublic, otherKey.GetFingerprint())
+	if assert.Error(t, result.SignatureError()) {
+		assert.Equal(t, constants.SIGNATURE_NO_VERIFIER, result.SignatureErrorExplicit().Status)
+	}
+	result = verify(signedOnce, bothSigners, otherKey.GetFingerprint())
+	if assert.Error(t, result.SignatureError()) {
+		assert.Equal(t, constants.SIGNATURE_NO_VERIFIER, result.SignatureErrorExplicit().Status)
+		assert.Nil(t, result.SignedByKey())
+	}
+
+	// The issuer fingerprint is chosen by the signer, thus a claimed fingerprint
+	// in the unhashed area of another key's signature does not match.
+	pinnedKey := keyRingTestPublic.GetKeys()[0]
+	detachedSigner, _ := testPGP.Sign().SigningKeys(signingKeys).Detached().New()
+	detached, err := detachedSigner.Sign([]byte(messageToSign), Bytes)
+	if err != nil {
+		t.Fatal("Expected no error while signing, got:", err)
+	}
+	otherSignature := signaturePacketOf(t, detached, otherKey.GetKeyID())
+	otherKeyID := make([]byte, 8)
+	binary.BigEndian.PutUint64(otherKeyID, otherKey.GetKeyID())
+	forged := withUnhashedSubpackets(
+		t,
+		otherSignature,
+		append([]byte{33, 4}, pinnedKey.GetFingerprintBytes()...),
+		append([]byte{16}, otherKeyID...),
+	)
+	verifier, _ := testPGP.Verify().VerificationKeys(bothSigners).RequireSignatureFrom(testFingerprint).New()
+	forgedResult, err := verifier.VerifyDetached([]byte(messageToSign), forged, Bytes)
+	if err != nil {
+		t.Fatal("Expected no error while verifying, got:", err)
+	}
+	if assert.Len(t, forgedResult.Signatures, 1) {
+		assert.Equal(t, pinnedKey.GetFingerprintBytes(), forgedResult.Signatures[0].Signature.IssuerFingerprint)
+	}
+	if assert.Error(t, forgedResult.SignatureError()) {
+		assert.Equal(t, constants.SIGNATURE_NO_VERIFIER, forgedResult.SignatureErrorExplicit().Status)
+		assert.Nil(t, forgedResult.SignedByKey())
+	}
+
+	_, err = testPGP.Verify().VerificationKeys(bothSigners).RequireSignatureFrom("not a fingerprint").New()
+	assert.Error(t, err)
+	_, err = testPGP.Verify().VerificationKeys(bothSigners).RequireSignatureFrom(testFingerprint).RequireSignatures(1, bothSigners).New()
+	assert.Error(t, err)
+}
+
+// signaturePacketOf returns the serialized v4 signature packet of the key id in the signatures.
+func signaturePacketOf(t *testing.T, signatures []byte, keyID uint64) []byte {
+	packets := packet.NewReader(bytes.NewReader(signatures))
+	for {
+		p, err := packets.Next()
+		if err != nil {
+			t.Fatal("Expected a signature of the key, got:", err)
+		}
+		if sig, ok := p.(*packet.Signature); ok && *sig.IssuerKeyId == keyID {
+			var serialized bytes.Buffer
+			if err := sig.Serialize(&serialized); err != nil {
+				t.Fatal("Expected no error while serializing the signature, got:", err)
+			}
+			return serialized.Bytes()
+		}
+	}
+}
+
+// withUnhashedSubpackets appends the subpackets, each given as type and body,
+// to the unhashed area of the serialized v4 signature packet.
+func withUnhashedSubpackets(t *testing.T, signature []byte, subpackets ...[]byte) []byte {
+	if signature[0] != 0xc2 {
+		t.Fatal("Expected a signature packet in the new format")
+	}
+	var body []byte
+	switch length := signature[1]; {
+	case length < 192:
+		body = signature[2:]
+	case length < 224:
+		body = signature[3:]
+	default:
+		body = signature[6:]
+	}
+	if body[0] != 4 {
+		t.Fatal("Expected a v4 signature")
+	}
+	unhashedStart := 6 + int(binary.BigEndian.Uint16(body[4:6]))
+	unhashedLength := int(binary.BigEndian.Uint16(body[unhashedStart : unhashedStart+2]))
+	unhashed := append([]byte{}, body[unhashedStart+2:unhashedStart+2+unhashedLength]...)
+	for _, subpacket := range subpackets {
+		unhashed = append(unhashed, byte(len(subpacket)))
+		unhashed = append(unhashed, subpacket...)
+	}
+	var forged []byte
+	forged = append(forged, body[:unhashedStart]...)
+	forged = append(forged, byte(len(unhashed)>>8), byte(len(unhashed)))
+	forged = append(forged, unhashed...)
+	forged = append(forged, body[unhashedStart+2+unhashedLength:]...)
+	header := make([]byte, 6)
+	header[0], header[1] = 0xc2, 0xff
+	binary.BigEndian.PutUint32(header[2:], uint32(len(forged)))
+	return append(header, forged...)
+}
+
+func TestSignVerifyVerificationPolicy(t *testing.T) {
+	signer, _ := testPGP.Sign().SigningKeys(keyRingTestPrivate).New()
+	signed, err := signer.Sign([]byte(messageToSign), Bytes)
+	if err != nil {
+		t.Fatal("Expected no error while signing, got:", err)
+	}
+	verify := func(policy VerificationPolicy) *VerifyResult {
+		verifier, err := testPGP.Verify().VerificationKeys(keyRingTestPublic).VerificationPolicy(policy).New()
+		if err != nil {
+			t.Fatal("Expected no error while creating the verifier, got:", err)
+		}
+		result, err := verifier.VerifyInline(signed, Bytes)
+		if err != nil {
+			t.Fatal("Expected no error while verifying, got:", err)
+		}
+		return &result.VerifyResult
+	}
+	result := verify(DefaultAlgorithmPolicy())
+	if err := result.SignatureError(); err != nil {
+		t.Fatal("Expected no error with the default policy, got:", err)
+	}
+
+	result = verify(&AlgorithmPolicy{RejectedHashes: []crypto.Hash{result.selectedSignature.Signature.Hash}})
+	sigErr := result.SignatureErrorExplicit()
+	if sigErr == nil {
+		t.Fatal("Expected a signature verification error")
+	}
+	assert.Equal(t, constants.SIGNATURE_POLICY_VIOLATION, sigErr.Status)
+
+	result = verify(&AlgorithmPolicy{MinRSABits: 1 << 16})
+	if keyRingTestPublic.GetKeys()[0].GetEntity().PrimaryKey.PubKeyAlgo == packet.PubKeyAlgoRSA {
+		assert.Error(t, result.SignatureError())
+	} else {
+		assert.NoError(t, result.SignatureError())
+	}
+}
+
+func TestSignVerifyNotations(t *testing.T) {
+	textNotation := NewNotation("text@example.com", "Hello", false)
+	binaryNotation := NewBinaryNotation("binary@example.com", []byte{0, 1, 2}, true)
+	for _, detached := range []bool{false, true} {
+		builder := testPGP.Sign().SigningKeys(keyRingTestPrivate).Notation(textNotation).Notation(binaryNotation)
+		if detached {
+			builder = builder.Detached()
+		}
+		signer, _ := builder.New()
+		signature, err := signer.Sign([]byte(messageToSign), Bytes)
+		if err != nil {
+			t.Fatal("Expected no error while signing, got:", err)
+		}
+		verify := func(builder *VerifyHandleBuilder) *VerifyResult {
+			verifier, _ := builder.VerificationKeys(keyRingTestPublic).New()
+			if detached {
+				result, err := verifier.VerifyDetached([]byte(messageToSign), signature, Bytes)
+				if err != nil {
+					t.Fatal("Expected no error while verifying, got:", err)
+				}
+				return result
+			}
+			result, err := verifier.VerifyInline(signature, Bytes)
+			if err != nil {
+				t.Fatal("Expected no error while verifying, got:", err)
+			}
+			return &result.VerifyResult
+		}
+		// The critical binary notation is unknown to the verifier.
+		assert.Error(t, verify(testPGP.Verify()).SignatureError())
+
+		result := verify(testPGP.Verify().KnownNotation(binaryNotation.Name))
+		if err := result.SignatureError(); err != nil {
+			t.Fatal("Expected no signature error, got:", err)
+		}
+		assert.Equal(t, textNotation, result.Notation(textNotation.Name))
+		assert.Equal(t, binaryNotation, result.Notation(binaryNotation.Name))
+		assert.Equal(t, "Hello", result.Notation(textNotation.Name).GetValueString())
+		assert.Nil(t, result.Notation("missing@example.com"))
+	}
+
+	encHandle, _ := testPGP.Encryption().
+		Recipients(keyRingTestPublic).
+		SigningKeys(keyRingTestPrivate).
+		SigningNotation(textNotation).
+		New()
+	pgpMessage, err := encHandle.Encrypt([]byte(messageToSign))
+	if err != nil {
+		t.Fatal("Expected no error while encrypting, got:", err)
+	}
+	decHandle, _ := testPGP.Decryption().DecryptionKeys(keyRingTestPrivate).VerificationKeys(keyRingTestPublic).New()
+	decrypted, err := decHandle.Decrypt(pgpMessage.Bytes(), Bytes)
+	if err != nil {
+		t.Fatal("Expected no error while decrypting, got:", err)
+	}
+	if err := decrypted.SignatureError(); err != nil {
+		t.Fatal("Expected no signature error, got:", err)
+	}
+	assert.Equal(t, textNotation, decrypted.Notation(textNotation.Name))
+
+	_, err = testPGP.Sign().SigningKeys(keyRingTestPrivate).Notation(NewNotation("invalid", "", false)).New()
+	assert.Error(t, err)
+	_, err = testPGP.Encryption().Recipients(keyRingTestPublic).SigningNotation(textNotation).New()
+	assert.Error(t, err)
+}
+
+func TestSignVerifyAllowedClockSkew(t *testing.T) {
+	verifyTime := time.Now().Unix()
+	signer, _ := testPGP.Sign().SigningKeys(keyRingTestPrivate).SignTime(verifyTime + 300).New()
+	signature, err := signer.Sign([]byte(messageToSign), Bytes)
+	if err != nil {
+		t.Fatal("Expected no error while signing, got:", err)
+	}
+	verify := func(clockSkew int64) error {
+		verifier, err := testPGP.Verify().
+			VerificationKeys(keyRingTestPublic).
+			VerifyTime(verifyTime).
+			AllowedClockSkew(clockSkew).
+			New()
+		if err != nil {
+			t.Fatal("Expected no error while creating the verifier, got:", err)
+		}
+		result, err := verifier.VerifyInline(signature, Bytes)
+		if err != nil {
+			t.Fatal("Expected no error while verifying, got:", err)
+		}
+		return result.SignatureError()
+	}
+	assert.Error(t, verify(0))
+	assert.Error(t, verify(60))
+	assert.NoError(t, verify(600))
+
+	encHandle, _ := testPGP.Encryption().
+		Recipients(keyRingTestPublic).
+		SigningKeys(keyRingTestPrivate).
+		SignTime(verifyTime + 300).
+		New()
+	pgpMessage, err := encHandle.Encrypt([]byte(messageToSign))
+	if err != nil {
+		t.Fatal("Expected no error while encrypting, got:", err)
+	}
+	decHandle, _ := testPGP.Decryption().
+		DecryptionKeys(keyRingTestPrivate).
+		VerificationKeys(keyRingTestPublic).
+		VerifyTime(verifyTime).
+		AllowedClockSkew(600).
+		New()
+	decrypted, err := decHandle.Decrypt(pgpMessage.Bytes(), Bytes)
+	if err != nil {
+		t.Fatal("Expected no error while decrypting, got:", err)
+	}
+	assert.NoError(t, decrypted.SignatureError())
+
+	_, err = testPGP.Verify().VerificationKeys(keyRingTestPublic).AllowedClockSkew(-1).New()
+	assert.Error(t, err)
+}
+
+func TestSignVerifyKeyLookup(t *testing.T) {
+	otherKey, err := testPGP.KeyGeneration().AddUserId("other", "other@example.com").New().GenerateKey()
+	if err != nil {
+		t.Fatal("Expected no error while generating a key, got:", err)
+	}
+	otherPublicKey, err := otherKey.ToPublic()
+	if err != nil {
+		t.Fatal("Expected no error while extracting the public key, got:", err)
+	}
+	otherKeyRing, _ := NewKeyRing(otherKey)
+	signer, _ := testPGP.Sign().SigningKeys(otherKeyRing).New()
+	signature, err := signer.Sign([]byte(messageToSign), Bytes)
+	if err != nil {
+		t.Fatal("Expected no error while signing, got:", err)
+	}
+
+	var lookups int
+	lookup := KeyLookupFunc(func(keyID uint64, fingerprint []byte) (*Key, error) {
+		lookups++
+		if keyID != otherPublicKey.GetKeyID() {
+			return nil, nil
+		}
+		if fingerprint != nil {
+			assert.Equal(t, otherPublicKey.GetFingerprintBytes(), fingerprint)
+		}
+		return otherPublicKey, nil
+	})
+	verifier, _ := testPGP.Verify().VerificationKeys(keyRingTestPublic).KeyLookup(lookup).New()
+	result, err := verifier.VerifyInline(signature, Bytes)
+	if err != nil {
+		t.Fatal("Expected no error while verifying, got:", err)
+	}
+	if err := result.SignatureError(); err != nil {
+		t.Fatal("Expected no signature error, got:", err)
+	}
+	assert.Equal(t, 1, lookups)
+	assert.Equal(t, otherPublicKey.GetFingerprint(), result.SignedByKey().GetFingerprint())
+
+	verifier, err = testPGP.Verify().KeyLookup(lookup).New()
+	if err != nil {
+		t.Fatal("Expected no error while creating the verifier, got:", err)
+	}
+	result, err = verifier.VerifyInline(signature, Bytes)
+	if err != nil {
+		t.Fatal("Expected no error while verifying, got:", err)
+	}
+	assert.NoError(t, result.SignatureError())
+
+	verifier, _ = testPGP.Verify().
+		VerificationKeys(keyRingTestPublic).
+		KeyLookup(KeyLookupFunc(func(uint64, []byte) (*Key, error) { return nil, nil })).
+		New()
+	result, err = verifier.VerifyInline(signature, Bytes)
+	if err != nil {
+		t.Fatal("Expected no error while verifying, got:", err)
+	}
+	assert.Error(t, result.SignatureError())
+
+	encHandle, _ := testPGP.Encryption().Recipients(keyRingTestPublic).SigningKeys(otherKeyRing).New()
+	pgpMessage, err := encHandle.Encrypt([]byte(messageToSign))
+	if err != nil {
+		t.Fatal("Expected no error while encrypting, got:", err)
+	}
+	decHandle, _ := testPGP.Decryption().
+		DecryptionKeys(keyRingTestPrivate).
+		VerificationKeys(keyRingTestPublic).
+		KeyLookup(lookup).
+		New()
+	decrypted, err := decHandle.Decrypt(pgpMessage.Bytes(), Bytes)
+	if err != nil {
+		t.Fatal("Expected no error while decrypting, got:", err)
+	}
+	assert.NoError(t, decrypted.SignatureError())
+	assert.Equal(t, messageToSign, decrypted.String())
+}
+
+func TestSignVerifyMultipleSigners(t *testing.T) {
+	otherKey, err := testPGP.KeyGeneration().AddUserId("other", "other@example.com").New().GenerateKey()
+	if err != nil {
+		t.Fatal("Expected no error while generating a key, got:", err)
+	}
+	otherPublicKey, err := otherKey.ToPublic()
+	if err != nil {
+		t.Fatal("Expected no error while extracting the public key, got:", err)
+	}
+	verificationKeys, _ := NewKeyRing(keyRingTestPublic.GetKeys()[0])
+	_ = verificationKeys.AddKey(otherPublicKey)
+	for _, signType := range []string{"inline", "detached", "cleartext"} {
+		t.Run(signType, func(t *testing.T) {
+			builder := testPGP.Sign().SigningKeys(keyRingTestPrivate).SigningKey(otherKey)
+			if signType == "detached" {
+				builder = builder.Detached()
+			}
+			signer, err := builder.New()
+			if err != nil {
+				t.Fatal("Expected no error while creating the signer, got:", err)
+			}
+			verifier, _ := testPGP.Verify().
+				VerificationKeys(verificationKeys).
+				RequireSignatures(2, verificationKeys).
+				New()
+			var result *VerifyResult
+			switch signType {
+			case "inline":
+				signature, err := signer.Sign([]byte(messageToSign), Armor)
+				if err != nil {
+					t.Fatal("Expected no error while signing, got:", err)
+				}
+				verifiedData, err := verifier.VerifyInline(signature, Armor)
+				if err != nil {
+					t.Fatal("Expected no error while verifying, got:", err)
+				}
+				result = &verifiedData.VerifyResult
+			case "detached":
+				signature, err := signer.Sign([]byte(messageToSign), Armor)
+				if err != nil {
+					t.Fatal("Expected no error while signing, got:", err)
+				}
+				if result, err = verifier.VerifyDetached([]byte(messageToSign), signature, Armor); err != nil {
+					t.Fatal("Expected no error while verifying, got:", err)
+				}
+			case "cleartext":
+				signature, err := signer.SignCleartext([]byte(messageToSign))
+				if err != nil {
+					t.Fatal("Expected no error while signing, got:", err)
+				}
+				verifiedCleartext, err := verifier.VerifyCleartext(signature)
+				if err != nil {
+					t.Fatal("Expected no error while verifying, got:", err)
+				}
+				result = &verifiedCleartext.VerifyResult
+			}
+			if err := result.SignatureError(); err != nil {
+				t.Fatal("Expected no signature error, got:", err)
+			}
+			assert.Len(t, result.Signatures, 2)
+		})
+	}
+	// Adding a signing key must not modify the key ring passed by the caller.
+	assert.Equal(t, 1, keyRingTestPrivate.CountEntities())
+}
+
+func TestSignVerifyDetachedMultipleHashes(t *testing.T) {
+	otherKey, err := testPGP.KeyGeneration().AddUserId("other", "other@example.com").New().GenerateKey()
+	if err != nil {
+		t.Fatal("Expected no error while generating a key, got:", err)
+	}
+	otherPublicKey, err := otherKey.ToPublic()
+	if err != nil {
+		t.Fatal("Expected no error while extracting the public key, got:", err)
+	}
+	verificationKeys, _ := NewKeyRing(keyRingTestPublic.GetKeys()[0])
+	_ = verificationKeys.AddKey(otherPublicKey)
+	sha512Profile := profile.RFC4880()
+	sha512Profile.Hash = crypto.SHA512
+	data := bytes.Repeat([]byte(messageToSign), 10000)
+
+	// Two SHA-256 signatures share a hash, the SHA-512 signature is hashed in parallel.
+	var signatures []byte
+	for _, signer := range []*PGPHandle{PGPWithProfile(profile.RFC4880()), PGPWithProfile(sha512Profile)} {
+		signer.defaultTime = testPGP.defaultTime
+		builder := signer.Sign().Detached()
+		if len(signatures) == 0 {
+			builder = builder.SigningKeys(keyRingTestPrivate)
+		}
+		builder = builder.SigningKey(otherKey)
+		signHandle, _ := builder.New()
+		signature, err := signHandle.Sign(data, Bytes)
+		if err != nil {
+			t.Fatal("Expected no error while signing, got:", err)
+		}
+		signatures = append(signatures, signature...)
+	}
+
+	verifier, _ := testPGP.Verify().
+		VerificationKeys(verificationKeys).
+		RequireAllSignatures().
+		New()
+	reader, err := verifier.VerifyingReader(bytes.NewReader(data), bytes.NewReader(signatures), Bytes)
+	if err != nil {
+		t.Fatal("Expected no error while verifying, got:", err)
+	}
+	result, err := reader.DiscardAllAndVerifySignature()
+	if err != nil {
+		t.Fatal("Expected no error while verifying, got:", err)
+	}
+	if err := result.SignatureError(); err != nil {
+		t.Fatal("Expected no signature error, got:", err)
+	}
+	assert.Len(t, result.Signatures, 3)
+
+	data[0] ^= 1
+	result, err = verifier.VerifyDetached(data, signatures, Bytes)
+	if err != nil {
+		t.Fatal("Expected no error while verifying, got:", err)
+	}
+	for _, signature := range result.Signatures {
+		assert.NotNil(t, signature.SignatureError)
+	}
+}
+
+func TestSignVerifyTimestamp(t *testing.T) {
+	data := []byte(messageToSign)
+	for _, material := range testMaterialForProfiles {
+		t.Run(material.profileName, func(t *testing.T) {
+			signer, _ := material.pgp.Sign().
+				SigningKeys(material.keyRingTestPrivate).
+				New()
+			verifier, _ := material.pgp.Verify().
+				VerificationKeys(material.keyRingTestPublic).
+				New()
+			timestamp, err := RequestTimestamp(NewLocalTimestampClient(signer), bytes.NewReader(data), crypto.SHA256)
+			if err != nil {
+				t.Fatal("Expected no error while requesting a timestamp, got:", err)
+			}
+			result, err := verifier.VerifyTimestamp(data, timestamp, Bytes)
+			if err != nil {
+				t.Fatal("Expected no error while verifying the timestamp, got:", err)
+			}
+			if err := result.SignatureError(); err != nil {
+				t.Fatal("Expected no signature error, got:", err)
+			}
+			assert.Len(t, result.Signatures, len(material.keyRingTestPrivate.entities))
+			assert.Equal(t, constants.SigTypeTimestamp, int8(result.Signatures[0].Signature.SigType))
+
+			result, err = verifier.VerifyTimestamp([]byte("other data"), timestamp, Bytes)
+			if err != nil {
+				t.Fatal("Expected no error while verifying the timestamp, got:", err)
+			}
+			assert.Error(t, result.SignatureError())
+
+			// A timestamp is not a valid detached signature and vice versa.
+			result, err = verifier.VerifyDetached(data, timestamp, Bytes)
+			if err == nil {
+				assert.Error(t, result.SignatureError())
+			}
+			detachedSigner, _ := material.pgp.Sign().
+				SigningKeys(material.keyRingTestPrivate).
+				Detached().
+				New()
+			signature, err := detachedSigner.Sign(data, Bytes)
+			if err != nil {
+				t.Fatal("Expected no error while signing, got:", err)
+			}
+			result, err = verifier.VerifyTimestamp(data, signature, Bytes)
+			if err != nil {
+				t.Fatal("Expected no error while verifying the timestamp, got:", err)
+			}
+			assert.Error(t, result.SignatureError())
+		})
+	}
+}
+
+func TestSignTimestampArmor(t *testing.T) {
+	signer, _ := testPGP.Sign().SigningKeys(keyRingTestPrivate).New()
+	verifier, _ := testPGP.Verify().VerificationKeys(keyRingTestPublic).New()
+	digest := crypto.SHA512.New()
+	_, _ = digest.Write([]byte(messageToSign))
+	timestamp, err := signer.SignTimestamp(digest.Sum(nil), crypto.SHA512, Armor)
+	if err != nil {
+		t.Fatal("Expected no error while signing the timestamp, got:", err)
+	}
+	result, err := verifier.VerifyTimestamp([]byte(messageToSign), timestamp, Auto)
+	if err != nil {
+		t.Fatal("Expected no error while verifying the timestamp, got:", err)
+	}
+	if err := result.SignatureError(); err != nil {
+		t.Fatal("Expected no signature error, got:", err)
+	}
+	assert.Equal(t, int64(testTime), result.SignatureCreationTime())
+
+	_, err = signer.SignTimestamp(digest.Sum(nil), crypto.SHA256, Bytes)
+	assert.Error(t, err)
+}
+
+func TestVerifiedSignatureFailureReason(t *testing.T) {
+	otherKey, _ := testPGP.KeyGeneration().AddUserId("other", "other@proton.me").New().GenerateKey()
+	signers, _ := keyRingTestPrivate.Copy()
+	if err := signers.AddKey(otherKey); err != nil {
+		t.Fatal("Expected no error while adding the key, got:", err)
+	}
+	signTime := time.Now().Unix()
+	signer, _ := testPGP.Sign().SigningKeys(signers).SignTime(signTime).Detached().New()
+	verifier, _ := testPGP.Verify().VerificationKeys(keyRingTestPublic).VerifyTime(signTime).New()
+	data := []byte(messageToSign)
+	signature, err := signer.Sign(data, Bytes)
+	if err != nil {
+		t.Fatal("Expected no error while signing, got:", err)
+	}
+	failureReasons := func(verifier PGPVerify, data, signature []byte) []int {
+		verifyResult, err := verifier.VerifyDetached(data, signature, Bytes)
+		if err != nil {
+			t.Fatal("Expected no error while verifying, got:", err)
+		}
+		var reasons []int
+		for index, verifiedSignature := range verifyResult.Signatures {
+			reasons = append(reasons, verifiedSignature.FailureReason())
+			assert.Equal(t, verifiedSignature.FailureReason(), verifyResult.Summary().Signatures[index].FailureReason)
+		}
+		return reasons
+	}
+
+	assert.Equal(t, []int{constants.SIGNATURE_REASON_NONE, constants.SIGNATURE_REASON_MISSING_KEY}, failureReasons(verifier, data, signature))
+	assert.Equal(t, []int{constants.SIGNATURE_REASON_BAD_HASH, constants.SIGNATURE_REASON_MISSING_KEY}, failureReasons(verifier, []byte("modified"), signature))
+
+	expiredVerifier, _ := testPGP.Verify().VerificationKeys(keyRingTestPublic).VerifyTime(signTime - 3600).New()
+	assert.Equal(t, []int{constants.SIGNATURE_REASON_EXPIRED, constants.SIGNATURE_REASON_MISSING_KEY}, failureReasons(expiredVerifier, data, signature))
+
+	singleSigner, _ := testPGP.Sign().SigningKeys(keyRingTestPrivate).SignTime(signTime).Detached().New()
+	signature, err = singleSigner.Sign(data, Bytes)
+	if err != nil {
+		t.Fatal("Expected no error while signing, got:", err)
+	}
+	// A corrupted signature value does not verify with the key of the issuer.
+	signature[len(signature)-1] ^= 0xff
+	assert.Equal(t, []int{constants.SIGNATURE_REASON_WRONG_KEY}, failureReasons(verifier, data, signature))
+
+	cleartext, err := singleSigner.SignCleartext(data)
+	if err != nil {
+		t.Fatal("Expected no error while signing, got:", err)
+	}
+	cleartextResult, err := verifier.VerifyCleartext(bytes.Replace(cleartext, data, []byte("modified"), 1))
+	if err != nil {
+		t.Fatal("Expected no error while verifying, got:", err)
+	}
+	if assert.Len(t, cleartextResult.Signatures, 1) {
+		assert.Equal(t, constants.SIGNATURE_REASON_BAD_HASH, cleartextResult.Signatures[0].FailureReason())
+	}
+}
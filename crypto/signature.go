@@ -2,7 +2,9 @@ package crypto
 
 import (
 	"bytes"
+	"encoding/hex"
 	"fmt"
+	"strings"
 	"time"
 
 	pgpErrors "github.com/ProtonMail/go-crypto/openpgp/errors"
@@ -18,6 +20,15 @@ type VerifiedSignature struct {
 	Signature      *packet.Signature
 	SignedBy       *Key
 	SignatureError *SignatureVerificationError
+	// SignerValidity is the validity of SignedBy in the web of trust of the verification,
+	// see constants.Validity...
+	SignerValidity int8
+	// Transparency is the attestation of the key transparency verifier for SignedBy,
+	// or nil if no verifier was set.
+	Transparency *TransparencyAttestation
+	// signingKeyFingerprint is the fingerprint of the primary key or subkey of SignedBy
+	// that verified the signature, or nil if no key matched.
+	signingKeyFingerprint []byte
 }
 
 // SignatureVerificationError is returned from Decrypt and VerifyDetached
@@ -39,6 +50,68 @@ type VerifyResult struct {
 	// The signature error of the selected signature.
 	// Is nil for a successful verification.
 	signatureError *SignatureVerificationError
+	// The policy that defines which signatures must be valid.
+	// Is nil if any valid signature is accepted.
+	policy *signaturePolicy
+}
+
+// signaturePolicy defines which signatures of a message must be valid
+// for a successful verification.
+type signaturePolicy struct {
+	// requireAll indicates that all signatures must be valid.
+	requireAll bool
+	// signers and threshold indicate that at least threshold distinct keys
+	// in signers must have a valid signature.
+	signers   *KeyRing
+	threshold int
+	// verificationPolicy refuses signatures, e.g., with weak algorithms.
+	verificationPolicy VerificationPolicy
+	// webOfTrust computes the validity of the signers.
+	webOfTrust *WebOfTrust
+	// keyTransparency attests the inclusion of the signers in a key transparency log.
+	keyTransparency KeyTransparencyVerifier
+	// issuerFingerprint indicates that only the signatures of the key
+	// with this fingerprint are considered.
+	issuerFingerprint []byte
+}
+
+// newSignaturePolicy returns the signature policy for the given options,
+// or nil if any valid signature is accepted.
+func newSignaturePolicy(
+	requireAll bool,
+	signers *KeyRing,
+	threshold int,
+	verificationPolicy VerificationPolicy,
+	webOfTrust *WebOfTrust,
+	keyTransparency KeyTransparencyVerifier,
+	issuerFingerprint []byte,
+) *signaturePolicy {
+	if !requireAll && signers == nil && verificationPolicy == nil && webOfTrust == nil && keyTransparency == nil &&
+		issuerFingerprint == nil {
+		return nil
+	}
+	return &signaturePolicy{
+		requireAll:         requireAll,
+		signers:            signers,
+		threshold:          threshold,
+		verificationPolicy: verificationPolicy,
+		webOfTrust:         webOfTrust,
+		keyTransparency:    keyTransparency,
+		issuerFingerprint:  issuerFingerprint,
+	}
+}
+
+func (p *signaturePolicy) validate() error {
+	if p != nil && p.issuerFingerprint != nil && p.signers != nil {
+		return errors.New("gopenpgp: a required issuer cannot be combined with required signers")
+	}
+	if p == nil || p.signers == nil {
+		return nil
+	}
+	if p.threshold < 1 || p.threshold > p.signers.CountEntities() {
+		return errors.New("gopenpgp: the signature threshold must be between one and the number of required signers")
+	}
+	return nil
 }
 
 // SignatureCreationTime returns the creation time of
@@ -65,6 +138,22 @@ func (vr *VerifyResult) SignedWithTypeInt8() int8 {
 	return int8(vr.SignedWithType())
 }
 
+// SignedWithAlgorithm returns the public key algorithm of the selected signature if found, else returns 0,
+// e.g., for relying parties to enforce a policy on the signature algorithms.
+// Not supported in go-mobile use SignedWithAlgorithmInt instead.
+func (vr *VerifyResult) SignedWithAlgorithm() packet.PublicKeyAlgorithm {
+	if vr.selectedSignature == nil || vr.selectedSignature.Signature == nil {
+		return 0
+	}
+	return vr.selectedSignature.Signature.PubKeyAlgo
+}
+
+// SignedWithAlgorithmInt returns the OpenPGP ID of the public key algorithm
+// of the selected signature if found, else returns 0.
+func (vr *VerifyResult) SignedWithAlgorithmInt() int {
+	return int(vr.SignedWithAlgorithm())
+}
+
 // SignedByKeyId returns the key id of the key that was used to verify the selected signature,
 // if found, else returns 0.
 // Not supported in go-mobile use SignedByKeyIdString instead.
@@ -112,6 +201,75 @@ func (vr *VerifyResult) SignedByKey() *Key {
 	}
 }
 
+// SignedByValidity returns the validity of the key that was used to verify the selected signature
+// in the web of trust of the verification, see constants.Validity...
+// Returns constants.ValidityUnknown if no web of trust was set or no key was found.
+func (vr *VerifyResult) SignedByValidity() int8 {
+	if vr.selectedSignature == nil {
+		return constants.ValidityUnknown
+	}
+	return vr.selectedSignature.SignerValidity
+}
+
+// Notations returns all notations of the selected signature, including the context notation,
+// if found, else returns nil.
+// Not supported on go-mobile clients use vr.Notation(name) instead.
+func (vr *VerifyResult) Notations() []*Notation {
+	if vr.selectedSignature == nil {
+		return nil
+	}
+	return vr.selectedSignature.Notations()
+}
+
+// Notation returns the first notation with the given name of the selected signature,
+// if found, else returns nil.
+func (vr *VerifyResult) Notation(name string) *Notation {
+	for _, notation := range vr.Notations() {
+		if notation.Name == name {
+			return notation
+		}
+	}
+	return nil
+}
+
+// Notations returns all notations of the signature, if found, else returns nil.
+// Not supported on go-mobile clients.
+func (vs *VerifiedSignature) Notations() []*Notation {
+	if vs.Signature == nil {
+		return nil
+	}
+	return notationsFromSignature(vs.Signature)
+}
+
+// FailureReason returns the reason why the signature failed, see constants.SIGNATURE_REASON_...
+// Returns constants.SIGNATURE_REASON_NONE if the signature is valid.
+func (vs *VerifiedSignature) FailureReason() int {
+	if vs.SignatureError == nil {
+		return constants.SIGNATURE_REASON_NONE
+	}
+	if vs.SignatureError.Status == constants.SIGNATURE_NO_VERIFIER {
+		return constants.SIGNATURE_REASON_MISSING_KEY
+	}
+	if vs.SignatureError.Status != constants.SIGNATURE_FAILED {
+		return constants.SIGNATURE_REASON_OTHER
+	}
+	cause := vs.SignatureError.Cause
+	var signatureError pgpErrors.SignatureError
+	switch {
+	case errors.Is(cause, pgpErrors.ErrSignatureExpired):
+		return constants.SIGNATURE_REASON_EXPIRED
+	case errors.Is(cause, pgpErrors.ErrKeyExpired):
+		return constants.SIGNATURE_REASON_KEY_EXPIRED
+	case errors.Is(cause, pgpErrors.ErrKeyRevoked):
+		return constants.SIGNATURE_REASON_KEY_REVOKED
+	case errors.Is(cause, errHashTagMismatch):
+		return constants.SIGNATURE_REASON_BAD_HASH
+	case errors.As(cause, &signatureError) && strings.HasSuffix(string(signatureError), "verification failure"):
+		return constants.SIGNATURE_REASON_WRONG_KEY
+	}
+	return constants.SIGNATURE_REASON_OTHER
+}
+
 // Signature returns the serialized openpgp signature packet of the selected signature.
 func (vr *VerifyResult) Signature() ([]byte, error) {
 	if vr.selectedSignature == nil || vr.selectedSignature.Signature == nil {
@@ -195,6 +353,99 @@ func (vr *VerifyResult) selectSignature() {
 		vr.selectedSignature = signature
 		vr.signatureError = signature.SignatureError
 	}
+	vr.applySignaturePolicy()
+}
+
+// applySignaturePolicy updates the selected signature and the signature error
+// such that the result is only successful if the signatures fulfill the policy.
+func (vr *VerifyResult) applySignaturePolicy() {
+	if vr.policy == nil || len(vr.Signatures) == 0 {
+		return
+	}
+	signatures := vr.Signatures
+	if vr.policy.issuerFingerprint != nil {
+		signatures = vr.signaturesFrom(vr.policy.issuerFingerprint)
+		if len(signatures) == 0 {
+			signatureError := newSignatureNoVerifier()
+			signatureError.Cause = errors.Errorf(
+				"gopenpgp: no signature of the required issuer %s",
+				hex.EncodeToString(vr.policy.issuerFingerprint),
+			)
+			vr.selectedSignature = nil
+			vr.signatureError = &signatureError
+			return
+		}
+		// Select among the signatures of the issuer as in selectSignature.
+		vr.selectedSignature = signatures[len(signatures)-1]
+		vr.signatureError = vr.selectedSignature.SignatureError
+		for _, signature := range signatures {
+			if signature.SignatureError == nil {
+				vr.selectedSignature = signature
+				vr.signatureError = nil
+				break
+			}
+		}
+	}
+	if vr.policy.requireAll {
+		for _, signature := range signatures {
+			if signature.SignatureError != nil {
+				vr.selectedSignature = signature
+				vr.signatureError = signature.SignatureError
+				return
+			}
+		}
+	}
+	if vr.policy.signers == nil {
+		return
+	}
+	requiredSigners := make(map[string]bool)
+	for _, key := range vr.policy.signers.GetKeys() {
+		requiredSigners[key.GetFingerprint()] = true
+	}
+	validSigners := make(map[string]bool)
+	var firstValid *VerifiedSignature
+	for _, signature := range vr.Signatures {
+		if signature.SignatureError != nil || signature.SignedBy == nil {
+			continue
+		}
+		fingerprint := signature.SignedBy.GetFingerprint()
+		if !requiredSigners[fingerprint] {
+			continue
+		}
+		validSigners[fingerprint] = true
+		if firstValid == nil {
+			firstValid = signature
+		}
+	}
+	if len(validSigners) < vr.policy.threshold {
+		signatureError := newSignatureFailed(errors.Errorf(
+			"gopenpgp: %d of %d required signers have a valid signature",
+			len(validSigners),
+			vr.policy.threshold,
+		))
+		vr.signatureError = &signatureError
+		return
+	}
+	vr.selectedSignature = firstValid
+	vr.signatureError = nil
+}
+
+// signaturesFrom returns the signatures of the key with the fingerprint, i.e., the signatures
+// whose verification key or its primary key has the fingerprint.
+// The issuer fingerprint of the signature is not considered, since it is chosen by the signer
+// and might be in the unhashed area, while the verification key is selected by the issuer key id.
+func (vr *VerifyResult) signaturesFrom(fingerprint []byte) []*VerifiedSignature {
+	var signatures []*VerifiedSignature
+	for _, signature := range vr.Signatures {
+		if signature.SignedBy == nil {
+			continue
+		}
+		if bytes.Equal(signature.SignedBy.GetFingerprintBytes(), fingerprint) ||
+			bytes.Equal(signature.signingKeyFingerprint, fingerprint) {
+			signatures = append(signatures, signature)
+		}
+	}
+	return signatures
 }
 
 // newSignatureFailed creates a new SignatureVerificationError, type
@@ -215,6 +466,26 @@ func newSignatureFailed(cause error) SignatureVerificationError {
 	}
 }
 
+// newSignaturePolicyViolation creates a new SignatureVerificationError, type
+// SignaturePolicyViolation.
+func newSignaturePolicyViolation(cause error) SignatureVerificationError {
+	return SignatureVerificationError{
+		Status:  constants.SIGNATURE_POLICY_VIOLATION,
+		Message: "Signature refused by the verification policy",
+		Cause:   cause,
+	}
+}
+
+// newSignatureBadIntendedRecipient creates a new SignatureVerificationError, type
+// SignatureBadIntendedRecipient.
+func newSignatureBadIntendedRecipient(cause error) SignatureVerificationError {
+	return SignatureVerificationError{
+		Status:  constants.SIGNATURE_BAD_INTENDED_RECIPIENT,
+		Message: "Decryption key is not an intended recipient",
+		Cause:   cause,
+	}
+}
+
 // newSignatureNotSigned creates a new SignatureVerificationError, type
 // SignatureNotSigned.
 func newSignatureNotSigned() SignatureVerificationError {
@@ -235,22 +506,101 @@ func newSignatureNoVerifier() SignatureVerificationError {
 
 // processSignatureExpiration handles signature time verification manually, so
 // we can ignore signature expired errors if configured so.
-func processSignatureExpiration(sig *packet.Signature, toCheck error, verifyTime int64, disableTimeCheck bool) error {
+// errHashTagMismatch is the error of go-crypto for signatures whose hash tag does not match the data.
+var errHashTagMismatch error = pgpErrors.SignatureError("hash tag doesn't match")
+
+// isHashTagMismatch returns true if the signature of the candidate failed to verify,
+// since the hash of the data does not match the hash tag of the signature.
+// go-crypto only compares the hash tag of v6 signatures, and reports a generic
+// verification failure for other signatures over modified data.
+func isHashTagMismatch(candidate *openpgp.SignatureCandidate) bool {
+	var signatureError pgpErrors.SignatureError
+	if !errors.As(candidate.SignatureError, &signatureError) ||
+		!strings.HasSuffix(string(signatureError), "verification failure") ||
+		candidate.CorrespondingSig == nil || candidate.Hash == nil {
+		return false
+	}
+	// The hash contains the data and the hashed suffix of the signature after the verification.
+	digest := candidate.Hash.Sum(nil)
+	return len(digest) >= 2 &&
+		(digest[0] != candidate.CorrespondingSig.HashTag[0] || digest[1] != candidate.CorrespondingSig.HashTag[1])
+}
+
+func processSignatureExpiration(
+	candidate *openpgp.SignatureCandidate,
+	toCheck error,
+	verifyTime int64,
+	disableTimeCheck bool,
+	clockSkew int64,
+) error {
+	sig := candidate.CorrespondingSig
 	if sig == nil || !errors.Is(toCheck, pgpErrors.ErrSignatureExpired) {
 		return toCheck
 	}
 	if disableTimeCheck || verifyTime == 0 {
 		return nil
 	}
+	if clockSkew > 0 && isWithinClockSkew(candidate, verifyTime, clockSkew) {
+		return nil
+	}
 	return toCheck
 }
 
+// isWithinClockSkew checks if the signature is valid at the verification time
+// when tolerating clockSkew seconds for its creation and expiration time.
+// The binding signatures of the signing key are checked at the signature creation time
+// without tolerance, since they might also cause an expiration error.
+func isWithinClockSkew(candidate *openpgp.SignatureCandidate, verifyTime, clockSkew int64) bool {
+	sig := candidate.CorrespondingSig
+	if sig.CreationTime.Unix() > verifyTime+clockSkew {
+		return false
+	}
+	if sig.SigLifetimeSecs != nil && *sig.SigLifetimeSecs != 0 &&
+		sig.CreationTime.Unix()+int64(*sig.SigLifetimeSecs) < verifyTime-clockSkew {
+		return false
+	}
+	signedBy := candidate.SignedBy
+	if signedBy == nil {
+		return false
+	}
+	bindingSignatures := []*packet.Signature{signedBy.PrimarySelfSignature}
+	if !signedBy.IsPrimary() && signedBy.SelfSignature != nil {
+		bindingSignatures = append(bindingSignatures, signedBy.SelfSignature, signedBy.SelfSignature.EmbeddedSignature)
+	}
+	for _, bindingSignature := range bindingSignatures {
+		if bindingSignature != nil && bindingSignature.SigExpired(sig.CreationTime) {
+			return false
+		}
+	}
+	return true
+}
+
+// isIntendedRecipientMismatch checks if the signature lists intended recipients
+// but the key the message was decrypted with is not one of them.
+func isIntendedRecipientMismatch(md *openpgp.MessageDetails, sig *packet.Signature) bool {
+	if sig == nil ||
+		len(sig.IntendedRecipients) == 0 ||
+		!md.CheckRecipients ||
+		md.IsSymmetricallyEncrypted ||
+		md.DecryptedWith.Entity == nil {
+		return false
+	}
+	for _, recipient := range sig.IntendedRecipients {
+		if bytes.Equal(recipient.Fingerprint, md.DecryptedWith.Entity.PrimaryKey.Fingerprint) {
+			return false
+		}
+	}
+	return true
+}
+
 func createVerifyResult(
 	md *openpgp.MessageDetails,
 	verifierKey *KeyRing,
 	verificationContext *VerificationContext,
 	verifyTime int64,
 	disableTimeCheck bool,
+	clockSkew int64,
+	policy *signaturePolicy,
 ) (*VerifyResult, error) {
 	if !md.IsSigned {
 		signatureError := newSignatureNotSigned()
@@ -261,6 +611,9 @@ func createVerifyResult(
 	if !md.IsVerified {
 		return nil, errors.New("gopenpgp: message has not been verified")
 	}
+	if err := verifierKey.resolutionError(); err != nil {
+		return nil, err
+	}
 
 	verifiedSignatures := make([]*VerifiedSignature, len(md.SignatureCandidates))
 	for candidateIndex, signature := range md.SignatureCandidates {
@@ -274,11 +627,21 @@ func createVerifyResult(
 			Signature: signature.CorrespondingSig,
 			SignedBy:  singedBy,
 		}
+		if signature.SignedBy != nil && signature.SignedBy.PublicKey != nil {
+			verifiedSignature.signingKeyFingerprint = signature.SignedBy.PublicKey.Fingerprint
+		}
+		if singedBy != nil && policy != nil && policy.webOfTrust != nil {
+			verifiedSignature.SignerValidity = policy.webOfTrust.GetKeyValidity(singedBy.GetFingerprint(), verifyTime)
+		}
+		if isHashTagMismatch(signature) {
+			signature.SignatureError = tagError(signature.SignatureError, errHashTagMismatch)
+		}
 		signature.SignatureError = processSignatureExpiration(
-			signature.CorrespondingSig,
+			signature,
 			signature.SignatureError,
 			verifyTime,
 			disableTimeCheck,
+			clockSkew,
 		)
 		var signatureError SignatureVerificationError
 
@@ -286,6 +649,8 @@ func createVerifyResult(
 		case verifierKey == nil || len(verifierKey.entities) == 0 ||
 			errors.Is(signature.SignatureError, pgpErrors.ErrUnknownIssuer):
 			signatureError = newSignatureNoVerifier()
+		case signature.SignatureError != nil && isIntendedRecipientMismatch(md, signature.CorrespondingSig):
+			signatureError = newSignatureBadIntendedRecipient(signature.SignatureError)
 		case signature.SignatureError != nil:
 			signatureError = newSignatureFailed(signature.SignatureError)
 		case verificationContext != nil:
@@ -294,14 +659,37 @@ func createVerifyResult(
 				signatureError = newSignatureBadContext(err)
 			}
 		}
+		if signatureError.Status == constants.SIGNATURE_OK &&
+			policy != nil && policy.verificationPolicy != nil &&
+			signature.SignedBy != nil {
+			err := policy.verificationPolicy.CheckSignature(signature.CorrespondingSig, signature.SignedBy.PublicKey)
+			if err != nil {
+				signatureError = newSignaturePolicyViolation(err)
+			}
+		}
+		// Signatures created before a soft revocation by a designated revoker stay valid.
+		if signatureError.Status == constants.SIGNATURE_OK &&
+			signature.SignedBy != nil && signature.CorrespondingSig != nil &&
+			isRevokedByDesignatedRevoker(signature.SignedBy.Entity, verifierKey, signature.CorrespondingSig.CreationTime) {
+			signatureError = newSignatureFailed(tagError(
+				errors.New("gopenpgp: signing key is revoked by a designated revoker"),
+				pgpErrors.ErrKeyRevoked,
+			))
+		}
 		if signatureError.Status != constants.SIGNATURE_OK {
 			verifiedSignature.SignatureError = &signatureError
 		}
 		verifiedSignatures[candidateIndex] = verifiedSignature
 	}
+	if policy != nil && policy.keyTransparency != nil {
+		if err := attestSigners(policy.keyTransparency, verifiedSignatures, verifyTime); err != nil {
+			return nil, err
+		}
+	}
 
 	verifyResult := &VerifyResult{
 		Signatures: verifiedSignatures,
+		policy:     policy,
 	}
 
 	// Select the signature to show in the result
@@ -0,0 +1,156 @@
+package crypto
+
+import (
+	"encoding/hex"
+	"encoding/json"
+
+	openpgp "github.com/ProtonMail/go-crypto/openpgp/v2"
+	"github.com/ProtonMail/gopenpgp/v3/constants"
+)
+
+// SignatureSummary is a serializable summary of a verified signature,
+// e.g., for audit logging.
+// Algorithms are identified by their OpenPGP algorithm IDs.
+type SignatureSummary struct {
+	// IssuerKeyID is the hex encoded key ID of the issuer, if present in the signature.
+	IssuerKeyID string `json:"issuerKeyId,omitempty"`
+	// IssuerFingerprint is the hex encoded issuer fingerprint, if present in the signature.
+	IssuerFingerprint string `json:"issuerFingerprint,omitempty"`
+	// SignedByFingerprint is the hex encoded fingerprint of the verification key that
+	// matched the signature, if any.
+	SignedByFingerprint string `json:"signedByFingerprint,omitempty"`
+	// CreationTime is the signature creation time in unix time.
+	CreationTime int64 `json:"creationTime"`
+	// SignatureType is the signature type, see constants.SigType...
+	SignatureType int `json:"signatureType"`
+	// HashAlgorithm is the OpenPGP ID of the hash algorithm.
+	HashAlgorithm int `json:"hashAlgorithm"`
+	// PublicKeyAlgorithm is the OpenPGP ID of the public key algorithm.
+	PublicKeyAlgorithm int `json:"publicKeyAlgorithm"`
+	// Version is the signature packet version.
+	Version int `json:"version"`
+	// SignerValidity is the validity of the verification key in the web of trust,
+	// see constants.Validity...
+	SignerValidity int `json:"signerValidity,omitempty"`
+	// TransparencyStatus is the inclusion status of the verification key in the
+	// key transparency log, see constants.Transparency...
+	TransparencyStatus int `json:"transparencyStatus,omitempty"`
+	// Status is the verification status, see constants.SIGNATURE_...
+	Status int `json:"status"`
+	// FailureReason further describes a failed status, e.g., an expired signature
+	// or a missing verification key, see constants.SIGNATURE_REASON_...
+	FailureReason int `json:"failureReason,omitempty"`
+	// ErrorClass is a stable name for the status, e.g., "failed" or "no_verifier".
+	ErrorClass string `json:"errorClass,omitempty"`
+	// Error describes the verification error, if any.
+	Error string `json:"error,omitempty"`
+}
+
+// VerifyResultSummary is a serializable summary of a VerifyResult,
+// e.g., for audit logging.
+type VerifyResultSummary struct {
+	// Signatures contains a summary for each signature found in the message.
+	Signatures []*SignatureSummary `json:"signatures"`
+	// SelectedSignature is the index of the selected signature in Signatures,
+	// or -1 if no signature was selected.
+	SelectedSignature int `json:"selectedSignature"`
+	// Status is the verification status of the result, see constants.SIGNATURE_...
+	Status int `json:"status"`
+	// ErrorClass is a stable name for the status, e.g., "failed" or "not_signed".
+	ErrorClass string `json:"errorClass,omitempty"`
+	// Error describes the verification error of the result, if any.
+	Error string `json:"error,omitempty"`
+}
+
+// Summary returns a serializable summary of the verification result
+// with information on all signatures in the message.
+// Not supported on go-mobile clients use vr.SummaryJson() instead.
+func (vr *VerifyResult) Summary() *VerifyResultSummary {
+	summary := &VerifyResultSummary{
+		Signatures:        make([]*SignatureSummary, 0, len(vr.Signatures)),
+		SelectedSignature: -1,
+	}
+	for index, signature := range vr.Signatures {
+		if signature == vr.selectedSignature {
+			summary.SelectedSignature = index
+		}
+		summary.Signatures = append(summary.Signatures, signature.summary())
+	}
+	summary.Status, summary.ErrorClass, summary.Error = signatureErrorSummary(vr.signatureError)
+	return summary
+}
+
+// SummaryJson returns the summary of the verification result as JSON, see Summary.
+// If an error occurs it returns nil.
+// Helper function for go-mobile clients.
+func (vr *VerifyResult) SummaryJson() []byte {
+	summary, err := json.Marshal(vr.Summary())
+	if err != nil {
+		return nil
+	}
+	return summary
+}
+
+// MarshalJSON encodes the summary of the verification result, see Summary.
+// Implements the json.Marshaler interface.
+func (vr *VerifyResult) MarshalJSON() ([]byte, error) {
+	return json.Marshal(vr.Summary())
+}
+
+func (vs *VerifiedSignature) summary() *SignatureSummary {
+	summary := &SignatureSummary{}
+	if sig := vs.Signature; sig != nil {
+		if sig.IssuerKeyId != nil {
+			summary.IssuerKeyID = keyIDToHex(*sig.IssuerKeyId)
+		}
+		if sig.IssuerFingerprint != nil {
+			summary.IssuerFingerprint = hex.EncodeToString(sig.IssuerFingerprint)
+		}
+		summary.CreationTime = sig.CreationTime.Unix()
+		summary.SignatureType = int(sig.SigType)
+		if hashID, ok := openpgp.HashToHashId(sig.Hash); ok {
+			summary.HashAlgorithm = int(hashID)
+		}
+		summary.PublicKeyAlgorithm = int(sig.PubKeyAlgo)
+		summary.Version = sig.Version
+	}
+	if vs.SignedBy != nil {
+		summary.SignedByFingerprint = vs.SignedBy.GetFingerprint()
+	}
+	summary.SignerValidity = int(vs.SignerValidity)
+	if vs.Transparency != nil {
+		summary.TransparencyStatus = int(vs.Transparency.Status)
+	}
+	summary.Status, summary.ErrorClass, summary.Error = signatureErrorSummary(vs.SignatureError)
+	summary.FailureReason = vs.FailureReason()
+	return summary
+}
+
+func signatureErrorSummary(signatureError *SignatureVerificationError) (status int, errorClass string, message string) {
+	if signatureError == nil {
+		return constants.SIGNATURE_OK, "", ""
+	}
+	return signatureError.Status, signatureErrorClass(signatureError.Status), signatureError.Error()
+}
+
+// signatureErrorClass returns a stable name for a signature verification status.
+func signatureErrorClass(status int) string {
+	switch status {
+	case constants.SIGNATURE_OK:
+		return ""
+	case constants.SIGNATURE_NOT_SIGNED:
+		return "not_signed"
+	case constants.SIGNATURE_NO_VERIFIER:
+		return "no_verifier"
+	case constants.SIGNATURE_FAILED:
+		return "failed"
+	case constants.SIGNATURE_BAD_CONTEXT:
+		return "bad_context"
+	case constants.SIGNATURE_BAD_INTENDED_RECIPIENT:
+		return "bad_intended_recipient"
+	case constants.SIGNATURE_POLICY_VIOLATION:
+		return "policy_violation"
+	default:
+		return "unknown"
+	}
+}
@@ -0,0 +1,126 @@
+package crypto
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	openpgp "github.com/ProtonMail/go-crypto/openpgp/v2"
+	"github.com/ProtonMail/gopenpgp/v3/internal"
+	"github.com/pkg/errors"
+)
+
+// strictProfile is implemented by profiles that only create RFC9580 artifacts,
+// e.g., profile.StrictRFC9580().
+type strictProfile interface {
+	IsStrictRFC9580() bool
+}
+
+// isStrictRFC9580 returns true if the profile refuses to create legacy artifacts.
+func isStrictRFC9580(profile interface{}) bool {
+	strict, ok := profile.(strictProfile)
+	return ok && strict.IsStrictRFC9580()
+}
+
+// checkStrictSigners returns an error if a key of the key ring creates v4 signatures.
+func checkStrictSigners(signers *KeyRing) error {
+	if signers == nil {
+		return nil
+	}
+	for _, signer := range signers.entities {
+		if signer.PrimaryKey.Version != 6 {
+			return errors.New("gopenpgp: strict RFC9580 profile refuses to create v4 signatures")
+		}
+	}
+	return nil
+}
+
+// checkStrictRecipients returns an error if the message for the recipients
+// would be encrypted in a SEIPDv1 data packet.
+func checkStrictRecipients(recipients *KeyRing, config *packet.Config, now time.Time) error {
+	if config.AEADConfig == nil {
+		return errors.New("gopenpgp: strict RFC9580 profile requires AEAD encryption")
+	}
+	if recipients == nil {
+		return nil
+	}
+	for _, recipient := range recipients.entities {
+		selfSignature, err := recipient.PrimarySelfSignature(now, config)
+		if err != nil || !selfSignature.SEIPDv2 {
+			return errors.Errorf(
+				"gopenpgp: strict RFC9580 profile refuses to encrypt to key %s without SEIPDv2 support",
+				keyIDToHex(recipient.PrimaryKey.KeyId),
+			)
+		}
+	}
+	return nil
+}
+
+// readLegacyConstructs returns the legacy key and data packets of the message
+// that RFC9580 only keeps for backwards compatibility, and a reader that
+// replays the message.
+func readLegacyConstructs(message Reader) (Reader, []string, error) {
+	resetReader := internal.NewResetReader(message)
+	packets := packet.NewReader(resetReader)
+	var legacyConstructs []string
+Loop:
+	for {
+		p, err := packets.Next()
+		if err != nil {
+			break
+		}
+		switch p := p.(type) {
+		case *packet.EncryptedKey:
+			if p.Version != 6 {
+				legacyConstructs = append(legacyConstructs, fmt.Sprintf("v%d public-key encrypted session key", p.Version))
+			}
+		case *packet.SymmetricKeyEncrypted:
+			if p.Version != 6 {
+				legacyConstructs = append(legacyConstructs, fmt.Sprintf("v%d symmetric-key encrypted session key", p.Version))
+			}
+		case *packet.SymmetricallyEncrypted:
+			switch {
+			case !p.IntegrityProtected:
+				legacyConstructs = append(legacyConstructs, "symmetrically encrypted data without integrity protection")
+			case p.Version != 2:
+				legacyConstructs = append(legacyConstructs, fmt.Sprintf("SEIPDv%d data", p.Version))
+			}
+			break Loop
+		default:
+			break Loop
+		}
+	}
+	replay, err := resetReader.Reset()
+	if err != nil {
+		return nil, nil, err
+	}
+	return replay, legacyConstructs, nil
+}
+
+// legacySignatures returns the versions of the signatures in the message details
+// that predate RFC9580.
+func legacySignatures(details *openpgp.MessageDetails) []string {
+	var legacyConstructs []string
+	for _, candidate := range details.SignatureCandidates {
+		version := candidate.OPSVersion
+		if version == 3 {
+			// v3 one-pass signatures precede v4 signatures.
+			version = 4
+		}
+		if candidate.CorrespondingSig != nil {
+			version = candidate.CorrespondingSig.Version
+		}
+		if version != 6 {
+			legacyConstructs = append(legacyConstructs, fmt.Sprintf("v%d signature", version))
+		}
+	}
+	return legacyConstructs
+}
+
+func legacyWarning(legacyConstructs []string) error {
+	if len(legacyConstructs) > 0 {
+		return errors.Errorf("gopenpgp: message contains legacy constructs: %s", strings.Join(legacyConstructs, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,259 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto"
+	"io"
+
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	pgpErrors "github.com/ProtonMail/go-crypto/openpgp/errors"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	openpgp "github.com/ProtonMail/go-crypto/openpgp/v2"
+	armorHelper "github.com/ProtonMail/gopenpgp/v3/armor"
+	"github.com/ProtonMail/gopenpgp/v3/constants"
+	"github.com/pkg/errors"
+)
+
+// A timestamp signature (type 0x40) proves that a document existed at the signature creation time.
+// The signature is computed over the digest of the document instead of the document itself,
+// such that a timestamping service never learns the document.
+
+// TimestampClient submits the digest of a document to a timestamping key service,
+// which returns timestamp signatures over the digest, see (PGPSign).SignTimestamp.
+// Not supported on go-mobile clients.
+type TimestampClient interface {
+	// RequestTimestamp returns the timestamp signatures over the digest,
+	// which is computed with hashAlgorithm, in binary or armored encoding.
+	RequestTimestamp(digest []byte, hashAlgorithm crypto.Hash) ([]byte, error)
+}
+
+// TimestampClientFunc is an adapter to use a function as TimestampClient.
+// Not supported on go-mobile clients.
+type TimestampClientFunc func(digest []byte, hashAlgorithm crypto.Hash) ([]byte, error)
+
+// RequestTimestamp calls f(digest, hashAlgorithm).
+func (f TimestampClientFunc) RequestTimestamp(digest []byte, hashAlgorithm crypto.Hash) ([]byte, error) {
+	return f(digest, hashAlgorithm)
+}
+
+// NewLocalTimestampClient returns a TimestampClient that creates the timestamp signatures
+// with the signing keys of signer, e.g., to implement a timestamping service.
+// Not supported on go-mobile clients.
+func NewLocalTimestampClient(signer PGPSign) TimestampClient {
+	return TimestampClientFunc(func(digest []byte, hashAlgorithm crypto.Hash) ([]byte, error) {
+		return signer.SignTimestamp(digest, hashAlgorithm, Bytes)
+	})
+}
+
+// RequestTimestamp computes the digest of data with hashAlgorithm and requests
+// timestamp signatures over it from client.
+// The returned signatures can be verified with (PGPVerify).VerifyTimestamp.
+// Not supported on go-mobile clients.
+func RequestTimestamp(client TimestampClient, data Reader, hashAlgorithm crypto.Hash) ([]byte, error) {
+	digest, err := timestampDigest(data, hashAlgorithm)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := client.RequestTimestamp(digest, hashAlgorithm)
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: timestamp request failed")
+	}
+	return signature, nil
+}
+
+func timestampDigest(data io.Reader, hashAlgorithm crypto.Hash) ([]byte, error) {
+	if _, ok := openpgp.HashToHashId(hashAlgorithm); !ok || !hashAlgorithm.Available() {
+		return nil, errors.Errorf("gopenpgp: hash algorithm %s is not supported", hashAlgorithm)
+	}
+	digest := hashAlgorithm.New()
+	if _, err := io.Copy(digest, data); err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: hashing the timestamped data failed")
+	}
+	return digest.Sum(nil), nil
+}
+
+// signTimestamp creates a timestamp signature over the digest with each key in the sign key ring.
+func (sh *signatureHandle) signTimestamp(digest []byte, hashAlgorithm crypto.Hash, encoding int8) ([]byte, error) {
+	if _, ok := openpgp.HashToHashId(hashAlgorithm); !ok || !hashAlgorithm.Available() {
+		return nil, errors.Errorf("gopenpgp: hash algorithm %s is not supported", hashAlgorithm)
+	}
+	if len(digest) != hashAlgorithm.Size() {
+		return nil, errors.Errorf("gopenpgp: digest is not a %s digest", hashAlgorithm)
+	}
+	config := sh.signConfig()
+	config.Time = NewConstantClock(sh.clock().Unix())
+	if sh.SignContext != nil {
+		config.SignatureNotations = append(config.SignatureNotations, sh.SignContext.getNotation())
+	}
+	var signatures bytes.Buffer
+	for _, entity := range sh.SignKeyRing.isolatedEntities() {
+		key, ok := entity.SigningKey(config.Now(), config)
+		if !ok || key.PrivateKey == nil || key.PrivateKey.Encrypted {
+			return nil, errors.New("gopenpgp: no signing key found for entity")
+		}
+		sigLifetimeSecs := config.SigLifetime()
+		sig := &packet.Signature{
+			Version:           key.PrivateKey.Version,
+			SigType:           packet.SignatureType(constants.SigTypeTimestamp),
+			PubKeyAlgo:        key.PrivateKey.PubKeyAlgo,
+			Hash:              hashAlgorithm,
+			CreationTime:      config.Now(),
+			IssuerKeyId:       &key.PrivateKey.KeyId,
+			IssuerFingerprint: key.PrivateKey.Fingerprint,
+			Notations:         config.Notations(),
+			SigLifetimeSecs:   &sigLifetimeSecs,
+		}
+		signed, err := sig.PrepareSign(config)
+		if err != nil {
+			return nil, errors.Wrap(err, "gopenpgp: signing timestamp failed")
+		}
+		_, _ = signed.Write(digest)
+		if err := sig.Sign(signed, key.PrivateKey, config); err != nil {
+			return nil, errors.Wrap(err, "gopenpgp: signing timestamp failed")
+		}
+		if err := sig.Serialize(&signatures); err != nil {
+			return nil, errors.Wrap(err, "gopenpgp: serializing timestamp signature failed")
+		}
+	}
+	if encoding == Base64 {
+		return encodeBase64(signatures.Bytes()), nil
+	}
+	if !armorOutput(encoding) {
+		return signatures.Bytes(), nil
+	}
+	var armored bytes.Buffer
+	armorWriter, err := armorHelper.ArmorWriterWithOptions(&armored, constants.PGPSignatureHeader, &armorHelper.Options{
+		Headers:      sh.ArmorHeaders,
+		LineLength:   sh.ArmorLineLength,
+		OmitChecksum: !sh.armorChecksumRequired(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := armorWriter.Write(signatures.Bytes()); err != nil {
+		return nil, err
+	}
+	if err := armorWriter.Close(); err != nil {
+		return nil, err
+	}
+	return armored.Bytes(), nil
+}
+
+// verifyTimestamp verifies the timestamp signatures over the digest of data.
+func (vh *verifyHandle) verifyTimestamp(data, signature []byte, encoding int8) (*VerifyResult, error) {
+	vh = vh.withKeyResolver()
+	signatureReader, unarmor := unarmorInput(encoding, bytes.NewReader(signature))
+	if unarmor {
+		block, err := armor.Decode(signatureReader)
+		if err != nil {
+			return nil, errors.Wrap(err, "gopenpgp: unarmor failed")
+		}
+		signatureReader = block.Body
+	}
+	config := vh.profile.SignConfig()
+	verifyTime := vh.clock().Unix()
+	config.Time = NewConstantClock(verifyTime)
+	config.KnownNotations = knownNotations(vh.VerificationContext, vh.KnownNotations)
+	md := &openpgp.MessageDetails{
+		IsSigned:   true,
+		IsVerified: true,
+	}
+	digests := make(map[crypto.Hash][]byte)
+	packets := packet.NewReader(signatureReader)
+	for {
+		p, err := packets.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "gopenpgp: parsing timestamp signature failed")
+		}
+		sig, ok := p.(*packet.Signature)
+		if !ok {
+			continue
+		}
+		candidate := &openpgp.SignatureCandidate{
+			SigType:           sig.SigType,
+			HashAlgorithm:     sig.Hash,
+			PubKeyAlgo:        sig.PubKeyAlgo,
+			IssuerFingerprint: sig.IssuerFingerprint,
+			Salt:              sig.Salt(),
+			CorrespondingSig:  sig,
+		}
+		if sig.IssuerKeyId != nil {
+			candidate.IssuerKeyId = *sig.IssuerKeyId
+		}
+		candidate.SignatureError = vh.verifyTimestampCandidate(candidate, data, digests, config)
+		md.SignatureCandidates = append(md.SignatureCandidates, candidate)
+	}
+	if len(md.SignatureCandidates) == 0 {
+		return nil, errors.New("gopenpgp: no timestamp signature found")
+	}
+	return createVerifyResult(
+		md,
+		vh.VerifyKeyRing,
+		vh.VerificationContext,
+		verifyTime,
+		vh.DisableVerifyTimeCheck,
+		vh.AllowedClockSkew,
+		vh.signaturePolicy(),
+	)
+}
+
+// verifyTimestampCandidate checks a timestamp signature like go-crypto checks message signatures.
+// The signing key of the candidate is set if it is known.
+func (vh *verifyHandle) verifyTimestampCandidate(
+	candidate *openpgp.SignatureCandidate,
+	data []byte,
+	digests map[crypto.Hash][]byte,
+	config *packet.Config,
+) error {
+	sig := candidate.CorrespondingSig
+	if sig.IssuerKeyId == nil {
+		return pgpErrors.ErrUnknownIssuer
+	}
+	entities := keyRings{vh.VerifyKeyRing}.EntitiesById(*sig.IssuerKeyId)
+	if len(entities) == 0 {
+		return pgpErrors.ErrUnknownIssuer
+	}
+	candidate.SignedByEntity = entities[0]
+	key, ok := candidate.SignedByEntity.SigningKeyById(sig.CreationTime, *sig.IssuerKeyId, config)
+	if !ok {
+		return errors.New("gopenpgp: signing key is not valid at the timestamp")
+	}
+	candidate.SignedBy = &key
+	if sig.SigType != packet.SignatureType(constants.SigTypeTimestamp) {
+		return errors.New("gopenpgp: signature is not a timestamp signature")
+	}
+	if config.RejectHashAlgorithm(sig.Hash) {
+		return errors.Errorf("gopenpgp: insecure hash algorithm %s", sig.Hash)
+	}
+	digest, ok := digests[sig.Hash]
+	if !ok {
+		var err error
+		if digest, err = timestampDigest(bytes.NewReader(data), sig.Hash); err != nil {
+			return err
+		}
+		digests[sig.Hash] = digest
+	}
+	signed, err := sig.PrepareVerify()
+	if err != nil {
+		return err
+	}
+	_, _ = signed.Write(digest)
+	if err := key.PublicKey.VerifySignature(signed, sig); err != nil {
+		return err
+	}
+	if key.PublicKey.CreationTime.After(sig.CreationTime) {
+		return pgpErrors.ErrSignatureOlderThanKey
+	}
+	for _, notation := range sig.Notations {
+		if notation.IsCritical && !config.KnownNotation(notation.Name) {
+			return errors.Errorf("gopenpgp: unknown critical notation %s", notation.Name)
+		}
+	}
+	if sig.SigExpired(config.Now()) {
+		return pgpErrors.ErrSignatureExpired
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package crypto
+
+import (
+	"crypto"
+
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/pkg/errors"
+)
+
+// VerificationPolicy decides if an otherwise valid signature is accepted,
+// e.g., to refuse signatures with weak algorithms.
+// Not supported on go-mobile clients.
+type VerificationPolicy interface {
+	// CheckSignature is called for each cryptographically valid signature with the
+	// key that created it. A returned error refuses the signature.
+	CheckSignature(signature *packet.Signature, signingKey *packet.PublicKey) error
+}
+
+// AlgorithmPolicy is a VerificationPolicy that refuses signatures
+// based on their hash algorithm and the algorithm parameters of the signing key.
+// Not supported on go-mobile clients.
+type AlgorithmPolicy struct {
+	// RejectedHashes contains the hash algorithms of refused signatures.
+	RejectedHashes []crypto.Hash
+	// MinRSABits is the minimum bit length of RSA signing keys.
+	// If zero, RSA keys of all lengths are accepted.
+	MinRSABits int
+	// RejectedCurves contains the curves of refused ECDSA, EdDSA, and ECDH signing keys.
+	RejectedCurves []packet.Curve
+}
+
+// DefaultAlgorithmPolicy returns an AlgorithmPolicy that refuses signatures
+// with MD5, SHA-1, or RIPEMD-160 hashes, from RSA keys shorter than 2048 bits,
+// or from secp256k1 keys.
+// Not supported on go-mobile clients.
+func DefaultAlgorithmPolicy() *AlgorithmPolicy {
+	return &AlgorithmPolicy{
+		RejectedHashes: []crypto.Hash{crypto.MD5, crypto.SHA1, crypto.RIPEMD160},
+		MinRSABits:     2048,
+		RejectedCurves: []packet.Curve{packet.CurveSecP256k1},
+	}
+}
+
+// CheckSignature implements the VerificationPolicy interface.
+func (policy *AlgorithmPolicy) CheckSignature(signature *packet.Signature, signingKey *packet.PublicKey) error {
+	for _, hash := range policy.RejectedHashes {
+		if signature.Hash == hash {
+			return errors.Errorf("gopenpgp: signature hash algorithm %s is rejected", hash)
+		}
+	}
+	switch signingKey.PubKeyAlgo {
+	case packet.PubKeyAlgoRSA, packet.PubKeyAlgoRSASignOnly:
+		bitLength, err := signingKey.BitLength()
+		if err != nil {
+			return errors.Wrap(err, "gopenpgp: unable to determine the signing key length")
+		}
+		if int(bitLength) < policy.MinRSABits {
+			return errors.Errorf("gopenpgp: rsa signing key of %d bits is too short", bitLength)
+		}
+	case packet.PubKeyAlgoECDSA, packet.PubKeyAlgoEdDSA, packet.PubKeyAlgoECDH:
+		curve, err := signingKey.Curve()
+		if err != nil {
+			return errors.Wrap(err, "gopenpgp: unable to determine the signing key curve")
+		}
+		for _, rejected := range policy.RejectedCurves {
+			if curve == rejected {
+				return errors.Errorf("gopenpgp: signing key curve %s is rejected", curve)
+			}
+		}
+	}
+	return nil
+}
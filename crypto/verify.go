@@ -1,5 +1,7 @@
 package crypto
 
+import "context"
+
 // PGPVerify is an interface for verifying detached signatures with GopenPGP.
 type PGPVerify interface {
 	// VerifyingReader wraps a reader with a signature verify reader.
@@ -7,24 +9,29 @@ type PGPVerify interface {
 	// with (VerifyDataReader).VerifySignature().
 	// Note that an error is only returned if it is not a signature error.
 	// The encoding indicates if the input signature message should be unarmored or not,
-	// i.e., Bytes/Armor/Auto where Auto tries to detect it automatically.
+	// i.e., Bytes/Armor/Base64/Auto where Auto tries to detect it automatically.
 	// If detachedData is nil, signatureMessage is treated as an inline signature message.
 	// Thus, it is expected that signatureMessage contains the data to be verified.
 	// If detachedData is not nil, signatureMessage must contain a detached signature,
 	// which is verified against the detachedData.
 	VerifyingReader(detachedData, signatureMessage Reader, encoding int8) (*VerifyDataReader, error)
+	// VerifyingReaderContext is like VerifyingReader but aborts the verification once ctx is done.
+	// Reads from the returned VerifyDataReader then fail with ctx.Err(),
+	// even if a read from the inputs blocks.
+	// Not supported on go-mobile clients.
+	VerifyingReaderContext(ctx context.Context, detachedData, signatureMessage Reader, encoding int8) (*VerifyDataReader, error)
 	// VerifyDetached verifies a detached signature pgp message
 	// and returns a VerifyResult. The VerifyResult can be checked for failure
 	// and allows access to information about the signatures.
 	// Note that an error is only returned if it is not a signature error.
 	// The encoding indicates if the input signature message should be unarmored or not,
-	// i.e., Bytes/Armor/Auto where Auto tries to detect it automatically.
+	// i.e., Bytes/Armor/Base64/Auto where Auto tries to detect it automatically.
 	VerifyDetached(data []byte, signature []byte, encoding int8) (*VerifyResult, error)
 	// VerifyInline verifies an inline signed pgp message
 	// and returns a VerifiedDataResult. The VerifiedDataResult can be checked for failure,
 	// allows access to information about the signatures, and includes the plain message.
 	// Note that an error is only returned if it is not a signature error.
-	// The encoding indicates if the input message should be unarmored or not, i.e., Bytes/Armor/Auto
+	// The encoding indicates if the input message should be unarmored or not, i.e., Bytes/Armor/Base64/Auto
 	// where Auto tries to detect it automatically.
 	VerifyInline(message []byte, encoding int8) (*VerifiedDataResult, error)
 	// VerifyCleartext verifies an armored cleartext message
@@ -32,4 +39,20 @@ type PGPVerify interface {
 	// and allows access the contained message
 	// Note that an error is only returned if it is not a signature error.
 	VerifyCleartext(cleartext []byte) (*VerifyCleartextResult, error)
+	// VerifyTimestamp verifies timestamp signatures (type 0x40) over the digest of data
+	// and returns a VerifyResult. The signature creation time in the result is the
+	// time at which the data provably existed.
+	// Note that an error is only returned if it is not a signature error.
+	// The encoding indicates if the input signature should be unarmored or not,
+	// i.e., Bytes/Armor/Base64/Auto where Auto tries to detect it automatically.
+	VerifyTimestamp(data []byte, signature []byte, encoding int8) (*VerifyResult, error)
+	// VerifyingCleartextReader wraps an armored cleartext message with a reader
+	// that outputs the contained message while reading it.
+	// Once all data is read from the returned reader, the signature can be verified
+	// with (VerifyDataReader).VerifySignature().
+	// In contrast to VerifyCleartext, the message does not have to fit into memory.
+	// Salted v6 signatures are not supported, since their salt is only known at the end,
+	// and are reported as failed.
+	// Note that an error is only returned if it is not a signature error.
+	VerifyingCleartextReader(cleartext Reader) (*VerifyDataReader, error)
 }
@@ -2,24 +2,57 @@ package crypto
 
 import (
 	"bytes"
+	"context"
 	"io"
 
 	"github.com/ProtonMail/go-crypto/openpgp/armor"
 	"github.com/ProtonMail/go-crypto/openpgp/clearsign"
 	"github.com/ProtonMail/go-crypto/openpgp/packet"
 	openpgp "github.com/ProtonMail/go-crypto/openpgp/v2"
-	"github.com/ProtonMail/gopenpgp/v3/constants"
 	"github.com/ProtonMail/gopenpgp/v3/internal"
 	"github.com/pkg/errors"
 )
 
 type verifyHandle struct {
-	VerifyKeyRing                *KeyRing
-	VerificationContext          *VerificationContext
-	DisableVerifyTimeCheck       bool
+	VerifyKeyRing *KeyRing
+	// VerificationKeyResolver looks up the verification keys by key id during each verification,
+	// in addition to the keys in VerifyKeyRing.
+	VerificationKeyResolver KeyResolver
+	VerificationContext     *VerificationContext
+	// RequireAllSignatures indicates that the verification only succeeds
+	// if all signatures in the message are valid.
+	RequireAllSignatures bool
+	// RequiredSigners and RequiredSignatureThreshold indicate that the verification only succeeds
+	// if at least RequiredSignatureThreshold distinct keys in RequiredSigners have a valid signature.
+	// If RequiredSigners is nil, any valid signature is accepted.
+	RequiredSigners            *KeyRing
+	RequiredSignatureThreshold int
+	// RequiredIssuerFingerprint indicates that the verification only considers the signatures
+	// of the key with this fingerprint, and only succeeds if one of them is valid.
+	// If nil, all signatures are considered.
+	RequiredIssuerFingerprint []byte
+	// VerificationPolicy refuses otherwise valid signatures, e.g., with weak algorithms.
+	// If nil, all valid signatures are accepted.
+	VerificationPolicy VerificationPolicy
+	// KnownNotations contains the names of critical notations that are accepted in signatures.
+	// Signatures with unknown critical notations are invalid.
+	KnownNotations []string
+	// KeyLookup fetches the keys of signers that are not among the verification keys.
+	KeyLookup KeyLookup
+	// WebOfTrust computes the validity of the signers.
+	WebOfTrust *WebOfTrust
+	// KeyTransparency attests the inclusion of the signers in a key transparency log.
+	KeyTransparency        KeyTransparencyVerifier
+	DisableVerifyTimeCheck bool
+	// AllowedClockSkew is the tolerance in seconds for the creation and expiration time
+	// of signatures, e.g., to accept signatures from clients with slightly fast clocks.
+	AllowedClockSkew             int64
 	DisableStrictMessageParsing  bool
 	DisableAutomaticTextSanitize bool
 	IsUTF8                       bool
+	ProgressListener             ProgressListener
+	Observer                     OperationObserver
+	Logger                       Logger
 	clock                        Clock
 	profile                      SignProfile
 }
@@ -40,12 +73,35 @@ func defaultVerifyHandle(profile SignProfile, clock Clock) *verifyHandle {
 // with (VerifyDataReader).VerifySignature().
 // Note that an error is only returned if it is not a signature error.
 // The encoding indicates if the input signature message should be unarmored or not,
-// i.e., Bytes/Armor/Auto where Auto tries to detect it automatically.
+// i.e., Bytes/Armor/Base64/Auto where Auto tries to detect it automatically.
 // If detachedData is nil, signatureMessage is treated as an inline signature message.
 // Thus, it is expected that signatureMessage contains the data to be verified.
 // If detachedData is not nil, signatureMessage must contain a detached signature,
 // which is verified against the detachedData.
 func (vh *verifyHandle) VerifyingReader(detachedData, signatureMessage Reader, encoding int8) (reader *VerifyDataReader, err error) {
+	if vh.Observer != nil {
+		observation := startObservation(vh.Observer, OperationVerify, "")
+		handle := *vh
+		handle.Observer = nil
+		reader, err = handle.VerifyingReader(detachedData, signatureMessage, encoding)
+		return observeReader(observation, reader, err)
+	}
+	vh = vh.withKeyResolver()
+	if vh.ProgressListener != nil {
+		progress := &progressTracker{listener: vh.ProgressListener}
+		handle := *vh
+		handle.ProgressListener = nil
+		if detachedData != nil {
+			detachedData = &progressReader{reader: detachedData, count: progress.addIn}
+		}
+		signatureMessage = &progressReader{reader: signatureMessage, count: progress.addIn}
+		reader, err = handle.VerifyingReader(detachedData, signatureMessage, encoding)
+		if err != nil {
+			return nil, err
+		}
+		reader.internalReader = &progressReader{reader: reader.internalReader, count: progress.addOut}
+		return reader, nil
+	}
 	var armored bool
 	signatureMessage, armored = unarmorInput(encoding, signatureMessage)
 	if armored {
@@ -70,20 +126,51 @@ func (vh *verifyHandle) VerifyingReader(detachedData, signatureMessage Reader, e
 	return
 }
 
+// VerifyingReaderContext is like VerifyingReader but aborts the verification once ctx is done.
+// Reads from the returned VerifyDataReader then fail with ctx.Err(),
+// even if a read from the inputs blocks.
+// Not supported on go-mobile clients.
+func (vh *verifyHandle) VerifyingReaderContext(ctx context.Context, detachedData, signatureMessage Reader, encoding int8) (*VerifyDataReader, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	reader, err := vh.VerifyingReader(newContextReader(ctx, detachedData), newContextReader(ctx, signatureMessage), encoding)
+	if err != nil {
+		return nil, err
+	}
+	reader.internalReader = &contextCheckReader{ctx: ctx, reader: reader.internalReader}
+	return reader, nil
+}
+
 // VerifyDetached verifies a detached signature pgp message
 // and returns a VerifyResult. The VerifyResult can be checked for failure
 // and allows access to information about the signatures.
 // Note that an error is only returned if it is not a signature error.
 // The encoding indicates if the input signature message should be unarmored or not,
-// i.e., Bytes/Armor/Auto where Auto tries to detect it automatically.
+// i.e., Bytes/Armor/Base64/Auto where Auto tries to detect it automatically.
 func (vh *verifyHandle) VerifyDetached(data, signature []byte, encoding int8) (verifyResult *VerifyResult, err error) {
+	if vh.KeyLookup != nil {
+		handle := *vh
+		handle.KeyLookup = nil
+		verifyResult, err = handle.VerifyDetached(data, signature, encoding)
+		if err != nil {
+			return nil, err
+		}
+		if handle.VerifyKeyRing, err = lookupUnknownSigners(vh.KeyLookup, vh.VerifyKeyRing, verifyResult); err != nil {
+			return nil, err
+		}
+		if handle.VerifyKeyRing == nil {
+			return verifyResult, nil
+		}
+		return handle.VerifyDetached(data, signature, encoding)
+	}
 	signatureMessageReader := bytes.NewReader(signature)
 	detachedDataReader := bytes.NewReader(data)
 	ptReader, err := vh.VerifyingReader(detachedDataReader, signatureMessageReader, encoding)
 	if err != nil {
 		return nil, errors.Wrap(err, "gopenpgp: verifying signature failed")
 	}
-	_, err = io.Copy(io.Discard, ptReader)
+	_, err = internal.Copy(io.Discard, ptReader)
 	if err != nil {
 		return nil, errors.Wrap(err, "gopenpgp: reading data to verify signature failed")
 	}
@@ -94,9 +181,24 @@ func (vh *verifyHandle) VerifyDetached(data, signature []byte, encoding int8) (v
 // and returns a VerifiedDataResult. The VerifiedDataResult can be checked for failure,
 // allows access to information about the signatures, and includes the plain message.
 // Note that an error is only returned if it is not a signature error.
-// The encoding indicates if the input message should be unarmored or not, i.e., Bytes/Armor/Auto
+// The encoding indicates if the input message should be unarmored or not, i.e., Bytes/Armor/Base64/Auto
 // where Auto tries to detect it automatically.
 func (vh *verifyHandle) VerifyInline(message []byte, encoding int8) (verifyDataResult *VerifiedDataResult, err error) {
+	if vh.KeyLookup != nil {
+		handle := *vh
+		handle.KeyLookup = nil
+		verifyDataResult, err = handle.VerifyInline(message, encoding)
+		if err != nil {
+			return nil, err
+		}
+		if handle.VerifyKeyRing, err = lookupUnknownSigners(vh.KeyLookup, vh.VerifyKeyRing, &verifyDataResult.VerifyResult); err != nil {
+			return nil, err
+		}
+		if handle.VerifyKeyRing == nil {
+			return verifyDataResult, nil
+		}
+		return handle.VerifyInline(message, encoding)
+	}
 	var ptReader *VerifyDataReader
 	messageReader := bytes.NewReader(message)
 	ptReader, err = vh.VerifyingReader(nil, messageReader, encoding)
@@ -112,9 +214,10 @@ func (vh *verifyHandle) VerifyInline(message []byte, encoding int8) (verifyDataR
 		return nil, errors.Wrap(err, "gopenpgp: verifying signature failed")
 	}
 	verifyDataResult = &VerifiedDataResult{
-		data:         data,
-		metadata:     ptReader.GetMetadata(),
-		VerifyResult: *verifyResult,
+		data:          data,
+		metadata:      ptReader.GetMetadata(),
+		VerifyResult:  *verifyResult,
+		passwordIndex: noPasswordIndex,
 	}
 	return
 }
@@ -123,16 +226,136 @@ func (vh *verifyHandle) VerifyInline(message []byte, encoding int8) (verifyDataR
 // The VerifyCleartextResult can be checked for failure and allows access the contained message.
 // Note that an error is only returned if it is not a signature error.
 func (vh *verifyHandle) VerifyCleartext(cleartext []byte) (*VerifyCleartextResult, error) {
+	if vh.Observer != nil {
+		observation := startObservation(vh.Observer, OperationVerify, "")
+		handle := *vh
+		handle.Observer = nil
+		result, err := handle.VerifyCleartext(cleartext)
+		if result != nil {
+			observation.count(len(result.cleartext))
+			if result.selectedSignature != nil && result.selectedSignature.Signature != nil {
+				observation.info.Algorithm = result.selectedSignature.Signature.Hash.String()
+			}
+		}
+		observation.finish(err)
+		return result, err
+	}
+	if vh.KeyLookup != nil {
+		handle := *vh
+		handle.KeyLookup = nil
+		result, err := handle.verifyCleartext(cleartext)
+		if err != nil {
+			return nil, err
+		}
+		if handle.VerifyKeyRing, err = lookupUnknownSigners(vh.KeyLookup, vh.VerifyKeyRing, &result.VerifyResult); err != nil {
+			return nil, err
+		}
+		if handle.VerifyKeyRing == nil {
+			return result, nil
+		}
+		return handle.verifyCleartext(cleartext)
+	}
 	return vh.verifyCleartext(cleartext)
 }
 
+// VerifyTimestamp verifies timestamp signatures (type 0x40) over the digest of data
+// and returns a VerifyResult. The signature creation time in the result is the
+// time at which the data provably existed.
+// Note that an error is only returned if it is not a signature error.
+// The encoding indicates if the input signature should be unarmored or not,
+// i.e., Bytes/Armor/Base64/Auto where Auto tries to detect it automatically.
+func (vh *verifyHandle) VerifyTimestamp(data, signature []byte, encoding int8) (*VerifyResult, error) {
+	if vh.KeyLookup != nil {
+		handle := *vh
+		handle.KeyLookup = nil
+		result, err := handle.verifyTimestamp(data, signature, encoding)
+		if err != nil {
+			return nil, err
+		}
+		if handle.VerifyKeyRing, err = lookupUnknownSigners(vh.KeyLookup, vh.VerifyKeyRing, result); err != nil {
+			return nil, err
+		}
+		if handle.VerifyKeyRing == nil {
+			return result, nil
+		}
+		return handle.verifyTimestamp(data, signature, encoding)
+	}
+	return vh.verifyTimestamp(data, signature, encoding)
+}
+
+// VerifyingCleartextReader wraps an armored cleartext message with a reader
+// that outputs the contained message while reading it.
+// Once all data is read from the returned reader, the signature can be verified
+// with (VerifyDataReader).VerifySignature().
+// In contrast to VerifyCleartext, the message does not have to fit into memory.
+// Salted v6 signatures are not supported, since their salt is only known at the end,
+// and are reported as failed.
+// Note that an error is only returned if it is not a signature error.
+func (vh *verifyHandle) VerifyingCleartextReader(cleartext Reader) (*VerifyDataReader, error) {
+	if vh.Observer != nil {
+		observation := startObservation(vh.Observer, OperationVerify, "")
+		handle := *vh
+		handle.Observer = nil
+		reader, err := handle.VerifyingCleartextReader(cleartext)
+		return observeReader(observation, reader, err)
+	}
+	vh = vh.withKeyResolver()
+	if vh.ProgressListener != nil {
+		progress := &progressTracker{listener: vh.ProgressListener}
+		handle := *vh
+		handle.ProgressListener = nil
+		reader, err := handle.VerifyingCleartextReader(&progressReader{reader: cleartext, count: progress.addIn})
+		if err != nil {
+			return nil, err
+		}
+		reader.internalReader = &progressReader{reader: reader.internalReader, count: progress.addOut}
+		return reader, nil
+	}
+	config := vh.profile.SignConfig()
+	verifyTime := vh.clock().Unix()
+	config.Time = NewConstantClock(verifyTime)
+	config.KnownNotations = knownNotations(vh.VerificationContext, vh.KnownNotations)
+	details := &openpgp.MessageDetails{IsSigned: true}
+	reader, err := newCleartextReader(cleartext, vh.VerifyKeyRing, config, details)
+	if err != nil {
+		return nil, err
+	}
+	return &VerifyDataReader{
+		details:             details,
+		internalReader:      reader,
+		verifyKeyRing:       vh.VerifyKeyRing,
+		verifyTime:          verifyTime,
+		disableTimeCheck:    vh.DisableVerifyTimeCheck,
+		clockSkew:           vh.AllowedClockSkew,
+		verificationContext: vh.VerificationContext,
+		passwordIndex:       noPasswordIndex,
+		signaturePolicy:     vh.signaturePolicy(),
+		logger:              vh.Logger,
+	}, nil
+}
+
 // --- Private logic functions
 
 func (vh *verifyHandle) validate() error {
-	if vh.VerifyKeyRing == nil {
+	if vh.VerifyKeyRing == nil && vh.VerificationKeyResolver == nil && vh.KeyLookup == nil {
 		return errors.New("gopenpgp: no verification key provided")
 	}
-	return nil
+	if vh.AllowedClockSkew < 0 {
+		return errors.New("gopenpgp: allowed clock skew must not be negative")
+	}
+	return vh.signaturePolicy().validate()
+}
+
+func (vh *verifyHandle) signaturePolicy() *signaturePolicy {
+	return newSignaturePolicy(
+		vh.RequireAllSignatures,
+		vh.RequiredSigners,
+		vh.RequiredSignatureThreshold,
+		vh.VerificationPolicy,
+		vh.WebOfTrust,
+		vh.KeyTransparency,
+		vh.RequiredIssuerFingerprint,
+	)
 }
 
 // verifyDetachedSignature verifies if a detached signature is valid with the entity list.
@@ -145,7 +368,7 @@ func (vh *verifyHandle) verifyDetachedSignature(
 	if err != nil {
 		return nil, errors.Wrap(err, "gopenpgp: verify signature failed")
 	}
-	_, err = io.Copy(io.Discard, ptReader)
+	_, err = internal.Copy(io.Discard, ptReader)
 	if err != nil {
 		return nil, errors.Wrap(err, "gopenpgp: reading all data from plaintext reader failed")
 	}
@@ -160,12 +383,10 @@ func (vh *verifyHandle) verifyingReader(
 	config.CheckPacketSequence = &checkPacketSequence
 	verifyTime := vh.clock().Unix()
 	config.Time = NewConstantClock(verifyTime)
-	if vh.VerificationContext != nil {
-		config.KnownNotations = map[string]bool{constants.SignatureContextName: true}
-	}
+	config.KnownNotations = knownNotations(vh.VerificationContext, vh.KnownNotations)
 	md, err := openpgp.ReadMessage(
 		signatureMessage,
-		vh.VerifyKeyRing.getEntities(),
+		keyRings{vh.VerifyKeyRing},
 		nil,
 		config,
 	)
@@ -173,13 +394,16 @@ func (vh *verifyHandle) verifyingReader(
 		return nil, errors.Wrap(err, "gopenpgp: initialize signature reader failed")
 	}
 	return &VerifyDataReader{
-		md,
-		md.UnverifiedBody,
-		vh.VerifyKeyRing,
-		verifyTime,
-		vh.DisableVerifyTimeCheck,
-		false,
-		vh.VerificationContext,
+		details:             md,
+		internalReader:      md.UnverifiedBody,
+		verifyKeyRing:       vh.VerifyKeyRing,
+		verifyTime:          verifyTime,
+		disableTimeCheck:    vh.DisableVerifyTimeCheck,
+		clockSkew:           vh.AllowedClockSkew,
+		verificationContext: vh.VerificationContext,
+		passwordIndex:       noPasswordIndex,
+		signaturePolicy:     vh.signaturePolicy(),
+		logger:              vh.Logger,
 	}, nil
 }
 
@@ -187,19 +411,29 @@ func (vh *verifyHandle) verifyingDetachedReader(
 	data Reader,
 	signature Reader,
 ) (*VerifyDataReader, error) {
-	return verifyingDetachedReader(
+	config := vh.profile.SignConfig()
+	config.KnownNotations = knownNotations(vh.VerificationContext, vh.KnownNotations)
+	reader, err := verifyingDetachedReader(
 		data,
 		signature,
 		vh.VerifyKeyRing,
 		vh.VerificationContext,
 		vh.DisableVerifyTimeCheck,
+		vh.AllowedClockSkew,
 		vh.DisableAutomaticTextSanitize,
-		vh.profile.SignConfig(),
+		config,
 		vh.clock,
+		vh.signaturePolicy(),
 	)
+	if err != nil {
+		return nil, err
+	}
+	reader.logger = vh.Logger
+	return reader, nil
 }
 
 func (vh *verifyHandle) verifyCleartext(cleartext []byte) (*VerifyCleartextResult, error) {
+	vh = vh.withKeyResolver()
 	block, rest := clearsign.Decode(cleartext)
 	if block == nil {
 		return nil, errors.New("gopenpgp: not able to parse cleartext message")
@@ -231,27 +465,28 @@ func verifyingDetachedReader(
 	verifyKeyRing *KeyRing,
 	verificationContext *VerificationContext,
 	disableVerifyTimeCheck bool,
+	clockSkew int64,
 	disableAutomaticTextSanitize bool,
 	config *packet.Config,
 	clock Clock,
+	policy *signaturePolicy,
 ) (*VerifyDataReader, error) {
 	if config == nil {
 		config = &packet.Config{}
 	}
 	verifyTime := clock().Unix()
 	config.Time = NewConstantClock(verifyTime)
-	if verificationContext != nil {
-		config.KnownNotations = map[string]bool{constants.SignatureContextName: true}
-	}
+	hashReader := newParallelHashReader(data)
 	md, err := openpgp.VerifyDetachedSignatureReader(
-		verifyKeyRing.getEntities(),
-		data,
+		keyRings{verifyKeyRing},
+		hashReader,
 		signature,
 		config,
 	)
 	if err != nil {
 		return nil, errors.Wrap(err, "gopenpgp: verify signature reader failed")
 	}
+	hashReader.setCandidates(md.SignatureCandidates)
 	internalReader := md.UnverifiedBody
 	if len(md.SignatureCandidates) > 0 &&
 		!disableAutomaticTextSanitize &&
@@ -259,12 +494,14 @@ func verifyingDetachedReader(
 		internalReader = internal.NewSanitizeReader(internalReader)
 	}
 	return &VerifyDataReader{
-		md,
-		internalReader,
-		verifyKeyRing,
-		verifyTime,
-		disableVerifyTimeCheck,
-		false,
-		verificationContext,
+		details:             md,
+		internalReader:      internalReader,
+		verifyKeyRing:       verifyKeyRing,
+		verifyTime:          verifyTime,
+		disableTimeCheck:    disableVerifyTimeCheck,
+		clockSkew:           clockSkew,
+		verificationContext: verificationContext,
+		passwordIndex:       noPasswordIndex,
+		signaturePolicy:     policy,
 	}, nil
 }
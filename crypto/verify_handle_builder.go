@@ -1,5 +1,11 @@
 package crypto
 
+import (
+	"encoding/hex"
+
+	"github.com/pkg/errors"
+)
+
 // VerifyHandleBuilder configures a VerifyHandle handle.
 type VerifyHandleBuilder struct {
 	handle       *verifyHandle
@@ -24,13 +30,17 @@ func (vhb *VerifyHandleBuilder) VerificationKeys(keys *KeyRing) *VerifyHandleBui
 
 // VerificationKey sets the public key for verifying the signatures.
 func (vhb *VerifyHandleBuilder) VerificationKey(key *Key) *VerifyHandleBuilder {
-	var err error
-	if vhb.handle.VerifyKeyRing == nil {
-		vhb.handle.VerifyKeyRing, err = NewKeyRing(key)
-	} else {
-		err = vhb.handle.VerifyKeyRing.AddKey(key)
-	}
-	vhb.err = err
+	vhb.handle.VerifyKeyRing, vhb.err = keyRingWithKey(vhb.handle.VerifyKeyRing, key)
+	return vhb
+}
+
+// VerificationKeyResolver sets a resolver that looks up the verification keys
+// by the key ids of the signatures during each verification, e.g., in a database,
+// in addition to the keys set with VerificationKeys.
+// See KeyResolverFunc to use a function.
+// Not supported on go-mobile clients.
+func (vhb *VerifyHandleBuilder) VerificationKeyResolver(resolver KeyResolver) *VerifyHandleBuilder {
+	vhb.handle.VerificationKeyResolver = resolver
 	return vhb
 }
 
@@ -41,6 +51,91 @@ func (vhb *VerifyHandleBuilder) VerificationContext(verifyContext *VerificationC
 	return vhb
 }
 
+// RequireAllSignatures indicates that the signature verification only succeeds
+// if all signatures in the message are valid, e.g., if a message is signed with multiple keys.
+// Signatures from keys that are not among the verification keys are invalid.
+// If not set, the verification succeeds if at least one signature is valid.
+func (vhb *VerifyHandleBuilder) RequireAllSignatures() *VerifyHandleBuilder {
+	vhb.handle.RequireAllSignatures = true
+	return vhb
+}
+
+// RequireSignatures indicates that the signature verification only succeeds
+// if at least threshold distinct keys in signers have a valid signature, i.e., a k-of-n policy.
+// The signers must also be among the verification keys.
+// If not set, the verification succeeds if at least one signature is valid.
+func (vhb *VerifyHandleBuilder) RequireSignatures(threshold int, signers *KeyRing) *VerifyHandleBuilder {
+	vhb.handle.RequiredSigners = signers
+	vhb.handle.RequiredSignatureThreshold = threshold
+	return vhb
+}
+
+// RequireSignatureFrom indicates that the signature verification only considers the signatures
+// of the key with the hex encoded fingerprint, e.g., to pin the key of a correspondent.
+// Signatures of other keys are ignored, and the verification fails with status
+// constants.SIGNATURE_NO_VERIFIER if the message has no signature of the key.
+// The fingerprint is the one of the primary key or of the signing subkey,
+// and the key must be among the verification keys.
+// Cannot be combined with RequireSignatures.
+func (vhb *VerifyHandleBuilder) RequireSignatureFrom(fingerprint string) *VerifyHandleBuilder {
+	issuerFingerprint, err := hex.DecodeString(fingerprint)
+	if err != nil || (len(issuerFingerprint) != 20 && len(issuerFingerprint) != 32) {
+		vhb.err = errors.New("gopenpgp: the required issuer must be a hex encoded v4 or v6 fingerprint")
+		return vhb
+	}
+	vhb.handle.RequiredIssuerFingerprint = issuerFingerprint
+	return vhb
+}
+
+// VerificationPolicy sets a policy that refuses otherwise valid signatures,
+// e.g., signatures with weak hash algorithms or from small RSA keys, see DefaultAlgorithmPolicy.
+// A refused signature results in a signature error with status constants.SIGNATURE_POLICY_VIOLATION.
+// If not set, all valid signatures are accepted.
+// Not supported on go-mobile clients.
+func (vhb *VerifyHandleBuilder) VerificationPolicy(policy VerificationPolicy) *VerifyHandleBuilder {
+	vhb.handle.VerificationPolicy = policy
+	return vhb
+}
+
+// KnownNotation marks the notation name as known, such that signatures with
+// a critical notation of this name are accepted.
+// Signatures with unknown critical notations are invalid.
+// Can be called multiple times to add multiple names.
+func (vhb *VerifyHandleBuilder) KnownNotation(name string) *VerifyHandleBuilder {
+	vhb.handle.KnownNotations = append(vhb.handle.KnownNotations, name)
+	return vhb
+}
+
+// KeyLookup sets a lookup for the keys of signers that are not among the verification keys,
+// e.g., to fetch them from a key server or a database.
+// If a signature is from an unknown signer and the lookup finds its key,
+// the verification is repeated with the found key. See KeyLookupFunc to use a function.
+// Only considered by VerifyInline, VerifyDetached, and VerifyCleartext, since the input
+// of the streaming functions cannot be read twice.
+// Not supported on go-mobile clients.
+func (vhb *VerifyHandleBuilder) KeyLookup(lookup KeyLookup) *VerifyHandleBuilder {
+	vhb.handle.KeyLookup = lookup
+	return vhb
+}
+
+// WebOfTrust sets the web of trust that computes the validity of the signers
+// at the verification time, see (VerifyResult).SignedByValidity.
+// The validity does not affect the verification result.
+func (vhb *VerifyHandleBuilder) WebOfTrust(webOfTrust *WebOfTrust) *VerifyHandleBuilder {
+	vhb.handle.WebOfTrust = webOfTrust
+	return vhb
+}
+
+// KeyTransparency sets the verifier that attests the inclusion of the signers
+// in a key transparency log, including keys found by the KeyLookup,
+// see (VerifyResult).SignedByTransparency.
+// The attestation does not affect the verification result.
+// Not supported on go-mobile clients.
+func (vhb *VerifyHandleBuilder) KeyTransparency(verifier KeyTransparencyVerifier) *VerifyHandleBuilder {
+	vhb.handle.KeyTransparency = verifier
+	return vhb
+}
+
 // VerifyTime sets the verification time to the provided timestamp.
 // If not set, the systems current time is used for signature verification.
 func (vhb *VerifyHandleBuilder) VerifyTime(unixTime int64) *VerifyHandleBuilder {
@@ -57,6 +152,16 @@ func (vhb *VerifyHandleBuilder) Utf8() *VerifyHandleBuilder {
 	return vhb
 }
 
+// AllowedClockSkew sets the tolerance in seconds for the creation and expiration time of signatures.
+// For example, with a skew of 600 seconds, signatures that were created up to ten minutes
+// after the verification time by a client with a fast clock are accepted,
+// as well as signatures that expired up to ten minutes before it.
+// If not set, the times are checked exactly.
+func (vhb *VerifyHandleBuilder) AllowedClockSkew(seconds int64) *VerifyHandleBuilder {
+	vhb.handle.AllowedClockSkew = seconds
+	return vhb
+}
+
 // DisableVerifyTimeCheck disables the check for comparing the signature expiration time
 // against the verification time.
 func (vhb *VerifyHandleBuilder) DisableVerifyTimeCheck() *VerifyHandleBuilder {
@@ -80,6 +185,32 @@ func (vhb *VerifyHandleBuilder) DisableAutomaticTextSanitize() *VerifyHandleBuil
 	return vhb
 }
 
+// ProgressListener sets a listener that is notified about the progress of the verification,
+// i.e., the message and data bytes read and the plaintext bytes produced so far.
+// If not set, no progress is reported.
+func (vhb *VerifyHandleBuilder) ProgressListener(listener ProgressListener) *VerifyHandleBuilder {
+	vhb.handle.ProgressListener = listener
+	return vhb
+}
+
+// Observer sets an observer that is notified once an verification completed,
+// e.g., to export metrics. Overrides the observer set with PGPHandle.WithObserver.
+// If not set, no operations are reported.
+// Not supported on go-mobile clients.
+func (vhb *VerifyHandleBuilder) Observer(observer OperationObserver) *VerifyHandleBuilder {
+	vhb.handle.Observer = observer
+	return vhb
+}
+
+// Logger sets a logger that is called with the status of each signature candidate,
+// e.g., to diagnose interoperability failures. Overrides the logger set with PGPHandle.WithLogger.
+// If not set, nothing is logged.
+// Not supported on go-mobile clients.
+func (vhb *VerifyHandleBuilder) Logger(logger Logger) *VerifyHandleBuilder {
+	vhb.handle.Logger = logger
+	return vhb
+}
+
 // New creates a VerifyHandle and checks that the given
 // combination of parameters is valid. If the parameters are invalid,
 // an error is returned.
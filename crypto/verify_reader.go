@@ -1,9 +1,11 @@
 package crypto
 
 import (
+	"bytes"
 	"io"
 
 	openpgp "github.com/ProtonMail/go-crypto/openpgp/v2"
+	"github.com/ProtonMail/gopenpgp/v3/internal"
 	"github.com/pkg/errors"
 )
 
@@ -11,15 +13,33 @@ import (
 // It further contains additional information about the parsed pgp message where the read
 // data stems from.
 type VerifyDataReader struct {
-	details             *openpgp.MessageDetails
-	internalReader      Reader
-	verifyKeyRing       *KeyRing
-	verifyTime          int64
-	disableTimeCheck    bool
+	details          *openpgp.MessageDetails
+	internalReader   Reader
+	verifyKeyRing    *KeyRing
+	verifyTime       int64
+	disableTimeCheck bool
+	// clockSkew is the tolerance in seconds for the creation and expiration time of signatures.
+	clockSkew           int64
 	readAll             bool
 	verificationContext *VerificationContext
+	// passwordIndex is the index of the password that decrypted the message, or noPasswordIndex.
+	passwordIndex int
+	// missingIntegrityProtection indicates that the message was decrypted from
+	// a legacy data packet without modification detection code.
+	missingIntegrityProtection bool
+	// signaturePolicy defines which signatures must be valid, nil accepts any valid signature.
+	signaturePolicy *signaturePolicy
+	// strictRFC9580 indicates that the message is decrypted with a strict RFC9580 profile,
+	// which reports legacyConstructs and legacy signatures.
+	strictRFC9580    bool
+	legacyConstructs []string
+	// logger is called with the status of each signature candidate.
+	logger Logger
 }
 
+// noPasswordIndex is the password index of messages that are not decrypted with a password.
+const noPasswordIndex = -1
+
 // GetMetadata returns the metadata of the literal data packet that
 // this reader reads from. Can be nil, if the data is not read from
 // a literal data packet.
@@ -52,18 +72,30 @@ func (msg *VerifyDataReader) VerifySignature() (result *VerifyResult, err error)
 	if !msg.readAll {
 		return nil, errors.New("gopenpgp: can't verify the signature until the message reader has been read entirely")
 	}
-	return createVerifyResult(msg.details, msg.verifyKeyRing, msg.verificationContext, msg.verifyTime, msg.disableTimeCheck)
+	result, err = createVerifyResult(
+		msg.details,
+		msg.verifyKeyRing,
+		msg.verificationContext,
+		msg.verifyTime,
+		msg.disableTimeCheck,
+		msg.clockSkew,
+		msg.signaturePolicy,
+	)
+	if err == nil {
+		logSignatureCandidates(msg.logger, msg.operation(), msg.details, result)
+	}
+	return result, err
 }
 
 // ReadAll reads all plaintext data from the reader
 // and returns it as a byte slice.
 func (msg *VerifyDataReader) ReadAll() (plaintext []byte, err error) {
-	return io.ReadAll(msg)
+	return internal.ReadAll(msg)
 }
 
 // DiscardAll reads all data from the reader and discards it.
 func (msg *VerifyDataReader) DiscardAll() (err error) {
-	_, err = io.Copy(io.Discard, msg)
+	_, err = internal.Copy(io.Discard, msg)
 	return err
 }
 
@@ -86,12 +118,49 @@ func (msg *VerifyDataReader) ReadAllAndVerifySignature() (*VerifiedDataResult, e
 		return nil, errors.Wrap(err, "gopenpgp: reading all data from reader failed")
 	}
 	verifyResult, err := msg.VerifySignature()
+	if err != nil {
+		return nil, err
+	}
 	return &VerifiedDataResult{
-		VerifyResult:     *verifyResult,
-		data:             plaintext,
-		metadata:         msg.GetMetadata(),
-		cachedSessionKey: msg.SessionKey(),
-	}, err
+		VerifyResult:               *verifyResult,
+		data:                       plaintext,
+		metadata:                   msg.GetMetadata(),
+		cachedSessionKey:           msg.SessionKey(),
+		passwordIndex:              msg.passwordIndex,
+		missingIntegrityProtection: msg.missingIntegrityProtection,
+		legacyConstructs:           msg.legacyConstructsFound(),
+	}, nil
+}
+
+// IntegrityWarning returns an error if the message was decrypted from a legacy
+// data packet without modification detection code (MDC), which is only accepted with
+// InsecureAllowMissingMDC. Such a plaintext might have been modified by an attacker.
+// Returns nil, if the message is integrity protected or not encrypted.
+func (msg *VerifyDataReader) IntegrityWarning() error {
+	return integrityWarning(msg.missingIntegrityProtection)
+}
+
+// LegacyWarning returns an error listing the legacy constructs of the message, e.g.,
+// v3 key packets, SEIPDv1 data packets, or v4 signatures, if the message is decrypted
+// with a strict RFC9580 profile. Signatures are only listed once the message has been read.
+// Returns nil, if the message has no legacy constructs or the profile is not strict.
+func (msg *VerifyDataReader) LegacyWarning() error {
+	return legacyWarning(msg.legacyConstructsFound())
+}
+
+func (msg *VerifyDataReader) legacyConstructsFound() []string {
+	if !msg.strictRFC9580 {
+		return nil
+	}
+	legacyConstructs := append([]string{}, msg.legacyConstructs...)
+	return append(legacyConstructs, legacySignatures(msg.details)...)
+}
+
+// PasswordIndex returns the index of the password that decrypted the message,
+// in the order the passwords were set on the decryption handle.
+// Returns -1, if the message was not decrypted with a password.
+func (msg *VerifyDataReader) PasswordIndex() int {
+	return msg.passwordIndex
 }
 
 // SessionKey returns the session key the data is decrypted with.
@@ -112,6 +181,12 @@ type VerifiedDataResult struct {
 	metadata         *LiteralMetadata
 	data             []byte
 	cachedSessionKey *SessionKey
+	passwordIndex    int
+	// missingIntegrityProtection indicates that the message was decrypted from
+	// a legacy data packet without modification detection code.
+	missingIntegrityProtection bool
+	// legacyConstructs lists the legacy constructs found with a strict RFC9580 profile.
+	legacyConstructs []string
 }
 
 // Metadata returns the associated literal metadata of the data.
@@ -120,15 +195,33 @@ func (r *VerifiedDataResult) Metadata() *LiteralMetadata {
 }
 
 // Bytes returns the result data as bytes.
+// The returned slice is not copied, it is owned by the result and shared
+// with NewReader and WriteTo. Callers that modify the data must copy it first.
 func (r *VerifiedDataResult) Bytes() []byte {
 	return r.data
 }
 
 // String returns the result data as string.
+// Converting to a string copies the data, use Bytes, NewReader, or WriteTo
+// to access large results without a copy.
 func (r *VerifiedDataResult) String() string {
 	return string(r.data)
 }
 
+// NewReader returns a new io.Reader that reads the result data without copying it.
+// Not supported on go-mobile clients.
+func (r *VerifiedDataResult) NewReader() io.Reader {
+	return bytes.NewReader(r.data)
+}
+
+// WriteTo writes the result data to w without copying it, and
+// implements io.WriterTo.
+// Not supported on go-mobile clients.
+func (r *VerifiedDataResult) WriteTo(w io.Writer) (int64, error) {
+	n, err := w.Write(r.data)
+	return int64(n), err
+}
+
 // SessionKey returns the session key the data is decrypted with.
 // Returns nil, if the data was not encrypted or
 // session key caching was not enabled.
@@ -136,6 +229,39 @@ func (r *VerifiedDataResult) SessionKey() *SessionKey {
 	return r.cachedSessionKey
 }
 
+// PasswordIndex returns the index of the password that decrypted the message,
+// in the order the passwords were set on the decryption handle.
+// Returns -1, if the message was not decrypted with a password.
+func (r *VerifiedDataResult) PasswordIndex() int {
+	return r.passwordIndex
+}
+
+// IntegrityWarning returns an error if the message was decrypted from a legacy
+// data packet without modification detection code (MDC), which is only accepted with
+// InsecureAllowMissingMDC. Such a plaintext might have been modified by an attacker.
+// Returns nil, if the message is integrity protected or not encrypted.
+func (r *VerifiedDataResult) IntegrityWarning() error {
+	return integrityWarning(r.missingIntegrityProtection)
+}
+
+// LegacyWarning returns an error listing the legacy constructs of the message, e.g.,
+// v3 key packets, SEIPDv1 data packets, or v4 signatures, if the message is decrypted
+// with a strict RFC9580 profile.
+// Returns nil, if the message has no legacy constructs or the profile is not strict.
+func (r *VerifiedDataResult) LegacyWarning() error {
+	return legacyWarning(r.legacyConstructs)
+}
+
+func integrityWarning(missingIntegrityProtection bool) error {
+	if missingIntegrityProtection {
+		return tagError(
+			errors.New("gopenpgp: message is not integrity protected, the plaintext might have been modified"),
+			ErrMessageNotIntegrityProtected,
+		)
+	}
+	return nil
+}
+
 // VerifyCleartextResult is a result of a cleartext message verification.
 type VerifyCleartextResult struct {
 	VerifyResult
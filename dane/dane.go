@@ -0,0 +1,107 @@
+// Package dane discovers the public key of an email address in the DNS
+// with OPENPGPKEY records, see RFC 7929.
+// It is an alternative to the Web Key Directory of the wkd package
+// for domains that publish keys in their DNS zone.
+//
+// The keys are only as trustworthy as the DNS answers. Use a validating resolver
+// and Client.RequireDNSSEC, or validate the answers with Client.ValidateDNSSEC.
+package dane
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	"github.com/ProtonMail/gopenpgp/v3/crypto"
+	"github.com/pkg/errors"
+)
+
+// Records are the OPENPGPKEY records of a domain name.
+type Records struct {
+	// Keys contains the data of the records, i.e., the binary public keys.
+	Keys [][]byte
+	// Authenticated is true if the resolver validated the answer with DNSSEC,
+	// i.e., the answer had the authenticated data flag.
+	Authenticated bool
+}
+
+// Resolver looks up the OPENPGPKEY records of a domain name.
+type Resolver interface {
+	// LookupOpenPGPKey returns the OPENPGPKEY records of the domain name,
+	// which are empty if the name does not exist.
+	LookupOpenPGPKey(ctx context.Context, name string) (*Records, error)
+}
+
+// Client looks up keys in OPENPGPKEY records.
+type Client struct {
+	// Resolver looks up the records, a DNSResolver with the name servers
+	// of the system if nil.
+	Resolver Resolver
+	// RequireDNSSEC rejects answers that are not authenticated by the resolver.
+	RequireDNSSEC bool
+	// ValidateDNSSEC is called with the records of each lookup before their keys are used,
+	// e.g., to validate the DNSSEC chain of the answer independently of the resolver.
+	// A returned error fails the lookup.
+	ValidateDNSSEC func(ctx context.Context, name string, records *Records) error
+}
+
+// Lookup fetches the keys of the email address with the default client, see (*Client).Lookup.
+func Lookup(ctx context.Context, email string) (*crypto.KeyRing, error) {
+	return (&Client{}).Lookup(ctx, email)
+}
+
+// Lookup fetches the keys of the email address from the OPENPGPKEY records of its domain.
+// Only the keys with a user id of the email address are returned,
+// and a nil key ring if the domain publishes no key for the address.
+func (client *Client) Lookup(ctx context.Context, email string) (*crypto.KeyRing, error) {
+	name, err := OwnerName(email)
+	if err != nil {
+		return nil, err
+	}
+	resolver := client.Resolver
+	if resolver == nil {
+		resolver = &DNSResolver{}
+	}
+	records, err := resolver.LookupOpenPGPKey(ctx, name)
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: openpgpkey lookup failed")
+	}
+	if client.RequireDNSSEC && !records.Authenticated {
+		return nil, errors.New("gopenpgp: openpgpkey records are not authenticated with dnssec")
+	}
+	if client.ValidateDNSSEC != nil {
+		if err := client.ValidateDNSSEC(ctx, name, records); err != nil {
+			return nil, errors.Wrap(err, "gopenpgp: openpgpkey records are not valid")
+		}
+	}
+	keys := &crypto.KeyRing{}
+	for _, data := range records.Keys {
+		keyRing, err := crypto.NewKeyRingFromBinary(data)
+		if err != nil {
+			return nil, errors.Wrap(err, "gopenpgp: invalid key in openpgpkey record")
+		}
+		for _, key := range keyRing.GetKeysByEmail(email).GetKeys() {
+			if err := keys.AddKey(key); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if keys.CountEntities() == 0 {
+		return nil, nil
+	}
+	return keys, nil
+}
+
+// OwnerName returns the domain name of the OPENPGPKEY records of the email address,
+// i.e., the hex encoded SHA2-256 hash of the local part truncated to 28 octets,
+// followed by the label _openpgpkey and the domain.
+// The local part is hashed as is, see RFC 7929, section 3.
+func OwnerName(email string) (string, error) {
+	at := strings.LastIndex(email, "@")
+	if at <= 0 || at == len(email)-1 || strings.ContainsAny(email, " <>") {
+		return "", errors.Errorf("gopenpgp: %q is not an email address", email)
+	}
+	hash := sha256.Sum256([]byte(email[:at]))
+	return hex.EncodeToString(hash[:28]) + "._openpgpkey." + strings.ToLower(email[at+1:]), nil
+}
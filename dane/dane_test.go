@@ -0,0 +1,158 @@
+package dane
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/ProtonMail/gopenpgp/v3/crypto"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOwnerName(t *testing.T) {
+	// Example of RFC 7929, section 3.
+	name, err := OwnerName("hugh@Example.com")
+	require.NoError(t, err)
+	assert.Exactly(t, "c93f1e400f26708f98cb19d936620da35eec8f72e57f9eec01c1afd6._openpgpkey.example.com", name)
+
+	for _, invalid := range []string{"", "hugh", "@example.com", "hugh@", "Hugh <hugh@example.com>"} {
+		_, err := OwnerName(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+type testResolver struct {
+	names   []string
+	records *Records
+}
+
+func (resolver *testResolver) LookupOpenPGPKey(ctx context.Context, name string) (*Records, error) {
+	resolver.names = append(resolver.names, name)
+	return resolver.records, nil
+}
+
+func TestClientLookup(t *testing.T) {
+	alice, err := crypto.PGP().KeyGeneration().AddUserId("Alice", "alice@example.com").New().GenerateKey()
+	require.NoError(t, err)
+	bob, err := crypto.PGP().KeyGeneration().AddUserId("Bob", "bob@example.com").New().GenerateKey()
+	require.NoError(t, err)
+	aliceKey, err := alice.GetPublicKey()
+	require.NoError(t, err)
+	bobKey, err := bob.GetPublicKey()
+	require.NoError(t, err)
+
+	resolver := &testResolver{records: &Records{Keys: [][]byte{bobKey, aliceKey}}}
+	client := &Client{Resolver: resolver}
+	keys, err := client.Lookup(context.Background(), "alice@example.com")
+	require.NoError(t, err)
+	require.NotNil(t, keys)
+	assert.Exactly(t, 1, keys.CountEntities())
+	assert.Exactly(t, alice.GetFingerprint(), keys.GetKeys()[0].GetFingerprint())
+	name, err := OwnerName("alice@example.com")
+	require.NoError(t, err)
+	assert.Exactly(t, []string{name}, resolver.names)
+
+	keys, err = client.Lookup(context.Background(), "carol@example.com")
+	require.NoError(t, err)
+	assert.Nil(t, keys)
+
+	// Unauthenticated answers are rejected if DNSSEC is required.
+	client.RequireDNSSEC = true
+	_, err = client.Lookup(context.Background(), "alice@example.com")
+	assert.Error(t, err)
+	resolver.records.Authenticated = true
+	_, err = client.Lookup(context.Background(), "alice@example.com")
+	assert.NoError(t, err)
+
+	client.ValidateDNSSEC = func(ctx context.Context, name string, records *Records) error {
+		return errors.New("invalid signature")
+	}
+	_, err = client.Lookup(context.Background(), "alice@example.com")
+	assert.Error(t, err)
+}
+
+// serveDNS answers queries with an OPENPGPKEY record with the data,
+// or with a truncated response over UDP if truncate is set.
+func serveDNS(t *testing.T, data []byte, truncate bool) string {
+	tcpListener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = tcpListener.Close() })
+	udpConnection, err := net.ListenPacket("udp", tcpListener.Addr().String())
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = udpConnection.Close() })
+
+	answer := func(query []byte, truncated bool) []byte {
+		offset, ok := skipDNSName(query, 12)
+		if !ok || offset+4 > len(query) {
+			return nil
+		}
+		response := append([]byte(nil), query[:offset+4]...)
+		flags := uint16(dnsFlagResponse | dnsFlagRecursion | dnsFlagAuthenticated)
+		if truncated {
+			binary.BigEndian.PutUint16(response[2:], flags|dnsFlagTruncated)
+			binary.BigEndian.PutUint16(response[10:], 0)
+			return response
+		}
+		binary.BigEndian.PutUint16(response[2:], flags)
+		binary.BigEndian.PutUint16(response[6:], 1)
+		binary.BigEndian.PutUint16(response[10:], 0)
+		// The answer refers to the name of the question.
+		response = append(response, 0xc0, 12, 0, dnsTypeOpenPGPKey, 0, dnsClassIN, 0, 0, 0x0e, 0x10,
+			byte(len(data)>>8), byte(len(data)))
+		return append(response, data...)
+	}
+	go func() {
+		buffer := make([]byte, 512)
+		for {
+			n, address, err := udpConnection.ReadFrom(buffer)
+			if err != nil {
+				return
+			}
+			_, _ = udpConnection.WriteTo(answer(buffer[:n], truncate), address)
+		}
+	}()
+	go func() {
+		for {
+			connection, err := tcpListener.Accept()
+			if err != nil {
+				return
+			}
+			var length [2]byte
+			if _, err := io.ReadFull(connection, length[:]); err == nil {
+				query := make([]byte, binary.BigEndian.Uint16(length[:]))
+				if _, err := io.ReadFull(connection, query); err == nil {
+					response := answer(query, false)
+					_, _ = connection.Write(append([]byte{byte(len(response) >> 8), byte(len(response))}, response...))
+				}
+			}
+			_ = connection.Close()
+		}
+	}()
+	return tcpListener.Addr().String()
+}
+
+func TestDNSResolver(t *testing.T) {
+	key, err := crypto.PGP().KeyGeneration().AddUserId("Alice", "alice@example.com").New().GenerateKey()
+	require.NoError(t, err)
+	data, err := key.GetPublicKey()
+	require.NoError(t, err)
+
+	for _, truncate := range []bool{false, true} {
+		resolver := &DNSResolver{Servers: []string{serveDNS(t, data, truncate)}}
+		keys, err := (&Client{Resolver: resolver, RequireDNSSEC: true}).Lookup(context.Background(), "alice@example.com")
+		require.NoError(t, err, truncate)
+		require.NotNil(t, keys)
+		assert.Exactly(t, key.GetFingerprint(), keys.GetKeys()[0].GetFingerprint())
+	}
+
+	_, err = newDNSQuery("a..example.com", dnsTypeOpenPGPKey)
+	assert.Error(t, err)
+	query, err := newDNSQuery("example.com", dnsTypeOpenPGPKey)
+	require.NoError(t, err)
+	_, err = parseDNSResponse(query, query, dnsTypeOpenPGPKey)
+	assert.Error(t, err)
+}
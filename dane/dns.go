@@ -0,0 +1,247 @@
+package dane
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// dnsTypeOpenPGPKey is the type of OPENPGPKEY records.
+	dnsTypeOpenPGPKey = 61
+	// dnsTypeOPT is the type of the EDNS(0) pseudo record.
+	dnsTypeOPT = 41
+	dnsClassIN = 1
+
+	dnsFlagResponse      = 0x8000
+	dnsFlagTruncated     = 0x0200
+	dnsFlagRecursion     = 0x0100
+	dnsFlagAuthenticated = 0x0020
+	dnsRcodeNameError    = 3
+
+	// dnsUDPPayloadSize is the advertised size of UDP responses,
+	// since OPENPGPKEY records are larger than the 512 bytes of plain DNS.
+	dnsUDPPayloadSize = 4096
+	// dnsDefaultTimeout is the timeout of each query if the context has no deadline.
+	dnsDefaultTimeout = 5 * time.Second
+	// resolvConfPath is the path of the resolver configuration with the system name servers.
+	resolvConfPath = "/etc/resolv.conf"
+)
+
+// DNSResolver is a Resolver that queries name servers directly over UDP,
+// and over TCP if the answer is truncated.
+// It requests the authenticated data flag, i.e., the name server must validate
+// the answer with DNSSEC to authenticate it, and the connection to the name server
+// must be trusted, e.g., a validating resolver on the local host.
+type DNSResolver struct {
+	// Servers are the addresses of the name servers, e.g., "127.0.0.1:53",
+	// which are queried in order until one answers.
+	// If empty, the name servers in /etc/resolv.conf are used.
+	Servers []string
+}
+
+// LookupOpenPGPKey implements the Resolver interface.
+func (resolver *DNSResolver) LookupOpenPGPKey(ctx context.Context, name string) (*Records, error) {
+	servers := resolver.Servers
+	if len(servers) == 0 {
+		var err error
+		if servers, err = systemNameServers(); err != nil {
+			return nil, err
+		}
+	}
+	query, err := newDNSQuery(name, dnsTypeOpenPGPKey)
+	if err != nil {
+		return nil, err
+	}
+	var lastErr error
+	for _, server := range servers {
+		response, err := exchangeDNS(ctx, server, query)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		return parseDNSResponse(response, query, dnsTypeOpenPGPKey)
+	}
+	return nil, lastErr
+}
+
+// newDNSQuery returns a recursive query for the records of the type of the name
+// with an EDNS(0) record that advertises large UDP responses.
+func newDNSQuery(name string, recordType uint16) ([]byte, error) {
+	query := make([]byte, 12, 512)
+	if _, err := io.ReadFull(rand.Reader, query[:2]); err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: error in generating dns query id")
+	}
+	binary.BigEndian.PutUint16(query[2:], dnsFlagRecursion|dnsFlagAuthenticated)
+	binary.BigEndian.PutUint16(query[4:], 1)
+	binary.BigEndian.PutUint16(query[10:], 1)
+	name = strings.TrimSuffix(name, ".")
+	if len(name) == 0 || len(name) > 253 {
+		return nil, errors.Errorf("gopenpgp: invalid domain name %q", name)
+	}
+	for _, label := range strings.Split(name, ".") {
+		if len(label) == 0 || len(label) > 63 {
+			return nil, errors.Errorf("gopenpgp: invalid domain name %q", name)
+		}
+		query = append(query, byte(len(label)))
+		query = append(query, label...)
+	}
+	query = append(query, 0, byte(recordType>>8), byte(recordType), 0, dnsClassIN)
+	// The OPT record has the root name, the payload size as class, and no data.
+	query = append(query, 0, 0, dnsTypeOPT, byte(dnsUDPPayloadSize>>8), byte(dnsUDPPayloadSize&0xff), 0, 0, 0, 0, 0, 0)
+	return query, nil
+}
+
+// exchangeDNS sends the query to the name server over UDP, and over TCP if
+// the UDP response is truncated, and returns the response.
+func exchangeDNS(ctx context.Context, server string, query []byte) ([]byte, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, dnsDefaultTimeout)
+		defer cancel()
+	}
+	deadline, _ := ctx.Deadline()
+	dialer := &net.Dialer{}
+	connection, err := dialer.DialContext(ctx, "udp", server)
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: dns query failed")
+	}
+	defer func() { _ = connection.Close() }()
+	_ = connection.SetDeadline(deadline)
+	if _, err := connection.Write(query); err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: dns query failed")
+	}
+	response := make([]byte, 65535)
+	for {
+		n, err := connection.Read(response)
+		if err != nil {
+			return nil, errors.Wrap(err, "gopenpgp: dns query failed")
+		}
+		// Responses to other queries are ignored.
+		if n >= 12 && response[0] == query[0] && response[1] == query[1] {
+			response = response[:n]
+			break
+		}
+	}
+	if binary.BigEndian.Uint16(response[2:])&dnsFlagTruncated == 0 {
+		return response, nil
+	}
+
+	tcpConnection, err := dialer.DialContext(ctx, "tcp", server)
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: dns query failed")
+	}
+	defer func() { _ = tcpConnection.Close() }()
+	_ = tcpConnection.SetDeadline(deadline)
+	// Messages over TCP are prefixed with their length.
+	if _, err := tcpConnection.Write(append([]byte{byte(len(query) >> 8), byte(len(query))}, query...)); err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: dns query failed")
+	}
+	var length [2]byte
+	if _, err := io.ReadFull(tcpConnection, length[:]); err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: dns query failed")
+	}
+	response = make([]byte, binary.BigEndian.Uint16(length[:]))
+	if _, err := io.ReadFull(tcpConnection, response); err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: dns query failed")
+	}
+	return response, nil
+}
+
+// parseDNSResponse returns the data of the records of the type in the answer to the query.
+func parseDNSResponse(response, query []byte, recordType uint16) (*Records, error) {
+	invalid := errors.New("gopenpgp: invalid dns response")
+	if len(response) < 12 || response[0] != query[0] || response[1] != query[1] {
+		return nil, invalid
+	}
+	flags := binary.BigEndian.Uint16(response[2:])
+	if flags&dnsFlagResponse == 0 {
+		return nil, invalid
+	}
+	records := &Records{Authenticated: flags&dnsFlagAuthenticated != 0}
+	switch rcode := flags & 0xf; rcode {
+	case 0:
+	case dnsRcodeNameError:
+		return records, nil
+	default:
+		return nil, errors.Errorf("gopenpgp: dns query failed with response code %d", rcode)
+	}
+	questions := int(binary.BigEndian.Uint16(response[4:]))
+	answers := int(binary.BigEndian.Uint16(response[6:]))
+	offset := 12
+	for i := 0; i < questions; i++ {
+		var ok bool
+		if offset, ok = skipDNSName(response, offset); !ok || offset+4 > len(response) {
+			return nil, invalid
+		}
+		offset += 4
+	}
+	for i := 0; i < answers; i++ {
+		var ok bool
+		if offset, ok = skipDNSName(response, offset); !ok || offset+10 > len(response) {
+			return nil, invalid
+		}
+		answerType := binary.BigEndian.Uint16(response[offset:])
+		answerClass := binary.BigEndian.Uint16(response[offset+2:])
+		length := int(binary.BigEndian.Uint16(response[offset+8:]))
+		offset += 10
+		if offset+length > len(response) {
+			return nil, invalid
+		}
+		if answerType == recordType && answerClass == dnsClassIN {
+			records.Keys = append(records.Keys, append([]byte(nil), response[offset:offset+length]...))
+		}
+		offset += length
+	}
+	return records, nil
+}
+
+// skipDNSName returns the offset after the possibly compressed domain name at the offset.
+func skipDNSName(message []byte, offset int) (int, bool) {
+	for offset < len(message) {
+		length := int(message[offset])
+		switch {
+		case length == 0:
+			return offset + 1, true
+		case length&0xc0 == 0xc0:
+			// A pointer to the rest of the name ends the name.
+			return offset + 2, offset+2 <= len(message)
+		case length&0xc0 != 0:
+			return 0, false
+		}
+		offset += 1 + length
+	}
+	return 0, false
+}
+
+// systemNameServers returns the name servers in /etc/resolv.conf.
+func systemNameServers() ([]string, error) {
+	file, err := os.Open(resolvConfPath)
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: cannot read the system name servers")
+	}
+	defer func() { _ = file.Close() }()
+	var servers []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "nameserver" {
+			servers = append(servers, net.JoinHostPort(fields[1], "53"))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: cannot read the system name servers")
+	}
+	if len(servers) == 0 {
+		return nil, errors.New("gopenpgp: no system name servers found")
+	}
+	return servers, nil
+}
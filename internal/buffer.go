@@ -0,0 +1,93 @@
+package internal
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+const (
+	// copyBufferSize is the size of the pooled buffers of Copy, which matches io.Copy.
+	copyBufferSize = 32 * 1024
+	// maxPooledBufferSize bounds the capacity of buffers returned to the pool,
+	// such that a single large write does not pin its memory.
+	maxPooledBufferSize = 64 * 1024
+)
+
+var copyBufferPool = sync.Pool{
+	New: func() interface{} {
+		buffer := make([]byte, copyBufferSize)
+		return &buffer
+	},
+}
+
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		return new(bytes.Buffer)
+	},
+}
+
+// Copy is like io.Copy, but uses a pooled intermediate buffer instead of allocating one per call.
+// It ignores io.WriterTo and io.ReaderFrom implementations, e.g., of *os.File or io.Discard,
+// which allocate or pool their own buffer.
+// The buffer is cleared before it is returned to the pool, since the copied data might be plaintext.
+func Copy(dst io.Writer, src io.Reader) (int64, error) {
+	buffer := copyBufferPool.Get().(*[]byte)
+	defer func() {
+		zero(*buffer)
+		copyBufferPool.Put(buffer)
+	}()
+	return io.CopyBuffer(struct{ io.Writer }{dst}, struct{ io.Reader }{src}, *buffer)
+}
+
+// ReadAll is like io.ReadAll, but reads into a pooled buffer, see TakeBytes.
+func ReadAll(r io.Reader) ([]byte, error) {
+	buffer := GetBuffer()
+	_, err := buffer.ReadFrom(r)
+	return TakeBytes(buffer), err
+}
+
+// GetBuffer returns an empty buffer from the pool.
+// The buffer must be returned with PutBuffer, or PutSensitiveBuffer if it held plaintext
+// or key material, once its content is no longer referenced.
+func GetBuffer() *bytes.Buffer {
+	return bufferPool.Get().(*bytes.Buffer)
+}
+
+// PutBuffer resets the buffer and returns it to the pool.
+// The content is not cleared, thus, the buffer must only have held public data, e.g., ciphertext.
+func PutBuffer(buffer *bytes.Buffer) {
+	buffer.Reset()
+	if buffer.Cap() > maxPooledBufferSize {
+		return
+	}
+	bufferPool.Put(buffer)
+}
+
+// TakeBytes returns the content of the buffer, which might be plaintext, and returns the buffer
+// to the pool. The content is copied into a slice of the exact size, and the buffer is cleared,
+// unless the buffer is too large to be pooled. Then, the content is returned without a copy.
+// The buffer must not be used afterwards.
+func TakeBytes(buffer *bytes.Buffer) []byte {
+	if buffer.Cap() > maxPooledBufferSize {
+		return buffer.Bytes()
+	}
+	data := make([]byte, buffer.Len())
+	copy(data, buffer.Bytes())
+	PutSensitiveBuffer(buffer)
+	return data
+}
+
+// PutSensitiveBuffer clears the whole backing array of the buffer,
+// which held plaintext or key material, and returns it to the pool.
+func PutSensitiveBuffer(buffer *bytes.Buffer) {
+	buffer.Reset()
+	zero(buffer.Bytes()[:buffer.Cap()])
+	PutBuffer(buffer)
+}
+
+func zero(data []byte) {
+	for i := range data {
+		data[i] = 0
+	}
+}
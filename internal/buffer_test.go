@@ -0,0 +1,116 @@
+package internal
+
+import (
+	"bytes"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestPutSensitiveBufferClearsContent(t *testing.T) {
+	buffer := GetBuffer()
+	buffer.WriteString("secret plaintext")
+	backing := buffer.Bytes()[:buffer.Cap()]
+	PutSensitiveBuffer(buffer)
+	if !bytes.Equal(backing, make([]byte, len(backing))) {
+		t.Fatal("Expected the pooled buffer to be cleared")
+	}
+}
+
+func TestTakeBytes(t *testing.T) {
+	buffer := GetBuffer()
+	buffer.WriteString("secret plaintext")
+	backing := buffer.Bytes()[:buffer.Cap()]
+	data := TakeBytes(buffer)
+	if string(data) != "secret plaintext" {
+		t.Fatalf("Expected the content to be returned, got %q", data)
+	}
+	if !bytes.Equal(backing, make([]byte, len(backing))) {
+		t.Fatal("Expected the pooled buffer to be cleared")
+	}
+
+	// Large buffers are not pooled, and their content is returned without a copy.
+	large := GetBuffer()
+	large.Write(make([]byte, maxPooledBufferSize+1))
+	if content := large.Bytes(); &TakeBytes(large)[0] != &content[0] {
+		t.Fatal("Expected the content of a large buffer not to be copied")
+	}
+}
+
+func TestCopy(t *testing.T) {
+	var dst bytes.Buffer
+	n, err := Copy(&dst, strings.NewReader("plaintext"))
+	if err != nil {
+		t.Fatal("Expected no error while copying, got:", err)
+	}
+	if n != 9 || dst.String() != "plaintext" {
+		t.Fatalf("Expected the plaintext to be copied, got %d bytes: %q", n, dst.String())
+	}
+}
+
+func TestReadAll(t *testing.T) {
+	data, err := ReadAll(strings.NewReader("plaintext"))
+	if err != nil {
+		t.Fatal("Expected no error while reading, got:", err)
+	}
+	if string(data) != "plaintext" {
+		t.Fatalf("Expected the plaintext to be read, got %q", data)
+	}
+	if data, err = ReadAll(strings.NewReader("")); err != nil || data == nil || len(data) != 0 {
+		t.Fatalf("Expected an empty slice, got %v, %v", data, err)
+	}
+}
+
+var benchmarkSizes = []int{256, 4 * 1024, 32 * 1024}
+
+// readerOnly hides the io.WriterTo implementation of the benchmark input,
+// like the decrypting readers of messages, which only implement io.Reader.
+type readerOnly struct {
+	io.Reader
+}
+
+func BenchmarkCopy(b *testing.B) {
+	for _, size := range benchmarkSizes {
+		data := make([]byte, size)
+		b.Run("pooled/"+strconv.Itoa(size), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := Copy(io.Discard, readerOnly{bytes.NewReader(data)}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		// io.Copy into a writer without io.ReaderFrom, which allocates a buffer per call.
+		b.Run("baseline/"+strconv.Itoa(size), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := io.Copy(struct{ io.Writer }{io.Discard}, readerOnly{bytes.NewReader(data)}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkReadAll(b *testing.B) {
+	for _, size := range benchmarkSizes {
+		data := make([]byte, size)
+		b.Run("pooled/"+strconv.Itoa(size), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := ReadAll(readerOnly{bytes.NewReader(data)}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		b.Run("baseline/"+strconv.Itoa(size), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := io.ReadAll(readerOnly{bytes.NewReader(data)}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package internal
+
+import (
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// ShamirShare is a share of a secret that is split with ShamirSplit.
+type ShamirShare struct {
+	// X is the non-zero evaluation point of the share.
+	X byte
+	// Y contains the evaluations of the secret polynomials at X, one per secret byte.
+	Y []byte
+}
+
+// ShamirSplit splits the secret into the given number of shares with Shamir's secret sharing
+// over GF(2^8), such that any threshold of the shares reconstruct the secret
+// and fewer shares reveal nothing about it.
+func ShamirSplit(secret []byte, shares, threshold int, random io.Reader) ([]ShamirShare, error) {
+	if threshold < 2 || shares < threshold || shares > 255 {
+		return nil, errors.New("gopenpgp: shamir sharing requires 2 <= threshold <= shares <= 255")
+	}
+	if len(secret) == 0 {
+		return nil, errors.New("gopenpgp: shamir sharing requires a secret")
+	}
+	result := make([]ShamirShare, shares)
+	for index := range result {
+		result[index] = ShamirShare{X: byte(index + 1), Y: make([]byte, len(secret))}
+	}
+	coefficients := make([]byte, threshold)
+	defer func() {
+		for index := range coefficients {
+			coefficients[index] = 0
+		}
+	}()
+	for position, secretByte := range secret {
+		coefficients[0] = secretByte
+		if _, err := io.ReadFull(random, coefficients[1:]); err != nil {
+			return nil, errors.Wrap(err, "gopenpgp: error in generating shamir coefficients")
+		}
+		for index := range result {
+			result[index].Y[position] = gfEvaluate(coefficients, result[index].X)
+		}
+	}
+	return result, nil
+}
+
+// ShamirCombine reconstructs the secret from shares created with ShamirSplit.
+// The shares must have distinct evaluation points, and their number must reach the threshold
+// of the split. Otherwise, the returned secret is meaningless.
+func ShamirCombine(shares []ShamirShare) ([]byte, error) {
+	if len(shares) < 2 {
+		return nil, errors.New("gopenpgp: shamir reconstruction requires at least two shares")
+	}
+	for index, share := range shares {
+		if share.X == 0 {
+			return nil, errors.New("gopenpgp: invalid shamir share")
+		}
+		if len(share.Y) != len(shares[0].Y) {
+			return nil, errors.New("gopenpgp: shamir shares have different lengths")
+		}
+		for _, other := range shares[:index] {
+			if other.X == share.X {
+				return nil, errors.New("gopenpgp: duplicate shamir share")
+			}
+		}
+	}
+	secret := make([]byte, len(shares[0].Y))
+	for index, share := range shares {
+		// Lagrange basis polynomial of the share evaluated at zero.
+		basis := byte(1)
+		for otherIndex, other := range shares {
+			if otherIndex == index {
+				continue
+			}
+			basis = gfMultiply(basis, gfMultiply(other.X, gfInverse(other.X^share.X)))
+		}
+		for position, y := range share.Y {
+			secret[position] ^= gfMultiply(y, basis)
+		}
+	}
+	return secret, nil
+}
+
+// gfEvaluate evaluates the polynomial with the given coefficients at x with Horner's method.
+func gfEvaluate(coefficients []byte, x byte) byte {
+	var result byte
+	for index := len(coefficients) - 1; index >= 0; index-- {
+		result = gfMultiply(result, x) ^ coefficients[index]
+	}
+	return result
+}
+
+// gfMultiply multiplies in GF(2^8) with the AES polynomial in constant time.
+func gfMultiply(a, b byte) byte {
+	var result byte
+	for bit := 0; bit < 8; bit++ {
+		result ^= a & -(b & 1)
+		b >>= 1
+		a = (a << 1) ^ (0x1b & -(a >> 7))
+	}
+	return result
+}
+
+// gfInverse returns the multiplicative inverse in GF(2^8), i.e., a^254.
+func gfInverse(a byte) byte {
+	result := a
+	for bit := 0; bit < 6; bit++ {
+		result = gfMultiply(gfMultiply(result, result), a)
+	}
+	return gfMultiply(result, result)
+}
@@ -0,0 +1,63 @@
+package internal
+
+import (
+	"bytes"
+	"crypto/rand"
+	"testing"
+)
+
+func TestShamirSplitCombine(t *testing.T) {
+	secret := []byte("a secret that is split into shares")
+	shares, err := ShamirSplit(secret, 5, 3, rand.Reader)
+	if err != nil {
+		t.Fatal("Cannot split secret:", err)
+	}
+	if len(shares) != 5 {
+		t.Fatalf("Expected 5 shares, got %d", len(shares))
+	}
+	subsets := [][]int{{0, 1, 2}, {4, 2, 0}, {1, 3, 4}, {0, 1, 2, 3, 4}}
+	for _, subset := range subsets {
+		var selected []ShamirShare
+		for _, index := range subset {
+			selected = append(selected, shares[index])
+		}
+		combined, err := ShamirCombine(selected)
+		if err != nil {
+			t.Fatal("Cannot combine shares:", err)
+		}
+		if !bytes.Equal(secret, combined) {
+			t.Fatalf("Shares %v do not reconstruct the secret", subset)
+		}
+	}
+	combined, err := ShamirCombine(shares[:2])
+	if err != nil {
+		t.Fatal("Cannot combine shares:", err)
+	}
+	if bytes.Equal(secret, combined) {
+		t.Fatal("Shares below the threshold reconstruct the secret")
+	}
+}
+
+func TestShamirErrors(t *testing.T) {
+	if _, err := ShamirSplit([]byte("secret"), 2, 3, rand.Reader); err == nil {
+		t.Fatal("Expected an error for a threshold above the number of shares")
+	}
+	if _, err := ShamirSplit([]byte("secret"), 256, 3, rand.Reader); err == nil {
+		t.Fatal("Expected an error for too many shares")
+	}
+	shares, err := ShamirSplit([]byte("secret"), 3, 2, rand.Reader)
+	if err != nil {
+		t.Fatal("Cannot split secret:", err)
+	}
+	if _, err := ShamirCombine([]ShamirShare{shares[0], shares[0]}); err == nil {
+		t.Fatal("Expected an error for duplicate shares")
+	}
+}
+
+func TestGFInverse(t *testing.T) {
+	for a := 1; a < 256; a++ {
+		if gfMultiply(byte(a), gfInverse(byte(a))) != 1 {
+			t.Fatalf("Wrong inverse of %d", a)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package mime
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/textproto"
+	"sort"
+	"strings"
+
+	"github.com/ProtonMail/gopenpgp/v3/crypto"
+	"github.com/ProtonMail/gopenpgp/v3/internal"
+	"github.com/pkg/errors"
+)
+
+// pgpHashNames maps OpenPGP hash algorithm ids to their names in the micalg parameter, see RFC 3156.
+var pgpHashNames = map[int]string{
+	1:  "md5",
+	2:  "sha1",
+	3:  "ripemd160",
+	8:  "sha256",
+	9:  "sha384",
+	10: "sha512",
+	11: "sha224",
+	12: "sha3-256",
+	14: "sha3-512",
+}
+
+// Headers of the mail that are replaced by the PGP/MIME structure.
+var contentHeaders = []string{"Content-Type", "Content-Transfer-Encoding", "Content-Disposition", "Mime-Version"}
+
+// BuildEncrypted encrypts the MIME part, i.e., the headers and body of the content,
+// and returns an RFC 3156 multipart/encrypted mail with the headers.
+// The encryption handle can sign the content, which is the combined method of RFC 3156, section 6.2.
+// Content headers, e.g., Content-Type, of the mail headers are replaced.
+// Not supported on go-mobile clients.
+func BuildEncrypted(header textproto.MIMEHeader, part []byte, encryptionHandle crypto.PGPEncryption) ([]byte, error) {
+	if err := checkPart(part); err != nil {
+		return nil, err
+	}
+	pgpMessage, err := encryptionHandle.Encrypt(internal.CanonicalizeBytes(part))
+	if err != nil {
+		return nil, errors.Wrap(err, "mime: encryption failed")
+	}
+	armored, err := pgpMessage.ArmorBytes()
+	if err != nil {
+		return nil, errors.Wrap(err, "mime: encryption failed")
+	}
+
+	var mail bytes.Buffer
+	boundary := multipart.NewWriter(io.Discard).Boundary()
+	if err := writeHeader(&mail, header, fmt.Sprintf(
+		"multipart/encrypted; protocol=\"application/pgp-encrypted\"; boundary=%q", boundary,
+	)); err != nil {
+		return nil, err
+	}
+	mail.WriteString("This is an OpenPGP/MIME encrypted message (RFC 4880 and 3156)\r\n")
+	fmt.Fprintf(&mail, "--%s\r\n", boundary)
+	mail.WriteString("Content-Type: application/pgp-encrypted\r\n")
+	mail.WriteString("Content-Description: PGP/MIME version identification\r\n\r\n")
+	mail.WriteString("Version: 1\r\n\r\n")
+	fmt.Fprintf(&mail, "--%s\r\n", boundary)
+	mail.WriteString("Content-Type: application/octet-stream; name=\"encrypted.asc\"\r\n")
+	mail.WriteString("Content-Description: OpenPGP encrypted message\r\n")
+	mail.WriteString("Content-Disposition: inline; filename=\"encrypted.asc\"\r\n\r\n")
+	mail.Write(internal.CanonicalizeBytes(armored))
+	fmt.Fprintf(&mail, "\r\n--%s--\r\n", boundary)
+	return mail.Bytes(), nil
+}
+
+// BuildSigned signs the MIME part, i.e., the headers and body of the content,
+// and returns an RFC 3156 multipart/signed mail with the headers.
+// The sign handle must create detached signatures, see crypto.SignHandleBuilder.Detached.
+// Line endings of the part are canonicalized to CRLF and trailing whitespace is removed,
+// since mail transport does not preserve them.
+// Content headers, e.g., Content-Type, of the mail headers are replaced.
+// Not supported on go-mobile clients.
+func BuildSigned(header textproto.MIMEHeader, part []byte, signHandle crypto.PGPSign) ([]byte, error) {
+	if err := checkPart(part); err != nil {
+		return nil, err
+	}
+	signedPart := internal.CanonicalizeBytes(internal.TrimEachLineBytes(part))
+	signature, err := signHandle.Sign(signedPart, crypto.Bytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "mime: signing failed")
+	}
+	packets, err := crypto.NewPGPMessage(signature).InspectPackets()
+	if err != nil {
+		return nil, errors.Wrap(err, "mime: signing failed")
+	}
+	var hashNames []string
+	for _, info := range packets {
+		if info.Tag != 2 {
+			return nil, errors.New("mime: the sign handle does not create detached signatures")
+		}
+		hashName, ok := pgpHashNames[info.Hash]
+		if !ok {
+			return nil, errors.Errorf("mime: unsupported signature hash algorithm %d", info.Hash)
+		}
+		if !contains(hashNames, "pgp-"+hashName) {
+			hashNames = append(hashNames, "pgp-"+hashName)
+		}
+	}
+	armoredSignature, err := crypto.TranscodeToArmor(signature)
+	if err != nil {
+		return nil, errors.Wrap(err, "mime: signing failed")
+	}
+
+	var mail bytes.Buffer
+	boundary := multipart.NewWriter(io.Discard).Boundary()
+	if err := writeHeader(&mail, header, fmt.Sprintf(
+		"multipart/signed; micalg=%s; protocol=\"application/pgp-signature\"; boundary=%q",
+		strings.Join(hashNames, ","), boundary,
+	)); err != nil {
+		return nil, err
+	}
+	mail.WriteString("This is an OpenPGP/MIME signed message (RFC 4880 and 3156)\r\n")
+	fmt.Fprintf(&mail, "--%s\r\n", boundary)
+	mail.Write(signedPart)
+	fmt.Fprintf(&mail, "\r\n--%s\r\n", boundary)
+	mail.WriteString("Content-Type: application/pgp-signature; name=\"signature.asc\"\r\n")
+	mail.WriteString("Content-Description: OpenPGP digital signature\r\n")
+	mail.WriteString("Content-Disposition: attachment; filename=\"signature.asc\"\r\n\r\n")
+	mail.WriteString(internal.Canonicalize(armoredSignature))
+	fmt.Fprintf(&mail, "\r\n--%s--\r\n", boundary)
+	return mail.Bytes(), nil
+}
+
+// checkPart checks that the part starts with a valid MIME header.
+func checkPart(part []byte) error {
+	reader := textproto.NewReader(bufio.NewReader(bytes.NewReader(part)))
+	if _, err := reader.ReadMIMEHeader(); err != nil {
+		return errors.Wrap(err, "mime: invalid MIME part")
+	}
+	return nil
+}
+
+// writeHeader writes the mail headers, except for the content headers,
+// followed by the MIME version and the content type.
+func writeHeader(mail *bytes.Buffer, header textproto.MIMEHeader, contentType string) error {
+	if err := writeHeaderFields(mail, header, func(key string) bool {
+		return !contains(contentHeaders, key)
+	}); err != nil {
+		return err
+	}
+	mail.WriteString("MIME-Version: 1.0\r\n")
+	fmt.Fprintf(mail, "Content-Type: %s\r\n\r\n", contentType)
+	return nil
+}
+
+// writeHeaderFields writes the header fields with canonical keys for which include returns true,
+// sorted by key.
+func writeHeaderFields(out *bytes.Buffer, header textproto.MIMEHeader, include func(key string) bool) error {
+	keys := make([]string, 0, len(header))
+	for key := range header {
+		if include(textproto.CanonicalMIMEHeaderKey(key)) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		for _, value := range header[key] {
+			if strings.ContainsAny(key+value, "\r\n") || strings.ContainsAny(key, ": ") {
+				return errors.Errorf("mime: invalid header %q", key)
+			}
+			fmt.Fprintf(out, "%s: %s\r\n", key, value)
+		}
+	}
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,135 @@
+package mime
+
+import (
+	"bytes"
+	"net/textproto"
+	"strings"
+	"testing"
+
+	"github.com/ProtonMail/gopenpgp/v3/constants"
+	"github.com/ProtonMail/gopenpgp/v3/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testPart = "Content-Type: multipart/mixed; boundary=\"mixed\"\n" +
+	"\n" +
+	"--mixed\n" +
+	"Content-Type: text/plain; charset=utf-8\n" +
+	"\n" +
+	"Hello Bob,  \n" +
+	"the report is attached.\n" +
+	"--mixed\n" +
+	"Content-Type: text/plain; name=\"report.txt\"\n" +
+	"Content-Disposition: attachment; filename=\"report.txt\"\n" +
+	"\n" +
+	"report\n" +
+	"--mixed--\n"
+
+func testMailHeader() textproto.MIMEHeader {
+	header := make(textproto.MIMEHeader)
+	header.Set("From", "alice@example.com")
+	header.Set("To", "bob@example.com")
+	header.Set("Subject", "Report")
+	header.Set("Content-Type", "text/plain")
+	return header
+}
+
+func newTestKeyRing(t *testing.T, name string) *crypto.KeyRing {
+	key, err := crypto.PGP().KeyGeneration().AddUserId(name, name+"@example.com").New().GenerateKey()
+	require.NoError(t, err)
+	keyRing, err := crypto.NewKeyRing(key)
+	require.NoError(t, err)
+	return keyRing
+}
+
+func checkParsedPart(t *testing.T, parsed *Message) {
+	assert.Exactly(t, "Report", parsed.Header.Get("Subject"))
+	assert.Exactly(t, "text/plain", parsed.MIMEType)
+	assert.Contains(t, parsed.Body, "the report is attached.")
+	require.Len(t, parsed.Attachments, 1)
+	assert.Exactly(t, "report", strings.TrimSpace(string(parsed.Attachments[0].Data)))
+	assert.Contains(t, parsed.Attachments[0].Header, "report.txt")
+}
+
+func TestBuildParseEncrypted(t *testing.T) {
+	alice := newTestKeyRing(t, "alice")
+	bob := newTestKeyRing(t, "bob")
+	pgp := crypto.PGP()
+	encHandle, err := pgp.Encryption().Recipients(bob).SigningKeys(alice).New()
+	require.NoError(t, err)
+	mail, err := BuildEncrypted(testMailHeader(), []byte(testPart), encHandle)
+	require.NoError(t, err)
+	assert.Contains(t, string(mail), "Content-Type: multipart/encrypted; protocol=\"application/pgp-encrypted\"")
+	assert.NotContains(t, string(mail), "the report is attached.")
+
+	decHandle, err := pgp.Decryption().DecryptionKeys(bob).VerificationKeys(alice).New()
+	require.NoError(t, err)
+	parsed, err := Parse(mail, decHandle, nil)
+	require.NoError(t, err)
+	assert.True(t, parsed.Encrypted)
+	assert.Exactly(t, constants.SIGNATURE_OK, parsed.SignatureStatus())
+	checkParsedPart(t, parsed)
+
+	decHandle, err = pgp.Decryption().DecryptionKeys(bob).New()
+	require.NoError(t, err)
+	parsed, err = Parse(mail, decHandle, nil)
+	require.NoError(t, err)
+	assert.Exactly(t, constants.SIGNATURE_NO_VERIFIER, parsed.SignatureStatus())
+
+	_, err = Parse(mail, nil, nil)
+	assert.Error(t, err)
+	decHandle, err = pgp.Decryption().DecryptionKeys(alice).New()
+	require.NoError(t, err)
+	_, err = Parse(mail, decHandle, nil)
+	assert.Error(t, err)
+}
+
+func TestBuildParseSigned(t *testing.T) {
+	alice := newTestKeyRing(t, "alice")
+	pgp := crypto.PGP()
+	signHandle, err := pgp.Sign().SigningKeys(alice).Detached().New()
+	require.NoError(t, err)
+	mail, err := BuildSigned(testMailHeader(), []byte(testPart), signHandle)
+	require.NoError(t, err)
+	assert.Contains(t, string(mail), "Content-Type: multipart/signed; micalg=pgp-sha")
+	assert.Exactly(t, 1, strings.Count(string(mail), "Content-Type: text/plain; charset=utf-8"))
+
+	verifyHandle, err := pgp.Verify().VerificationKeys(alice).New()
+	require.NoError(t, err)
+	parsed, err := Parse(mail, nil, verifyHandle)
+	require.NoError(t, err)
+	assert.False(t, parsed.Encrypted)
+	assert.Exactly(t, constants.SIGNATURE_OK, parsed.SignatureStatus())
+	checkParsedPart(t, parsed)
+
+	// Line endings are canonicalized by the signature verification.
+	parsed, err = Parse(bytes.ReplaceAll(mail, []byte("\r\n"), []byte("\n")), nil, verifyHandle)
+	require.NoError(t, err)
+	assert.Exactly(t, constants.SIGNATURE_OK, parsed.SignatureStatus())
+
+	tampered := bytes.Replace(mail, []byte("report is"), []byte("money is"), 1)
+	parsed, err = Parse(tampered, nil, verifyHandle)
+	require.NoError(t, err)
+	assert.Exactly(t, constants.SIGNATURE_FAILED, parsed.SignatureStatus())
+
+	parsed, err = Parse(mail, nil, nil)
+	require.NoError(t, err)
+	assert.Exactly(t, constants.SIGNATURE_NO_VERIFIER, parsed.SignatureStatus())
+
+	inlineHandle, err := pgp.Sign().SigningKeys(alice).New()
+	require.NoError(t, err)
+	_, err = BuildSigned(testMailHeader(), []byte(testPart), inlineHandle)
+	assert.Error(t, err)
+}
+
+func TestBuildInvalidInput(t *testing.T) {
+	signHandle, err := crypto.PGP().Sign().SigningKeys(newTestKeyRing(t, "alice")).Detached().New()
+	require.NoError(t, err)
+	header := testMailHeader()
+	header.Set("Subject", "Report\r\nBcc: eve@example.com")
+	_, err = BuildSigned(header, []byte(testPart), signHandle)
+	assert.Error(t, err)
+	_, err = BuildSigned(testMailHeader(), []byte("no header\n"), signHandle)
+	assert.Error(t, err)
+}
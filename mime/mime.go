@@ -1,4 +1,4 @@
-// Package mime provides an API to decrypt mime messages.
+// Package mime provides an API to build and decrypt PGP/MIME messages.
 package mime
 
 import (
@@ -64,7 +64,7 @@ func Decrypt(
 	for i := 0; i < len(attachments); i++ {
 		callbacks.OnAttachment(attachmentHeaders[i], []byte(attachments[i]))
 	}
-	callbacks.OnEncryptedHeaders("")
+	callbacks.OnEncryptedHeaders(formatHeader(readProtectedHeaders(decryptedMessage)))
 }
 
 // ----- INTERNAL FUNCTIONS -----
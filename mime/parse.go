@@ -0,0 +1,158 @@
+package mime
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"net/textproto"
+	"strings"
+
+	gomime "github.com/ProtonMail/go-mime"
+	"github.com/ProtonMail/gopenpgp/v3/constants"
+	"github.com/ProtonMail/gopenpgp/v3/crypto"
+	"github.com/ProtonMail/gopenpgp/v3/internal"
+	"github.com/pkg/errors"
+)
+
+// Message is a parsed PGP/MIME mail.
+type Message struct {
+	// Header contains the headers of the mail, which are not protected by encryption or signatures.
+	Header textproto.MIMEHeader
+	// Encrypted indicates that the mail was an RFC 3156 multipart/encrypted mail.
+	Encrypted bool
+	// ProtectedHeader contains the protected headers of the encrypted part, e.g., the actual subject,
+	// or is nil if the mail has none, see BuildEncryptedWithProtectedHeaders.
+	ProtectedHeader textproto.MIMEHeader
+	// Body is the sanitized body of the mail.
+	Body string
+	// MIMEType is the MIME type of the body, e.g., "text/plain" or "text/html".
+	MIMEType string
+	// Attachments contains the attachments of the mail.
+	Attachments []*Attachment
+	// SignatureError is nil if the content is signed by a verification key,
+	// either inside the encrypted message or by a multipart/signed part.
+	// Content that is not verified since no verification keys are provided
+	// has the status constants.SIGNATURE_NO_VERIFIER.
+	SignatureError *crypto.SignatureVerificationError
+}
+
+// Attachment is an attachment of a parsed mail.
+type Attachment struct {
+	// Header contains the MIME headers of the attachment.
+	Header string
+	// Data is the decoded content of the attachment.
+	Data []byte
+}
+
+// SignatureStatus returns the verification status of the mail, i.e., one of the
+// constants.SIGNATURE_* values.
+func (msg *Message) SignatureStatus() int {
+	if msg.SignatureError == nil {
+		return constants.SIGNATURE_OK
+	}
+	return msg.SignatureError.Status
+}
+
+// Parse parses an incoming mail, which can be an RFC 3156 multipart/encrypted or
+// multipart/signed mail, or an unprotected mail.
+// Encrypted mails are decrypted and verified with the decryptionHandle, while
+// the verifyHandle is used to verify multipart/signed parts.
+// The decryptionHandle is only required for encrypted mails, and the verifyHandle can be nil.
+// Not supported on go-mobile clients, use Decrypt instead.
+func Parse(message []byte, decryptionHandle crypto.PGPDecryption, verifyHandle crypto.PGPVerify) (*Message, error) {
+	mm, err := mail.ReadMessage(bytes.NewReader(message))
+	if err != nil {
+		return nil, errors.Wrap(err, "mime: error in reading message")
+	}
+	parsed := &Message{Header: textproto.MIMEHeader(mm.Header)}
+	content := message
+	var embeddedSigError *crypto.SignatureVerificationError
+	mediaType, params, _ := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+	if mediaType == "multipart/encrypted" && strings.EqualFold(params["protocol"], "application/pgp-encrypted") {
+		if decryptionHandle == nil {
+			return nil, errors.New("mime: no decryption handle provided for the encrypted mail")
+		}
+		ciphertext, err := readEncryptedPart(mm.Body, params["boundary"])
+		if err != nil {
+			return nil, err
+		}
+		decResult, err := decryptionHandle.Decrypt(ciphertext, crypto.Auto)
+		if err != nil {
+			return nil, errors.Wrap(err, "mime: decryption failed")
+		}
+		parsed.Encrypted = true
+		content = decResult.Bytes()
+		parsed.ProtectedHeader = readProtectedHeaders(content)
+		embeddedSigError, _ = separateSigError(decResult.SignatureError())
+		if embeddedSigError == nil && len(decResult.Signatures) == 0 {
+			// The decryption handle has no verification keys.
+			noVerifier := newSignatureNoVerifier()
+			embeddedSigError = &noVerifier
+		}
+	}
+
+	body, attachments, attachmentHeaders, err := parseMIME(content, verifyHandle)
+	mimeSigError, err := separateSigError(err)
+	if err != nil {
+		return nil, err
+	}
+	if verifyHandle == nil && mimeSigError == nil {
+		noVerifier := newSignatureNoVerifier()
+		mimeSigError = &noVerifier
+	}
+	switch {
+	case !parsed.Encrypted:
+		parsed.SignatureError = mimeSigError
+	case embeddedSigError != nil && mimeSigError != nil:
+		// The content is only unsigned if both the embedded and the mime verification failed.
+		parsed.SignatureError = embeddedSigError
+		if mimeSigError.Status > embeddedSigError.Status {
+			parsed.SignatureError = mimeSigError
+		}
+	}
+	bodyContent, bodyMimeType := body.GetBody()
+	parsed.Body = internal.SanitizeString(bodyContent)
+	parsed.MIMEType = bodyMimeType
+	for i := range attachments {
+		parsed.Attachments = append(parsed.Attachments, &Attachment{
+			Header: attachmentHeaders[i],
+			Data:   []byte(attachments[i]),
+		})
+	}
+	return parsed, nil
+}
+
+// readEncryptedPart returns the encrypted message of a multipart/encrypted body,
+// i.e., the second part after the version identification, see RFC 3156, section 4.
+func readEncryptedPart(body io.Reader, boundary string) ([]byte, error) {
+	if boundary == "" {
+		return nil, errors.New("mime: missing boundary of the encrypted mail")
+	}
+	reader := multipart.NewReader(body, boundary)
+	for index := 0; ; index++ {
+		part, err := reader.NextRawPart()
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("mime: missing encrypted part")
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "mime: error in reading encrypted mail")
+		}
+		mediaType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		if index == 0 {
+			if mediaType != "application/pgp-encrypted" {
+				return nil, errors.New("mime: missing PGP/MIME version identification")
+			}
+			continue
+		}
+		if mediaType != "application/octet-stream" {
+			return nil, errors.Errorf("mime: unexpected encrypted part of type %q", mediaType)
+		}
+		ciphertext, err := io.ReadAll(gomime.DecodeContentEncoding(part, part.Header.Get("Content-Transfer-Encoding")))
+		if err != nil {
+			return nil, errors.Wrap(err, "mime: error in reading encrypted part")
+		}
+		return ciphertext, nil
+	}
+}
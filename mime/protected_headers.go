@@ -0,0 +1,112 @@
+package mime
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"mime"
+	"net/textproto"
+	"strings"
+
+	"github.com/ProtonMail/gopenpgp/v3/crypto"
+	"github.com/pkg/errors"
+)
+
+// ProtectedHeaderFields are the mail headers that are copied into the encrypted part
+// by BuildEncryptedWithProtectedHeaders.
+var ProtectedHeaderFields = []string{
+	"Subject", "From", "To", "Cc", "Reply-To", "Date", "Message-Id", "In-Reply-To", "References",
+}
+
+// ObscuredSubject replaces the subject of the mail headers if it is protected.
+const ObscuredSubject = "..."
+
+// protectedHeadersParameter is the Content-Type parameter that marks a part
+// with protected headers, see draft-autocrypt-lamps-protected-headers.
+const protectedHeadersParameter = "protected-headers"
+
+// BuildEncryptedWithProtectedHeaders is like BuildEncrypted but copies the ProtectedHeaderFields
+// of the mail headers into the headers of the encrypted part, and replaces the subject
+// of the mail headers with ObscuredSubject, following draft-autocrypt-lamps-protected-headers.
+// The protected headers are returned by Parse, and passed to MIMECallbacks.OnEncryptedHeaders by Decrypt.
+// Not supported on go-mobile clients.
+func BuildEncryptedWithProtectedHeaders(header textproto.MIMEHeader, part []byte, encryptionHandle crypto.PGPEncryption) ([]byte, error) {
+	protectedPart, err := protectPart(header, part)
+	if err != nil {
+		return nil, err
+	}
+	outerHeader := make(textproto.MIMEHeader, len(header))
+	for key, values := range header {
+		outerHeader[key] = values
+	}
+	if outerHeader.Get("Subject") != "" {
+		outerHeader.Set("Subject", ObscuredSubject)
+	}
+	return BuildEncrypted(outerHeader, protectedPart, encryptionHandle)
+}
+
+// protectPart adds the protected header fields of the mail headers to the headers of the part,
+// and marks the part with the protected-headers parameter of the Content-Type.
+func protectPart(header textproto.MIMEHeader, part []byte) ([]byte, error) {
+	reader := bufio.NewReader(bytes.NewReader(part))
+	partHeader, err := textproto.NewReader(reader).ReadMIMEHeader()
+	if err != nil {
+		return nil, errors.Wrap(err, "mime: invalid MIME part")
+	}
+	body, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, errors.Wrap(err, "mime: invalid MIME part")
+	}
+	contentType := partHeader.Get("Content-Type")
+	if contentType == "" {
+		contentType = "text/plain"
+	}
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, errors.Wrap(err, "mime: invalid content type of the MIME part")
+	}
+	params[protectedHeadersParameter] = "v1"
+	partHeader.Set("Content-Type", mime.FormatMediaType(mediaType, params))
+
+	var protectedPart bytes.Buffer
+	if err := writeHeaderFields(&protectedPart, header, func(key string) bool {
+		return contains(ProtectedHeaderFields, key)
+	}); err != nil {
+		return nil, err
+	}
+	if err := writeHeaderFields(&protectedPart, partHeader, func(key string) bool {
+		return !contains(ProtectedHeaderFields, key)
+	}); err != nil {
+		return nil, err
+	}
+	protectedPart.WriteString("\r\n")
+	protectedPart.Write(body)
+	return protectedPart.Bytes(), nil
+}
+
+// readProtectedHeaders returns the protected headers of the decrypted content,
+// or nil if the content is not marked with the protected-headers parameter.
+func readProtectedHeaders(content []byte) textproto.MIMEHeader {
+	header, err := textproto.NewReader(bufio.NewReader(bytes.NewReader(content))).ReadMIMEHeader()
+	if err != nil {
+		return nil
+	}
+	_, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil || params[protectedHeadersParameter] != "v1" {
+		return nil
+	}
+	protected := make(textproto.MIMEHeader)
+	for key, values := range header {
+		if !strings.HasPrefix(key, "Content-") && key != "Mime-Version" {
+			protected[key] = values
+		}
+	}
+	return protected
+}
+
+// formatHeader returns the header fields as lines of the form "Key: value".
+func formatHeader(header textproto.MIMEHeader) string {
+	var formatted bytes.Buffer
+	_ = writeHeaderFields(&formatted, header, func(key string) bool { return true })
+	return formatted.String()
+}
@@ -0,0 +1,65 @@
+package mime
+
+import (
+	"testing"
+
+	"github.com/ProtonMail/gopenpgp/v3/constants"
+	"github.com/ProtonMail/gopenpgp/v3/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildParseProtectedHeaders(t *testing.T) {
+	alice := newTestKeyRing(t, "alice")
+	bob := newTestKeyRing(t, "bob")
+	pgp := crypto.PGP()
+	encHandle, err := pgp.Encryption().Recipients(bob).SigningKeys(alice).New()
+	require.NoError(t, err)
+	header := testMailHeader()
+	header.Set("Message-ID", "<report@example.com>")
+	mail, err := BuildEncryptedWithProtectedHeaders(header, []byte(testPart), encHandle)
+	require.NoError(t, err)
+	assert.Contains(t, string(mail), "Subject: "+ObscuredSubject+"\r\n")
+	assert.NotContains(t, string(mail), "Report")
+	assert.Exactly(t, "Report", header.Get("Subject"))
+
+	decHandle, err := pgp.Decryption().DecryptionKeys(bob).VerificationKeys(alice).New()
+	require.NoError(t, err)
+	parsed, err := Parse(mail, decHandle, nil)
+	require.NoError(t, err)
+	assert.Exactly(t, ObscuredSubject, parsed.Header.Get("Subject"))
+	require.NotNil(t, parsed.ProtectedHeader)
+	assert.Exactly(t, "Report", parsed.ProtectedHeader.Get("Subject"))
+	assert.Exactly(t, "alice@example.com", parsed.ProtectedHeader.Get("From"))
+	assert.Exactly(t, "<report@example.com>", parsed.ProtectedHeader.Get("Message-Id"))
+	assert.Empty(t, parsed.ProtectedHeader.Get("Content-Type"))
+	assert.Exactly(t, constants.SIGNATURE_OK, parsed.SignatureStatus())
+	assert.Contains(t, parsed.Body, "the report is attached.")
+	assert.Len(t, parsed.Attachments, 1)
+
+	// Mails without protected headers have none.
+	mail, err = BuildEncrypted(header, []byte(testPart), encHandle)
+	require.NoError(t, err)
+	parsed, err = Parse(mail, decHandle, nil)
+	require.NoError(t, err)
+	assert.Nil(t, parsed.ProtectedHeader)
+}
+
+func TestDecryptProtectedHeaders(t *testing.T) {
+	bob := newTestKeyRing(t, "bob")
+	pgp := crypto.PGP()
+	part, err := protectPart(testMailHeader(), []byte(testPart))
+	require.NoError(t, err)
+	encHandle, err := pgp.Encryption().Recipients(bob).New()
+	require.NoError(t, err)
+	pgpMessage, err := encHandle.Encrypt(part)
+	require.NoError(t, err)
+	decHandle, err := pgp.Decryption().DecryptionKeys(bob).New()
+	require.NoError(t, err)
+
+	callbacks := &testMIMECallbacks{}
+	Decrypt(pgpMessage.Bytes(), crypto.Bytes, decHandle, nil, callbacks)
+	assert.Empty(t, callbacks.onError)
+	require.Len(t, callbacks.onEncryptedHeaders, 1)
+	assert.Exactly(t, "From: alice@example.com\r\nSubject: Report\r\nTo: bob@example.com\r\n", callbacks.onEncryptedHeaders[0])
+}
@@ -0,0 +1,78 @@
+package mobile
+
+import (
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+)
+
+// ErrCancelled is returned by the wrappers and chunk streams once their CancelToken is cancelled.
+var ErrCancelled = errors.New("gopenpgp: operation cancelled")
+
+// CancelToken lets the mobile app abort a running stream, e.g., from a UI thread.
+// Cancellation is checked before each read or write of a wrapper, thus no goroutine
+// is started to interrupt a blocked read or write of the app.
+type CancelToken struct {
+	cancelled int32
+}
+
+// NewCancelToken returns a token that is not cancelled.
+func NewCancelToken() *CancelToken {
+	return &CancelToken{}
+}
+
+// Cancel cancels the token. It is safe to call from any thread.
+func (t *CancelToken) Cancel() {
+	atomic.StoreInt32(&t.cancelled, 1)
+}
+
+// IsCancelled returns true once the token is cancelled.
+func (t *CancelToken) IsCancelled() bool {
+	return atomic.LoadInt32(&t.cancelled) == 1
+}
+
+// ProgressCallback is the interface that progress observers in the mobile runtime must implement.
+// OnProgress is called with the total number of bytes processed so far,
+// on the thread that drives the stream.
+type ProgressCallback interface {
+	OnProgress(processedBytes int64)
+}
+
+// streamControl holds the optional cancel token and progress callback of a stream.
+type streamControl struct {
+	cancelToken *CancelToken
+	progress    ProgressCallback
+	processed   int64
+}
+
+// SetCancelToken sets the token that aborts the stream once cancelled.
+func (c *streamControl) SetCancelToken(token *CancelToken) {
+	c.cancelToken = token
+}
+
+// SetProgressCallback sets the callback that is notified of the processed bytes.
+func (c *streamControl) SetProgressCallback(progress ProgressCallback) {
+	c.progress = progress
+}
+
+// ProcessedBytes returns the total number of bytes processed so far.
+func (c *streamControl) ProcessedBytes() int64 {
+	return c.processed
+}
+
+func (c *streamControl) checkCancelled() error {
+	if c.cancelToken != nil && c.cancelToken.IsCancelled() {
+		return ErrCancelled
+	}
+	return nil
+}
+
+func (c *streamControl) addProgress(n int) {
+	if n <= 0 {
+		return
+	}
+	c.processed += int64(n)
+	if c.progress != nil {
+		c.progress.OnProgress(c.processed)
+	}
+}
@@ -0,0 +1,84 @@
+package mobile
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/ProtonMail/gopenpgp/v3/crypto"
+)
+
+type testProgressCallback struct {
+	calls     int
+	processed int64
+}
+
+func (p *testProgressCallback) OnProgress(processedBytes int64) {
+	p.calls++
+	p.processed = processedBytes
+}
+
+func TestMobile2GoWriterCancelAndProgress(t *testing.T) {
+	outBuf := &bytes.Buffer{}
+	writer := NewMobile2GoWriter(outBuf)
+	token := NewCancelToken()
+	progress := &testProgressCallback{}
+	writer.SetCancelToken(token)
+	writer.SetProgressCallback(progress)
+	if _, err := writer.Write([]byte("Hello ")); err != nil {
+		t.Fatal("Expected no error while writing, got:", err)
+	}
+	if _, err := writer.Write([]byte("World!")); err != nil {
+		t.Fatal("Expected no error while writing, got:", err)
+	}
+	if progress.calls != 2 || progress.processed != 12 || writer.ProcessedBytes() != 12 {
+		t.Fatalf("expected 2 progress calls with 12 bytes, got %d calls with %d bytes", progress.calls, progress.processed)
+	}
+	token.Cancel()
+	if !token.IsCancelled() {
+		t.Fatal("expected the token to be cancelled")
+	}
+	if _, err := writer.Write([]byte("!")); !errors.Is(err, ErrCancelled) {
+		t.Fatalf("expected error %v, got %v", ErrCancelled, err)
+	}
+	if outBuf.String() != "Hello World!" {
+		t.Fatalf("expected no data to be written after cancellation, got %q", outBuf.String())
+	}
+}
+
+func TestChunkDecryptorCancel(t *testing.T) {
+	data := bytes.Repeat([]byte("hello chunks "), 1000)
+	pgpHandle, pubKR, privKR, err := setUpTestKeyRing()
+	if err != nil {
+		t.Fatalf("Got an error while loading test key: %v", err)
+	}
+	defer privKR.ClearPrivateParams()
+	encHandle, _ := pgpHandle.Encryption().Recipients(pubKR).New()
+	ciphertext, err := encHandle.Encrypt(data)
+	if err != nil {
+		t.Fatalf("Got an error while encrypting test data: %v", err)
+	}
+	decHandle, _ := pgpHandle.Decryption().DecryptionKeys(privKR).New()
+	source := &testMobileReader{bytes.NewReader(ciphertext.Bytes()), false}
+	decryptor, err := NewChunkDecryptor(decHandle, source, crypto.Bytes, 1024)
+	if err != nil {
+		t.Fatalf("Got an error while creating the chunk decryptor: %v", err)
+	}
+	token := NewCancelToken()
+	progress := &testProgressCallback{}
+	decryptor.SetCancelToken(token)
+	decryptor.SetProgressCallback(progress)
+	if _, err := decryptor.Pull(); err != nil {
+		t.Fatalf("Got an error while pulling a chunk: %v", err)
+	}
+	if progress.calls == 0 || progress.processed != decryptor.ProcessedBytes() {
+		t.Fatalf("expected progress of %d bytes, got %d", decryptor.ProcessedBytes(), progress.processed)
+	}
+	token.Cancel()
+	if _, err := decryptor.Pull(); err == nil {
+		t.Fatal("expected an error after cancellation, got nil")
+	}
+	if code := decryptor.ErrorCode(); code != ChunkErrorCancelled {
+		t.Fatalf("expected error code %d, got %d", ChunkErrorCancelled, code)
+	}
+}
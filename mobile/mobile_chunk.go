@@ -0,0 +1,258 @@
+package mobile
+
+import (
+	"io"
+
+	"github.com/ProtonMail/gopenpgp/v3/crypto"
+	"github.com/pkg/errors"
+)
+
+// Error codes of the chunk streaming API.
+// They are returned by ErrorCode of ChunkEncryptor and ChunkDecryptor,
+// since gomobile reduces the returned errors to their message.
+const (
+	// ChunkOK indicates that no error occurred.
+	ChunkOK = 0
+	// ChunkErrorSource indicates that the MobileReader of the app failed.
+	ChunkErrorSource = 1
+	// ChunkErrorSink indicates that the ChunkSink of the app failed.
+	ChunkErrorSink = 2
+	// ChunkErrorCrypto indicates that the encryption or decryption failed,
+	// e.g., since no key matched or the message is corrupted.
+	ChunkErrorCrypto = 3
+	// ChunkErrorClosed indicates that the stream was used after it was finished or failed.
+	ChunkErrorClosed = 4
+	// ChunkErrorCancelled indicates that the CancelToken of the stream was cancelled.
+	ChunkErrorCancelled = 5
+)
+
+var errChunkEncryptorClosed = errors.New("gopenpgp: chunk encryptor is finished or failed")
+
+// ChunkSink is the interface that chunk consumers in the mobile runtime must implement.
+// WriteChunk is called with each output chunk. The chunk is only valid for the duration
+// of the call and must be copied by the app if it is retained.
+type ChunkSink interface {
+	WriteChunk(chunk []byte) error
+}
+
+// chunkSinkWriter delivers the written data to a ChunkSink in chunks of a fixed size.
+type chunkSinkWriter struct {
+	sink   ChunkSink
+	buffer []byte
+	err    error
+}
+
+func (w *chunkSinkWriter) Write(b []byte) (n int, err error) {
+	for len(b) > 0 {
+		free := cap(w.buffer) - len(w.buffer)
+		if free > len(b) {
+			free = len(b)
+		}
+		w.buffer = append(w.buffer, b[:free]...)
+		b = b[free:]
+		n += free
+		if len(w.buffer) == cap(w.buffer) {
+			if err := w.flush(); err != nil {
+				return n, err
+			}
+		}
+	}
+	return n, nil
+}
+
+func (w *chunkSinkWriter) flush() error {
+	if len(w.buffer) == 0 {
+		return nil
+	}
+	if err := w.sink.WriteChunk(w.buffer); err != nil {
+		w.err = err
+		return errors.Wrap(err, "gopenpgp: couldn't write to chunk sink")
+	}
+	w.buffer = w.buffer[:0]
+	return nil
+}
+
+// ChunkEncryptor encrypts plaintext pushed by the mobile app in chunks,
+// and delivers the encrypted message to a ChunkSink in chunks of a fixed size.
+// A CancelToken and a ProgressCallback can be set to abort the encryption
+// and observe the pushed plaintext bytes.
+type ChunkEncryptor struct {
+	streamControl
+	ptWriter  crypto.WriteCloser
+	sink      *chunkSinkWriter
+	errorCode int
+}
+
+// NewChunkEncryptor returns a ChunkEncryptor that encrypts with the given handle
+// and writes the encrypted message with the given encoding to the sink,
+// in chunks of chunkSize bytes. Only the last chunk may be shorter.
+func NewChunkEncryptor(handle crypto.PGPEncryption, sink ChunkSink, encoding int8, chunkSize int) (*ChunkEncryptor, error) {
+	if chunkSize <= 0 {
+		return nil, errors.New("gopenpgp: chunk size must be positive")
+	}
+	sinkWriter := &chunkSinkWriter{sink: sink, buffer: make([]byte, 0, chunkSize)}
+	ptWriter, err := handle.EncryptingWriter(sinkWriter, encoding)
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: couldn't create encrypting writer")
+	}
+	return &ChunkEncryptor{ptWriter: ptWriter, sink: sinkWriter}, nil
+}
+
+// Push encrypts the plaintext chunk.
+// It clones the provided data to prevent errors with garbage collectors.
+func (e *ChunkEncryptor) Push(chunk []byte) error {
+	if e.errorCode != ChunkOK {
+		return errChunkEncryptorClosed
+	}
+	if err := e.checkCancelled(); err != nil {
+		return e.fail(err)
+	}
+	n, err := e.ptWriter.Write(clone(chunk))
+	e.addProgress(n)
+	if err != nil {
+		return e.fail(err)
+	}
+	return nil
+}
+
+// Finish finalizes the encryption and delivers the remaining encrypted data to the sink.
+// The encryptor must not be used afterwards.
+func (e *ChunkEncryptor) Finish() error {
+	if e.errorCode != ChunkOK {
+		return errChunkEncryptorClosed
+	}
+	if err := e.checkCancelled(); err != nil {
+		return e.fail(err)
+	}
+	if err := e.ptWriter.Close(); err != nil {
+		return e.fail(err)
+	}
+	if err := e.sink.flush(); err != nil {
+		return e.fail(err)
+	}
+	e.errorCode = ChunkErrorClosed
+	return nil
+}
+
+// ErrorCode returns the code of the error that stopped the encryptor,
+// ChunkErrorClosed once it is finished, or ChunkOK while it is usable.
+func (e *ChunkEncryptor) ErrorCode() int {
+	return e.errorCode
+}
+
+func (e *ChunkEncryptor) fail(err error) error {
+	switch {
+	case errors.Is(err, ErrCancelled):
+		e.errorCode = ChunkErrorCancelled
+	case e.sink.err != nil:
+		e.errorCode = ChunkErrorSink
+	default:
+		e.errorCode = ChunkErrorCrypto
+	}
+	return errors.Wrap(err, "gopenpgp: chunk encryption failed")
+}
+
+// mobileSourceReader reads from a MobileReader with the control of a stream
+// and records the failure of the MobileReader.
+type mobileSourceReader struct {
+	source  *Mobile2GoReader
+	control *streamControl
+	err     error
+}
+
+func (r *mobileSourceReader) Read(b []byte) (n int, err error) {
+	if err := r.control.checkCancelled(); err != nil {
+		return 0, err
+	}
+	n, err = r.source.Read(b)
+	r.control.addProgress(n)
+	if err != nil && !errors.Is(err, io.EOF) {
+		r.err = err
+	}
+	return n, err
+}
+
+// ChunkDecryptor decrypts a message read from a MobileReader of the app,
+// and returns the plaintext to the app in chunks of a fixed size.
+// A CancelToken and a ProgressCallback can be set on the decryptor to abort the decryption
+// and observe the bytes read from the MobileReader, e.g., to relate them to the file size.
+type ChunkDecryptor struct {
+	streamControl
+	ptReader  *crypto.VerifyDataReader
+	source    *mobileSourceReader
+	chunkSize int
+	errorCode int
+	isEOF     bool
+}
+
+// NewChunkDecryptor returns a ChunkDecryptor that decrypts the message with the given
+// encoding read from source with the given handle.
+// Pull returns the plaintext in chunks of chunkSize bytes, only the last chunk may be shorter.
+func NewChunkDecryptor(handle crypto.PGPDecryption, source MobileReader, encoding int8, chunkSize int) (*ChunkDecryptor, error) {
+	if chunkSize <= 0 {
+		return nil, errors.New("gopenpgp: chunk size must be positive")
+	}
+	decryptor := &ChunkDecryptor{chunkSize: chunkSize}
+	decryptor.source = &mobileSourceReader{source: NewMobile2GoReader(source), control: &decryptor.streamControl}
+	ptReader, err := handle.DecryptingReader(decryptor.source, encoding)
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: couldn't create decrypting reader")
+	}
+	decryptor.ptReader = ptReader
+	return decryptor, nil
+}
+
+// Pull returns the next plaintext chunk.
+// The result is marked with IsEOF once the plaintext is read entirely,
+// afterwards the signatures can be checked with VerifySignature.
+func (d *ChunkDecryptor) Pull() (*MobileReadResult, error) {
+	if d.errorCode != ChunkOK {
+		return nil, errors.New("gopenpgp: chunk decryptor failed")
+	}
+	if d.isEOF {
+		return &MobileReadResult{IsEOF: true}, nil
+	}
+	if err := d.checkCancelled(); err != nil {
+		return nil, d.fail(err)
+	}
+	buffer := make([]byte, d.chunkSize)
+	n := 0
+	for n < len(buffer) && !d.isEOF {
+		read, err := d.ptReader.Read(buffer[n:])
+		n += read
+		switch {
+		case err == io.EOF:
+			d.isEOF = true
+		case err != nil:
+			return nil, d.fail(err)
+		}
+	}
+	return &MobileReadResult{N: n, IsEOF: d.isEOF, Data: buffer[:n]}, nil
+}
+
+// VerifySignature checks the signatures of the message once all chunks are pulled.
+// The returned result can be checked for signature errors.
+func (d *ChunkDecryptor) VerifySignature() (*crypto.VerifyResult, error) {
+	if !d.isEOF {
+		return nil, errors.New("gopenpgp: the plaintext must be read entirely before verifying signatures")
+	}
+	return d.ptReader.VerifySignature()
+}
+
+// ErrorCode returns the code of the error that stopped the decryptor,
+// or ChunkOK while it is usable.
+func (d *ChunkDecryptor) ErrorCode() int {
+	return d.errorCode
+}
+
+func (d *ChunkDecryptor) fail(err error) error {
+	switch {
+	case d.checkCancelled() != nil:
+		d.errorCode = ChunkErrorCancelled
+	case d.source.err != nil:
+		d.errorCode = ChunkErrorSource
+	default:
+		d.errorCode = ChunkErrorCrypto
+	}
+	return errors.Wrap(err, "gopenpgp: chunk decryption failed")
+}
@@ -0,0 +1,117 @@
+package mobile
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/ProtonMail/gopenpgp/v3/crypto"
+)
+
+type testChunkSink struct {
+	chunks      [][]byte
+	returnError bool
+}
+
+func (s *testChunkSink) WriteChunk(chunk []byte) error {
+	if s.returnError {
+		return errors.New("gopenpgp: test - forced error while writing")
+	}
+	s.chunks = append(s.chunks, clone(chunk))
+	return nil
+}
+
+func TestChunkEncryptDecrypt(t *testing.T) {
+	data := bytes.Repeat([]byte("hello chunks "), 1000)
+	chunkSize := 1024
+	pgpHandle, pubKR, privKR, err := setUpTestKeyRing()
+	if err != nil {
+		t.Fatalf("Got an error while loading test key: %v", err)
+	}
+	defer privKR.ClearPrivateParams()
+	encHandle, _ := pgpHandle.Encryption().Recipients(pubKR).SigningKeys(privKR).New()
+	sink := &testChunkSink{}
+	encryptor, err := NewChunkEncryptor(encHandle, sink, crypto.Bytes, chunkSize)
+	if err != nil {
+		t.Fatalf("Got an error while creating the chunk encryptor: %v", err)
+	}
+	for offset := 0; offset < len(data); offset += 100 {
+		end := offset + 100
+		if end > len(data) {
+			end = len(data)
+		}
+		if err := encryptor.Push(data[offset:end]); err != nil {
+			t.Fatalf("Got an error while pushing a chunk: %v", err)
+		}
+	}
+	if err := encryptor.Finish(); err != nil {
+		t.Fatalf("Got an error while finishing the encryption: %v", err)
+	}
+	if code := encryptor.ErrorCode(); code != ChunkErrorClosed {
+		t.Fatalf("expected error code %d after finish, got %d", ChunkErrorClosed, code)
+	}
+	var ciphertext []byte
+	for i, chunk := range sink.chunks {
+		if i < len(sink.chunks)-1 && len(chunk) != chunkSize {
+			t.Fatalf("expected chunk %d to have size %d, got %d", i, chunkSize, len(chunk))
+		}
+		ciphertext = append(ciphertext, chunk...)
+	}
+
+	decHandle, _ := pgpHandle.Decryption().DecryptionKeys(privKR).VerificationKeys(pubKR).New()
+	decryptor, err := NewChunkDecryptor(decHandle, &testMobileReader{bytes.NewReader(ciphertext), false}, crypto.Bytes, chunkSize)
+	if err != nil {
+		t.Fatalf("Got an error while creating the chunk decryptor: %v", err)
+	}
+	var plaintext []byte
+	reachedEnd := false
+	for !reachedEnd {
+		result, err := decryptor.Pull()
+		if err != nil {
+			t.Fatalf("Got an error while pulling a chunk: %v", err)
+		}
+		reachedEnd = result.IsEOF
+		if !reachedEnd && result.N != chunkSize {
+			t.Fatalf("expected chunk to have size %d, got %d", chunkSize, result.N)
+		}
+		plaintext = append(plaintext, result.Data[:result.N]...)
+	}
+	if !bytes.Equal(data, plaintext) {
+		t.Fatalf("expected plaintext to be %x, got %x", data, plaintext)
+	}
+	verifyResult, err := decryptor.VerifySignature()
+	if err != nil {
+		t.Fatalf("Got an error while verifying: %v", err)
+	}
+	if err = verifyResult.SignatureError(); err != nil {
+		t.Fatalf("Got a signature error while verifying embedded sig: %v", err)
+	}
+}
+
+func TestChunkErrorCodes(t *testing.T) {
+	pgpHandle, pubKR, privKR, err := setUpTestKeyRing()
+	if err != nil {
+		t.Fatalf("Got an error while loading test key: %v", err)
+	}
+	defer privKR.ClearPrivateParams()
+	encHandle, _ := pgpHandle.Encryption().Recipients(pubKR).New()
+	encryptor, err := NewChunkEncryptor(encHandle, &testChunkSink{returnError: true}, crypto.Bytes, 1024)
+	if err != nil {
+		t.Fatalf("Got an error while creating the chunk encryptor: %v", err)
+	}
+	_ = encryptor.Push(bytes.Repeat([]byte{0x01}, 4096))
+	if err := encryptor.Finish(); err == nil {
+		t.Fatal("expected an error while finishing, got nil")
+	}
+	if code := encryptor.ErrorCode(); code != ChunkErrorSink {
+		t.Fatalf("expected error code %d, got %d", ChunkErrorSink, code)
+	}
+
+	decHandle, _ := pgpHandle.Decryption().DecryptionKeys(privKR).New()
+	if _, err := NewChunkDecryptor(decHandle, &testMobileReader{bytes.NewReader(nil), true}, crypto.Bytes, 16); err == nil {
+		t.Fatal("expected an error while creating the chunk decryptor, got nil")
+	}
+	if _, err := NewChunkDecryptor(decHandle, &testMobileReader{bytes.NewReader(nil), false}, crypto.Bytes, 0); err == nil {
+		t.Fatal("expected an error for a non-positive chunk size, got nil")
+	}
+}
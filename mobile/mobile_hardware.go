@@ -0,0 +1,286 @@
+package mobile
+
+import (
+	"bytes"
+	stdcrypto "crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/binary"
+	"io"
+
+	"github.com/ProtonMail/go-crypto/openpgp/aes/keywrap"
+	"github.com/ProtonMail/go-crypto/openpgp/ecdh"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/ProtonMail/gopenpgp/v3/constants"
+	"github.com/ProtonMail/gopenpgp/v3/crypto"
+	"github.com/pkg/errors"
+)
+
+// HardwareKey is the interface that the mobile app implements around a private key
+// that is held in hardware, e.g., in the Secure Enclave on iOS or in the Android Keystore.
+// The private key never enters the golang runtime, only digests, signatures,
+// public points, and shared secrets cross the interface.
+type HardwareKey interface {
+	// PublicKey returns the public key as DER encoded SubjectPublicKeyInfo,
+	// e.g., SecKeyCopyExternalRepresentation wrapped in an SPKI header on iOS,
+	// or PublicKey.getEncoded on Android.
+	PublicKey() ([]byte, error)
+	// Sign signs the digest, which was computed with the hash algorithm named
+	// "SHA224", "SHA256", "SHA384", or "SHA512".
+	// ECDSA keys must return ASN.1 encoded signatures, RSA keys PKCS#1 v1.5 signatures.
+	Sign(digest []byte, hash string) ([]byte, error)
+	// SharedSecret performs ECDH with the uncompressed public point of the peer,
+	// and returns the x coordinate of the shared point.
+	// Keys that cannot perform ECDH return an error.
+	SharedSecret(peerPoint []byte) ([]byte, error)
+}
+
+// hashNames maps the hash functions used in OpenPGP signatures to the names passed to HardwareKey.Sign.
+var hashNames = map[stdcrypto.Hash]string{
+	stdcrypto.SHA224: "SHA224",
+	stdcrypto.SHA256: "SHA256",
+	stdcrypto.SHA384: "SHA384",
+	stdcrypto.SHA512: "SHA512",
+}
+
+// hardwareSigner implements crypto.ExternalPrivateKey and crypto.Signer of the standard library
+// around a HardwareKey of the app.
+type hardwareSigner struct {
+	key       HardwareKey
+	publicKey stdcrypto.PublicKey
+}
+
+func newHardwareSigner(key HardwareKey) (*hardwareSigner, error) {
+	encoded, err := key.PublicKey()
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: couldn't get the public key of the hardware key")
+	}
+	publicKey, err := x509.ParsePKIXPublicKey(clone(encoded))
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: couldn't parse the public key of the hardware key")
+	}
+	switch publicKey.(type) {
+	case *rsa.PublicKey, *ecdsa.PublicKey:
+	default:
+		return nil, errors.Errorf("gopenpgp: unsupported hardware key type %T", publicKey)
+	}
+	return &hardwareSigner{key: key, publicKey: publicKey}, nil
+}
+
+func (s *hardwareSigner) Public() stdcrypto.PublicKey {
+	return s.publicKey
+}
+
+func (s *hardwareSigner) Sign(_ io.Reader, digest []byte, opts stdcrypto.SignerOpts) ([]byte, error) {
+	if _, ok := opts.(*rsa.PSSOptions); ok {
+		return nil, errors.New("gopenpgp: hardware rsa-pss signatures are not supported")
+	}
+	hash, ok := hashNames[opts.HashFunc()]
+	if !ok {
+		return nil, errors.Errorf("gopenpgp: hash %d is not supported for hardware keys", opts.HashFunc())
+	}
+	signature, err := s.key.Sign(clone(digest), hash)
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: hardware signing failed")
+	}
+	return clone(signature), nil
+}
+
+// NewHardwareSigningKey creates a v4 key around the hardware key of the app,
+// which becomes the primary key that can sign and certify, with the given user id.
+// Since the creation time is part of the OpenPGP fingerprint, the same creation time
+// must be used to recreate the same key, e.g., after a restart of the app.
+// See crypto.NewKeyFromExternalPrivateKey.
+func NewHardwareSigningKey(hardwareKey HardwareKey, name, email string, creationTime int64) (*crypto.Key, error) {
+	signer, err := newHardwareSigner(hardwareKey)
+	if err != nil {
+		return nil, err
+	}
+	return crypto.NewKeyFromExternalPrivateKey(signer, name, email, creationTime)
+}
+
+// NewKeyWithHardwareSigningKey returns a private key with the public key material of publicKey,
+// whose primary key or signing subkey with the public key of the hardware key signs with the hardware key.
+// See crypto.NewKeyWithExternalPrivateKeys.
+func NewKeyWithHardwareSigningKey(publicKey *crypto.Key, hardwareKey HardwareKey) (*crypto.Key, error) {
+	signer, err := newHardwareSigner(hardwareKey)
+	if err != nil {
+		return nil, err
+	}
+	return crypto.NewKeyWithExternalPrivateKeys(publicKey, signer)
+}
+
+// DecryptSessionKeyWithHardwareKey decrypts the session key from the key packets
+// that are encrypted to the ECDH subkey of recipient, whose private key is the hardware key.
+// The ECDH key agreement is performed by the hardware key, and the returned
+// session key can be used to decrypt the data packets in a decryption handle.
+func DecryptSessionKeyWithHardwareKey(keyPackets []byte, recipient *crypto.Key, hardwareKey HardwareKey) (*crypto.SessionKey, error) {
+	signer, err := newHardwareSigner(hardwareKey)
+	if err != nil {
+		return nil, err
+	}
+	hardwarePublicKey, ok := signer.publicKey.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, errors.New("gopenpgp: hardware key is not an elliptic curve key")
+	}
+	curveOid, ok := ecdhCurveOids[hardwarePublicKey.Curve]
+	if !ok {
+		return nil, errors.New("gopenpgp: unsupported hardware key curve")
+	}
+	//nolint:staticcheck // the uncompressed point encoding is required by OpenPGP
+	point := elliptic.Marshal(hardwarePublicKey.Curve, hardwarePublicKey.X, hardwarePublicKey.Y)
+	recipientKey := ecdhKeyWithPoint(recipient, point)
+	if recipientKey == nil {
+		return nil, errors.New("gopenpgp: recipient has no ecdh key matching the hardware key")
+	}
+	packets := packet.NewReader(bytes.NewReader(keyPackets))
+	for {
+		p, err := packets.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "gopenpgp: couldn't parse key packets")
+		}
+		encryptedKey, ok := p.(*packet.EncryptedKey)
+		if !ok || encryptedKey.Version != 3 || encryptedKey.Algo != packet.PubKeyAlgoECDH ||
+			(encryptedKey.KeyId != recipientKey.KeyId && encryptedKey.KeyId != 0) {
+			continue
+		}
+		sessionKey, err := decryptECDHSessionKey(encryptedKey, recipientKey, curveOid, hardwareKey)
+		if err == nil {
+			return sessionKey, nil
+		}
+	}
+	return nil, errors.New("gopenpgp: no key packet could be decrypted with the hardware key")
+}
+
+// ecdhCurveOids are the OpenPGP object identifiers of the NIST curves with their length octet.
+var ecdhCurveOids = map[elliptic.Curve][]byte{
+	elliptic.P256(): {0x08, 0x2a, 0x86, 0x48, 0xce, 0x3d, 0x03, 0x01, 0x07},
+	elliptic.P384(): {0x05, 0x2b, 0x81, 0x04, 0x00, 0x22},
+	elliptic.P521(): {0x05, 0x2b, 0x81, 0x04, 0x00, 0x23},
+}
+
+// ecdhKeyWithPoint returns the v4 ECDH primary key or subkey of key with the given public point.
+func ecdhKeyWithPoint(key *crypto.Key, point []byte) *packet.PublicKey {
+	entity := key.GetEntity()
+	candidates := []*packet.PublicKey{entity.PrimaryKey}
+	for _, subkey := range entity.Subkeys {
+		candidates = append(candidates, subkey.PublicKey)
+	}
+	for _, candidate := range candidates {
+		if candidate.Version != 4 || candidate.PubKeyAlgo != packet.PubKeyAlgoECDH {
+			continue
+		}
+		if ecdhKey, ok := candidate.PublicKey.(*ecdh.PublicKey); ok && bytes.Equal(ecdhKey.Point, point) {
+			return candidate
+		}
+	}
+	return nil
+}
+
+// decryptECDHSessionKey decrypts the session key of a v3 ECDH key packet as in RFC 6637, section 8,
+// with the shared secret computed by the hardware key.
+func decryptECDHSessionKey(
+	encryptedKey *packet.EncryptedKey,
+	recipientKey *packet.PublicKey,
+	curveOid []byte,
+	hardwareKey HardwareKey,
+) (*crypto.SessionKey, error) {
+	ephemeralPoint, wrappedKey, err := parseECDHKeyPacket(encryptedKey)
+	if err != nil {
+		return nil, err
+	}
+	sharedSecret, err := hardwareKey.SharedSecret(ephemeralPoint)
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: hardware key agreement failed")
+	}
+	ecdhKey := recipientKey.PublicKey.(*ecdh.PublicKey)
+	// Param = curve_OID_len || curve_OID || public_key_alg_ID || 03 || 01 || KDF_hash_ID
+	//         || KEK_alg_ID for AESKeyWrap || "Anonymous Sender    " || recipient_fingerprint
+	kdf := ecdhKey.KDF.Hash.New()
+	_, _ = kdf.Write([]byte{0x00, 0x00, 0x00, 0x01})
+	_, _ = kdf.Write(clone(sharedSecret))
+	_, _ = kdf.Write(curveOid)
+	_, _ = kdf.Write([]byte{byte(packet.PubKeyAlgoECDH), 0x03, 0x01, ecdhKey.KDF.Hash.Id(), ecdhKey.KDF.Cipher.Id()})
+	_, _ = kdf.Write([]byte("Anonymous Sender    "))
+	_, _ = kdf.Write(recipientKey.Fingerprint)
+	kek := kdf.Sum(nil)[:ecdhKey.KDF.Cipher.KeySize()]
+	decoded, err := keywrap.Unwrap(kek, wrappedKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: couldn't unwrap the session key")
+	}
+	// decoded = symm_alg_ID || session key || checksum || pkcs5_padding
+	padding := int(decoded[len(decoded)-1])
+	if padding == 0 || padding > len(decoded)-3 {
+		return nil, errors.New("gopenpgp: invalid session key padding")
+	}
+	decoded = decoded[:len(decoded)-padding]
+	cipherFunc := packet.CipherFunction(decoded[0])
+	token := decoded[1 : len(decoded)-2]
+	var checksum uint16
+	for _, b := range token {
+		checksum += uint16(b)
+	}
+	if subtle.ConstantTimeCompare(decoded[len(decoded)-2:], []byte{byte(checksum >> 8), byte(checksum)}) != 1 {
+		return nil, errors.New("gopenpgp: invalid session key checksum")
+	}
+	algo, ok := cipherNames[cipherFunc]
+	if !ok || cipherFunc.KeySize() != len(token) {
+		return nil, errors.Errorf("gopenpgp: unsupported session key cipher %d", cipherFunc)
+	}
+	return crypto.NewSessionKeyFromToken(token, algo), nil
+}
+
+// cipherNames maps the cipher functions to the algorithm names of session keys.
+var cipherNames = map[packet.CipherFunction]string{
+	packet.Cipher3DES:   constants.TripleDES,
+	packet.CipherCAST5:  constants.CAST5,
+	packet.CipherAES128: constants.AES128,
+	packet.CipherAES192: constants.AES192,
+	packet.CipherAES256: constants.AES256,
+}
+
+// parseECDHKeyPacket returns the ephemeral public point and the wrapped session key of a v3 ECDH key packet.
+// go-crypto does not expose the encrypted fields, thus they are parsed from the serialized packet.
+func parseECDHKeyPacket(encryptedKey *packet.EncryptedKey) (ephemeralPoint, wrappedKey []byte, err error) {
+	var serialized bytes.Buffer
+	if err := encryptedKey.Serialize(&serialized); err != nil {
+		return nil, nil, errors.Wrap(err, "gopenpgp: couldn't serialize key packet")
+	}
+	body := serialized.Bytes()[1:]
+	// Skip the new format length of the packet header.
+	switch {
+	case len(body) > 0 && body[0] < 192:
+		body = body[1:]
+	case len(body) > 1 && body[0] < 224:
+		body = body[2:]
+	case len(body) > 4 && body[0] == 255:
+		body = body[5:]
+	default:
+		return nil, nil, errors.New("gopenpgp: malformed key packet")
+	}
+	// The body is the version, the key id, the algorithm, the ephemeral point as MPI,
+	// and the wrapped session key with a length octet.
+	const fieldsOffset = 1 + 8 + 1
+	if len(body) < fieldsOffset+2 {
+		return nil, nil, errors.New("gopenpgp: malformed key packet")
+	}
+	body = body[fieldsOffset:]
+	pointLength := (int(binary.BigEndian.Uint16(body)) + 7) / 8
+	body = body[2:]
+	if len(body) < pointLength+1 {
+		return nil, nil, errors.New("gopenpgp: malformed key packet")
+	}
+	ephemeralPoint, body = body[:pointLength], body[pointLength:]
+	wrappedLength := int(body[0])
+	body = body[1:]
+	if len(body) < wrappedLength {
+		return nil, nil, errors.New("gopenpgp: malformed key packet")
+	}
+	return ephemeralPoint, body[:wrappedLength], nil
+}
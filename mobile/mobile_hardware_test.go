@@ -0,0 +1,119 @@
+package mobile
+
+import (
+	stdcrypto "crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp/ecdh"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/ProtonMail/gopenpgp/v3/crypto"
+	"github.com/ProtonMail/gopenpgp/v3/profile"
+)
+
+// testHardwareKey emulates a hardware key of the app with a software key on the NIST P-256 curve.
+type testHardwareKey struct {
+	publicKey *ecdsa.PublicKey
+	d         *big.Int
+}
+
+func (k *testHardwareKey) PublicKey() ([]byte, error) {
+	return x509.MarshalPKIXPublicKey(k.publicKey)
+}
+
+func (k *testHardwareKey) Sign(digest []byte, hash string) ([]byte, error) {
+	return ecdsa.SignASN1(rand.Reader, &ecdsa.PrivateKey{PublicKey: *k.publicKey, D: k.d}, digest)
+}
+
+func (k *testHardwareKey) SharedSecret(peerPoint []byte) ([]byte, error) {
+	//nolint:staticcheck // the test emulates the point encoding of OpenPGP
+	x, y := elliptic.Unmarshal(elliptic.P256(), peerPoint)
+	sharedX, _ := elliptic.P256().ScalarMult(x, y, k.d.Bytes())
+	return sharedX.FillBytes(make([]byte, 32)), nil
+}
+
+func TestHardwareSigningKey(t *testing.T) {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal("Expected no error while generating the test key, got:", err)
+	}
+	hardwareKey := &testHardwareKey{publicKey: &privateKey.PublicKey, d: privateKey.D}
+	key, err := NewHardwareSigningKey(hardwareKey, "test", "test@protonmail.com", time.Now().Unix())
+	if err != nil {
+		t.Fatal("Expected no error while creating the hardware signing key, got:", err)
+	}
+	keyRing, _ := crypto.NewKeyRing(key)
+	pgpHandle := crypto.PGP()
+	signHandle, _ := pgpHandle.Sign().SigningKeys(keyRing).Detached().New()
+	data := []byte("hello hardware")
+	signature, err := signHandle.Sign(data, crypto.Bytes)
+	if err != nil {
+		t.Fatal("Expected no error while signing, got:", err)
+	}
+	// Keys with external private keys cannot be copied, thus ToPublic is not supported.
+	serializedPublicKey, _ := key.GetPublicKey()
+	publicKey, _ := crypto.NewKey(serializedPublicKey)
+	verifyKeyRing, _ := crypto.NewKeyRing(publicKey)
+	verifyHandle, _ := pgpHandle.Verify().VerificationKeys(verifyKeyRing).New()
+	result, err := verifyHandle.VerifyDetached(data, signature, crypto.Bytes)
+	if err != nil {
+		t.Fatal("Expected no error while verifying, got:", err)
+	}
+	if err := result.SignatureError(); err != nil {
+		t.Fatal("Expected no signature error, got:", err)
+	}
+	if _, err := NewKeyWithHardwareSigningKey(publicKey, hardwareKey); err != nil {
+		t.Fatal("Expected no error while attaching the hardware key to the public key, got:", err)
+	}
+}
+
+func TestDecryptSessionKeyWithHardwareKey(t *testing.T) {
+	nistProfile := profile.Default()
+	nistProfile.SetKeyAlgorithm = func(cfg *packet.Config, _ int8) {
+		cfg.Algorithm = packet.PubKeyAlgoECDSA
+		cfg.Curve = packet.CurveNistP256
+	}
+	nistProfile.Hash = stdcrypto.SHA256
+	pgpHandle := crypto.PGPWithProfile(nistProfile)
+	key, err := pgpHandle.KeyGeneration().AddUserId("test", "test@protonmail.com").New().GenerateKey()
+	if err != nil {
+		t.Fatal("Expected no error while generating the key, got:", err)
+	}
+	// Move the ECDH private key of the subkey into the emulated hardware key.
+	ecdhPrivateKey := key.GetEntity().Subkeys[0].PrivateKey.PrivateKey.(*ecdh.PrivateKey)
+	d := new(big.Int).SetBytes(ecdhPrivateKey.D)
+	x, y := elliptic.P256().ScalarBaseMult(ecdhPrivateKey.D)
+	hardwareKey := &testHardwareKey{publicKey: &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, d: d}
+	publicKey, _ := key.ToPublic()
+	recipients, _ := crypto.NewKeyRing(publicKey)
+
+	data := []byte("hello hardware")
+	encHandle, _ := pgpHandle.Encryption().Recipients(recipients).New()
+	message, err := encHandle.Encrypt(data)
+	if err != nil {
+		t.Fatal("Expected no error while encrypting, got:", err)
+	}
+	sessionKey, err := DecryptSessionKeyWithHardwareKey(message.KeyPacket, publicKey, hardwareKey)
+	if err != nil {
+		t.Fatal("Expected no error while decrypting the session key, got:", err)
+	}
+	decHandle, _ := pgpHandle.Decryption().SessionKey(sessionKey).New()
+	result, err := decHandle.Decrypt(message.Bytes(), crypto.Bytes)
+	if err != nil {
+		t.Fatal("Expected no error while decrypting, got:", err)
+	}
+	if result.String() != string(data) {
+		t.Fatalf("expected plaintext %q, got %q", data, result.String())
+	}
+
+	otherKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	wrongHardwareKey := &testHardwareKey{publicKey: &otherKey.PublicKey, d: otherKey.D}
+	if _, err := DecryptSessionKeyWithHardwareKey(message.KeyPacket, publicKey, wrongHardwareKey); err == nil {
+		t.Fatal("expected an error with a hardware key of another recipient, got nil")
+	}
+}
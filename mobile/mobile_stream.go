@@ -12,26 +12,35 @@ import (
 
 // Mobile2GoWriter is used to wrap a writer in the mobile app runtime,
 // to be usable in the golang runtime (via gomobile).
+// A CancelToken and a ProgressCallback can be set to abort writes and observe the written bytes.
 type Mobile2GoWriter struct {
+	streamControl
 	writer crypto.Writer
 }
 
 // NewMobile2GoWriter wraps a writer to be usable in the golang runtime (via gomobile).
 func NewMobile2GoWriter(writer crypto.Writer) *Mobile2GoWriter {
-	return &Mobile2GoWriter{writer}
+	return &Mobile2GoWriter{writer: writer}
 }
 
 // Write writes the data in the provided buffer in the wrapped writer.
 // It clones the provided data to prevent errors with garbage collectors.
 func (w *Mobile2GoWriter) Write(b []byte) (n int, err error) {
+	if err := w.checkCancelled(); err != nil {
+		return 0, err
+	}
 	bufferCopy := clone(b)
-	return w.writer.Write(bufferCopy)
+	n, err = w.writer.Write(bufferCopy)
+	w.addProgress(n)
+	return n, err
 }
 
 // Mobile2GoWriterWithSHA256 is used to wrap a writer in the mobile app runtime,
 // to be usable in the golang runtime (via gomobile).
 // It also computes the SHA256 hash of the data being written on the fly.
+// A CancelToken and a ProgressCallback can be set to abort writes and observe the written bytes.
 type Mobile2GoWriterWithSHA256 struct {
+	streamControl
 	writer crypto.Writer
 	sha256 hash.Hash
 }
@@ -39,15 +48,19 @@ type Mobile2GoWriterWithSHA256 struct {
 // NewMobile2GoWriterWithSHA256 wraps a writer to be usable in the golang runtime (via gomobile).
 // The wrapper also computes the SHA256 hash of the data being written on the fly.
 func NewMobile2GoWriterWithSHA256(writer crypto.Writer) *Mobile2GoWriterWithSHA256 {
-	return &Mobile2GoWriterWithSHA256{writer, sha256.New()}
+	return &Mobile2GoWriterWithSHA256{writer: writer, sha256: sha256.New()}
 }
 
 // Write writes the data in the provided buffer in the wrapped writer.
 // It clones the provided data to prevent errors with garbage collectors.
 // It also computes the SHA256 hash of the data being written on the fly.
 func (w *Mobile2GoWriterWithSHA256) Write(b []byte) (n int, err error) {
+	if err := w.checkCancelled(); err != nil {
+		return 0, err
+	}
 	bufferCopy := clone(b)
 	n, err = w.writer.Write(bufferCopy)
+	w.addProgress(n)
 	if err == nil {
 		hashedTotal := 0
 		for hashedTotal < n {
@@ -95,18 +108,23 @@ func clone(src []byte) (dst []byte) {
 
 // Mobile2GoReader is used to wrap a MobileReader in the mobile app runtime,
 // to be usable in the golang runtime (via gomobile) as a native Reader.
+// A CancelToken and a ProgressCallback can be set to abort reads and observe the read bytes.
 type Mobile2GoReader struct {
+	streamControl
 	reader MobileReader
 }
 
 // NewMobile2GoReader wraps a MobileReader to be usable in the golang runtime (via gomobile).
 func NewMobile2GoReader(reader MobileReader) *Mobile2GoReader {
-	return &Mobile2GoReader{reader}
+	return &Mobile2GoReader{reader: reader}
 }
 
 // Read reads data from the wrapped MobileReader and copies the read data in the provided buffer.
 // It also handles the conversion of EOF to an error.
 func (r *Mobile2GoReader) Read(b []byte) (n int, err error) {
+	if err := r.checkCancelled(); err != nil {
+		return 0, err
+	}
 	result, err := r.reader.Read(len(b))
 	if err != nil {
 		return 0, errors.Wrap(err, "gopenpgp: couldn't read from mobile reader")
@@ -115,6 +133,7 @@ func (r *Mobile2GoReader) Read(b []byte) (n int, err error) {
 	if n > 0 {
 		copy(b, result.Data[:n])
 	}
+	r.addProgress(n)
 	if result.IsEOF {
 		err = io.EOF
 	}
@@ -123,7 +142,9 @@ func (r *Mobile2GoReader) Read(b []byte) (n int, err error) {
 
 // Go2AndroidReader is used to wrap a native golang Reader in the golang runtime,
 // to be usable in the android app runtime (via gomobile).
+// A CancelToken and a ProgressCallback can be set to abort reads and observe the read bytes.
 type Go2AndroidReader struct {
+	streamControl
 	isEOF  bool
 	reader crypto.Reader
 }
@@ -131,7 +152,7 @@ type Go2AndroidReader struct {
 // NewGo2AndroidReader wraps a native golang Reader to be usable in the mobile app runtime (via gomobile).
 // It doesn't follow the standard golang Reader behavior, and returns n = -1 on EOF.
 func NewGo2AndroidReader(reader crypto.Reader) *Go2AndroidReader {
-	return &Go2AndroidReader{isEOF: false, reader: reader}
+	return &Go2AndroidReader{reader: reader}
 }
 
 // Read reads bytes into the provided buffer and returns the number of bytes read
@@ -140,7 +161,11 @@ func (r *Go2AndroidReader) Read(b []byte) (n int, err error) {
 	if r.isEOF {
 		return -1, nil
 	}
+	if err := r.checkCancelled(); err != nil {
+		return 0, err
+	}
 	n, err = r.reader.Read(b)
+	r.addProgress(n)
 	if errors.Is(err, io.EOF) {
 		if n == 0 {
 			return -1, nil
@@ -154,19 +179,25 @@ func (r *Go2AndroidReader) Read(b []byte) (n int, err error) {
 
 // Go2IOSReader is used to wrap a native golang Reader in the golang runtime,
 // to be usable in the iOS app runtime (via gomobile) as a MobileReader.
+// A CancelToken and a ProgressCallback can be set to abort reads and observe the read bytes.
 type Go2IOSReader struct {
+	streamControl
 	reader crypto.Reader
 }
 
 // NewGo2IOSReader wraps a native golang Reader to be usable in the ios app runtime (via gomobile).
 func NewGo2IOSReader(reader crypto.Reader) *Go2IOSReader {
-	return &Go2IOSReader{reader}
+	return &Go2IOSReader{reader: reader}
 }
 
 // Read reads at most <max> bytes from the wrapped Reader and returns the read data as a MobileReadResult.
 func (r *Go2IOSReader) Read(max int) (result *MobileReadResult, err error) {
+	if err := r.checkCancelled(); err != nil {
+		return nil, err
+	}
 	b := make([]byte, max)
 	n, err := r.reader.Read(b)
+	r.addProgress(n)
 	result = &MobileReadResult{}
 	if err != nil {
 		if errors.Is(err, io.EOF) {
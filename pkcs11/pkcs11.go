@@ -0,0 +1,430 @@
+//go:build cgo
+// +build cgo
+
+// Package pkcs11 provides an API to use the private keys on PKCS#11 tokens,
+// e.g., hardware security modules and smartcards, as gopenpgp keys.
+// The private key operations are performed by the token, and the private key material never leaves it.
+// RSA keys can sign and decrypt, ECDSA keys on the NIST curves can sign.
+package pkcs11
+
+import (
+	stdcrypto "crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/asn1"
+	"io"
+	"math/big"
+	"sync"
+
+	"github.com/ProtonMail/gopenpgp/v3/crypto"
+	p11 "github.com/miekg/pkcs11"
+	"github.com/pkg/errors"
+)
+
+// findObjectsBatchSize is the number of object handles requested per FindObjects call.
+const findObjectsBatchSize = 64
+
+var (
+	oidCurveP256 = asn1.ObjectIdentifier{1, 2, 840, 10045, 3, 1, 7}
+	oidCurveP384 = asn1.ObjectIdentifier{1, 3, 132, 0, 34}
+	oidCurveP521 = asn1.ObjectIdentifier{1, 3, 132, 0, 35}
+)
+
+// digestInfoPrefixes contains the DER encoded DigestInfo prefixes of PKCS#1 v1.5 signatures,
+// since the CKM_RSA_PKCS mechanism signs the DigestInfo instead of the digest.
+var digestInfoPrefixes = map[stdcrypto.Hash][]byte{
+	stdcrypto.SHA1:     {0x30, 0x21, 0x30, 0x09, 0x06, 0x05, 0x2b, 0x0e, 0x03, 0x02, 0x1a, 0x05, 0x00, 0x04, 0x14},
+	stdcrypto.SHA224:   {0x30, 0x2d, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x04, 0x05, 0x00, 0x04, 0x1c},
+	stdcrypto.SHA256:   {0x30, 0x31, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x01, 0x05, 0x00, 0x04, 0x20},
+	stdcrypto.SHA384:   {0x30, 0x41, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x02, 0x05, 0x00, 0x04, 0x30},
+	stdcrypto.SHA512:   {0x30, 0x51, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x03, 0x05, 0x00, 0x04, 0x40},
+	stdcrypto.SHA3_256: {0x30, 0x31, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x08, 0x05, 0x00, 0x04, 0x20},
+	stdcrypto.SHA3_512: {0x30, 0x51, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x0a, 0x05, 0x00, 0x04, 0x40},
+}
+
+// Module is a loaded PKCS#11 module, i.e., the shared library of a token vendor.
+type Module struct {
+	ctx *p11.Ctx
+}
+
+// Open loads and initializes the PKCS#11 module at modulePath,
+// e.g., /usr/lib/softhsm/libsofthsm2.so.
+// The module must be closed with Close once it is not needed anymore.
+func Open(modulePath string) (*Module, error) {
+	ctx := p11.New(modulePath)
+	if ctx == nil {
+		return nil, errors.Errorf("gopenpgp: loading pkcs11 module %s failed", modulePath)
+	}
+	if err := ctx.Initialize(); err != nil {
+		ctx.Destroy()
+		return nil, errors.Wrap(err, "gopenpgp: initializing pkcs11 module failed")
+	}
+	return &Module{ctx: ctx}, nil
+}
+
+// Close finalizes and unloads the module.
+// The sessions opened with the module must be closed before.
+func (module *Module) Close() error {
+	err := module.ctx.Finalize()
+	module.ctx.Destroy()
+	if err != nil {
+		return errors.Wrap(err, "gopenpgp: finalizing pkcs11 module failed")
+	}
+	return nil
+}
+
+// Slots returns the ids of the slots that contain a token.
+func (module *Module) Slots() ([]uint, error) {
+	slots, err := module.ctx.GetSlotList(true)
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: listing pkcs11 slots failed")
+	}
+	return slots, nil
+}
+
+// OpenSession opens a session with the token in the slot and logs in as user with the pin.
+// If the pin is empty, the session is not logged in, which some tokens allow for public objects.
+// The session must be closed with Close once its keys are not needed anymore.
+func (module *Module) OpenSession(slotID uint, pin string) (*Session, error) {
+	handle, err := module.ctx.OpenSession(slotID, p11.CKF_SERIAL_SESSION)
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: opening pkcs11 session failed")
+	}
+	session := &Session{ctx: module.ctx, handle: handle}
+	if pin != "" {
+		if err := module.ctx.Login(handle, p11.CKU_USER, pin); err != nil {
+			_ = module.ctx.CloseSession(handle)
+			return nil, errors.Wrap(err, "gopenpgp: pkcs11 login failed")
+		}
+		session.loggedIn = true
+	}
+	return session, nil
+}
+
+// Session is a session with a token.
+// Since PKCS#11 sessions cannot be used concurrently, the operations of the session
+// and its keys are serialized.
+type Session struct {
+	ctx      *p11.Ctx
+	handle   p11.SessionHandle
+	loggedIn bool
+	mutex    sync.Mutex
+}
+
+// Close logs out and closes the session.
+func (session *Session) Close() error {
+	session.mutex.Lock()
+	defer session.mutex.Unlock()
+	if session.loggedIn {
+		if err := session.ctx.Logout(session.handle); err != nil {
+			return errors.Wrap(err, "gopenpgp: pkcs11 logout failed")
+		}
+		session.loggedIn = false
+	}
+	if err := session.ctx.CloseSession(session.handle); err != nil {
+		return errors.Wrap(err, "gopenpgp: closing pkcs11 session failed")
+	}
+	return nil
+}
+
+// Keys returns the private keys on the token that can be used in OpenPGP,
+// i.e., RSA keys and ECDSA keys on the NIST curves.
+// Other private keys are skipped.
+func (session *Session) Keys() ([]*TokenKey, error) {
+	session.mutex.Lock()
+	defer session.mutex.Unlock()
+	var keys []*TokenKey
+	// Searching by key type avoids decoding the native CK_ULONG attribute values.
+	for _, keyType := range []uint{p11.CKK_RSA, p11.CKK_EC} {
+		handles, err := session.findObjects([]*p11.Attribute{
+			p11.NewAttribute(p11.CKA_CLASS, p11.CKO_PRIVATE_KEY),
+			p11.NewAttribute(p11.CKA_KEY_TYPE, keyType),
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, handle := range handles {
+			key, err := session.tokenKey(handle, keyType)
+			if err != nil {
+				return nil, err
+			}
+			if key != nil {
+				keys = append(keys, key)
+			}
+		}
+	}
+	return keys, nil
+}
+
+// Key returns a gopenpgp key with the public key material of publicKey,
+// whose primary key and subkeys delegate their private key operations
+// to the keys on the token with the same public key.
+// It fails if no key on the token matches the primary key or a subkey.
+// See crypto.NewKeyWithExternalPrivateKeys.
+func (session *Session) Key(publicKey *crypto.Key) (*crypto.Key, error) {
+	keys, err := session.Keys()
+	if err != nil {
+		return nil, err
+	}
+	var matching []crypto.ExternalPrivateKey
+	for _, key := range keys {
+		// Keys that do not belong to publicKey are rejected.
+		if _, err := crypto.NewKeyWithExternalPrivateKeys(publicKey, key); err == nil {
+			matching = append(matching, key)
+		}
+	}
+	if len(matching) == 0 {
+		return nil, errors.New("gopenpgp: no key on the token matches the public key")
+	}
+	return crypto.NewKeyWithExternalPrivateKeys(publicKey, matching...)
+}
+
+// findObjects returns the handles of all objects that match the template.
+// The caller must hold the session mutex.
+func (session *Session) findObjects(template []*p11.Attribute) ([]p11.ObjectHandle, error) {
+	if err := session.ctx.FindObjectsInit(session.handle, template); err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: finding pkcs11 objects failed")
+	}
+	var handles []p11.ObjectHandle
+	for {
+		batch, _, err := session.ctx.FindObjects(session.handle, findObjectsBatchSize)
+		if err != nil {
+			_ = session.ctx.FindObjectsFinal(session.handle)
+			return nil, errors.Wrap(err, "gopenpgp: finding pkcs11 objects failed")
+		}
+		if len(batch) == 0 {
+			break
+		}
+		handles = append(handles, batch...)
+	}
+	if err := session.ctx.FindObjectsFinal(session.handle); err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: finding pkcs11 objects failed")
+	}
+	return handles, nil
+}
+
+// attributes reads the values of the attributes of the object.
+// The caller must hold the session mutex.
+func (session *Session) attributes(handle p11.ObjectHandle, types ...uint) (map[uint][]byte, error) {
+	template := make([]*p11.Attribute, len(types))
+	for index, attributeType := range types {
+		template[index] = p11.NewAttribute(attributeType, nil)
+	}
+	attributes, err := session.ctx.GetAttributeValue(session.handle, handle, template)
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: reading pkcs11 attributes failed")
+	}
+	values := make(map[uint][]byte, len(attributes))
+	for _, attribute := range attributes {
+		values[attribute.Type] = attribute.Value
+	}
+	return values, nil
+}
+
+// tokenKey reads the private key object of the key type with its public key.
+// It returns nil if the key cannot be used in OpenPGP.
+// The caller must hold the session mutex.
+func (session *Session) tokenKey(handle p11.ObjectHandle, keyType uint) (*TokenKey, error) {
+	values, err := session.attributes(handle, p11.CKA_ID, p11.CKA_LABEL)
+	if err != nil {
+		return nil, err
+	}
+	key := &TokenKey{
+		ID:      values[p11.CKA_ID],
+		Label:   string(values[p11.CKA_LABEL]),
+		session: session,
+		handle:  handle,
+		keyType: keyType,
+	}
+	switch keyType {
+	case p11.CKK_RSA:
+		// The private key object contains the public key material of RSA keys.
+		values, err := session.attributes(handle, p11.CKA_MODULUS, p11.CKA_PUBLIC_EXPONENT)
+		if err != nil {
+			return nil, err
+		}
+		key.publicKey, err = rsaPublicKey(values[p11.CKA_MODULUS], values[p11.CKA_PUBLIC_EXPONENT])
+		if err != nil {
+			return nil, err
+		}
+	case p11.CKK_EC:
+		// The EC point is only stored in the public key object with the same id.
+		publicHandles, err := session.findObjects([]*p11.Attribute{
+			p11.NewAttribute(p11.CKA_CLASS, p11.CKO_PUBLIC_KEY),
+			p11.NewAttribute(p11.CKA_KEY_TYPE, p11.CKK_EC),
+			p11.NewAttribute(p11.CKA_ID, key.ID),
+		})
+		if err != nil {
+			return nil, err
+		}
+		if len(publicHandles) == 0 {
+			return nil, nil
+		}
+		values, err := session.attributes(publicHandles[0], p11.CKA_EC_PARAMS, p11.CKA_EC_POINT)
+		if err != nil {
+			return nil, err
+		}
+		key.publicKey, err = ecdsaPublicKey(values[p11.CKA_EC_PARAMS], values[p11.CKA_EC_POINT])
+		if errors.Is(err, errUnsupportedCurve) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, nil
+	}
+	return key, nil
+}
+
+// TokenKey is a private key on a token.
+// It implements crypto.Signer and crypto.Decrypter of the standard library,
+// and crypto.ExternalPrivateKey.
+type TokenKey struct {
+	// ID is the CKA_ID attribute of the key.
+	ID []byte
+	// Label is the CKA_LABEL attribute of the key.
+	Label string
+
+	session   *Session
+	handle    p11.ObjectHandle
+	keyType   uint
+	publicKey stdcrypto.PublicKey
+}
+
+// Public returns the public key, i.e., a *rsa.PublicKey or a *ecdsa.PublicKey.
+func (key *TokenKey) Public() stdcrypto.PublicKey {
+	return key.publicKey
+}
+
+// NewKey creates a v4 gopenpgp key around the token key with the given user id.
+// Since the creation time is part of the OpenPGP fingerprint, the same creation time
+// must be used to recreate the same key.
+// See crypto.NewKeyFromExternalPrivateKey.
+func (key *TokenKey) NewKey(name, email string, creationTime int64) (*crypto.Key, error) {
+	return crypto.NewKeyFromExternalPrivateKey(key, name, email, creationTime)
+}
+
+// Sign signs the digest on the token.
+// RSA keys create PKCS#1 v1.5 signatures, ECDSA keys return ASN.1 encoded signatures.
+func (key *TokenKey) Sign(_ io.Reader, digest []byte, opts stdcrypto.SignerOpts) ([]byte, error) {
+	var mechanism uint
+	var data []byte
+	switch key.keyType {
+	case p11.CKK_RSA:
+		if _, ok := opts.(*rsa.PSSOptions); ok {
+			return nil, errors.New("gopenpgp: pkcs11 rsa-pss signatures are not supported")
+		}
+		prefix, ok := digestInfoPrefixes[opts.HashFunc()]
+		if !ok {
+			return nil, errors.Errorf("gopenpgp: hash %s is not supported for pkcs11 rsa signatures", opts.HashFunc())
+		}
+		mechanism = p11.CKM_RSA_PKCS
+		data = append(append([]byte(nil), prefix...), digest...)
+	case p11.CKK_EC:
+		mechanism = p11.CKM_ECDSA
+		data = digest
+	default:
+		return nil, errors.New("gopenpgp: pkcs11 key cannot sign")
+	}
+	signature, err := key.operation(mechanism, data, key.session.ctx.SignInit, key.session.ctx.Sign)
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: pkcs11 signing failed")
+	}
+	if key.keyType == p11.CKK_EC {
+		return ecdsaSignatureToASN1(signature)
+	}
+	return signature, nil
+}
+
+// Decrypt decrypts the PKCS#1 v1.5 encrypted ciphertext on the token.
+// Only RSA keys can decrypt.
+func (key *TokenKey) Decrypt(_ io.Reader, ciphertext []byte, opts stdcrypto.DecrypterOpts) ([]byte, error) {
+	if key.keyType != p11.CKK_RSA {
+		return nil, errors.New("gopenpgp: pkcs11 key cannot decrypt")
+	}
+	if _, ok := opts.(*rsa.OAEPOptions); ok {
+		return nil, errors.New("gopenpgp: pkcs11 rsa-oaep decryption is not supported")
+	}
+	plaintext, err := key.operation(p11.CKM_RSA_PKCS, ciphertext, key.session.ctx.DecryptInit, key.session.ctx.Decrypt)
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: pkcs11 decryption failed")
+	}
+	return plaintext, nil
+}
+
+// operation runs a single-part operation with the key on the token.
+func (key *TokenKey) operation(
+	mechanism uint,
+	data []byte,
+	init func(p11.SessionHandle, []*p11.Mechanism, p11.ObjectHandle) error,
+	run func(p11.SessionHandle, []byte) ([]byte, error),
+) ([]byte, error) {
+	session := key.session
+	session.mutex.Lock()
+	defer session.mutex.Unlock()
+	if err := init(session.handle, []*p11.Mechanism{p11.NewMechanism(mechanism, nil)}, key.handle); err != nil {
+		return nil, err
+	}
+	return run(session.handle, data)
+}
+
+var errUnsupportedCurve = errors.New("gopenpgp: unsupported pkcs11 ec curve")
+
+func rsaPublicKey(modulus, exponent []byte) (*rsa.PublicKey, error) {
+	e := new(big.Int).SetBytes(exponent)
+	if len(modulus) == 0 || !e.IsInt64() || e.Int64() < 3 || e.Int64() > 1<<31-1 {
+		return nil, errors.New("gopenpgp: invalid pkcs11 rsa public key")
+	}
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(modulus), E: int(e.Int64())}, nil
+}
+
+// ecdsaPublicKey decodes the CKA_EC_PARAMS and CKA_EC_POINT attributes of an EC public key.
+// The params contain the DER encoded curve oid, and the point is a DER encoded octet string
+// with the uncompressed point, although some tokens return the point without the octet string.
+func ecdsaPublicKey(params, point []byte) (*ecdsa.PublicKey, error) {
+	var oid asn1.ObjectIdentifier
+	if rest, err := asn1.Unmarshal(params, &oid); err != nil || len(rest) > 0 {
+		return nil, errUnsupportedCurve
+	}
+	var curve elliptic.Curve
+	switch {
+	case oid.Equal(oidCurveP256):
+		curve = elliptic.P256()
+	case oid.Equal(oidCurveP384):
+		curve = elliptic.P384()
+	case oid.Equal(oidCurveP521):
+		curve = elliptic.P521()
+	default:
+		return nil, errUnsupportedCurve
+	}
+	var encoded []byte
+	if rest, err := asn1.Unmarshal(point, &encoded); err != nil || len(rest) > 0 {
+		encoded = point
+	}
+	x, y := elliptic.Unmarshal(curve, encoded)
+	if x == nil {
+		return nil, errors.New("gopenpgp: invalid pkcs11 ec point")
+	}
+	return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+}
+
+// ecdsaSignatureToASN1 converts the concatenation of r and s returned by CKM_ECDSA
+// into the ASN.1 encoding of crypto/ecdsa.
+func ecdsaSignatureToASN1(signature []byte) ([]byte, error) {
+	if len(signature) == 0 || len(signature)%2 != 0 {
+		return nil, errors.New("gopenpgp: invalid pkcs11 ecdsa signature")
+	}
+	half := len(signature) / 2
+	encoded, err := asn1.Marshal(struct {
+		R, S *big.Int
+	}{
+		R: new(big.Int).SetBytes(signature[:half]),
+		S: new(big.Int).SetBytes(signature[half:]),
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: encoding ecdsa signature failed")
+	}
+	return encoded, nil
+}
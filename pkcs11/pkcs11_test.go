@@ -0,0 +1,128 @@
+//go:build cgo
+// +build cgo
+
+package pkcs11
+
+import (
+	stdcrypto "crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/asn1"
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/ProtonMail/gopenpgp/v3/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	_ stdcrypto.Signer          = (*TokenKey)(nil)
+	_ stdcrypto.Decrypter       = (*TokenKey)(nil)
+	_ crypto.ExternalPrivateKey = (*TokenKey)(nil)
+)
+
+func TestDigestInfoPrefixes(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	for hash, prefix := range digestInfoPrefixes {
+		digest := make([]byte, hash.Size())
+		_, err := rand.Read(digest)
+		require.NoError(t, err)
+		// Signing the raw DigestInfo like CKM_RSA_PKCS must result in a regular signature.
+		signature, err := rsa.SignPKCS1v15(rand.Reader, privateKey, 0, append(append([]byte(nil), prefix...), digest...))
+		require.NoError(t, err)
+		assert.NoError(t, rsa.VerifyPKCS1v15(&privateKey.PublicKey, hash, digest, signature), hash.String())
+	}
+}
+
+func TestECDSASignatureToASN1(t *testing.T) {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+	digest := sha256.Sum256([]byte("hello"))
+	r, s, err := ecdsa.Sign(rand.Reader, privateKey, digest[:])
+	require.NoError(t, err)
+	raw := make([]byte, 96)
+	r.FillBytes(raw[:48])
+	s.FillBytes(raw[48:])
+
+	signature, err := ecdsaSignatureToASN1(raw)
+	require.NoError(t, err)
+	assert.True(t, ecdsa.VerifyASN1(&privateKey.PublicKey, digest[:], signature))
+
+	_, err = ecdsaSignatureToASN1(raw[:95])
+	assert.Error(t, err)
+}
+
+func TestPublicKeyAttributes(t *testing.T) {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	params, err := asn1.Marshal(oidCurveP256)
+	require.NoError(t, err)
+	rawPoint := elliptic.Marshal(elliptic.P256(), privateKey.X, privateKey.Y)
+	point, err := asn1.Marshal(rawPoint)
+	require.NoError(t, err)
+
+	for _, encoded := range [][]byte{point, rawPoint} {
+		publicKey, err := ecdsaPublicKey(params, encoded)
+		require.NoError(t, err)
+		assert.True(t, publicKey.Equal(&privateKey.PublicKey))
+	}
+	unsupported, err := asn1.Marshal(asn1.ObjectIdentifier{1, 3, 132, 0, 10})
+	require.NoError(t, err)
+	_, err = ecdsaPublicKey(unsupported, point)
+	assert.ErrorIs(t, err, errUnsupportedCurve)
+	_, err = ecdsaPublicKey(params, []byte{4, 1, 2})
+	assert.Error(t, err)
+
+	publicKey, err := rsaPublicKey([]byte{0xc5, 0x01}, []byte{1, 0, 1})
+	require.NoError(t, err)
+	assert.Equal(t, 65537, publicKey.E)
+	_, err = rsaPublicKey([]byte{0xc5, 0x01}, nil)
+	assert.Error(t, err)
+}
+
+// TestTokenKeys signs and verifies a message with each OpenPGP-compatible key on a token,
+// e.g., of SoftHSM.
+// Only runs if GOPENPGP_TEST_PKCS11_MODULE is set to the module path,
+// GOPENPGP_TEST_PKCS11_SLOT to the slot id, and GOPENPGP_TEST_PKCS11_PIN to the user pin.
+func TestTokenKeys(t *testing.T) {
+	modulePath := os.Getenv("GOPENPGP_TEST_PKCS11_MODULE")
+	if modulePath == "" {
+		t.Skip("set GOPENPGP_TEST_PKCS11_MODULE to run")
+	}
+	slotID, err := strconv.ParseUint(os.Getenv("GOPENPGP_TEST_PKCS11_SLOT"), 10, 64)
+	require.NoError(t, err)
+	module, err := Open(modulePath)
+	require.NoError(t, err)
+	defer func() { assert.NoError(t, module.Close()) }()
+	session, err := module.OpenSession(uint(slotID), os.Getenv("GOPENPGP_TEST_PKCS11_PIN"))
+	require.NoError(t, err)
+	defer func() { assert.NoError(t, session.Close()) }()
+
+	tokenKeys, err := session.Keys()
+	require.NoError(t, err)
+	for _, tokenKey := range tokenKeys {
+		key, err := tokenKey.NewKey("token", "token@example.com", 1700000000)
+		require.NoError(t, err, tokenKey.Label)
+		publicKey, err := key.ToPublic()
+		require.NoError(t, err)
+		sessionKey, err := session.Key(publicKey)
+		require.NoError(t, err)
+		assert.Equal(t, key.GetFingerprint(), sessionKey.GetFingerprint())
+
+		signer, err := crypto.PGP().Sign().SigningKey(sessionKey).Detached().New()
+		require.NoError(t, err)
+		signature, err := signer.Sign([]byte("hello"), crypto.Bytes)
+		require.NoError(t, err, tokenKey.Label)
+		verifier, err := crypto.PGP().Verify().VerificationKey(publicKey).New()
+		require.NoError(t, err)
+		result, err := verifier.VerifyDetached([]byte("hello"), signature, crypto.Bytes)
+		require.NoError(t, err)
+		assert.NoError(t, result.SignatureError(), tokenKey.Label)
+	}
+}
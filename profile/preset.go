@@ -53,6 +53,8 @@ func RFC4880() *Custom {
 
 // RFC9580 returns a custom profile for this library
 // that conforms with the algorithms in RFC9580.
+// It generates v6 keys with Ed25519 signing and X25519 encryption keys,
+// or with Ed448 signing and X448 encryption keys for constants.HighSecurity.
 func RFC9580() *Custom {
 	setKeyAlgorithm := func(cfg *packet.Config, securityLevel int8) {
 		switch securityLevel {
@@ -80,3 +82,13 @@ func RFC9580() *Custom {
 		V6: true,
 	}
 }
+
+// StrictRFC9580 returns a custom profile for teams that target RFC9580-only ecosystems.
+// It uses the algorithms of RFC9580(), and refuses to create v4 keys,
+// v4 signatures, and SEIPDv1 data packets, e.g., when encrypting to v4 keys without
+// SEIPDv2 support. Legacy constructs of decrypted messages are reported as a LegacyWarning.
+func StrictRFC9580() *Custom {
+	profile := RFC9580()
+	profile.StrictRFC9580 = true
+	return profile
+}
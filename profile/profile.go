@@ -50,6 +50,9 @@ type Custom struct {
 	InsecureAllowWeakRSA bool
 	// InsecureAllowDecryptionWithSigningKeys is a flag to enable to decrypt with signing keys for compatibility reasons.
 	InsecureAllowDecryptionWithSigningKeys bool
+	// StrictRFC9580 is a flag to refuse creating v4 keys, v4 signatures, and SEIPDv1 data packets,
+	// and to report legacy constructs of decrypted messages.
+	StrictRFC9580 bool
 }
 
 // Custom implements the profile interfaces:
@@ -89,6 +92,12 @@ func (p *Custom) EncryptionConfig() *packet.Config {
 	return config
 }
 
+// IsStrictRFC9580 returns true if the profile only creates RFC9580 artifacts,
+// see the StrictRFC9580 flag.
+func (p *Custom) IsStrictRFC9580() bool {
+	return p.StrictRFC9580
+}
+
 func (p *Custom) KeyEncryptionConfig() *packet.Config {
 	return &packet.Config{
 		DefaultHash:   p.Hash,
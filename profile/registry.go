@@ -0,0 +1,73 @@
+package profile
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Names of the pre-defined profiles in the registry.
+const (
+	NameDefault       = "default"
+	NameRFC4880       = "rfc4880"
+	NameRFC9580       = "rfc9580"
+	NameStrictRFC9580 = "rfc9580-strict"
+)
+
+var (
+	registryLock sync.RWMutex
+	registry     = map[string]func() *Custom{
+		NameDefault:       Default,
+		NameRFC4880:       RFC4880,
+		NameRFC9580:       RFC9580,
+		NameStrictRFC9580: StrictRFC9580,
+	}
+)
+
+// Register registers a named profile, such that integrators can select their own
+// algorithm preferences by name, e.g., with crypto.PGPWithProfileName.
+// The constructor is called on each lookup, thus each handle gets a fresh profile
+// that is not affected by changes to the profiles of other handles.
+// Names of registered profiles, including the pre-defined ones, cannot be reused.
+func Register(name string, newProfile func() *Custom) error {
+	if name == "" {
+		return errors.New("gopenpgp: profile name must not be empty")
+	}
+	if newProfile == nil {
+		return errors.New("gopenpgp: no profile constructor given")
+	}
+	if profile := newProfile(); profile == nil || profile.SetKeyAlgorithm == nil {
+		return errors.New("gopenpgp: profile must define SetKeyAlgorithm")
+	}
+	registryLock.Lock()
+	defer registryLock.Unlock()
+	if _, ok := registry[name]; ok {
+		return errors.Errorf("gopenpgp: profile %q is already registered", name)
+	}
+	registry[name] = newProfile
+	return nil
+}
+
+// Named returns a new instance of the profile registered under the name.
+func Named(name string) (*Custom, error) {
+	registryLock.RLock()
+	newProfile, ok := registry[name]
+	registryLock.RUnlock()
+	if !ok {
+		return nil, errors.Errorf("gopenpgp: unknown profile %q", name)
+	}
+	return newProfile(), nil
+}
+
+// Names returns the sorted names of all registered profiles.
+func Names() []string {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,264 @@
+// Package refresh updates certificates from key servers and key directories,
+// such that revocations, new subkeys, and extended expirations of the keys of
+// others are picked up.
+package refresh
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ProtonMail/gopenpgp/v3/crypto"
+	"github.com/pkg/errors"
+)
+
+// defaultConcurrency is the number of keys refreshed in parallel by default.
+const defaultConcurrency = 4
+
+// Refresher fetches the current version of keys from its sources and merges
+// the updates into the keys, see (*crypto.Key).Merge.
+// Only certificates with the same primary key are merged, and private key material
+// of the sources is ignored.
+type Refresher struct {
+	// Sources are queried in order for each key.
+	Sources []Source
+	// Concurrency is the number of keys that are refreshed in parallel, 4 if zero.
+	Concurrency int
+	// MinInterval is the minimal time between the start of two fetches from the same source,
+	// e.g., to respect the rate limits of key servers. If zero, fetches are not limited.
+	MinInterval time.Duration
+}
+
+// FetchError is the failure of a source to fetch a key, or of merging the fetched key.
+type FetchError struct {
+	// Fingerprint is the hex encoded fingerprint of the key.
+	Fingerprint string
+	// Source is the index of the source in Refresher.Sources, or -1 if merging failed.
+	Source int
+	// Err is the cause of the failure.
+	Err error
+}
+
+// Error implements the error interface.
+func (err *FetchError) Error() string {
+	if err.Source < 0 {
+		return fmt.Sprintf("gopenpgp: refreshing key %s failed: %v", err.Fingerprint, err.Err)
+	}
+	return fmt.Sprintf("gopenpgp: refreshing key %s from source %d failed: %v", err.Fingerprint, err.Source, err.Err)
+}
+
+// Unwrap returns the cause of the failure.
+func (err *FetchError) Unwrap() error {
+	return err.Err
+}
+
+// Result is the outcome of a refresh.
+type Result struct {
+	// Keys contains the refreshed keys in the order of the refreshed keys.
+	// Keys without updates are the original keys.
+	Keys []*crypto.Key
+	// Updated contains the fingerprints of the keys with new user ids, subkeys, or signatures.
+	Updated []string
+	// Revoked contains the fingerprints of the keys that are revoked at the time
+	// of the refresh, but were not revoked before.
+	Revoked []string
+	// Expired contains the fingerprints of the keys that are expired at the time
+	// of the refresh, but were not expired before, e.g., due to a shortened expiration.
+	Expired []string
+	// Errors contains the failed fetches. Keys are still refreshed from the other sources.
+	Errors []*FetchError
+}
+
+// KeyRing returns a key ring with the refreshed keys.
+// Locked private keys cannot be part of key rings and are skipped.
+func (result *Result) KeyRing() *crypto.KeyRing {
+	keyRing := &crypto.KeyRing{}
+	for _, key := range result.Keys {
+		_ = keyRing.AddKey(key)
+	}
+	return keyRing
+}
+
+// RefreshKeyRing refreshes the keys of the key ring, and evaluates the revocations and
+// expirations at unixTime. The key ring is not modified, see Result.KeyRing.
+// An error is only returned if the context is done, the failures of the sources
+// are listed in the result.
+func (refresher *Refresher) RefreshKeyRing(ctx context.Context, keyRing *crypto.KeyRing, unixTime int64) (*Result, error) {
+	return refresher.RefreshKeys(ctx, keyRing.GetKeys(), unixTime)
+}
+
+// RefreshKeyStore refreshes the keys of the key store, see RefreshKeyRing,
+// and puts the updated keys into the store.
+func (refresher *Refresher) RefreshKeyStore(ctx context.Context, store crypto.KeyStore, unixTime int64) (*Result, error) {
+	fingerprints, err := store.List()
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]*crypto.Key, 0, len(fingerprints))
+	for _, fingerprint := range fingerprints {
+		key, err := store.Get(fingerprint)
+		if err != nil {
+			return nil, err
+		}
+		if key != nil {
+			keys = append(keys, key)
+		}
+	}
+	result, err := refresher.RefreshKeys(ctx, keys, unixTime)
+	if err != nil {
+		return nil, err
+	}
+	updated := make(map[string]bool, len(result.Updated))
+	for _, fingerprint := range result.Updated {
+		updated[fingerprint] = true
+	}
+	for _, key := range result.Keys {
+		if !updated[key.GetFingerprint()] {
+			continue
+		}
+		if err := store.Put(key); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// RefreshKeys refreshes the keys, see RefreshKeyRing.
+func (refresher *Refresher) RefreshKeys(ctx context.Context, keys []*crypto.Key, unixTime int64) (*Result, error) {
+	limiters := make([]*limiter, len(refresher.Sources))
+	for index := range limiters {
+		limiters[index] = &limiter{interval: refresher.MinInterval}
+	}
+	concurrency := refresher.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultConcurrency
+	}
+	refreshed := make([]*crypto.Key, len(keys))
+	fetchErrors := make([][]*FetchError, len(keys))
+	indices := make(chan int)
+	var wg sync.WaitGroup
+	for worker := 0; worker < concurrency && worker < len(keys); worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range indices {
+				refreshed[index], fetchErrors[index] = refresher.refreshKey(ctx, keys[index], limiters)
+			}
+		}()
+	}
+	for index := range keys {
+		if ctx.Err() != nil {
+			break
+		}
+		indices <- index
+	}
+	close(indices)
+	wg.Wait()
+	if ctx.Err() != nil {
+		return nil, errors.Wrap(ctx.Err(), "gopenpgp: refresh aborted")
+	}
+
+	result := &Result{Keys: refreshed}
+	for index, key := range keys {
+		result.Errors = append(result.Errors, fetchErrors[index]...)
+		newKey := refreshed[index]
+		if newKey == key {
+			continue
+		}
+		fingerprint := key.GetFingerprint()
+		result.Updated = append(result.Updated, fingerprint)
+		revoked := newKey.IsRevoked(unixTime)
+		if revoked && !key.IsRevoked(unixTime) {
+			result.Revoked = append(result.Revoked, fingerprint)
+		}
+		if !revoked && newKey.IsExpired(unixTime) && !key.IsExpired(unixTime) {
+			result.Expired = append(result.Expired, fingerprint)
+		}
+	}
+	return result, nil
+}
+
+// refreshKey fetches the key from all sources and returns the merged key,
+// or the key itself if the sources have no updates.
+func (refresher *Refresher) refreshKey(ctx context.Context, key *crypto.Key, limiters []*limiter) (*crypto.Key, []*FetchError) {
+	fingerprint := key.GetFingerprint()
+	var fetchErrors []*FetchError
+	merged := key
+	for index, source := range refresher.Sources {
+		if err := limiters[index].wait(ctx); err != nil {
+			break
+		}
+		fetched, err := source.Fetch(ctx, key)
+		if err != nil {
+			fetchErrors = append(fetchErrors, &FetchError{Fingerprint: fingerprint, Source: index, Err: err})
+			continue
+		}
+		if fetched == nil {
+			continue
+		}
+		for _, fetchedKey := range fetched.GetKeys() {
+			if fetchedKey.GetFingerprint() != fingerprint {
+				continue
+			}
+			if fetchedKey.IsPrivate() {
+				if fetchedKey, err = fetchedKey.ToPublic(); err != nil {
+					fetchErrors = append(fetchErrors, &FetchError{Fingerprint: fingerprint, Source: index, Err: err})
+					continue
+				}
+			}
+			newKey, err := merged.Merge(fetchedKey)
+			if err != nil {
+				fetchErrors = append(fetchErrors, &FetchError{Fingerprint: fingerprint, Source: -1, Err: err})
+				continue
+			}
+			if packetCount(newKey) > packetCount(merged) {
+				merged = newKey
+			}
+		}
+	}
+	return merged, fetchErrors
+}
+
+// packetCount returns the number of user ids, subkeys, and signatures of the key.
+// Since merging only adds packets, a merged key with more packets has updates.
+func packetCount(key *crypto.Key) int {
+	entity := key.GetEntity()
+	count := len(entity.Revocations) + len(entity.DirectSignatures)
+	for _, identity := range entity.Identities {
+		count += 1 + len(identity.SelfCertifications) + len(identity.OtherCertifications) + len(identity.Revocations)
+	}
+	for _, subkey := range entity.Subkeys {
+		count += 1 + len(subkey.Bindings) + len(subkey.Revocations)
+	}
+	return count
+}
+
+// limiter spaces the fetches from a source by a minimal interval.
+type limiter struct {
+	interval time.Duration
+	mutex    sync.Mutex
+	next     time.Time
+}
+
+// wait blocks until the next fetch may start, or the context is done.
+func (limiter *limiter) wait(ctx context.Context) error {
+	if limiter.interval <= 0 {
+		return ctx.Err()
+	}
+	limiter.mutex.Lock()
+	start := time.Now()
+	if start.Before(limiter.next) {
+		start = limiter.next
+	}
+	limiter.next = start.Add(limiter.interval)
+	limiter.mutex.Unlock()
+	timer := time.NewTimer(time.Until(start))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
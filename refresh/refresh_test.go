@@ -0,0 +1,216 @@
+package refresh
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ProtonMail/gopenpgp/v3/constants"
+	"github.com/ProtonMail/gopenpgp/v3/crypto"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testKeys are private keys, their public keys, and the updated public keys of a key server.
+type testKeys struct {
+	names   []string
+	private map[string]*crypto.Key
+	public  map[string]*crypto.Key
+	server  map[string]*crypto.Key
+}
+
+func newTestKeys(t *testing.T) *testKeys {
+	keys := &testKeys{
+		names:   []string{"alice", "bob", "carol", "dave"},
+		private: make(map[string]*crypto.Key),
+		public:  make(map[string]*crypto.Key),
+		server:  make(map[string]*crypto.Key),
+	}
+	for _, name := range keys.names {
+		key, err := crypto.PGP().KeyGeneration().AddUserId(name, name+"@example.com").New().GenerateKey()
+		require.NoError(t, err)
+		keys.private[name] = key
+		keys.public[name], err = key.ToPublic()
+		require.NoError(t, err)
+	}
+	creationTime := keys.private["alice"].GetEntity().PrimaryKey.CreationTime.Unix()
+
+	// Alice revoked her key, Bob added a subkey, Carol did not change her key,
+	// and Dave shortened the expiration of his key.
+	certificate, err := keys.private["alice"].GenerateRevocationCertificate(constants.RevocationKeyCompromised, "", creationTime+1)
+	require.NoError(t, err)
+	alice, err := keys.public["alice"].ApplyRevocationCertificate([]byte(certificate))
+	require.NoError(t, err)
+	bob, err := keys.private["bob"].AddSubkey(0, crypto.KeyCapabilityEncrypt, 0, creationTime+1)
+	require.NoError(t, err)
+	dave, err := keys.private["dave"].SetExpiration(time.Second, nil, creationTime+1)
+	require.NoError(t, err)
+	for name, key := range map[string]*crypto.Key{"alice": alice, "bob": bob, "carol": keys.private["carol"], "dave": dave} {
+		if key.IsPrivate() {
+			key, err = key.ToPublic()
+			require.NoError(t, err)
+		}
+		keys.server[name] = key
+	}
+	return keys
+}
+
+func (keys *testKeys) publicKeyRing(t *testing.T) *crypto.KeyRing {
+	keyRing := &crypto.KeyRing{}
+	for _, name := range keys.names {
+		require.NoError(t, keyRing.AddKey(keys.public[name]))
+	}
+	return keyRing
+}
+
+// source returns a source that serves the keys of the server by fingerprint.
+func (keys *testKeys) source() Source {
+	return SourceFunc(func(ctx context.Context, key *crypto.Key) (*crypto.KeyRing, error) {
+		for _, serverKey := range keys.server {
+			if serverKey.GetFingerprint() == key.GetFingerprint() {
+				return crypto.NewKeyRing(serverKey)
+			}
+		}
+		return nil, nil
+	})
+}
+
+func (keys *testKeys) fingerprints(names ...string) []string {
+	fingerprints := make([]string, len(names))
+	for index, name := range names {
+		fingerprints[index] = keys.public[name].GetFingerprint()
+	}
+	return fingerprints
+}
+
+func TestRefreshKeyRing(t *testing.T) {
+	keys := newTestKeys(t)
+	failure := errors.New("unavailable")
+	refresher := &Refresher{
+		Sources: []Source{
+			SourceFunc(func(ctx context.Context, key *crypto.Key) (*crypto.KeyRing, error) {
+				return nil, failure
+			}),
+			keys.source(),
+			// Other keys and the private key material of sources are ignored.
+			SourceFunc(func(ctx context.Context, key *crypto.Key) (*crypto.KeyRing, error) {
+				keyRing := &crypto.KeyRing{}
+				for _, name := range keys.names {
+					if err := keyRing.AddKey(keys.private[name]); err != nil {
+						return nil, err
+					}
+				}
+				return keyRing, nil
+			}),
+		},
+		Concurrency: 2,
+	}
+	unixTime := time.Now().Unix() + 100
+	result, err := refresher.RefreshKeyRing(context.Background(), keys.publicKeyRing(t), unixTime)
+	require.NoError(t, err)
+
+	assert.Exactly(t, keys.fingerprints("alice", "bob", "dave"), result.Updated)
+	assert.Exactly(t, keys.fingerprints("alice"), result.Revoked)
+	assert.Exactly(t, keys.fingerprints("dave"), result.Expired)
+	require.Len(t, result.Errors, len(keys.names))
+	for index, fetchError := range result.Errors {
+		assert.Exactly(t, keys.public[keys.names[index]].GetFingerprint(), fetchError.Fingerprint)
+		assert.Exactly(t, 0, fetchError.Source)
+		assert.ErrorIs(t, fetchError, failure)
+	}
+	assert.Same(t, keys.public["carol"].GetEntity(), result.Keys[2].GetEntity())
+	for index, key := range result.Keys {
+		assert.False(t, key.IsPrivate())
+		assert.Exactly(t, keys.public[keys.names[index]].GetFingerprint(), key.GetFingerprint())
+	}
+	assert.Len(t, result.Keys[1].GetEntity().Subkeys, len(keys.public["bob"].GetEntity().Subkeys)+1)
+	assert.Exactly(t, len(keys.names), result.KeyRing().CountEntities())
+
+	// Refreshing the refreshed keys finds no updates.
+	result, err = refresher.RefreshKeyRing(context.Background(), result.KeyRing(), unixTime)
+	require.NoError(t, err)
+	assert.Empty(t, result.Updated)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = refresher.RefreshKeyRing(ctx, keys.publicKeyRing(t), unixTime)
+	assert.Error(t, err)
+}
+
+func TestRefreshRateLimit(t *testing.T) {
+	keys := newTestKeys(t)
+	var mutex sync.Mutex
+	var fetchTimes []time.Time
+	refresher := &Refresher{
+		Sources: []Source{SourceFunc(func(ctx context.Context, key *crypto.Key) (*crypto.KeyRing, error) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			fetchTimes = append(fetchTimes, time.Now())
+			return nil, nil
+		})},
+		Concurrency: len(keys.names),
+		MinInterval: 20 * time.Millisecond,
+	}
+	_, err := refresher.RefreshKeyRing(context.Background(), keys.publicKeyRing(t), time.Now().Unix())
+	require.NoError(t, err)
+	require.Len(t, fetchTimes, len(keys.names))
+	// Single gaps vary with the scheduling of the workers, but timers never fire early.
+	minSpan := time.Duration(len(keys.names)-1) * refresher.MinInterval
+	assert.GreaterOrEqual(t, fetchTimes[len(fetchTimes)-1].Sub(fetchTimes[0]), minSpan*3/4)
+}
+
+func TestRefreshKeyStore(t *testing.T) {
+	keys := newTestKeys(t)
+	store, err := crypto.NewFileKeyStore(filepath.Join(t.TempDir(), "keys.pgp"), []byte("password"))
+	require.NoError(t, err)
+	for _, name := range keys.names {
+		require.NoError(t, store.Put(keys.public[name]))
+	}
+	refresher := &Refresher{Sources: []Source{keys.source()}}
+	result, err := refresher.RefreshKeyStore(context.Background(), store, time.Now().Unix()+100)
+	require.NoError(t, err)
+	assert.Exactly(t, keys.fingerprints("alice", "bob", "dave"), result.Updated)
+
+	alice, err := store.Get(keys.public["alice"].GetFingerprint())
+	require.NoError(t, err)
+	assert.True(t, alice.IsRevoked(time.Now().Unix()+100))
+}
+
+func TestKeyServerSources(t *testing.T) {
+	keys := newTestKeys(t)
+	bob := keys.server["bob"]
+	armored, err := bob.GetArmoredPublicKey()
+	require.NoError(t, err)
+	fingerprint := strings.ToUpper(bob.GetFingerprint())
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.RequestURI())
+		switch {
+		case r.URL.Path == "/pks/lookup" && r.URL.Query().Get("search") == "0x"+fingerprint:
+			_, _ = w.Write([]byte(armored))
+		case r.URL.Path == "/vks/v1/by-fingerprint/"+fingerprint:
+			_, _ = w.Write([]byte(armored))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	for _, source := range []Source{HKPSource(server.URL+"/", nil), VKSSource(server.URL, server.Client())} {
+		fetched, err := source.Fetch(context.Background(), keys.public["bob"])
+		require.NoError(t, err)
+		require.NotNil(t, fetched)
+		assert.Exactly(t, bob.GetFingerprint(), fetched.GetKeys()[0].GetFingerprint())
+		fetched, err = source.Fetch(context.Background(), keys.public["carol"])
+		require.NoError(t, err)
+		assert.Nil(t, fetched)
+	}
+	assert.Exactly(t, "/pks/lookup?op=get&options=mr&search=0x"+fingerprint, requests[0])
+	assert.Exactly(t, "/vks/v1/by-fingerprint/"+fingerprint, requests[2])
+}
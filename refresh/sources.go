@@ -0,0 +1,144 @@
+package refresh
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/ProtonMail/gopenpgp/v3/armor"
+	"github.com/ProtonMail/gopenpgp/v3/crypto"
+	"github.com/ProtonMail/gopenpgp/v3/dane"
+	"github.com/ProtonMail/gopenpgp/v3/wkd"
+	"github.com/pkg/errors"
+)
+
+// maxKeySize is the maximal size in bytes of a key fetched from a key server.
+const maxKeySize = 1 << 20
+
+// Source fetches the current version of certificates, e.g., from a key server.
+type Source interface {
+	// Fetch returns the keys the source has for the key, or nil if it has none.
+	// The returned keys may include other keys, which are ignored.
+	Fetch(ctx context.Context, key *crypto.Key) (*crypto.KeyRing, error)
+}
+
+// SourceFunc is an adapter to use a function as Source.
+type SourceFunc func(ctx context.Context, key *crypto.Key) (*crypto.KeyRing, error)
+
+// Fetch calls f(ctx, key).
+func (f SourceFunc) Fetch(ctx context.Context, key *crypto.Key) (*crypto.KeyRing, error) {
+	return f(ctx, key)
+}
+
+// WKDSource returns a source that looks up the email addresses of the user ids
+// of the keys in Web Key Directories with the client, or the default client if nil.
+func WKDSource(client *wkd.Client) Source {
+	if client == nil {
+		client = &wkd.Client{}
+	}
+	return emailSource(client.Lookup)
+}
+
+// DANESource returns a source that looks up the email addresses of the user ids
+// of the keys in OPENPGPKEY records with the client, or the default client if nil.
+func DANESource(client *dane.Client) Source {
+	if client == nil {
+		client = &dane.Client{}
+	}
+	return emailSource(client.Lookup)
+}
+
+// emailSource returns a source that looks up each email address of the key.
+func emailSource(lookup func(ctx context.Context, email string) (*crypto.KeyRing, error)) Source {
+	return SourceFunc(func(ctx context.Context, key *crypto.Key) (*crypto.KeyRing, error) {
+		keyRing, err := crypto.NewKeyRing(key)
+		if err != nil {
+			return nil, err
+		}
+		found := &crypto.KeyRing{}
+		for _, identity := range keyRing.GetIdentities() {
+			if identity.Email == "" {
+				continue
+			}
+			keys, err := lookup(ctx, identity.Email)
+			if err != nil {
+				return nil, err
+			}
+			if keys == nil {
+				continue
+			}
+			for _, key := range keys.GetKeys() {
+				if err := found.AddKey(key); err != nil {
+					return nil, err
+				}
+			}
+		}
+		return found, nil
+	})
+}
+
+// HKPSource returns a source that fetches keys by fingerprint from the HKP key server
+// at the base URL, e.g., "https://keyserver.ubuntu.com", with the HTTP client,
+// or http.DefaultClient if nil.
+func HKPSource(baseURL string, httpClient *http.Client) Source {
+	baseURL = strings.TrimSuffix(baseURL, "/")
+	return SourceFunc(func(ctx context.Context, key *crypto.Key) (*crypto.KeyRing, error) {
+		query := url.Values{
+			"op":      {"get"},
+			"options": {"mr"},
+			"search":  {"0x" + strings.ToUpper(key.GetFingerprint())},
+		}
+		return fetchArmoredKeys(ctx, httpClient, baseURL+"/pks/lookup?"+query.Encode())
+	})
+}
+
+// VKSSource returns a source that fetches keys by fingerprint from the verifying key server
+// at the base URL, e.g., "https://keys.openpgp.org", with the HTTP client,
+// or http.DefaultClient if nil.
+func VKSSource(baseURL string, httpClient *http.Client) Source {
+	baseURL = strings.TrimSuffix(baseURL, "/")
+	return SourceFunc(func(ctx context.Context, key *crypto.Key) (*crypto.KeyRing, error) {
+		return fetchArmoredKeys(ctx, httpClient, baseURL+"/vks/v1/by-fingerprint/"+strings.ToUpper(key.GetFingerprint()))
+	})
+}
+
+// fetchArmoredKeys returns the armored keys at the URL, or nil if the server does not have them.
+func fetchArmoredKeys(ctx context.Context, httpClient *http.Client, keyURL string) (*crypto.KeyRing, error) {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, keyURL, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: key server request failed")
+	}
+	response, err := httpClient.Do(request)
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: key server request failed")
+	}
+	defer func() { _ = response.Body.Close() }()
+	switch response.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, nil
+	default:
+		return nil, errors.Errorf("gopenpgp: key server request failed with status %d", response.StatusCode)
+	}
+	armored, err := io.ReadAll(io.LimitReader(response.Body, maxKeySize+1))
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: key server request failed")
+	}
+	if len(armored) > maxKeySize {
+		return nil, errors.New("gopenpgp: key server response exceeds the maximal size")
+	}
+	data, err := armor.UnarmorBytes(armored)
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: invalid key server response")
+	}
+	keyRing, err := crypto.NewKeyRingFromBinary(data)
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: invalid key server response")
+	}
+	return keyRing, nil
+}
@@ -0,0 +1,16 @@
+module github.com/ProtonMail/gopenpgp/v3/tpm
+
+go 1.20
+
+require (
+	github.com/google/go-tpm v0.9.0
+	github.com/pkg/errors v0.9.1
+	github.com/stretchr/testify v1.7.0
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.0 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	golang.org/x/sys v0.8.0 // indirect
+	gopkg.in/yaml.v3 v3.0.0-20200313102051-9f266ea9e77c // indirect
+)
@@ -0,0 +1,194 @@
+// Package tpm provides signing keys that reside in a TPM 2.0.
+// The keys implement crypto.Signer, and are turned into gopenpgp keys with
+// crypto.NewKeyFromExternalPrivateKey, which builds the OpenPGP certificate
+// around the public key of the TPM-resident key.
+// The private key material never leaves the TPM.
+// The package is a separate module since go-tpm requires Go 1.20, while gopenpgp supports Go 1.17.
+// It does not depend on the gopenpgp module, such that it can be used with any gopenpgp
+// version that provides crypto.NewKeyFromExternalPrivateKey.
+package tpm
+
+import (
+	stdcrypto "crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"encoding/asn1"
+	"io"
+	"math/big"
+	"sync"
+
+	"github.com/google/go-tpm/legacy/tpm2"
+	"github.com/google/go-tpm/tpmutil"
+	"github.com/pkg/errors"
+)
+
+const (
+	// AlgorithmRSA creates 2048 bit RSA signing keys.
+	AlgorithmRSA int8 = 1
+	// AlgorithmECDSA creates ECDSA signing keys on the NIST P-256 curve.
+	AlgorithmECDSA int8 = 2
+)
+
+// signingKeyAttributes are the attributes of an unrestricted signing key,
+// which can sign external digests, whose private part never leaves the TPM.
+const signingKeyAttributes = tpm2.FlagSign | tpm2.FlagFixedTPM | tpm2.FlagFixedParent |
+	tpm2.FlagSensitiveDataOrigin | tpm2.FlagUserWithAuth
+
+// Open opens the TPM device or simulator socket at path,
+// or the default TPM device of the platform if path is empty.
+// The returned TPM must be closed once its keys are not needed anymore.
+func Open(path string) (io.ReadWriteCloser, error) {
+	var device io.ReadWriteCloser
+	var err error
+	if path == "" {
+		device, err = tpm2.OpenTPM()
+	} else {
+		device, err = tpm2.OpenTPM(path)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: opening tpm failed")
+	}
+	return device, nil
+}
+
+// Key is a signing key in a TPM.
+// It implements crypto.Signer of the standard library, and thus the ExternalPrivateKey
+// interface of gopenpgp.
+type Key struct {
+	tpm       io.ReadWriter
+	handle    tpmutil.Handle
+	transient bool
+	publicKey stdcrypto.PublicKey
+	// mutex serializes the commands sent to the TPM.
+	mutex sync.Mutex
+}
+
+// CreateSigningKey creates a primary signing key of the algorithm,
+// i.e., AlgorithmRSA or AlgorithmECDSA, in the owner hierarchy of the TPM.
+// The key is derived from the seed of the owner hierarchy,
+// such that the same key is created again, e.g., after a reboot, until the TPM is cleared.
+// The key is loaded as a transient object, which must be released with Close.
+func CreateSigningKey(tpm io.ReadWriter, algorithm int8) (*Key, error) {
+	template := tpm2.Public{
+		NameAlg:    tpm2.AlgSHA256,
+		Attributes: signingKeyAttributes,
+	}
+	switch algorithm {
+	case AlgorithmRSA:
+		template.Type = tpm2.AlgRSA
+		template.RSAParameters = &tpm2.RSAParams{KeyBits: 2048}
+	case AlgorithmECDSA:
+		template.Type = tpm2.AlgECC
+		template.ECCParameters = &tpm2.ECCParams{CurveID: tpm2.CurveNISTP256}
+	default:
+		return nil, errors.Errorf("gopenpgp: unsupported tpm key algorithm %d", algorithm)
+	}
+	handle, publicKey, err := tpm2.CreatePrimary(tpm, tpm2.HandleOwner, tpm2.PCRSelection{}, "", "", template)
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: creating tpm signing key failed")
+	}
+	return &Key{tpm: tpm, handle: handle, transient: true, publicKey: publicKey}, nil
+}
+
+// LoadSigningKey wraps the signing key that is persisted in the TPM at handle,
+// e.g., 0x81000001, which was created by another application.
+// The key must be an unrestricted RSA or ECDSA signing key without authorization value.
+func LoadSigningKey(tpm io.ReadWriter, handle uint32) (*Key, error) {
+	public, _, _, err := tpm2.ReadPublic(tpm, tpmutil.Handle(handle))
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: reading tpm key failed")
+	}
+	if public.Attributes&tpm2.FlagSign == 0 || public.Attributes&tpm2.FlagRestricted != 0 {
+		return nil, errors.New("gopenpgp: tpm key is not an unrestricted signing key")
+	}
+	publicKey, err := public.Key()
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: reading tpm key failed")
+	}
+	return &Key{tpm: tpm, handle: tpmutil.Handle(handle), publicKey: publicKey}, nil
+}
+
+// Persist makes the key persistent in the TPM at handle, e.g., 0x81000001,
+// such that it can be loaded with LoadSigningKey.
+// The key then refers to the persistent object.
+func (key *Key) Persist(handle uint32) error {
+	key.mutex.Lock()
+	defer key.mutex.Unlock()
+	if !key.transient {
+		return errors.New("gopenpgp: tpm key is already persistent")
+	}
+	if err := tpm2.EvictControl(key.tpm, "", tpm2.HandleOwner, key.handle, tpmutil.Handle(handle)); err != nil {
+		return errors.Wrap(err, "gopenpgp: persisting tpm key failed")
+	}
+	if err := tpm2.FlushContext(key.tpm, key.handle); err != nil {
+		return errors.Wrap(err, "gopenpgp: flushing tpm key failed")
+	}
+	key.handle = tpmutil.Handle(handle)
+	key.transient = false
+	return nil
+}
+
+// Close releases the key if it is a transient object.
+// Persistent keys remain in the TPM.
+func (key *Key) Close() error {
+	key.mutex.Lock()
+	defer key.mutex.Unlock()
+	if !key.transient {
+		return nil
+	}
+	if err := tpm2.FlushContext(key.tpm, key.handle); err != nil {
+		return errors.Wrap(err, "gopenpgp: flushing tpm key failed")
+	}
+	key.transient = false
+	return nil
+}
+
+// Public returns the public key, i.e., a *rsa.PublicKey or a *ecdsa.PublicKey.
+func (key *Key) Public() stdcrypto.PublicKey {
+	return key.publicKey
+}
+
+// Sign signs the digest in the TPM.
+// RSA keys create PKCS#1 v1.5 signatures, ECDSA keys return ASN.1 encoded signatures.
+func (key *Key) Sign(_ io.Reader, digest []byte, opts stdcrypto.SignerOpts) ([]byte, error) {
+	hashAlgorithm, err := tpm2.HashToAlgorithm(opts.HashFunc())
+	if err != nil {
+		return nil, errors.Errorf("gopenpgp: hash %s is not supported by the tpm", opts.HashFunc())
+	}
+	scheme := &tpm2.SigScheme{Hash: hashAlgorithm}
+	switch key.publicKey.(type) {
+	case *rsa.PublicKey:
+		if _, ok := opts.(*rsa.PSSOptions); ok {
+			return nil, errors.New("gopenpgp: tpm rsa-pss signatures are not supported")
+		}
+		scheme.Alg = tpm2.AlgRSASSA
+	case *ecdsa.PublicKey:
+		scheme.Alg = tpm2.AlgECDSA
+	default:
+		return nil, errors.New("gopenpgp: tpm key cannot sign")
+	}
+	key.mutex.Lock()
+	signature, err := tpm2.Sign(key.tpm, key.handle, "", digest, nil, scheme)
+	key.mutex.Unlock()
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: tpm signing failed")
+	}
+	switch {
+	case signature.RSA != nil:
+		return signature.RSA.Signature, nil
+	case signature.ECC != nil:
+		return ecdsaSignatureToASN1(signature.ECC.R, signature.ECC.S)
+	}
+	return nil, errors.New("gopenpgp: unexpected tpm signature")
+}
+
+// ecdsaSignatureToASN1 encodes r and s like crypto/ecdsa.
+func ecdsaSignatureToASN1(r, s *big.Int) ([]byte, error) {
+	encoded, err := asn1.Marshal(struct {
+		R, S *big.Int
+	}{r, s})
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: encoding ecdsa signature failed")
+	}
+	return encoded, nil
+}
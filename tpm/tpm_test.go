@@ -0,0 +1,55 @@
+package tpm
+
+import (
+	stdcrypto "crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var _ stdcrypto.Signer = (*Key)(nil)
+
+// TestSigningKeys signs and verifies a digest with TPM keys of each algorithm.
+// Only runs if GOPENPGP_TEST_TPM is set to the path of a TPM device or simulator socket.
+func TestSigningKeys(t *testing.T) {
+	path := os.Getenv("GOPENPGP_TEST_TPM")
+	if path == "" {
+		t.Skip("set GOPENPGP_TEST_TPM to run")
+	}
+	device, err := Open(path)
+	require.NoError(t, err)
+	defer func() { assert.NoError(t, device.Close()) }()
+
+	digest := sha256.Sum256([]byte("hello"))
+	for _, algorithm := range []int8{AlgorithmRSA, AlgorithmECDSA} {
+		tpmKey, err := CreateSigningKey(device, algorithm)
+		require.NoError(t, err)
+
+		// The key is derived from the owner seed and has the same public key if created again,
+		// such that the OpenPGP key created around it has the same fingerprint.
+		recreated, err := CreateSigningKey(device, algorithm)
+		require.NoError(t, err)
+		assert.Equal(t, tpmKey.Public(), recreated.Public())
+		require.NoError(t, recreated.Close())
+
+		signature, err := tpmKey.Sign(rand.Reader, digest[:], stdcrypto.SHA256)
+		require.NoError(t, err)
+		switch publicKey := tpmKey.Public().(type) {
+		case *rsa.PublicKey:
+			assert.NoError(t, rsa.VerifyPKCS1v15(publicKey, stdcrypto.SHA256, digest[:], signature))
+		case *ecdsa.PublicKey:
+			assert.True(t, ecdsa.VerifyASN1(publicKey, digest[:], signature))
+		default:
+			t.Fatalf("unexpected public key type %T", publicKey)
+		}
+		require.NoError(t, tpmKey.Close())
+	}
+	_, err = CreateSigningKey(device, 0)
+	assert.Error(t, err)
+}
@@ -0,0 +1,191 @@
+// Package wkd implements the OpenPGP Web Key Directory, which discovers the public key
+// of an email address on a web server of the domain of the address.
+// The client fetches keys with the advanced method and falls back to the direct method,
+// and publications produce the files to serve one's own key.
+// See https://datatracker.ietf.org/doc/draft-koch-openpgp-webkey-service/.
+package wkd
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/base32"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/ProtonMail/gopenpgp/v3/crypto"
+	"github.com/pkg/errors"
+)
+
+// maxKeySize is the maximal size in bytes of a fetched key file.
+const maxKeySize = 1 << 20
+
+// zBase32 is the z-base-32 encoding of the hashed local parts.
+var zBase32 = base32.NewEncoding("ybndrfg8ejkmcpqxot1uwisza345h769").WithPadding(base32.NoPadding)
+
+// Client fetches keys from Web Key Directories.
+type Client struct {
+	// HTTPClient performs the requests, http.DefaultClient if nil.
+	HTTPClient *http.Client
+}
+
+// Lookup fetches the keys of the email address with the default client, see (*Client).Lookup.
+func Lookup(ctx context.Context, email string) (*crypto.KeyRing, error) {
+	return (&Client{}).Lookup(ctx, email)
+}
+
+// Lookup fetches the keys of the email address from the Web Key Directory of its domain.
+// The advanced method is tried first, and the direct method if the advanced method
+// fails or does not know the address.
+// Only the keys with a user id of the email address are returned,
+// and a nil key ring if the directory does not know the address.
+func (client *Client) Lookup(ctx context.Context, email string) (*crypto.KeyRing, error) {
+	advancedURL, err := AdvancedURL(email)
+	if err != nil {
+		return nil, err
+	}
+	directURL, err := DirectURL(email)
+	if err != nil {
+		return nil, err
+	}
+	data, err := client.fetch(ctx, advancedURL)
+	if err != nil || data == nil {
+		if ctx.Err() != nil {
+			return nil, errors.Wrap(ctx.Err(), "gopenpgp: wkd lookup failed")
+		}
+		if data, err = client.fetch(ctx, directURL); err != nil {
+			return nil, err
+		}
+	}
+	if data == nil {
+		return nil, nil
+	}
+	keyRing, err := crypto.NewKeyRingFromBinary(data)
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: invalid key in web key directory")
+	}
+	keys := keyRing.GetKeysByEmail(email)
+	if keys.CountEntities() == 0 {
+		return nil, nil
+	}
+	return keys, nil
+}
+
+// fetch returns the key file at the URL, or nil if the server does not have it.
+func (client *Client) fetch(ctx context.Context, keyURL string) ([]byte, error) {
+	httpClient := client.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, keyURL, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: wkd lookup failed")
+	}
+	response, err := httpClient.Do(request)
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: wkd lookup failed")
+	}
+	defer func() { _ = response.Body.Close() }()
+	switch response.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, nil
+	default:
+		return nil, errors.Errorf("gopenpgp: wkd lookup failed with status %d", response.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(response.Body, maxKeySize+1))
+	if err != nil {
+		return nil, errors.Wrap(err, "gopenpgp: wkd lookup failed")
+	}
+	if len(data) > maxKeySize {
+		return nil, errors.New("gopenpgp: wkd key exceeds the maximal size")
+	}
+	return data, nil
+}
+
+// AdvancedURL returns the URL of the key of the email address for the advanced method,
+// which is served by the openpgpkey subdomain of the domain.
+func AdvancedURL(email string) (string, error) {
+	localPart, domain, err := splitEmail(email)
+	if err != nil {
+		return "", err
+	}
+	return "https://openpgpkey." + domain + "/" + advancedPath(localPart, domain) +
+		"?l=" + url.QueryEscape(localPart), nil
+}
+
+// DirectURL returns the URL of the key of the email address for the direct method,
+// which is served by the domain itself.
+func DirectURL(email string) (string, error) {
+	localPart, domain, err := splitEmail(email)
+	if err != nil {
+		return "", err
+	}
+	return "https://" + domain + "/" + directPath(localPart) +
+		"?l=" + url.QueryEscape(localPart), nil
+}
+
+// HashLocalPart returns the z-base-32 encoded SHA-1 hash of the lower case local part
+// of an email address, which names the key files in a Web Key Directory.
+func HashLocalPart(localPart string) string {
+	// The WKD specification mandates SHA-1, which is not used for security here.
+	hash := sha1.Sum([]byte(strings.ToLower(localPart)))
+	return zBase32.EncodeToString(hash[:])
+}
+
+// Publication contains the file that publishes a key in a Web Key Directory.
+// The web server must also serve a policy file, which may be empty, at
+// ".well-known/openpgpkey/policy" for the direct method, or at
+// ".well-known/openpgpkey/<domain>/policy" for the advanced method.
+type Publication struct {
+	// AdvancedPath is the path of the key file on the openpgpkey subdomain for the advanced method.
+	AdvancedPath string
+	// DirectPath is the path of the key file on the domain for the direct method.
+	DirectPath string
+	// Key is the binary public key, minimized to the user id of the email address
+	// and without third-party certifications.
+	Key []byte
+}
+
+// NewPublication returns the file that publishes the key for the email address,
+// which must be the email address of a user id of the key.
+func NewPublication(key *crypto.Key, email string) (*Publication, error) {
+	localPart, domain, err := splitEmail(email)
+	if err != nil {
+		return nil, err
+	}
+	minimal, err := key.Minimize(&crypto.KeyExportOptions{
+		StripThirdPartyCertifications: true,
+		UserId:                        email,
+	})
+	if err != nil {
+		return nil, err
+	}
+	serialized, err := minimal.GetPublicKey()
+	if err != nil {
+		return nil, err
+	}
+	return &Publication{
+		AdvancedPath: advancedPath(localPart, domain),
+		DirectPath:   directPath(localPart),
+		Key:          serialized,
+	}, nil
+}
+
+func advancedPath(localPart, domain string) string {
+	return ".well-known/openpgpkey/" + domain + "/hu/" + HashLocalPart(localPart)
+}
+
+func directPath(localPart string) string {
+	return ".well-known/openpgpkey/hu/" + HashLocalPart(localPart)
+}
+
+// splitEmail returns the local part and the lower case domain of the email address.
+func splitEmail(email string) (localPart, domain string, err error) {
+	at := strings.LastIndex(email, "@")
+	if at <= 0 || at == len(email)-1 || strings.ContainsAny(email, " <>/?#") {
+		return "", "", errors.Errorf("gopenpgp: %q is not an email address", email)
+	}
+	return email[:at], strings.ToLower(email[at+1:]), nil
+}
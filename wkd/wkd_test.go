@@ -0,0 +1,104 @@
+package wkd
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ProtonMail/gopenpgp/v3/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHashLocalPart(t *testing.T) {
+	// Test vector of the WKD specification.
+	assert.Exactly(t, "iy9q119eutrkn8s1mk4r39qejnbu3n5q", HashLocalPart("Joe.Doe"))
+
+	advancedURL, err := AdvancedURL("Joe.Doe@Example.ORG")
+	require.NoError(t, err)
+	assert.Exactly(t,
+		"https://openpgpkey.example.org/.well-known/openpgpkey/example.org/hu/iy9q119eutrkn8s1mk4r39qejnbu3n5q?l=Joe.Doe",
+		advancedURL,
+	)
+	directURL, err := DirectURL("Joe.Doe@Example.ORG")
+	require.NoError(t, err)
+	assert.Exactly(t, "https://example.org/.well-known/openpgpkey/hu/iy9q119eutrkn8s1mk4r39qejnbu3n5q?l=Joe.Doe", directURL)
+
+	for _, invalid := range []string{"", "joe", "@example.org", "joe@", "Joe <joe@example.org>"} {
+		_, err := AdvancedURL(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+// newTestClient returns a client that connects to the server for the domain example.com,
+// which is in the certificate of the test server, and fails to connect to other hosts.
+func newTestClient(server *httptest.Server, hosts ...string) *Client {
+	transport := server.Client().Transport.(*http.Transport).Clone()
+	transport.DialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+		for _, host := range hosts {
+			if address == host+":443" {
+				return (&net.Dialer{}).DialContext(ctx, network, server.Listener.Addr().String())
+			}
+		}
+		return nil, &net.DNSError{Err: "no such host", Name: address, IsNotFound: true}
+	}
+	return &Client{HTTPClient: &http.Client{Transport: transport}}
+}
+
+func TestPublicationLookup(t *testing.T) {
+	key, err := crypto.PGP().KeyGeneration().AddUserId("Alice", "alice@example.com").New().GenerateKey()
+	require.NoError(t, err)
+	key, err = key.AddUserId("Alice", "alice@example.org", key.GetEntity().PrimaryKey.CreationTime.Unix())
+	require.NoError(t, err)
+	publication, err := NewPublication(key, "Alice@example.com")
+	require.NoError(t, err)
+	assert.Exactly(t, ".well-known/openpgpkey/hu/"+HashLocalPart("alice"), publication.DirectPath)
+	assert.Exactly(t, ".well-known/openpgpkey/example.com/hu/"+HashLocalPart("alice"), publication.AdvancedPath)
+	published, err := crypto.NewKey(publication.Key)
+	require.NoError(t, err)
+	assert.False(t, published.IsPrivate())
+	assert.Len(t, published.GetEntity().Identities, 1)
+
+	_, err = NewPublication(key, "bob@example.com")
+	assert.Error(t, err)
+
+	var requests []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Host+r.URL.Path)
+		switch r.URL.Path {
+		case "/" + publication.DirectPath, "/" + publication.AdvancedPath:
+			_, _ = w.Write(publication.Key)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	server := httptest.NewTLSServer(mux)
+	defer server.Close()
+
+	// Without the openpgpkey subdomain, the direct method is used.
+	keys, err := newTestClient(server, "example.com").Lookup(context.Background(), "alice@example.com")
+	require.NoError(t, err)
+	require.NotNil(t, keys)
+	assert.Exactly(t, 1, keys.CountEntities())
+	assert.Exactly(t, []string{"example.com/" + publication.DirectPath}, requests)
+
+	requests = nil
+	keys, err = newTestClient(server, "example.com", "openpgpkey.example.com").Lookup(context.Background(), "ALICE@example.com")
+	require.NoError(t, err)
+	require.NotNil(t, keys)
+	assert.Exactly(t, key.GetFingerprint(), keys.GetKeys()[0].GetFingerprint())
+	assert.Exactly(t, []string{"openpgpkey.example.com/" + publication.AdvancedPath}, requests)
+
+	// Unknown addresses are not found by either method.
+	requests = nil
+	keys, err = newTestClient(server, "example.com", "openpgpkey.example.com").Lookup(context.Background(), "bob@example.com")
+	require.NoError(t, err)
+	assert.Nil(t, keys)
+	assert.Len(t, requests, 2)
+
+	_, err = newTestClient(server).Lookup(context.Background(), "alice@example.com")
+	assert.Error(t, err)
+}